@@ -0,0 +1,272 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cel pairs the declarations against which expressions are checked
+// with the Interpreter which evaluates them, so that a service may compile
+// its expressions in one step.
+//
+// The checker does not depend on the interpreter, so the Env of this package
+// wraps a function which returns a checker.Env rather than extending it.
+package cel
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/parser"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// EnvFunc returns the checker.Env against which expressions are checked.
+// Type-check errors must be reported to the given errors.
+type EnvFunc func(errors *common.Errors) *checker.Env
+
+// Env compiles expressions against the declarations returned by an EnvFunc
+// into Interpretables of an Interpreter.
+type Env struct {
+	env         EnvFunc
+	interpreter interpreter.Interpreter
+	programOpts []interpreter.ProgramOption
+	parallelism int
+	compiled    func(name string, score *checker.ComplexityScore, issues *common.Errors)
+
+	complexityLimits *checker.ComplexityLimits
+
+	// The Interpretables planned by the last call of CompileAll, keyed by
+	// their checked expressions.
+	plansMutex sync.Mutex
+	plans      map[string]interpreter.Interpretable
+}
+
+// EnvOption configures an Env.
+type EnvOption func(*Env)
+
+// OnCompiled registers a hook which is called by CompileAll with the
+// complexity and issues of each named expression which compiled, in the order
+// of their names. The issues are nil when the expression reported none.
+func OnCompiled(
+	hook func(name string, score *checker.ComplexityScore, issues *common.Errors)) EnvOption {
+	return func(e *Env) {
+		e.compiled = hook
+	}
+}
+
+// Parallelism limits the number of expressions compiled concurrently by
+// CompileAll, which is the number of CPUs by default.
+func Parallelism(n int) EnvOption {
+	return func(e *Env) {
+		e.parallelism = n
+	}
+}
+
+// ProgramOptions configures the options of the Programs created by the Env.
+func ProgramOptions(opts ...interpreter.ProgramOption) EnvOption {
+	return func(e *Env) {
+		e.programOpts = append(e.programOpts, opts...)
+	}
+}
+
+// WarnComplexity reports a warning for each expression whose complexity
+// exceeds the limits, rather than an error.
+func WarnComplexity(limits *checker.ComplexityLimits) EnvOption {
+	return func(e *Env) {
+		e.complexityLimits = limits
+	}
+}
+
+// NewEnv creates an Env which checks expressions against the checker.Env
+// returned by env and evaluates them with the Interpreter. Expressions are
+// compiled concurrently, so env may be called concurrently unless the
+// Parallelism of the Env is 1.
+func NewEnv(env EnvFunc, interp interpreter.Interpreter, opts ...EnvOption) *Env {
+	e := &Env{env: env, interpreter: interp, parallelism: runtime.NumCPU()}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// ValidateEnvironment cross-checks the functions declared within the
+// checker.Env of the Env against the overloads of its Interpreter, e.g. as a
+// self-check when a service starts, so that a function which would
+// type-check but fail to evaluate is reported before any expression uses it.
+// See interpreter.ValidateDeclarations for the mismatches which are reported.
+func ValidateEnvironment(env *Env) error {
+	errors := common.NewErrors(common.NewStringSource("", "<input>"))
+	return interpreter.ValidateDeclarations(env.interpreter, env.env(errors).Functions())
+}
+
+// compiled is the outcome of compiling the text of an expression.
+type compiled struct {
+	interpretable interpreter.Interpretable
+	issues        []common.Error
+	score         *checker.ComplexityScore
+}
+
+// CompileAll compiles the expressions, keyed by name, e.g. to validate the
+// thousands of stored expressions of a service at startup. The expressions
+// are compiled concurrently, up to the Parallelism of the Env, and their
+// programs share a pool of constants. Expressions with the same text are
+// compiled once and share an Interpretable.
+//
+// The Interpretables are cached by their checked expressions, including the
+// types and references resolved against the checker.Env, until the next call
+// of CompileAll, which plans only the expressions which changed. An
+// expression whose text is unchanged is planned again when the checker.Env it
+// is checked against resolves it differently.
+//
+// The Interpretables of the expressions which compiled are returned along
+// with the errors and warnings of each expression which reported any, so
+// that one invalid expression does not prevent the others from being used.
+func (e *Env) CompileAll(expressions map[string]string) (
+	map[string]interpreter.Interpretable, map[string]*common.Errors) {
+	var texts []string
+	seen := make(map[string]bool)
+	for _, text := range expressions {
+		if !seen[text] {
+			seen[text] = true
+			texts = append(texts, text)
+		}
+	}
+	results := make(map[string]*compiled, len(texts))
+	e.plansMutex.Lock()
+	plans := &planCache{
+		previous: e.plans,
+		current:  make(map[string]interpreter.Interpretable, len(texts))}
+	e.plansMutex.Unlock()
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	opts := append([]interpreter.ProgramOption{
+		interpreter.SharedConstants(interpreter.NewConstants())}, e.programOpts...)
+	workers := e.parallelism
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for text := range jobs {
+				result := e.compileText(text, opts, plans)
+				mutex.Lock()
+				results[text] = result
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, text := range texts {
+		jobs <- text
+	}
+	close(jobs)
+	wg.Wait()
+	e.plansMutex.Lock()
+	e.plans = plans.current
+	e.plansMutex.Unlock()
+
+	var names []string
+	for name := range expressions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	interpretables := make(map[string]interpreter.Interpretable, len(names))
+	issues := make(map[string]*common.Errors)
+	for _, name := range names {
+		result := results[expressions[name]]
+		if len(result.issues) != 0 {
+			// The issues are reported against the source of each name.
+			errors := common.NewErrors(common.NewStringSource(expressions[name], name))
+			for _, issue := range result.issues {
+				errors.ReportIssue(issue)
+			}
+			issues[name] = errors
+		}
+		if result.interpretable == nil {
+			continue
+		}
+		interpretables[name] = result.interpretable
+		if e.compiled != nil {
+			e.compiled(name, result.score, issues[name])
+		}
+	}
+	return interpretables, issues
+}
+
+// compileText parses, checks, and plans the text of an expression, unless
+// the checked expression was planned before.
+func (e *Env) compileText(text string,
+	opts []interpreter.ProgramOption, plans *planCache) *compiled {
+	parsed, errors := parser.Parse(common.NewStringSource(text, ""), parser.AllMacros)
+	if len(errors.GetErrors()) != 0 {
+		return &compiled{issues: errors.GetIssues()}
+	}
+	checked := checker.Check(parsed, e.env(errors))
+	if len(errors.GetErrors()) != 0 {
+		return &compiled{issues: errors.GetIssues()}
+	}
+	score := checker.CheckedComplexity(checked)
+	if e.complexityLimits != nil {
+		if err := e.complexityLimits.Check(score); err != nil {
+			errors.ReportWarning(common.NewLocation(1, 0), "%s", err.Error())
+		}
+	}
+	interpretable := plans.plan(checked, func() interpreter.Interpretable {
+		program := interpreter.NewCheckedProgram(checked, opts...)
+		return e.interpreter.NewInterpretable(program)
+	})
+	return &compiled{
+		interpretable: interpretable,
+		issues:        errors.GetIssues(),
+		score:         score}
+}
+
+// planCache holds the Interpretables planned by a call of CompileAll along
+// with those of the previous call, keyed by the serialized CheckedExpr. The
+// key includes the source positions of the expression, which the
+// Interpretable reports along with its errors.
+type planCache struct {
+	mutex    sync.Mutex
+	previous map[string]interpreter.Interpretable
+	current  map[string]interpreter.Interpretable
+}
+
+// plan returns the Interpretable of the checked expression, which is created
+// with newInterpretable unless it was planned before.
+func (c *planCache) plan(checked *checkedpb.CheckedExpr,
+	newInterpretable func() interpreter.Interpretable) interpreter.Interpretable {
+	var buf proto.Buffer
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(checked); err != nil {
+		return newInterpretable()
+	}
+	key := string(buf.Bytes())
+	c.mutex.Lock()
+	interpretable, found := c.current[key]
+	if !found {
+		interpretable, found = c.previous[key]
+	}
+	c.mutex.Unlock()
+	if !found {
+		interpretable = newInterpretable()
+	}
+	c.mutex.Lock()
+	c.current[key] = interpretable
+	c.mutex.Unlock()
+	return interpretable
+}
@@ -0,0 +1,152 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cel
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+func newTestEnv(opts ...EnvOption) *Env {
+	provider := types.NewProvider(&expr.ParsedExpr{})
+	env := func(errors *common.Errors) *checker.Env {
+		env := checker.NewStandardEnv(packages.DefaultPackage, provider, errors)
+		env.Add(decls.NewIdent("x", decls.Int, nil))
+		return env
+	}
+	return NewEnv(env,
+		interpreter.NewStandardIntepreter(packages.DefaultPackage, provider),
+		opts...)
+}
+
+func TestEnv_CompileAll(t *testing.T) {
+	var compiled []string
+	env := newTestEnv(Parallelism(3),
+		OnCompiled(func(name string, score *checker.ComplexityScore, issues *common.Errors) {
+			warnings := 0
+			if issues != nil {
+				warnings = len(issues.GetWarnings())
+			}
+			compiled = append(compiled, fmt.Sprintf("%s:%d", name, warnings))
+		}))
+	expressions := map[string]string{
+		"bad":   "x +",
+		"dup":   "x * 2 > 10",
+		"neg":   "!!(x < 0)",
+		"pos":   "x * 2 > 10",
+		"undef": "y > 0",
+	}
+	interpretables, issues := env.CompileAll(expressions)
+	if len(interpretables) != 3 {
+		t.Errorf("Got %d interpretables, wanted 3", len(interpretables))
+	}
+	if interpretables["dup"] != interpretables["pos"] {
+		t.Error("Expressions of the same text were compiled twice")
+	}
+	if result, _ := interpretables["pos"].Eval(interpreter.NewActivation(
+		map[string]interface{}{"x": 7})); result != types.True {
+		t.Errorf("Got '%v' from 'pos', wanted true", result)
+	}
+	if len(issues) != 3 || issues["bad"] == nil || issues["undef"] == nil {
+		t.Errorf("Got issues for %d expressions, wanted 'bad', 'neg' and 'undef'",
+			len(issues))
+	}
+	if display := issues["undef"].ToDisplayString(); !strings.HasPrefix(display,
+		"ERROR: undef:1:1: undeclared reference to 'y'") {
+		t.Errorf("Got issues %s for 'undef'", display)
+	}
+	// The hook is called in the order of the names of the expressions which
+	// compiled.
+	if fmt.Sprint(compiled) != "[dup:0 neg:1 pos:0]" {
+		t.Errorf("Got compiled %v, wanted [dup:0 neg:1 pos:0]", compiled)
+	}
+}
+
+func TestEnv_CompileAllPlanCache(t *testing.T) {
+	provider := types.NewProvider(&expr.ParsedExpr{})
+	xType := decls.Int
+	env := func(errors *common.Errors) *checker.Env {
+		env := checker.NewStandardEnv(packages.DefaultPackage, provider, errors)
+		env.Add(decls.NewIdent("x", xType, nil))
+		return env
+	}
+	celEnv := NewEnv(env,
+		interpreter.NewStandardIntepreter(packages.DefaultPackage, provider))
+	first, _ := celEnv.CompileAll(map[string]string{
+		"a": "x == x",
+		"b": "x > 1",
+		"c": "x < 1"})
+	// Unchanged expressions are not planned again.
+	second, _ := celEnv.CompileAll(map[string]string{
+		"a": "x == x",
+		"b": "x > 1",
+		"c": "x < 2"})
+	if second["a"] != first["a"] || second["b"] != first["b"] {
+		t.Error("Got new interpretables for unchanged expressions")
+	}
+	if second["c"] == first["c"] {
+		t.Error("Got a cached interpretable for a changed expression")
+	}
+	// Expressions which are resolved differently by the Env are planned
+	// again, as are those which were not compiled by the last call.
+	xType = decls.Double
+	third, _ := celEnv.CompileAll(map[string]string{
+		"a": "x == x",
+		"b": "x > 1"})
+	if third["a"] == second["a"] {
+		t.Error("Got a cached interpretable for an expression of a new type")
+	}
+	if result, _ := third["a"].Eval(interpreter.NewActivation(
+		map[string]interface{}{"x": 1.5})); result != types.True {
+		t.Errorf("Got '%v', wanted true", result)
+	}
+	xType = decls.Int
+	fourth, _ := celEnv.CompileAll(map[string]string{"a": "x == x"})
+	if fourth["a"] == first["a"] {
+		t.Error("Got an interpretable cached before the last compilation")
+	}
+}
+
+func TestValidateEnvironment(t *testing.T) {
+	provider := types.NewProvider(&expr.ParsedExpr{})
+	var lookup bool
+	env := NewEnv(func(errors *common.Errors) *checker.Env {
+		env := checker.NewStandardEnv(packages.DefaultPackage, provider, errors)
+		if lookup {
+			env.Add(decls.NewFunction("lookup",
+				decls.NewOverload("lookup_string", []*checkedpb.Type{decls.String}, decls.String)))
+		}
+		return env
+	}, interpreter.NewStandardIntepreter(packages.DefaultPackage, provider))
+	if err := ValidateEnvironment(env); err != nil {
+		t.Errorf("Got '%v' for the standard environment", err)
+	}
+	lookup = true
+	err := ValidateEnvironment(env)
+	if compatErr, ok := err.(*interpreter.CompatibilityError); !ok ||
+		fmt.Sprint(compatErr.Mismatches) != "[no overload for declared function 'lookup']" {
+		t.Errorf("Got '%v', wanted an error for 'lookup'", err)
+	}
+}
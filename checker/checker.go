@@ -23,6 +23,10 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/google/cel-go/checker/decls"
 	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
 	expr "github.com/google/cel-spec/proto/v1/syntax"
@@ -32,23 +36,57 @@ type checker struct {
 	env                *Env
 	mappings           *mapping
 	freeTypeVarCounter int
-	sourceInfo         *expr.SourceInfo
+	sourceInfo         *ast.SourceInfo
 
 	types      map[int64]*checkedpb.Type
 	references map[int64]*checkedpb.Reference
+
+	// guards counts the presence tests, by qualified field name, which are
+	// known to hold for the expression under check.
+	guards map[string]int
+	// fieldNames holds the proto names of the message fields selected by
+	// expression id, so that the presence tests of fields selected by their
+	// JSON names guard the selections by their proto names, and vice versa.
+	fieldNames map[int64]string
 }
 
 func Check(parsedExpr *expr.ParsedExpr, env *Env) *checkedpb.CheckedExpr {
+	typeMap, referenceMap := check(astpb.FromParsedExpr(parsedExpr), env)
+	return &checkedpb.CheckedExpr{
+		Expr:         parsedExpr.GetExpr(),
+		SourceInfo:   parsedExpr.GetSourceInfo(),
+		TypeMap:      typeMap,
+		ReferenceMap: referenceMap,
+	}
+}
+
+// CheckAst type-checks the native ast representation of a parsed expression.
+func CheckAst(parsed *ast.ParsedExpr, env *Env) *checkedpb.CheckedExpr {
+	typeMap, referenceMap := check(parsed, env)
+	return &checkedpb.CheckedExpr{
+		Expr:         astpb.ToExpr(parsed.Expr),
+		SourceInfo:   astpb.ToSourceInfo(parsed.SourceInfo),
+		TypeMap:      typeMap,
+		ReferenceMap: referenceMap,
+	}
+}
+
+func check(parsed *ast.ParsedExpr, env *Env) (map[int64]*checkedpb.Type, map[int64]*checkedpb.Reference) {
 	c := checker{
 		env:                env,
 		mappings:           newMapping(),
 		freeTypeVarCounter: 0,
-		sourceInfo:         parsedExpr.GetSourceInfo(),
+		sourceInfo:         parsed.SourceInfo,
 
 		types:      make(map[int64]*checkedpb.Type),
 		references: make(map[int64]*checkedpb.Reference),
+		guards:     make(map[string]int),
+		fieldNames: make(map[int64]string),
+	}
+	c.check(parsed.Expr)
+	for _, usage := range deprecatedUsage(parsed.Expr, parsed.SourceInfo, c.references, env) {
+		env.errors.ReportIssue(usage.Issue())
 	}
-	c.check(parsedExpr.GetExpr())
 
 	// Walk over the final type map substituting any type parameters either by their bound value or
 	// by DYN.
@@ -57,85 +95,79 @@ func Check(parsedExpr *expr.ParsedExpr, env *Env) *checkedpb.CheckedExpr {
 		m[k] = substitute(c.mappings, v, true)
 	}
 
-	return &checkedpb.CheckedExpr{
-		Expr:         parsedExpr.GetExpr(),
-		SourceInfo:   parsedExpr.GetSourceInfo(),
-		TypeMap:      m,
-		ReferenceMap: c.references,
-	}
+	return m, c.references
 }
 
-func (c *checker) check(e *expr.Expr) {
+func (c *checker) check(e *ast.Expr) {
 	if e == nil {
 		return
 	}
 
-	switch e.ExprKind.(type) {
-	case *expr.Expr_LiteralExpr:
-		literal := e.GetLiteralExpr()
-		switch literal.LiteralKind.(type) {
-		case *expr.Literal_BoolValue:
+	switch kind := e.Kind.(type) {
+	case *ast.Literal:
+		switch kind.Value.(type) {
+		case bool:
 			c.checkBoolLiteral(e)
-		case *expr.Literal_BytesValue:
+		case []byte:
 			c.checkBytesLiteral(e)
-		case *expr.Literal_DoubleValue:
+		case float64:
 			c.checkDoubleLiteral(e)
-		case *expr.Literal_Int64Value:
+		case int64:
 			c.checkInt64Literal(e)
-		case *expr.Literal_NullValue:
+		case nil:
 			c.checkNullLiteral(e)
-		case *expr.Literal_StringValue:
+		case string:
 			c.checkStringLiteral(e)
-		case *expr.Literal_Uint64Value:
+		case uint64:
 			c.checkUint64Literal(e)
 		}
-	case *expr.Expr_IdentExpr:
+	case *ast.Ident:
 		c.checkIdent(e)
-	case *expr.Expr_SelectExpr:
+	case *ast.Select:
 		c.checkSelect(e)
-	case *expr.Expr_CallExpr:
+	case *ast.Call:
 		c.checkCall(e)
-	case *expr.Expr_ListExpr:
+	case *ast.CreateList:
 		c.checkCreateList(e)
-	case *expr.Expr_StructExpr:
+	case *ast.CreateStruct:
 		c.checkCreateStruct(e)
-	case *expr.Expr_ComprehensionExpr:
+	case *ast.Comprehension:
 		c.checkComprehension(e)
 	default:
 		panic(fmt.Sprintf("Unrecognized ast type: %v", reflect.TypeOf(e)))
 	}
 }
 
-func (c *checker) checkInt64Literal(e *expr.Expr) {
+func (c *checker) checkInt64Literal(e *ast.Expr) {
 	c.setType(e, decls.Int)
 }
 
-func (c *checker) checkUint64Literal(e *expr.Expr) {
+func (c *checker) checkUint64Literal(e *ast.Expr) {
 	c.setType(e, decls.Uint)
 }
 
-func (c *checker) checkStringLiteral(e *expr.Expr) {
+func (c *checker) checkStringLiteral(e *ast.Expr) {
 	c.setType(e, decls.String)
 }
 
-func (c *checker) checkBytesLiteral(e *expr.Expr) {
+func (c *checker) checkBytesLiteral(e *ast.Expr) {
 	c.setType(e, decls.Bytes)
 }
 
-func (c *checker) checkDoubleLiteral(e *expr.Expr) {
+func (c *checker) checkDoubleLiteral(e *ast.Expr) {
 	c.setType(e, decls.Double)
 }
 
-func (c *checker) checkBoolLiteral(e *expr.Expr) {
+func (c *checker) checkBoolLiteral(e *ast.Expr) {
 	c.setType(e, decls.Bool)
 }
 
-func (c *checker) checkNullLiteral(e *expr.Expr) {
+func (c *checker) checkNullLiteral(e *ast.Expr) {
 	c.setType(e, decls.Null)
 }
 
-func (c *checker) checkIdent(e *expr.Expr) {
-	identExpr := e.GetIdentExpr()
+func (c *checker) checkIdent(e *ast.Expr) {
+	identExpr := e.Kind.(*ast.Ident)
 	if ident := c.env.LookupIdent(identExpr.Name); ident != nil {
 		c.setType(e, ident.GetIdent().Type)
 		c.setReference(e, newIdentReference(ident.Name, ident.GetIdent().Value))
@@ -147,8 +179,8 @@ func (c *checker) checkIdent(e *expr.Expr) {
 		c.location(e), c.env.packager.Package(), identExpr.Name)
 }
 
-func (c *checker) checkSelect(e *expr.Expr) {
-	sel := e.GetSelectExpr()
+func (c *checker) checkSelect(e *ast.Expr) {
+	sel := e.Kind.(*ast.Select)
 	// Before traversing down the tree, try to interpret as qualified name.
 	qname, found := toQualifiedName(e)
 	if found {
@@ -177,6 +209,9 @@ func (c *checker) checkSelect(e *expr.Expr) {
 
 	case kindObject:
 		messageType := targetType
+		if protoName, _, valid := c.env.protoFieldName(messageType.GetMessageType(), sel.Field); valid {
+			c.fieldNames[e.Id] = protoName
+		}
 		if fieldType, found := c.lookupFieldType(c.location(e), messageType, sel.Field); found {
 			resultType = fieldType.Type
 			if sel.TestOnly && !fieldType.SupportsPresence {
@@ -188,6 +223,13 @@ func (c *checker) checkSelect(e *expr.Expr) {
 		mapType := targetType.GetMapType()
 		resultType = mapType.ValueType
 
+	case kindNull:
+		if c.env.propagateNullSelect {
+			resultType = decls.Null
+		} else {
+			c.env.errors.typeDoesNotSupportFieldSelection(c.location(e), targetType)
+		}
+
 	default:
 		c.env.errors.typeDoesNotSupportFieldSelection(c.location(e), targetType)
 	}
@@ -199,10 +241,27 @@ func (c *checker) checkSelect(e *expr.Expr) {
 	c.setType(e, resultType)
 }
 
-func (c *checker) checkCall(e *expr.Expr) {
-	call := e.GetCallExpr()
+func (c *checker) checkCall(e *ast.Expr) {
+	call := e.Kind.(*ast.Call)
 	// Traverse arguments.
-	for _, arg := range call.Args {
+	for i, arg := range call.Args {
+		if i == 1 && c.env.strictNullHandling &&
+			(call.Function == operators.LogicalAnd ||
+				call.Function == operators.LogicalOr ||
+				call.Function == operators.Conditional) {
+			// The second argument is only evaluated when the first is true, or
+			// false for a logical or.
+			var guards []string
+			if call.Function == operators.LogicalOr {
+				guards = c.absenceTests(call.Args[0])
+			} else {
+				guards = c.presenceTests(call.Args[0])
+			}
+			c.enterGuards(guards)
+			c.check(arg)
+			c.exitGuards(guards)
+			continue
+		}
 		c.check(arg)
 	}
 
@@ -239,6 +298,9 @@ func (c *checker) checkCall(e *expr.Expr) {
 	}
 
 	if resolution != nil {
+		if c.env.strictNullHandling {
+			c.checkNullableArgs(call)
+		}
 		c.setType(e, resolution.Type)
 		c.setReference(e, resolution.Reference)
 	} else {
@@ -246,9 +308,44 @@ func (c *checker) checkCall(e *expr.Expr) {
 	}
 }
 
+// checkNullableArgs reports wrapper fields which are provided as arguments to a
+// function that cannot accept null without a has() guard.
+func (c *checker) checkNullableArgs(call *ast.Call) {
+	switch call.Function {
+	case operators.Equals, operators.NotEquals, operators.Conditional:
+		// Functions which accept null operands.
+		return
+	}
+	args := call.Args
+	if call.Target != nil {
+		args = append([]*ast.Expr{call.Target}, args...)
+	}
+	for _, arg := range args {
+		sel, isSelect := arg.Kind.(*ast.Select)
+		if !isSelect || sel.TestOnly || kindOf(c.getType(arg)) != kindWrapper {
+			continue
+		}
+		if qname, found := c.guardName(arg); found && c.guards[qname] == 0 {
+			c.env.errors.unguardedNullableField(c.location(arg), qname)
+		}
+	}
+}
+
+func (c *checker) enterGuards(guards []string) {
+	for _, g := range guards {
+		c.guards[g]++
+	}
+}
+
+func (c *checker) exitGuards(guards []string) {
+	for _, g := range guards {
+		c.guards[g]--
+	}
+}
+
 func (c *checker) resolveOverload(
 	loc common.Location,
-	fn *checkedpb.Decl, target *expr.Expr, args []*expr.Expr) *overloadResolution {
+	fn *checkedpb.Decl, target *ast.Expr, args []*ast.Expr) *overloadResolution {
 
 	var argTypes []*checkedpb.Type
 	if target != nil {
@@ -304,12 +401,49 @@ func (c *checker) resolveOverload(
 		resultType = decls.Error
 		return nil
 	}
+	if len(checkedRef.OverloadId) == 1 {
+		resultType = c.refineResult(loc, checkedRef.OverloadId[0], resultType, target, args)
+	}
 
 	return newResolution(checkedRef, resultType)
 }
 
-func (c *checker) checkCreateList(e *expr.Expr) {
-	create := e.GetListExpr()
+// refineResult applies the result refinement of an overload, if any, to a
+// call whose target and arguments are all literals.
+func (c *checker) refineResult(loc common.Location, overloadId string,
+	resultType *checkedpb.Type, target *ast.Expr, args []*ast.Expr) *checkedpb.Type {
+	refine, found := c.env.refinements[overloadId]
+	if !found {
+		return resultType
+	}
+	if target != nil {
+		args = append([]*ast.Expr{target}, args...)
+	}
+	values := make([]ref.Value, len(args))
+	for i, arg := range args {
+		lit, isLiteral := arg.Kind.(*ast.Literal)
+		if !isLiteral {
+			return resultType
+		}
+		values[i] = types.NativeToValue(lit.Value)
+	}
+	refined, err := refine(values)
+	if err != nil {
+		c.env.errors.refinementFailed(loc, overloadId, err)
+		return decls.Error
+	}
+	if refined == nil {
+		return resultType
+	}
+	if !c.isAssignable(resultType, refined) {
+		c.env.errors.refinementNotAssignable(loc, overloadId, resultType, refined)
+		return decls.Error
+	}
+	return refined
+}
+
+func (c *checker) checkCreateList(e *ast.Expr) {
+	create := e.Kind.(*ast.CreateList)
 	var elemType *checkedpb.Type = nil
 	for _, e := range create.Elements {
 		c.check(e)
@@ -322,8 +456,8 @@ func (c *checker) checkCreateList(e *expr.Expr) {
 	c.setType(e, decls.NewListType(elemType))
 }
 
-func (c *checker) checkCreateStruct(e *expr.Expr) {
-	str := e.GetStructExpr()
+func (c *checker) checkCreateStruct(e *ast.Expr) {
+	str := e.Kind.(*ast.CreateStruct)
 	if str.MessageName != "" {
 		c.checkCreateMessage(e)
 	} else {
@@ -331,12 +465,12 @@ func (c *checker) checkCreateStruct(e *expr.Expr) {
 	}
 }
 
-func (c *checker) checkCreateMap(e *expr.Expr) {
-	mapVal := e.GetStructExpr()
+func (c *checker) checkCreateMap(e *ast.Expr) {
+	mapVal := e.Kind.(*ast.CreateStruct)
 	var keyType *checkedpb.Type = nil
 	var valueType *checkedpb.Type = nil
-	for _, ent := range mapVal.GetEntries() {
-		key := ent.GetMapKey()
+	for _, ent := range mapVal.Entries {
+		key := ent.MapKey
 		c.check(key)
 		keyType = c.joinTypes(c.location(key), keyType, c.getType(key))
 
@@ -351,8 +485,8 @@ func (c *checker) checkCreateMap(e *expr.Expr) {
 	c.setType(e, decls.NewMapType(keyType, valueType))
 }
 
-func (c *checker) checkCreateMessage(e *expr.Expr) {
-	msgVal := e.GetStructExpr()
+func (c *checker) checkCreateMessage(e *ast.Expr) {
+	msgVal := e.Kind.(*ast.CreateStruct)
 	// Determine the type of the message.
 	messageType := decls.Error
 	decl := c.env.LookupIdent(msgVal.MessageName)
@@ -379,8 +513,8 @@ func (c *checker) checkCreateMessage(e *expr.Expr) {
 	c.setType(e, messageType)
 
 	// Check the field initializers.
-	for _, ent := range msgVal.GetEntries() {
-		field := ent.GetFieldKey()
+	for _, ent := range msgVal.Entries {
+		field := ent.FieldKey
 		value := ent.Value
 		c.check(value)
 
@@ -394,8 +528,8 @@ func (c *checker) checkCreateMessage(e *expr.Expr) {
 	}
 }
 
-func (c *checker) checkComprehension(e *expr.Expr) {
-	comp := e.GetComprehensionExpr()
+func (c *checker) checkComprehension(e *ast.Expr) {
+	comp := e.Kind.(*ast.Comprehension)
 	c.check(comp.IterRange)
 	c.check(comp.AccuInit)
 	accuType := c.getType(comp.AccuInit)
@@ -475,7 +609,12 @@ func (c *checker) lookupFieldType(l common.Location, messageType *checkedpb.Type
 		return nil, false
 	}
 
-	if ft, found := c.env.typeProvider.FindFieldType(messageType, fieldName); found {
+	protoName, expected, valid := c.env.protoFieldName(messageType.GetMessageType(), fieldName)
+	if !valid {
+		c.env.errors.fieldNameConvention(l, fieldName, c.env.fieldNames, expected)
+		return nil, false
+	}
+	if ft, found := c.env.typeProvider.FindFieldType(messageType, protoName); found {
 		return ft, found
 	}
 
@@ -483,25 +622,25 @@ func (c *checker) lookupFieldType(l common.Location, messageType *checkedpb.Type
 	return nil, false
 }
 
-func (c *checker) setType(e *expr.Expr, t *checkedpb.Type) {
+func (c *checker) setType(e *ast.Expr, t *checkedpb.Type) {
 	if old, found := c.types[e.Id]; found && !proto.Equal(old, t) {
 		panic(fmt.Sprintf("(Incompatible) Type already exists for expression: %v(%d) old:%v, new:%v", e, e.Id, old, t))
 	}
 	c.types[e.Id] = t
 }
 
-func (c *checker) getType(e *expr.Expr) *checkedpb.Type {
+func (c *checker) getType(e *ast.Expr) *checkedpb.Type {
 	return c.types[e.Id]
 }
 
-func (c *checker) setReference(e *expr.Expr, r *checkedpb.Reference) {
+func (c *checker) setReference(e *ast.Expr, r *checkedpb.Reference) {
 	if old, found := c.references[e.Id]; found && !proto.Equal(old, r) {
 		panic(fmt.Sprintf("Reference already exists for expression: %v(%d) old:%v, new:%v", e, e.Id, old, r))
 	}
 	c.references[e.Id] = r
 }
 
-func (c *checker) assertType(e *expr.Expr, t *checkedpb.Type) {
+func (c *checker) assertType(e *ast.Expr, t *checkedpb.Type) {
 	if !c.isAssignable(t, c.getType(e)) {
 		c.env.errors.typeMismatch(c.location(e), t, c.getType(e))
 	}
@@ -519,17 +658,24 @@ func newResolution(checkedRef *checkedpb.Reference, t *checkedpb.Type) *overload
 	}
 }
 
-func (c *checker) location(e *expr.Expr) common.Location {
+func (c *checker) location(e *ast.Expr) common.Location {
 	return c.locationById(e.Id)
 }
 
 func (c *checker) locationById(id int64) common.Location {
-	positions := c.sourceInfo.GetPositions()
+	return sourceLocation(c.sourceInfo, id)
+}
+
+func sourceLocation(sourceInfo *ast.SourceInfo, id int64) common.Location {
+	if sourceInfo == nil {
+		return common.NoLocation
+	}
+	positions := sourceInfo.Positions
 	var line = 1
 	var col = 0
 	if offset, found := positions[id]; found {
 		col = int(offset)
-		for _, lineOffset := range c.sourceInfo.LineOffsets {
+		for _, lineOffset := range sourceInfo.LineOffsets {
 			if lineOffset < offset {
 				line += 1
 				col = int(offset - lineOffset)
@@ -550,16 +696,94 @@ func newFunctionReference(overloads ...string) *checkedpb.Reference {
 	return &checkedpb.Reference{OverloadId: overloads}
 }
 
+// presenceTests returns the qualified names of the fields which are tested for
+// presence by the conjuncts of a boolean expression, e.g. 'has(a.b) && has(c.d)'
+// or 'a.b != null'.
+func (c *checker) presenceTests(e *ast.Expr) []string {
+	switch kind := e.Kind.(type) {
+	case *ast.Select:
+		if kind.TestOnly {
+			if qname, found := c.guardName(e); found {
+				return []string{qname}
+			}
+		}
+	case *ast.Call:
+		call := kind
+		if len(call.Args) != 2 {
+			return nil
+		}
+		switch call.Function {
+		case operators.LogicalAnd:
+			return append(c.presenceTests(call.Args[0]), c.presenceTests(call.Args[1])...)
+		case operators.NotEquals:
+			return c.nullTest(call)
+		}
+	}
+	return nil
+}
+
+// absenceTests returns the qualified names of the fields which are tested for
+// absence by the disjuncts of a boolean expression, e.g. 'a.b == null ||
+// !has(c.d)', so that the fields are present when the expression is false.
+func (c *checker) absenceTests(e *ast.Expr) []string {
+	call, isCall := e.Kind.(*ast.Call)
+	if !isCall {
+		return nil
+	}
+	switch {
+	case call.Function == operators.LogicalOr && len(call.Args) == 2:
+		return append(c.absenceTests(call.Args[0]), c.absenceTests(call.Args[1])...)
+	case call.Function == operators.LogicalNot && len(call.Args) == 1:
+		return c.presenceTests(call.Args[0])
+	case call.Function == operators.Equals && len(call.Args) == 2:
+		return c.nullTest(call)
+	}
+	return nil
+}
+
+// nullTest returns the qualified name of the field selection which is compared
+// to the null literal by an equality or inequality test.
+func (c *checker) nullTest(call *ast.Call) []string {
+	for i, arg := range call.Args {
+		literal, isLiteral := call.Args[1-i].Kind.(*ast.Literal)
+		if !isLiteral || literal.Value != nil {
+			continue
+		}
+		if sel, isSelect := arg.Kind.(*ast.Select); isSelect && !sel.TestOnly {
+			if qname, found := c.guardName(arg); found {
+				return []string{qname}
+			}
+		}
+	}
+	return nil
+}
+
+// guardName returns the qualified name of a checked field selection, as for
+// toQualifiedName, with the fields of messages named by their proto names.
+func (c *checker) guardName(e *ast.Expr) (string, bool) {
+	switch kind := e.Kind.(type) {
+	case *ast.Ident:
+		return kind.Name, true
+	case *ast.Select:
+		if qname, found := c.guardName(kind.Operand); found {
+			if protoName, found := c.fieldNames[e.Id]; found {
+				return qname + "." + protoName, true
+			}
+			return qname + "." + kind.Field, true
+		}
+	}
+	return "", false
+}
+
 // Attempt to interpret an expression as a qualified name. This traverses select and getIdent
 // expression and returns the name they constitute, or null if the expression cannot be
 // interpreted like this.
-func toQualifiedName(e *expr.Expr) (string, bool) {
-	switch e.ExprKind.(type) {
-	case *expr.Expr_IdentExpr:
-		i := e.GetIdentExpr()
-		return i.Name, true
-	case *expr.Expr_SelectExpr:
-		s := e.GetSelectExpr()
+func toQualifiedName(e *ast.Expr) (string, bool) {
+	switch kind := e.Kind.(type) {
+	case *ast.Ident:
+		return kind.Name, true
+	case *ast.Select:
+		s := kind
 		if qname, found := toQualifiedName(s.Operand); found {
 			return qname + "." + s.Field, true
 		}
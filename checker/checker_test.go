@@ -16,13 +16,16 @@ package checker
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common"
 	"github.com/google/cel-go/common/packages"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
 	"github.com/google/cel-go/parser"
 	"github.com/google/cel-go/test"
 	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
@@ -293,6 +296,12 @@ _?_:_(_||_(_&&_(false~bool, !_(true~bool)~bool^logical_not)~bool^logical_and,
 		Type: decls.Int,
 	},
 
+	{
+		I:    `coalesce({"a": 1}["b"], 2)`,
+		R:    `coalesce(_[_]({"a"~string:1~int}~map(string, int), "b"~string)~int^index_map, 2~int)~int^coalesce`,
+		Type: decls.Int,
+	},
+
 	{
 		I:    `b"abc" + b"def"`,
 		R:    `_+_(b"abc"~bytes, b"def"~bytes)~bytes^add_bytes`,
@@ -638,6 +647,26 @@ ERROR: <input>:1:79: field 'repeated_int32' does not support presence check
 		`,
 	},
 
+	{
+		I: `has(x.single_nested_enum) && whichOneof(x, 'nested_type') == 'single_nested_enum'`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		R: `_&&_(
+    		  x~google.api.tools.expr.test.TestAllTypes^x.single_nested_enum~test-only~~bool,
+    		  _==_(
+    		    whichOneof(
+    		      x~google.api.tools.expr.test.TestAllTypes^x,
+    		      "nested_type"~string
+    		    )~string^whichOneof,
+    		    "single_nested_enum"~string
+    		  )~bool^equals
+    		)~bool^logical_and`,
+		Type: decls.Bool,
+	},
+
 	{
 		I: `x.single_nested_message != null`,
 		Env: env{
@@ -964,6 +993,182 @@ _&&_(_==_(list~type(list(dyn))^list,
     		)~bool^equals`,
 		Type: decls.Bool,
 	},
+
+	{
+		I: `x.single_int64_wrapper + 1 != 23`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{StrictNullHandling()},
+		Error: `ERROR: <input>:1:2: field 'x.single_int64_wrapper' may be null, guard the selection with 'has(x.single_int64_wrapper)'
+    		| x.single_int64_wrapper + 1 != 23
+    		| .^`,
+	},
+
+	{
+		I: `x.single_int64_wrapper == null || x.single_int64_wrapper > 0`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{StrictNullHandling()},
+		Type: decls.Bool,
+	},
+
+	{
+		I: `x.single_int64_wrapper != null && x.single_int64_wrapper > 0`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{StrictNullHandling()},
+		Type: decls.Bool,
+	},
+
+	{
+		I: `x.single_int64_wrapper != null ? x.single_int64_wrapper + 1 : 0`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{StrictNullHandling()},
+		Type: decls.Int,
+	},
+
+	{
+		I: `x.single_int64_wrapper == null && x.single_int64_wrapper > 0`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{StrictNullHandling()},
+		Error: `ERROR: <input>:1:36: field 'x.single_int64_wrapper' may be null, guard the selection with 'has(x.single_int64_wrapper)'
+    		| x.single_int64_wrapper == null && x.single_int64_wrapper > 0
+    		| ...................................^`,
+	},
+
+	{
+		I: `has(x.single_int64_wrapper) && x.single_int64_wrapper > 0`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{StrictNullHandling()},
+		Type: decls.Bool,
+	},
+
+	{
+		I: `has(x.single_int64_wrapper) ? x.single_int64_wrapper + 1 : 0`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{StrictNullHandling()},
+		Type: decls.Int,
+	},
+
+	{
+		I: `x.single_int64_wrapper != null`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{StrictNullHandling()},
+		Type: decls.Bool,
+	},
+
+	{
+		I:    `null.a.b`,
+		Opts: []EnvOption{PropagateNullSelect()},
+		Type: decls.Null,
+	},
+
+	{
+		I: `null.a`,
+		Error: `
+ERROR: <input>:1:5: type 'null:NULL_VALUE ' does not support field selection
+ | null.a
+ | ....^`,
+	},
+
+	{
+		I: `has(x.singleInt64) && x.single_int64 > 0`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Error: `
+ERROR: <input>:1:4: field 'singleInt64' does not follow the proto field name convention, use 'single_int64'
+ | has(x.singleInt64) && x.single_int64 > 0
+ | ...^`,
+	},
+
+	{
+		I: `has(x.singleInt64Wrapper) && x.single_int64_wrapper > 0`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{FieldNames(JSONFieldNames)},
+		Error: `
+ERROR: <input>:1:31: field 'single_int64_wrapper' does not follow the json field name convention, use 'singleInt64Wrapper'
+ | has(x.singleInt64Wrapper) && x.single_int64_wrapper > 0
+ | ..............................^`,
+	},
+
+	{
+		I: `has(x.singleInt64Wrapper) ? x.singleInt64Wrapper + x.single_int32 : 0`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{FieldNames(JSONFieldNames)},
+		Error: `
+ERROR: <input>:1:53: field 'single_int32' does not follow the json field name convention, use 'singleInt32'
+ | has(x.singleInt64Wrapper) ? x.singleInt64Wrapper + x.single_int32 : 0
+ | ....................................................^`,
+	},
+
+	{
+		I:         `TestAllTypes{singleInt64: 1, repeatedString: ['a']}`,
+		Container: "google.api.tools.expr.test",
+		Opts:      []EnvOption{FieldNames(JSONFieldNames)},
+		Type:      decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"),
+	},
+
+	{
+		I: `has(x.singleInt64Wrapper) && x.single_int64_wrapper > 0`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{FieldNames(ProtoOrJSONFieldNames), StrictNullHandling()},
+		Type: decls.Bool,
+	},
+
+	{
+		I: `x.single_int64_wrapper > 0 && x.singleInt64Wrapper > 0`,
+		Env: env{
+			idents: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil),
+			},
+		},
+		Opts: []EnvOption{FieldNames(ProtoOrJSONFieldNames)},
+		Type: decls.Bool,
+	},
 }
 
 var typeProvider = initTypeProvider()
@@ -1009,6 +1214,9 @@ type testInfo struct {
 	// Env is the environment to use for testing.
 	Env env
 
+	// Opts are the environment options to use for testing.
+	Opts []EnvOption
+
 	// Error is the expected error for negative test cases.
 	Error string
 }
@@ -1031,7 +1239,7 @@ func Test(t *testing.T) {
 			}
 
 			pkg := packages.NewPackage(tst.Container)
-			env := NewEnv(pkg, typeProvider, errors)
+			env := NewEnv(pkg, typeProvider, errors, tst.Opts...)
 			env.Add(StandardDeclarations()...)
 
 			if tst.Env.idents != nil {
@@ -1075,3 +1283,286 @@ func Test(t *testing.T) {
 		})
 	}
 }
+
+func TestReportDynUsage(t *testing.T) {
+	expression, errors := parser.ParseText(`dyn(1) + ii == d.b && [].size() == 0`)
+	if len(errors.GetErrors()) > 0 {
+		t.Fatalf("Unexpected parse errors: %v", errors.ToDisplayString())
+	}
+	env := NewStandardEnv(packages.DefaultPackage, typeProvider, errors)
+	env.Add(decls.NewIdent("ii", decls.Int, nil),
+		decls.NewIdent("d", decls.Dyn, nil))
+	checked := Check(expression, env)
+	if len(errors.GetErrors()) > 0 {
+		t.Fatalf("Unexpected type-check errors: %v", errors.ToDisplayString())
+	}
+
+	var actual []string
+	for _, usage := range ReportDynUsage(checked) {
+		actual = append(actual, usage.String())
+	}
+	expected := []string{
+		"1:3: expression #2 has type 'dyn' (explicit dyn() conversion)",
+		"1:16: expression #6 has type 'dyn' (dynamic operand)",
+		"1:15: expression #5 has type 'dyn' (declared as dyn)",
+		"1:22: expression #8 has type 'list(dyn)' (heterogeneous or empty literal)",
+	}
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("Got '%v', wanted '%v'", actual, expected)
+	}
+}
+
+func TestCheckAst(t *testing.T) {
+	parsed, errors := parser.ParseAst(
+		common.NewStringSource(`[1, 2].exists(x, x > ii)`, "<input>"),
+		parser.AllMacros)
+	if len(errors.GetErrors()) > 0 {
+		t.Fatalf("Unexpected parse errors: %v", errors.ToDisplayString())
+	}
+	env := NewStandardEnv(packages.DefaultPackage, typeProvider, errors)
+	env.Add(decls.NewIdent("ii", decls.Int, nil))
+	checked := CheckAst(parsed, env)
+	if len(errors.GetErrors()) > 0 {
+		t.Fatalf("Unexpected type-check errors: %v", errors.ToDisplayString())
+	}
+	if actual := checked.TypeMap[parsed.Expr.Id]; !proto.Equal(actual, decls.Bool) {
+		t.Error(test.DiffMessage("Type Error", actual, decls.Bool))
+	}
+	if checked.GetExpr().GetId() != parsed.Expr.Id {
+		t.Errorf("Got expression id %d, wanted %d",
+			checked.GetExpr().GetId(), parsed.Expr.Id)
+	}
+}
+
+func TestReportDeprecatedUsage(t *testing.T) {
+	parsed, errors := parser.ParseText(
+		"geo.country(ip) == 'US' && old_ip != ip && size(old_ip) > 0 && geo.region(ip) != ''")
+	if len(errors.GetErrors()) > 0 {
+		t.Fatalf("Unexpected parse errors: %v", errors.ToDisplayString())
+	}
+	env := NewStandardEnv(packages.DefaultPackage, typeProvider, errors,
+		Deprecated("old_ip", "use 'ip' instead"),
+		Deprecated("geo.country", ""),
+		Deprecated("size_string", "use 'old_ip.size()' instead"))
+	env.Add(GeoDeclarations()...)
+	env.Add(decls.NewIdent("ip", decls.String, nil),
+		decls.NewIdent("old_ip", decls.String, nil))
+	checked := Check(parsed, env)
+	if len(errors.GetErrors()) > 0 {
+		t.Fatalf("Unexpected type-check errors: %v", errors.ToDisplayString())
+	}
+	usages := ReportDeprecatedUsage(checked, env)
+	var actual []string
+	for _, usage := range usages {
+		actual = append(actual, usage.String())
+	}
+	expected := []string{
+		"1:11: 'geo.country' is deprecated",
+		"1:27: 'old_ip' is deprecated: use 'ip' instead",
+		"1:47: 'size_string' is deprecated: use 'old_ip.size()' instead",
+		"1:48: 'old_ip' is deprecated: use 'ip' instead",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Got:\n%s\nwanted:\n%s", strings.Join(actual, "\n"),
+			strings.Join(expected, "\n"))
+	}
+	// The uses are reported as warnings by the check.
+	if warnings := errors.GetWarnings(); len(warnings) != len(usages) ||
+		warnings[2].Message != "'size_string' is deprecated: use 'old_ip.size()' instead" ||
+		warnings[2].Severity != common.SeverityWarning {
+		t.Errorf("Got warnings %v", warnings)
+	}
+	counts := CountDeprecatedUsage(usages, usages[1:2])
+	if fmt.Sprint(counts) != "map[geo.country:1 old_ip:3 size_string:1]" {
+		t.Errorf("Got counts %v", counts)
+	}
+	if ReportDeprecatedUsage(checked, NewStandardEnv(packages.DefaultPackage,
+		typeProvider, errors)) != nil {
+		t.Error("Got deprecated usage within an Env without deprecations")
+	}
+}
+
+func TestEnvConfig(t *testing.T) {
+	errors := common.NewErrors(common.NewStringSource("", "<input>"))
+	env := NewEnv(packages.NewPackage("google.api"), typeProvider, errors,
+		StrictNullHandling())
+	env.Add(decls.NewIdent("ii", decls.Int, nil))
+	env.Add(MathDeclarations()...)
+	config := env.Config()
+	expected := &EnvConfig{
+		Container:          "google.api",
+		StrictNullHandling: true,
+		Idents:             []string{"ii"},
+		Functions: []string{
+			"math.abs", "math.bitAnd", "math.bitNot", "math.bitOr",
+			"math.bitShiftLeft", "math.bitShiftRight", "math.bitXor",
+			"math.ceil", "math.floor", "math.greatest", "math.isFinite",
+			"math.isInf", "math.isNaN", "math.least", "math.round", "math.sqrt"},
+		Overloads: []string{
+			"math_abs_double", "math_abs_int", "math_abs_uint",
+			"math_bit_and_int_int", "math_bit_and_uint_uint",
+			"math_bit_not_int", "math_bit_not_uint",
+			"math_bit_or_int_int", "math_bit_or_uint_uint",
+			"math_bit_shift_left_int_int", "math_bit_shift_left_uint_int",
+			"math_bit_shift_right_int_int", "math_bit_shift_right_uint_int",
+			"math_bit_xor_int_int", "math_bit_xor_uint_uint",
+			"math_ceil_double", "math_floor_double",
+			"math_greatest_double", "math_greatest_double_double",
+			"math_greatest_int", "math_greatest_int_int",
+			"math_greatest_list_double", "math_greatest_list_int",
+			"math_greatest_list_uint", "math_greatest_uint",
+			"math_greatest_uint_uint",
+			"math_is_finite_double", "math_is_inf_double", "math_is_nan_double",
+			"math_least_double", "math_least_double_double",
+			"math_least_int", "math_least_int_int",
+			"math_least_list_double", "math_least_list_int",
+			"math_least_list_uint", "math_least_uint", "math_least_uint_uint",
+			"math_round_double",
+			"math_sqrt_double", "math_sqrt_int", "math_sqrt_uint"}}
+	if fmt.Sprint(config) != fmt.Sprint(expected) {
+		t.Errorf("Got %+v, wanted %+v", config, expected)
+	}
+	geo := NewEnv(packages.DefaultPackage, typeProvider, errors)
+	geo.Add(GeoDeclarations()...)
+	expected = &EnvConfig{
+		Functions: []string{"geo.country", "geo.region"},
+		Overloads: []string{"geo_country_string", "geo_region_string"}}
+	if fmt.Sprint(geo.Config()) != fmt.Sprint(expected) {
+		t.Errorf("Got %+v, wanted %+v", geo.Config(), expected)
+	}
+	standard := NewStandardEnv(packages.DefaultPackage, typeProvider, errors)
+	if len(standard.Config().Overloads) <= len(config.Overloads) {
+		t.Errorf("Got %d standard overloads, wanted more than %d",
+			len(standard.Config().Overloads), len(config.Overloads))
+	}
+}
+
+func TestEnvVariables(t *testing.T) {
+	errors := common.NewErrors(common.NewStringSource("", "<input>"))
+	env := NewStandardEnv(packages.DefaultPackage, typeProvider, errors)
+	env.Add(decls.NewIdent("request", decls.NewMapType(decls.String, decls.Dyn), nil),
+		decls.NewIdent("ii", decls.Int, nil))
+	// Types and enum values referenced by expressions are not variables.
+	env.LookupIdent("google.expr.proto3.test.TestAllTypes")
+	env.LookupIdent("google.expr.proto3.test.GlobalEnum.GAZ")
+	if variables := env.Variables(); fmt.Sprint(variables) != "[ii request]" {
+		t.Errorf("Got variables %v, wanted [ii request]", variables)
+	}
+}
+
+func TestProviderComparisons(t *testing.T) {
+	provider := types.NewProvider()
+	provider.RegisterType(types.NewTypeValue("acme.Version", traits.ComparerType),
+		types.NewTypeValue("acme.Point"))
+	version := decls.NewObjectType("acme.Version")
+	point := decls.NewObjectType("acme.Point")
+	for _, tst := range []struct {
+		text string
+		err  string
+	}{
+		{text: `installed < required`},
+		{text: `installed >= required && required > installed`},
+		{text: `size(name) <= 10 && installed <= required`},
+		{text: `origin < origin`,
+			err: "found no matching overload for '_<_' applied to '(acme.Point, acme.Point)'"},
+	} {
+		expression, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) > 0 {
+			t.Fatalf("Unexpected parse errors: %v", errors.ToDisplayString())
+		}
+		env := NewStandardEnv(packages.DefaultPackage, provider, errors)
+		env.Add(decls.NewIdent("installed", version, nil),
+			decls.NewIdent("required", version, nil),
+			decls.NewIdent("origin", point, nil),
+			decls.NewIdent("name", decls.String, nil))
+		checked := Check(expression, env)
+		if tst.err != "" {
+			if !strings.Contains(errors.ToDisplayString(), tst.err) {
+				t.Errorf("%s: got errors '%s', wanted '%s'",
+					tst.text, errors.ToDisplayString(), tst.err)
+			}
+			continue
+		}
+		if len(errors.GetErrors()) > 0 {
+			t.Fatalf("%s: unexpected type-check errors: %v", tst.text, errors.ToDisplayString())
+		}
+		if actual := checked.TypeMap[checked.Expr.Id]; !proto.Equal(actual, decls.Bool) {
+			t.Error(test.DiffMessage(tst.text, actual, decls.Bool))
+		}
+	}
+	// The comparisons declared by NewStandardEnv may be declared again.
+	errors := common.NewErrors(common.NewStringSource("", "<input>"))
+	env := NewStandardEnv(packages.DefaultPackage, provider, errors)
+	env.Add(ComparisonDeclarations(types.NewTypeValue("acme.Version",
+		traits.ComparerType))...)
+	if len(errors.GetErrors()) > 0 {
+		t.Errorf("Got errors for redeclared comparisons: %v", errors.ToDisplayString())
+	}
+}
+
+func TestRefineResult(t *testing.T) {
+	schema := map[string]*checkedpb.Type{
+		"name": decls.String,
+		"age":  decls.Int,
+		"tags": decls.NewListType(decls.String)}
+	refineField := RefineResult("dyn_field_string",
+		func(args []ref.Value) (*checkedpb.Type, error) {
+			name := string(args[0].(types.String))
+			if t, found := schema[name]; found {
+				return t, nil
+			}
+			if name == "any" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("no field '%s'", name)
+		})
+	refineLabel := RefineResult("label_string",
+		func(args []ref.Value) (*checkedpb.Type, error) {
+			return decls.Int, nil
+		})
+	for _, tst := range []struct {
+		text     string
+		expected *checkedpb.Type
+		err      string
+	}{
+		{text: `dyn_field('age')`, expected: decls.Int},
+		{text: `dyn_field('tags')`, expected: decls.NewListType(decls.String)},
+		{text: `dyn_field('age') + 1`, expected: decls.Int},
+		{text: `dyn_field(s)`, expected: decls.Dyn},
+		{text: `dyn_field('any')`, expected: decls.Dyn},
+		{text: `dyn_field('nme')`,
+			err: "refinement of the result of 'dyn_field_string' failed: no field 'nme'"},
+		{text: `dyn_field('name') + 1`,
+			err: "found no matching overload for '_+_' applied to '(string, int)'"},
+		{text: `label('a')`,
+			err: "refined result type 'int' of 'label_string' is not assignable to declared type 'string'"},
+	} {
+		expression, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) > 0 {
+			t.Fatalf("Unexpected parse errors: %v", errors.ToDisplayString())
+		}
+		env := NewStandardEnv(packages.DefaultPackage, typeProvider, errors,
+			refineField, refineLabel)
+		env.Add(decls.NewIdent("s", decls.String, nil),
+			decls.NewFunction("dyn_field",
+				decls.NewOverload("dyn_field_string",
+					[]*checkedpb.Type{decls.String}, decls.Dyn)),
+			decls.NewFunction("label",
+				decls.NewOverload("label_string",
+					[]*checkedpb.Type{decls.String}, decls.String)))
+		checked := Check(expression, env)
+		if tst.err != "" {
+			if !strings.Contains(errors.ToDisplayString(), tst.err) {
+				t.Errorf("%s: got errors '%s', wanted '%s'",
+					tst.text, errors.ToDisplayString(), tst.err)
+			}
+			continue
+		}
+		if len(errors.GetErrors()) > 0 {
+			t.Fatalf("%s: unexpected type-check errors: %v", tst.text, errors.ToDisplayString())
+		}
+		if actual := checked.TypeMap[checked.Expr.Id]; !proto.Equal(actual, tst.expected) {
+			t.Error(test.DiffMessage(tst.text, actual, tst.expected))
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+// ComplexityScore describes the size and shape of an expression, e.g. for
+// rejecting user-supplied expressions which exceed the quota of a tenant
+// before they are stored or evaluated.
+//
+// The score is computed from the parsed expression, so the nodes generated
+// by the expansion of macros such as 'exists' are counted along with those
+// written by the user.
+type ComplexityScore struct {
+	// Nodes is the number of nodes in the expression tree.
+	Nodes int
+
+	// Comprehensions is the number of comprehensions, and
+	// ComprehensionDepth the greatest nesting of one comprehension within
+	// another, which bounds the polynomial degree of the evaluation cost.
+	Comprehensions     int
+	ComprehensionDepth int
+
+	// Depth is the greatest depth of a node within the expression tree, where
+	// the root is at a depth of one.
+	Depth int
+
+	// LiteralBytes is the total length in bytes of the string and bytes
+	// literals.
+	LiteralBytes int
+
+	// Calls is the number of calls of each function, including operators
+	// such as '_+_'.
+	Calls map[string]int
+
+	// Overloads is the number of calls of each overload, by overload id, when
+	// the score is computed by CheckedComplexity, or nil otherwise.
+	Overloads map[string]int
+}
+
+// Complexity returns the ComplexityScore of a parsed or checked expression.
+func Complexity(e *expr.Expr) *ComplexityScore {
+	s := &ComplexityScore{Calls: make(map[string]int)}
+	s.visit(e, 0, 1)
+	return s
+}
+
+// CheckedComplexity returns the ComplexityScore of a checked expression, along
+// with the calls of each overload resolved by the checker.
+func CheckedComplexity(checked *checkedpb.CheckedExpr) *ComplexityScore {
+	s := Complexity(checked.GetExpr())
+	s.Overloads = make(map[string]int)
+	for _, reference := range checked.GetReferenceMap() {
+		for _, overloadId := range reference.GetOverloadId() {
+			s.Overloads[overloadId]++
+		}
+	}
+	return s
+}
+
+// CallCount returns the total number of calls.
+func (s *ComplexityScore) CallCount() int {
+	count := 0
+	for _, calls := range s.Calls {
+		count += calls
+	}
+	return count
+}
+
+// String implements the fmt.Stringer interface method, with a breakdown of
+// the calls by function, e.g.
+//
+//	12 nodes, 1 comprehension (depth 1), 4 calls (_==_: 2, _&&_: 1, size: 1)
+func (s *ComplexityScore) String() string {
+	functions := make([]string, 0, len(s.Calls))
+	for function := range s.Calls {
+		functions = append(functions, function)
+	}
+	// The most frequent calls are listed first.
+	sort.Slice(functions, func(i, j int) bool {
+		if s.Calls[functions[i]] != s.Calls[functions[j]] {
+			return s.Calls[functions[i]] > s.Calls[functions[j]]
+		}
+		return functions[i] < functions[j]
+	})
+	calls := make([]string, len(functions))
+	for i, function := range functions {
+		calls[i] = fmt.Sprintf("%s: %d", function, s.Calls[function])
+	}
+	text := fmt.Sprintf("%s, %s (depth %d), %s",
+		plural(s.Nodes, "node"), plural(s.Comprehensions, "comprehension"),
+		s.ComprehensionDepth, plural(s.CallCount(), "call"))
+	if len(calls) != 0 {
+		text += " (" + strings.Join(calls, ", ") + ")"
+	}
+	return text
+}
+
+// visit scores an expression at the given depth of nesting within
+// comprehensions and within the expression tree.
+func (s *ComplexityScore) visit(e *expr.Expr, depth int, height int) {
+	if e == nil {
+		return
+	}
+	s.Nodes++
+	if height > s.Depth {
+		s.Depth = height
+	}
+	switch e.ExprKind.(type) {
+	case *expr.Expr_LiteralExpr:
+		switch c := e.GetLiteralExpr().LiteralKind.(type) {
+		case *expr.Literal_StringValue:
+			s.LiteralBytes += len(c.StringValue)
+		case *expr.Literal_BytesValue:
+			s.LiteralBytes += len(c.BytesValue)
+		}
+	case *expr.Expr_SelectExpr:
+		s.visit(e.GetSelectExpr().Operand, depth, height+1)
+	case *expr.Expr_CallExpr:
+		call := e.GetCallExpr()
+		s.Calls[call.Function]++
+		s.visit(call.Target, depth, height+1)
+		for _, arg := range call.Args {
+			s.visit(arg, depth, height+1)
+		}
+	case *expr.Expr_ListExpr:
+		for _, elem := range e.GetListExpr().Elements {
+			s.visit(elem, depth, height+1)
+		}
+	case *expr.Expr_StructExpr:
+		for _, entry := range e.GetStructExpr().Entries {
+			s.visit(entry.GetMapKey(), depth, height+1)
+			s.visit(entry.Value, depth, height+1)
+		}
+	case *expr.Expr_ComprehensionExpr:
+		comp := e.GetComprehensionExpr()
+		s.Comprehensions++
+		if depth+1 > s.ComprehensionDepth {
+			s.ComprehensionDepth = depth + 1
+		}
+		// The range and the initial value of the accumulator are evaluated
+		// once, outside of the loop.
+		s.visit(comp.IterRange, depth, height+1)
+		s.visit(comp.AccuInit, depth, height+1)
+		s.visit(comp.LoopCondition, depth+1, height+1)
+		s.visit(comp.LoopStep, depth+1, height+1)
+		s.visit(comp.Result, depth, height+1)
+	}
+}
+
+// ComplexityLimits are the thresholds of a ComplexityScore, e.g. the quota
+// of one tenant of a platform which evaluates user-supplied expressions.
+// Zero values are unlimited.
+type ComplexityLimits struct {
+	MaxNodes              int
+	MaxComprehensions     int
+	MaxComprehensionDepth int
+	MaxCalls              int
+
+	// MaxFunctionCalls limits the number of calls of individual functions,
+	// e.g. of expensive functions such as 'matches'.
+	MaxFunctionCalls map[string]int
+}
+
+// Check returns an error describing each limit exceeded by the score, along
+// with the breakdown of the score, or nil when the score is within the
+// limits.
+func (l *ComplexityLimits) Check(score *ComplexityScore) error {
+	var exceeded []string
+	check := func(what string, value, max int) {
+		if max != 0 && value > max {
+			exceeded = append(exceeded,
+				fmt.Sprintf("%s %d exceeds the limit of %d", what, value, max))
+		}
+	}
+	check("node count", score.Nodes, l.MaxNodes)
+	check("comprehension count", score.Comprehensions, l.MaxComprehensions)
+	check("comprehension depth", score.ComprehensionDepth, l.MaxComprehensionDepth)
+	check("call count", score.CallCount(), l.MaxCalls)
+	functions := make([]string, 0, len(l.MaxFunctionCalls))
+	for function := range l.MaxFunctionCalls {
+		functions = append(functions, function)
+	}
+	sort.Strings(functions)
+	for _, function := range functions {
+		check(fmt.Sprintf("call count of '%s'", function),
+			score.Calls[function], l.MaxFunctionCalls[function])
+	}
+	if len(exceeded) == 0 {
+		return nil
+	}
+	return fmt.Errorf("expression is too complex: %s; %v",
+		strings.Join(exceeded, ", "), score)
+}
+
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/parser"
+)
+
+func TestComplexity(t *testing.T) {
+	for _, tst := range []struct {
+		text     string
+		expected string
+	}{
+		{text: `a`,
+			expected: `1 node, 0 comprehensions (depth 0), 0 calls`},
+		{text: `a.b == 1 && size(c) > 2`,
+			expected: `9 nodes, 0 comprehensions (depth 0), 4 calls (_&&_: 1, _==_: 1, _>_: 1, size: 1)`},
+		{text: `{'k': [1, 2]}`,
+			expected: `5 nodes, 0 comprehensions (depth 0), 0 calls`},
+		{text: `a.exists(x, x.all(y, y > 0))`,
+			expected: `18 nodes, 2 comprehensions (depth 2), 4 calls (!_: 1, _&&_: 1, _>_: 1, _||_: 1)`},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		if score := Complexity(parsed.GetExpr()).String(); score != tst.expected {
+			t.Errorf("%s: got '%s', wanted '%s'", tst.text, score, tst.expected)
+		}
+	}
+}
+
+func TestComplexity_Shape(t *testing.T) {
+	parsed, errors := parser.ParseText(`a.b.c == 'abc' || [b'\x00\x01'].exists(x, x == d)`)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	score := Complexity(parsed.GetExpr())
+	if score.Depth != 5 || score.LiteralBytes != 5 || score.Overloads != nil {
+		t.Errorf("Got depth %d and %d literal bytes, wanted 5 and 5",
+			score.Depth, score.LiteralBytes)
+	}
+
+	parsed, errors = parser.ParseText(`a.b + a.c > 1 && size(a) == 2 && size('s') == 1`)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	env := NewStandardEnv(packages.DefaultPackage, typeProvider, errors)
+	env.Add(decls.NewIdent("a", decls.NewMapType(decls.String, decls.Int), nil))
+	checked := Check(parsed, env)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	score = CheckedComplexity(checked)
+	for overload, count := range map[string]int{
+		"add_int64":     1,
+		"size_map":      1,
+		"size_string":   1,
+		"equals":        2,
+		"greater_int64": 1,
+		"logical_and":   2,
+	} {
+		if score.Overloads[overload] != count {
+			t.Errorf("Got %d calls of '%s', wanted %d", score.Overloads[overload],
+				overload, count)
+		}
+	}
+}
+
+func TestComplexityLimits(t *testing.T) {
+	parsed, errors := parser.ParseText(
+		`a.exists(x, x.matches('a+') && b.exists(y, y.matches(x)))`)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	score := Complexity(parsed.GetExpr())
+	for _, tst := range []struct {
+		limits   ComplexityLimits
+		exceeded []string
+	}{
+		{limits: ComplexityLimits{}},
+		{limits: ComplexityLimits{
+			MaxNodes:              100,
+			MaxComprehensionDepth: 2,
+			MaxFunctionCalls:      map[string]int{"matches": 2}}},
+		{limits: ComplexityLimits{
+			MaxComprehensionDepth: 1,
+			MaxFunctionCalls:      map[string]int{"matches": 1}},
+			exceeded: []string{
+				"comprehension depth 2 exceeds the limit of 1",
+				"call count of 'matches' 2 exceeds the limit of 1"}},
+		{limits: ComplexityLimits{MaxNodes: 10, MaxComprehensions: 1, MaxCalls: 5},
+			exceeded: []string{
+				"node count 23 exceeds the limit of 10",
+				"comprehension count 2 exceeds the limit of 1",
+				"call count 7 exceeds the limit of 5"}},
+	} {
+		err := tst.limits.Check(score)
+		if len(tst.exceeded) == 0 {
+			if err != nil {
+				t.Errorf("Got '%v' for %+v, wanted no error", err, tst.limits)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("Got no error for %+v, wanted %v", tst.limits, tst.exceeded)
+			continue
+		}
+		for _, exceeded := range append(tst.exceeded, score.String()) {
+			if !strings.Contains(err.Error(), exceeded) {
+				t.Errorf("Got '%v' for %+v, wanted '%s'", err, tst.limits, exceeded)
+			}
+		}
+	}
+}
@@ -4,6 +4,8 @@ package decls
 import (
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/golang/protobuf/ptypes/struct"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
 	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
 	expr "github.com/google/cel-spec/proto/v1/syntax"
 )
@@ -58,6 +60,21 @@ func NewFunction(name string,
 				Overloads: overloads}}}
 }
 
+// NewInFunctions creates the declarations of an overload of the 'in'
+// operator, and of the deprecated 'in()' function, for a container type, e.g.
+// an abstract set of IP ranges, whose elements are of the element type.
+//
+// The values of the container type are tested for membership through the
+// traits.Container interface when the expression is evaluated.
+func NewInFunctions(id string, elem *checkedpb.Type,
+	container *checkedpb.Type) []*checkedpb.Decl {
+	return []*checkedpb.Decl{
+		NewFunction(operators.In,
+			NewOverload(id, []*checkedpb.Type{elem, container}, Bool)),
+		NewFunction(overloads.DeprecatedIn,
+			NewOverload(id, []*checkedpb.Type{elem, container}, Bool))}
+}
+
 // NewIdent creates a named identifier declaration with an optional literal
 // value.
 //
@@ -71,6 +88,17 @@ func NewIdent(name string, t *checkedpb.Type, v *expr.Literal) *checkedpb.Decl {
 				Value: v}}}
 }
 
+// NewVar creates a variable declaration of the given type, e.g.
+//
+//     decls.NewVar("tags", decls.NewListType(decls.String))
+//     decls.NewVar("labels", decls.NewMapType(decls.String, decls.String))
+//
+// Values bound to the variable at evaluation time may be native Go slices and
+// maps with any element types supported by types.NativeToValue.
+func NewVar(name string, t *checkedpb.Type) *checkedpb.Decl {
+	return NewIdent(name, t, nil)
+}
+
 // NewInstanceOverload creates a instance function overload contract.
 func NewInstanceOverload(id string, argTypes []*checkedpb.Type,
 	resultType *checkedpb.Type) *checkedpb.Decl_FunctionDecl_Overload {
@@ -14,10 +14,16 @@
 
 package decls
 
-import checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+import (
+	"sort"
+
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
 
 type Scopes struct {
 	scopes []*Group
+	// parent holds the declarations which the global scope extends, if any.
+	parent *Scopes
 }
 
 func NewScopes() *Scopes {
@@ -26,6 +32,16 @@ func NewScopes() *Scopes {
 	}
 }
 
+// Extend returns Scopes whose global scope extends the global scope of s, so
+// that declarations may be added to the new Scopes without copying or
+// modifying those of s. The Scopes s must not be modified afterwards.
+func (s *Scopes) Extend() *Scopes {
+	return &Scopes{
+		scopes: []*Group{newGroup()},
+		parent: s,
+	}
+}
+
 func (s *Scopes) Push() {
 	g := newGroup()
 	s.scopes = append(s.scopes, g)
@@ -46,6 +62,9 @@ func (s *Scopes) FindIdent(name string) *checkedpb.Decl {
 			return ident
 		}
 	}
+	if s.parent != nil {
+		return s.parent.FindIdent(name)
+	}
 	return nil
 }
 
@@ -53,6 +72,10 @@ func (s *Scopes) FindIdentInScope(name string) *checkedpb.Decl {
 	if ident, found := s.scopes[len(s.scopes)-1].idents[name]; found {
 		return ident
 	}
+	// The global scope includes the declarations it extends.
+	if len(s.scopes) == 1 && s.parent != nil {
+		return s.parent.FindIdentInScope(name)
+	}
 	return nil
 }
 
@@ -67,9 +90,50 @@ func (s *Scopes) FindFunction(name string) *checkedpb.Decl {
 			return fn
 		}
 	}
+	if s.parent != nil {
+		return s.parent.FindFunction(name)
+	}
+	return nil
+}
+
+// FindOwnFunction returns the function declared within s rather than within
+// the Scopes it extends, or nil if there is none.
+func (s *Scopes) FindOwnFunction(name string) *checkedpb.Decl {
+	for i := len(s.scopes) - 1; i >= 0; i-- {
+		if fn, found := s.scopes[i].functions[name]; found {
+			return fn
+		}
+	}
 	return nil
 }
 
+// Names returns the sorted names of the identifiers and functions declared
+// within all scopes.
+func (s *Scopes) Names() (idents []string, functions []string) {
+	identSet := make(map[string]bool)
+	functionSet := make(map[string]bool)
+	for scopes := s; scopes != nil; scopes = scopes.parent {
+		for _, scope := range scopes.scopes {
+			for name := range scope.idents {
+				identSet[name] = true
+			}
+			for name := range scope.functions {
+				functionSet[name] = true
+			}
+		}
+	}
+	return sortedNames(identSet), sortedNames(functionSet)
+}
+
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 type Group struct {
 	idents    map[string]*checkedpb.Decl
 	functions map[string]*checkedpb.Decl
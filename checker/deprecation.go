@@ -0,0 +1,132 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// DeprecatedUsage describes a reference to a declaration which was marked as
+// Deprecated within the Env an expression was checked against.
+type DeprecatedUsage struct {
+	Id       int64
+	Location common.Location
+
+	// Name is the name of the variable or function, or the id of the
+	// function overload, which was marked as deprecated.
+	Name string
+
+	// Replacement is the hint given when the declaration was deprecated.
+	Replacement string
+}
+
+// String implements the fmt.Stringer interface method.
+func (u *DeprecatedUsage) String() string {
+	return fmt.Sprintf("%d:%d: %s",
+		u.Location.Line(), u.Location.Column(), u.Issue().Message)
+}
+
+// Issue returns the usage as a warning.
+func (u *DeprecatedUsage) Issue() common.Error {
+	message := fmt.Sprintf("'%s' is deprecated", u.Name)
+	if u.Replacement != "" {
+		message += ": " + u.Replacement
+	}
+	return common.Error{
+		Location: u.Location,
+		Message:  message,
+		Severity: common.SeverityWarning}
+}
+
+// ReportDeprecatedUsage lists the references of a checked expression to the
+// variables, functions, and function overloads which are deprecated within
+// the Env, in pre-order. A call is reported once, by the name of its function
+// when the function is deprecated, and otherwise by the id of its first
+// deprecated overload.
+//
+// The uses are warnings rather than errors: expressions which use deprecated
+// declarations continue to check and evaluate, and Check reports each use as
+// a warning to the errors of the Env.
+func ReportDeprecatedUsage(checked *checkedpb.CheckedExpr, env *Env) []*DeprecatedUsage {
+	return deprecatedUsage(astpb.FromExpr(checked.GetExpr()),
+		astpb.FromSourceInfo(checked.GetSourceInfo()), checked.GetReferenceMap(), env)
+}
+
+// deprecatedUsage lists the references to deprecated declarations within an
+// expression, as ReportDeprecatedUsage.
+func deprecatedUsage(root *ast.Expr, sourceInfo *ast.SourceInfo,
+	references map[int64]*checkedpb.Reference, env *Env) []*DeprecatedUsage {
+	if len(env.deprecations) == 0 {
+		return nil
+	}
+	functions := env.overloadFunctions()
+	var usages []*DeprecatedUsage
+	ast.Visit(root, func(e *ast.Expr, parent *ast.Expr) bool {
+		reference, found := references[e.Id]
+		if !found {
+			return true
+		}
+		var names []string
+		if len(reference.GetOverloadId()) == 0 {
+			names = append(names, reference.GetName())
+		} else {
+			names = append(names, functions[reference.GetOverloadId()[0]])
+			names = append(names, reference.GetOverloadId()...)
+		}
+		for _, name := range names {
+			if replacement, deprecated := env.deprecations[name]; deprecated {
+				usages = append(usages, &DeprecatedUsage{
+					Id:          e.Id,
+					Location:    sourceLocation(sourceInfo, e.Id),
+					Name:        name,
+					Replacement: replacement})
+				break
+			}
+		}
+		return true
+	})
+	return usages
+}
+
+// CountDeprecatedUsage aggregates the reports of ReportDeprecatedUsage, e.g.
+// for each of the stored expressions revalidated against an Env, into the
+// number of uses of each deprecated declaration.
+func CountDeprecatedUsage(reports ...[]*DeprecatedUsage) map[string]int {
+	counts := make(map[string]int)
+	for _, report := range reports {
+		for _, usage := range report {
+			counts[usage.Name]++
+		}
+	}
+	return counts
+}
+
+// overloadFunctions returns the names of the declared functions by the ids
+// of their overloads.
+func (e *Env) overloadFunctions() map[string]string {
+	_, functions := e.declarations.Names()
+	names := make(map[string]string)
+	for _, name := range functions {
+		for _, overload := range e.declarations.FindFunction(name).GetFunction().GetOverloads() {
+			names[overload.GetOverloadId()] = name
+		}
+	}
+	return names
+}
@@ -0,0 +1,215 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/overloads"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+// DynReason indicates why the static type of an expression is dynamic.
+type DynReason int
+
+const (
+	// DynDeclared indicates a variable, field, or function result which is
+	// declared with a dynamic type.
+	DynDeclared DynReason = iota + 1
+
+	// DynAny indicates a value of type google.protobuf.Any whose content type
+	// is only known at evaluation time.
+	DynAny
+
+	// DynLiteral indicates a list or map literal whose elements are dynamic,
+	// either because the literal is empty or its elements are heterogeneous.
+	DynLiteral
+
+	// DynConversion indicates an explicit conversion with dyn().
+	DynConversion
+
+	// DynOverload indicates a call which matches more than one overload and
+	// whose result type is therefore dynamic.
+	DynOverload
+
+	// DynOperand indicates an expression which is dynamic because one of its
+	// operands is dynamic.
+	DynOperand
+)
+
+// String implements the fmt.Stringer interface method.
+func (r DynReason) String() string {
+	switch r {
+	case DynDeclared:
+		return "declared as dyn"
+	case DynAny:
+		return "google.protobuf.Any value"
+	case DynLiteral:
+		return "heterogeneous or empty literal"
+	case DynConversion:
+		return "explicit dyn() conversion"
+	case DynOverload:
+		return "ambiguous overload resolution"
+	case DynOperand:
+		return "dynamic operand"
+	}
+	return fmt.Sprintf("DynReason(%d)", int(r))
+}
+
+// DynUsage describes a sub-expression whose static type is, or contains, dyn.
+type DynUsage struct {
+	Id       int64
+	Location common.Location
+	Type     *checkedpb.Type
+	Reason   DynReason
+}
+
+// String implements the fmt.Stringer interface method.
+func (u *DynUsage) String() string {
+	return fmt.Sprintf("%d:%d: expression #%d has type '%s' (%s)",
+		u.Location.Line(), u.Location.Column(), u.Id,
+		FormatCheckedType(u.Type), u.Reason)
+}
+
+// Issue returns the usage as an informational issue, since dynamic types are
+// valid but defer the detection of type errors to evaluation.
+func (u *DynUsage) Issue() common.Error {
+	return common.Error{
+		Location: u.Location,
+		Message: fmt.Sprintf("expression #%d has type '%s' (%s)",
+			u.Id, FormatCheckedType(u.Type), u.Reason),
+		Severity: common.SeverityInfo}
+}
+
+// ReportDynUsage lists every sub-expression of a checked expression whose type
+// degraded to dyn, in pre-order, along with the reason for the degradation.
+//
+// The report is intended as an audit of gradual typing within an environment:
+// declaring variables and functions with concrete types removes the need for
+// dynamic dispatch during evaluation and allows more errors to be caught when
+// the expression is checked.
+func ReportDynUsage(checked *checkedpb.CheckedExpr) []*DynUsage {
+	r := &dynReporter{
+		checked:    checked,
+		sourceInfo: astpb.FromSourceInfo(checked.GetSourceInfo()),
+	}
+	r.visit(checked.GetExpr())
+	return r.usages
+}
+
+type dynReporter struct {
+	checked    *checkedpb.CheckedExpr
+	sourceInfo *ast.SourceInfo
+	usages     []*DynUsage
+}
+
+func (r *dynReporter) visit(e *expr.Expr) {
+	if e == nil {
+		return
+	}
+	if t := r.checked.TypeMap[e.Id]; isDynamic(t) {
+		r.usages = append(r.usages, &DynUsage{
+			Id:       e.Id,
+			Location: sourceLocation(r.sourceInfo, e.Id),
+			Type:     t,
+			Reason:   r.reason(e, t),
+		})
+	}
+	switch e.ExprKind.(type) {
+	case *expr.Expr_SelectExpr:
+		if _, found := r.checked.ReferenceMap[e.Id]; !found {
+			r.visit(e.GetSelectExpr().Operand)
+		}
+	case *expr.Expr_CallExpr:
+		call := e.GetCallExpr()
+		r.visit(call.Target)
+		for _, arg := range call.Args {
+			r.visit(arg)
+		}
+	case *expr.Expr_ListExpr:
+		for _, elem := range e.GetListExpr().Elements {
+			r.visit(elem)
+		}
+	case *expr.Expr_StructExpr:
+		for _, entry := range e.GetStructExpr().Entries {
+			r.visit(entry.GetMapKey())
+			r.visit(entry.Value)
+		}
+	case *expr.Expr_ComprehensionExpr:
+		comp := e.GetComprehensionExpr()
+		r.visit(comp.IterRange)
+		r.visit(comp.AccuInit)
+		r.visit(comp.LoopCondition)
+		r.visit(comp.LoopStep)
+		r.visit(comp.Result)
+	}
+}
+
+func (r *dynReporter) reason(e *expr.Expr, t *checkedpb.Type) DynReason {
+	if kindOf(t) == kindWellKnown && t.GetWellKnown() == checkedpb.Type_ANY {
+		return DynAny
+	}
+	switch e.ExprKind.(type) {
+	case *expr.Expr_SelectExpr:
+		operand := e.GetSelectExpr().Operand
+		if _, found := r.checked.ReferenceMap[e.Id]; !found &&
+			isDynamic(r.checked.TypeMap[operand.Id]) {
+			return DynOperand
+		}
+	case *expr.Expr_CallExpr:
+		call := e.GetCallExpr()
+		overloadIds := r.checked.ReferenceMap[e.Id].GetOverloadId()
+		if len(overloadIds) == 1 && overloadIds[0] == overloads.ToDyn {
+			return DynConversion
+		}
+		if len(overloadIds) > 1 {
+			return DynOverload
+		}
+		if call.Target != nil && isDynamic(r.checked.TypeMap[call.Target.Id]) {
+			return DynOperand
+		}
+		for _, arg := range call.Args {
+			if isDynamic(r.checked.TypeMap[arg.Id]) {
+				return DynOperand
+			}
+		}
+	case *expr.Expr_ListExpr, *expr.Expr_StructExpr:
+		return DynLiteral
+	case *expr.Expr_ComprehensionExpr:
+		return DynOperand
+	}
+	return DynDeclared
+}
+
+// isDynamic returns whether the type is dyn, google.protobuf.Any, or an
+// aggregate whose type parameters are dynamic.
+func isDynamic(t *checkedpb.Type) bool {
+	switch kindOf(t) {
+	case kindDyn:
+		return true
+	case kindWellKnown:
+		return t.GetWellKnown() == checkedpb.Type_ANY
+	case kindList:
+		return isDynamic(t.GetListType().ElemType)
+	case kindMap:
+		m := t.GetMapType()
+		return isDynamic(m.KeyType) || isDynamic(m.ValueType)
+	}
+	return false
+}
@@ -15,10 +15,15 @@
 package checker
 
 import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
 	"github.com/google/cel-go/checker/decls"
 	"github.com/google/cel-go/common"
 	"github.com/google/cel-go/common/packages"
 	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/pb"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/parser"
 	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
@@ -31,30 +36,257 @@ type Env struct {
 	typeProvider ref.TypeProvider
 
 	declarations *decls.Scopes
+	refinements  map[string]ResultRefinement
+	deprecations map[string]string
+
+	fieldNames          FieldNameConvention
+	propagateNullSelect bool
+	strictNullHandling  bool
+}
+
+// FieldNameConvention is the naming convention of the fields selected from,
+// and set within, protobuf messages.
+type FieldNameConvention int
+
+const (
+	// ProtoFieldNames selects fields by their names within the proto, e.g.
+	// 'single_int64'. This is the default.
+	ProtoFieldNames FieldNameConvention = iota
+	// JSONFieldNames selects fields by their names within the JSON encoding of
+	// the proto, e.g. 'singleInt64'.
+	JSONFieldNames
+	// ProtoOrJSONFieldNames accepts either name, e.g. while migrating
+	// expressions from one convention to the other.
+	ProtoOrJSONFieldNames
+)
+
+func (c FieldNameConvention) String() string {
+	switch c {
+	case ProtoFieldNames:
+		return "proto"
+	case JSONFieldNames:
+		return "json"
+	case ProtoOrJSONFieldNames:
+		return "proto or json"
+	}
+	return fmt.Sprintf("FieldNameConvention(%d)", int(c))
+}
+
+// EnvOption configures optional type-checking behaviors of an Env.
+type EnvOption func(*Env)
+
+// StrictNullHandling rejects expressions which pass a possibly-null wrapper
+// field to a function that requires a value, unless the field selection is
+// guarded by a has() or null test, e.g. 'has(x.f) && x.f > 0',
+// 'x.f != null && x.f > 0', or 'x.f == null || x.f > 0'.
+//
+// Without this option such expressions type-check, but produce an error at
+// evaluation time whenever the field is unset.
+func StrictNullHandling() EnvOption {
+	return func(e *Env) {
+		e.strictNullHandling = true
+	}
+}
+
+// FieldNames configures the naming convention of the fields of protobuf
+// messages, and reports the selection of a field by a name which does not
+// follow the convention, e.g. 'msg.singleInt64' when proto field names are
+// expected. Mixing conventions would otherwise be prone to presence tests
+// and selections which refer to the same field by different names.
+//
+// Fields are only found by their proto names during evaluation. Programs
+// created from the checked expression by interpreter.NewCheckedProgram
+// resolve the JSON names accepted by the checker to the proto names, while
+// unchecked expressions must select fields by their proto names.
+func FieldNames(convention FieldNameConvention) EnvOption {
+	return func(e *Env) {
+		e.fieldNames = convention
+	}
+}
+
+// PropagateNullSelect types the selection of a field from null as null rather
+// than as an error, e.g. 'x.f' where 'x' is null. The option should be paired
+// with the interpreter.PropagateNullSelect program option, which evaluates such
+// selections to null, as in the path semantics of jsonpath and jq.
+func PropagateNullSelect() EnvOption {
+	return func(e *Env) {
+		e.propagateNullSelect = true
+	}
+}
+
+// Deprecated marks the variable, function, or function overload with the
+// given name or overload id as deprecated, along with a hint describing its
+// replacement, if any, e.g. "use 'request.auth.principal' instead".
+//
+// Deprecated declarations continue to type-check. Their uses are reported by
+// ReportDeprecatedUsage, e.g. to warn the authors of new expressions, and to
+// count the stored expressions which must be migrated before the
+// declarations are removed.
+func Deprecated(name string, replacement string) EnvOption {
+	return func(e *Env) {
+		if e.deprecations == nil {
+			e.deprecations = make(map[string]string)
+		}
+		e.deprecations[name] = replacement
+	}
+}
+
+// ResultRefinement computes a more specific result type for a call of a
+// function overload whose arguments are all literals, e.g. the type of a field
+// named by its argument within a schema registry. The arguments include the
+// target of a receiver-style call.
+//
+// A nil type keeps the declared result type of the overload, and an error is
+// reported as a type-checking error of the call.
+type ResultRefinement func(args []ref.Value) (*checkedpb.Type, error)
+
+// RefineResult refines the result type of the overload with the given id when
+// it is called with literal arguments. The refined type must be assignable to
+// the declared result type, so a refinement tightens the static type of the
+// call without changing the overload which is dispatched at evaluation time:
+//
+//     checker.RefineResult("dyn_field_string",
+//         func(args []ref.Value) (*checkedpb.Type, error) {
+//             return registry.FieldType(string(args[0].(types.String)))
+//         })
+func RefineResult(overloadId string, refine ResultRefinement) EnvOption {
+	return func(e *Env) {
+		if e.refinements == nil {
+			e.refinements = make(map[string]ResultRefinement)
+		}
+		e.refinements[overloadId] = refine
+	}
 }
 
 func NewEnv(packager packages.Packager,
 	typeProvider ref.TypeProvider,
-	errors *common.Errors) *Env {
+	errors *common.Errors,
+	opts ...EnvOption) *Env {
 	declarations := decls.NewScopes()
 	declarations.Push()
 
-	return &Env{
+	e := &Env{
 		errors:       &typeErrors{errors},
 		packager:     packager,
 		typeProvider: typeProvider,
 		declarations: declarations,
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 func NewStandardEnv(packager packages.Packager,
 	typeProvider ref.TypeProvider,
-	errors *common.Errors) *Env {
-	e := NewEnv(packager, typeProvider, errors)
+	errors *common.Errors,
+	opts ...EnvOption) *Env {
+	e := NewEnv(packager, typeProvider, errors, opts...)
 	e.Add(StandardDeclarations()...)
+	e.Add(providerComparisons(typeProvider)...)
 	return e
 }
 
+// EnvConfig describes the options and declarations an Env was created with.
+type EnvConfig struct {
+	// Container is the package within which names are resolved.
+	Container string
+
+	FieldNames          FieldNameConvention
+	PropagateNullSelect bool
+	StrictNullHandling  bool
+
+	// Idents and Functions hold the sorted names of the declared identifiers
+	// and functions, including those of opt-in declaration sets such as
+	// MathDeclarations.
+	Idents    []string
+	Functions []string
+
+	// Overloads holds the sorted ids of the declared function overloads.
+	Overloads []string
+}
+
+// Config returns the configuration of the Env, e.g. for logging and comparing
+// the type-checking behavior of different services.
+func (e *Env) Config() *EnvConfig {
+	idents, functions := e.declarations.Names()
+	var overloads []string
+	for _, name := range functions {
+		for _, overload := range e.declarations.FindFunction(name).GetFunction().GetOverloads() {
+			overloads = append(overloads, overload.GetOverloadId())
+		}
+	}
+	sort.Strings(overloads)
+	return &EnvConfig{
+		Container:           e.packager.Package(),
+		FieldNames:          e.fieldNames,
+		PropagateNullSelect: e.propagateNullSelect,
+		StrictNullHandling:  e.strictNullHandling,
+		Idents:              idents,
+		Functions:           functions,
+		Overloads:           overloads}
+}
+
+// Variables returns the sorted names of the declared variables, i.e. the
+// identifiers which are neither types nor constants such as enum values, and
+// which must therefore be supplied by the activation an expression is
+// evaluated with.
+func (e *Env) Variables() []string {
+	idents, _ := e.declarations.Names()
+	var variables []string
+	for _, name := range idents {
+		ident := e.declarations.FindIdent(name).GetIdent()
+		if ident.GetValue() != nil || ident.GetType().GetType() != nil {
+			continue
+		}
+		variables = append(variables, name)
+	}
+	return variables
+}
+
+// Functions returns the declarations of the functions of the Env, sorted by
+// name.
+func (e *Env) Functions() []*checkedpb.Decl {
+	_, names := e.declarations.Names()
+	functions := make([]*checkedpb.Decl, len(names))
+	for i, name := range names {
+		functions[i] = e.declarations.FindFunction(name)
+	}
+	return functions
+}
+
+// protoFieldName returns the proto name of the field of a message type
+// selected by the given name, or false if the name does not follow the field
+// naming convention of the Env, along with the name the field should be
+// selected by. Names which are not those of fields, and the fields of types
+// which are not protobuf messages, are returned as they are.
+func (e *Env) protoFieldName(messageType string, name string) (string, string, bool) {
+	td, err := pb.DescribeType(messageType)
+	if err != nil {
+		return name, "", true
+	}
+	protoField, isProtoName := td.FieldByName(name)
+	jsonField, isJSONName := td.FieldByJSONName(name)
+	switch e.fieldNames {
+	case ProtoFieldNames:
+		if !isProtoName && isJSONName {
+			return "", jsonField.OrigName(), false
+		}
+	case JSONFieldNames:
+		if isJSONName {
+			return jsonField.OrigName(), "", true
+		}
+		if isProtoName {
+			return "", protoField.JSONName(), false
+		}
+	case ProtoOrJSONFieldNames:
+		if !isProtoName && isJSONName {
+			return jsonField.OrigName(), "", true
+		}
+	}
+	return name, "", true
+}
+
 func (e *Env) Add(decls ...*checkedpb.Decl) {
 	for _, decl := range decls {
 		switch decl.DeclKind.(type) {
@@ -73,6 +305,11 @@ func (e *Env) addOverload(f *checkedpb.Decl, overload *checkedpb.Decl_FunctionDe
 		overload.GetParams()...)
 	overloadErased := substitute(emptyMappings, overloadFunction, true)
 	for _, existing := range function.GetOverloads() {
+		// Redeclaring an overload, such as a comparison declared for the types
+		// of the TypeProvider, has no effect.
+		if proto.Equal(existing, overload) {
+			return
+		}
 		existingFunction := decls.NewFunctionType(existing.GetResultType(),
 			existing.GetParams()...)
 		existingErased := substitute(emptyMappings, existingFunction, true)
@@ -97,10 +334,16 @@ func (e *Env) addOverload(f *checkedpb.Decl, overload *checkedpb.Decl_FunctionDe
 }
 
 func (e *Env) addFunction(decl *checkedpb.Decl) {
-	current := e.declarations.FindFunction(decl.Name)
+	current := e.declarations.FindOwnFunction(decl.Name)
 	if current == nil {
 		//Add the function declaration without overloads and check the overloads below.
 		current = decls.NewFunction(decl.Name)
+		// The overloads of a function inherited from the base of a derived Env
+		// are copied rather than modified, as the base is shared.
+		if inherited := e.declarations.FindFunction(decl.Name); inherited != nil {
+			current.GetFunction().Overloads = append(current.GetFunction().GetOverloads(),
+				inherited.GetFunction().GetOverloads()...)
+		}
 		e.declarations.AddFunction(current)
 	}
 
@@ -40,10 +40,20 @@ func (e *typeErrors) undefinedField(l common.Location, field string) {
 	e.ReportError(l, "undefined field '%s'", field)
 }
 
+func (e *typeErrors) fieldNameConvention(l common.Location, field string,
+	convention FieldNameConvention, expected string) {
+	e.ReportError(l, "field '%s' does not follow the %s field name convention, use '%s'",
+		field, convention, expected)
+}
+
 func (e *typeErrors) fieldDoesNotSupportPresenceCheck(l common.Location, field string) {
 	e.ReportError(l, "field '%s' does not support presence check", field)
 }
 
+func (e *typeErrors) unguardedNullableField(l common.Location, field string) {
+	e.ReportError(l, "field '%s' may be null, guard the selection with 'has(%s)'", field, field)
+}
+
 func (e *typeErrors) overlappingOverload(l common.Location, name string, overloadId1 string, f1 *checkedpb.Type,
 	overloadId2 string, f2 *checkedpb.Type) {
 	e.ReportError(l, "overlapping overload for name '%s' (type '%s' with overloadId: '%s' cannot be distinguished from '%s' with "+
@@ -60,6 +70,16 @@ func (e *typeErrors) noMatchingOverload(l common.Location, name string, args []*
 	e.ReportError(l, "found no matching overload for '%s' applied to '%s'", name, signature)
 }
 
+func (e *typeErrors) refinementFailed(l common.Location, overloadId string, err error) {
+	e.ReportError(l, "refinement of the result of '%s' failed: %v", overloadId, err)
+}
+
+func (e *typeErrors) refinementNotAssignable(l common.Location, overloadId string,
+	declared *checkedpb.Type, refined *checkedpb.Type) {
+	e.ReportError(l, "refined result type '%s' of '%s' is not assignable to declared type '%s'",
+		FormatCheckedType(refined), overloadId, FormatCheckedType(declared))
+}
+
 func (e *typeErrors) aggregateTypeMismatch(l common.Location, aggregate *checkedpb.Type, member *checkedpb.Type) {
 	e.ReportError(
 		l,
@@ -0,0 +1,78 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types/ref"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// EnvFactory derives Envs, e.g. one for each tenant of a service, from an
+// immutable base of the standard declarations and any shared declarations.
+//
+// A derived Env extends the declarations of the base rather than copying
+// them, so that deriving an Env costs about as much as declaring its own
+// identifiers and functions. Declarations added to a derived Env, including
+// new overloads of the functions of the base, are only visible within it.
+//
+// An EnvFactory is safe for concurrent use.
+type EnvFactory struct {
+	base         *decls.Scopes
+	typeProvider ref.TypeProvider
+	opts         []EnvOption
+}
+
+// NewEnvFactory returns an EnvFactory whose base holds the standard
+// declarations and the given declarations, or an error if the declarations
+// conflict. The options apply to each derived Env.
+func NewEnvFactory(typeProvider ref.TypeProvider, base []*checkedpb.Decl,
+	opts ...EnvOption) (*EnvFactory, error) {
+	errors := common.NewErrors(common.NewStringSource("", "<base>"))
+	env := NewStandardEnv(packages.DefaultPackage, typeProvider, errors, opts...)
+	env.Add(base...)
+	if len(errors.GetErrors()) != 0 {
+		return nil, fmt.Errorf("invalid base declarations: %s", errors.ToDisplayString())
+	}
+	return &EnvFactory{
+		base:         env.declarations,
+		typeProvider: typeProvider,
+		opts:         opts}, nil
+}
+
+// NewEnv derives an Env from the base, which resolves names within the
+// container of the packager and reports errors to the given errors. The
+// options apply after those of the factory.
+func (f *EnvFactory) NewEnv(packager packages.Packager,
+	errors *common.Errors,
+	opts ...EnvOption) *Env {
+	e := &Env{
+		errors:       &typeErrors{errors},
+		packager:     packager,
+		typeProvider: f.typeProvider,
+		declarations: f.base.Extend(),
+	}
+	for _, opt := range f.opts {
+		opt(e)
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/parser"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+var tenantDecls = []*checkedpb.Decl{
+	decls.NewIdent("quota", decls.Int, nil),
+	decls.NewFunction("size",
+		decls.NewOverload("size_tenant_int", []*checkedpb.Type{decls.Int}, decls.Int)),
+}
+
+func newEnvFactory(t testing.TB) *EnvFactory {
+	factory, err := NewEnvFactory(typeProvider,
+		[]*checkedpb.Decl{decls.NewIdent("region", decls.String, nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return factory
+}
+
+func checkText(env func(*common.Errors) *Env, text string) string {
+	parsed, errors := parser.ParseText(text)
+	if len(errors.GetErrors()) != 0 {
+		return errors.ToDisplayString()
+	}
+	Check(parsed, env(errors))
+	return errors.ToDisplayString()
+}
+
+func TestEnvFactory(t *testing.T) {
+	factory := newEnvFactory(t)
+	tenant := func(errors *common.Errors) *Env {
+		env := factory.NewEnv(packages.NewPackage("google.api.tools.expr.test"), errors)
+		env.Add(tenantDecls...)
+		return env
+	}
+	other := func(errors *common.Errors) *Env {
+		return factory.NewEnv(packages.DefaultPackage, errors)
+	}
+	for _, tst := range []struct {
+		env   func(*common.Errors) *Env
+		text  string
+		valid bool
+	}{
+		{env: tenant, valid: true,
+			text: "size(quota) + size('abc') > 0 && region == 'us'"},
+		{env: tenant, valid: true,
+			text: "TestAllTypes{single_int64: quota} != TestAllTypes{}"},
+		{env: other, valid: true,
+			text: "size('abc') > 0 && region == 'us'"},
+		{env: other, text: "quota > 0"},
+		{env: other, text: "size(1) > 0"},
+		{env: other, text: "TestAllTypes{} != TestAllTypes{}"},
+	} {
+		if errors := checkText(tst.env, tst.text); (errors == "") != tst.valid {
+			t.Errorf("%s: got errors '%s', wanted valid %t", tst.text, errors, tst.valid)
+		}
+	}
+
+	// The declarations of the tenant, including the types it resolved, are
+	// not added to the base.
+	base := factory.NewEnv(packages.DefaultPackage,
+		common.NewErrors(common.NewStringSource("", "<input>"))).Config()
+	standardEnv := NewStandardEnv(packages.DefaultPackage, typeProvider,
+		common.NewErrors(common.NewStringSource("", "<input>")))
+	standardEnv.Add(decls.NewIdent("region", decls.String, nil))
+	standard := standardEnv.Config()
+	if !reflect.DeepEqual(base.Idents, standard.Idents) ||
+		!reflect.DeepEqual(base.Overloads, standard.Overloads) {
+		t.Errorf("Got %v and %d overloads for the base, wanted %v and %d",
+			base.Idents, len(base.Overloads), standard.Idents, len(standard.Overloads))
+	}
+}
+
+func TestEnvFactory_Concurrent(t *testing.T) {
+	factory := newEnvFactory(t)
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			tenant := func(errors *common.Errors) *Env {
+				env := factory.NewEnv(packages.NewPackage("google.api.tools.expr.test"), errors)
+				env.Add(decls.NewIdent(fmt.Sprintf("tenant%d", g), decls.Int, nil))
+				env.Add(tenantDecls...)
+				return env
+			}
+			text := fmt.Sprintf("size(tenant%d) > quota && TestAllTypes{} != TestAllTypes{}", g)
+			if errors := checkText(tenant, text); errors != "" {
+				errs <- errors
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestEnvFactory_Errors(t *testing.T) {
+	_, err := NewEnvFactory(typeProvider, []*checkedpb.Decl{
+		decls.NewFunction("size",
+			decls.NewOverload("size_string_again", []*checkedpb.Type{decls.String}, decls.Int))})
+	if err == nil {
+		t.Error("Got no error for an overlapping overload")
+	}
+	factory := newEnvFactory(t)
+	errors := common.NewErrors(common.NewStringSource("", "<input>"))
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Got no panic for an identifier declared by the base")
+		}
+	}()
+	factory.NewEnv(packages.DefaultPackage, errors).Add(
+		decls.NewIdent("region", decls.Int, nil))
+}
+
+func BenchmarkEnvFactory_NewEnv(b *testing.B) {
+	factory := newEnvFactory(b)
+	errors := common.NewErrors(common.NewStringSource("", "<input>"))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		env := factory.NewEnv(packages.NewPackage(fmt.Sprintf("tenant%d", i%1000)), errors)
+		env.Add(tenantDecls...)
+	}
+}
+
+func BenchmarkNewStandardEnv(b *testing.B) {
+	errors := common.NewErrors(common.NewStringSource("", "<input>"))
+	for i := 0; i < b.N; i++ {
+		env := NewStandardEnv(packages.NewPackage(fmt.Sprintf("tenant%d", i%1000)),
+			typeProvider, errors)
+		env.Add(decls.NewIdent("region", decls.String, nil))
+		env.Add(tenantDecls...)
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/operators"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// LintFinding describes a likely defect of a checked expression, along with
+// the edits of its source which fix the defect, if any.
+type LintFinding struct {
+	Id       int64
+	Location common.Location
+	Message  string
+
+	// Fixes holds the edits which together fix the defect, e.g. to be offered
+	// as a quick fix by an editor, or is empty when there is no fix.
+	Fixes []*TextEdit
+}
+
+// String implements the fmt.Stringer interface method.
+func (f *LintFinding) String() string {
+	return fmt.Sprintf("%d:%d: %s", f.Location.Line(), f.Location.Column(), f.Message)
+}
+
+// Issue returns the finding as a warning.
+func (f *LintFinding) Issue() common.Error {
+	return common.Error{
+		Location: f.Location,
+		Message:  f.Message,
+		Severity: common.SeverityWarning}
+}
+
+// TextEdit replaces the text of a source between two code point offsets, or
+// inserts text at an offset when the offsets are equal.
+type TextEdit struct {
+	Start int32
+	End   int32
+	Text  string
+}
+
+// ApplyEdits returns the text with the edits applied. The edits must not
+// overlap.
+func ApplyEdits(text string, edits []*TextEdit) string {
+	sorted := append([]*TextEdit{}, edits...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	runes := []rune(text)
+	var out strings.Builder
+	offset := int32(0)
+	for _, edit := range sorted {
+		out.WriteString(string(runes[offset:edit.Start]))
+		out.WriteString(edit.Text)
+		offset = edit.End
+	}
+	out.WriteString(string(runes[offset:]))
+	return out.String()
+}
+
+// Lint reports the likely defects of a checked expression parsed from the
+// source, in pre-order:
+//
+//   - a comparison of a field which may be absent, i.e. an entry of a map
+//     selected with field syntax or a field of a wrapper type, which is not
+//     guarded by a presence test. The comparison reports an error when the
+//     map has no such entry, and compares null when the field is unset. The
+//     fix guards the comparison with has(), e.g. 'has(m.k) && m.k == 1'.
+//
+// A comparison is guarded when it is conditioned on has() by an enclosing
+// '&&', '||' with the negated test, or '?:'.
+func Lint(checked *checkedpb.CheckedExpr, source common.Source) []*LintFinding {
+	l := &linter{
+		checked:    checked,
+		sourceInfo: astpb.FromSourceInfo(checked.GetSourceInfo()),
+		source:     []rune(source.Content())}
+	root := astpb.FromExpr(checked.GetExpr())
+	l.parents = ast.Parents(root)
+	ast.Visit(root, func(e *ast.Expr, parent *ast.Expr) bool {
+		l.lintComparison(e)
+		return true
+	})
+	return l.findings
+}
+
+var comparisonOperators = map[string]bool{
+	operators.Equals:        true,
+	operators.NotEquals:     true,
+	operators.Less:          true,
+	operators.LessEquals:    true,
+	operators.Greater:       true,
+	operators.GreaterEquals: true,
+}
+
+// guardableOperators are the operators whose operands may be guarded with
+// '&&' without parentheses.
+var guardableOperators = map[string]bool{
+	operators.LogicalAnd:  true,
+	operators.LogicalOr:   true,
+	operators.Conditional: true,
+}
+
+// isOperator returns whether the function is the internal name of an
+// operator, e.g. '_+_', '!_', or '@in'.
+func isOperator(function string) bool {
+	return strings.HasPrefix(function, "_") ||
+		strings.HasSuffix(function, "_") ||
+		strings.HasPrefix(function, "@")
+}
+
+type linter struct {
+	checked    *checkedpb.CheckedExpr
+	sourceInfo *ast.SourceInfo
+	source     []rune
+	parents    map[int64]*ast.Expr
+	findings   []*LintFinding
+}
+
+// lintComparison reports the operands of a comparison which select fields
+// that may be absent without a guard.
+func (l *linter) lintComparison(e *ast.Expr) {
+	call, isCall := e.Kind.(*ast.Call)
+	if !isCall || !comparisonOperators[call.Function] {
+		return
+	}
+	for _, arg := range call.Args {
+		path, optional := l.optionalField(arg)
+		if !optional || l.guarded(e, path) {
+			continue
+		}
+		finding := &LintFinding{
+			Id:       arg.Id,
+			Location: sourceLocation(l.sourceInfo, arg.Id),
+			Message: fmt.Sprintf(
+				"comparison of '%s', which may be absent, is not guarded by has()", path)}
+		if start, fixable := l.guardOffset(e); fixable {
+			finding.Fixes = []*TextEdit{{
+				Start: start,
+				End:   start,
+				Text:  fmt.Sprintf("has(%s) && ", path)}}
+		}
+		l.findings = append(l.findings, finding)
+	}
+}
+
+// optionalField returns the path of a field selection, e.g. 'a.b.c', when
+// the field may be absent.
+func (l *linter) optionalField(e *ast.Expr) (string, bool) {
+	sel, isSelect := e.Kind.(*ast.Select)
+	if !isSelect || sel.TestOnly {
+		return "", false
+	}
+	// Qualified names resolved by the checker, such as those of enum
+	// constants, are not selections.
+	if _, found := l.checked.ReferenceMap[e.Id]; found {
+		return "", false
+	}
+	if kindOf(l.checked.TypeMap[sel.Operand.Id]) != kindMap &&
+		kindOf(l.checked.TypeMap[e.Id]) != kindWrapper {
+		return "", false
+	}
+	return selectPath(e)
+}
+
+// selectPath returns the path of a chain of selections from an identifier.
+func selectPath(e *ast.Expr) (string, bool) {
+	switch kind := e.Kind.(type) {
+	case *ast.Ident:
+		return kind.Name, true
+	case *ast.Select:
+		if kind.TestOnly {
+			return "", false
+		}
+		if operand, found := selectPath(kind.Operand); found {
+			return operand + "." + kind.Field, true
+		}
+	}
+	return "", false
+}
+
+// guarded returns whether an enclosing expression conditions the evaluation
+// of the comparison on a presence test of the path.
+func (l *linter) guarded(e *ast.Expr, path string) bool {
+	for child, parent := e, l.parents[e.Id]; parent != nil; child, parent = parent, l.parents[parent.Id] {
+		call, isCall := parent.Kind.(*ast.Call)
+		if !isCall || len(call.Args) < 2 || call.Args[1] != child {
+			continue
+		}
+		switch call.Function {
+		case operators.LogicalAnd, operators.Conditional:
+			for _, conjunct := range operands(call.Args[0], operators.LogicalAnd) {
+				if isPresenceTest(conjunct, path) {
+					return true
+				}
+			}
+		case operators.LogicalOr:
+			for _, disjunct := range operands(call.Args[0], operators.LogicalOr) {
+				if not, isNot := disjunct.Kind.(*ast.Call); isNot &&
+					not.Function == operators.LogicalNot &&
+					isPresenceTest(not.Args[0], path) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// operands returns the operands of a chain of calls of a logical operator.
+func operands(e *ast.Expr, function string) []*ast.Expr {
+	if call, isCall := e.Kind.(*ast.Call); isCall && call.Function == function {
+		var args []*ast.Expr
+		for _, arg := range call.Args {
+			args = append(args, operands(arg, function)...)
+		}
+		return args
+	}
+	return []*ast.Expr{e}
+}
+
+// isPresenceTest returns whether the expression is has() of the path.
+func isPresenceTest(e *ast.Expr, path string) bool {
+	sel, isSelect := e.Kind.(*ast.Select)
+	if !isSelect || !sel.TestOnly {
+		return false
+	}
+	operand, found := selectPath(sel.Operand)
+	return found && operand+"."+sel.Field == path
+}
+
+// guardOffset returns the offset at which a guard may be inserted before the
+// comparison, or false when the guard would change the meaning of the
+// enclosing expression, e.g. when the comparison is negated or is the operand
+// of another comparison, or when its leftmost operand is parenthesized.
+func (l *linter) guardOffset(e *ast.Expr) (int32, bool) {
+	if parent, found := l.parents[e.Id]; found {
+		if call, isCall := parent.Kind.(*ast.Call); isCall &&
+			!guardableOperators[call.Function] && isOperator(call.Function) {
+			return 0, false
+		}
+	}
+	start, found := int32(-1), false
+	ast.Visit(e, func(e *ast.Expr, parent *ast.Expr) bool {
+		if offset, hasOffset := l.sourceInfo.Positions[e.Id]; hasOffset &&
+			(!found || offset < start) {
+			start, found = offset, true
+		}
+		return true
+	})
+	op, hasOp := l.sourceInfo.Positions[e.Id]
+	if !found || !hasOp || op > int32(len(l.source)) {
+		return 0, false
+	}
+	// The text of the left-hand side must be balanced, so that the guard is
+	// not inserted within parentheses which end before the operator.
+	depth := 0
+	for _, r := range l.source[start:op] {
+		switch r {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth--; depth < 0 {
+				return 0, false
+			}
+		}
+	}
+	return start, depth == 0
+}
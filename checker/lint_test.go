@@ -0,0 +1,87 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/parser"
+)
+
+func TestLint(t *testing.T) {
+	for _, tst := range []struct {
+		text     string
+		findings []string
+		fixed    string
+	}{
+		{text: `m.k == 'v'`,
+			findings: []string{"1:1: comparison of 'm.k', which may be absent, is not guarded by has()"},
+			fixed:    `has(m.k) && m.k == 'v'`},
+		{text: `x.single_int64_wrapper > 1 || size(m.k) < 2`,
+			findings: []string{"1:1: comparison of 'x.single_int64_wrapper', which may be absent, is not guarded by has()"},
+			fixed:    `has(x.single_int64_wrapper) && x.single_int64_wrapper > 1 || size(m.k) < 2`},
+		{text: `['v' == m.k, 'v' != m.k]`,
+			findings: []string{
+				"1:9: comparison of 'm.k', which may be absent, is not guarded by has()",
+				"1:21: comparison of 'm.k', which may be absent, is not guarded by has()"},
+			fixed: `[has(m.k) && 'v' == m.k, has(m.k) && 'v' != m.k]`},
+		{text: `(m.k) == 'v'`,
+			findings: []string{"1:2: comparison of 'm.k', which may be absent, is not guarded by has()"}},
+		{text: `!(m.k == 'v')`,
+			findings: []string{"1:3: comparison of 'm.k', which may be absent, is not guarded by has()"}},
+		{text: `has(m.k) && m.k == 'v'`},
+		{text: `!has(m.k) || m.k == 'v'`},
+		{text: `has(m.k) ? m.k == 'v' : false`},
+		{text: `m.k == 'v' && has(m.k)`,
+			findings: []string{"1:1: comparison of 'm.k', which may be absent, is not guarded by has()"},
+			fixed:    `has(m.k) && m.k == 'v' && has(m.k)`},
+		{text: `x.single_int64 == 1 && m['k'] == 'v'`},
+	} {
+		source := common.NewStringSource(tst.text, "<input>")
+		expression, errors := parser.Parse(source, parser.AllMacros)
+		if len(errors.GetErrors()) > 0 {
+			t.Fatalf("Unexpected parse errors: %v", errors.ToDisplayString())
+		}
+		env := NewStandardEnv(packages.DefaultPackage, typeProvider, errors)
+		env.Add(
+			decls.NewIdent("m", decls.NewMapType(decls.String, decls.String), nil),
+			decls.NewIdent("x", decls.NewObjectType("google.api.tools.expr.test.TestAllTypes"), nil))
+		checked := Check(expression, env)
+		if len(errors.GetErrors()) > 0 {
+			t.Fatalf("Unexpected type-check errors: %v", errors.ToDisplayString())
+		}
+
+		var actual []string
+		var fixes []*TextEdit
+		for _, finding := range Lint(checked, source) {
+			actual = append(actual, finding.String())
+			fixes = append(fixes, finding.Fixes...)
+		}
+		if !reflect.DeepEqual(actual, tst.findings) {
+			t.Errorf("%s: got findings %v, wanted %v", tst.text, actual, tst.findings)
+		}
+		if len(fixes) == 0 {
+			if tst.fixed != "" {
+				t.Errorf("%s: got no fixes, wanted '%s'", tst.text, tst.fixed)
+			}
+		} else if fixed := ApplyEdits(tst.text, fixes); fixed != tst.fixed {
+			t.Errorf("%s: got fixed text '%s', wanted '%s'", tst.text, fixed, tst.fixed)
+		}
+	}
+}
@@ -15,9 +15,15 @@
 package checker
 
 import (
+	"strings"
+	"unicode"
+
 	"github.com/google/cel-go/checker/decls"
 	"github.com/google/cel-go/common/operators"
 	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
 	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
 )
 
@@ -51,6 +57,16 @@ func StandardDeclarations() []*checkedpb.Decl {
 				[]*checkedpb.Type{decls.Bool, paramA, paramA}, paramA,
 				typeParamAList)),
 
+		decls.NewFunction(overloads.Coalesce,
+			decls.NewParameterizedOverload(overloads.Coalesce,
+				[]*checkedpb.Type{paramA, paramA}, paramA,
+				typeParamAList)),
+
+		decls.NewFunction(overloads.WhichOneof,
+			decls.NewParameterizedOverload(overloads.WhichOneof,
+				[]*checkedpb.Type{paramA, decls.String}, decls.String,
+				typeParamAList)),
+
 		decls.NewFunction(operators.LogicalAnd,
 			decls.NewOverload(overloads.LogicalAnd,
 				[]*checkedpb.Type{decls.Bool, decls.Bool}, decls.Bool)),
@@ -67,6 +83,18 @@ func StandardDeclarations() []*checkedpb.Decl {
 			decls.NewInstanceOverload(overloads.MatchString,
 				[]*checkedpb.Type{decls.String, decls.String}, decls.Bool)),
 
+		decls.NewFunction(overloads.AllUniqueKeys,
+			decls.NewParameterizedOverload(overloads.AllUniqueList,
+				[]*checkedpb.Type{listOfA}, decls.Bool, typeParamAList)),
+
+		decls.NewFunction(overloads.ExistsUniqueKeys,
+			decls.NewParameterizedOverload(overloads.ExistsUniqueList,
+				[]*checkedpb.Type{listOfA}, decls.Bool, typeParamAList)),
+
+		decls.NewFunction(overloads.MatchesGlob,
+			decls.NewInstanceOverload(overloads.MatchesGlobString,
+				[]*checkedpb.Type{decls.String, decls.String}, decls.Bool)),
+
 		// Relations
 
 		decls.NewFunction(operators.Less,
@@ -421,3 +449,579 @@ func StandardDeclarations() []*checkedpb.Decl {
 			decls.NewInstanceOverload(overloads.DurationToMilliseconds,
 				[]*checkedpb.Type{decls.Duration}, decls.Int))}...)
 }
+
+// SafeArithmeticDeclarations returns the declarations of the safeDiv and
+// safeMod functions, which are not part of the standard declarations.
+//
+// Each function accepts a dividend, a divisor, and a default value of the same
+// type which is the result when the divisor is zero:
+//
+//     safeDiv(errors, requests, 0.0) > 0.1
+func SafeArithmeticDeclarations() []*checkedpb.Decl {
+	return []*checkedpb.Decl{
+		decls.NewFunction(overloads.SafeDiv,
+			decls.NewOverload(overloads.SafeDivInt64,
+				[]*checkedpb.Type{decls.Int, decls.Int, decls.Int}, decls.Int),
+			decls.NewOverload(overloads.SafeDivUint64,
+				[]*checkedpb.Type{decls.Uint, decls.Uint, decls.Uint}, decls.Uint),
+			decls.NewOverload(overloads.SafeDivDouble,
+				[]*checkedpb.Type{decls.Double, decls.Double, decls.Double}, decls.Double)),
+
+		decls.NewFunction(overloads.SafeMod,
+			decls.NewOverload(overloads.SafeModInt64,
+				[]*checkedpb.Type{decls.Int, decls.Int, decls.Int}, decls.Int),
+			decls.NewOverload(overloads.SafeModUint64,
+				[]*checkedpb.Type{decls.Uint, decls.Uint, decls.Uint}, decls.Uint)),
+	}
+}
+
+// ComparisonDeclarations returns the declarations of the ordering operators
+// '<', '<=', '>', and '>=' for the object types which have the
+// traits#ComparerType trait, such as the types registered with a
+// TypeProvider for the values of custom Go types:
+//
+//     version := types.NewTypeValue("acme.Version", traits.ComparerType)
+//     env.Add(checker.ComparisonDeclarations(version)...)
+//
+// NewStandardEnv declares the comparisons of the types registered with its
+// TypeProvider, so the declarations are only needed for the types registered
+// after the Env is created, or with Envs created by NewEnv.
+//
+// The operators compare two values of the same type, and are implemented by
+// the standard overloads, which call the Compare methods of the values.
+// Equality is declared for all types by the standard declarations, and is
+// implemented by the Equal methods of the values. Types without the trait
+// are ignored.
+func ComparisonDeclarations(objTypes ...ref.Type) []*checkedpb.Decl {
+	var comparisons []*checkedpb.Decl
+	for _, t := range objTypes {
+		if !t.HasTrait(traits.ComparerType) {
+			continue
+		}
+		objType := decls.NewObjectType(t.TypeName())
+		params := []*checkedpb.Type{objType, objType}
+		suffix := "_" + strings.Replace(t.TypeName(), ".", "_", -1)
+		comparisons = append(comparisons,
+			decls.NewFunction(operators.Less,
+				decls.NewOverload("less"+suffix, params, decls.Bool)),
+			decls.NewFunction(operators.LessEquals,
+				decls.NewOverload("less_equals"+suffix, params, decls.Bool)),
+			decls.NewFunction(operators.Greater,
+				decls.NewOverload("greater"+suffix, params, decls.Bool)),
+			decls.NewFunction(operators.GreaterEquals,
+				decls.NewOverload("greater_equals"+suffix, params, decls.Bool)))
+	}
+	return comparisons
+}
+
+// providerComparisons returns the ComparisonDeclarations of the types
+// registered with the TypeProvider, other than the types registered by
+// types#NewProvider, whose comparisons are standard declarations.
+func providerComparisons(provider ref.TypeProvider) []*checkedpb.Decl {
+	builtins := make(map[string]bool)
+	for _, typeName := range types.NewProvider().TypeNames() {
+		builtins[typeName] = true
+	}
+	var objTypes []ref.Type
+	for _, typeName := range provider.TypeNames() {
+		if builtins[typeName] {
+			continue
+		}
+		if value, found := provider.FindIdent(typeName); found {
+			if t, isType := value.(ref.Type); isType {
+				objTypes = append(objTypes, t)
+			}
+		}
+	}
+	return ComparisonDeclarations(objTypes...)
+}
+
+// EditDistanceDeclarations returns the declarations of the
+// strings.editDistance and strings.similar functions, which are not part of
+// the standard declarations.
+//
+// The edit distance is the Levenshtein distance between two strings, counted
+// in code points, of strings of at most functions#MaxEditDistanceLength code
+// points. Two strings are similar when their edit distance, relative to the
+// length of the longer string, is within the threshold:
+//
+//     strings.similar(user.name, 'administrator', 0.8)
+func EditDistanceDeclarations() []*checkedpb.Decl {
+	return []*checkedpb.Decl{
+		decls.NewFunction(overloads.StringsEditDistance,
+			decls.NewOverload(overloads.StringsEditDistanceString,
+				[]*checkedpb.Type{decls.String, decls.String}, decls.Int)),
+		decls.NewFunction(overloads.StringsSimilar,
+			decls.NewOverload(overloads.StringsSimilarString,
+				[]*checkedpb.Type{decls.String, decls.String, decls.Double},
+				decls.Bool)),
+	}
+}
+
+// EncodersDeclarations returns the declarations of the encoding functions,
+// which are not part of the standard declarations:
+//
+//   - base64.encode and hex.encode of bytes to a string, and base64.decode
+//     and hex.decode of a string to bytes
+//   - url.encode and url.decode of a string as a URL query component
+//   - utf8.valid of bytes, whether they are valid UTF-8
+//
+// Decoding an invalid string is an error:
+//
+//     utf8.valid(base64.decode(request.headers['x-token']))
+//
+// The functions are supplied to the interpreter with
+// functions#EncodersOverloads.
+func EncodersDeclarations() []*checkedpb.Decl {
+	return []*checkedpb.Decl{
+		decls.NewFunction(overloads.Base64Encode,
+			decls.NewOverload(overloads.Base64EncodeBytes,
+				[]*checkedpb.Type{decls.Bytes}, decls.String)),
+		decls.NewFunction(overloads.Base64Decode,
+			decls.NewOverload(overloads.Base64DecodeString,
+				[]*checkedpb.Type{decls.String}, decls.Bytes)),
+		decls.NewFunction(overloads.HexEncode,
+			decls.NewOverload(overloads.HexEncodeBytes,
+				[]*checkedpb.Type{decls.Bytes}, decls.String)),
+		decls.NewFunction(overloads.HexDecode,
+			decls.NewOverload(overloads.HexDecodeString,
+				[]*checkedpb.Type{decls.String}, decls.Bytes)),
+		decls.NewFunction(overloads.URLEncode,
+			decls.NewOverload(overloads.URLEncodeString,
+				[]*checkedpb.Type{decls.String}, decls.String)),
+		decls.NewFunction(overloads.URLDecode,
+			decls.NewOverload(overloads.URLDecodeString,
+				[]*checkedpb.Type{decls.String}, decls.String)),
+		decls.NewFunction(overloads.UTF8Valid,
+			decls.NewOverload(overloads.UTF8ValidBytes,
+				[]*checkedpb.Type{decls.Bytes}, decls.Bool)),
+	}
+}
+
+// EnvoyDeclarations returns the declarations of the attributes which Envoy
+// exposes to the CEL expressions of its RBAC filters, with the types
+// documented by Envoy, so that the policies of the filters may be checked and
+// tested offline:
+//
+//     request.headers['x-api-key'] == 'secret' && source.port < 1024
+//
+// The attributes are qualified identifiers of the request, response,
+// connection, source, and destination variables. Their values are supplied
+// with interpreter#EnvoyActivation.
+func EnvoyDeclarations() []*checkedpb.Decl {
+	headers := decls.NewMapType(decls.String, decls.String)
+	var attributes []*checkedpb.Decl
+	for _, attr := range []struct {
+		name string
+		t    *checkedpb.Type
+	}{
+		{"request.path", decls.String},
+		{"request.url_path", decls.String},
+		{"request.host", decls.String},
+		{"request.scheme", decls.String},
+		{"request.method", decls.String},
+		{"request.headers", headers},
+		{"request.referer", decls.String},
+		{"request.useragent", decls.String},
+		{"request.time", decls.Timestamp},
+		{"request.id", decls.String},
+		{"request.protocol", decls.String},
+		{"request.query", decls.String},
+		{"request.duration", decls.Duration},
+		{"request.size", decls.Int},
+		{"request.total_size", decls.Int},
+		{"response.code", decls.Int},
+		{"response.code_details", decls.String},
+		{"response.flags", decls.Int},
+		{"response.grpc_status", decls.Int},
+		{"response.headers", headers},
+		{"response.trailers", headers},
+		{"response.size", decls.Int},
+		{"response.total_size", decls.Int},
+		{"source.address", decls.String},
+		{"source.port", decls.Int},
+		{"destination.address", decls.String},
+		{"destination.port", decls.Int},
+		{"connection.id", decls.Uint},
+		{"connection.mtls", decls.Bool},
+		{"connection.requested_server_name", decls.String},
+		{"connection.tls_version", decls.String},
+		{"connection.subject_local_certificate", decls.String},
+		{"connection.subject_peer_certificate", decls.String},
+		{"connection.dns_san_local_certificate", decls.String},
+		{"connection.dns_san_peer_certificate", decls.String},
+		{"connection.uri_san_local_certificate", decls.String},
+		{"connection.uri_san_peer_certificate", decls.String},
+		{"connection.sha256_peer_certificate_digest", decls.String},
+		{"connection.termination_details", decls.String},
+	} {
+		attributes = append(attributes, decls.NewIdent(attr.name, attr.t, nil))
+	}
+	return attributes
+}
+
+// GeoDeclarations returns the declarations of the geo.country and geo.region
+// functions, which are not part of the standard declarations. The functions
+// return the ISO 3166 codes of the country and region of an IP address, or
+// the empty string when the location is not known:
+//
+//     geo.country(request.ip) in ['DE', 'FR']
+//
+// The location data is supplied to the interpreter with
+// functions#GeoOverloads.
+func GeoDeclarations() []*checkedpb.Decl {
+	return []*checkedpb.Decl{
+		decls.NewFunction(overloads.GeoCountry,
+			decls.NewOverload(overloads.GeoCountryString,
+				[]*checkedpb.Type{decls.String}, decls.String)),
+		decls.NewFunction(overloads.GeoRegion,
+			decls.NewOverload(overloads.GeoRegionString,
+				[]*checkedpb.Type{decls.String}, decls.String)),
+	}
+}
+
+// HeadersDeclarations returns the declarations of the get, getAll, and
+// contains functions of HTTP header maps, whose values are created with
+// types#NewHeaderMap, and which are not part of the standard declarations:
+//
+//     request.headers.get('X-Forwarded-Proto') == 'https'
+//     request.headers.getAll('Accept').exists(a, a.matches('^text/'))
+//
+// The names of header fields are compared case-insensitively. The functions
+// are implemented by the header maps themselves.
+func HeadersDeclarations() []*checkedpb.Decl {
+	headers := decls.NewObjectType(types.HeaderMapType.TypeName())
+	return []*checkedpb.Decl{
+		decls.NewFunction(overloads.HeadersGet,
+			decls.NewInstanceOverload(overloads.HeadersGetString,
+				[]*checkedpb.Type{headers, decls.String}, decls.String)),
+		decls.NewFunction(overloads.HeadersGetAll,
+			decls.NewInstanceOverload(overloads.HeadersGetAllString,
+				[]*checkedpb.Type{headers, decls.String},
+				decls.NewListType(decls.String))),
+		decls.NewFunction(overloads.HeadersContains,
+			decls.NewInstanceOverload(overloads.HeadersContainsString,
+				[]*checkedpb.Type{headers, decls.String}, decls.Bool)),
+	}
+}
+
+// IAMDeclarations returns the declarations of the variables of the condition
+// context of cloud IAM policies, which are not part of the standard
+// declarations, along with the inIpRange function:
+//
+//     principal.tags['team'] == resource.tags['team'] &&
+//         request.time < timestamp('2019-01-01T00:00:00Z') &&
+//         inIpRange(request.source_ip, '10.0.0.0/8')
+//
+// The variables follow the names of the attributes of Google Cloud IAM
+// conditions, and cover the global condition keys of AWS IAM policies:
+//
+//   - principal.id, the ARN or email address of the principal
+//   - principal.account, the account or project of the principal
+//   - principal.type, e.g. 'User' or 'serviceAccount'
+//   - principal.tags, the tags of the principal
+//   - resource.name, the ARN or full resource name of the resource
+//   - resource.type, e.g. 'storage.googleapis.com/Bucket'
+//   - resource.service, e.g. 's3.amazonaws.com'
+//   - resource.tags, the tags of the resource
+//   - request.action, e.g. 's3:GetObject'
+//   - request.time, the time at which the request was received
+//   - request.source_ip, the IP address from which the request was sent
+//   - request.secure, whether the request was sent over TLS
+//
+// The values of the variables are supplied with interpreter#IAMActivation, and
+// the conditions of AWS policies may be converted with
+// interpreter#IAMConditionToCEL. inIpRange returns whether an IP address is
+// within a CIDR range, and is supplied to the interpreter with
+// functions#IAMOverloads.
+func IAMDeclarations() []*checkedpb.Decl {
+	tags := decls.NewMapType(decls.String, decls.String)
+	var attributes []*checkedpb.Decl
+	for _, attr := range []struct {
+		name string
+		t    *checkedpb.Type
+	}{
+		{"principal.id", decls.String},
+		{"principal.account", decls.String},
+		{"principal.type", decls.String},
+		{"principal.tags", tags},
+		{"resource.name", decls.String},
+		{"resource.type", decls.String},
+		{"resource.service", decls.String},
+		{"resource.tags", tags},
+		{"request.action", decls.String},
+		{"request.time", decls.Timestamp},
+		{"request.source_ip", decls.String},
+		{"request.secure", decls.Bool},
+	} {
+		attributes = append(attributes, decls.NewIdent(attr.name, attr.t, nil))
+	}
+	return append(attributes,
+		decls.NewFunction(overloads.InIPRange,
+			decls.NewOverload(overloads.InIPRangeString,
+				[]*checkedpb.Type{decls.String, decls.String}, decls.Bool)))
+}
+
+// MatcherDeclarations returns the declarations of the matcher.fromList
+// function, which are not part of the standard declarations, and of the tests
+// of the strings against the patterns of a matcher:
+//
+//     matcher.fromList(['api.example.com', 'static.example.com'])
+//         .endsWithAny(request.host)
+//
+// Matchers are intended for lists with many patterns, for which the tests are
+// faster than 'patterns.exists(p, s.startsWith(p))' and similar comprehensions.
+func MatcherDeclarations() []*checkedpb.Decl {
+	matcher := decls.NewObjectType(types.MatcherType.TypeName())
+	return []*checkedpb.Decl{
+		decls.NewFunction(overloads.MatcherFromList,
+			decls.NewOverload(overloads.MatcherFromListString,
+				[]*checkedpb.Type{decls.NewListType(decls.String)}, matcher)),
+		decls.NewFunction(overloads.ContainsAny,
+			decls.NewInstanceOverload(overloads.MatcherContainsAnyString,
+				[]*checkedpb.Type{matcher, decls.String}, decls.Bool)),
+		decls.NewFunction(overloads.StartsWithAny,
+			decls.NewInstanceOverload(overloads.MatcherStartsWithAnyString,
+				[]*checkedpb.Type{matcher, decls.String}, decls.Bool)),
+		decls.NewFunction(overloads.EndsWithAny,
+			decls.NewInstanceOverload(overloads.MatcherEndsWithAnyString,
+				[]*checkedpb.Type{matcher, decls.String}, decls.Bool)),
+	}
+}
+
+// MathDeclarations returns the declarations of the math functions, which are
+// not part of the standard declarations:
+//
+//   - the math.isNaN, math.isInf, and math.isFinite predicates on doubles
+//   - math.greatest and math.least of one or two values of the same numeric
+//     type, or of a list of them
+//   - math.abs of ints, uints, and doubles, and math.sqrt of them as a double
+//   - math.ceil, math.floor, and math.round of doubles
+//   - the math.bitAnd, math.bitOr, math.bitXor, math.bitNot,
+//     math.bitShiftLeft, and math.bitShiftRight operations on ints and uints
+//
+// The predicates allow expressions to guard conversions which would produce
+// an error for NaN or infinite values:
+//
+//     math.isFinite(ratio) ? int(ratio) : 0
+//
+// The absolute value of the least int is an error, as it overflows, and
+// math.round rounds half away from zero. The bits of ints are shifted as
+// those of uints, so a right shift fills the high bits with zeros.
+func MathDeclarations() []*checkedpb.Decl {
+	numeric := []*checkedpb.Type{decls.Int, decls.Uint, decls.Double}
+	integral := []*checkedpb.Type{decls.Int, decls.Uint}
+	var greatest, least, abs, sqrt []*checkedpb.Decl_FunctionDecl_Overload
+	for _, t := range numeric {
+		for _, params := range [][]*checkedpb.Type{
+			{t}, {t, t}, {decls.NewListType(t)}} {
+			greatest = append(greatest, mathOverload(overloads.MathGreatest, t, params...))
+			least = append(least, mathOverload(overloads.MathLeast, t, params...))
+		}
+		abs = append(abs, mathOverload(overloads.MathAbs, t, t))
+		sqrt = append(sqrt, mathOverload(overloads.MathSqrt, decls.Double, t))
+	}
+	var bitAnd, bitOr, bitXor, bitNot, shiftLeft, shiftRight []*checkedpb.Decl_FunctionDecl_Overload
+	for _, t := range integral {
+		bitAnd = append(bitAnd, mathOverload(overloads.MathBitAnd, t, t, t))
+		bitOr = append(bitOr, mathOverload(overloads.MathBitOr, t, t, t))
+		bitXor = append(bitXor, mathOverload(overloads.MathBitXor, t, t, t))
+		bitNot = append(bitNot, mathOverload(overloads.MathBitNot, t, t))
+		shiftLeft = append(shiftLeft,
+			mathOverload(overloads.MathBitShiftLeft, t, t, decls.Int))
+		shiftRight = append(shiftRight,
+			mathOverload(overloads.MathBitShiftRight, t, t, decls.Int))
+	}
+	return []*checkedpb.Decl{
+		decls.NewFunction(overloads.MathIsNaN,
+			decls.NewOverload(overloads.MathIsNaNDouble,
+				[]*checkedpb.Type{decls.Double}, decls.Bool)),
+		decls.NewFunction(overloads.MathIsInf,
+			decls.NewOverload(overloads.MathIsInfDouble,
+				[]*checkedpb.Type{decls.Double}, decls.Bool)),
+		decls.NewFunction(overloads.MathIsFinite,
+			decls.NewOverload(overloads.MathIsFiniteDouble,
+				[]*checkedpb.Type{decls.Double}, decls.Bool)),
+		decls.NewFunction(overloads.MathGreatest, greatest...),
+		decls.NewFunction(overloads.MathLeast, least...),
+		decls.NewFunction(overloads.MathAbs, abs...),
+		decls.NewFunction(overloads.MathCeil,
+			mathOverload(overloads.MathCeil, decls.Double, decls.Double)),
+		decls.NewFunction(overloads.MathFloor,
+			mathOverload(overloads.MathFloor, decls.Double, decls.Double)),
+		decls.NewFunction(overloads.MathRound,
+			mathOverload(overloads.MathRound, decls.Double, decls.Double)),
+		decls.NewFunction(overloads.MathSqrt, sqrt...),
+		decls.NewFunction(overloads.MathBitAnd, bitAnd...),
+		decls.NewFunction(overloads.MathBitOr, bitOr...),
+		decls.NewFunction(overloads.MathBitXor, bitXor...),
+		decls.NewFunction(overloads.MathBitNot, bitNot...),
+		decls.NewFunction(overloads.MathBitShiftLeft, shiftLeft...),
+		decls.NewFunction(overloads.MathBitShiftRight, shiftRight...),
+	}
+}
+
+// mathOverload declares an overload of a math function whose id is formed from
+// the name of the function and the types of its parameters, e.g.
+// 'math_bit_shift_left_uint_int'.
+func mathOverload(function string, result *checkedpb.Type,
+	params ...*checkedpb.Type) *checkedpb.Decl_FunctionDecl_Overload {
+	var id strings.Builder
+	for _, c := range strings.Replace(function, ".", "_", 1) {
+		if unicode.IsUpper(c) {
+			id.WriteRune('_')
+		}
+		id.WriteRune(unicode.ToLower(c))
+	}
+	for _, param := range params {
+		id.WriteRune('_')
+		id.WriteString(strings.NewReplacer("(", "_", ")", "").Replace(
+			FormatCheckedType(param)))
+	}
+	return decls.NewOverload(id.String(), params, result)
+}
+
+// RegexDeclarations returns the declarations of the re.matches, re.capture,
+// re.captureAll, and re.replace functions, which are not part of the standard
+// declarations. The pattern is the second argument of each function, and uses
+// the RE2 syntax:
+//
+//     re.matches(request.path, '^/users/[0-9]+$')
+//     re.replace(request.host, '\\.internal$', '')
+//
+// Constant patterns are compiled once when a program is planned, rather than
+// on each call. The functions are supplied to the interpreter with
+// functions#RegexOverloads.
+func RegexDeclarations() []*checkedpb.Decl {
+	return []*checkedpb.Decl{
+		decls.NewFunction(overloads.RegexMatches,
+			decls.NewOverload(overloads.RegexMatchesString,
+				[]*checkedpb.Type{decls.String, decls.String}, decls.Bool)),
+		decls.NewFunction(overloads.RegexCapture,
+			decls.NewOverload(overloads.RegexCaptureString,
+				[]*checkedpb.Type{decls.String, decls.String}, decls.String)),
+		decls.NewFunction(overloads.RegexCaptureAll,
+			decls.NewOverload(overloads.RegexCaptureAllString,
+				[]*checkedpb.Type{decls.String, decls.String},
+				decls.NewListType(decls.String))),
+		decls.NewFunction(overloads.RegexReplace,
+			decls.NewOverload(overloads.RegexReplaceString,
+				[]*checkedpb.Type{decls.String, decls.String, decls.String},
+				decls.String)),
+	}
+}
+
+// StringsDeclarations returns the declarations of the split, join, replace,
+// substring, indexOf, lowerAscii, upperAscii, trim, and format functions,
+// which are not part of the standard declarations:
+//
+//     request.path.split('/')[1].lowerAscii()
+//     '%s requested %d items'.format([user, dyn(items.size())])
+//
+// The arguments of format are a list of dyn, so a literal list of arguments of
+// different types must mark them as dyn.
+// The functions are supplied to the interpreter with
+// functions#StringsOverloads.
+func StringsDeclarations() []*checkedpb.Decl {
+	return []*checkedpb.Decl{
+		decls.NewFunction(overloads.Split,
+			decls.NewInstanceOverload(overloads.SplitString,
+				[]*checkedpb.Type{decls.String, decls.String},
+				decls.NewListType(decls.String)),
+			decls.NewInstanceOverload(overloads.SplitStringInt,
+				[]*checkedpb.Type{decls.String, decls.String, decls.Int},
+				decls.NewListType(decls.String))),
+		decls.NewFunction(overloads.Join,
+			decls.NewInstanceOverload(overloads.JoinList,
+				[]*checkedpb.Type{decls.NewListType(decls.String)}, decls.String),
+			decls.NewInstanceOverload(overloads.JoinListString,
+				[]*checkedpb.Type{decls.NewListType(decls.String), decls.String},
+				decls.String)),
+		decls.NewFunction(overloads.Replace,
+			decls.NewInstanceOverload(overloads.ReplaceStringString,
+				[]*checkedpb.Type{decls.String, decls.String, decls.String},
+				decls.String),
+			decls.NewInstanceOverload(overloads.ReplaceStringStringInt,
+				[]*checkedpb.Type{decls.String, decls.String, decls.String, decls.Int},
+				decls.String)),
+		decls.NewFunction(overloads.Substring,
+			decls.NewInstanceOverload(overloads.SubstringInt,
+				[]*checkedpb.Type{decls.String, decls.Int}, decls.String),
+			decls.NewInstanceOverload(overloads.SubstringIntInt,
+				[]*checkedpb.Type{decls.String, decls.Int, decls.Int}, decls.String)),
+		decls.NewFunction(overloads.IndexOf,
+			decls.NewInstanceOverload(overloads.IndexOfString,
+				[]*checkedpb.Type{decls.String, decls.String}, decls.Int),
+			decls.NewInstanceOverload(overloads.IndexOfStringInt,
+				[]*checkedpb.Type{decls.String, decls.String, decls.Int}, decls.Int)),
+		decls.NewFunction(overloads.LowerAscii,
+			decls.NewInstanceOverload(overloads.LowerAsciiString,
+				[]*checkedpb.Type{decls.String}, decls.String)),
+		decls.NewFunction(overloads.UpperAscii,
+			decls.NewInstanceOverload(overloads.UpperAsciiString,
+				[]*checkedpb.Type{decls.String}, decls.String)),
+		decls.NewFunction(overloads.Trim,
+			decls.NewInstanceOverload(overloads.TrimString,
+				[]*checkedpb.Type{decls.String}, decls.String)),
+		decls.NewFunction(overloads.Format,
+			decls.NewInstanceOverload(overloads.FormatStringList,
+				[]*checkedpb.Type{decls.String, decls.NewListType(decls.Dyn)},
+				decls.String)),
+	}
+}
+
+// TimeDeclarations returns the declarations of the time functions, which are
+// not part of the standard declarations:
+//
+//   - truncateToMinute, truncateToHour, and truncateToDay of a timestamp, the
+//     latter two optionally in the named time zone, which defaults to UTC
+//   - since and until of a timestamp, the duration from the timestamp to the
+//     current time, or from the current time to the timestamp
+//   - age of a timestamp, the number of whole years from the timestamp to the
+//     current date, optionally in the named time zone
+//   - parseTimestamp of a string with a layout of the Go time package, such
+//     as '2006-01-02 15:04', optionally in the named time zone when the layout
+//     has none
+//
+// Along with the standard accessors, the functions allow policies to express
+// business hours:
+//
+//     request.time.getDayOfWeek('Europe/Berlin') in [1, 2, 3, 4, 5] &&
+//         request.time - request.time.truncateToDay('Europe/Berlin')
+//             < duration('18h')
+//
+// The functions are supplied to the interpreter with functions#TimeOverloads.
+func TimeDeclarations() []*checkedpb.Decl {
+	return []*checkedpb.Decl{
+		decls.NewFunction(overloads.TruncateToMinute,
+			decls.NewInstanceOverload(overloads.TimestampTruncateToMinute,
+				[]*checkedpb.Type{decls.Timestamp}, decls.Timestamp)),
+		decls.NewFunction(overloads.TruncateToHour,
+			decls.NewInstanceOverload(overloads.TimestampTruncateToHour,
+				[]*checkedpb.Type{decls.Timestamp}, decls.Timestamp),
+			decls.NewInstanceOverload(overloads.TruncateToHourWithTz,
+				[]*checkedpb.Type{decls.Timestamp, decls.String}, decls.Timestamp)),
+		decls.NewFunction(overloads.TruncateToDay,
+			decls.NewInstanceOverload(overloads.TimestampTruncateToDay,
+				[]*checkedpb.Type{decls.Timestamp}, decls.Timestamp),
+			decls.NewInstanceOverload(overloads.TruncateToDayWithTz,
+				[]*checkedpb.Type{decls.Timestamp, decls.String}, decls.Timestamp)),
+		decls.NewFunction(overloads.Since,
+			decls.NewInstanceOverload(overloads.SinceTimestamp,
+				[]*checkedpb.Type{decls.Timestamp}, decls.Duration)),
+		decls.NewFunction(overloads.Until,
+			decls.NewInstanceOverload(overloads.UntilTimestamp,
+				[]*checkedpb.Type{decls.Timestamp}, decls.Duration)),
+		decls.NewFunction(overloads.Age,
+			decls.NewInstanceOverload(overloads.AgeTimestamp,
+				[]*checkedpb.Type{decls.Timestamp}, decls.Int),
+			decls.NewInstanceOverload(overloads.AgeTimestampWithTz,
+				[]*checkedpb.Type{decls.Timestamp, decls.String}, decls.Int)),
+		decls.NewFunction(overloads.ParseTimestamp,
+			decls.NewOverload(overloads.ParseTimestampString,
+				[]*checkedpb.Type{decls.String, decls.String}, decls.Timestamp),
+			decls.NewOverload(overloads.ParseTimestampStringWithTz,
+				[]*checkedpb.Type{decls.String, decls.String, decls.String},
+				decls.Timestamp)),
+	}
+}
@@ -0,0 +1,566 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package codegen generates Go source code which implements a checked CEL
+// expression directly against the declared types of its variables.
+//
+// The package is experimental. Only a subset of CEL is supported: literals
+// of type bool, int, uint, double, and string; variables of those types, or
+// lists of them, or maps with string keys and values of those types; the
+// arithmetic, comparison, logical, and conditional operators; indexing;
+// membership tests with 'in'; and size(). Expressions outside of this subset
+// produce an error rather than partially generated code.
+//
+// A generated function takes one argument per variable, sorted by variable
+// name, and returns the result along with any evaluation error:
+//
+//     func Eval(a int64, b string) (bool, error)
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// Options configure the generated source.
+type Options struct {
+	// Package name of the generated file. Defaults to 'main'.
+	Package string
+
+	// Function name of the generated function. Defaults to 'Eval'.
+	Function string
+}
+
+// Generate produces a formatted Go source file implementing the checked
+// expression as a single function.
+func Generate(checked *checkedpb.CheckedExpr, opts Options) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "main"
+	}
+	if opts.Function == "" {
+		opts.Function = "Eval"
+	}
+	g := &generator{
+		checked:   checked,
+		imports:   map[string]bool{},
+		variables: map[string]*checkedpb.Type{},
+	}
+	result, err := g.gen(astpb.FromExpr(checked.GetExpr()))
+	if err != nil {
+		return nil, err
+	}
+	resultType, err := goType(checked.TypeMap[checked.GetExpr().GetId()])
+	if err != nil {
+		return nil, err
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by cel-go codegen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", opts.Package)
+	if len(g.imports) > 0 {
+		fmt.Fprintf(&src, "import (\n")
+		for _, imp := range sortedKeys(g.imports) {
+			fmt.Fprintf(&src, "\t%q\n", imp)
+		}
+		fmt.Fprintf(&src, ")\n\n")
+	}
+	var params []string
+	for _, name := range sortedKeys(g.variables) {
+		paramType, err := goType(g.variables[name])
+		if err != nil {
+			return nil, fmt.Errorf("variable '%s': %v", name, err)
+		}
+		params = append(params, fmt.Sprintf("%s %s", paramName(name), paramType))
+	}
+	fmt.Fprintf(&src, "func %s(%s) (%s, error) {\n",
+		opts.Function, strings.Join(params, ", "), resultType)
+	src.Write(g.body.Bytes())
+	fmt.Fprintf(&src, "return %s, %s\n}\n", result.value, result.err)
+	return format.Source(src.Bytes())
+}
+
+// generator emits the statements for an expression into the function body.
+//
+// Each sub-expression produces a Go expression for its value and another for
+// its error. Sub-expressions which cannot fail use the literal 'nil' for their
+// error, which lets parent expressions omit the corresponding checks.
+type generator struct {
+	checked   *checkedpb.CheckedExpr
+	body      bytes.Buffer
+	imports   map[string]bool
+	variables map[string]*checkedpb.Type
+	nextTemp  int
+}
+
+type result struct {
+	value string
+	err   string
+}
+
+func (g *generator) gen(e *ast.Expr) (*result, error) {
+	if ref, found := g.checked.ReferenceMap[e.Id]; found &&
+		ref.GetName() != "" && len(ref.GetOverloadId()) == 0 {
+		if ref.GetValue() != nil {
+			return nil, unsupported(e, "constant reference '%s'", ref.GetName())
+		}
+		g.variables[ref.GetName()] = g.checked.TypeMap[e.Id]
+		return &result{value: paramName(ref.GetName()), err: "nil"}, nil
+	}
+	switch kind := e.Kind.(type) {
+	case *ast.Literal:
+		return g.genLiteral(e, kind)
+	case *ast.Call:
+		return g.genCall(e, kind)
+	case *ast.CreateList:
+		return g.genList(e, kind)
+	}
+	return nil, unsupported(e, "expression")
+}
+
+func (g *generator) genLiteral(e *ast.Expr, lit *ast.Literal) (*result, error) {
+	switch v := lit.Value.(type) {
+	case bool:
+		return &result{value: strconv.FormatBool(v), err: "nil"}, nil
+	case float64:
+		return &result{
+			value: fmt.Sprintf("float64(%s)", strconv.FormatFloat(v, 'g', -1, 64)),
+			err:   "nil"}, nil
+	case int64:
+		return &result{value: fmt.Sprintf("int64(%d)", v), err: "nil"}, nil
+	case string:
+		return &result{value: strconv.Quote(v), err: "nil"}, nil
+	case uint64:
+		return &result{value: fmt.Sprintf("uint64(%d)", v), err: "nil"}, nil
+	}
+	return nil, unsupported(e, "literal %v", lit.Value)
+}
+
+func (g *generator) genList(e *ast.Expr, list *ast.CreateList) (*result, error) {
+	listType, err := goType(g.checked.TypeMap[e.Id])
+	if err != nil {
+		return nil, unsupported(e, "list: %v", err)
+	}
+	elems, err := g.genAll(list.Elements)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, len(elems))
+	for i, elem := range elems {
+		values[i] = elem.value
+	}
+	return g.strict(e, elems, fmt.Sprintf("%s{%s}", listType, strings.Join(values, ", ")))
+}
+
+func (g *generator) genCall(e *ast.Expr, call *ast.Call) (*result, error) {
+	args := call.Args
+	if call.Target != nil {
+		args = append([]*ast.Expr{call.Target}, args...)
+	}
+	switch call.Function {
+	case operators.LogicalAnd, operators.LogicalOr:
+		return g.genLogical(e, call.Function, args)
+	case operators.Conditional:
+		return g.genConditional(e, args)
+	}
+	argResults, err := g.genAll(args)
+	if err != nil {
+		return nil, err
+	}
+	argTypes := make([]*checkedpb.Type, len(args))
+	for i, arg := range args {
+		argTypes[i] = g.checked.TypeMap[arg.Id]
+	}
+	switch call.Function {
+	case operators.LogicalNot:
+		return g.strict(e, argResults, fmt.Sprintf("!%s", argResults[0].value))
+	case operators.Negate:
+		if !isNumeric(argTypes[0]) || isUint(argTypes[0]) {
+			return nil, unsupported(e, "operand type")
+		}
+		return g.strict(e, argResults, fmt.Sprintf("-%s", argResults[0].value))
+	case operators.Add, operators.Subtract, operators.Multiply:
+		if !isNumeric(argTypes[0]) &&
+			!(call.Function == operators.Add && isString(argTypes[0])) {
+			return nil, unsupported(e, "operand type")
+		}
+		return g.strict(e, argResults, binary(call.Function, argResults))
+	case operators.Divide, operators.Modulo:
+		if !isNumeric(argTypes[0]) ||
+			(call.Function == operators.Modulo && isDouble(argTypes[0])) {
+			return nil, unsupported(e, "operand type")
+		}
+		if isDouble(argTypes[0]) {
+			return g.strict(e, argResults, binary(call.Function, argResults))
+		}
+		msg := "divide by zero"
+		if call.Function == operators.Modulo {
+			msg = "modulus by zero"
+		}
+		return g.checkedOp(e, argResults,
+			fmt.Sprintf("%s == 0", argResults[1].value), msg,
+			binary(call.Function, argResults))
+	case operators.Equals, operators.NotEquals:
+		if !isPrimitive(argTypes[0]) {
+			return nil, unsupported(e, "operand type")
+		}
+		return g.strict(e, argResults, binary(call.Function, argResults))
+	case operators.Less, operators.LessEquals,
+		operators.Greater, operators.GreaterEquals:
+		if !isNumeric(argTypes[0]) && !isString(argTypes[0]) {
+			return nil, unsupported(e, "operand type")
+		}
+		return g.strict(e, argResults, binary(call.Function, argResults))
+	case operators.Index:
+		return g.genIndex(e, argResults, argTypes[0])
+	case operators.In:
+		return g.genIn(e, argResults, argTypes[1])
+	case overloads.Size:
+		return g.genSize(e, argResults, argTypes[0])
+	}
+	return nil, unsupported(e, "function '%s'", call.Function)
+}
+
+func (g *generator) genLogical(e *ast.Expr, function string,
+	args []*ast.Expr) (*result, error) {
+	// Logical operators are commutative with respect to errors: a false lhs
+	// of '&&' or true lhs of '||' short-circuits, otherwise the rhs is
+	// evaluated and may decide the result even when the lhs is an error.
+	absorbing := "!"
+	if function == operators.LogicalOr {
+		absorbing = ""
+	}
+	lhs, err := g.gen(args[0])
+	if err != nil {
+		return nil, err
+	}
+	// The rhs statements are generated separately as they must only execute
+	// when the lhs does not short-circuit.
+	body := g.body
+	g.body = bytes.Buffer{}
+	rhs, err := g.gen(args[1])
+	rhsBody := g.body
+	g.body = body
+	if err != nil {
+		return nil, err
+	}
+	if !canFail([]*result{lhs, rhs}) && rhsBody.Len() == 0 {
+		return &result{
+			value: fmt.Sprintf("(%s %s %s)",
+				lhs.value, strings.Trim(function, "_"), rhs.value),
+			err: "nil"}, nil
+	}
+	v, errVar := g.temp("bool")
+	fmt.Fprintf(&g.body, "if %s%s%s {\n%s = %t\n} else {\n",
+		noError(lhs), absorbing, lhs.value, v, function == operators.LogicalOr)
+	g.body.Write(rhsBody.Bytes())
+	fmt.Fprintf(&g.body, "if %s%s%s {\n%s = %t\n",
+		noError(rhs), absorbing, rhs.value, v, function == operators.LogicalOr)
+	for _, arg := range []*result{lhs, rhs} {
+		if arg.err != "nil" {
+			fmt.Fprintf(&g.body, "} else if %s != nil {\n%s = %s\n", arg.err, errVar, arg.err)
+		}
+	}
+	fmt.Fprintf(&g.body, "} else {\n%s = %t\n}\n}\n", v, function == operators.LogicalAnd)
+	return &result{value: v, err: errVar}, nil
+}
+
+func (g *generator) genConditional(e *ast.Expr, args []*ast.Expr) (*result, error) {
+	resultType, err := goType(g.checked.TypeMap[e.Id])
+	if err != nil {
+		return nil, unsupported(e, "conditional: %v", err)
+	}
+	cond, err := g.gen(args[0])
+	if err != nil {
+		return nil, err
+	}
+	v, errVar := g.temp(resultType)
+	if cond.err != "nil" {
+		fmt.Fprintf(&g.body, "if %s != nil {\n%s = %s\n} else ", cond.err, errVar, cond.err)
+	}
+	fmt.Fprintf(&g.body, "if %s {\n", cond.value)
+	truthy, err := g.gen(args[1])
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&g.body, "%s, %s = %s, %s\n} else {\n", v, errVar, truthy.value, truthy.err)
+	falsy, err := g.gen(args[2])
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&g.body, "%s, %s = %s, %s\n}\n", v, errVar, falsy.value, falsy.err)
+	return &result{value: v, err: errVar}, nil
+}
+
+func (g *generator) genIndex(e *ast.Expr, args []*result,
+	operandType *checkedpb.Type) (*result, error) {
+	resultType, err := goType(g.checked.TypeMap[e.Id])
+	if err != nil {
+		return nil, unsupported(e, "index: %v", err)
+	}
+	switch operandType.GetTypeKind().(type) {
+	case *checkedpb.Type_ListType_:
+		return g.checkedOp(e, args,
+			fmt.Sprintf("%s < 0 || %s >= int64(len(%s))",
+				args[1].value, args[1].value, args[0].value),
+			"index out of range",
+			fmt.Sprintf("%s[%s]", args[0].value, args[1].value))
+	case *checkedpb.Type_MapType_:
+		v, errVar := g.temp(resultType)
+		g.errorChain(args, errVar)
+		g.imports["fmt"] = true
+		fmt.Fprintf(&g.body, "if elem, found := %s[%s]; found {\n%s = elem\n",
+			args[0].value, args[1].value, v)
+		fmt.Fprintf(&g.body, "} else {\n%s = fmt.Errorf(\"no such key: %%v\", %s)\n}\n",
+			errVar, args[1].value)
+		g.closeErrorChain(args)
+		return &result{value: v, err: errVar}, nil
+	}
+	return nil, unsupported(e, "operand type")
+}
+
+func (g *generator) genIn(e *ast.Expr, args []*result,
+	containerType *checkedpb.Type) (*result, error) {
+	switch containerType.GetTypeKind().(type) {
+	case *checkedpb.Type_ListType_:
+		v, errVar := g.temp("bool")
+		g.errorChain(args, errVar)
+		fmt.Fprintf(&g.body, "for _, elem := range %s {\nif elem == %s {\n%s = true\nbreak\n}\n}\n",
+			args[1].value, args[0].value, v)
+		g.closeErrorChain(args)
+		return &result{value: v, err: errVar}, nil
+	case *checkedpb.Type_MapType_:
+		v, errVar := g.temp("bool")
+		g.errorChain(args, errVar)
+		fmt.Fprintf(&g.body, "_, %s = %s[%s]\n", v, args[1].value, args[0].value)
+		g.closeErrorChain(args)
+		return &result{value: v, err: errVar}, nil
+	}
+	return nil, unsupported(e, "operand type")
+}
+
+func (g *generator) genSize(e *ast.Expr, args []*result,
+	operandType *checkedpb.Type) (*result, error) {
+	if isString(operandType) {
+		g.imports["unicode/utf8"] = true
+		return g.strict(e, args,
+			fmt.Sprintf("int64(utf8.RuneCountInString(%s))", args[0].value))
+	}
+	switch operandType.GetTypeKind().(type) {
+	case *checkedpb.Type_ListType_, *checkedpb.Type_MapType_:
+		return g.strict(e, args, fmt.Sprintf("int64(len(%s))", args[0].value))
+	}
+	return nil, unsupported(e, "operand type")
+}
+
+func (g *generator) genAll(exprs []*ast.Expr) ([]*result, error) {
+	results := make([]*result, len(exprs))
+	for i, e := range exprs {
+		r, err := g.gen(e)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = r
+	}
+	return results, nil
+}
+
+// strict produces the result of an operation which is only evaluated when none
+// of its arguments are errors.
+//
+// When none of the arguments can fail, the operation is inlined into the
+// parent expression.
+func (g *generator) strict(e *ast.Expr, args []*result, op string) (*result, error) {
+	if !canFail(args) {
+		return &result{value: "(" + op + ")", err: "nil"}, nil
+	}
+	resultType, err := goType(g.checked.TypeMap[e.Id])
+	if err != nil {
+		return nil, unsupported(e, "result: %v", err)
+	}
+	v, errVar := g.temp(resultType)
+	g.errorChain(args, errVar)
+	fmt.Fprintf(&g.body, "%s = %s\n", v, op)
+	g.closeErrorChain(args)
+	return &result{value: v, err: errVar}, nil
+}
+
+// checkedOp produces the result of a strict operation which reports an error
+// when the failure condition holds.
+func (g *generator) checkedOp(e *ast.Expr, args []*result,
+	failure, msg, op string) (*result, error) {
+	resultType, err := goType(g.checked.TypeMap[e.Id])
+	if err != nil {
+		return nil, unsupported(e, "result: %v", err)
+	}
+	v, errVar := g.temp(resultType)
+	g.imports["errors"] = true
+	g.errorChain(args, errVar)
+	fmt.Fprintf(&g.body, "if %s {\n%s = errors.New(%q)\n} else {\n%s = %s\n}\n",
+		failure, errVar, msg, v, op)
+	g.closeErrorChain(args)
+	return &result{value: v, err: errVar}, nil
+}
+
+// errorChain opens a chain of conditionals which propagates the first argument
+// error. The caller emits the statements for the success case and then calls
+// closeErrorChain.
+func (g *generator) errorChain(args []*result, errVar string) {
+	for _, arg := range args {
+		if arg.err != "nil" {
+			fmt.Fprintf(&g.body, "if %s != nil {\n%s = %s\n} else ", arg.err, errVar, arg.err)
+		}
+	}
+	fmt.Fprintf(&g.body, "{\n")
+}
+
+func (g *generator) closeErrorChain(args []*result) {
+	fmt.Fprintf(&g.body, "}\n")
+}
+
+// temp declares a value and error variable pair for an intermediate result.
+func (g *generator) temp(goType string) (string, string) {
+	g.nextTemp++
+	v := fmt.Sprintf("v%d", g.nextTemp)
+	errVar := fmt.Sprintf("err%d", g.nextTemp)
+	fmt.Fprintf(&g.body, "var %s %s\nvar %s error\n", v, goType, errVar)
+	return v, errVar
+}
+
+func binary(function string, args []*result) string {
+	op := strings.Trim(function, "_")
+	return fmt.Sprintf("%s %s %s", args[0].value, op, args[1].value)
+}
+
+// noError returns a condition prefix which holds when the result is not an
+// error, or the empty string when the result cannot fail.
+func noError(r *result) string {
+	if r.err == "nil" {
+		return ""
+	}
+	return r.err + " == nil && "
+}
+
+func canFail(args []*result) bool {
+	for _, arg := range args {
+		if arg.err != "nil" {
+			return true
+		}
+	}
+	return false
+}
+
+// goType returns the Go type used to represent values of the CEL type.
+func goType(t *checkedpb.Type) (string, error) {
+	switch t.GetTypeKind().(type) {
+	case *checkedpb.Type_Primitive:
+		switch t.GetPrimitive() {
+		case checkedpb.Type_BOOL:
+			return "bool", nil
+		case checkedpb.Type_DOUBLE:
+			return "float64", nil
+		case checkedpb.Type_INT64:
+			return "int64", nil
+		case checkedpb.Type_STRING:
+			return "string", nil
+		case checkedpb.Type_UINT64:
+			return "uint64", nil
+		}
+	case *checkedpb.Type_ListType_:
+		elem := t.GetListType().GetElemType()
+		if isPrimitive(elem) {
+			elemType, _ := goType(elem)
+			return "[]" + elemType, nil
+		}
+	case *checkedpb.Type_MapType_:
+		m := t.GetMapType()
+		if isString(m.GetKeyType()) && isPrimitive(m.GetValueType()) {
+			valueType, _ := goType(m.GetValueType())
+			return "map[string]" + valueType, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported type '%v'", t)
+}
+
+func isPrimitive(t *checkedpb.Type) bool {
+	_, isPrim := t.GetTypeKind().(*checkedpb.Type_Primitive)
+	return isPrim && t.GetPrimitive() != checkedpb.Type_BYTES
+}
+
+func isNumeric(t *checkedpb.Type) bool {
+	return isDouble(t) || isUint(t) ||
+		isPrimitive(t) && t.GetPrimitive() == checkedpb.Type_INT64
+}
+
+func isDouble(t *checkedpb.Type) bool {
+	return isPrimitive(t) && t.GetPrimitive() == checkedpb.Type_DOUBLE
+}
+
+func isString(t *checkedpb.Type) bool {
+	return isPrimitive(t) && t.GetPrimitive() == checkedpb.Type_STRING
+}
+
+func isUint(t *checkedpb.Type) bool {
+	return isPrimitive(t) && t.GetPrimitive() == checkedpb.Type_UINT64
+}
+
+// paramName converts a qualified variable name to a Go identifier which does
+// not collide with keywords, imported packages, or generated identifiers.
+func paramName(name string) string {
+	param := strings.Replace(name, ".", "_", -1)
+	if token.IsKeyword(param) || reservedNames[param] ||
+		generatedName.MatchString(param) {
+		param += "_"
+	}
+	return param
+}
+
+func unsupported(e *ast.Expr, format string, args ...interface{}) error {
+	return fmt.Errorf("unsupported %s at expression id %d",
+		fmt.Sprintf(format, args...), e.Id)
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch set := m.(type) {
+	case map[string]bool:
+		for key := range set {
+			keys = append(keys, key)
+		}
+	case map[string]*checkedpb.Type:
+		for key := range set {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	reservedNames = map[string]bool{
+		"elem": true, "errors": true, "fmt": true, "found": true, "utf8": true,
+	}
+	generatedName = regexp.MustCompile(`^(v|err)[0-9]+$`)
+)
@@ -0,0 +1,268 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+	celparser "github.com/google/cel-go/parser"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+func TestGenerate_Inline(t *testing.T) {
+	checked := check(t, `a + 1 > 2 && name.size() < 10`,
+		decls.NewIdent("a", decls.Int, nil),
+		decls.NewIdent("name", decls.String, nil))
+	src, err := Generate(checked, Options{Package: "policy", Function: "Allow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `// Code generated by cel-go codegen. DO NOT EDIT.
+
+package policy
+
+import (
+	"unicode/utf8"
+)
+
+func Allow(a int64, name string) (bool, error) {
+	return (((a + int64(1)) > int64(2)) && ((int64(utf8.RuneCountInString(name))) < int64(10))), nil
+}
+`
+	if string(src) != expected {
+		t.Errorf("Got:\n%s\nwanted:\n%s", src, expected)
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	checked := check(t, `x / y == 2 || ids[i] in names ? m[k] : 'none'`,
+		decls.NewIdent("x", decls.Int, nil),
+		decls.NewIdent("y", decls.Int, nil),
+		decls.NewIdent("i", decls.Int, nil),
+		decls.NewIdent("ids", decls.NewListType(decls.String), nil),
+		decls.NewIdent("names", decls.NewListType(decls.String), nil),
+		decls.NewIdent("k", decls.String, nil),
+		decls.NewIdent("m", decls.NewMapType(decls.String, decls.String), nil))
+	src, err := Generate(checked, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "gen.go", src, 0); err != nil {
+		t.Fatalf("Generated invalid source: %v\n%s", err, src)
+	}
+	for _, fragment := range []string{
+		"func Eval(i int64, ids []string, k string, m map[string]string, names []string, x int64, y int64) (string, error)",
+		`errors.New("divide by zero")`,
+		`errors.New("index out of range")`,
+		`fmt.Errorf("no such key: %v", k)`,
+	} {
+		if !strings.Contains(string(src), fragment) {
+			t.Errorf("Generated source missing %q:\n%s", fragment, src)
+		}
+	}
+}
+
+func TestGenerate_Unsupported(t *testing.T) {
+	for _, tst := range []struct {
+		text  string
+		decls []*checkedpb.Decl
+	}{
+		{`[1, 2].exists(x, x > 1)`, nil},
+		{`b'abc'.size() == 3`, nil},
+		{`d.f == 1`, []*checkedpb.Decl{decls.NewIdent("d", decls.Dyn, nil)}},
+	} {
+		checked := check(t, tst.text, tst.decls...)
+		if _, err := Generate(checked, Options{}); err == nil ||
+			!strings.Contains(err.Error(), "unsupported") {
+			t.Errorf("%s: got error %v, wanted unsupported", tst.text, err)
+		}
+	}
+}
+
+func TestGenerate_MatchesInterpreter(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not available")
+	}
+	tests := []struct {
+		text   string
+		decls  []*checkedpb.Decl
+		inputs []map[string]interface{}
+	}{
+		{text: `a + 1 > 2 && name.size() < 10`,
+			decls: []*checkedpb.Decl{
+				decls.NewIdent("a", decls.Int, nil),
+				decls.NewIdent("name", decls.String, nil)},
+			inputs: []map[string]interface{}{
+				{"a": int64(5), "name": "hello"},
+				{"a": int64(1), "name": "hello"},
+				{"a": int64(5), "name": "hello, world"}}},
+		{text: `x / y == 2 || ids[i] in names ? m[k] : 'none'`,
+			decls: []*checkedpb.Decl{
+				decls.NewIdent("x", decls.Int, nil),
+				decls.NewIdent("y", decls.Int, nil),
+				decls.NewIdent("i", decls.Int, nil),
+				decls.NewIdent("ids", decls.NewListType(decls.String), nil),
+				decls.NewIdent("names", decls.NewListType(decls.String), nil),
+				decls.NewIdent("k", decls.String, nil),
+				decls.NewIdent("m", decls.NewMapType(decls.String, decls.String), nil)},
+			inputs: []map[string]interface{}{
+				{"x": int64(4), "y": int64(2), "i": int64(0), "ids": []string{"a"},
+					"names": []string{}, "k": "k", "m": map[string]string{"k": "v"}},
+				{"x": int64(4), "y": int64(0), "i": int64(0), "ids": []string{"a"},
+					"names": []string{"a"}, "k": "k", "m": map[string]string{"k": "v"}},
+				{"x": int64(4), "y": int64(0), "i": int64(0), "ids": []string{"a"},
+					"names": []string{}, "k": "k", "m": map[string]string{"k": "v"}},
+				{"x": int64(1), "y": int64(1), "i": int64(3), "ids": []string{"a"},
+					"names": []string{"a"}, "k": "k", "m": map[string]string{"k": "v"}},
+				{"x": int64(1), "y": int64(1), "i": int64(0), "ids": []string{"a"},
+					"names": []string{"a"}, "k": "j", "m": map[string]string{"k": "v"}},
+				{"x": int64(1), "y": int64(1), "i": int64(0), "ids": []string{"a"},
+					"names": []string{"b"}, "k": "j", "m": map[string]string{"k": "v"}}}},
+		{text: `d * 2.0 - 1.0 < 10.0 || u % 3u == 1u`,
+			decls: []*checkedpb.Decl{
+				decls.NewIdent("d", decls.Double, nil),
+				decls.NewIdent("u", decls.Uint, nil)},
+			inputs: []map[string]interface{}{
+				{"d": 1.5, "u": uint64(4)},
+				{"d": 20.0, "u": uint64(4)},
+				{"d": 20.0, "u": uint64(5)}}},
+		{text: `s + '!' != 'hi!' && !(s in tags)`,
+			decls: []*checkedpb.Decl{
+				decls.NewIdent("s", decls.String, nil),
+				decls.NewIdent("tags", decls.NewMapType(decls.String, decls.Bool), nil)},
+			inputs: []map[string]interface{}{
+				{"s": "hi", "tags": map[string]bool{}},
+				{"s": "yo", "tags": map[string]bool{}},
+				{"s": "yo", "tags": map[string]bool{"yo": true}}}},
+		{text: `-n + size(l)`,
+			decls: []*checkedpb.Decl{
+				decls.NewIdent("n", decls.Int, nil),
+				decls.NewIdent("l", decls.NewListType(decls.Int), nil)},
+			inputs: []map[string]interface{}{
+				{"n": int64(2), "l": []int64{1, 2, 3}}}},
+	}
+
+	// Generate every expression into a single main package, then run it
+	// once and compare each printed result against the interpreter.
+	dir, err := ioutil.TempDir("", "codegen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var calls bytes.Buffer
+	var expected []string
+	i := interpreter.NewStandardIntepreter(packages.DefaultPackage, types.NewProvider())
+	for n, tst := range tests {
+		checked := check(t, tst.text, tst.decls...)
+		function := fmt.Sprintf("Eval%d", n)
+		src, err := Generate(checked, Options{Function: function})
+		if err != nil {
+			t.Fatalf("%s: %v", tst.text, err)
+		}
+		file := filepath.Join(dir, fmt.Sprintf("gen%d.go", n))
+		if err := ioutil.WriteFile(file, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+		eval := i.NewInterpretable(interpreter.NewCheckedProgram(checked))
+		for _, input := range tst.inputs {
+			var names, args []string
+			for name := range input {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				args = append(args, fmt.Sprintf("%#v", input[name]))
+			}
+			fmt.Fprintf(&calls, "\tprint(%s(%s))\n", function, strings.Join(args, ", "))
+
+			result, _ := eval.Eval(interpreter.NewActivation(input))
+			if types.IsError(result) {
+				expected = append(expected, fmt.Sprintf("%s %v: error", tst.text, input))
+			} else {
+				expected = append(expected,
+					fmt.Sprintf("%s %v: %#v", tst.text, input, result.Value()))
+			}
+		}
+	}
+	main := fmt.Sprintf(`package main
+
+import "fmt"
+
+func print(v interface{}, err error) {
+	if err != nil {
+		fmt.Println("error")
+	} else {
+		fmt.Printf("%%#v\n", v)
+	}
+}
+
+func main() {
+%s}
+`, calls.String())
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	cmd := exec.Command(goTool, append([]string{"run"}, files...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GO111MODULE=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Got %d results, wanted %d:\n%s", len(lines), len(expected), out)
+	}
+	for n, line := range lines {
+		want := expected[n]
+		if got := want[:strings.LastIndex(want, ": ")+2] + line; got != want {
+			t.Errorf("Got %s, wanted %s", got, want)
+		}
+	}
+}
+
+func check(t *testing.T, text string, idents ...*checkedpb.Decl) *checkedpb.CheckedExpr {
+	t.Helper()
+	parsed, errors := celparser.ParseText(text)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	env := checker.NewStandardEnv(packages.DefaultPackage,
+		types.NewProvider(&expr.ParsedExpr{}), errors)
+	env.Add(idents...)
+	checked := checker.Check(parsed, env)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	return checked
+}
@@ -0,0 +1,253 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package columnar evaluates predicates against batches of rows stored by
+// column, such as Arrow record batches, for filtering large data sets.
+//
+// Comparisons, membership tests, and logical operators whose operands are
+// columns and values which do not depend upon the row are evaluated a column
+// at a time. Other expressions, and rows at which the vectorized evaluation
+// cannot determine a result, e.g. due to null values, are interpreted row by
+// row, so the results match those of the interpreter.
+package columnar
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+)
+
+// Column is a column of values, one per row of a batch.
+//
+// The column types below are satisfied by the arrays of the same names in the
+// Arrow Go library, e.g. *array.Int64 is an Int64Column, so the columns of an
+// Arrow record batch may be bound without conversion.
+type Column interface {
+	// Len returns the number of values in the column.
+	Len() int
+
+	// IsNull returns whether the value at the row is null.
+	IsNull(i int) bool
+}
+
+// BooleanColumn is a column of bool values.
+type BooleanColumn interface {
+	Column
+	Value(i int) bool
+}
+
+// Int32Column is a column of int32 values.
+type Int32Column interface {
+	Column
+	Value(i int) int32
+}
+
+// Int64Column is a column of int64 values.
+type Int64Column interface {
+	Column
+	Value(i int) int64
+}
+
+// Uint32Column is a column of uint32 values.
+type Uint32Column interface {
+	Column
+	Value(i int) uint32
+}
+
+// Uint64Column is a column of uint64 values.
+type Uint64Column interface {
+	Column
+	Value(i int) uint64
+}
+
+// Float32Column is a column of float32 values.
+type Float32Column interface {
+	Column
+	Value(i int) float32
+}
+
+// Float64Column is a column of float64 values.
+type Float64Column interface {
+	Column
+	Value(i int) float64
+}
+
+// StringColumn is a column of string values.
+type StringColumn interface {
+	Column
+	Value(i int) string
+}
+
+// BinaryColumn is a column of bytes values.
+type BinaryColumn interface {
+	Column
+	Value(i int) []byte
+}
+
+// Batch is a batch of rows, whose columns are bound to the variables of the
+// same names.
+type Batch struct {
+	rows    int
+	columns map[string]*column
+}
+
+// NewBatch returns a Batch of the given number of rows from columns of one of
+// the column types of this package, keyed by name.
+func NewBatch(rows int, columns map[string]Column) (*Batch, error) {
+	b := &Batch{rows: rows, columns: make(map[string]*column, len(columns))}
+	for name, c := range columns {
+		if c.Len() != rows {
+			return nil, fmt.Errorf(
+				"column '%s' has %d rows, wanted %d", name, c.Len(), rows)
+		}
+		col := newColumn(c)
+		if col == nil {
+			return nil, fmt.Errorf("column '%s' has unsupported type %T", name, c)
+		}
+		b.columns[name] = col
+	}
+	return b, nil
+}
+
+// Rows returns the number of rows in the batch.
+func (b *Batch) Rows() int {
+	return b.rows
+}
+
+// valueKind is the kind of the values of a column.
+type valueKind int
+
+const (
+	kindBool valueKind = iota + 1
+	kindInt
+	kindUint
+	kindDouble
+	kindString
+	kindBytes
+)
+
+// column reads the values of a column, or of a constant, by row. Only the
+// accessor for the kind of the values is set.
+type column struct {
+	// values is nil for a constant.
+	values  Column
+	kind    valueKind
+	bools   func(int) bool
+	ints    func(int) int64
+	uints   func(int) uint64
+	doubles func(int) float64
+	strings func(int) string
+	bytes   func(int) []byte
+}
+
+func newColumn(c Column) *column {
+	col := &column{values: c}
+	switch c := c.(type) {
+	case BooleanColumn:
+		col.kind, col.bools = kindBool, c.Value
+	case Int32Column:
+		col.kind = kindInt
+		col.ints = func(i int) int64 { return int64(c.Value(i)) }
+	case Int64Column:
+		col.kind, col.ints = kindInt, c.Value
+	case Uint32Column:
+		col.kind = kindUint
+		col.uints = func(i int) uint64 { return uint64(c.Value(i)) }
+	case Uint64Column:
+		col.kind, col.uints = kindUint, c.Value
+	case Float32Column:
+		col.kind = kindDouble
+		col.doubles = func(i int) float64 { return float64(c.Value(i)) }
+	case Float64Column:
+		col.kind, col.doubles = kindDouble, c.Value
+	case StringColumn:
+		col.kind, col.strings = kindString, c.Value
+	case BinaryColumn:
+		col.kind, col.bytes = kindBytes, c.Value
+	default:
+		return nil
+	}
+	return col
+}
+
+// newConstant returns a column holding the value at every row, or nil when
+// the value is not of a supported kind.
+func newConstant(value ref.Value) *column {
+	switch v := value.(type) {
+	case types.Bool:
+		return &column{kind: kindBool, bools: func(int) bool { return bool(v) }}
+	case types.Int:
+		return &column{kind: kindInt, ints: func(int) int64 { return int64(v) }}
+	case types.Uint:
+		return &column{kind: kindUint, uints: func(int) uint64 { return uint64(v) }}
+	case types.Double:
+		return &column{kind: kindDouble,
+			doubles: func(int) float64 { return float64(v) }}
+	case types.String:
+		return &column{kind: kindString,
+			strings: func(int) string { return string(v) }}
+	case types.Bytes:
+		return &column{kind: kindBytes, bytes: func(int) []byte { return []byte(v) }}
+	}
+	return nil
+}
+
+func (c *column) isNull(i int) bool {
+	return c.values != nil && c.values.IsNull(i)
+}
+
+// value returns the value at a row as a CEL value.
+func (c *column) value(i int) ref.Value {
+	if c.isNull(i) {
+		return types.NullValue
+	}
+	switch c.kind {
+	case kindBool:
+		return types.Bool(c.bools(i))
+	case kindInt:
+		return types.Int(c.ints(i))
+	case kindUint:
+		return types.Uint(c.uints(i))
+	case kindDouble:
+		return types.Double(c.doubles(i))
+	case kindString:
+		return types.String(c.strings(i))
+	}
+	return types.Bytes(c.bytes(i))
+}
+
+// rowActivation binds the values of the columns at a row, and resolves other
+// names from its parent.
+type rowActivation struct {
+	batch  *Batch
+	row    int
+	parent interpreter.Activation
+}
+
+func (a *rowActivation) Parent() interpreter.Activation {
+	return a.parent
+}
+
+func (a *rowActivation) ResolveName(name string) (ref.Value, bool) {
+	if c, found := a.batch.columns[name]; found {
+		return c.value(a.row), true
+	}
+	return a.parent.ResolveName(name)
+}
+
+func (a *rowActivation) ResolveReference(exprId int64) (ref.Value, bool) {
+	return a.parent.ResolveReference(exprId)
+}
@@ -0,0 +1,401 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package columnar
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+// Predicate evaluates a boolean expression against the rows of batches.
+//
+// The vectorized evaluation implements the standard semantics of the logical,
+// comparison, and 'in' operators. A Predicate is not safe for concurrent use.
+type Predicate struct {
+	interp     interpreter.Interpreter
+	expression *ast.Expr
+	info       *ast.SourceInfo
+	opts       []interpreter.ProgramOption
+	// interpretables are created on demand for the expressions which are
+	// interpreted, keyed by expression id.
+	interpretables map[int64]interpreter.Interpretable
+}
+
+// NewPredicate returns a Predicate for the expression, whose programs are
+// created with the given options and evaluated by the Interpreter.
+//
+// The programs are planned as trees, as with interpreter.TreeEvaluation, as
+// they are evaluated once per row.
+func NewPredicate(i interpreter.Interpreter, expression *expr.Expr,
+	info *expr.SourceInfo, opts ...interpreter.ProgramOption) *Predicate {
+	programOpts := make([]interpreter.ProgramOption, 0, len(opts)+1)
+	programOpts = append(programOpts, opts...)
+	return &Predicate{
+		interp:         i,
+		expression:     astpb.FromExpr(expression),
+		info:           astpb.FromSourceInfo(info),
+		opts:           append(programOpts, interpreter.TreeEvaluation()),
+		interpretables: make(map[int64]interpreter.Interpretable)}
+}
+
+// Filter evaluates the predicate at each row of the batch, resolving
+// variables other than the columns from the activation, which may be nil.
+//
+// Filter returns whether the predicate is true at each row, along with the
+// values of the predicate at the rows where it is not a bool, such as errors
+// and unknowns, keyed by row.
+func (p *Predicate) Filter(batch *Batch,
+	activation interpreter.Activation) ([]bool, map[int]ref.Value) {
+	if activation == nil {
+		activation = interpreter.NewActivation(map[string]interface{}{})
+	}
+	f := &filter{
+		predicate: p,
+		batch:     batch,
+		vars:      activation,
+		row:       &rowActivation{batch: batch, parent: activation}}
+	v := f.eval(p.expression)
+	var others map[int]ref.Value
+	for i := 0; i < batch.rows; i++ {
+		if !v.unresolved[i] {
+			continue
+		}
+		value := f.evalRow(p.expression, i)
+		if b, isBool := value.(types.Bool); isBool {
+			v.values[i] = bool(b)
+			continue
+		}
+		v.values[i] = false
+		if others == nil {
+			others = make(map[int]ref.Value)
+		}
+		others[i] = value
+	}
+	return v.values, others
+}
+
+func (p *Predicate) interpretable(e *ast.Expr) interpreter.Interpretable {
+	if i, found := p.interpretables[e.Id]; found {
+		return i
+	}
+	program := interpreter.NewAstProgram(e, p.info, p.opts...)
+	i := p.interp.NewInterpretable(program)
+	p.interpretables[e.Id] = i
+	return i
+}
+
+// vector holds the value of a boolean expression at each row of a batch.
+// Rows at which the value could not be computed a column at a time are
+// unresolved, and are resolved by interpreting the expression for the row.
+type vector struct {
+	values     []bool
+	unresolved []bool
+}
+
+func newVector(rows int) *vector {
+	return &vector{values: make([]bool, rows), unresolved: make([]bool, rows)}
+}
+
+// filter evaluates a predicate against a batch.
+type filter struct {
+	predicate *Predicate
+	batch     *Batch
+	vars      interpreter.Activation
+	// row is reused for each row which is interpreted.
+	row *rowActivation
+}
+
+func (f *filter) eval(e *ast.Expr) *vector {
+	switch kind := e.Kind.(type) {
+	case *ast.Ident:
+		if c, found := f.batch.columns[kind.Name]; found && c.kind == kindBool {
+			v := newVector(f.batch.rows)
+			for i := range v.values {
+				if c.isNull(i) {
+					v.unresolved[i] = true
+				} else {
+					v.values[i] = c.bools(i)
+				}
+			}
+			return v
+		}
+	case *ast.Call:
+		args := kind.Args
+		switch kind.Function {
+		case operators.LogicalAnd, operators.LogicalOr:
+			if len(args) == 2 {
+				v := f.eval(args[0])
+				v.combine(f.eval(args[1]), kind.Function == operators.LogicalOr)
+				return v
+			}
+		case operators.LogicalNot:
+			if len(args) == 1 {
+				v := f.eval(args[0])
+				for i, value := range v.values {
+					v.values[i] = !value
+				}
+				return v
+			}
+		case operators.Equals, operators.NotEquals,
+			operators.Less, operators.LessEquals,
+			operators.Greater, operators.GreaterEquals:
+			if len(args) == 2 && (f.references(args[0]) || f.references(args[1])) {
+				if v := f.compare(kind.Function, args[0], args[1]); v != nil {
+					return v
+				}
+			}
+		case operators.In:
+			if len(args) == 2 && f.references(args[0]) && !f.references(args[1]) {
+				if v := f.in(args[0], args[1]); v != nil {
+					return v
+				}
+			}
+		}
+	}
+	// Expressions which do not refer to the columns have the same value at
+	// every row.
+	if !f.references(e) {
+		v := newVector(f.batch.rows)
+		value, _ := f.predicate.interpretable(e).Eval(f.vars)
+		b, isBool := value.(types.Bool)
+		for i := range v.values {
+			v.values[i], v.unresolved[i] = bool(b), !isBool
+		}
+		return v
+	}
+	return f.interpret(e)
+}
+
+// combine computes the logical and, or the logical or when shortCircuit is
+// true, of two vectors. A resolved short-circuit value at either side
+// resolves the row, in keeping with the commutative logical operators of the
+// interpreter.
+func (v *vector) combine(other *vector, shortCircuit bool) {
+	for i := range v.values {
+		switch {
+		case !v.unresolved[i] && v.values[i] == shortCircuit:
+		case !other.unresolved[i] && other.values[i] == shortCircuit:
+			v.values[i], v.unresolved[i] = shortCircuit, false
+		case v.unresolved[i] || other.unresolved[i]:
+			v.unresolved[i] = true
+		default:
+			v.values[i] = !shortCircuit
+		}
+	}
+}
+
+// interpret evaluates an expression at each row of the batch.
+func (f *filter) interpret(e *ast.Expr) *vector {
+	v := newVector(f.batch.rows)
+	for i := range v.values {
+		b, isBool := f.evalRow(e, i).(types.Bool)
+		v.values[i], v.unresolved[i] = bool(b), !isBool
+	}
+	return v
+}
+
+func (f *filter) evalRow(e *ast.Expr, row int) ref.Value {
+	f.row.row = row
+	value, _ := f.predicate.interpretable(e).Eval(f.row)
+	return value
+}
+
+// operand returns a column, or a constant for an expression which does not
+// refer to the columns. The result is nil when the operand is not a column or
+// a constant of a supported kind.
+func (f *filter) operand(e *ast.Expr) *column {
+	if ident, isIdent := e.Kind.(*ast.Ident); isIdent {
+		if c, found := f.batch.columns[ident.Name]; found {
+			return c
+		}
+	}
+	if f.references(e) {
+		return nil
+	}
+	value, _ := f.predicate.interpretable(e).Eval(f.vars)
+	return newConstant(value)
+}
+
+// compare evaluates a comparison of operands of the same kind, or returns
+// nil.
+func (f *filter) compare(function string, lhsExpr, rhsExpr *ast.Expr) *vector {
+	lhs, rhs := f.operand(lhsExpr), f.operand(rhsExpr)
+	if lhs == nil || rhs == nil || lhs.kind != rhs.kind {
+		return nil
+	}
+	var cmp func(i int) int
+	var equal func(i int) bool
+	switch lhs.kind {
+	case kindBool:
+		l, r := lhs.bools, rhs.bools
+		cmp = func(i int) int { return compareBools(l(i), r(i)) }
+	case kindInt:
+		l, r := lhs.ints, rhs.ints
+		cmp = func(i int) int { return compareInts(l(i), r(i)) }
+	case kindUint:
+		l, r := lhs.uints, rhs.uints
+		cmp = func(i int) int { return compareUints(l(i), r(i)) }
+	case kindDouble:
+		// Doubles are ordered as by Double.Compare, under which NaN compares
+		// equal to other values, but are tested for equality as by '=='.
+		l, r := lhs.doubles, rhs.doubles
+		cmp = func(i int) int { return compareDoubles(l(i), r(i)) }
+		equal = func(i int) bool { return l(i) == r(i) }
+	case kindString:
+		l, r := lhs.strings, rhs.strings
+		cmp = func(i int) int { return strings.Compare(l(i), r(i)) }
+	case kindBytes:
+		l, r := lhs.bytes, rhs.bytes
+		cmp = func(i int) int { return bytes.Compare(l(i), r(i)) }
+	}
+	if equal == nil {
+		equal = func(i int) bool { return cmp(i) == 0 }
+	}
+	var test func(i int) bool
+	switch function {
+	case operators.Equals:
+		test = equal
+	case operators.NotEquals:
+		test = func(i int) bool { return !equal(i) }
+	case operators.Less:
+		test = func(i int) bool { return cmp(i) < 0 }
+	case operators.LessEquals:
+		test = func(i int) bool { return cmp(i) <= 0 }
+	case operators.Greater:
+		test = func(i int) bool { return cmp(i) > 0 }
+	case operators.GreaterEquals:
+		test = func(i int) bool { return cmp(i) >= 0 }
+	}
+	v := newVector(f.batch.rows)
+	for i := range v.values {
+		if lhs.isNull(i) || rhs.isNull(i) {
+			v.unresolved[i] = true
+			continue
+		}
+		v.values[i] = test(i)
+	}
+	return v
+}
+
+// in evaluates the membership of a column's values within a container which
+// does not depend upon the row, or returns nil.
+func (f *filter) in(elemExpr, containerExpr *ast.Expr) *vector {
+	elem := f.operand(elemExpr)
+	if elem == nil {
+		return nil
+	}
+	value, _ := f.predicate.interpretable(containerExpr).Eval(f.vars)
+	container, isContainer := value.(traits.Container)
+	if !isContainer || !value.Type().HasTrait(traits.ContainerType) {
+		return nil
+	}
+	v := newVector(f.batch.rows)
+	for i := range v.values {
+		if elem.isNull(i) {
+			v.unresolved[i] = true
+			continue
+		}
+		b, isBool := container.Contains(elem.value(i)).(types.Bool)
+		v.values[i], v.unresolved[i] = bool(b), !isBool
+	}
+	return v
+}
+
+// references returns whether an expression refers to a column of the batch.
+func (f *filter) references(e *ast.Expr) bool {
+	switch kind := e.Kind.(type) {
+	case *ast.Ident:
+		_, found := f.batch.columns[kind.Name]
+		return found
+	case *ast.Select:
+		return f.references(kind.Operand)
+	case *ast.Call:
+		if kind.Target != nil && f.references(kind.Target) {
+			return true
+		}
+		for _, arg := range kind.Args {
+			if f.references(arg) {
+				return true
+			}
+		}
+	case *ast.CreateList:
+		for _, elem := range kind.Elements {
+			if f.references(elem) {
+				return true
+			}
+		}
+	case *ast.CreateStruct:
+		for _, entry := range kind.Entries {
+			if entry.MapKey != nil && f.references(entry.MapKey) ||
+				f.references(entry.Value) {
+				return true
+			}
+		}
+	case *ast.Comprehension:
+		return f.references(kind.IterRange) || f.references(kind.AccuInit) ||
+			f.references(kind.LoopCondition) || f.references(kind.LoopStep) ||
+			f.references(kind.Result)
+	}
+	return false
+}
+
+func compareBools(l, r bool) int {
+	switch {
+	case l == r:
+		return 0
+	case r:
+		return -1
+	}
+	return 1
+}
+
+func compareInts(l, r int64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
+
+func compareUints(l, r uint64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
+
+func compareDoubles(l, r float64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,229 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package columnar
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/parser"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+// The test columns mirror the Arrow arrays, with nulls marked by row.
+
+type nulls []bool
+
+func (n nulls) Len() int {
+	return len(n)
+}
+
+func (n nulls) IsNull(i int) bool {
+	return len(n) > i && n[i]
+}
+
+type int64Column struct {
+	nulls
+	values []int64
+}
+
+func (c *int64Column) Len() int          { return len(c.values) }
+func (c *int64Column) Value(i int) int64 { return c.values[i] }
+
+type int32Column struct {
+	nulls
+	values []int32
+}
+
+func (c *int32Column) Len() int          { return len(c.values) }
+func (c *int32Column) Value(i int) int32 { return c.values[i] }
+
+type float64Column struct {
+	nulls
+	values []float64
+}
+
+func (c *float64Column) Len() int            { return len(c.values) }
+func (c *float64Column) Value(i int) float64 { return c.values[i] }
+
+type stringColumn struct {
+	nulls
+	values []string
+}
+
+func (c *stringColumn) Len() int           { return len(c.values) }
+func (c *stringColumn) Value(i int) string { return c.values[i] }
+
+type booleanColumn struct {
+	nulls
+	values []bool
+}
+
+func (c *booleanColumn) Len() int         { return len(c.values) }
+func (c *booleanColumn) Value(i int) bool { return c.values[i] }
+
+var (
+	interp = interpreter.NewStandardIntepreter(packages.DefaultPackage,
+		types.NewProvider(&expr.ParsedExpr{}))
+)
+
+func testBatch(t testing.TB) *Batch {
+	batch, err := NewBatch(5, map[string]Column{
+		"id": &int64Column{values: []int64{1, 2, 3, 4, 5}},
+		"size": &int32Column{values: []int32{10, 0, 300, 40, 5},
+			nulls: nulls{false, true}},
+		"score": &float64Column{values: []float64{0.5, math.NaN(), 1.5, 2.5, 0}},
+		"name": &stringColumn{values: []string{"a", "b", "c", "", "e"},
+			nulls: nulls{false, false, false, true}},
+		"active": &booleanColumn{values: []bool{true, false, true, true, false}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batch
+}
+
+func TestPredicate_Filter(t *testing.T) {
+	batch := testBatch(t)
+	vars := interpreter.NewActivation(map[string]interface{}{
+		"limit": 100,
+		"names": []string{"a", "c"}})
+	// Operands which match no overload are reported along with the signatures
+	// of the overloads of the function.
+	less := "candidates: less_bool(bool, bool), less_int64(int, int), " +
+		"less_uint64(uint, uint), less_double(double, double), " +
+		"less_string(string, string), less_bytes(bytes, bytes), " +
+		"less_timestamp(google.protobuf.Timestamp, google.protobuf.Timestamp), " +
+		"less_duration(google.protobuf.Duration, google.protobuf.Duration)"
+	greater := strings.Replace(less, "less_", "greater_", -1)
+	for _, tst := range []struct {
+		text     string
+		selected []bool
+		others   string
+	}{
+		{text: "id > 2",
+			selected: []bool{false, false, true, true, true}},
+		{text: "2 >= id || id == 5",
+			selected: []bool{true, true, false, false, true}},
+		{text: "size < limit && active",
+			selected: []bool{true, false, false, true, false}},
+		{text: "size < limit",
+			selected: []bool{true, false, false, true, true},
+			others: "map[1:no matching overload for '_<_' applied to (null_type, int), " +
+				less + "]"},
+		{text: "!active || size > 0",
+			selected: []bool{true, true, true, true, true}},
+		{text: "name in names",
+			selected: []bool{true, false, true, false, false}},
+		{text: "name in names || id == 4",
+			selected: []bool{true, false, true, true, false}},
+		{text: "score <= 1.0",
+			selected: []bool{true, true, false, false, true}},
+		{text: "score == score",
+			selected: []bool{true, false, true, true, true}},
+		{text: "name.size() == 1 && id != 3",
+			selected: []bool{true, true, false, false, true},
+			others: "map[3:no matching overload for 'size' applied to (null_type), " +
+				"candidates: string_size(string), bytes_size(bytes), list_size(list), " +
+				"map_size(map), size_string(string), size_bytes(bytes), " +
+				"size_list(list), size_map(map)]"},
+		{text: "id > 2.0 || active",
+			selected: []bool{true, false, true, true, false},
+			others: "map[" +
+				"1:no matching overload for '_>_' applied to (int, double), " +
+				greater + " " +
+				"4:no matching overload for '_>_' applied to (int, double), " +
+				greater + "]"},
+		{text: "limit > 10",
+			selected: []bool{true, true, true, true, true}},
+		{text: "[1, 3].exists(x, x == id)",
+			selected: []bool{true, false, true, false, false}},
+		{text: "missing || active",
+			selected: []bool{true, false, true, true, false},
+			others:   "map[1:unknown [1] (missing) 4:unknown [1] (missing)]"},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		p := NewPredicate(interp, parsed.GetExpr(), parsed.GetSourceInfo())
+		selected, others := p.Filter(batch, vars)
+		if !reflect.DeepEqual(selected, tst.selected) {
+			t.Errorf("%s: got %v, wanted %v", tst.text, selected, tst.selected)
+		}
+		expectedOthers := tst.others
+		if expectedOthers == "" {
+			expectedOthers = "map[]"
+		}
+		if fmt.Sprint(others) != expectedOthers {
+			t.Errorf("%s: got others %v, wanted %s", tst.text, others, expectedOthers)
+		}
+
+		// The results match those of interpreting the predicate at each row.
+		for i := 0; i < batch.Rows(); i++ {
+			program := interpreter.NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+			res, _ := interp.NewInterpretable(program).Eval(
+				&rowActivation{batch: batch, row: i, parent: vars})
+			if res != types.Bool(selected[i]) && others[i] == nil {
+				t.Errorf("%s: got %t at row %d, wanted %v", tst.text, selected[i], i, res)
+			}
+		}
+	}
+}
+
+func TestNewBatch_Errors(t *testing.T) {
+	for _, tst := range []struct {
+		columns map[string]Column
+		err     string
+	}{
+		{columns: map[string]Column{"id": &int64Column{values: []int64{1}}},
+			err: "column 'id' has 1 rows, wanted 2"},
+		{columns: map[string]Column{"id": nulls{false, false}},
+			err: "column 'id' has unsupported type columnar.nulls"},
+	} {
+		if _, err := NewBatch(2, tst.columns); err == nil || err.Error() != tst.err {
+			t.Errorf("Got error '%v', wanted '%s'", err, tst.err)
+		}
+	}
+}
+
+func BenchmarkPredicate_Filter(b *testing.B) {
+	ids := make([]int64, 4096)
+	names := make([]string, len(ids))
+	for i := range ids {
+		ids[i] = int64(i)
+		names[i] = fmt.Sprintf("name%d", i%16)
+	}
+	batch, err := NewBatch(len(ids), map[string]Column{
+		"id":   &int64Column{values: ids},
+		"name": &stringColumn{values: names}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	parsed, errors := parser.ParseText("id % 2 == 0 && name in ['name1', 'name2'] || id < 100")
+	if len(errors.GetErrors()) != 0 {
+		b.Fatal(errors.ToDisplayString())
+	}
+	p := NewPredicate(interp, parsed.GetExpr(), parsed.GetSourceInfo())
+	for i := 0; i < b.N; i++ {
+		p.Filter(batch, nil)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ast defines a native Go representation of parsed CEL expressions.
+//
+// The package itself has no dependencies on the expression protos, and the
+// astparser package parses expressions into it without them. The checker and
+// the interpreter still use the protos and the common/types values, which
+// depend on golang/protobuf, so programs which type-check or evaluate
+// expressions continue to link them. Conversions to and from the protos are
+// provided by the astpb package.
+package ast
+
+// ParsedExpr is an expression and the source information captured while
+// parsing it.
+type ParsedExpr struct {
+	Expr       *Expr
+	SourceInfo *SourceInfo
+}
+
+// SourceInfo records the source locations of the nodes within an expression.
+type SourceInfo struct {
+	// SyntaxVersion is the version of the syntax the expression was parsed
+	// with.
+	SyntaxVersion string
+
+	// Location is the description of the expression source, e.g. a file name.
+	Location string
+
+	// LineOffsets contains the code point offset of the start of each line
+	// after the first.
+	LineOffsets []int32
+
+	// Positions maps expression and struct entry ids to their code point
+	// offset within the source.
+	Positions map[int64]int32
+}
+
+// Expr is a node within an expression tree.
+type Expr struct {
+	// Id is unique among the nodes of the expression tree.
+	Id int64
+
+	// Kind holds one of *Literal, *Ident, *Select, *Call, *CreateList,
+	// *CreateStruct, or *Comprehension.
+	Kind ExprKind
+}
+
+// ExprKind is implemented by each of the expression node types.
+type ExprKind interface {
+	isExprKind()
+}
+
+// Literal is a constant value.
+//
+// The Value is one of bool, []byte, float64, int64, string, uint64, or nil
+// when the literal is null.
+type Literal struct {
+	Value interface{}
+}
+
+// Ident is a reference to a variable or type by its simple name.
+type Ident struct {
+	Name string
+}
+
+// Select is a field selection, or a presence test when TestOnly is set, e.g.
+// 'a.b' or 'has(a.b)'.
+type Select struct {
+	Operand  *Expr
+	Field    string
+	TestOnly bool
+}
+
+// Call is a global function call when the Target is nil, otherwise a
+// receiver-style call on the Target.
+type Call struct {
+	Target   *Expr
+	Function string
+	Args     []*Expr
+}
+
+// CreateList is a list literal.
+type CreateList struct {
+	Elements []*Expr
+}
+
+// CreateStruct is a message construction when the MessageName is set,
+// otherwise a map literal.
+type CreateStruct struct {
+	MessageName string
+	Entries     []*Entry
+}
+
+// Entry is a field initializer or map entry within a CreateStruct.
+//
+// Exactly one of FieldKey or MapKey is set depending on whether the entry is
+// part of a message construction or a map literal.
+type Entry struct {
+	Id       int64
+	FieldKey string
+	MapKey   *Expr
+	Value    *Expr
+}
+
+// Comprehension is the expansion of a macro, such as 'all' or 'exists', over
+// the elements of a list or the keys of a map.
+type Comprehension struct {
+	IterVar       string
+	IterRange     *Expr
+	AccuVar       string
+	AccuInit      *Expr
+	LoopCondition *Expr
+	LoopStep      *Expr
+	Result        *Expr
+}
+
+func (*Literal) isExprKind()       {}
+func (*Ident) isExprKind()         {}
+func (*Select) isExprKind()        {}
+func (*Call) isExprKind()          {}
+func (*CreateList) isExprKind()    {}
+func (*CreateStruct) isExprKind()  {}
+func (*Comprehension) isExprKind() {}
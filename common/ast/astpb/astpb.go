@@ -0,0 +1,248 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package astpb converts between the native ast representation of an
+// expression and the expression protos.
+package astpb
+
+import (
+	"fmt"
+	"reflect"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/google/cel-go/common/ast"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+// FromParsedExpr converts a ParsedExpr proto to its native representation.
+func FromParsedExpr(p *expr.ParsedExpr) *ast.ParsedExpr {
+	if p == nil {
+		return nil
+	}
+	return &ast.ParsedExpr{
+		Expr:       FromExpr(p.GetExpr()),
+		SourceInfo: FromSourceInfo(p.GetSourceInfo())}
+}
+
+// ToParsedExpr converts a native ParsedExpr to its proto representation.
+func ToParsedExpr(p *ast.ParsedExpr) *expr.ParsedExpr {
+	if p == nil {
+		return nil
+	}
+	return &expr.ParsedExpr{
+		Expr:       ToExpr(p.Expr),
+		SourceInfo: ToSourceInfo(p.SourceInfo)}
+}
+
+// FromSourceInfo converts a SourceInfo proto to its native representation.
+func FromSourceInfo(info *expr.SourceInfo) *ast.SourceInfo {
+	if info == nil {
+		return nil
+	}
+	return &ast.SourceInfo{
+		SyntaxVersion: info.SyntaxVersion,
+		Location:      info.Location,
+		LineOffsets:   info.LineOffsets,
+		Positions:     info.Positions}
+}
+
+// ToSourceInfo converts a native SourceInfo to its proto representation.
+func ToSourceInfo(info *ast.SourceInfo) *expr.SourceInfo {
+	if info == nil {
+		return nil
+	}
+	return &expr.SourceInfo{
+		SyntaxVersion: info.SyntaxVersion,
+		Location:      info.Location,
+		LineOffsets:   info.LineOffsets,
+		Positions:     info.Positions}
+}
+
+// FromExpr converts an Expr proto to its native representation.
+func FromExpr(e *expr.Expr) *ast.Expr {
+	if e == nil {
+		return nil
+	}
+	result := &ast.Expr{Id: e.Id}
+	switch e.ExprKind.(type) {
+	case *expr.Expr_LiteralExpr:
+		result.Kind = fromLiteral(e.GetLiteralExpr())
+	case *expr.Expr_IdentExpr:
+		result.Kind = &ast.Ident{Name: e.GetIdentExpr().Name}
+	case *expr.Expr_SelectExpr:
+		sel := e.GetSelectExpr()
+		result.Kind = &ast.Select{
+			Operand:  FromExpr(sel.Operand),
+			Field:    sel.Field,
+			TestOnly: sel.TestOnly}
+	case *expr.Expr_CallExpr:
+		call := e.GetCallExpr()
+		result.Kind = &ast.Call{
+			Target:   FromExpr(call.Target),
+			Function: call.Function,
+			Args:     fromExprs(call.Args)}
+	case *expr.Expr_ListExpr:
+		result.Kind = &ast.CreateList{
+			Elements: fromExprs(e.GetListExpr().Elements)}
+	case *expr.Expr_StructExpr:
+		str := e.GetStructExpr()
+		entries := make([]*ast.Entry, len(str.Entries))
+		for i, entry := range str.Entries {
+			entries[i] = &ast.Entry{
+				Id:       entry.Id,
+				FieldKey: entry.GetFieldKey(),
+				MapKey:   FromExpr(entry.GetMapKey()),
+				Value:    FromExpr(entry.Value)}
+		}
+		result.Kind = &ast.CreateStruct{
+			MessageName: str.MessageName,
+			Entries:     entries}
+	case *expr.Expr_ComprehensionExpr:
+		comp := e.GetComprehensionExpr()
+		result.Kind = &ast.Comprehension{
+			IterVar:       comp.IterVar,
+			IterRange:     FromExpr(comp.IterRange),
+			AccuVar:       comp.AccuVar,
+			AccuInit:      FromExpr(comp.AccuInit),
+			LoopCondition: FromExpr(comp.LoopCondition),
+			LoopStep:      FromExpr(comp.LoopStep),
+			Result:        FromExpr(comp.Result)}
+	}
+	return result
+}
+
+// ToExpr converts a native Expr to its proto representation.
+func ToExpr(e *ast.Expr) *expr.Expr {
+	if e == nil {
+		return nil
+	}
+	result := &expr.Expr{Id: e.Id}
+	switch kind := e.Kind.(type) {
+	case *ast.Literal:
+		result.ExprKind = &expr.Expr_LiteralExpr{LiteralExpr: ToLiteral(kind)}
+	case *ast.Ident:
+		result.ExprKind = &expr.Expr_IdentExpr{
+			IdentExpr: &expr.Expr_Ident{Name: kind.Name}}
+	case *ast.Select:
+		result.ExprKind = &expr.Expr_SelectExpr{
+			SelectExpr: &expr.Expr_Select{
+				Operand:  ToExpr(kind.Operand),
+				Field:    kind.Field,
+				TestOnly: kind.TestOnly}}
+	case *ast.Call:
+		result.ExprKind = &expr.Expr_CallExpr{
+			CallExpr: &expr.Expr_Call{
+				Target:   ToExpr(kind.Target),
+				Function: kind.Function,
+				Args:     toExprs(kind.Args)}}
+	case *ast.CreateList:
+		result.ExprKind = &expr.Expr_ListExpr{
+			ListExpr: &expr.Expr_CreateList{
+				Elements: toExprs(kind.Elements)}}
+	case *ast.CreateStruct:
+		entries := make([]*expr.Expr_CreateStruct_Entry, len(kind.Entries))
+		for i, entry := range kind.Entries {
+			entries[i] = &expr.Expr_CreateStruct_Entry{
+				Id:    entry.Id,
+				Value: ToExpr(entry.Value)}
+			if entry.MapKey != nil {
+				entries[i].KeyKind = &expr.Expr_CreateStruct_Entry_MapKey{
+					MapKey: ToExpr(entry.MapKey)}
+			} else {
+				entries[i].KeyKind = &expr.Expr_CreateStruct_Entry_FieldKey{
+					FieldKey: entry.FieldKey}
+			}
+		}
+		result.ExprKind = &expr.Expr_StructExpr{
+			StructExpr: &expr.Expr_CreateStruct{
+				MessageName: kind.MessageName,
+				Entries:     entries}}
+	case *ast.Comprehension:
+		result.ExprKind = &expr.Expr_ComprehensionExpr{
+			ComprehensionExpr: &expr.Expr_Comprehension{
+				IterVar:       kind.IterVar,
+				IterRange:     ToExpr(kind.IterRange),
+				AccuVar:       kind.AccuVar,
+				AccuInit:      ToExpr(kind.AccuInit),
+				LoopCondition: ToExpr(kind.LoopCondition),
+				LoopStep:      ToExpr(kind.LoopStep),
+				Result:        ToExpr(kind.Result)}}
+	}
+	return result
+}
+
+// ToLiteral converts a native Literal to its proto representation.
+//
+// ToLiteral panics if the literal value is not one of the supported types.
+func ToLiteral(l *ast.Literal) *expr.Literal {
+	switch v := l.Value.(type) {
+	case nil:
+		return &expr.Literal{
+			LiteralKind: &expr.Literal_NullValue{
+				NullValue: structpb.NullValue_NULL_VALUE}}
+	case bool:
+		return &expr.Literal{LiteralKind: &expr.Literal_BoolValue{BoolValue: v}}
+	case []byte:
+		return &expr.Literal{LiteralKind: &expr.Literal_BytesValue{BytesValue: v}}
+	case float64:
+		return &expr.Literal{LiteralKind: &expr.Literal_DoubleValue{DoubleValue: v}}
+	case int64:
+		return &expr.Literal{LiteralKind: &expr.Literal_Int64Value{Int64Value: v}}
+	case string:
+		return &expr.Literal{LiteralKind: &expr.Literal_StringValue{StringValue: v}}
+	case uint64:
+		return &expr.Literal{LiteralKind: &expr.Literal_Uint64Value{Uint64Value: v}}
+	}
+	panic(fmt.Sprintf("unsupported literal type: %v", reflect.TypeOf(l.Value)))
+}
+
+func fromLiteral(l *expr.Literal) *ast.Literal {
+	switch l.LiteralKind.(type) {
+	case *expr.Literal_BoolValue:
+		return &ast.Literal{Value: l.GetBoolValue()}
+	case *expr.Literal_BytesValue:
+		return &ast.Literal{Value: l.GetBytesValue()}
+	case *expr.Literal_DoubleValue:
+		return &ast.Literal{Value: l.GetDoubleValue()}
+	case *expr.Literal_Int64Value:
+		return &ast.Literal{Value: l.GetInt64Value()}
+	case *expr.Literal_StringValue:
+		return &ast.Literal{Value: l.GetStringValue()}
+	case *expr.Literal_Uint64Value:
+		return &ast.Literal{Value: l.GetUint64Value()}
+	}
+	return &ast.Literal{}
+}
+
+func fromExprs(exprs []*expr.Expr) []*ast.Expr {
+	if exprs == nil {
+		return nil
+	}
+	result := make([]*ast.Expr, len(exprs))
+	for i, e := range exprs {
+		result[i] = FromExpr(e)
+	}
+	return result
+}
+
+func toExprs(exprs []*ast.Expr) []*expr.Expr {
+	if exprs == nil {
+		return nil
+	}
+	result := make([]*expr.Expr, len(exprs))
+	for i, e := range exprs {
+		result[i] = ToExpr(e)
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package astpb
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/test"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+func TestRoundTrip(t *testing.T) {
+	testExprs := []*test.TestExpr{
+		test.Exists,
+		test.ExistsWithInput,
+		test.DynMap,
+		test.LogicalAnd,
+		test.Conditional,
+		test.Select,
+		test.Equality,
+		test.TypeEquality,
+	}
+	for _, tst := range testExprs {
+		parsed := &expr.ParsedExpr{Expr: tst.Expr, SourceInfo: tst.Info(t.Name())}
+		roundTrip := ToParsedExpr(FromParsedExpr(parsed))
+		if !proto.Equal(parsed, roundTrip) {
+			t.Errorf("Got '%v', wanted '%v'", roundTrip, parsed)
+		}
+	}
+}
+
+func TestRoundTrip_Literals(t *testing.T) {
+	literals := []interface{}{structpb.NullValue_NULL_VALUE, true, []byte("bytes"), 1.5, int64(-1), "str", uint64(1)}
+	for _, lit := range literals {
+		e := test.ExprLiteral(1, lit)
+		roundTrip := ToExpr(FromExpr(e))
+		if !proto.Equal(e, roundTrip) {
+			t.Errorf("Got '%v', wanted '%v'", roundTrip, e)
+		}
+	}
+}
+
+func TestFromExpr_Kinds(t *testing.T) {
+	e := FromExpr(test.ExprMemberCall(1, operators.Index,
+		test.ExprIdent(2, "a"), test.ExprLiteral(3, int64(1))))
+	call, isCall := e.Kind.(*ast.Call)
+	if !isCall || call.Function != operators.Index || len(call.Args) != 1 {
+		t.Fatalf("Got '%v', wanted call '%s' with one arg", e.Kind, operators.Index)
+	}
+	if ident, isIdent := call.Target.Kind.(*ast.Ident); !isIdent || ident.Name != "a" {
+		t.Errorf("Got '%v', wanted ident 'a'", call.Target.Kind)
+	}
+	if lit, isLit := call.Args[0].Kind.(*ast.Literal); !isLit || lit.Value != int64(1) {
+		t.Errorf("Got '%v', wanted literal 1", call.Args[0].Kind)
+	}
+}
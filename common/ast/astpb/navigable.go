@@ -0,0 +1,98 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package astpb
+
+import (
+	"github.com/google/cel-go/common/ast"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// NavigableExpr is a node of an expression which is linked to its parent and
+// children, and annotated with the type and reference resolved by the
+// checker, e.g. for optimizers, linters, and editors which inspect the
+// context of a node.
+//
+// The tree is built once, so the expression must not be modified while it is
+// navigated.
+type NavigableExpr struct {
+	expr     *ast.Expr
+	parent   *NavigableExpr
+	children []*NavigableExpr
+	// nodes indexes the nodes of the tree by expression id, and is shared by
+	// all of the nodes.
+	nodes   map[int64]*NavigableExpr
+	checked *checkedpb.CheckedExpr
+}
+
+// NewNavigableExpr returns the root of a checked expression.
+//
+// A parsed expression may be navigated as a CheckedExpr without types and
+// references, e.g. &checkedpb.CheckedExpr{Expr: parsed.Expr}.
+func NewNavigableExpr(checked *checkedpb.CheckedExpr) *NavigableExpr {
+	nodes := make(map[int64]*NavigableExpr)
+	var root *NavigableExpr
+	ast.Visit(FromExpr(checked.Expr), func(e *ast.Expr, parent *ast.Expr) bool {
+		node := &NavigableExpr{expr: e, nodes: nodes, checked: checked}
+		if parent == nil {
+			root = node
+		} else {
+			node.parent = nodes[parent.Id]
+			node.parent.children = append(node.parent.children, node)
+		}
+		nodes[e.Id] = node
+		return true
+	})
+	return root
+}
+
+// Expr returns the native representation of the node.
+func (e *NavigableExpr) Expr() *ast.Expr {
+	return e.expr
+}
+
+// Id returns the expression id of the node.
+func (e *NavigableExpr) Id() int64 {
+	return e.expr.Id
+}
+
+// Parent returns the parent of the node, or nil for the root.
+func (e *NavigableExpr) Parent() *NavigableExpr {
+	return e.parent
+}
+
+// Children returns the children of the node in the order described by
+// ast.Children.
+func (e *NavigableExpr) Children() []*NavigableExpr {
+	return e.children
+}
+
+// Type returns the type of the node, or nil when the expression was not
+// checked.
+func (e *NavigableExpr) Type() *checkedpb.Type {
+	return e.checked.TypeMap[e.expr.Id]
+}
+
+// Reference returns the declaration referenced by an identifier, a qualified
+// name, or a call, or nil when the node references none.
+func (e *NavigableExpr) Reference() *checkedpb.Reference {
+	return e.checked.ReferenceMap[e.expr.Id]
+}
+
+// Find returns the node of the expression with the given id, or false if
+// there is none.
+func (e *NavigableExpr) Find(id int64) (*NavigableExpr, bool) {
+	node, found := e.nodes[id]
+	return node, found
+}
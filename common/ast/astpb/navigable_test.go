@@ -0,0 +1,91 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package astpb
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/test"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+func TestNavigableExpr(t *testing.T) {
+	intType := &checkedpb.Type{
+		TypeKind: &checkedpb.Type_Primitive{Primitive: checkedpb.Type_INT64}}
+	// a.b + 1
+	checked := &checkedpb.CheckedExpr{
+		Expr: test.ExprCall(1, operators.Add,
+			test.ExprSelect(2, test.ExprIdent(3, "a"), "b"),
+			test.ExprLiteral(4, int64(1))),
+		TypeMap: map[int64]*checkedpb.Type{1: intType, 2: intType, 4: intType},
+		ReferenceMap: map[int64]*checkedpb.Reference{
+			1: {OverloadId: []string{"add_int64"}},
+			3: {Name: "a"}}}
+	root := NewNavigableExpr(checked)
+	if root.Id() != 1 || root.Parent() != nil || len(root.Children()) != 2 {
+		t.Fatalf("Got root %d with parent %v and %d children, wanted 1 without a parent and 2 children",
+			root.Id(), root.Parent(), len(root.Children()))
+	}
+	if !proto.Equal(root.Type(), intType) {
+		t.Errorf("Got type %v, wanted %v", root.Type(), intType)
+	}
+	if ref := root.Reference(); ref == nil || ref.OverloadId[0] != "add_int64" {
+		t.Errorf("Got reference %v, wanted add_int64", ref)
+	}
+	ident, found := root.Find(3)
+	if !found {
+		t.Fatal("Got no node 3, wanted the identifier")
+	}
+	if ident.Parent().Id() != 2 || ident.Parent().Parent() != root {
+		t.Errorf("Got parent %d, wanted 2 within the root", ident.Parent().Id())
+	}
+	if ident.Type() != nil || ident.Reference().GetName() != "a" {
+		t.Errorf("Got type %v and reference %v, wanted no type and 'a'",
+			ident.Type(), ident.Reference())
+	}
+	if literal := root.Children()[1]; literal.Id() != 4 || len(literal.Children()) != 0 {
+		t.Errorf("Got node %d with %d children, wanted 4 with none",
+			literal.Id(), len(literal.Children()))
+	}
+	if _, found := ident.Find(5); found {
+		t.Error("Got node 5, wanted none")
+	}
+}
+
+func TestNavigableExpr_Parsed(t *testing.T) {
+	root := NewNavigableExpr(&checkedpb.CheckedExpr{Expr: test.Exists.Expr})
+	count := 0
+	var visit func(e *NavigableExpr)
+	visit = func(e *NavigableExpr) {
+		count++
+		if e.Type() != nil || e.Reference() != nil {
+			t.Errorf("Got type %v and reference %v for %d, wanted none",
+				e.Type(), e.Reference(), e.Id())
+		}
+		for _, child := range e.Children() {
+			if child.Parent() != e {
+				t.Errorf("Got parent %d of %d, wanted %d",
+					child.Parent().Id(), child.Id(), e.Id())
+			}
+			visit(child)
+		}
+	}
+	visit(root)
+	if count != len(root.nodes) {
+		t.Errorf("Got %d nodes, wanted %d", count, len(root.nodes))
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ast
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/common/operators"
+)
+
+// Text returns the source text of an expression, e.g. to describe the
+// sub-expression which produced an error. Operators are written in infix
+// form, and are parenthesized as needed to preserve the order of evaluation.
+//
+// The text of a comprehension is a placeholder, as the macro it was expanded
+// from is not recorded.
+func Text(e *Expr) string {
+	var buf bytes.Buffer
+	writeText(&buf, e, 0)
+	return buf.String()
+}
+
+// The precedence of the operators, from the lowest to the highest. Members,
+// indexes, and calls have the highest precedence.
+const (
+	precConditional = iota + 1
+	precOr
+	precAnd
+	precRelation
+	precAdd
+	precMultiply
+	precUnary
+	precMember
+)
+
+var binaryOperators = map[string]struct {
+	text string
+	prec int
+}{
+	operators.LogicalOr:     {"||", precOr},
+	operators.LogicalAnd:    {"&&", precAnd},
+	operators.Equals:        {"==", precRelation},
+	operators.NotEquals:     {"!=", precRelation},
+	operators.Less:          {"<", precRelation},
+	operators.LessEquals:    {"<=", precRelation},
+	operators.Greater:       {">", precRelation},
+	operators.GreaterEquals: {">=", precRelation},
+	operators.In:            {"in", precRelation},
+	operators.Add:           {"+", precAdd},
+	operators.Subtract:      {"-", precAdd},
+	operators.Multiply:      {"*", precMultiply},
+	operators.Divide:        {"/", precMultiply},
+	operators.Modulo:        {"%", precMultiply},
+}
+
+var unaryOperators = map[string]string{
+	operators.LogicalNot: "!",
+	operators.Negate:     "-",
+}
+
+// writeText writes the text of the expression, parenthesized when its
+// precedence is lower than the given minimum.
+func writeText(buf *bytes.Buffer, e *Expr, minPrec int) {
+	if e == nil {
+		return
+	}
+	prec := precedence(e)
+	if prec < minPrec {
+		buf.WriteString("(")
+		defer buf.WriteString(")")
+	}
+	switch kind := e.Kind.(type) {
+	case *Literal:
+		buf.WriteString(literalText(kind.Value))
+	case *Ident:
+		buf.WriteString(kind.Name)
+	case *Select:
+		if kind.TestOnly {
+			buf.WriteString("has(")
+			writeText(buf, kind.Operand, precMember)
+			buf.WriteString("." + kind.Field + ")")
+			return
+		}
+		writeText(buf, kind.Operand, precMember)
+		buf.WriteString("." + kind.Field)
+	case *Call:
+		writeCall(buf, kind, prec)
+	case *CreateList:
+		buf.WriteString("[")
+		for i, elem := range kind.Elements {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeText(buf, elem, 0)
+		}
+		buf.WriteString("]")
+	case *CreateStruct:
+		buf.WriteString(kind.MessageName + "{")
+		for i, entry := range kind.Entries {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if entry.MapKey != nil {
+				writeText(buf, entry.MapKey, 0)
+			} else {
+				buf.WriteString(entry.FieldKey)
+			}
+			buf.WriteString(": ")
+			writeText(buf, entry.Value, 0)
+		}
+		buf.WriteString("}")
+	case *Comprehension:
+		writeText(buf, kind.IterRange, precMember)
+		buf.WriteString(".<comprehension>(" + kind.IterVar + ", ...)")
+	}
+}
+
+func writeCall(buf *bytes.Buffer, call *Call, prec int) {
+	if op, found := binaryOperators[call.Function]; found && len(call.Args) == 2 {
+		// Operators of the same precedence associate to the left.
+		writeText(buf, call.Args[0], prec)
+		buf.WriteString(" " + op.text + " ")
+		writeText(buf, call.Args[1], prec+1)
+		return
+	}
+	if op, found := unaryOperators[call.Function]; found && len(call.Args) == 1 {
+		buf.WriteString(op)
+		writeText(buf, call.Args[0], prec)
+		return
+	}
+	switch {
+	case call.Function == operators.Conditional && len(call.Args) == 3:
+		writeText(buf, call.Args[0], precConditional+1)
+		buf.WriteString(" ? ")
+		writeText(buf, call.Args[1], precConditional+1)
+		buf.WriteString(" : ")
+		writeText(buf, call.Args[2], precConditional)
+		return
+	case call.Function == operators.Index && len(call.Args) == 2:
+		writeText(buf, call.Args[0], precMember)
+		buf.WriteString("[")
+		writeText(buf, call.Args[1], 0)
+		buf.WriteString("]")
+		return
+	}
+	if call.Target != nil {
+		writeText(buf, call.Target, precMember)
+		buf.WriteString(".")
+	}
+	buf.WriteString(call.Function + "(")
+	for i, arg := range call.Args {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		writeText(buf, arg, 0)
+	}
+	buf.WriteString(")")
+}
+
+// precedence returns the precedence of the operator of an expression.
+func precedence(e *Expr) int {
+	call, isCall := e.Kind.(*Call)
+	if !isCall {
+		return precMember
+	}
+	if op, found := binaryOperators[call.Function]; found && len(call.Args) == 2 {
+		return op.prec
+	}
+	if _, found := unaryOperators[call.Function]; found && len(call.Args) == 1 {
+		return precUnary
+	}
+	if call.Function == operators.Conditional && len(call.Args) == 3 {
+		return precConditional
+	}
+	return precMember
+}
+
+func literalText(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case []byte:
+		return "b" + strconv.Quote(string(v))
+	case float64:
+		text := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(text, ".eEN") {
+			// Doubles are distinguished from ints by a decimal point.
+			text += ".0"
+		}
+		return text
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return strconv.Quote(v)
+	case uint64:
+		return strconv.FormatUint(v, 10) + "u"
+	}
+	return "null"
+}
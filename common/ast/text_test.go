@@ -0,0 +1,55 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ast
+
+import (
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	ident := func(id int64, name string) *Expr {
+		return &Expr{Id: id, Kind: &Ident{Name: name}}
+	}
+	call := func(id int64, function string, args ...*Expr) *Expr {
+		return &Expr{Id: id, Kind: &Call{Function: function, Args: args}}
+	}
+	for _, tst := range []struct {
+		e        *Expr
+		expected string
+	}{
+		{e: testExpr(), expected: `a.b + f(1, {"k": [x]})`},
+		{e: call(1, "_*_", call(2, "_+_", ident(3, "a"), ident(4, "b")), ident(5, "c")),
+			expected: `(a + b) * c`},
+		{e: call(1, "_-_", ident(2, "a"), call(3, "_-_", ident(4, "b"), ident(5, "c"))),
+			expected: `a - (b - c)`},
+		{e: call(1, "_?_:_", call(2, "_&&_", ident(3, "a"), call(4, "!_", ident(5, "b"))),
+			&Expr{Id: 6, Kind: &Literal{Value: 1.0}},
+			call(7, "_[_]", ident(8, "m"), &Expr{Id: 9, Kind: &Literal{Value: uint64(2)}})),
+			expected: `a && !b ? 1.0 : m[2u]`},
+		{e: &Expr{Id: 1, Kind: &Call{Target: &Expr{Id: 2, Kind: &Select{
+			Operand: ident(3, "a"), Field: "b", TestOnly: true}}, Function: "f",
+			Args: []*Expr{{Id: 4, Kind: &Literal{Value: []byte("x")}}, {Id: 5, Kind: &Literal{}}}}},
+			expected: `has(a.b).f(b"x", null)`},
+		{e: &Expr{Id: 1, Kind: &CreateStruct{MessageName: "pkg.Msg", Entries: []*Entry{
+			{Id: 2, FieldKey: "name", Value: &Expr{Id: 3, Kind: &Literal{Value: "n"}}}}}},
+			expected: `pkg.Msg{name: "n"}`},
+		{e: &Expr{Id: 1, Kind: &Comprehension{IterVar: "x", IterRange: ident(2, "l")}},
+			expected: `l.<comprehension>(x, ...)`},
+	} {
+		if text := Text(tst.e); text != tst.expected {
+			t.Errorf("Got '%s', wanted '%s'", text, tst.expected)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ast
+
+// Visitor is called for each node of an expression by Walk.
+//
+// Parsed and checked expressions are converted to the native representation
+// with the astpb package. The expression ids are preserved by the
+// conversion, so the types and references of a checked expression may be
+// looked up by the id of each node.
+type Visitor interface {
+	// Pre is called before the children of a node are visited, with the
+	// parent of the node, or nil for the root. The children of the node are
+	// skipped, and Post is not called for the node, when Pre returns false.
+	Pre(e *Expr, parent *Expr) bool
+
+	// Post is called after the children of a node have been visited.
+	Post(e *Expr, parent *Expr)
+}
+
+// Walk traverses an expression in depth-first order, calling the Visitor for
+// each node before and after its children.
+func Walk(e *Expr, v Visitor) {
+	walk(e, nil, v)
+}
+
+func walk(e *Expr, parent *Expr, v Visitor) {
+	if e == nil || !v.Pre(e, parent) {
+		return
+	}
+	for _, child := range Children(e) {
+		walk(child, e, v)
+	}
+	v.Post(e, parent)
+}
+
+// Visit traverses an expression in pre-order, calling the function for each
+// node along with its parent, or nil for the root. The children of a node are
+// skipped when the function returns false.
+func Visit(e *Expr, visit func(e *Expr, parent *Expr) bool) {
+	Walk(e, preVisitor(visit))
+}
+
+type preVisitor func(e *Expr, parent *Expr) bool
+
+func (v preVisitor) Pre(e *Expr, parent *Expr) bool {
+	return v(e, parent)
+}
+
+func (v preVisitor) Post(e *Expr, parent *Expr) {
+}
+
+// Children returns the direct children of a node in the order in which they
+// appear in the source: the target and arguments of a call, the keys and
+// values of the entries of a struct in alternation, and the range,
+// accumulator initializer, loop condition, loop step, and result of a
+// comprehension. Absent children, such as the keys of message fields, are
+// omitted.
+func Children(e *Expr) []*Expr {
+	var children []*Expr
+	add := func(child *Expr) {
+		if child != nil {
+			children = append(children, child)
+		}
+	}
+	switch kind := e.Kind.(type) {
+	case *Select:
+		add(kind.Operand)
+	case *Call:
+		add(kind.Target)
+		for _, arg := range kind.Args {
+			add(arg)
+		}
+	case *CreateList:
+		for _, elem := range kind.Elements {
+			add(elem)
+		}
+	case *CreateStruct:
+		for _, entry := range kind.Entries {
+			add(entry.MapKey)
+			add(entry.Value)
+		}
+	case *Comprehension:
+		add(kind.IterRange)
+		add(kind.AccuInit)
+		add(kind.LoopCondition)
+		add(kind.LoopStep)
+		add(kind.Result)
+	}
+	return children
+}
+
+// Parents returns the parent of each node of an expression by the node id.
+// The root has no parent.
+func Parents(e *Expr) map[int64]*Expr {
+	parents := make(map[int64]*Expr)
+	Visit(e, func(e *Expr, parent *Expr) bool {
+		if parent != nil {
+			parents[e.Id] = parent
+		}
+		return true
+	})
+	return parents
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testExpr returns the expression 'a.b + f(1, {"k": [x]})', whose nodes are
+// numbered in pre-order.
+func testExpr() *Expr {
+	return &Expr{Id: 1, Kind: &Call{Function: "_+_", Args: []*Expr{
+		{Id: 2, Kind: &Select{
+			Operand: &Expr{Id: 3, Kind: &Ident{Name: "a"}},
+			Field:   "b"}},
+		{Id: 4, Kind: &Call{Function: "f", Args: []*Expr{
+			{Id: 5, Kind: &Literal{Value: int64(1)}},
+			{Id: 6, Kind: &CreateStruct{Entries: []*Entry{{
+				Id:     7,
+				MapKey: &Expr{Id: 8, Kind: &Literal{Value: "k"}},
+				Value: &Expr{Id: 9, Kind: &CreateList{Elements: []*Expr{
+					{Id: 10, Kind: &Ident{Name: "x"}}}}}}}}}}}}}}}
+}
+
+type recorder struct {
+	events []string
+	skip   int64
+}
+
+func (r *recorder) Pre(e *Expr, parent *Expr) bool {
+	r.events = append(r.events, fmt.Sprintf("pre %d", e.Id))
+	return e.Id != r.skip
+}
+
+func (r *recorder) Post(e *Expr, parent *Expr) {
+	r.events = append(r.events, fmt.Sprintf("post %d", e.Id))
+}
+
+func TestWalk(t *testing.T) {
+	r := &recorder{}
+	Walk(testExpr(), r)
+	expected := []string{
+		"pre 1", "pre 2", "pre 3", "post 3", "post 2",
+		"pre 4", "pre 5", "post 5", "pre 6", "pre 8", "post 8",
+		"pre 9", "pre 10", "post 10", "post 9", "post 6", "post 4", "post 1"}
+	if !reflect.DeepEqual(r.events, expected) {
+		t.Errorf("Got %v, wanted %v", r.events, expected)
+	}
+
+	r = &recorder{skip: 4}
+	Walk(testExpr(), r)
+	expected = []string{"pre 1", "pre 2", "pre 3", "post 3", "post 2", "pre 4", "post 1"}
+	if !reflect.DeepEqual(r.events, expected) {
+		t.Errorf("Got %v when skipping 4, wanted %v", r.events, expected)
+	}
+}
+
+func TestVisit(t *testing.T) {
+	var ids []int64
+	Visit(testExpr(), func(e *Expr, parent *Expr) bool {
+		ids = append(ids, e.Id)
+		_, isCall := e.Kind.(*Call)
+		return !isCall || parent == nil
+	})
+	if expected := []int64{1, 2, 3, 4}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Got %v, wanted %v", ids, expected)
+	}
+}
+
+func TestParents(t *testing.T) {
+	parents := make(map[int64]int64)
+	for id, parent := range Parents(testExpr()) {
+		parents[id] = parent.Id
+	}
+	expected := map[int64]int64{2: 1, 3: 2, 4: 1, 5: 4, 6: 4, 8: 6, 9: 6, 10: 9}
+	if !reflect.DeepEqual(parents, expected) {
+		t.Errorf("Got %v, wanted %v", parents, expected)
+	}
+}
+
+func TestChildren_Comprehension(t *testing.T) {
+	comprehension := &Expr{Id: 1, Kind: &Comprehension{
+		IterVar:       "x",
+		IterRange:     &Expr{Id: 2, Kind: &Ident{Name: "list"}},
+		AccuVar:       "__result__",
+		AccuInit:      &Expr{Id: 3, Kind: &Literal{Value: false}},
+		LoopCondition: &Expr{Id: 4, Kind: &Literal{Value: true}},
+		LoopStep:      &Expr{Id: 5, Kind: &Ident{Name: "x"}},
+		Result:        &Expr{Id: 6, Kind: &Ident{Name: "__result__"}}}}
+	var ids []int64
+	for _, child := range Children(comprehension) {
+		ids = append(ids, child.Id)
+	}
+	if expected := []int64{2, 3, 4, 5, 6}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Got %v, wanted %v", ids, expected)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+)
+
+var (
+	// Infix operators and their precedence; higher values bind tighter.
+	infixOperators = map[string]struct {
+		symbol     string
+		precedence int
+	}{
+		operators.LogicalOr:     {"||", 2},
+		operators.LogicalAnd:    {"&&", 3},
+		operators.Equals:        {"==", 4},
+		operators.NotEquals:     {"!=", 4},
+		operators.Less:          {"<", 4},
+		operators.LessEquals:    {"<=", 4},
+		operators.Greater:       {">", 4},
+		operators.GreaterEquals: {">=", 4},
+		operators.In:            {"in", 4},
+		operators.Add:           {"+", 5},
+		operators.Subtract:      {"-", 5},
+		operators.Multiply:      {"*", 6},
+		operators.Divide:        {"/", 6},
+		operators.Modulo:        {"%", 6},
+	}
+)
+
+const (
+	conditionalPrecedence = 1
+	unaryPrecedence       = 7
+	memberPrecedence      = 8
+)
+
+// Format returns the text of an expression in CEL syntax. Comprehensions,
+// which result from the expansion of macros such as 'all', are written in an
+// abbreviated form.
+func Format(e *ast.Expr) string {
+	text, _ := format(e)
+	return text
+}
+
+// format returns the text of an expression along with its precedence.
+func format(e *ast.Expr) (string, int) {
+	switch kind := e.Kind.(type) {
+	case *ast.Literal:
+		return formatLiteralValue(kind.Value), memberPrecedence
+	case *ast.Ident:
+		return kind.Name, memberPrecedence
+	case *ast.Select:
+		operand := operandText(kind.Operand, memberPrecedence)
+		if kind.TestOnly {
+			return fmt.Sprintf("has(%s.%s)", operand, kind.Field), memberPrecedence
+		}
+		return operand + "." + kind.Field, memberPrecedence
+	case *ast.Call:
+		return formatCall(kind)
+	case *ast.CreateList:
+		return "[" + formatList(kind.Elements) + "]", memberPrecedence
+	case *ast.CreateStruct:
+		entries := make([]string, len(kind.Entries))
+		for i, entry := range kind.Entries {
+			key := entry.FieldKey
+			if entry.MapKey != nil {
+				key = Format(entry.MapKey)
+			}
+			entries[i] = key + ": " + Format(entry.Value)
+		}
+		return kind.MessageName + "{" + strings.Join(entries, ", ") + "}", memberPrecedence
+	case *ast.Comprehension:
+		return fmt.Sprintf("%s.<comprehension>(%s, %s)",
+			operandText(kind.IterRange, memberPrecedence), kind.IterVar,
+			Format(kind.LoopStep)), memberPrecedence
+	}
+	return "", memberPrecedence
+}
+
+func formatCall(call *ast.Call) (string, int) {
+	if op, found := infixOperators[call.Function]; found && len(call.Args) == 2 {
+		// Operators are left-associative, so a right operand of equal
+		// precedence requires parentheses.
+		lhs := operandText(call.Args[0], op.precedence)
+		rhs := operandText(call.Args[1], op.precedence+1)
+		return lhs + " " + op.symbol + " " + rhs, op.precedence
+	}
+	switch call.Function {
+	case operators.Conditional:
+		if len(call.Args) == 3 {
+			return fmt.Sprintf("%s ? %s : %s",
+				operandText(call.Args[0], conditionalPrecedence+1),
+				operandText(call.Args[1], conditionalPrecedence+1),
+				operandText(call.Args[2], conditionalPrecedence)), conditionalPrecedence
+		}
+	case operators.LogicalNot:
+		if len(call.Args) == 1 {
+			return "!" + operandText(call.Args[0], unaryPrecedence), unaryPrecedence
+		}
+	case operators.Negate:
+		if len(call.Args) == 1 {
+			return "-" + operandText(call.Args[0], unaryPrecedence), unaryPrecedence
+		}
+	case operators.Index:
+		if len(call.Args) == 2 {
+			return operandText(call.Args[0], memberPrecedence) +
+				"[" + Format(call.Args[1]) + "]", memberPrecedence
+		}
+	}
+	args := "(" + formatList(call.Args) + ")"
+	if call.Target != nil {
+		return operandText(call.Target, memberPrecedence) + "." + call.Function + args,
+			memberPrecedence
+	}
+	return call.Function + args, memberPrecedence
+}
+
+// operandText formats an operand, enclosing it in parentheses when it binds
+// less tightly than the given precedence.
+func operandText(e *ast.Expr, precedence int) string {
+	text, p := format(e)
+	if p < precedence {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func formatList(elems []*ast.Expr) string {
+	texts := make([]string, len(elems))
+	for i, elem := range elems {
+		texts[i] = Format(elem)
+	}
+	return strings.Join(texts, ", ")
+}
+
+func formatLiteralValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case []byte:
+		return "b" + strconv.Quote(string(v))
+	case float64:
+		text := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(text, ".eEn") {
+			text += ".0"
+		}
+		return text
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return strconv.Quote(v)
+	case uint64:
+		return strconv.FormatUint(v, 10) + "u"
+	}
+	return fmt.Sprint(value)
+}
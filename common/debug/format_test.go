@@ -0,0 +1,43 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/parser/astparser"
+)
+
+func TestFormat(t *testing.T) {
+	var tests = []string{
+		`(a + b) * c - d`,
+		`a - (b - c)`,
+		`!(a || b) && c ? -x : y[0].z`,
+		`size(l) > 2u && m.f(1.5, b"x", null, true)`,
+		`{"a": [1, 2]}.a`,
+		`has(a.b) && Msg{f: 1} != null`,
+	}
+	for _, text := range tests {
+		parsed, errs := astparser.Parse(common.NewStringSource(text, "<input>"),
+			astparser.AllMacros)
+		if len(errs.GetErrors()) != 0 {
+			t.Fatal(errs.ToDisplayString())
+		}
+		if got := Format(parsed.Expr); got != text {
+			t.Errorf("Got %s, wanted %s", got, text)
+		}
+	}
+}
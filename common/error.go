@@ -19,15 +19,49 @@ import (
 	"strings"
 )
 
+// Severity is the level of an issue reported within Errors. Issues of a
+// severity below SeverityError are reported without failing the parse or
+// check which found them.
+type Severity int
+
+const (
+	// SeverityError is the severity of an issue which prevents the expression
+	// from being used, and is the default severity of an Error.
+	SeverityError Severity = iota
+
+	// SeverityWarning is the severity of a likely defect, e.g. the use of a
+	// deprecated declaration.
+	SeverityWarning
+
+	// SeverityInfo is the severity of an observation, e.g. a sub-expression
+	// whose type degraded to dyn.
+	SeverityInfo
+)
+
+// String implements the fmt.Stringer interface method.
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "ERROR"
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityInfo:
+		return "INFO"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
 // Error type which references a location within source and a message.
 type Error struct {
 	Location Location
 	Message  string
+	Severity Severity
 }
 
 // Stringer implementation that places errors in context with the source.
 func (e *Error) ToDisplayString(source Source) string {
-	var result = fmt.Sprintf("ERROR: %s:%d:%d: %s",
+	var result = fmt.Sprintf("%s: %s:%d:%d: %s",
+		e.Severity,
 		source.Description(),
 		e.Location.Line(),
 		e.Location.Column()+1, // add one to the 0-based column for display
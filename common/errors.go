@@ -18,7 +18,8 @@ import (
 	"fmt"
 )
 
-// Errors type which contains a list of errors observed during parsing.
+// Errors type which contains a list of errors observed during parsing, along
+// with the warnings and other issues of lesser severity.
 type Errors struct {
 	errors []Error
 	source Source
@@ -33,19 +34,67 @@ func NewErrors(source Source) *Errors {
 
 // ReportError records an error at a source location.
 func (e *Errors) ReportError(l Location, format string, args ...interface{}) {
-	err := Error{
+	e.report(SeverityError, l, format, args...)
+}
+
+// ReportWarning records a warning at a source location. Warnings do not
+// count as errors, so that they may be surfaced without failing the parse or
+// check which found them.
+func (e *Errors) ReportWarning(l Location, format string, args ...interface{}) {
+	e.report(SeverityWarning, l, format, args...)
+}
+
+// ReportInfo records an informational issue at a source location.
+func (e *Errors) ReportInfo(l Location, format string, args ...interface{}) {
+	e.report(SeverityInfo, l, format, args...)
+}
+
+// ReportIssue records an issue of any severity, e.g. a finding of a linter.
+func (e *Errors) ReportIssue(issue Error) {
+	e.errors = append(e.errors, issue)
+}
+
+func (e *Errors) report(severity Severity, l Location, format string,
+	args ...interface{}) {
+	e.ReportIssue(Error{
 		Location: l,
 		Message:  fmt.Sprintf(format, args...),
-	}
-	e.errors = append(e.errors, err)
+		Severity: severity,
+	})
 }
 
-// GetErrors returns the list of observed errors.
+// GetErrors returns the list of observed errors, excluding the issues of
+// lesser severity.
 func (e *Errors) GetErrors() []Error {
-	return e.errors[:]
+	return e.GetIssues(SeverityError)
+}
+
+// GetWarnings returns the list of observed warnings and informational
+// issues.
+func (e *Errors) GetWarnings() []Error {
+	return e.GetIssues(SeverityWarning, SeverityInfo)
+}
+
+// GetIssues returns the list of observed issues of the given severities, or
+// of every severity when none are given, in the order they were reported.
+func (e *Errors) GetIssues(severities ...Severity) []Error {
+	if len(severities) == 0 {
+		return e.errors[:]
+	}
+	issues := []Error{}
+	for _, issue := range e.errors {
+		for _, severity := range severities {
+			if issue.Severity == severity {
+				issues = append(issues, issue)
+				break
+			}
+		}
+	}
+	return issues
 }
 
-// ToDisplayString returns the error set to a newline delimited string.
+// ToDisplayString returns the issues, including those of lesser severity than
+// errors, as a newline delimited string.
 func (e *Errors) ToDisplayString() string {
 	var result = ""
 	for i, err := range e.errors {
@@ -44,3 +44,33 @@ func TestErrors(t *testing.T) {
 		t.Errorf("%s got %s, wanted %s", t.Name(), actual, expected)
 	}
 }
+
+func TestErrors_Severity(t *testing.T) {
+	source := NewStringSource("a.b && c", "severity-test")
+	errors := NewErrors(source)
+	errors.ReportWarning(NewLocation(1, 1), "'b' is deprecated")
+	errors.ReportInfo(NewLocation(1, 7), "'c' has type 'dyn'")
+	if len(errors.GetErrors()) != 0 {
+		t.Errorf("Got errors %v, wanted none", errors.GetErrors())
+	}
+	if len(errors.GetWarnings()) != 2 || len(errors.GetIssues(SeverityInfo)) != 1 {
+		t.Errorf("Got warnings %v, wanted 2", errors.GetWarnings())
+	}
+	errors.ReportError(NewLocation(1, 0), "undeclared reference to 'a'")
+	if len(errors.GetErrors()) != 1 || len(errors.GetIssues()) != 3 {
+		t.Errorf("Got issues %v, wanted 3", errors.GetIssues())
+	}
+	expected :=
+		"WARNING: severity-test:1:2: 'b' is deprecated\n" +
+			" | a.b && c\n" +
+			" | .^\n" +
+			"INFO: severity-test:1:8: 'c' has type 'dyn'\n" +
+			" | a.b && c\n" +
+			" | .......^\n" +
+			"ERROR: severity-test:1:1: undeclared reference to 'a'\n" +
+			" | a.b && c\n" +
+			" | ^"
+	if actual := errors.ToDisplayString(); actual != expected {
+		t.Errorf("Got %s, wanted %s", actual, expected)
+	}
+}
@@ -40,6 +40,8 @@ const (
 	All           = "all"
 	Exists        = "exists"
 	ExistsOne     = "exists_one"
+	AllUnique     = "all_unique"
+	ExistsUnique  = "exists_unique"
 	Map           = "map"
 	Filter        = "filter"
 )
@@ -106,10 +106,147 @@ const (
 	SizeListInst   = "list_size"
 	SizeMapInst    = "map_size"
 
+	// Null coalescing function
+	Coalesce = "coalesce"
+
+	// Oneof introspection function
+	WhichOneof = "whichOneof"
+
+	// Safe arithmetic functions, declared separately from the standard
+	// functions.
+	SafeDiv       = "safeDiv"
+	SafeDivInt64  = "safe_div_int64"
+	SafeDivUint64 = "safe_div_uint64"
+	SafeDivDouble = "safe_div_double"
+	SafeMod       = "safeMod"
+	SafeModInt64  = "safe_mod_int64"
+	SafeModUint64 = "safe_mod_uint64"
+
+	// Math predicates, declared separately from the standard functions.
+	MathIsNaN          = "math.isNaN"
+	MathIsNaNDouble    = "math_is_nan_double"
+	MathIsInf          = "math.isInf"
+	MathIsInfDouble    = "math_is_inf_double"
+	MathIsFinite       = "math.isFinite"
+	MathIsFiniteDouble = "math_is_finite_double"
+
+	// Math functions, declared separately from the standard functions. The
+	// ids of their overloads are formed from the name of the function and the
+	// types of the arguments, e.g. 'math_greatest_int_int'.
+	MathGreatest      = "math.greatest"
+	MathLeast         = "math.least"
+	MathAbs           = "math.abs"
+	MathCeil          = "math.ceil"
+	MathFloor         = "math.floor"
+	MathRound         = "math.round"
+	MathSqrt          = "math.sqrt"
+	MathBitAnd        = "math.bitAnd"
+	MathBitOr         = "math.bitOr"
+	MathBitXor        = "math.bitXor"
+	MathBitNot        = "math.bitNot"
+	MathBitShiftLeft  = "math.bitShiftLeft"
+	MathBitShiftRight = "math.bitShiftRight"
+
+	// Edit distance functions, declared separately from the standard functions.
+	StringsEditDistance       = "strings.editDistance"
+	StringsEditDistanceString = "strings_edit_distance_string_string"
+	StringsSimilar            = "strings.similar"
+	StringsSimilarString      = "strings_similar_string_string_double"
+
+	// String functions, declared separately from the standard functions.
+	Split                  = "split"
+	SplitString            = "string_split_string"
+	SplitStringInt         = "string_split_string_int"
+	Join                   = "join"
+	JoinList               = "list_join"
+	JoinListString         = "list_join_string"
+	Replace                = "replace"
+	ReplaceStringString    = "string_replace_string_string"
+	ReplaceStringStringInt = "string_replace_string_string_int"
+	Substring              = "substring"
+	SubstringInt           = "string_substring_int"
+	SubstringIntInt        = "string_substring_int_int"
+	IndexOf                = "indexOf"
+	IndexOfString          = "string_index_of_string"
+	IndexOfStringInt       = "string_index_of_string_int"
+	LowerAscii             = "lowerAscii"
+	LowerAsciiString       = "string_lower_ascii"
+	UpperAscii             = "upperAscii"
+	UpperAsciiString       = "string_upper_ascii"
+	Trim                   = "trim"
+	TrimString             = "string_trim"
+	Format                 = "format"
+	FormatStringList       = "string_format_list"
+
+	// Encoder functions, declared separately from the standard functions.
+	Base64Encode       = "base64.encode"
+	Base64EncodeBytes  = "base64_encode_bytes"
+	Base64Decode       = "base64.decode"
+	Base64DecodeString = "base64_decode_string"
+	HexEncode          = "hex.encode"
+	HexEncodeBytes     = "hex_encode_bytes"
+	HexDecode          = "hex.decode"
+	HexDecodeString    = "hex_decode_string"
+	URLEncode          = "url.encode"
+	URLEncodeString    = "url_encode_string"
+	URLDecode          = "url.decode"
+	URLDecodeString    = "url_decode_string"
+	UTF8Valid          = "utf8.valid"
+	UTF8ValidBytes     = "utf8_valid_bytes"
+
+	// HTTP header functions, declared separately from the standard functions.
+	HeadersGet            = "get"
+	HeadersGetString      = "headers_get_string"
+	HeadersGetAll         = "getAll"
+	HeadersGetAllString   = "headers_get_all_string"
+	HeadersContains       = "contains"
+	HeadersContainsString = "headers_contains_string"
+
+	// Geo functions, declared separately from the standard functions.
+	GeoCountry       = "geo.country"
+	GeoCountryString = "geo_country_string"
+	GeoRegion        = "geo.region"
+	GeoRegionString  = "geo_region_string"
+
+	// IAM functions, declared separately from the standard functions.
+	InIPRange       = "inIpRange"
+	InIPRangeString = "in_ip_range_string"
+
+	// List matcher functions, declared separately from the standard functions.
+	MatcherFromList            = "matcher.fromList"
+	MatcherFromListString      = "matcher_from_list_string"
+	ContainsAny                = "containsAny"
+	MatcherContainsAnyString   = "matcher_contains_any_string"
+	StartsWithAny              = "startsWithAny"
+	MatcherStartsWithAnyString = "matcher_starts_with_any_string"
+	EndsWithAny                = "endsWithAny"
+	MatcherEndsWithAnyString   = "matcher_ends_with_any_string"
+
+	// Regex functions, declared separately from the standard functions.
+	RegexMatches          = "re.matches"
+	RegexMatchesString    = "re_matches_string_string"
+	RegexCapture          = "re.capture"
+	RegexCaptureString    = "re_capture_string_string"
+	RegexCaptureAll       = "re.captureAll"
+	RegexCaptureAllString = "re_capture_all_string_string"
+	RegexReplace          = "re.replace"
+	RegexReplaceString    = "re_replace_string_string_string"
+
 	// Matches function
 	Matches     = "matches"
 	MatchString = "matches_string"
 
+	// Key uniqueness functions, called by the expansions of the all_unique and
+	// exists_unique macros with the list of the keys of the elements.
+	AllUniqueKeys    = "@all_unique"
+	AllUniqueList    = "all_unique_list"
+	ExistsUniqueKeys = "@exists_unique"
+	ExistsUniqueList = "exists_unique_list"
+
+	// Glob matching function
+	MatchesGlob       = "matchesGlob"
+	MatchesGlobString = "matches_glob_string"
+
 	// Time-based functions
 	TimeGetFullYear     = "getFullYear"
 	TimeGetMonth        = "getMonth"
@@ -122,6 +259,27 @@ const (
 	TimeGetSeconds      = "getSeconds"
 	TimeGetMilliseconds = "getMilliseconds"
 
+	// Time extension functions, declared separately from the standard
+	// functions.
+	TruncateToMinute           = "truncateToMinute"
+	TimestampTruncateToMinute  = "timestamp_truncate_to_minute"
+	TruncateToHour             = "truncateToHour"
+	TimestampTruncateToHour    = "timestamp_truncate_to_hour"
+	TruncateToHourWithTz       = "timestamp_truncate_to_hour_with_tz"
+	TruncateToDay              = "truncateToDay"
+	TimestampTruncateToDay     = "timestamp_truncate_to_day"
+	TruncateToDayWithTz        = "timestamp_truncate_to_day_with_tz"
+	Since                      = "since"
+	SinceTimestamp             = "since_timestamp"
+	Until                      = "until"
+	UntilTimestamp             = "until_timestamp"
+	Age                        = "age"
+	AgeTimestamp               = "age_timestamp"
+	AgeTimestampWithTz         = "age_timestamp_with_tz"
+	ParseTimestamp             = "parseTimestamp"
+	ParseTimestampString       = "parse_timestamp_string_string"
+	ParseTimestampStringWithTz = "parse_timestamp_string_string_with_tz"
+
 	// Timestamp overloads for time functions without timezones.
 	TimestampToYear                = "timestamp_to_year"
 	TimestampToMonth               = "timestamp_to_month"
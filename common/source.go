@@ -17,7 +17,7 @@ package common
 import (
 	"strings"
 
-	"github.com/google/cel-spec/proto/v1/syntax"
+	"github.com/google/cel-go/common/ast"
 )
 
 // Source interface for filter source contents.
@@ -86,7 +86,11 @@ func NewStringSource(contents string, description string) Source {
 	}
 }
 
-func NewInfoSource(info *syntax.SourceInfo) Source {
+// NewInfoSource creates a Source without contents from the source information
+// of a parsed expression, e.g. to locate the errors of an expression whose
+// text is not available. The proto form of the information may be converted
+// with astpb#FromSourceInfo.
+func NewInfoSource(info *ast.SourceInfo) Source {
 	return &sourceImpl{
 		contents:    "",
 		description: info.Location,
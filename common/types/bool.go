@@ -38,7 +38,7 @@ var (
 
 func (b Bool) Compare(other ref.Value) ref.Value {
 	if BoolType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	otherBool := other.(Bool)
 	if b == otherBool {
@@ -36,14 +36,14 @@ var (
 
 func (b Bytes) Add(other ref.Value) ref.Value {
 	if BytesType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return append(b, other.(Bytes)...)
 }
 
 func (b Bytes) Compare(other ref.Value) ref.Value {
 	if BytesType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return Int(bytes.Compare(b, other.(Bytes)))
 }
@@ -19,6 +19,7 @@ import (
 	"github.com/golang/protobuf/ptypes/struct"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/common/types/traits"
+	"math"
 	"reflect"
 )
 
@@ -39,14 +40,14 @@ var (
 
 func (d Double) Add(other ref.Value) ref.Value {
 	if DoubleType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return d + other.(Double)
 }
 
 func (d Double) Compare(other ref.Value) ref.Value {
 	if DoubleType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	if d < other.(Double) {
 		return IntNegOne
@@ -88,9 +89,18 @@ func (d Double) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
 func (d Double) ConvertToType(typeVal ref.Type) ref.Value {
 	switch typeVal {
 	case IntType:
-		return Int(float64(d))
+		// Conversions truncate toward zero, and values which cannot be
+		// represented after truncation, including NaN and infinities, are
+		// errors.
+		if i := math.Trunc(float64(d)); i >= -(1<<63) && i < 1<<63 {
+			return Int(i)
+		}
+		return NewErr("range error converting %g to int", float64(d))
 	case UintType:
-		return Uint(float64(d))
+		if u := math.Trunc(float64(d)); u >= 0 && u < 1<<64 {
+			return Uint(u)
+		}
+		return NewErr("range error converting %g to uint", float64(d))
 	case DoubleType:
 		return d
 	case StringType:
@@ -103,7 +113,7 @@ func (d Double) ConvertToType(typeVal ref.Type) ref.Value {
 
 func (d Double) Divide(other ref.Value) ref.Value {
 	if DoubleType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	if other.(Double) == Double(0) {
 		return NewErr("divide by zero")
@@ -117,7 +127,7 @@ func (d Double) Equal(other ref.Value) ref.Value {
 
 func (d Double) Multiply(other ref.Value) ref.Value {
 	if DoubleType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return d * other.(Double)
 }
@@ -128,7 +138,7 @@ func (d Double) Negate() ref.Value {
 
 func (d Double) Subtract(subtrahend ref.Value) ref.Value {
 	if DoubleType != subtrahend.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return d - subtrahend.(Double)
 }
@@ -17,6 +17,8 @@ package types
 import (
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/struct"
+	"github.com/google/cel-go/common/types/ref"
+	"math"
 	"reflect"
 	"testing"
 )
@@ -106,9 +108,12 @@ func TestDouble_ConvertToType(t *testing.T) {
 	if !Double(-4.5).ConvertToType(IntType).Equal(Int(-4)).(Bool) {
 		t.Error("Unsuccessful type conversion to int")
 	}
-	if !Double(-4.5).ConvertToType(UintType).Equal(Uint(18446744073709551612)).(Bool) {
+	if !Double(4.5).ConvertToType(UintType).Equal(Uint(4)).(Bool) {
 		t.Error("Unsuccessful type conversion to uint")
 	}
+	if !IsError(Double(-4.5).ConvertToType(UintType)) {
+		t.Error("Got uint, expected range error")
+	}
 	if !Double(-4.5).ConvertToType(DoubleType).Equal(Double(-4.5)).(Bool) {
 		t.Error("Unsuccessful type conversion to double")
 	}
@@ -164,3 +169,31 @@ func TestDouble_Subtract(t *testing.T) {
 		t.Error("Subtraction permitted without express type-conversion.")
 	}
 }
+
+func TestDouble_ConvertToType_Range(t *testing.T) {
+	for _, tst := range []struct {
+		in       Double
+		typ      ref.Type
+		expected ref.Value
+	}{
+		{in: -0.9, typ: IntType, expected: Int(0)},
+		{in: -0.9, typ: UintType, expected: Uint(0)},
+		{in: -9223372036854775808.0, typ: IntType, expected: Int(math.MinInt64)},
+		{in: 9223372036854774784.0, typ: IntType, expected: Int(9223372036854774784)},
+		{in: 18446744073709549568.0, typ: UintType, expected: Uint(18446744073709549568)},
+		{in: 9223372036854775808.0, typ: IntType},
+		{in: 18446744073709551616.0, typ: UintType},
+		{in: Double(math.NaN()), typ: IntType},
+		{in: Double(math.Inf(-1)), typ: IntType},
+		{in: Double(math.Inf(1)), typ: UintType},
+	} {
+		val := tst.in.ConvertToType(tst.typ)
+		if tst.expected == nil {
+			if !IsError(val) {
+				t.Errorf("%s(%g): got %v, wanted range error", tst.typ, tst.in, val)
+			}
+		} else if val.Equal(tst.expected) != True {
+			t.Errorf("%s(%g): got %v, wanted %v", tst.typ, tst.in, val, tst.expected)
+		}
+	}
+}
@@ -46,50 +46,28 @@ var (
 func (d Duration) Add(other ref.Value) ref.Value {
 	switch other.Type() {
 	case DurationType:
-		dur1, err := ptypes.Duration(d.Duration)
-		if err != nil {
-			return &Err{err}
-		}
-		dur2, err := ptypes.Duration(other.(Duration).Duration)
-		if err != nil {
-			return &Err{err}
-		}
-		return Duration{ptypes.DurationProto(dur1 + dur2)}
+		o := other.(Duration)
+		return newDuration(d.GetSeconds()+o.GetSeconds(),
+			int64(d.GetNanos())+int64(o.GetNanos()))
 	case TimestampType:
-		dur, err := ptypes.Duration(d.Duration)
-		if err != nil {
-			return &Err{err}
-		}
-		ts, err := ptypes.Timestamp(other.(Timestamp).Timestamp)
-		if err != nil {
-			return &Err{err}
-		}
-		tstamp, err := ptypes.TimestampProto(ts.Add(dur))
-		if err != nil {
-			return &Err{err}
-		}
-		return Timestamp{tstamp}
+		ts := other.(Timestamp)
+		return newTimestamp(ts.GetSeconds()+d.GetSeconds(),
+			int64(ts.GetNanos())+int64(d.GetNanos()))
 	}
-	return NewErr("unsupported overload")
+	return newUnsupportedOverloadErr()
 }
 
 func (d Duration) Compare(other ref.Value) ref.Value {
 	if DurationType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
-	dur1, err := ptypes.Duration(d.Duration)
-	if err != nil {
-		return &Err{err}
-	}
-	dur2, err := ptypes.Duration(other.(Duration).Duration)
-	if err != nil {
-		return &Err{err}
-	}
-	dur := dur1 - dur2
-	if dur < 0 {
+	o := other.(Duration)
+	switch {
+	case d.GetSeconds() < o.GetSeconds(),
+		d.GetSeconds() == o.GetSeconds() && d.GetNanos() < o.GetNanos():
 		return IntNegOne
-	}
-	if dur > 0 {
+	case d.GetSeconds() > o.GetSeconds(),
+		d.GetSeconds() == o.GetSeconds() && d.GetNanos() > o.GetNanos():
 		return IntOne
 	}
 	return IntZero
@@ -131,29 +109,21 @@ func (d Duration) Equal(other ref.Value) ref.Value {
 }
 
 func (d Duration) Negate() ref.Value {
-	dur, err := ptypes.Duration(d.Duration)
-	if err != nil {
-		return &Err{err}
-	}
-	return Duration{ptypes.DurationProto(-dur)}
+	return newDuration(-d.GetSeconds(), -int64(d.GetNanos()))
 }
 
 func (d Duration) Receive(function string, overload string, args []ref.Value) ref.Value {
-	dur, err := ptypes.Duration(d.Duration)
-	if err != nil {
-		return &Err{err}
-	}
 	if len(args) == 0 {
 		if f, found := durationZeroArgOverloads[function]; found {
-			return f(dur)
+			return f(d.Duration)
 		}
 	}
-	return NewErr("unsupported overload")
+	return newUnsupportedOverloadErr()
 }
 
 func (d Duration) Subtract(subtrahend ref.Value) ref.Value {
 	if DurationType != subtrahend.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return d.Add(subtrahend.(Duration).Negate())
 }
@@ -169,17 +139,59 @@ func (d Duration) Value() interface{} {
 var (
 	durationValueType = reflect.TypeOf(&dpb.Duration{})
 
-	durationZeroArgOverloads = map[string]func(time.Duration) ref.Value{
-		overloads.TimeGetHours: func(dur time.Duration) ref.Value {
-			return Int(dur.Hours())
+	durationZeroArgOverloads = map[string]func(*dpb.Duration) ref.Value{
+		overloads.TimeGetHours: func(dur *dpb.Duration) ref.Value {
+			return Int(dur.GetSeconds() / 3600)
 		},
-		overloads.TimeGetMinutes: func(dur time.Duration) ref.Value {
-			return Int(dur.Minutes())
+		overloads.TimeGetMinutes: func(dur *dpb.Duration) ref.Value {
+			return Int(dur.GetSeconds() / 60)
 		},
-		overloads.TimeGetSeconds: func(dur time.Duration) ref.Value {
-			return Int(dur.Seconds())
+		overloads.TimeGetSeconds: func(dur *dpb.Duration) ref.Value {
+			return Int(dur.GetSeconds())
 		},
-		overloads.TimeGetMilliseconds: func(dur time.Duration) ref.Value {
-			return Int(dur.Nanoseconds() / 1000000)
+		overloads.TimeGetMilliseconds: func(dur *dpb.Duration) ref.Value {
+			return Int(dur.GetSeconds()*1000 + int64(dur.GetNanos())/1000000)
 		}}
 )
+
+const (
+	// maxDurationSeconds is the magnitude of the longest duration of the CEL
+	// spec, which is 10000 years and longer than a time.Duration.
+	maxDurationSeconds = 315576000000
+)
+
+// newDuration returns the duration of the seconds and nanos, which may be of
+// either sign and need not be normalized, or an error if it is outside of the
+// range of the CEL spec.
+func newDuration(seconds int64, nanos int64) ref.Value {
+	seconds += nanos / int64(time.Second)
+	nanos %= int64(time.Second)
+	if seconds > 0 && nanos < 0 {
+		seconds--
+		nanos += int64(time.Second)
+	} else if seconds < 0 && nanos > 0 {
+		seconds++
+		nanos -= int64(time.Second)
+	}
+	d := &dpb.Duration{Seconds: seconds, Nanos: int32(nanos)}
+	if err := checkDuration(d); err != nil {
+		return err
+	}
+	return Duration{d}
+}
+
+// checkDuration returns an error if the duration has a sign or precision
+// inconsistent between its seconds and nanos, or if it is outside of the range
+// of +/-10000 years of the CEL spec.
+func checkDuration(d *dpb.Duration) *Err {
+	seconds, nanos := d.GetSeconds(), d.GetNanos()
+	switch {
+	case seconds > maxDurationSeconds || seconds < -maxDurationSeconds:
+		return NewErr("duration (%v) out of range", d)
+	case nanos >= int32(time.Second) || nanos <= -int32(time.Second):
+		return NewErr("duration (%v) has out-of-range nanos", d)
+	case seconds > 0 && nanos < 0 || seconds < 0 && nanos > 0:
+		return NewErr("duration (%v) has seconds and nanos with different signs", d)
+	}
+	return nil
+}
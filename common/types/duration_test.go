@@ -30,6 +30,26 @@ func TestDuration_Add(t *testing.T) {
 	}
 }
 
+func TestDuration_Add_Range(t *testing.T) {
+	// 300 years are longer than a time.Duration, but within the range of
+	// durations of the CEL spec.
+	years := Duration{&dpb.Duration{Seconds: 300 * 31557600}}
+	if sum := years.Add(years); sum.Equal(Duration{&dpb.Duration{Seconds: 600 * 31557600}}) != True {
+		t.Errorf("Got '%v', wanted 600 years", sum)
+	}
+	if hours := years.Receive(overloads.TimeGetHours, overloads.DurationToHours,
+		[]ref.Value{}); hours != Int(300*8766) {
+		t.Errorf("Got '%v', wanted %d hours", hours, 300*8766)
+	}
+	max := Duration{&dpb.Duration{Seconds: 315576000000}}
+	if !IsError(max.Add(max)) {
+		t.Error("Got sum of durations, wanted out of range error")
+	}
+	if !IsError(max.Negate().(Duration).Subtract(max)) {
+		t.Error("Got difference of durations, wanted out of range error")
+	}
+}
+
 func TestDuration_Compare(t *testing.T) {
 	d := Duration{&dpb.Duration{Seconds: 7506}}
 	lt := Duration{&dpb.Duration{Seconds: -10}}
@@ -92,6 +112,14 @@ func TestDuration_Negate(t *testing.T) {
 	}
 }
 
+func TestDuration_Subtract_Normalized(t *testing.T) {
+	d := Duration{&dpb.Duration{Seconds: 1, Nanos: 100}}
+	diff := d.Subtract(Duration{&dpb.Duration{Seconds: 2, Nanos: 200}}).(Duration)
+	if !proto.Equal(diff.Duration, &dpb.Duration{Seconds: -1, Nanos: -100}) {
+		t.Errorf("Got '%v', expected seconds: -1, nanos: -100", diff)
+	}
+}
+
 func TestDuration_Receive_GetHours(t *testing.T) {
 	d := Duration{&dpb.Duration{Seconds: 7506}}
 	hr := d.Receive(overloads.TimeGetHours, overloads.DurationToHours, []ref.Value{})
@@ -0,0 +1,267 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/cel-go/common/types/pb"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// dynamicObj is a message whose type is only known by its descriptor, such as
+// a type registered from a FileDescriptorSet at runtime, and which therefore
+// has no generated struct. The values of its set fields are held by field
+// name.
+type dynamicObj struct {
+	typeDesc  *pb.TypeDescription
+	typeValue *TypeValue
+	fields    map[string]ref.Value
+}
+
+// newDynamicObject returns a message of a type known only by its descriptor,
+// whose fields are initialized with the given values. An error is returned
+// for an unknown field, or a value of the wrong type for its field.
+func newDynamicObject(typeDesc *pb.TypeDescription,
+	fields map[string]ref.Value) ref.Value {
+	o := &dynamicObj{
+		typeDesc:  typeDesc,
+		typeValue: NewObjectTypeValue(typeDesc.Name()),
+		fields:    make(map[string]ref.Value, len(fields))}
+	for name, value := range fields {
+		f, found := typeDesc.FieldByName(name)
+		if !found {
+			return NewErr("no such field '%s'", name)
+		}
+		if IsUnknownOrError(value) {
+			return value
+		}
+		if err := checkFieldValue(f, value); err != nil {
+			return &Err{error: err}
+		}
+		if value == NullValue && f.IsMessage() {
+			// Assigning null to a message field leaves it unset.
+			continue
+		}
+		o.fields[f.OrigName()] = value
+	}
+	return o
+}
+
+// checkFieldValue returns an error if the value may not be assigned to the
+// field.
+func checkFieldValue(f *pb.FieldDescription, value ref.Value) error {
+	var valid bool
+	switch {
+	case f.IsMap():
+		_, valid = value.(traits.Mapper)
+	case f.IsRepeated():
+		_, valid = value.(traits.Lister)
+	default:
+		valid = isAssignable(f.CheckedType(), f.TypeName(), value)
+	}
+	if !valid {
+		return fmt.Errorf("type conversion error from '%s' to field '%s'",
+			value.Type().TypeName(), f.OrigName())
+	}
+	return nil
+}
+
+// isAssignable returns whether a value may be assigned to a singular field of
+// the checked type, or of the named message type.
+func isAssignable(t *checkedpb.Type, typeName string, value ref.Value) bool {
+	switch t.TypeKind.(type) {
+	case *checkedpb.Type_Primitive:
+		return value.Type() == primitiveTypes[t.GetPrimitive()]
+	case *checkedpb.Type_Wrapper:
+		return value == NullValue ||
+			value.Type() == primitiveTypes[t.GetWrapper()]
+	case *checkedpb.Type_WellKnown:
+		switch t.GetWellKnown() {
+		case checkedpb.Type_TIMESTAMP:
+			return value == NullValue || value.Type() == TimestampType
+		case checkedpb.Type_DURATION:
+			return value == NullValue || value.Type() == DurationType
+		}
+		return true
+	case *checkedpb.Type_MessageType:
+		return value == NullValue || value.Type().TypeName() == typeName
+	}
+	// Values of JSON and null types are not restricted.
+	return true
+}
+
+var primitiveTypes = map[checkedpb.Type_PrimitiveType]ref.Type{
+	checkedpb.Type_BOOL:   BoolType,
+	checkedpb.Type_BYTES:  BytesType,
+	checkedpb.Type_DOUBLE: DoubleType,
+	checkedpb.Type_INT64:  IntType,
+	checkedpb.Type_STRING: StringType,
+	checkedpb.Type_UINT64: UintType,
+}
+
+func (o *dynamicObj) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	// If the object is already assignable to the desired type return it.
+	if reflect.TypeOf(o).AssignableTo(typeDesc) {
+		return o, nil
+	}
+	return nil, fmt.Errorf("type conversion error from '%s' to '%v'",
+		o.typeDesc.Name(), typeDesc)
+}
+
+func (o *dynamicObj) ConvertToType(typeVal ref.Type) ref.Value {
+	switch typeVal {
+	default:
+		if o.Type().TypeName() == typeVal.TypeName() {
+			return o
+		}
+	case TypeType:
+		return o.typeValue
+	}
+	return NewErr("type conversion error from '%s' to '%s'",
+		o.typeDesc.Name(), typeVal)
+}
+
+// Equal returns whether the other value is a message of the same type whose
+// fields, including those which are unset, have equal values.
+func (o *dynamicObj) Equal(other ref.Value) ref.Value {
+	otherObj, isObj := other.(*dynamicObj)
+	if !isObj || o.typeDesc.Name() != otherObj.typeDesc.Name() {
+		return False
+	}
+	for _, f := range o.typeDesc.Fields() {
+		if o.isFieldSet(f) != otherObj.isFieldSet(f) {
+			return False
+		}
+		if !o.isFieldSet(f) {
+			continue
+		}
+		field := String(f.OrigName())
+		if eq := o.Get(field).Equal(otherObj.Get(field)); eq != True {
+			return False
+		}
+	}
+	return True
+}
+
+func (o *dynamicObj) Get(index ref.Value) ref.Value {
+	if index.Type() != StringType {
+		return NewErr("illegal object field type '%s'", index.Type())
+	}
+	f, found := o.typeDesc.FieldByName(string(index.(String)))
+	if !found {
+		return newMissingFieldErr("no such field '%s'", index)
+	}
+	if value, found := o.fields[f.OrigName()]; found {
+		return value
+	}
+	return defaultFieldValue(f)
+}
+
+// defaultFieldValue returns the value of a field which is unset.
+func defaultFieldValue(f *pb.FieldDescription) ref.Value {
+	switch {
+	case f.IsMap():
+		return NewDynamicMap(map[string]string{})
+	case f.IsRepeated():
+		return NewValueList([]ref.Value{})
+	case f.IsEnum():
+		return Int(0)
+	case f.IsMessage():
+		if _, isWrapper := f.CheckedType().TypeKind.(*checkedpb.Type_Wrapper); isWrapper {
+			return NullValue
+		}
+		td, err := pb.DescribeType(f.TypeName())
+		if err != nil {
+			return NewErr("unknown type '%s'", f.TypeName())
+		}
+		if refType := td.ReflectType(); refType != nil {
+			return NativeToValue(reflect.New(refType.Elem()).Interface().(proto.Message))
+		}
+		return newDynamicObject(td, nil)
+	}
+	switch primitiveTypes[f.CheckedType().GetPrimitive()] {
+	case BoolType:
+		return False
+	case BytesType:
+		return Bytes{}
+	case DoubleType:
+		return Double(0)
+	case IntType:
+		return Int(0)
+	case StringType:
+		return String("")
+	case UintType:
+		return Uint(0)
+	}
+	return NewErr("unsupported type of field '%s'", f.OrigName())
+}
+
+// IsSet returns whether the field is set within the message.
+//
+// Fields which support presence detection are set when they have been
+// assigned a value. Otherwise, repeated and map fields are set when they are
+// non-empty, and scalar fields are set when they have a non-default value.
+func (o *dynamicObj) IsSet(field ref.Value) ref.Value {
+	if field.Type() != StringType {
+		return NewErr("illegal object field type '%s'", field.Type())
+	}
+	f, found := o.typeDesc.FieldByName(string(field.(String)))
+	if !found {
+		return newMissingFieldErr("no such field '%s'", field)
+	}
+	return Bool(o.isFieldSet(f))
+}
+
+func (o *dynamicObj) isFieldSet(f *pb.FieldDescription) bool {
+	value, found := o.fields[f.OrigName()]
+	if !found || f.SupportsPresence() {
+		return found
+	}
+	if sizer, isSizer := value.(traits.Sizer); isSizer && f.IsRepeated() {
+		return sizer.Size() != Int(0)
+	}
+	return value.Equal(defaultFieldValue(f)) != True
+}
+
+// Iterator iterates over the names of the set fields, in declaration order.
+func (o *dynamicObj) Iterator() traits.Iterator {
+	var names []string
+	for _, f := range o.typeDesc.Fields() {
+		if o.isFieldSet(f) {
+			names = append(names, f.OrigName())
+		}
+	}
+	return NewStringList(names).Iterator()
+}
+
+func (o *dynamicObj) Type() ref.Type {
+	return o.typeValue
+}
+
+// Value returns the values of the set fields, by field name.
+func (o *dynamicObj) Value() interface{} {
+	fields := make(map[string]ref.Value, len(o.fields))
+	for _, f := range o.typeDesc.Fields() {
+		if o.isFieldSet(f) {
+			fields[f.OrigName()] = o.fields[f.OrigName()]
+		}
+	}
+	return fields
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	descpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+func TestDynamicObject(t *testing.T) {
+	field := func(name string, number int32, fieldType descpb.FieldDescriptorProto_Type,
+		typeName string) *descpb.FieldDescriptorProto {
+		f := &descpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   fieldType.Enum()}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	tags := field("tags", 3, descpb.FieldDescriptorProto_TYPE_STRING, "")
+	tags.Label = descpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	user := field("user", 4, descpb.FieldDescriptorProto_TYPE_STRING, "")
+	user.OneofIndex = proto.Int32(0)
+	group := field("group", 5, descpb.FieldDescriptorProto_TYPE_STRING, "")
+	group.OneofIndex = proto.Int32(0)
+	fds := &descpb.FileDescriptorSet{
+		File: []*descpb.FileDescriptorProto{{
+			Name:       proto.String("acme/dynamic/policy.proto"),
+			Package:    proto.String("acme.dynamic"),
+			Dependency: []string{"google/protobuf/wrappers.proto"},
+			Syntax:     proto.String("proto3"),
+			MessageType: []*descpb.DescriptorProto{{
+				Name: proto.String("Rule"),
+				Field: []*descpb.FieldDescriptorProto{
+					field("name", 1, descpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("priority", 2, descpb.FieldDescriptorProto_TYPE_INT32, ""),
+					tags, user, group,
+					field("limit", 6, descpb.FieldDescriptorProto_TYPE_MESSAGE,
+						".google.protobuf.Int64Value"),
+					field("parent", 7, descpb.FieldDescriptorProto_TYPE_MESSAGE,
+						".acme.dynamic.Rule")},
+				OneofDecl: []*descpb.OneofDescriptorProto{{
+					Name: proto.String("subject")}},
+			}},
+		}}}
+	typeProvider := NewProvider()
+	if err := typeProvider.(DescriptorRegistry).RegisterDescriptors(fds); err != nil {
+		t.Fatal(err)
+	}
+	rule := &checkedpb.Type{
+		TypeKind: &checkedpb.Type_MessageType{MessageType: "acme.dynamic.Rule"}}
+	if limit, found := typeProvider.FindFieldType(rule, "limit"); !found ||
+		limit.Type.GetWrapper() != checkedpb.Type_INT64 {
+		t.Errorf("Unexpected field type for 'limit': %v", limit)
+	}
+
+	parent := typeProvider.NewValue("acme.dynamic.Rule", map[string]ref.Value{
+		"name": String("root")})
+	obj := typeProvider.NewValue("acme.dynamic.Rule", map[string]ref.Value{
+		"name":     String("child"),
+		"priority": Int(0),
+		"tags":     NewStringList([]string{"a", "b"}),
+		"group":    String("admins"),
+		"parent":   parent})
+	if IsError(obj) {
+		t.Fatal(obj)
+	}
+	indexer := obj.(traits.Indexer)
+	for field, expected := range map[string]ref.Value{
+		"name":     String("child"),
+		"priority": Int(0),
+		"user":     String(""),
+		"limit":    NullValue,
+	} {
+		if value := indexer.Get(String(field)); value.Equal(expected) != True {
+			t.Errorf("Got '%v' for field '%s', wanted '%v'", value, field, expected)
+		}
+	}
+	if name := indexer.Get(String("parent")).(traits.Indexer).Get(
+		String("name")); name != String("root") {
+		t.Errorf("Got parent name '%v', wanted 'root'", name)
+	}
+	grandparent := indexer.Get(String("parent")).(traits.Indexer).Get(String("parent"))
+	if grandparent.Type().TypeName() != "acme.dynamic.Rule" ||
+		grandparent.(traits.FieldTester).IsSet(String("name")) != False {
+		t.Errorf("Got '%v' for an unset message field, wanted an empty message",
+			grandparent)
+	}
+	tester := obj.(traits.FieldTester)
+	for field, expected := range map[string]ref.Value{
+		"name":     True,
+		"priority": False,
+		"tags":     True,
+		"user":     False,
+		"group":    True,
+		"limit":    False,
+	} {
+		if isSet := tester.IsSet(String(field)); isSet != expected {
+			t.Errorf("Got IsSet '%v' for field '%s', wanted '%v'", isSet, field, expected)
+		}
+	}
+	if subject := WhichOneof(obj, String("subject")); subject != String("group") {
+		t.Errorf("Got oneof field '%v', wanted 'group'", subject)
+	}
+	var names []string
+	for it := obj.(traits.Iterable).Iterator(); it.HasNext() == True; {
+		names = append(names, string(it.Next().(String)))
+	}
+	if expected := []string{"name", "tags", "group", "parent"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Got set fields %v, wanted %v", names, expected)
+	}
+
+	same := typeProvider.NewValue("acme.dynamic.Rule", map[string]ref.Value{
+		"name":   String("child"),
+		"tags":   NewStringList([]string{"a", "b"}),
+		"group":  String("admins"),
+		"parent": parent})
+	if obj.Equal(same) != True {
+		t.Error("Got unequal messages with the same fields")
+	}
+	if obj.Equal(parent) != False {
+		t.Error("Got equal messages with different fields")
+	}
+
+	for _, fields := range []map[string]ref.Value{
+		{"missing": String("x")},
+		{"priority": String("1")},
+		{"parent": String("root")},
+		{"tags": String("a")},
+	} {
+		if val := typeProvider.NewValue("acme.dynamic.Rule", fields); !IsError(val) {
+			t.Errorf("Got '%v' for fields %v, wanted an error", val, fields)
+		}
+	}
+}
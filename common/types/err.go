@@ -15,14 +15,37 @@
 package types
 
 import (
+	"errors"
 	"fmt"
-	"github.com/google/cel-go/common/types/ref"
 	"reflect"
+	"strings"
+
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/types/ref"
 )
 
 // Err type which extends the built-in go error and implements ref.Value.
 type Err struct {
 	error
+
+	// Format and arguments of the error message, retained for redaction.
+	format string
+	args   []interface{}
+
+	// Whether the error reports a missing map key or message field.
+	missingField bool
+
+	// Whether the error reports arguments which match no overload.
+	noSuchOverload bool
+
+	// Id of the expression which produced the error, if known.
+	exprId    int64
+	hasExprId bool
+
+	// Source location and text of the expression which produced the error,
+	// if known.
+	location common.Location
+	snippet  string
 }
 
 var (
@@ -31,7 +54,134 @@ var (
 )
 
 func NewErr(format string, args ...interface{}) *Err {
-	return &Err{fmt.Errorf(format, args...)}
+	return &Err{error: fmt.Errorf(format, args...), format: format, args: args}
+}
+
+// newMissingFieldErr returns an error for a map key or message field which is
+// not present in the value being accessed.
+func newMissingFieldErr(format string, args ...interface{}) *Err {
+	err := NewErr(format, args...)
+	err.missingField = true
+	return err
+}
+
+// IsMissingField returns whether the value is an error reporting that a map key
+// or message field is not present in the value being accessed.
+func IsMissingField(val ref.Value) bool {
+	err, isErr := val.(*Err)
+	return isErr && err.missingField
+}
+
+// NewNoSuchOverloadErr returns an error for a call whose operands match none of
+// the overloads of the function. The error is reported as a 'no such overload'
+// error by IsNoSuchOverload.
+func NewNoSuchOverloadErr() *Err {
+	err := NewErr("no such overload")
+	err.noSuchOverload = true
+	return err
+}
+
+// newUnsupportedOverloadErr returns an error for an operation which the
+// operand types do not support, e.g. adding a string to an int. The error is
+// reported as a 'no such overload' error by IsNoSuchOverload.
+func newUnsupportedOverloadErr() *Err {
+	err := NewErr("unsupported overload")
+	err.noSuchOverload = true
+	return err
+}
+
+// NewNoMatchingOverloadErr returns an error for a call whose arguments match
+// none of the overloads of the function, listing the signatures of the
+// candidate overloads, e.g. 'area_circle(circle)'. The error is reported as a
+// 'no such overload' error by IsNoSuchOverload.
+func NewNoMatchingOverloadErr(function string, args []ref.Value,
+	candidates []string) *Err {
+	argTypes := make([]string, len(args))
+	for i, arg := range args {
+		argTypes[i] = arg.Type().TypeName()
+	}
+	err := NewErr("no matching overload for '%s' applied to (%s), candidates: %s",
+		function, strings.Join(argTypes, ", "), strings.Join(candidates, ", "))
+	err.noSuchOverload = true
+	return err
+}
+
+// IsNoSuchOverload returns whether the value is an error reporting that the
+// operands of a function match none of its overloads.
+func IsNoSuchOverload(val ref.Value) bool {
+	err, isErr := val.(*Err)
+	return isErr && err.noSuchOverload
+}
+
+// ExprId returns the id of the expression which produced the error, or false
+// if the error has not been associated with an expression.
+func (e *Err) ExprId() (int64, bool) {
+	return e.exprId, e.hasExprId
+}
+
+// WithExprId returns a copy of the error associated with the given expression
+// id.
+func (e *Err) WithExprId(exprId int64) *Err {
+	located := *e
+	located.exprId, located.hasExprId = exprId, true
+	return &located
+}
+
+// Location returns the source location of the expression which produced the
+// error, or false if it is not known.
+func (e *Err) Location() (common.Location, bool) {
+	return e.location, e.location != nil
+}
+
+// Snippet returns the source text of the expression which produced the error,
+// e.g. 'a.emial', or the empty string if it is not known.
+func (e *Err) Snippet() string {
+	return e.snippet
+}
+
+// WithLocation returns a copy of the error associated with the source
+// location and text of the expression which produced it.
+func (e *Err) WithLocation(location common.Location, snippet string) *Err {
+	located := *e
+	located.location, located.snippet = location, snippet
+	return &located
+}
+
+// Detail returns the message of the error along with the location and text
+// of the expression which produced it, when they are known, e.g.
+// "no such field 'emial' at line 3, col 14 in 'a.emial'". Columns are
+// reported 1-based, as are those of parse and check errors.
+func (e *Err) Detail() string {
+	detail := e.error.Error()
+	if e.location != nil {
+		detail += fmt.Sprintf(" at line %d, col %d",
+			e.location.Line(), e.location.Column()+1)
+	}
+	if e.snippet != "" {
+		detail += fmt.Sprintf(" in '%s'", e.snippet)
+	}
+	return detail
+}
+
+// Redact returns a copy of the error whose message replaces the values it was
+// formatted with, such as strings and map keys, with the names of their types.
+//
+// Errors which were not created with NewErr may embed values in ways which
+// cannot be detected, so their message is replaced entirely.
+func (e *Err) Redact() *Err {
+	redacted := *e
+	// The text of the expression may contain literal values.
+	redacted.snippet = ""
+	if len(e.format) == 0 {
+		redacted.error = errors.New("redacted error")
+		return &redacted
+	}
+	redacted.args = make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		redacted.args[i] = redactArg(arg)
+	}
+	redacted.error = fmt.Errorf(e.format, redacted.args...)
+	return &redacted
 }
 
 func (e *Err) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
@@ -71,3 +221,24 @@ func IsError(elem interface{}) bool {
 	}
 	return false
 }
+
+// redactedArg formats as the name of the type of a redacted value regardless
+// of the formatting verb.
+type redactedArg string
+
+func (r redactedArg) Format(f fmt.State, verb rune) {
+	fmt.Fprintf(f, "<%s>", string(r))
+}
+
+func redactArg(arg interface{}) interface{} {
+	switch a := arg.(type) {
+	case ref.Type, redactedArg:
+		// Type names describe the schema rather than the data.
+		return a
+	case ref.Value:
+		return redactedArg(a.Type().TypeName())
+	case nil:
+		return redactedArg("nil")
+	}
+	return redactedArg(reflect.TypeOf(arg).String())
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/cel-go/common"
+)
+
+func TestErr_Redact(t *testing.T) {
+	mapValue := NewDynamicMap(map[string]string{"key": "value"})
+	for _, tst := range []struct {
+		err      *Err
+		redacted string
+	}{
+		{mapValue.Get(String("ssn")).(*Err), "no such key: '<string>'"},
+		{String("secret").ConvertToType(MapType).(*Err),
+			"type conversion error from 'string' to 'map'"},
+		{NewErr("index '%d' out of range in list size '%d'", Int(4), Int(2)),
+			"index '<int>' out of range in list size '<int>'"},
+		{NewErr("unexpected %v", nil), "unexpected <nil>"},
+		{&Err{error: errors.New("parse error: 'secret'")}, "redacted error"},
+	} {
+		redacted := tst.err.Redact()
+		if redacted.String() != tst.redacted {
+			t.Errorf("Got '%s', wanted '%s'", redacted, tst.redacted)
+		}
+		if redacted.Redact().String() != tst.redacted {
+			t.Errorf("Got '%s' when redacted twice, wanted '%s'",
+				redacted.Redact(), tst.redacted)
+		}
+	}
+}
+
+func TestErr_WithExprId(t *testing.T) {
+	err := NewErr("no such key: '%v'", String("ssn"))
+	if _, found := err.ExprId(); found {
+		t.Error("Got an expression id for an unattributed error")
+	}
+	located := err.WithExprId(3).Redact()
+	if id, found := located.ExprId(); !found || id != 3 {
+		t.Errorf("Got expression id %d, wanted 3", id)
+	}
+	if err.String() != "no such key: 'ssn'" {
+		t.Errorf("Got '%s', wanted the original error unchanged", err)
+	}
+}
+
+func TestErr_WithLocation(t *testing.T) {
+	err := NewErr("no such field '%s'", "emial")
+	if _, found := err.Location(); found || err.Detail() != "no such field 'emial'" {
+		t.Errorf("Got '%s' for an unlocated error", err.Detail())
+	}
+	located := err.WithLocation(common.NewLocation(3, 13), "a.emial")
+	if loc, found := located.Location(); !found || loc.Line() != 3 || loc.Column() != 13 {
+		t.Errorf("Got location %v, wanted 3:13", loc)
+	}
+	if detail := located.Detail(); detail != "no such field 'emial' at line 3, col 14 in 'a.emial'" {
+		t.Errorf("Got '%s', wanted the location and snippet", detail)
+	}
+	if located.String() != err.String() {
+		t.Errorf("Got '%s', wanted the message unchanged", located)
+	}
+	if detail := located.Redact().Detail(); detail != "no such field '<string>' at line 3, col 14" {
+		t.Errorf("Got '%s', wanted a redacted error without a snippet", detail)
+	}
+}
+
+func TestIsMissingField(t *testing.T) {
+	mapValue := NewDynamicMap(map[string]string{"key": "value"})
+	if !IsMissingField(mapValue.Get(String("ssn"))) {
+		t.Error("Expected a missing key to be a missing field error")
+	}
+	if !IsMissingField(mapValue.Get(String("ssn")).(*Err).WithExprId(1).Redact()) {
+		t.Error("Expected a located, redacted error to remain a missing field error")
+	}
+	if IsMissingField(NewErr("divide by zero")) || IsMissingField(NullValue) {
+		t.Error("Got a missing field error for an unrelated value")
+	}
+}
+
+func TestIsNoSuchOverload(t *testing.T) {
+	if !IsNoSuchOverload(Int(1).Add(String("1"))) {
+		t.Error("Expected an unsupported operand to be a no such overload error")
+	}
+	if !IsNoSuchOverload(NewNoSuchOverloadErr().WithExprId(1).Redact()) {
+		t.Error("Expected a located, redacted error to remain a no such overload error")
+	}
+	// Only errors created as no such overload errors are reported as such,
+	// regardless of their message.
+	if IsNoSuchOverload(NewErr("no such overload")) ||
+		IsNoSuchOverload(NewErr("divide by zero")) || IsNoSuchOverload(NullValue) {
+		t.Error("Got a no such overload error for an unrelated value")
+	}
+}
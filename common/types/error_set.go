@@ -0,0 +1,84 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/cel-go/common/types/ref"
+	"reflect"
+	"strings"
+)
+
+// ErrorSet type which aggregates the errors encountered while computing a
+// value, corresponding to the ErrorSet of the CEL ExprValue.
+//
+// An ErrorSet has the ErrType, so IsError reports true for it.
+type ErrorSet []*Err
+
+// MergeErrors combines the error values, whether Err or ErrorSet, into a
+// single value. Non-error values are ignored.
+//
+// When the values contain a single error, the Err is returned as-is;
+// otherwise the result is an ErrorSet containing each Err in order. The
+// result is nil when none of the values is an error.
+func MergeErrors(vals ...ref.Value) ref.Value {
+	var errs ErrorSet
+	for _, val := range vals {
+		switch v := val.(type) {
+		case *Err:
+			errs = append(errs, v)
+		case ErrorSet:
+			errs = append(errs, v...)
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return errs
+}
+
+func (e ErrorSet) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	return nil, errors.New(e.String())
+}
+
+func (e ErrorSet) ConvertToType(typeVal ref.Type) ref.Value {
+	// Errors are not convertible to other representations.
+	return e
+}
+
+func (e ErrorSet) Equal(other ref.Value) ref.Value {
+	// An error cannot be equal to any other value, so it returns itself.
+	return e
+}
+
+func (e ErrorSet) String() string {
+	msgs := make([]string, len(e), len(e))
+	for i, err := range e {
+		msgs[i] = err.String()
+	}
+	return fmt.Sprintf("%d errors: %s", len(e), strings.Join(msgs, "; "))
+}
+
+func (e ErrorSet) Type() ref.Type {
+	return ErrType
+}
+
+func (e ErrorSet) Value() interface{} {
+	return []*Err(e)
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+)
+
+func TestMergeErrors(t *testing.T) {
+	first := NewErr("first")
+	second := NewErr("second")
+	third := NewErr("third")
+	if MergeErrors(True, Unknown{1}) != nil {
+		t.Error("Got an error when merging non-error values")
+	}
+	if MergeErrors(True, first) != first {
+		t.Error("Expected a single error to be returned as-is")
+	}
+	merged := MergeErrors(MergeErrors(first, second), String("x"), third)
+	if !IsError(merged) {
+		t.Fatalf("Got '%v', wanted an error", merged)
+	}
+	if merged.(ErrorSet).String() != "3 errors: first; second; third" {
+		t.Errorf("Got '%v', wanted all three errors in order", merged)
+	}
+	if _, err := merged.ConvertToNative(nil); err == nil {
+		t.Error("Expected an error converting an ErrorSet to native")
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/cel-go/common/types/ref"
+)
+
+var (
+	// GlobType is the type of a compiled glob pattern.
+	GlobType = NewTypeValue("glob")
+)
+
+// Glob is a compiled glob pattern in which:
+//
+//     *   matches any sequence of characters other than '/'
+//     ?   matches any single character other than '/'
+//     **  matches any sequence of characters, including '/'
+//
+// A '**/' path element also matches no elements at all, so that 'a/**/b'
+// matches 'a/b', and a backslash matches the following character literally.
+// Since '/' is the only separator, '*' matches across the labels of a
+// hostname: '*.example.com' matches 'a.b.example.com'.
+//
+// Glob values are not created by expressions. The interpreter compiles the
+// constant patterns of 'matchesGlob' calls when a program is planned, so
+// that the patterns are not recompiled on each evaluation.
+type Glob struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// NewGlob compiles a glob pattern.
+func NewGlob(pattern string) (*Glob, error) {
+	var re strings.Builder
+	re.WriteString(`^(?s:`)
+	for i := 0; i < len(pattern); {
+		c, size := utf8.DecodeRuneInString(pattern[i:])
+		i += size
+		switch c {
+		case '*':
+			if i < len(pattern) && pattern[i] == '*' {
+				i++
+				// A '**/' element may match no elements at all.
+				if (i == 2 || pattern[i-3] == '/') &&
+					i < len(pattern) && pattern[i] == '/' {
+					i++
+					re.WriteString(`(?:.*/)?`)
+				} else {
+					re.WriteString(`.*`)
+				}
+			} else {
+				re.WriteString(`[^/]*`)
+			}
+		case '?':
+			re.WriteString(`[^/]`)
+		case '\\':
+			if i == len(pattern) {
+				return nil, fmt.Errorf("invalid glob pattern '%s': trailing backslash", pattern)
+			}
+			escaped, size := utf8.DecodeRuneInString(pattern[i:])
+			i += size
+			re.WriteString(regexp.QuoteMeta(string(escaped)))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString(`)$`)
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, err
+	}
+	return &Glob{pattern: pattern, re: compiled}, nil
+}
+
+// Match returns whether the string value matches the pattern.
+func (g *Glob) Match(val ref.Value) ref.Value {
+	s, ok := val.(String)
+	if !ok {
+		return NewNoSuchOverloadErr()
+	}
+	return Bool(g.re.MatchString(string(s)))
+}
+
+func (g *Glob) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	if typeDesc.Kind() == reflect.String {
+		return g.pattern, nil
+	}
+	return nil, fmt.Errorf("type conversion error from glob to '%v'", typeDesc)
+}
+
+func (g *Glob) ConvertToType(typeVal ref.Type) ref.Value {
+	switch typeVal {
+	case StringType:
+		return String(g.pattern)
+	case GlobType:
+		return g
+	case TypeType:
+		return GlobType
+	}
+	return NewErr("type conversion error from '%s' to '%s'", GlobType, typeVal)
+}
+
+func (g *Glob) Equal(other ref.Value) ref.Value {
+	o, ok := other.(*Glob)
+	return Bool(ok && g.pattern == o.pattern)
+}
+
+func (g *Glob) Type() ref.Type {
+	return GlobType
+}
+
+func (g *Glob) Value() interface{} {
+	return g.pattern
+}
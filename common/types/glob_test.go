@@ -0,0 +1,73 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+)
+
+func TestGlob_Match(t *testing.T) {
+	for _, tst := range []struct {
+		pattern string
+		s       string
+		matched bool
+	}{
+		{pattern: "/api/*", s: "/api/users", matched: true},
+		{pattern: "/api/*", s: "/api/users/1", matched: false},
+		{pattern: "/api/**", s: "/api/users/1", matched: true},
+		{pattern: "/api/**/edit", s: "/api/edit", matched: true},
+		{pattern: "/api/**/edit", s: "/api/users/1/edit", matched: true},
+		{pattern: "/api/**/edit", s: "/api/users/1/view", matched: false},
+		{pattern: "**/*.go", s: "main.go", matched: true},
+		{pattern: "**/*.go", s: "a/b/main.go", matched: true},
+		{pattern: "file-?.txt", s: "file-1.txt", matched: true},
+		{pattern: "file-?.txt", s: "file-10.txt", matched: false},
+		{pattern: "*.example.com", s: "api.example.com", matched: true},
+		{pattern: "*.example.com", s: "example.com", matched: false},
+		{pattern: "*.example.com", s: "apiXexample.com", matched: false},
+		{pattern: "(a|b)+[c]", s: "(a|b)+[c]", matched: true},
+		{pattern: "(a|b)+[c]", s: "aac", matched: false},
+		{pattern: `literal\*`, s: "literal*", matched: true},
+		{pattern: `literal\*`, s: "literals", matched: false},
+		{pattern: "a*", s: "a\nb", matched: true},
+		{pattern: "café/*", s: "café/x", matched: true},
+		{pattern: "café/*", s: "cafe/x", matched: false},
+		{pattern: "?/x", s: "é/x", matched: true},
+		{pattern: `\é*`, s: "éclair", matched: true},
+		{pattern: `日本/\語`, s: "日本/語", matched: true},
+	} {
+		glob, err := NewGlob(tst.pattern)
+		if err != nil {
+			t.Fatalf("%s: %v", tst.pattern, err)
+		}
+		if matched := glob.Match(String(tst.s)); matched != Bool(tst.matched) {
+			t.Errorf("'%s'.matchesGlob('%s'): got %v, wanted %t",
+				tst.s, tst.pattern, matched, tst.matched)
+		}
+	}
+}
+
+func TestGlob_Errors(t *testing.T) {
+	if _, err := NewGlob(`trailing\`); err == nil {
+		t.Error("Got nil error for a trailing backslash")
+	}
+	glob, _ := NewGlob("*")
+	if !IsError(glob.Match(Int(1))) {
+		t.Error("Got match of an int, wanted error")
+	}
+	if glob.ConvertToType(StringType) != String("*") {
+		t.Errorf("Got %v, wanted '*'", glob.ConvertToType(StringType))
+	}
+}
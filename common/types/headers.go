@@ -0,0 +1,183 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+var (
+	// HeaderMapType is the type of a HeaderMap.
+	HeaderMapType = NewTypeValue("http.Headers",
+		traits.ContainerType,
+		traits.FieldTesterType,
+		traits.IndexerType,
+		traits.IterableType,
+		traits.ReceiverType,
+		traits.SizerType)
+)
+
+// HeaderMap is a multimap of HTTP header fields, whose names are compared
+// case-insensitively and which may have several values each.
+//
+// The value of a field, as selected by 'headers.host' or indexed by
+// headers['Host'], is the combination of its values separated by commas, as
+// described by RFC 7230, and the names of the fields are iterated in lower
+// case. Fields are tested with 'in' and has(), and the receiver functions
+// declared by checker#HeadersDeclarations access their values:
+//
+//     headers.get('Accept')      // the combined value, or '' when absent
+//     headers.getAll('Accept')   // the list of values
+//     headers.contains('Accept') // whether the field is present
+type HeaderMap struct {
+	fields map[string][]string
+	names  []string
+}
+
+// NewHeaderMap creates a HeaderMap from the values of the fields by name, as
+// held by an http.Header.
+func NewHeaderMap(headers map[string][]string) *HeaderMap {
+	// The values of the fields whose names differ only in case are combined
+	// in the order of the names.
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	m := &HeaderMap{fields: make(map[string][]string, len(headers))}
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if _, found := m.fields[key]; !found {
+			m.names = append(m.names, key)
+		}
+		m.fields[key] = append(m.fields[key], headers[name]...)
+	}
+	sort.Strings(m.names)
+	return m
+}
+
+// Contains returns whether the map holds a field of the given name.
+func (m *HeaderMap) Contains(name ref.Value) ref.Value {
+	values, err := m.values(name)
+	if err != nil {
+		return err
+	}
+	return Bool(values != nil)
+}
+
+func (m *HeaderMap) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	if typeDesc == reflect.TypeOf(m.fields) {
+		return m.fields, nil
+	}
+	return nil, fmt.Errorf("type conversion error from '%s' to '%v'",
+		HeaderMapType.TypeName(), typeDesc)
+}
+
+func (m *HeaderMap) ConvertToType(typeVal ref.Type) ref.Value {
+	switch typeVal {
+	case HeaderMapType:
+		return m
+	case TypeType:
+		return HeaderMapType
+	}
+	return NewErr("type conversion error from '%s' to '%s'",
+		HeaderMapType.TypeName(), typeVal)
+}
+
+// Equal returns whether the other value is a HeaderMap with the same fields
+// and values, in the same order.
+func (m *HeaderMap) Equal(other ref.Value) ref.Value {
+	o, isHeaders := other.(*HeaderMap)
+	return Bool(isHeaders && reflect.DeepEqual(m.fields, o.fields))
+}
+
+// Get returns the combined value of the named field, or an error when the
+// field is absent.
+func (m *HeaderMap) Get(name ref.Value) ref.Value {
+	values, err := m.values(name)
+	if err != nil {
+		return err
+	}
+	if values == nil {
+		return NewErr("no such key: '%s'", name)
+	}
+	return String(strings.Join(values, ","))
+}
+
+// IsSet returns whether the map holds a field of the given name.
+func (m *HeaderMap) IsSet(name ref.Value) ref.Value {
+	return m.Contains(name)
+}
+
+// Iterator iterates over the names of the fields, in lower case.
+func (m *HeaderMap) Iterator() traits.Iterator {
+	return NewStringList(m.names).Iterator()
+}
+
+// Receive dispatches the get, getAll, and contains functions.
+func (m *HeaderMap) Receive(function string, overload string, args []ref.Value) ref.Value {
+	if len(args) != 1 {
+		return NewNoSuchOverloadErr()
+	}
+	switch function {
+	case overloads.HeadersGet:
+		values, err := m.values(args[0])
+		if err != nil {
+			return err
+		}
+		return String(strings.Join(values, ","))
+	case overloads.HeadersGetAll:
+		values, err := m.values(args[0])
+		if err != nil {
+			return err
+		}
+		if values == nil {
+			values = []string{}
+		}
+		return NewStringList(values)
+	case overloads.HeadersContains:
+		return m.Contains(args[0])
+	}
+	return NewNoSuchOverloadErr()
+}
+
+func (m *HeaderMap) Size() ref.Value {
+	return Int(len(m.names))
+}
+
+func (m *HeaderMap) Type() ref.Type {
+	return HeaderMapType
+}
+
+// Value returns the values of the fields by their lower case names.
+func (m *HeaderMap) Value() interface{} {
+	return m.fields
+}
+
+// values returns the values of the named field, or nil when it is absent.
+func (m *HeaderMap) values(name ref.Value) ([]string, ref.Value) {
+	s, isString := name.(String)
+	if !isString {
+		return nil, NewNoSuchOverloadErr()
+	}
+	return m.fields[strings.ToLower(string(s))], nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+func TestHeaderMap(t *testing.T) {
+	m := NewHeaderMap(map[string][]string{
+		"Accept":       {"text/html", "application/json"},
+		"accept":       {"*/*"},
+		"Content-Type": {"text/plain"}})
+	if m.Size() != Int(2) {
+		t.Errorf("Got size %v, wanted 2", m.Size())
+	}
+	if got := m.Get(String("ACCEPT")); got != String("text/html,application/json,*/*") {
+		t.Errorf("Got combined value '%v'", got)
+	}
+	if got := m.Get(String("Host")); !IsError(got) {
+		t.Errorf("Got '%v' for an absent field, wanted error", got)
+	}
+	if m.Contains(String("content-type")) != True || m.IsSet(String("host")) != False {
+		t.Error("Got the wrong presence of fields")
+	}
+	for _, tst := range []struct {
+		function string
+		name     string
+		expected ref.Value
+	}{
+		{function: overloads.HeadersGet, name: "Content-Type", expected: String("text/plain")},
+		{function: overloads.HeadersGet, name: "Host", expected: String("")},
+		{function: overloads.HeadersGetAll, name: "accept",
+			expected: NewStringList([]string{"text/html", "application/json", "*/*"})},
+		{function: overloads.HeadersGetAll, name: "Host", expected: NewStringList([]string{})},
+		{function: overloads.HeadersContains, name: "CONTENT-TYPE", expected: True},
+	} {
+		got := m.Receive(tst.function, "", []ref.Value{String(tst.name)})
+		if got.Equal(tst.expected) != True {
+			t.Errorf("%s('%s'): got '%v', wanted '%v'", tst.function, tst.name, got, tst.expected)
+		}
+	}
+	var names []string
+	for it := m.Iterator(); it.HasNext() == True; {
+		names = append(names, string(it.Next().(String)))
+	}
+	if !reflect.DeepEqual(names, []string{"accept", "content-type"}) {
+		t.Errorf("Got names %v", names)
+	}
+}
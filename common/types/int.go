@@ -46,14 +46,14 @@ var (
 
 func (i Int) Add(other ref.Value) ref.Value {
 	if IntType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return i + other.(Int)
 }
 
 func (i Int) Compare(other ref.Value) ref.Value {
 	if IntType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	if i < other.(Int) {
 		return IntNegOne
@@ -66,6 +66,8 @@ func (i Int) Compare(other ref.Value) ref.Value {
 
 func (i Int) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
 	switch typeDesc.Kind() {
+	case reflect.Int:
+		return int(i), nil
 	case reflect.Int32:
 		return int32(i), nil
 	case reflect.Int64:
@@ -110,7 +112,7 @@ func (i Int) ConvertToType(typeVal ref.Type) ref.Value {
 
 func (i Int) Divide(other ref.Value) ref.Value {
 	if IntType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	otherInt := other.(Int)
 	if otherInt == IntZero {
@@ -125,7 +127,7 @@ func (i Int) Equal(other ref.Value) ref.Value {
 
 func (i Int) Modulo(other ref.Value) ref.Value {
 	if IntType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	otherInt := other.(Int)
 	if otherInt == IntZero {
@@ -136,7 +138,7 @@ func (i Int) Modulo(other ref.Value) ref.Value {
 
 func (i Int) Multiply(other ref.Value) ref.Value {
 	if IntType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return i * other.(Int)
 }
@@ -147,7 +149,7 @@ func (i Int) Negate() ref.Value {
 
 func (i Int) Subtract(subtrahend ref.Value) ref.Value {
 	if IntType != subtrahend.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return i - subtrahend.(Int)
 }
@@ -54,6 +54,15 @@ func TestInt_ConvertToNative_Error(t *testing.T) {
 	}
 }
 
+func TestInt_ConvertToNative_Int(t *testing.T) {
+	val, err := Int(-42).ConvertToNative(reflect.TypeOf(0))
+	if err != nil {
+		t.Error(err)
+	} else if val.(int) != -42 {
+		t.Errorf("Got '%v', expected -42", val)
+	}
+}
+
 func TestInt_ConvertToNative_Int32(t *testing.T) {
 	val, err := Int(20050).ConvertToNative(reflect.TypeOf(int32(0)))
 	if err != nil {
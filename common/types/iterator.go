@@ -38,11 +38,11 @@ func (it *baseIterator) ConvertToNative(typeDesc reflect.Type) (interface{}, err
 }
 
 func (it *baseIterator) ConvertToType(typeVal ref.Type) ref.Value {
-	return NewErr("no such overload")
+	return NewNoSuchOverloadErr()
 }
 
 func (it *baseIterator) Equal(other ref.Value) ref.Value {
-	return NewErr("no such overload")
+	return NewNoSuchOverloadErr()
 }
 
 func (it *baseIterator) Type() ref.Type {
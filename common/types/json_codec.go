@@ -0,0 +1,217 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// ValueToJSON converts a value to the values used by encoding/json, so that it
+// may be encoded with json.Marshal without the loss of precision incurred by
+// a conversion to a google.protobuf.Value:
+//
+//   - null, bools, and strings are converted to their Go equivalents.
+//   - ints and uints are converted to int64 and uint64 values, which are
+//     encoded exactly, and doubles to float64 values. Infinite and NaN doubles
+//     may not be converted.
+//   - bytes are converted to base64 strings, and timestamps and durations to
+//     the strings of their proto3 JSON mappings, e.g. '2018-01-02T03:04:05Z'
+//     and '1.5s'.
+//   - lists are converted to []interface{} values and maps to
+//     map[string]interface{} values. Map keys must be strings, bools, ints, or
+//     uints, which are converted to strings.
+//   - messages are converted to json.RawMessage values of their proto3 JSON
+//     mappings.
+//
+// Errors and unknowns may not be converted.
+func ValueToJSON(value ref.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case Null:
+		return nil, nil
+	case Bool:
+		return bool(v), nil
+	case Int:
+		return int64(v), nil
+	case Uint:
+		return uint64(v), nil
+	case Double:
+		if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {
+			return nil, fmt.Errorf("unsupported JSON value '%v'", v)
+		}
+		return float64(v), nil
+	case String:
+		return string(v), nil
+	case Bytes:
+		return base64.StdEncoding.EncodeToString(v), nil
+	case Timestamp:
+		return protoJSONString(v.Timestamp)
+	case Duration:
+		return protoJSONString(v.Duration)
+	case traits.Lister:
+		size, _ := v.Size().(Int)
+		elems := make([]interface{}, size)
+		for i := Int(0); i < size; i++ {
+			elem, err := ValueToJSON(v.Get(i))
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return elems, nil
+	case traits.Mapper:
+		entries := make(map[string]interface{})
+		for it := v.Iterator(); it.HasNext() == True; {
+			key := it.Next()
+			name, err := jsonKey(key)
+			if err != nil {
+				return nil, err
+			}
+			entry, err := ValueToJSON(v.Get(key))
+			if err != nil {
+				return nil, err
+			}
+			entries[name] = entry
+		}
+		return entries, nil
+	}
+	if msg, isMsg := value.Value().(proto.Message); isMsg {
+		return protoJSON(msg)
+	}
+	return nil, fmt.Errorf("unsupported JSON value of type '%s'",
+		value.Type().TypeName())
+}
+
+// protoJSON returns the proto3 JSON mapping of a message.
+func protoJSON(msg proto.Message) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	if err := (&jsonpb.Marshaler{}).Marshal(&buf, msg); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(buf.Bytes()), nil
+}
+
+// protoJSONString returns the proto3 JSON mapping of a message which maps to a
+// string, such as a google.protobuf.Timestamp.
+func protoJSONString(msg proto.Message) (interface{}, error) {
+	data, err := protoJSON(msg)
+	if err != nil {
+		return nil, err
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return nil, err
+	}
+	return str, nil
+}
+
+// jsonKey returns the name of a map key within a JSON object.
+func jsonKey(key ref.Value) (string, error) {
+	switch k := key.(type) {
+	case String:
+		return string(k), nil
+	case Bool:
+		return strconv.FormatBool(bool(k)), nil
+	case Int:
+		return strconv.FormatInt(int64(k), 10), nil
+	case Uint:
+		return strconv.FormatUint(uint64(k), 10), nil
+	}
+	return "", fmt.Errorf("unsupported JSON key of type '%s'", key.Type().TypeName())
+}
+
+// JSONToValue adapts a value decoded by encoding/json to a ref.Value. Numbers
+// decoded as json.Number values, as by a json.Decoder with UseNumber, are
+// adapted to ints when they are integers within the range of an int64, to
+// uints when they are greater integers within the range of a uint64, and to
+// doubles otherwise, so that large integers are preserved exactly. Numbers
+// decoded as float64 values are adapted to doubles.
+//
+// A json.RawMessage is decoded with json.Number values. Lists and maps are
+// adapted eagerly, and an error is returned for a value which is not JSON.
+func JSONToValue(value interface{}) ref.Value {
+	switch v := value.(type) {
+	case nil:
+		return NullValue
+	case bool:
+		return Bool(v)
+	case float64:
+		return Double(v)
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return Int(i)
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return Uint(u)
+		}
+		d, err := v.Float64()
+		if err != nil {
+			return NewErr("invalid JSON number '%s'", v)
+		}
+		return Double(d)
+	case string:
+		return String(v)
+	case []interface{}:
+		elems := make([]ref.Value, len(v))
+		for i, elem := range v {
+			elems[i] = JSONToValue(elem)
+			if IsError(elems[i]) {
+				return elems[i]
+			}
+		}
+		return NewValueList(elems)
+	case map[string]interface{}:
+		entries := make(map[string]ref.Value, len(v))
+		for name, entry := range v {
+			entries[name] = JSONToValue(entry)
+			if IsError(entries[name]) {
+				return entries[name]
+			}
+		}
+		return NewDynamicMap(entries)
+	case json.RawMessage:
+		decoder := json.NewDecoder(bytes.NewReader(v))
+		decoder.UseNumber()
+		var decoded interface{}
+		if err := decoder.Decode(&decoded); err != nil {
+			return NewErr("invalid JSON: %v", err)
+		}
+		return JSONToValue(decoded)
+	}
+	return NewErr("unsupported JSON value '%v'", value)
+}
+
+// EncodeJSON encodes a value as JSON, as converted by ValueToJSON.
+func EncodeJSON(value ref.Value) ([]byte, error) {
+	converted, err := ValueToJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(converted)
+}
+
+// DecodeJSON decodes JSON to a value, as adapted by JSONToValue.
+func DecodeJSON(data []byte) ref.Value {
+	return JSONToValue(json.RawMessage(data))
+}
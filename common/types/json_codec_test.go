@@ -0,0 +1,103 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	dpb "github.com/golang/protobuf/ptypes/duration"
+	tpb "github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/test"
+)
+
+func TestEncodeJSON(t *testing.T) {
+	for _, tst := range []struct {
+		value    ref.Value
+		expected string
+	}{
+		{value: NullValue, expected: `null`},
+		{value: Int(math.MaxInt64), expected: `9223372036854775807`},
+		{value: Uint(math.MaxUint64), expected: `18446744073709551615`},
+		{value: Double(1.5), expected: `1.5`},
+		{value: Bytes("abc"), expected: `"YWJj"`},
+		{value: Timestamp{&tpb.Timestamp{Seconds: 1514862245}},
+			expected: `"2018-01-02T03:04:05Z"`},
+		{value: Duration{&dpb.Duration{Seconds: 90, Nanos: 500000000}},
+			expected: `"90.500s"`},
+		{value: NewValueList([]ref.Value{True, String("a"), NullValue}),
+			expected: `[true,"a",null]`},
+		{value: NewDynamicMap(map[int64][]int64{1: {2}}), expected: `{"1":[2]}`},
+		{value: NewObject(&test.TestAllTypes{SingleInt64: 3}),
+			expected: `{"singleInt64":"3"}`},
+	} {
+		data, err := EncodeJSON(tst.value)
+		if err != nil {
+			t.Errorf("%v: %v", tst.value, err)
+		} else if string(data) != tst.expected {
+			t.Errorf("Got '%s', wanted '%s'", data, tst.expected)
+		}
+	}
+	for _, value := range []ref.Value{
+		Double(math.Inf(1)),
+		NewErr("failed"),
+		Unknown{1},
+		NewDynamicMap(map[float64]int{1.5: 1}),
+	} {
+		if _, err := EncodeJSON(value); err == nil {
+			t.Errorf("Got JSON for '%v', wanted an error", value)
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	value := DecodeJSON([]byte(
+		`{"id": 9223372036854775807, "big": 18446744073709551615,
+		  "ratio": 0.5, "tags": ["a", null, false]}`))
+	if IsError(value) {
+		t.Fatal(value)
+	}
+	m := value.(traits.Mapper)
+	for key, expected := range map[string]ref.Value{
+		"id":    Int(math.MaxInt64),
+		"big":   Uint(math.MaxUint64),
+		"ratio": Double(0.5),
+		"tags": NewValueList([]ref.Value{
+			String("a"), NullValue, False}),
+	} {
+		if actual := m.Get(String(key)); actual.Equal(expected) != True {
+			t.Errorf("Got '%v' for '%s', wanted '%v'", actual, key, expected)
+		}
+	}
+	// Values decoded without json.Number adapt numbers to doubles.
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`[1]`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if elem := JSONToValue(decoded).(traits.Lister).Get(Int(0)); elem != Double(1) {
+		t.Errorf("Got '%v', wanted 1.0", elem)
+	}
+	if value := DecodeJSON([]byte(`{`)); !IsError(value) {
+		t.Errorf("Got '%v' for invalid JSON, wanted an error", value)
+	}
+	// Values round trip through JSON.
+	data, err := EncodeJSON(DecodeJSON([]byte(`{"a":[1,2.5,"x",true,null]}`)))
+	if err != nil || string(data) != `{"a":[1,2.5,"x",true,null]}` {
+		t.Errorf("Got '%s' (%v) from a round trip", data, err)
+	}
+}
@@ -40,7 +40,7 @@ func NewJsonList(l *structpb.ListValue) traits.Lister {
 
 func (l *jsonListValue) Add(other ref.Value) ref.Value {
 	if other.Type() != ListType {
-		return NewErr("no such overload")
+		return NewNoSuchOverloadErr()
 	}
 	switch other.(type) {
 	case *jsonListValue:
@@ -127,7 +127,7 @@ func (m *jsonStruct) Get(key ref.Value) ref.Value {
 	fields := m.Struct.GetFields()
 	value, found := fields[string(key.(String))]
 	if !found {
-		return NewErr("no such key: '%v'", key)
+		return newMissingFieldErr("no such key: '%v'", key)
 	}
 	return NativeToValue(value)
 }
@@ -0,0 +1,62 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/golang/protobuf/ptypes"
+	anypb "github.com/golang/protobuf/ptypes/any"
+)
+
+// UnpackLimits bound the resources consumed when adapting untrusted protocol
+// buffer messages to CEL values, such as messages containing Any values
+// which nest further Any values.
+type UnpackLimits struct {
+	// AllowedAnyTypes lists the qualified names of the message types which
+	// may be unpacked from google.protobuf.Any values. All types may be
+	// unpacked when the list is empty.
+	AllowedAnyTypes []string
+
+	// MaxDepth limits the nesting depth of the messages which may be
+	// traversed, where a top-level message has a depth of one. The depth is
+	// not limited when zero.
+	MaxDepth int
+}
+
+// checkAny returns an error if the type of the Any value may not be unpacked.
+// The type is checked prior to unmarshalling the value.
+func (l *UnpackLimits) checkAny(val *anypb.Any) *Err {
+	if l == nil || len(l.AllowedAnyTypes) == 0 {
+		return nil
+	}
+	typeName, err := ptypes.AnyMessageName(val)
+	if err != nil {
+		return &Err{error: err}
+	}
+	for _, allowed := range l.AllowedAnyTypes {
+		if allowed == typeName {
+			return nil
+		}
+	}
+	return NewErr("type '%s' may not be unpacked from any", typeName)
+}
+
+// checkDepth returns an error if a message at the given depth may not be
+// traversed.
+func (l *UnpackLimits) checkDepth(depth int) *Err {
+	if l == nil || l.MaxDepth == 0 || depth <= l.MaxDepth {
+		return nil
+	}
+	return NewErr("message depth exceeds the limit of %d", l.MaxDepth)
+}
@@ -17,6 +17,7 @@ package types
 import (
 	"fmt"
 	"reflect"
+	"sync"
 
 	"github.com/golang/protobuf/ptypes/struct"
 	"github.com/google/cel-go/common/types/ref"
@@ -36,8 +37,11 @@ var (
 // NewDynamicList returns a traits.Lister with heterogenous elements.
 // value should be an array of "native" types, i.e. any type that
 // NativeToValue() can convert to a ref.Value.
+//
+// Elements are adapted on first access and cached for the lifetime of the
+// list, so the native array must not be modified while the list is in use.
 func NewDynamicList(value interface{}) traits.Lister {
-	return &baseList{value, reflect.ValueOf(value)}
+	return &baseList{value: value, refValue: reflect.ValueOf(value)}
 }
 
 // NewStringList returns a traits.Lister containing only strings.
@@ -56,14 +60,22 @@ func NewValueList(elems []ref.Value) traits.Lister {
 
 // baseList points to a list containing elements of any type.
 // value is an array of native values, and refValue is its reflection object.
+// elems caches the adapted ref.Value form of each element.
 type baseList struct {
 	value    interface{}
 	refValue reflect.Value
+
+	mutex sync.Mutex
+	elems []ref.Value
+
+	// Limits on the messages adapted from the elements of the list.
+	limits *UnpackLimits
+	depth  int
 }
 
 func (l *baseList) Add(other ref.Value) ref.Value {
 	if other.Type() != ListType {
-		return NewErr("no such overload")
+		return NewNoSuchOverloadErr()
 	}
 	if l.Size() == IntZero {
 		return other
@@ -172,8 +184,17 @@ func (l *baseList) Get(index ref.Value) ref.Value {
 	if i < 0 || i >= l.Size().(Int) {
 		return NewErr("index '%d' out of range in list size '%d'", i, l.Size())
 	}
-	elem := l.refValue.Index(int(i)).Interface()
-	return NativeToValue(elem)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.elems == nil {
+		l.elems = make([]ref.Value, l.refValue.Len())
+	}
+	if elem := l.elems[i]; elem != nil {
+		return elem
+	}
+	elem := nativeToValue(l.refValue.Index(int(i)).Interface(), l.limits, l.depth)
+	l.elems[i] = elem
+	return elem
 }
 
 func (l *baseList) Iterator() traits.Iterator {
@@ -205,7 +226,7 @@ type concatList struct {
 
 func (l *concatList) Add(other ref.Value) ref.Value {
 	if other.Type() != ListType {
-		return NewErr("no such overload")
+		return NewNoSuchOverloadErr()
 	}
 	if l.Size() == IntZero {
 		return other
@@ -312,7 +333,7 @@ type stringList struct {
 
 func (l *stringList) Add(other ref.Value) ref.Value {
 	if other.Type() != ListType {
-		return NewErr("no such overload")
+		return NewNoSuchOverloadErr()
 	}
 	if l.Size() == IntZero {
 		return other
@@ -383,7 +404,7 @@ type valueList struct {
 
 func (l *valueList) Add(other ref.Value) ref.Value {
 	if other.Type() != ListType {
-		return NewErr("no such overload")
+		return NewNoSuchOverloadErr()
 	}
 	return &concatList{
 		prevList: l,
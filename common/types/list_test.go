@@ -147,6 +147,28 @@ func TestBaseList_NestedList(t *testing.T) {
 	}
 }
 
+func TestBaseList_Get_Adapted(t *testing.T) {
+	type level uint8
+	list := NewDynamicList([]interface{}{
+		int8(-1), int16(2), level(3), float32(0.5), []level{4}})
+	for i, expected := range []ref.Value{Int(-1), Int(2), Uint(3), Double(0.5)} {
+		if elem := list.Get(Int(i)); elem != expected {
+			t.Errorf("Got '%v', expected '%v'", elem, expected)
+		}
+	}
+	nested := list.Get(Int(4))
+	if nested.(traits.Indexer).Get(IntZero) != Uint(4) {
+		t.Errorf("Got '%v', expected [4u]", nested)
+	}
+	if list.Get(Int(4)) != nested {
+		t.Error("Nested list was not cached")
+	}
+	durations := NewDynamicList([]*dpb.Duration{{Seconds: 5}})
+	if durations.Get(IntZero).Type() != DurationType {
+		t.Errorf("Got '%v', expected a duration", durations.Get(IntZero))
+	}
+}
+
 func TestBaseList_Size(t *testing.T) {
 	listUint32 := []uint32{1, 2}
 	nestedUint32 := NewDynamicList([]interface{}{listUint32})
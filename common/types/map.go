@@ -20,16 +20,29 @@ import (
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/common/types/traits"
 	"reflect"
+	"sync"
 )
 
+// baseMap points to a map with keys and values of any type. elems caches the
+// adapted ref.Value form of each value by its native key.
 type baseMap struct {
 	value    interface{}
 	refValue reflect.Value
+
+	mutex sync.Mutex
+	elems map[interface{}]ref.Value
+
+	// Limits on the messages adapted from the values of the map.
+	limits *UnpackLimits
+	depth  int
 }
 
 // NewDynamicMap returns a traits.Mapper value with dynamic key, value pairs.
+//
+// Values are adapted on first access and cached for the lifetime of the map,
+// so the native map must not be modified while the map is in use.
 func NewDynamicMap(value interface{}) traits.Mapper {
-	return &baseMap{value, reflect.ValueOf(value)}
+	return &baseMap{value: value, refValue: reflect.ValueOf(value)}
 }
 
 var (
@@ -139,17 +152,33 @@ func (m *baseMap) Get(key ref.Value) ref.Value {
 	thisKeyType := m.refValue.Type().Key()
 	nativeKey, err := key.ConvertToNative(thisKeyType)
 	if err != nil {
-		return &Err{err}
+		return &Err{error: err}
 	}
 	nativeKeyVal := reflect.ValueOf(nativeKey)
+	if nativeKeyVal.Kind() == thisKeyType.Kind() &&
+		nativeKeyVal.Type().ConvertibleTo(thisKeyType) {
+		// Named key types, e.g. 'type Role string'.
+		nativeKeyVal = nativeKeyVal.Convert(thisKeyType)
+		nativeKey = nativeKeyVal.Interface()
+	}
 	if !nativeKeyVal.Type().AssignableTo(thisKeyType) {
 		return NewErr("no such key: '%v'", nativeKey)
 	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if elem, found := m.elems[nativeKey]; found {
+		return elem
+	}
 	value := m.refValue.MapIndex(nativeKeyVal)
 	if !value.IsValid() {
-		return NewErr("no such key: '%v'", nativeKey)
+		return newMissingFieldErr("no such key: '%v'", nativeKey)
+	}
+	if m.elems == nil {
+		m.elems = make(map[interface{}]ref.Value)
 	}
-	return NativeToValue(value.Interface())
+	elem := nativeToValue(value.Interface(), m.limits, m.depth)
+	m.elems[nativeKey] = elem
+	return elem
 }
 
 func (m *baseMap) Iterator() traits.Iterator {
@@ -109,6 +109,25 @@ func TestBaseMap_Get(t *testing.T) {
 	}
 }
 
+func TestBaseMap_Get_NamedKey(t *testing.T) {
+	type role string
+	mapValue := NewDynamicMap(map[role][]int16{
+		"admin": {1, 2}}).(traits.Mapper)
+	admins := mapValue.Get(String("admin"))
+	if IsError(admins) {
+		t.Fatal(admins)
+	}
+	if admins.(traits.Indexer).Get(IntOne) != Int(2) {
+		t.Errorf("Got '%v', expected [1, 2]", admins)
+	}
+	if mapValue.Get(String("admin")) != admins {
+		t.Error("Map value was not cached")
+	}
+	if !IsError(mapValue.Get(String("guest"))) {
+		t.Error("Got a value for a missing key")
+	}
+}
+
 func TestBaseMap_Iterator(t *testing.T) {
 	mapValue := NewDynamicMap(map[string]map[int32]float32{
 		"nested": {1: -1.0, 2: 2.0},
@@ -0,0 +1,242 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+var (
+	// MatcherType is the type of a ListMatcher.
+	MatcherType = NewTypeValue("matcher")
+)
+
+// ListMatcher tests strings against a list of patterns with a cost which is
+// linear in the length of the tested string, regardless of the number of
+// patterns, for use with large allow-lists of URLs or domains.
+//
+// The patterns are indexed by an Aho-Corasick automaton, whose trie also
+// serves prefix tests, and by a trie of the reversed patterns for suffix
+// tests.
+type ListMatcher struct {
+	patterns []string
+	forward  []matcherNode
+	reverse  []matcherNode
+}
+
+// matcherNode is a trie node. The root of a trie is the node at index zero.
+type matcherNode struct {
+	children map[byte]int
+	// terminal is true when a pattern ends at the node.
+	terminal bool
+	// fail is the node of the longest proper suffix of the node's path which
+	// is also a path within the trie, and output is true when a pattern ends
+	// at the node or at any node along its chain of fail links.
+	fail   int
+	output bool
+}
+
+// NewListMatcher creates a ListMatcher from a list of string patterns.
+func NewListMatcher(patterns []string) *ListMatcher {
+	m := &ListMatcher{patterns: patterns}
+	m.forward = newTrie(patterns, false)
+	m.reverse = newTrie(patterns, true)
+	m.linkFailures()
+	return m
+}
+
+// NewListMatcherFromList creates a ListMatcher from a list of strings.
+//
+// The matchers of constant lists are built when a program is planned, and
+// take the place of the lists, so a ListMatcher is returned as is.
+func NewListMatcherFromList(list ref.Value) ref.Value {
+	if matcher, isMatcher := list.(*ListMatcher); isMatcher {
+		return matcher
+	}
+	lister, isList := list.(traits.Lister)
+	if !isList {
+		return NewNoSuchOverloadErr()
+	}
+	size, isInt := lister.Size().(Int)
+	if !isInt {
+		return NewNoSuchOverloadErr()
+	}
+	patterns := make([]string, size)
+	for i := Int(0); i < size; i++ {
+		pattern, isString := lister.Get(i).(String)
+		if !isString {
+			return NewErr("matcher patterns must be strings")
+		}
+		patterns[i] = string(pattern)
+	}
+	return NewListMatcher(patterns)
+}
+
+// Patterns returns the patterns of the matcher.
+func (m *ListMatcher) Patterns() []string {
+	return m.patterns
+}
+
+// ContainsAny returns whether the string contains any of the patterns.
+func (m *ListMatcher) ContainsAny(val ref.Value) ref.Value {
+	s, isString := val.(String)
+	if !isString {
+		return NewNoSuchOverloadErr()
+	}
+	node := 0
+	if m.forward[node].output {
+		return True
+	}
+	for i := 0; i < len(s); i++ {
+		node = m.next(node, s[i])
+		if m.forward[node].output {
+			return True
+		}
+	}
+	return False
+}
+
+// StartsWithAny returns whether the string starts with any of the patterns.
+func (m *ListMatcher) StartsWithAny(val ref.Value) ref.Value {
+	s, isString := val.(String)
+	if !isString {
+		return NewNoSuchOverloadErr()
+	}
+	return Bool(hasAffix(m.forward, string(s), false))
+}
+
+// EndsWithAny returns whether the string ends with any of the patterns.
+func (m *ListMatcher) EndsWithAny(val ref.Value) ref.Value {
+	s, isString := val.(String)
+	if !isString {
+		return NewNoSuchOverloadErr()
+	}
+	return Bool(hasAffix(m.reverse, string(s), true))
+}
+
+func (m *ListMatcher) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	if typeDesc == reflect.TypeOf(m.patterns) {
+		return m.patterns, nil
+	}
+	return nil, fmt.Errorf("type conversion error from matcher to '%v'", typeDesc)
+}
+
+func (m *ListMatcher) ConvertToType(typeVal ref.Type) ref.Value {
+	switch typeVal {
+	case MatcherType:
+		return m
+	case TypeType:
+		return MatcherType
+	}
+	return NewErr("type conversion error from '%s' to '%s'", MatcherType, typeVal)
+}
+
+func (m *ListMatcher) Equal(other ref.Value) ref.Value {
+	o, isMatcher := other.(*ListMatcher)
+	return Bool(isMatcher && reflect.DeepEqual(m.patterns, o.patterns))
+}
+
+func (m *ListMatcher) Type() ref.Type {
+	return MatcherType
+}
+
+func (m *ListMatcher) Value() interface{} {
+	return m.patterns
+}
+
+// next returns the node of the automaton reached from the node on the byte.
+func (m *ListMatcher) next(node int, c byte) int {
+	for {
+		if child, found := m.forward[node].children[c]; found {
+			return child
+		}
+		if node == 0 {
+			return 0
+		}
+		node = m.forward[node].fail
+	}
+}
+
+// linkFailures computes the fail links and outputs of the forward trie in
+// breadth-first order, so that the links of shallower nodes are known first.
+func (m *ListMatcher) linkFailures() {
+	nodes := m.forward
+	nodes[0].output = nodes[0].terminal
+	queue := []int{0}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for c, child := range nodes[parent].children {
+			if parent != 0 {
+				nodes[child].fail = m.next(nodes[parent].fail, c)
+			}
+			nodes[child].output = nodes[child].terminal ||
+				nodes[nodes[child].fail].output
+			queue = append(queue, child)
+		}
+	}
+}
+
+func newTrie(patterns []string, reversed bool) []matcherNode {
+	nodes := []matcherNode{{}}
+	for _, pattern := range patterns {
+		node := 0
+		for i := 0; i < len(pattern); i++ {
+			c := pattern[i]
+			if reversed {
+				c = pattern[len(pattern)-1-i]
+			}
+			child, found := nodes[node].children[c]
+			if !found {
+				if nodes[node].children == nil {
+					nodes[node].children = make(map[byte]int)
+				}
+				child = len(nodes)
+				nodes[node].children[c] = child
+				nodes = append(nodes, matcherNode{})
+			}
+			node = child
+		}
+		nodes[node].terminal = true
+	}
+	return nodes
+}
+
+// hasAffix returns whether a pattern within the trie is a prefix of the
+// string, or a suffix when the trie holds reversed patterns.
+func hasAffix(nodes []matcherNode, s string, reversed bool) bool {
+	node := 0
+	for i := 0; ; i++ {
+		if nodes[node].terminal {
+			return true
+		}
+		if i == len(s) {
+			return false
+		}
+		c := s[i]
+		if reversed {
+			c = s[len(s)-1-i]
+		}
+		child, found := nodes[node].children[c]
+		if !found {
+			return false
+		}
+		node = child
+	}
+}
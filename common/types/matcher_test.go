@@ -0,0 +1,99 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListMatcher(t *testing.T) {
+	m := NewListMatcher([]string{"he", "she", "his", "hers", ".example.com"})
+	for _, tst := range []struct {
+		s                          string
+		contains, starts, endsWith bool
+	}{
+		{s: "ushers", contains: true, starts: false, endsWith: true},
+		{s: "hers", contains: true, starts: true, endsWith: true},
+		{s: "shell", contains: true, starts: true, endsWith: false},
+		{s: "this", contains: true, starts: false, endsWith: true},
+		{s: "api.example.com", contains: true, starts: false, endsWith: true},
+		{s: "example.com", contains: false, starts: false, endsWith: false},
+		{s: "h", contains: false, starts: false, endsWith: false},
+		{s: "", contains: false, starts: false, endsWith: false},
+	} {
+		if got := m.ContainsAny(String(tst.s)); got != Bool(tst.contains) {
+			t.Errorf("'%s'.containsAny: got %v, wanted %t", tst.s, got, tst.contains)
+		}
+		if got := m.StartsWithAny(String(tst.s)); got != Bool(tst.starts) {
+			t.Errorf("'%s'.startsWithAny: got %v, wanted %t", tst.s, got, tst.starts)
+		}
+		if got := m.EndsWithAny(String(tst.s)); got != Bool(tst.endsWith) {
+			t.Errorf("'%s'.endsWithAny: got %v, wanted %t", tst.s, got, tst.endsWith)
+		}
+	}
+}
+
+func TestListMatcher_Empty(t *testing.T) {
+	none := NewListMatcher(nil)
+	if none.ContainsAny(String("a")) != False || none.StartsWithAny(String("")) != False {
+		t.Error("Got a match without patterns")
+	}
+	empty := NewListMatcher([]string{""})
+	if empty.ContainsAny(String("")) != True || empty.EndsWithAny(String("a")) != True {
+		t.Error("Got no match with an empty pattern")
+	}
+}
+
+// TestListMatcher_Exhaustive compares the matcher against the strings package
+// for all strings over a small alphabet.
+func TestListMatcher_Exhaustive(t *testing.T) {
+	patterns := []string{"ab", "bab", "bc", "bca", "c", "caa"}
+	m := NewListMatcher(patterns)
+	var inputs []string
+	var gen func(prefix string)
+	gen = func(prefix string) {
+		inputs = append(inputs, prefix)
+		if len(prefix) < 5 {
+			for _, c := range "abc" {
+				gen(prefix + string(c))
+			}
+		}
+	}
+	gen("")
+	for _, s := range inputs {
+		var contains, starts, ends bool
+		for _, p := range patterns {
+			contains = contains || strings.Contains(s, p)
+			starts = starts || strings.HasPrefix(s, p)
+			ends = ends || strings.HasSuffix(s, p)
+		}
+		if m.ContainsAny(String(s)) != Bool(contains) ||
+			m.StartsWithAny(String(s)) != Bool(starts) ||
+			m.EndsWithAny(String(s)) != Bool(ends) {
+			t.Errorf("'%s': got different results than the strings package", s)
+		}
+	}
+}
+
+func TestNewListMatcherFromList(t *testing.T) {
+	m := NewListMatcherFromList(NewStringList([]string{"a", "b"}))
+	if m.Type() != MatcherType {
+		t.Fatalf("Got %v, wanted a matcher", m)
+	}
+	if !IsError(NewListMatcherFromList(NewDynamicList([]interface{}{"a", 1}))) {
+		t.Error("Got a matcher from a list with a non-string element")
+	}
+}
@@ -30,12 +30,21 @@ type protoObj struct {
 	typeDesc  *pb.TypeDescription
 	typeValue *TypeValue
 	isAny     bool
+
+	// Limits on the messages adapted from the fields of the object, and the
+	// depth of the object within the value from which it was adapted.
+	limits *UnpackLimits
+	depth  int
 }
 
 // NewObject returns an object based on a proto.Message value which handles
 // conversion between protobuf type values and expression type values.
 // Objects support indexing and iteration.
 func NewObject(value proto.Message) ref.Value {
+	return newObject(value, nil, 1)
+}
+
+func newObject(value proto.Message, limits *UnpackLimits, depth int) ref.Value {
 	typeDesc, err := pb.DescribeValue(value)
 	if err != nil {
 		panic(err)
@@ -44,7 +53,9 @@ func NewObject(value proto.Message) ref.Value {
 		value:     value,
 		refValue:  reflect.ValueOf(value),
 		typeDesc:  typeDesc,
-		typeValue: NewObjectTypeValue(typeDesc.Name())}
+		typeValue: NewObjectTypeValue(typeDesc.Name()),
+		limits:    limits,
+		depth:     depth}
 }
 
 func (o *protoObj) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
@@ -89,18 +100,50 @@ func (o *protoObj) Get(index ref.Value) ref.Value {
 	protoFieldName := string(index.(String))
 	if f, found := o.typeDesc.FieldByName(protoFieldName); found {
 		if !f.IsOneof() {
-			return getOrDefaultInstance(o.refValue.Elem().Field(f.Index()))
+			return o.getOrDefaultInstance(o.refValue.Elem().Field(f.Index()))
 		}
 
 		getter := o.refValue.MethodByName(f.GetterName())
 		if getter.IsValid() {
 			refField := getter.Call([]reflect.Value{})[0]
 			if refField.IsValid() {
-				return getOrDefaultInstance(refField)
+				return o.getOrDefaultInstance(refField)
 			}
 		}
 	}
-	return NewErr("no such field '%s'", index)
+	return newMissingFieldErr("no such field '%s'", index)
+}
+
+// IsSet returns whether the field is set within the message.
+//
+// A field within a oneof is set when it is the field selected by the oneof.
+// Otherwise, message fields are set when they are non-nil, repeated and map
+// fields are set when they are non-empty, and scalar fields are set when they
+// have a non-default value.
+func (o *protoObj) IsSet(field ref.Value) ref.Value {
+	if field.Type() != StringType {
+		return NewErr("illegal object field type '%s'", field.Type())
+	}
+	protoFieldName := string(field.(String))
+	f, found := o.typeDesc.FieldByName(protoFieldName)
+	if !found {
+		return newMissingFieldErr("no such field '%s'", field)
+	}
+	return Bool(o.isFieldSet(f))
+}
+
+func (o *protoObj) isFieldSet(f *pb.FieldDescription) bool {
+	refField := o.refValue.Elem().Field(f.Index())
+	if f.IsOneof() {
+		return !refField.IsNil() && refField.Elem().Type() == f.OneofType()
+	}
+	switch refField.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return !refField.IsNil()
+	case reflect.Map, reflect.Slice:
+		return refField.Len() != 0
+	}
+	return refField.Interface() != reflect.Zero(refField.Type()).Interface()
 }
 
 func (o *protoObj) Iterator() traits.Iterator {
@@ -119,6 +162,44 @@ func (o *protoObj) Value() interface{} {
 	return o.value
 }
 
+// WhichOneof returns the name of the field which is set within the named oneof
+// of a message, or an empty string if none of the fields in the oneof is set.
+//
+// Each of the fields in the oneof is reported as read from a tracked object.
+func WhichOneof(msg ref.Value, oneofName ref.Value) ref.Value {
+	tracked, isTracked := msg.(*trackedObj)
+	if isTracked {
+		msg = tracked.protoObj
+	}
+	var typeDesc *pb.TypeDescription
+	var isFieldSet func(f *pb.FieldDescription) bool
+	switch o := msg.(type) {
+	case *protoObj:
+		typeDesc, isFieldSet = o.typeDesc, o.isFieldSet
+	case *dynamicObj:
+		typeDesc, isFieldSet = o.typeDesc, o.isFieldSet
+	}
+	if typeDesc == nil || oneofName.Type() != StringType {
+		return NewNoSuchOverloadErr()
+	}
+	fields, found := typeDesc.OneofFields(string(oneofName.(String)))
+	if !found {
+		return NewErr("no such oneof '%s' in type '%s'",
+			oneofName, typeDesc.Name())
+	}
+	if isTracked {
+		for _, f := range fields {
+			tracked.read(tracked.path + "." + f.OrigName())
+		}
+	}
+	for _, f := range fields {
+		if isFieldSet(f) {
+			return String(f.OrigName())
+		}
+	}
+	return String("")
+}
+
 type msgIterator struct {
 	*baseIterator
 	refValue reflect.Value
@@ -144,10 +225,10 @@ var (
 	protoDefaultInstanceMap = make(map[reflect.Type]ref.Value)
 )
 
-func getOrDefaultInstance(refVal reflect.Value) ref.Value {
+func (o *protoObj) getOrDefaultInstance(refVal reflect.Value) ref.Value {
 	value := refVal.Interface()
 	if refVal.Kind() != reflect.Ptr || !refVal.IsNil() {
-		return NativeToValue(value)
+		return nativeToValue(value, o.limits, o.depth)
 	}
 	return getDefaultInstance(refVal.Type())
 }
@@ -55,6 +55,61 @@ func TestProtoObject_Iterator(t *testing.T) {
 	}
 }
 
+func TestProtoObj_IsSet(t *testing.T) {
+	msg := NewObject(&test.TestAllTypes{
+		SingleInt32:     1,
+		RepeatedInt32:   []int32{},
+		MapStringString: map[string]string{"a": "b"},
+		NestedType: &test.TestAllTypes_SingleNestedEnum{
+			SingleNestedEnum: test.TestAllTypes_FOO}}).(traits.FieldTester)
+	for field, expected := range map[string]ref.Value{
+		"single_int32":          True,
+		"single_int64":          False,
+		"single_int64_wrapper":  False,
+		"repeated_int32":        False,
+		"map_string_string":     True,
+		"single_nested_enum":    True,
+		"single_nested_message": False,
+	} {
+		if isSet := msg.IsSet(String(field)); isSet != expected {
+			t.Errorf("Got '%v' for field '%s', wanted '%v'", isSet, field, expected)
+		}
+	}
+	if isSet := msg.IsSet(String("undefined")); !IsMissingField(isSet) {
+		t.Errorf("Got '%v' for an undefined field, wanted an error", isSet)
+	}
+}
+
+func TestProtoObj_JSONFieldNames(t *testing.T) {
+	// Fields are only found by their proto names during evaluation.
+	msg := NewObject(&test.TestAllTypes{SingleInt32: 1})
+	if value := msg.(traits.Indexer).Get(String("singleInt32")); !IsError(value) {
+		t.Errorf("Got '%v' for the field 'singleInt32', wanted an error", value)
+	}
+	if isSet := msg.(traits.FieldTester).IsSet(String("singleInt32")); !IsError(isSet) {
+		t.Errorf("Got '%v' for the presence of 'singleInt32', wanted an error", isSet)
+	}
+}
+
+func TestWhichOneof(t *testing.T) {
+	unset := NewObject(&test.TestAllTypes{})
+	set := NewObject(&test.TestAllTypes{
+		NestedType: &test.TestAllTypes_SingleNestedMessage{
+			SingleNestedMessage: &test.TestAllTypes_NestedMessage{}}})
+	if field := WhichOneof(set, String("nested_type")); field != String("single_nested_message") {
+		t.Errorf("Got '%v', wanted 'single_nested_message'", field)
+	}
+	if field := WhichOneof(unset, String("nested_type")); field != String("") {
+		t.Errorf("Got '%v', wanted ''", field)
+	}
+	if field := WhichOneof(unset, String("kind")); !IsError(field) {
+		t.Errorf("Got '%v' for an undefined oneof, wanted an error", field)
+	}
+	if field := WhichOneof(String("msg"), String("nested_type")); !IsError(field) {
+		t.Errorf("Got '%v' for a non-message operand, wanted an error", field)
+	}
+}
+
 func TestProtoObj_ConvertToNative(t *testing.T) {
 	pbMessage := &syntax.ParsedExpr{
 		SourceInfo: &syntax.SourceInfo{
@@ -42,14 +42,109 @@ func DescribeFile(message proto.Message) (*FileDescription, error) {
 		return fd, nil
 	}
 	fileDesc, _ := descriptor.ForMessage(message.(descriptor.Message))
-	fd, err := describeFileInternal(fileDesc)
-	if err != nil {
-		return nil, err
+	return describeFileIndexed(fileDesc)
+}
+
+// DescribeFileDescriptorSet indexes the message types and enum values of each
+// file within the set and returns the resulting FileDescriptions in dependency
+// order.
+//
+// Dependencies which are not contained within the set must be linked into the
+// binary, e.g. 'google/protobuf/duration.proto'. Files which are also linked
+// into the binary, or which were described previously, must be identical to
+// the existing definitions, and a type or enum may not be declared by more
+// than one file. An error is returned for a missing dependency or a
+// conflicting definition, in which case none of the files are indexed.
+func DescribeFileDescriptorSet(fds *descpb.FileDescriptorSet) ([]*FileDescription, error) {
+	files := make(map[string]*descpb.FileDescriptorProto)
+	for _, fileDesc := range fds.GetFile() {
+		if other, found := files[fileDesc.GetName()]; found &&
+			!equalFileDescriptors(fileDesc, other) {
+			return nil, fmt.Errorf(
+				"conflicting definitions of file '%s'", fileDesc.GetName())
+		}
+		files[fileDesc.GetName()] = fileDesc
 	}
-	pkg := fd.Package()
-	fd.indexTypes(pkg, fileDesc.MessageType)
-	fd.indexEnums(pkg, fileDesc.EnumType)
-	return fd, nil
+	// Order the files so that each is described after its dependencies.
+	var ordered []*descpb.FileDescriptorProto
+	visited := make(map[string]bool)
+	var visit func(fileName string, importedBy string) error
+	visit = func(fileName string, importedBy string) error {
+		if visited[fileName] {
+			return nil
+		}
+		visited[fileName] = true
+		fileDesc, found := files[fileName]
+		if !found {
+			if _, found := fileDescriptorMap[fileName]; found {
+				return nil
+			}
+			if proto.FileDescriptor(fileName) == nil {
+				return fmt.Errorf("missing dependency '%s' of file '%s'",
+					fileName, importedBy)
+			}
+			return nil
+		}
+		for _, dep := range fileDesc.GetDependency() {
+			if err := visit(dep, fileName); err != nil {
+				return err
+			}
+		}
+		ordered = append(ordered, fileDesc)
+		return nil
+	}
+	for _, fileDesc := range fds.GetFile() {
+		if err := visit(fileDesc.GetName(), ""); err != nil {
+			return nil, err
+		}
+	}
+	// Detect conflicts with existing definitions before indexing any file.
+	declaringFiles := make(map[string]string)
+	for _, fileDesc := range ordered {
+		existing, err := existingFileDescriptor(fileDesc.GetName())
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			if !equalFileDescriptors(fileDesc, existing) {
+				return nil, fmt.Errorf(
+					"conflicting definitions of file '%s'", fileDesc.GetName())
+			}
+			continue
+		}
+		for _, name := range declaredNames(fileDesc) {
+			if other, found := declaringFiles[name]; found {
+				return nil, fmt.Errorf(
+					"'%s' declared in both '%s' and '%s'",
+					name, other, fileDesc.GetName())
+			}
+			declaringFiles[name] = fileDesc.GetName()
+			if fd, found := revFileDescriptorMap[name]; found {
+				return nil, fmt.Errorf(
+					"'%s' declared in both '%s' and '%s'",
+					name, fd.desc.GetName(), fileDesc.GetName())
+			}
+			if proto.MessageType(name) != nil || proto.EnumValueMap(name) != nil {
+				return nil, fmt.Errorf(
+					"'%s' declared in '%s' conflicts with a linked type",
+					name, fileDesc.GetName())
+			}
+		}
+	}
+	described := make([]*FileDescription, len(ordered))
+	for i, fileDesc := range ordered {
+		if fd, found := fileDescriptorMap[fileDesc.GetName()]; found &&
+			len(fd.types)+len(fd.enums) != 0 {
+			described[i] = fd
+			continue
+		}
+		fd, err := describeFileIndexed(fileDesc)
+		if err != nil {
+			return nil, err
+		}
+		described[i] = fd
+	}
+	return described, nil
 }
 
 // DescribeType provides a TypeDescription given a qualified type name.
@@ -98,6 +193,69 @@ func describeFileInternal(fileDesc *descpb.FileDescriptorProto) (*FileDescriptio
 	return fd, nil
 }
 
+// describeFileIndexed describes the file and its dependencies, and indexes the
+// types and enums declared within the file.
+func describeFileIndexed(fileDesc *descpb.FileDescriptorProto) (*FileDescription, error) {
+	fd, err := describeFileInternal(fileDesc)
+	if err != nil {
+		return nil, err
+	}
+	pkg := fd.Package()
+	fd.indexTypes(pkg, fileDesc.MessageType)
+	fd.indexEnums(pkg, fileDesc.EnumType)
+	return fd, nil
+}
+
+// existingFileDescriptor returns the descriptor of a file which has already
+// been described or which is linked into the binary, or nil if there is none.
+func existingFileDescriptor(protoFileName string) (*descpb.FileDescriptorProto, error) {
+	if fd, found := fileDescriptorMap[protoFileName]; found {
+		return fd.desc, nil
+	}
+	if proto.FileDescriptor(protoFileName) == nil {
+		return nil, nil
+	}
+	return fileDescriptor(protoFileName)
+}
+
+// declaredNames returns the qualified names of the message types, enum types,
+// and enum values declared within a file.
+func declaredNames(fileDesc *descpb.FileDescriptorProto) []string {
+	var names []string
+	var addEnums func(prefix string, enumTypes []*descpb.EnumDescriptorProto)
+	addEnums = func(prefix string, enumTypes []*descpb.EnumDescriptorProto) {
+		for _, enumType := range enumTypes {
+			enumName := prefix + "." + enumType.GetName()
+			names = append(names, enumName)
+			for _, enumValue := range enumType.GetValue() {
+				names = append(names, enumName+"."+enumValue.GetName())
+			}
+		}
+	}
+	var addTypes func(prefix string, msgTypes []*descpb.DescriptorProto)
+	addTypes = func(prefix string, msgTypes []*descpb.DescriptorProto) {
+		for _, msgType := range msgTypes {
+			msgName := prefix + "." + msgType.GetName()
+			names = append(names, msgName)
+			addTypes(msgName, msgType.GetNestedType())
+			addEnums(msgName, msgType.GetEnumType())
+		}
+	}
+	addTypes(fileDesc.GetPackage(), fileDesc.GetMessageType())
+	addEnums(fileDesc.GetPackage(), fileDesc.GetEnumType())
+	return names
+}
+
+// equalFileDescriptors compares two file descriptors, ignoring source code
+// info which is only present when requested from protoc.
+func equalFileDescriptors(a, b *descpb.FileDescriptorProto) bool {
+	a = proto.Clone(a).(*descpb.FileDescriptorProto)
+	b = proto.Clone(b).(*descpb.FileDescriptorProto)
+	a.SourceCodeInfo = nil
+	b.SourceCodeInfo = nil
+	return proto.Equal(a, b)
+}
+
 func fileDescriptor(protoFileName string) (*descpb.FileDescriptorProto, error) {
 	gzipped := proto.FileDescriptor(protoFileName)
 	r, err := gzip.NewReader(bytes.NewReader(gzipped))
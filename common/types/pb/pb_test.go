@@ -0,0 +1,112 @@
+package pb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	descpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestDescribeFileDescriptorSet(t *testing.T) {
+	files, err := DescribeFileDescriptorSet(&descpb.FileDescriptorSet{
+		File: []*descpb.FileDescriptorProto{
+			fileProto("acme/request.proto", "acme", []string{"acme/resource.proto"},
+				messageProto("Request",
+					fieldProto("user", 1, descpb.FieldDescriptorProto_TYPE_STRING, ""),
+					fieldProto("resource", 2, descpb.FieldDescriptorProto_TYPE_MESSAGE, ".acme.Resource"))),
+			fileProto("acme/resource.proto", "acme", nil,
+				messageProto("Resource",
+					fieldProto("name", 1, descpb.FieldDescriptorProto_TYPE_STRING, ""))),
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].desc.GetName() != "acme/resource.proto" {
+		t.Fatalf("Files not in dependency order: %v", files)
+	}
+	td, err := DescribeType("acme.Request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, found := td.FieldByName("resource")
+	if !found {
+		t.Fatal("Field 'resource' not found")
+	}
+	if fd.OrigName() != "resource" || fd.IsRepeated() ||
+		fd.CheckedType().GetMessageType() != "acme.Resource" {
+		t.Errorf("Unexpected field description: %v", fd)
+	}
+}
+
+func TestDescribeFileDescriptorSet_Errors(t *testing.T) {
+	for _, tst := range []struct {
+		files []*descpb.FileDescriptorProto
+		err   string
+	}{
+		{
+			files: []*descpb.FileDescriptorProto{
+				fileProto("errors/missing.proto", "errors", []string{"errors/absent.proto"},
+					messageProto("Missing"))},
+			err: "missing dependency 'errors/absent.proto' of file 'errors/missing.proto'",
+		},
+		{
+			files: []*descpb.FileDescriptorProto{
+				fileProto("google/protobuf/duration.proto", "google.protobuf", nil,
+					messageProto("Duration"))},
+			err: "conflicting definitions of file 'google/protobuf/duration.proto'",
+		},
+		{
+			files: []*descpb.FileDescriptorProto{
+				fileProto("errors/duration.proto", "google.protobuf", nil,
+					messageProto("Duration"))},
+			err: "'google.protobuf.Duration' declared in 'errors/duration.proto' conflicts with a linked type",
+		},
+		{
+			files: []*descpb.FileDescriptorProto{
+				fileProto("errors/a.proto", "errors", nil, messageProto("Twice")),
+				fileProto("errors/b.proto", "errors", nil, messageProto("Twice"))},
+			err: "'errors.Twice' declared in both 'errors/a.proto' and 'errors/b.proto'",
+		},
+	} {
+		_, err := DescribeFileDescriptorSet(&descpb.FileDescriptorSet{File: tst.files})
+		if err == nil || err.Error() != tst.err {
+			t.Errorf("Got error '%v', wanted '%s'", err, tst.err)
+		}
+	}
+	if _, err := DescribeType("errors.Twice"); err == nil ||
+		!strings.Contains(err.Error(), "unrecognized type") {
+		t.Errorf("Files were indexed despite a conflict: %v", err)
+	}
+}
+
+func fileProto(name string, pkg string, deps []string,
+	msgTypes ...*descpb.DescriptorProto) *descpb.FileDescriptorProto {
+	return &descpb.FileDescriptorProto{
+		Name:        proto.String(name),
+		Package:     proto.String(pkg),
+		Dependency:  deps,
+		MessageType: msgTypes,
+		Syntax:      proto.String("proto3")}
+}
+
+func messageProto(name string,
+	fields ...*descpb.FieldDescriptorProto) *descpb.DescriptorProto {
+	return &descpb.DescriptorProto{
+		Name:  proto.String(name),
+		Field: fields}
+}
+
+func fieldProto(name string, number int32,
+	fieldType descpb.FieldDescriptorProto_Type,
+	typeName string) *descpb.FieldDescriptorProto {
+	field := &descpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Label:  descpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   fieldType.Enum()}
+	if typeName != "" {
+		field.TypeName = proto.String(typeName)
+	}
+	return field
+}
@@ -30,6 +30,7 @@ type TypeDescription struct {
 	file            *FileDescription
 	desc            *descpb.DescriptorProto
 	fields          map[string]*FieldDescription
+	jsonFields      map[string]*FieldDescription
 	fieldIndices    map[int][]*FieldDescription
 	fieldProperties *proto.StructProperties
 	refType         *reflect.Type
@@ -51,6 +52,21 @@ func (td *TypeDescription) FieldByName(name string) (*FieldDescription, bool) {
 	return fd, found
 }
 
+// FieldByJSONName returns the FieldDescription associated with the JSON name
+// of a field, e.g. 'singleInt64' for the field 'single_int64'.
+func (td *TypeDescription) FieldByJSONName(name string) (*FieldDescription, bool) {
+	if td.jsonFields == nil {
+		fieldMap, _ := td.getFieldsInfo()
+		jsonFields := make(map[string]*FieldDescription, len(fieldMap))
+		for _, fd := range fieldMap {
+			jsonFields[fd.JSONName()] = fd
+		}
+		td.jsonFields = jsonFields
+	}
+	fd, found := td.jsonFields[name]
+	return fd, found
+}
+
 // FieldNameAtIndex returns the field name at the specified index.
 //
 // For oneof field values, multiple fields may exist at the same index, so the
@@ -76,6 +92,39 @@ func (td *TypeDescription) FieldNameAtIndex(index int, refObj reflect.Value) (st
 	return "", false
 }
 
+// Fields returns the descriptions of the fields declared within the type, in
+// declaration order.
+func (td *TypeDescription) Fields() []*FieldDescription {
+	fieldMap, _ := td.getFieldsInfo()
+	fields := make([]*FieldDescription, 0, len(td.desc.GetField()))
+	for _, f := range td.desc.GetField() {
+		if fd, found := fieldMap[f.GetName()]; found {
+			fields = append(fields, fd)
+		}
+	}
+	return fields
+}
+
+// OneofFields returns the fields declared within the named oneof, or false if
+// the type declares no such oneof.
+func (td *TypeDescription) OneofFields(oneofName string) ([]*FieldDescription, bool) {
+	for i, oneof := range td.desc.GetOneofDecl() {
+		if oneof.GetName() != oneofName {
+			continue
+		}
+		var fields []*FieldDescription
+		for _, f := range td.desc.GetField() {
+			if f.OneofIndex != nil && int(f.GetOneofIndex()) == i {
+				if fd, found := td.FieldByName(f.GetName()); found {
+					fields = append(fields, fd)
+				}
+			}
+		}
+		return fields, true
+	}
+	return nil, false
+}
+
 // Name of the type.
 func (td *TypeDescription) Name() string {
 	return td.typeName
@@ -248,23 +297,67 @@ func (fd *FieldDescription) IsMessage() bool {
 // This method will also return true for map values, so check whether the
 // field is also a map.
 func (fd *FieldDescription) IsRepeated() bool {
+	if fd.prop == nil {
+		return fd.desc.GetLabel() == descpb.FieldDescriptorProto_LABEL_REPEATED
+	}
 	return fd.prop.Repeated
 }
 
 // OrigName returns the snake_case name of the field as it was declared within
 // the proto. This is the same name format that is expected within expressions.
 func (fd *FieldDescription) OrigName() string {
+	if fd.prop == nil {
+		return fd.desc.GetName()
+	}
 	return fd.prop.OrigName
 }
 
+// JSONName returns the lowerCamelCase name of the field within the JSON
+// encoding of the proto, e.g. 'singleInt64' for 'single_int64', unless the
+// field declares a json_name of its own.
+func (fd *FieldDescription) JSONName() string {
+	if fd.prop != nil && fd.prop.JSONName != "" {
+		return fd.prop.JSONName
+	}
+	if fd.desc.GetJsonName() != "" {
+		return fd.desc.GetJsonName()
+	}
+	// The name protoc derives when no json_name is declared.
+	var name []byte
+	upper := false
+	for _, c := range []byte(fd.OrigName()) {
+		switch {
+		case c == '_':
+			upper = true
+		case upper && 'a' <= c && c <= 'z':
+			name = append(name, c-'a'+'A')
+			upper = false
+		default:
+			name = append(name, c)
+			upper = false
+		}
+	}
+	return string(name)
+}
+
 // Name returns the CamelCase name of the field within the proto-based struct.
+//
+// Types which are only known by their descriptors have no generated struct,
+// in which case the name is the same as the OrigName.
 func (fd *FieldDescription) Name() string {
+	if fd.prop == nil {
+		return fd.desc.GetName()
+	}
 	return fd.prop.Name
 }
 
 // SupportsPresence returns true if the field supports presence detection.
+//
+// Fields declared within a oneof always support presence detection, since
+// the set field of the oneof is tracked even for proto3 scalar fields.
 func (fd *FieldDescription) SupportsPresence() bool {
-	return !fd.IsRepeated() && (fd.IsMessage() || !fd.proto3)
+	return !fd.IsRepeated() &&
+		(fd.IsMessage() || !fd.proto3 || fd.desc.OneofIndex != nil)
 }
 
 // String returns a proto-like field definition string.
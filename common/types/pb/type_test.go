@@ -60,3 +60,28 @@ func TestTypeDescription_Field(t *testing.T) {
 		t.Error("Field 'payload' had an unexpected checked type.")
 	}
 }
+
+func TestTypeDescription_FieldByJSONName(t *testing.T) {
+	td, err := DescribeValue(&test.TestAllTypes{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for jsonName, origName := range map[string]string{
+		"singleInt64":        "single_int64",
+		"singleInt64Wrapper": "single_int64_wrapper",
+		"singleNestedEnum":   "single_nested_enum",
+	} {
+		fd, found := td.FieldByJSONName(jsonName)
+		if !found {
+			t.Errorf("Field '%s' not found", jsonName)
+			continue
+		}
+		if fd.OrigName() != origName || fd.JSONName() != jsonName {
+			t.Errorf("Got field '%s' with JSON name '%s' for '%s', wanted '%s'",
+				fd.OrigName(), fd.JSONName(), jsonName, origName)
+		}
+	}
+	if _, found := td.FieldByJSONName("single_int64"); found {
+		t.Error("Field 'single_int64' found by its proto name")
+	}
+}
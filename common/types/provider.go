@@ -15,7 +15,9 @@
 package types
 
 import (
+	"fmt"
 	"github.com/golang/protobuf/proto"
+	descpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/golang/protobuf/ptypes"
 	anypb "github.com/golang/protobuf/ptypes/any"
 	dpb "github.com/golang/protobuf/ptypes/duration"
@@ -24,10 +26,13 @@ import (
 	"github.com/google/cel-go/common/types/pb"
 	"github.com/google/cel-go/common/types/ref"
 	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+	"io/ioutil"
 	"reflect"
+	"sort"
 )
 
 type protoTypeProvider struct {
+	enumNames  map[string]bool
 	revTypeMap map[string]ref.Type
 }
 
@@ -36,6 +41,7 @@ type protoTypeProvider struct {
 // message that proto depends upon in its FileDescriptor.
 func NewProvider(types ...proto.Message) ref.TypeProvider {
 	p := &protoTypeProvider{
+		enumNames:  make(map[string]bool),
 		revTypeMap: make(map[string]ref.Type)}
 	p.RegisterType(
 		BoolType,
@@ -57,13 +63,68 @@ func NewProvider(types ...proto.Message) ref.TypeProvider {
 		if err != nil {
 			panic(err)
 		}
-		for _, typeName := range fd.GetTypeNames() {
-			p.RegisterType(NewObjectTypeValue(typeName))
-		}
+		p.registerFile(fd)
 	}
 	return p
 }
 
+// NewProviderFromFileDescriptorSet returns a type provider for the types and
+// enums declared within the files of a FileDescriptorSet, such as the output
+// of 'protoc --include_imports --descriptor_set_out'.
+//
+// Dependencies which are not contained within the set must be linked into the
+// binary, and files which are also linked into the binary must match the
+// linked definitions. Values of types which are only known by their
+// descriptors are created as dynamic messages, whose fields are held by name
+// rather than by a generated struct.
+func NewProviderFromFileDescriptorSet(fds *descpb.FileDescriptorSet) (ref.TypeProvider, error) {
+	p := NewProvider().(*protoTypeProvider)
+	if err := p.RegisterDescriptors(fds); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DescriptorRegistry is implemented by the type providers of this package so
+// that the types and enums of a FileDescriptorSet may be registered after the
+// provider is created, e.g. when the schemas of messages are received from
+// configuration rather than generated code.
+type DescriptorRegistry interface {
+	// RegisterDescriptors registers the types and enums declared within the
+	// files of the set, as by NewProviderFromFileDescriptorSet. When an error
+	// is returned, none of the files are registered.
+	RegisterDescriptors(fds *descpb.FileDescriptorSet) error
+}
+
+// NewProviderFromFiles reads serialized FileDescriptorSet messages from the
+// given paths and returns a type provider for all of the files within them.
+//
+// See NewProviderFromFileDescriptorSet.
+func NewProviderFromFiles(paths ...string) (ref.TypeProvider, error) {
+	combined := &descpb.FileDescriptorSet{}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fds := &descpb.FileDescriptorSet{}
+		if err := proto.Unmarshal(data, fds); err != nil {
+			return nil, fmt.Errorf("invalid descriptor set '%s': %v", path, err)
+		}
+		combined.File = append(combined.File, fds.File...)
+	}
+	return NewProviderFromFileDescriptorSet(combined)
+}
+
+func (p *protoTypeProvider) EnumNames() []string {
+	enumNames := make([]string, 0, len(p.enumNames))
+	for enumName := range p.enumNames {
+		enumNames = append(enumNames, enumName)
+	}
+	sort.Strings(enumNames)
+	return enumNames
+}
+
 func (p *protoTypeProvider) EnumValue(enumName string) ref.Value {
 	enumVal, err := pb.DescribeEnum(enumName)
 	if err != nil {
@@ -93,6 +154,28 @@ func (p *protoTypeProvider) FindFieldType(t *checkedpb.Type,
 	}
 }
 
+// FieldNames returns the names of the fields of a message type in declaration
+// order. The fields of well-known types, which are not messages within CEL,
+// are not listed.
+func (p *protoTypeProvider) FieldNames(typeName string) ([]string, bool) {
+	if typeName != "" && typeName[0] == '.' {
+		typeName = typeName[1:]
+	}
+	if _, found := pb.CheckedWellKnowns[typeName]; found {
+		return nil, false
+	}
+	td, err := pb.DescribeType(typeName)
+	if err != nil {
+		return nil, false
+	}
+	fields := td.Fields()
+	fieldNames := make([]string, len(fields))
+	for i, f := range fields {
+		fieldNames[i] = f.OrigName()
+	}
+	return fieldNames, true
+}
+
 func (p *protoTypeProvider) FindIdent(identName string) (ref.Value, bool) {
 	if t, found := p.revTypeMap[identName]; found {
 		return t.(ref.Value), true
@@ -122,6 +205,12 @@ func (p *protoTypeProvider) FindType(typeName string) (*checkedpb.Type, bool) {
 					MessageType: typeName}}}}, true
 }
 
+func (p *protoTypeProvider) IdentNames() []string {
+	identNames := append(p.TypeNames(), p.EnumNames()...)
+	sort.Strings(identNames)
+	return identNames
+}
+
 func (p *protoTypeProvider) NewValue(typeName string,
 	fields map[string]ref.Value) ref.Value {
 	td, err := pb.DescribeType(typeName)
@@ -129,6 +218,9 @@ func (p *protoTypeProvider) NewValue(typeName string,
 		return NewErr("unknown type '%s'", typeName)
 	}
 	refType := td.ReflectType()
+	if refType == nil {
+		return newDynamicObject(td, fields)
+	}
 	// create the new type instance.
 	value := reflect.New(refType.Elem())
 	pbValue := value.Elem()
@@ -155,13 +247,34 @@ func (p *protoTypeProvider) NewValue(typeName string,
 		}
 		fieldValue, err := value.ConvertToNative(dstType)
 		if err != nil {
-			return &Err{err}
+			return &Err{error: err}
 		}
 		refField.Set(reflect.ValueOf(fieldValue))
 	}
 	return NewObject(value.Interface().(proto.Message))
 }
 
+func (p *protoTypeProvider) RegisterDescriptors(fds *descpb.FileDescriptorSet) error {
+	files, err := pb.DescribeFileDescriptorSet(fds)
+	if err != nil {
+		return err
+	}
+	for _, fd := range files {
+		p.registerFile(fd)
+	}
+	return nil
+}
+
+// registerFile registers the types and enums declared within a file.
+func (p *protoTypeProvider) registerFile(fd *pb.FileDescription) {
+	for _, typeName := range fd.GetTypeNames() {
+		p.RegisterType(NewObjectTypeValue(typeName))
+	}
+	for _, enumName := range fd.GetEnumNames() {
+		p.enumNames[enumName] = true
+	}
+}
+
 func (p *protoTypeProvider) RegisterType(types ...ref.Type) error {
 	for _, t := range types {
 		p.revTypeMap[t.TypeName()] = t
@@ -170,7 +283,33 @@ func (p *protoTypeProvider) RegisterType(types ...ref.Type) error {
 	return nil
 }
 
+func (p *protoTypeProvider) TypeNames() []string {
+	typeNames := make([]string, 0, len(p.revTypeMap))
+	for typeName := range p.revTypeMap {
+		typeNames = append(typeNames, typeName)
+	}
+	sort.Strings(typeNames)
+	return typeNames
+}
+
+// NativeToValue adapts a Go value, such as a primitive, slice, map, or
+// protocol buffer message, to a ref.Value.
 func NativeToValue(value interface{}) ref.Value {
+	return nativeToValue(value, nil, 0)
+}
+
+// NativeToValueWithLimits adapts a Go value to a ref.Value as NativeToValue
+// does, but restricts the messages which may be unpacked from Any values and
+// the depth of the messages which may be traversed within the value.
+//
+// The limits apply to the values adapted lazily as the returned value is
+// traversed, such as the fields of a message or the elements of a list.
+func NativeToValueWithLimits(value interface{}, limits *UnpackLimits) ref.Value {
+	return nativeToValue(value, limits, 0)
+}
+
+// nativeToValue adapts a value found at the given message depth.
+func nativeToValue(value interface{}, limits *UnpackLimits, depth int) ref.Value {
 	switch value.(type) {
 	case ref.Value:
 		return value.(ref.Value)
@@ -199,6 +338,9 @@ func NativeToValue(value interface{}) ref.Value {
 	case []string:
 		return NewStringList(value.([]string))
 	case *dpb.Duration:
+		if err := checkDuration(value.(*dpb.Duration)); err != nil {
+			return err
+		}
 		return Duration{value.(*dpb.Duration)}
 	case *structpb.ListValue:
 		return NewJsonList(value.(*structpb.ListValue))
@@ -223,16 +365,25 @@ func NativeToValue(value interface{}) ref.Value {
 			return NativeToValue(v.GetStructValue())
 		}
 	case *tpb.Timestamp:
+		if err := checkTimestamp(value.(*tpb.Timestamp)); err != nil {
+			return err
+		}
 		return Timestamp{value.(*tpb.Timestamp)}
 	case *anypb.Any:
 		val := value.(*anypb.Any)
+		if err := limits.checkAny(val); err != nil {
+			return err
+		}
 		unpackedAny := ptypes.DynamicAny{}
 		if ptypes.UnmarshalAny(val, &unpackedAny) != nil {
-			NewErr("Fail to unmarshal any.")
+			return NewErr("Fail to unmarshal any.")
 		}
-		return NativeToValue(unpackedAny.Message)
+		return nativeToValue(unpackedAny.Message, limits, depth)
 	case proto.Message:
-		return NewObject(value.(proto.Message))
+		if err := limits.checkDepth(depth + 1); err != nil {
+			return err
+		}
+		return newObject(value.(proto.Message), limits, depth+1)
 	default:
 		refValue := reflect.ValueOf(value)
 		if refValue.Kind() == reflect.Ptr {
@@ -241,9 +392,23 @@ func NativeToValue(value interface{}) ref.Value {
 		refKind := refValue.Kind()
 		switch refKind {
 		case reflect.Array, reflect.Slice:
-			return NewDynamicList(value)
+			return &baseList{value: value, refValue: reflect.ValueOf(value),
+				limits: limits, depth: depth}
 		case reflect.Map:
-			return NewDynamicMap(value)
+			return &baseMap{value: value, refValue: reflect.ValueOf(value),
+				limits: limits, depth: depth}
+		// Adapt sized and named primitive types, e.g. int16 or
+		// 'type Role string', by their underlying kind.
+		case reflect.Bool:
+			return Bool(refValue.Bool())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return Int(refValue.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return Uint(refValue.Uint())
+		case reflect.Float32, reflect.Float64:
+			return Double(refValue.Float())
+		case reflect.String:
+			return String(refValue.String())
 		}
 	}
 	return NewErr("unsupported type conversion for value '%v'", value)
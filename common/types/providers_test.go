@@ -17,12 +17,20 @@ package types
 import (
 	"bytes"
 	"github.com/golang/protobuf/proto"
+	descpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/golang/protobuf/ptypes"
+	dpb "github.com/golang/protobuf/ptypes/duration"
 	"github.com/golang/protobuf/ptypes/struct"
+	tpb "github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/test"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
 	expr "github.com/google/cel-spec/proto/v1/syntax"
+	"io/ioutil"
+	"os"
 	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -62,6 +70,141 @@ func TestTypeProvider_NewValue_OneofFields(t *testing.T) {
 	}
 }
 
+func TestTypeProvider_Names(t *testing.T) {
+	typeProvider := NewProvider(&test.TestAllTypes{})
+	typeNames := typeProvider.TypeNames()
+	if !sort.StringsAreSorted(typeNames) ||
+		!containsAll(typeNames, "int", "list",
+			"google.api.tools.expr.test.TestAllTypes",
+			"google.api.tools.expr.test.TestAllTypes.NestedMessage") {
+		t.Errorf("Unexpected type names: %v", typeNames)
+	}
+	enumNames := typeProvider.EnumNames()
+	expected := []string{
+		"google.api.tools.expr.test.GlobalEnum.GAR",
+		"google.api.tools.expr.test.GlobalEnum.GAZ",
+		"google.api.tools.expr.test.GlobalEnum.GOO",
+		"google.api.tools.expr.test.TestAllTypes.NestedEnum.BAR",
+		"google.api.tools.expr.test.TestAllTypes.NestedEnum.BAZ",
+		"google.api.tools.expr.test.TestAllTypes.NestedEnum.FOO"}
+	if !reflect.DeepEqual(enumNames, expected) {
+		t.Errorf("Got enum names %v, wanted %v", enumNames, expected)
+	}
+	identNames := typeProvider.IdentNames()
+	if len(identNames) != len(typeNames)+len(enumNames) ||
+		!sort.StringsAreSorted(identNames) {
+		t.Errorf("Unexpected ident names: %v", identNames)
+	}
+	for _, identName := range identNames {
+		if _, found := typeProvider.FindIdent(identName); !found {
+			t.Errorf("Ident '%s' could not be resolved", identName)
+		}
+	}
+	fieldNames, found := typeProvider.FieldNames("google.api.tools.expr.test.TestAllTypes")
+	if !found || len(fieldNames) == 0 || fieldNames[0] != "single_int32" {
+		t.Errorf("Unexpected field names: %v", fieldNames)
+	}
+	msgType, _ := typeProvider.FindType("google.api.tools.expr.test.TestAllTypes")
+	for _, fieldName := range fieldNames {
+		if _, found := typeProvider.FindFieldType(msgType.GetType(), fieldName); !found {
+			t.Errorf("Field '%s' could not be resolved", fieldName)
+		}
+	}
+	for _, typeName := range []string{"google.protobuf.Timestamp", "acme.Missing"} {
+		if fieldNames, found := typeProvider.FieldNames(typeName); found {
+			t.Errorf("Got fields %v of type '%s', wanted none", fieldNames, typeName)
+		}
+	}
+}
+
+func containsAll(names []string, wanted ...string) bool {
+	found := make(map[string]bool)
+	for _, name := range names {
+		found[name] = true
+	}
+	for _, name := range wanted {
+		if !found[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNewProviderFromFiles(t *testing.T) {
+	fds := &descpb.FileDescriptorSet{
+		File: []*descpb.FileDescriptorProto{{
+			Name:       proto.String("acme/policy.proto"),
+			Package:    proto.String("acme"),
+			Dependency: []string{"google/protobuf/duration.proto"},
+			Syntax:     proto.String("proto3"),
+			MessageType: []*descpb.DescriptorProto{{
+				Name: proto.String("Grant"),
+				Field: []*descpb.FieldDescriptorProto{{
+					Name:   proto.String("roles"),
+					Number: proto.Int32(1),
+					Label:  descpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:   descpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				}, {
+					Name:     proto.String("ttl"),
+					Number:   proto.Int32(2),
+					Label:    descpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".google.protobuf.Duration"),
+				}},
+			}},
+			EnumType: []*descpb.EnumDescriptorProto{{
+				Name: proto.String("Level"),
+				Value: []*descpb.EnumValueDescriptorProto{
+					{Name: proto.String("READ"), Number: proto.Int32(0)},
+					{Name: proto.String("WRITE"), Number: proto.Int32(1)}},
+			}},
+		}}}
+	data, err := proto.Marshal(fds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := ioutil.TempFile("", "policy.fds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	typeProvider, err := NewProviderFromFiles(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := typeProvider.FindType("acme.Grant"); !found {
+		t.Error("Type 'acme.Grant' not found")
+	}
+	grant := &checkedpb.Type{
+		TypeKind: &checkedpb.Type_MessageType{MessageType: "acme.Grant"}}
+	if roles, found := typeProvider.FindFieldType(grant, "roles"); !found ||
+		roles.Type.GetListType().GetElemType().GetPrimitive() != checkedpb.Type_STRING {
+		t.Errorf("Unexpected field type for 'roles': %v", roles)
+	}
+	if ttl, found := typeProvider.FindFieldType(grant, "ttl"); !found ||
+		ttl.Type.GetWellKnown() != checkedpb.Type_DURATION {
+		t.Errorf("Unexpected field type for 'ttl': %v", ttl)
+	}
+	if level := typeProvider.EnumValue("acme.Level.WRITE"); level != Int(1) {
+		t.Errorf("Got '%v', wanted 1", level)
+	}
+	grantVal := typeProvider.NewValue("acme.Grant", map[string]ref.Value{
+		"roles": NewStringList([]string{"reader"})})
+	if roles := grantVal.(traits.Indexer).Get(String("roles")); roles.Equal(
+		NewStringList([]string{"reader"})) != True {
+		t.Errorf("Got roles '%v', wanted [reader]", roles)
+	}
+
+	if _, err := NewProviderFromFiles(os.DevNull + "/missing"); err == nil {
+		t.Error("Got a provider for a missing file")
+	}
+}
+
 func TestTypeProvider_Getters(t *testing.T) {
 	typeProvider := NewProvider(&expr.ParsedExpr{})
 	if sourceInfo := typeProvider.NewValue(
@@ -174,6 +317,81 @@ func TestNativeToValue_Any(t *testing.T) {
 	expectNativeToValue(t, anyValue, NewObject(&pbMessage))
 }
 
+func TestNativeToValueWithLimits(t *testing.T) {
+	pbMessage := &expr.ParsedExpr{
+		Expr: &expr.Expr{Id: 1},
+		SourceInfo: &expr.SourceInfo{
+			LineOffsets: []int32{1, 2, 3}}}
+	anyValue, err := ptypes.MarshalAny(pbMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Allowed any type.
+	allowed := &UnpackLimits{
+		AllowedAnyTypes: []string{"google.api.expr.v1.ParsedExpr"}}
+	if val := NativeToValueWithLimits(anyValue, allowed); val.Equal(NewObject(pbMessage)) != True {
+		t.Errorf("Got %v, wanted the unpacked message", val)
+	}
+	// Disallowed any type.
+	disallowed := &UnpackLimits{
+		AllowedAnyTypes: []string{"google.api.expr.v1.SourceInfo"}}
+	if val := NativeToValueWithLimits(anyValue, disallowed); !IsError(val) {
+		t.Errorf("Got %v, wanted a disallowed type error", val)
+	}
+	// Depth limit on nested messages.
+	shallow := &UnpackLimits{MaxDepth: 1}
+	obj := NativeToValueWithLimits(pbMessage, shallow).(traits.Indexer)
+	if lines := obj.Get(String("source_info")); !IsError(lines) {
+		t.Errorf("Got %v, wanted a depth limit error", lines)
+	}
+	deep := &UnpackLimits{MaxDepth: 2}
+	obj = NativeToValueWithLimits(pbMessage, deep).(traits.Indexer)
+	info := obj.Get(String("source_info")).(traits.Indexer)
+	if lines := info.Get(String("line_offsets")); IsError(lines) {
+		t.Error(lines)
+	}
+	// Depth limit on messages within a list.
+	list := NativeToValueWithLimits([]*expr.Expr{{Id: 1}}, shallow).(traits.Lister)
+	if elem := list.Get(Int(0)).(traits.Indexer); IsError(elem.Get(String("id"))) {
+		t.Error("Failed to select a field within the depth limit")
+	}
+	list = NativeToValueWithLimits([]*expr.ParsedExpr{pbMessage}, shallow).(traits.Lister)
+	elem := list.Get(Int(0)).(traits.Indexer)
+	if val := elem.Get(String("expr")); !IsError(val) {
+		t.Errorf("Got %v, wanted a depth limit error", val)
+	}
+}
+
+func TestNativeToValue_TimeRanges(t *testing.T) {
+	for _, in := range []proto.Message{
+		&dpb.Duration{Seconds: 1, Nanos: -1},
+		&dpb.Duration{Nanos: 1000000000},
+		&dpb.Duration{Seconds: 315576000001},
+		&tpb.Timestamp{Seconds: -62135596801},
+		&tpb.Timestamp{Seconds: 253402300800},
+		&tpb.Timestamp{Nanos: -1},
+	} {
+		if val := NativeToValue(in); !IsError(val) {
+			t.Errorf("Got %v for %v, wanted out of range error", val, in)
+		}
+	}
+	for _, in := range []proto.Message{
+		&dpb.Duration{Seconds: -1, Nanos: -999999999},
+		&tpb.Timestamp{Seconds: -62135596800},
+		&tpb.Timestamp{Seconds: 253402300799, Nanos: 999999999},
+	} {
+		if val := NativeToValue(in); IsError(val) {
+			t.Errorf("Got %v for %v, wanted a value", val, in)
+		}
+	}
+	// Out of range values are also rejected when selected from a message.
+	msg := NewObject(&test.TestAllTypes{
+		SingleDuration: &dpb.Duration{Seconds: 1, Nanos: -1}}).(traits.Indexer)
+	if val := msg.Get(String("single_duration")); !IsError(val) {
+		t.Errorf("Got %v, wanted out of range error", val)
+	}
+}
+
 func TestNativeToValue_Json(t *testing.T) {
 	// Json primitive conversion test.
 	expectNativeToValue(t,
@@ -296,3 +514,93 @@ func BenchmarkTypeProvider_NewValue(b *testing.B) {
 			})
 	}
 }
+
+// dynamicTypeProvider resolves the type 'acme.Dynamic', whose values are maps,
+// and delegates all other types to the provider it embeds.
+type dynamicTypeProvider struct {
+	ref.TypeProvider
+}
+
+func (p *dynamicTypeProvider) FindType(typeName string) (*checkedpb.Type, bool) {
+	if typeName == "acme.Dynamic" {
+		return &checkedpb.Type{TypeKind: &checkedpb.Type_Type{
+			Type: &checkedpb.Type{
+				TypeKind: &checkedpb.Type_MessageType{MessageType: typeName}}}}, true
+	}
+	return nil, false
+}
+
+func (p *dynamicTypeProvider) FieldNames(typeName string) ([]string, bool) {
+	if typeName == "acme.Dynamic" {
+		return []string{"name"}, true
+	}
+	return nil, false
+}
+
+func (p *dynamicTypeProvider) NewValue(typeName string,
+	fields map[string]ref.Value) ref.Value {
+	if typeName != "acme.Dynamic" {
+		return NewErr("unknown type '%s'", typeName)
+	}
+	return NewDynamicMap(map[string]string{"name": typeName})
+}
+
+func (p *dynamicTypeProvider) TypeNames() []string {
+	return []string{"acme.Dynamic", "int"}
+}
+
+func TestCompositeTypeProvider(t *testing.T) {
+	typeProvider := ref.NewCompositeTypeProvider(
+		NewProvider(&expr.ParsedExpr{}),
+		&dynamicTypeProvider{TypeProvider: NewProvider(&test.TestAllTypes{})})
+	for _, typeName := range []string{
+		"google.api.expr.v1.SourceInfo",
+		"google.api.tools.expr.test.TestAllTypes",
+		"acme.Dynamic"} {
+		if _, found := typeProvider.FindType(typeName); !found {
+			t.Errorf("Type '%s' not found", typeName)
+		}
+	}
+	if _, found := typeProvider.FindType("acme.Missing"); found {
+		t.Error("Got a type for 'acme.Missing'")
+	}
+	if level := typeProvider.EnumValue(
+		"google.api.tools.expr.test.GlobalEnum.GAZ"); level != Int(2) {
+		t.Errorf("Got '%v', wanted 2", level)
+	}
+	if !IsError(typeProvider.EnumValue("acme.Missing.VALUE")) {
+		t.Error("Got a value of an unknown enum, wanted an error")
+	}
+	if sourceInfo := typeProvider.NewValue("google.api.expr.v1.SourceInfo",
+		map[string]ref.Value{"location": String("composite")}); IsError(sourceInfo) {
+		t.Error(sourceInfo)
+	}
+	if dynamic := typeProvider.NewValue("acme.Dynamic",
+		map[string]ref.Value{}); IsError(dynamic) {
+		t.Error(dynamic)
+	}
+	if !IsError(typeProvider.NewValue("acme.Missing", map[string]ref.Value{})) {
+		t.Error("Got a value of an unknown type, wanted an error")
+	}
+	if fieldNames, found := typeProvider.FieldNames("acme.Dynamic"); !found ||
+		!reflect.DeepEqual(fieldNames, []string{"name"}) {
+		t.Errorf("Got fields %v of 'acme.Dynamic', wanted [name]", fieldNames)
+	}
+	if fieldNames, found := typeProvider.FieldNames(
+		"google.api.expr.v1.SourceInfo"); !found || len(fieldNames) == 0 {
+		t.Errorf("Got fields %v of 'google.api.expr.v1.SourceInfo'", fieldNames)
+	}
+	typeNames := typeProvider.TypeNames()
+	if !sort.StringsAreSorted(typeNames) ||
+		!containsAll(typeNames, "acme.Dynamic", "int",
+			"google.api.expr.v1.SourceInfo") {
+		t.Errorf("Unexpected type names: %v", typeNames)
+	}
+	seen := make(map[string]bool)
+	for _, typeName := range typeNames {
+		if seen[typeName] {
+			t.Errorf("Got type name '%s' more than once", typeName)
+		}
+		seen[typeName] = true
+	}
+}
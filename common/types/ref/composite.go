@@ -0,0 +1,132 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ref
+
+import (
+	"sort"
+
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// NewCompositeTypeProvider returns a TypeProvider which consults the given
+// providers in order, e.g. to resolve the messages of two proto registries
+// along with the types of a custom provider within the same environment.
+//
+// Types, fields, and identifiers are resolved by the first provider which
+// resolves them, and values are created and fields are listed by the first
+// provider which resolves their type. Names are the sorted union of the names
+// of the providers. Types are registered with the first provider. At least one
+// provider is required.
+func NewCompositeTypeProvider(providers ...TypeProvider) TypeProvider {
+	return &compositeTypeProvider{providers: providers}
+}
+
+type compositeTypeProvider struct {
+	providers []TypeProvider
+}
+
+func (p *compositeTypeProvider) EnumNames() []string {
+	return p.names(TypeProvider.EnumNames)
+}
+
+func (p *compositeTypeProvider) EnumValue(enumName string) Value {
+	for _, provider := range p.providers {
+		if _, found := provider.FindIdent(enumName); found {
+			return provider.EnumValue(enumName)
+		}
+	}
+	// The last provider reports the enum value as unknown.
+	return p.last().EnumValue(enumName)
+}
+
+func (p *compositeTypeProvider) FindIdent(identName string) (Value, bool) {
+	for _, provider := range p.providers {
+		if ident, found := provider.FindIdent(identName); found {
+			return ident, true
+		}
+	}
+	return nil, false
+}
+
+func (p *compositeTypeProvider) IdentNames() []string {
+	return p.names(TypeProvider.IdentNames)
+}
+
+func (p *compositeTypeProvider) FindType(typeName string) (*checkedpb.Type, bool) {
+	for _, provider := range p.providers {
+		if t, found := provider.FindType(typeName); found {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func (p *compositeTypeProvider) FindFieldType(t *checkedpb.Type,
+	fieldName string) (*FieldType, bool) {
+	for _, provider := range p.providers {
+		if fieldType, found := provider.FindFieldType(t, fieldName); found {
+			return fieldType, true
+		}
+	}
+	return nil, false
+}
+
+func (p *compositeTypeProvider) FieldNames(typeName string) ([]string, bool) {
+	for _, provider := range p.providers {
+		if _, found := provider.FindType(typeName); found {
+			return provider.FieldNames(typeName)
+		}
+	}
+	return nil, false
+}
+
+func (p *compositeTypeProvider) NewValue(typeName string,
+	fields map[string]Value) Value {
+	for _, provider := range p.providers {
+		if _, found := provider.FindType(typeName); found {
+			return provider.NewValue(typeName, fields)
+		}
+	}
+	// The last provider reports the type as unknown.
+	return p.last().NewValue(typeName, fields)
+}
+
+func (p *compositeTypeProvider) RegisterType(types ...Type) error {
+	return p.providers[0].RegisterType(types...)
+}
+
+func (p *compositeTypeProvider) TypeNames() []string {
+	return p.names(TypeProvider.TypeNames)
+}
+
+func (p *compositeTypeProvider) last() TypeProvider {
+	return p.providers[len(p.providers)-1]
+}
+
+// names returns the sorted union of the names listed by each provider.
+func (p *compositeTypeProvider) names(list func(TypeProvider) []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, provider := range p.providers {
+		for _, name := range list(provider) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
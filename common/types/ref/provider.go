@@ -21,6 +21,10 @@ import (
 // TypeProvider specifies functions for creating new object instances and for
 // resolving enum values by name.
 type TypeProvider interface {
+	// EnumNames returns the sorted, qualified names of the enum values declared
+	// by the registered types, e.g. 'google.type.DayOfWeek.MONDAY'.
+	EnumNames() []string
+
 	// EnumValue returns the numeric value of the given enum value name.
 	EnumValue(enumName string) Value
 
@@ -28,6 +32,10 @@ type TypeProvider interface {
 	// exists.
 	FindIdent(identName string) (Value, bool)
 
+	// IdentNames returns the sorted names of the type and enum identifiers
+	// which FindIdent resolves.
+	IdentNames() []string
+
 	// FindType looks up the Type given a qualified typeName. Returns false
 	// if not found.
 	//
@@ -40,6 +48,12 @@ type TypeProvider interface {
 	// Used during type-checking only.
 	FindFieldType(t *checkedpb.Type, fieldName string) (*FieldType, bool)
 
+	// FieldNames returns the names of the fields of the named message type,
+	// whose types are resolved with FindFieldType, or false if the type is not
+	// found. Together with TypeNames and EnumNames, it allows tooling such as
+	// editors to list the selections which may follow an expression.
+	FieldNames(typeName string) ([]string, bool)
+
 	// NewValue creates a new type value from a qualified name and a map of
 	// field initializers.
 	NewValue(typeName string, fields map[string]Value) Value
@@ -50,6 +64,10 @@ type TypeProvider interface {
 	// If a type is provided more than once with an alternative definition, the
 	// call will result in an error.
 	RegisterType(types ...Type) error
+
+	// TypeNames returns the sorted, qualified names of the registered types,
+	// including the built-in CEL types such as 'int' and 'list'.
+	TypeNames() []string
 }
 
 // FieldType represents a field's type value and whether that field supports
@@ -0,0 +1,138 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"github.com/google/cel-go/common/types/ref"
+)
+
+var (
+	// RegexType is the type of a compiled regular expression.
+	RegexType = NewTypeValue("regex")
+)
+
+// Regex is a compiled RE2 regular expression used by the re.matches,
+// re.capture, re.captureAll, and re.replace functions.
+//
+// Like Glob values, Regex values are not created by expressions. The
+// interpreter compiles the constant patterns of the regex functions when a
+// program is planned, so that the patterns are not recompiled on each
+// evaluation.
+type Regex struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// NewRegex compiles a regular expression.
+func NewRegex(pattern string) (*Regex, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Regex{pattern: pattern, re: re}, nil
+}
+
+// Match returns whether the regular expression matches any substring of the
+// string value.
+func (r *Regex) Match(val ref.Value) ref.Value {
+	s, ok := val.(String)
+	if !ok {
+		return NewNoSuchOverloadErr()
+	}
+	return Bool(r.re.MatchString(string(s)))
+}
+
+// Capture returns the first capturing group of the leftmost match within the
+// string value, or the whole match when the expression has no groups. It is
+// an error for the expression not to match.
+func (r *Regex) Capture(val ref.Value) ref.Value {
+	s, ok := val.(String)
+	if !ok {
+		return NewNoSuchOverloadErr()
+	}
+	match := r.re.FindStringSubmatch(string(s))
+	if match == nil {
+		return NewErr("no match for regex '%s' in '%s'", r.pattern, s)
+	}
+	return String(r.captured(match))
+}
+
+// CaptureAll returns the first capturing group, or the whole match, of each
+// successive non-overlapping match within the string value.
+func (r *Regex) CaptureAll(val ref.Value) ref.Value {
+	s, ok := val.(String)
+	if !ok {
+		return NewNoSuchOverloadErr()
+	}
+	captures := []string{}
+	for _, match := range r.re.FindAllStringSubmatch(string(s), -1) {
+		captures = append(captures, r.captured(match))
+	}
+	return NewStringList(captures)
+}
+
+// Replace replaces the matches within the string value with the replacement,
+// in which '$1' or '${name}' stands for the text of a capturing group.
+func (r *Regex) Replace(val ref.Value, replacement ref.Value) ref.Value {
+	s, ok := val.(String)
+	repl, replIsString := replacement.(String)
+	if !ok || !replIsString {
+		return NewNoSuchOverloadErr()
+	}
+	return String(r.re.ReplaceAllString(string(s), string(repl)))
+}
+
+func (r *Regex) captured(match []string) string {
+	if len(match) > 1 {
+		return match[1]
+	}
+	return match[0]
+}
+
+func (r *Regex) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	if typeDesc.Kind() == reflect.String {
+		return r.pattern, nil
+	}
+	return nil, fmt.Errorf("type conversion error from regex to '%v'", typeDesc)
+}
+
+func (r *Regex) ConvertToType(typeVal ref.Type) ref.Value {
+	switch typeVal {
+	case StringType:
+		return String(r.pattern)
+	case RegexType:
+		return r
+	case TypeType:
+		return RegexType
+	}
+	return NewErr("type conversion error from '%s' to '%s'", RegexType, typeVal)
+}
+
+func (r *Regex) Equal(other ref.Value) ref.Value {
+	o, ok := other.(*Regex)
+	return Bool(ok && r.pattern == o.pattern)
+}
+
+func (r *Regex) Type() ref.Type {
+	return RegexType
+}
+
+func (r *Regex) Value() interface{} {
+	return r.pattern
+}
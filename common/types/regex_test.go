@@ -0,0 +1,60 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegex(t *testing.T) {
+	re, err := NewRegex(`user-(\d+)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re.Match(String("id: user-12")) != True || re.Match(String("user-x")) != False {
+		t.Error("Got wrong matches")
+	}
+	if captured := re.Capture(String("user-12, user-34")); captured != String("12") {
+		t.Errorf("Got capture %v, wanted '12'", captured)
+	}
+	if !IsError(re.Capture(String("none"))) {
+		t.Error("Got capture without a match, wanted error")
+	}
+	all, _ := re.CaptureAll(String("user-12, user-34")).ConvertToNative(
+		reflect.TypeOf([]string{}))
+	if expected := []string{"12", "34"}; !reflect.DeepEqual(all, expected) {
+		t.Errorf("Got captures %v, wanted %v", all, expected)
+	}
+	if replaced := re.Replace(String("user-12"), String("u$1")); replaced != String("u12") {
+		t.Errorf("Got replacement %v, wanted 'u12'", replaced)
+	}
+	if !IsError(re.Match(Int(1))) || !IsError(re.Replace(String("a"), Int(1))) {
+		t.Error("Got result for non-string arguments, wanted error")
+	}
+}
+
+func TestRegex_Errors(t *testing.T) {
+	if _, err := NewRegex(`(`); err == nil {
+		t.Error("Got nil error for an unclosed group")
+	}
+	re, _ := NewRegex(`a+`)
+	if re.Capture(String("baab")) != String("aa") {
+		t.Errorf("Got capture %v, wanted the whole match", re.Capture(String("baab")))
+	}
+	if re.ConvertToType(StringType) != String("a+") {
+		t.Errorf("Got %v, wanted 'a+'", re.ConvertToType(StringType))
+	}
+}
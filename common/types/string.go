@@ -42,14 +42,14 @@ var (
 
 func (s String) Add(other ref.Value) ref.Value {
 	if StringType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return s + other.(String)
 }
 
 func (s String) Compare(other ref.Value) ref.Value {
 	if StringType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return Int(strings.Compare(string(s), string(other.(String))))
 }
@@ -121,11 +121,11 @@ func (s String) Equal(other ref.Value) ref.Value {
 
 func (s String) Match(pattern ref.Value) ref.Value {
 	if pattern.Type() != StringType {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	matched, err := regexp.MatchString(string(pattern.(String)), string(s))
 	if err != nil {
-		return &Err{err}
+		return &Err{error: err}
 	}
 	return Bool(matched)
 }
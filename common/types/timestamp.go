@@ -23,6 +23,8 @@ import (
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/common/types/traits"
 	"reflect"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -47,20 +49,20 @@ func (t Timestamp) Add(other ref.Value) ref.Value {
 	case DurationType:
 		return other.(Duration).Add(t)
 	}
-	return NewErr("unsupported overload")
+	return newUnsupportedOverloadErr()
 }
 
 func (t Timestamp) Compare(other ref.Value) ref.Value {
 	if TimestampType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	ts1, err := ptypes.Timestamp(t.Timestamp)
 	if err != nil {
-		return &Err{err}
+		return &Err{error: err}
 	}
 	ts2, err := ptypes.Timestamp(other.(Timestamp).Timestamp)
 	if err != nil {
-		return &Err{err}
+		return &Err{error: err}
 	}
 	ts := ts1.Sub(ts2)
 	if ts < 0 {
@@ -110,7 +112,7 @@ func (t Timestamp) Receive(function string, overload string, args []ref.Value) r
 	ts := t.Timestamp
 	tstamp, err := ptypes.Timestamp(ts)
 	if err != nil {
-		return &Err{err}
+		return &Err{error: err}
 	}
 	switch len(args) {
 	case 0:
@@ -118,41 +120,43 @@ func (t Timestamp) Receive(function string, overload string, args []ref.Value) r
 			return f(tstamp)
 		}
 	case 1:
-		if f, found := timestampOneArgOverloads[function]; found {
-			return f(tstamp, args[0])
-		}
+		return t.ReceiveInTimeZone(function, args[0], LoadTimeZone)
 	}
-	return NewErr("unsupported overload")
+	return newUnsupportedOverloadErr()
+}
+
+// ReceiveInTimeZone dispatches a timestamp accessor, such as getHours, which
+// is called with the name of the time zone in which to interpret the
+// timestamp. The time zone is resolved with the given loader.
+func (t Timestamp) ReceiveInTimeZone(function string, tz ref.Value,
+	loader TimeZoneLoader) ref.Value {
+	f, found := timestampZeroArgOverloads[function]
+	if !found || StringType != tz.Type() {
+		return newUnsupportedOverloadErr()
+	}
+	tstamp, err := ptypes.Timestamp(t.Timestamp)
+	if err != nil {
+		return &Err{error: err}
+	}
+	loc, err := loader(string(tz.(String)))
+	if err != nil {
+		return &Err{error: err}
+	}
+	return f(tstamp.In(loc))
 }
 
 func (t Timestamp) Subtract(subtrahend ref.Value) ref.Value {
 	switch subtrahend.Type() {
 	case DurationType:
-		ts, err := ptypes.Timestamp(t.Timestamp)
-		if err != nil {
-			return &Err{err}
-		}
-		dur, err := ptypes.Duration(subtrahend.(Duration).Duration)
-		if err != nil {
-			return &Err{err}
-		}
-		tstamp, err := ptypes.TimestampProto(ts.Add(-dur))
-		if err != nil {
-			return &Err{err}
-		}
-		return Timestamp{tstamp}
+		dur := subtrahend.(Duration)
+		return newTimestamp(t.GetSeconds()-dur.GetSeconds(),
+			int64(t.GetNanos())-int64(dur.GetNanos()))
 	case TimestampType:
-		ts1, err := ptypes.Timestamp(t.Timestamp)
-		if err != nil {
-			return &Err{err}
-		}
-		ts2, err := ptypes.Timestamp(subtrahend.(Timestamp).Timestamp)
-		if err != nil {
-			return &Err{err}
-		}
-		return Duration{ptypes.DurationProto(ts1.Sub(ts2))}
+		ts := subtrahend.(Timestamp)
+		return newDuration(t.GetSeconds()-ts.GetSeconds(),
+			int64(t.GetNanos())-int64(ts.GetNanos()))
 	}
-	return NewErr("unsupported overload")
+	return newUnsupportedOverloadErr()
 }
 
 func (t Timestamp) Type() ref.Type {
@@ -177,21 +181,28 @@ var (
 		overloads.TimeGetMinutes:      timestampGetMinutes,
 		overloads.TimeGetSeconds:      timestampGetSeconds,
 		overloads.TimeGetMilliseconds: timestampGetMilliseconds}
-
-	timestampOneArgOverloads = map[string]func(time.Time, ref.Value) ref.Value{
-		overloads.TimeGetFullYear:     timestampGetFullYearWithTz,
-		overloads.TimeGetMonth:        timestampGetMonthWithTz,
-		overloads.TimeGetDayOfYear:    timestampGetDayOfYearWithTz,
-		overloads.TimeGetDate:         timestampGetDayOfMonthOneBasedWithTz,
-		overloads.TimeGetDayOfMonth:   timestampGetDayOfMonthZeroBasedWithTz,
-		overloads.TimeGetDayOfWeek:    timestampGetDayOfWeekWithTz,
-		overloads.TimeGetHours:        timestampGetHoursWithTz,
-		overloads.TimeGetMinutes:      timestampGetMinutesWithTz,
-		overloads.TimeGetSeconds:      timestampGetSecondsWithTz,
-		overloads.TimeGetMilliseconds: timestampGetMillisecondsWithTz}
 )
 
-type timestampVisitor func(time.Time) ref.Value
+// checkTimestamp returns an error if the timestamp is outside of the range
+// 0001-01-01T00:00:00Z to 9999-12-31T23:59:59.999999999Z, or if its nanos are
+// outside of the range of a second.
+// newTimestamp returns the timestamp of the seconds and nanos since the Unix
+// epoch, which need not be normalized, or an error if it is outside of the
+// range of the CEL spec.
+func newTimestamp(seconds int64, nanos int64) ref.Value {
+	ts, err := ptypes.TimestampProto(time.Unix(seconds, nanos))
+	if err != nil {
+		return &Err{error: err}
+	}
+	return Timestamp{ts}
+}
+
+func checkTimestamp(t *tpb.Timestamp) *Err {
+	if _, err := ptypes.Timestamp(t); err != nil {
+		return &Err{error: err}
+	}
+	return nil
+}
 
 func timestampGetFullYear(t time.Time) ref.Value {
 	return Int(t.Year())
@@ -224,46 +235,67 @@ func timestampGetMilliseconds(t time.Time) ref.Value {
 	return Int(t.Nanosecond() / 1000000)
 }
 
-func timestampGetFullYearWithTz(t time.Time, tz ref.Value) ref.Value {
-	return timeZone(tz, timestampGetFullYear)(t)
-}
-func timestampGetMonthWithTz(t time.Time, tz ref.Value) ref.Value {
-	return timeZone(tz, timestampGetMonth)(t)
-}
-func timestampGetDayOfYearWithTz(t time.Time, tz ref.Value) ref.Value {
-	return timeZone(tz, timestampGetDayOfYear)(t)
-}
-func timestampGetDayOfMonthZeroBasedWithTz(t time.Time, tz ref.Value) ref.Value {
-	return timeZone(tz, timestampGetDayOfMonthZeroBased)(t)
-}
-func timestampGetDayOfMonthOneBasedWithTz(t time.Time, tz ref.Value) ref.Value {
-	return timeZone(tz, timestampGetDayOfMonthOneBased)(t)
-}
-func timestampGetDayOfWeekWithTz(t time.Time, tz ref.Value) ref.Value {
-	return timeZone(tz, timestampGetDayOfWeek)(t)
-}
-func timestampGetHoursWithTz(t time.Time, tz ref.Value) ref.Value {
-	return timeZone(tz, timestampGetHours)(t)
-}
-func timestampGetMinutesWithTz(t time.Time, tz ref.Value) ref.Value {
-	return timeZone(tz, timestampGetMinutes)(t)
-}
-func timestampGetSecondsWithTz(t time.Time, tz ref.Value) ref.Value {
-	return timeZone(tz, timestampGetSeconds)(t)
+// TimeZoneLoader resolves the name of a time zone, such as
+// 'America/Los_Angeles' or '-08:00', to a location.
+type TimeZoneLoader func(name string) (*time.Location, error)
+
+// LoadTimeZone is the TimeZoneLoader used by default. It resolves fixed
+// offsets from UTC as LoadFixedTimeZone does, and resolves other names from
+// the time zone database of the host as described by time.LoadLocation.
+func LoadTimeZone(name string) (*time.Location, error) {
+	if loc, err := LoadFixedTimeZone(name); err == nil {
+		return loc, nil
+	}
+	return time.LoadLocation(name)
 }
-func timestampGetMillisecondsWithTz(t time.Time, tz ref.Value) ref.Value {
-	return timeZone(tz, timestampGetMilliseconds)(t)
+
+// LoadFixedTimeZone is a TimeZoneLoader which only resolves 'UTC' and fixed
+// offsets from UTC of the form '+hh:mm' or '-hh:mm', and so never consults a
+// time zone database.
+func LoadFixedTimeZone(name string) (*time.Location, error) {
+	if name == "UTC" {
+		return time.UTC, nil
+	}
+	if len(name) == 6 && (name[0] == '+' || name[0] == '-') && name[3] == ':' {
+		hours, hrErr := strconv.ParseUint(name[1:3], 10, 8)
+		minutes, minErr := strconv.ParseUint(name[4:6], 10, 8)
+		if hrErr == nil && minErr == nil && hours <= 23 && minutes <= 59 {
+			offset := int(hours*60*60 + minutes*60)
+			if name[0] == '-' {
+				offset = -offset
+			}
+			return time.FixedZone(name, offset), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported time zone: '%s'", name)
 }
 
-func timeZone(tz ref.Value, visitor timestampVisitor) timestampVisitor {
-	return func(t time.Time) ref.Value {
-		if StringType != tz.Type() {
-			return NewErr("unsupported overload")
+// NewTimeZoneDatabase returns a TimeZoneLoader which resolves time zone names
+// from the given zoneinfo data, keyed by name, rather than from the time zone
+// database of the host. Fixed offsets from UTC are also resolved.
+//
+// The zoneinfo of each zone is parsed when the zone is first loaded.
+func NewTimeZoneDatabase(zoneinfo map[string][]byte) TimeZoneLoader {
+	var mutex sync.Mutex
+	zones := make(map[string]*time.Location)
+	return func(name string) (*time.Location, error) {
+		if loc, err := LoadFixedTimeZone(name); err == nil {
+			return loc, nil
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		if loc, found := zones[name]; found {
+			return loc, nil
 		}
-		if loc, err := time.LoadLocation(string(tz.(String))); err == nil {
-			return visitor(t.In(loc))
-		} else {
-			return &Err{err}
+		data, found := zoneinfo[name]
+		if !found {
+			return nil, fmt.Errorf("unknown time zone: '%s'", name)
+		}
+		loc, err := time.LoadLocationFromTZData(name, data)
+		if err != nil {
+			return nil, err
 		}
+		zones[name] = loc
+		return loc, nil
 	}
 }
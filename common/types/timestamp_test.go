@@ -19,7 +19,9 @@ import (
 	tpb "github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/google/cel-go/common/overloads"
 	"github.com/google/cel-go/common/types/ref"
+	"io/ioutil"
 	"testing"
+	"time"
 )
 
 func TestTimestamp_Add(t *testing.T) {
@@ -49,6 +51,20 @@ func TestTimestamp_Subtract(t *testing.T) {
 	}
 }
 
+func TestTimestamp_Subtract_Range(t *testing.T) {
+	min := Timestamp{&tpb.Timestamp{Seconds: -62135596800}}
+	max := Timestamp{&tpb.Timestamp{Seconds: 253402300799}}
+	// The difference is longer than a time.Duration, but within the range of
+	// durations of the CEL spec.
+	expected := Duration{&dpb.Duration{Seconds: 315537897599}}
+	if diff := max.Subtract(min); diff.Equal(expected) != True {
+		t.Errorf("Got '%v', wanted '%v'", diff, expected)
+	}
+	if !IsError(min.Subtract(Duration{&dpb.Duration{Seconds: 1}})) {
+		t.Error("Got timestamp before 0001-01-01, wanted out of range error")
+	}
+}
+
 func TestTimestamp_ReceiveGetHours(t *testing.T) {
 	// 1970-01-01T02:05:05Z
 	ts := Timestamp{&tpb.Timestamp{Seconds: 7506}}
@@ -93,3 +109,60 @@ func TestTimestamp_ReceiveGetSeconds(t *testing.T) {
 		t.Error("Expected 6 seconds, got", secTz)
 	}
 }
+
+func TestTimestamp_ReceiveInTimeZone(t *testing.T) {
+	// 1970-01-01T02:05:06Z
+	ts := Timestamp{&tpb.Timestamp{Seconds: 7506}}
+	hr := ts.ReceiveInTimeZone(overloads.TimeGetHours, String("-07:00"),
+		LoadFixedTimeZone)
+	if !hr.Equal(Int(19)).(Bool) {
+		t.Error("Expected 19 hours, got", hr)
+	}
+	hr = ts.ReceiveInTimeZone(overloads.TimeGetHours, String("America/Phoenix"),
+		LoadFixedTimeZone)
+	if !IsError(hr) {
+		t.Error("Expected unsupported time zone error, got", hr)
+	}
+}
+
+func TestLoadFixedTimeZone(t *testing.T) {
+	for name, offset := range map[string]int{
+		"UTC":    0,
+		"+00:00": 0,
+		"+05:30": 5*60*60 + 30*60,
+		"-08:00": -8 * 60 * 60,
+	} {
+		loc, err := LoadFixedTimeZone(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if _, got := time.Unix(0, 0).In(loc).Zone(); got != offset {
+			t.Errorf("%s: got offset %d, wanted %d", name, got, offset)
+		}
+	}
+	for _, name := range []string{"", "America/Phoenix", "+5:30", "-24:00", "+01:60"} {
+		if _, err := LoadFixedTimeZone(name); err == nil {
+			t.Errorf("%s: got location, wanted error", name)
+		}
+	}
+}
+
+func TestNewTimeZoneDatabase(t *testing.T) {
+	data, err := ioutil.ReadFile("/usr/share/zoneinfo/America/Phoenix")
+	if err != nil {
+		t.Skip("zoneinfo unavailable:", err)
+	}
+	loader := NewTimeZoneDatabase(map[string][]byte{"America/Phoenix": data})
+	// 1970-01-01T02:05:06Z
+	ts := Timestamp{&tpb.Timestamp{Seconds: 7506}}
+	for _, tz := range []string{"America/Phoenix", "-07:00"} {
+		hr := ts.ReceiveInTimeZone(overloads.TimeGetHours, String(tz), loader)
+		if !hr.Equal(Int(19)).(Bool) {
+			t.Errorf("%s: expected 19 hours, got %v", tz, hr)
+		}
+	}
+	if _, err := loader("America/New_York"); err == nil {
+		t.Error("Got location for a zone missing from the database")
+	}
+}
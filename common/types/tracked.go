@@ -0,0 +1,98 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// trackedObj is a protoObj which reports the fields read from it.
+type trackedObj struct {
+	*protoObj
+	path string
+	read func(path string)
+}
+
+// NewTrackedObject returns the object adapted from a proto.Message, e.g. by
+// NativeToValue, wrapped so that the path of each field read from it is
+// reported to the read function, e.g. 'request.auth.claims' for a message at
+// the path 'request'.
+//
+// The messages selected from the object are tracked in turn, and are reported
+// only when they are used as a whole, e.g. when they are compared, rather than
+// when their fields are selected, so that the reported paths are those of a
+// field mask which suffices for the expression. Fields tested for presence
+// are reported as read. Repeated and map fields are reported as a whole.
+//
+// The message is not copied, and the tracked object equals the object it
+// wraps. Values other than messages are returned unchanged.
+func NewTrackedObject(value ref.Value, path string, read func(path string)) ref.Value {
+	if o, isObj := value.(*protoObj); isObj {
+		return &trackedObj{protoObj: o, path: path, read: read}
+	}
+	return value
+}
+
+func (o *trackedObj) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	o.read(o.path)
+	return o.protoObj.ConvertToNative(typeDesc)
+}
+
+func (o *trackedObj) ConvertToType(typeVal ref.Type) ref.Value {
+	if typeVal != TypeType {
+		o.read(o.path)
+	}
+	return o.protoObj.ConvertToType(typeVal)
+}
+
+func (o *trackedObj) Equal(other ref.Value) ref.Value {
+	o.read(o.path)
+	return o.protoObj.Equal(other)
+}
+
+func (o *trackedObj) Get(index ref.Value) ref.Value {
+	value := o.protoObj.Get(index)
+	field, isString := index.(String)
+	if !isString || IsError(value) {
+		return value
+	}
+	path := o.path + "." + string(field)
+	if _, isObj := value.(*protoObj); isObj {
+		return NewTrackedObject(value, path, o.read)
+	}
+	o.read(path)
+	return value
+}
+
+func (o *trackedObj) IsSet(field ref.Value) ref.Value {
+	isSet := o.protoObj.IsSet(field)
+	if name, isString := field.(String); isString && !IsError(isSet) {
+		o.read(o.path + "." + string(name))
+	}
+	return isSet
+}
+
+func (o *trackedObj) Iterator() traits.Iterator {
+	o.read(o.path)
+	return o.protoObj.Iterator()
+}
+
+func (o *trackedObj) Value() interface{} {
+	o.read(o.path)
+	return o.protoObj.Value()
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/test"
+)
+
+func TestTrackedObject(t *testing.T) {
+	msg := &test.TestAllTypes{
+		SingleInt32: 1,
+		NestedType: &test.TestAllTypes_SingleNestedMessage{
+			SingleNestedMessage: &test.TestAllTypes_NestedMessage{Bb: 2}}}
+	var reads []string
+	obj := NewTrackedObject(NewObject(msg), "x", func(path string) {
+		reads = append(reads, path)
+	})
+	indexer := obj.(traits.Indexer)
+	if indexer.Get(String("single_int32")) != Int(1) {
+		t.Error("Got the wrong value of a tracked field")
+	}
+	nested := indexer.Get(String("single_nested_message"))
+	if nested.(traits.Indexer).Get(String("bb")) != Int(2) {
+		t.Error("Got the wrong value of a nested tracked field")
+	}
+	if obj.(traits.FieldTester).IsSet(String("single_string")) != False {
+		t.Error("Got a set field, wanted an unset one")
+	}
+	if !IsError(indexer.Get(String("missing"))) {
+		t.Error("Got a value of a missing field, wanted an error")
+	}
+	if WhichOneof(obj, String("nested_type")) != String("single_nested_message") {
+		t.Error("Got the wrong field of a tracked oneof")
+	}
+	expected := []string{
+		"x.single_int32",
+		"x.single_nested_message.bb",
+		"x.single_string",
+		"x.single_nested_message",
+		"x.single_nested_enum"}
+	if !reflect.DeepEqual(reads, expected) {
+		t.Errorf("Got reads %v, wanted %v", reads, expected)
+	}
+
+	reads = nil
+	nestedObj := NewObject(msg.GetSingleNestedMessage())
+	if nested.Equal(nestedObj) != True || nestedObj.Equal(nested) != True {
+		t.Error("Got a tracked object unequal to its message")
+	}
+	if expected := []string{
+		"x.single_nested_message",
+		"x.single_nested_message"}; !reflect.DeepEqual(reads, expected) {
+		t.Errorf("Got reads %v of a compared message, wanted %v", reads, expected)
+	}
+	if NewTrackedObject(Int(1), "x", nil) != Int(1) {
+		t.Error("Got a tracked value other than an object, wanted it unchanged")
+	}
+}
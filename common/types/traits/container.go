@@ -17,7 +17,13 @@ package traits
 import "github.com/google/cel-go/common/types/ref"
 
 // Container interface which permits containment tests such as 'a in b'.
+//
+// The values of any type which implements Container, and whose ref.Type has
+// the ContainerType trait, may be the right-hand side of 'in', e.g. a set of
+// IP ranges or a bitmap of ids, without first being converted to a list.
 type Container interface {
-	// Contains returns true if the value exists within the object.
+	// Contains returns true if the value exists within the object, false if
+	// it does not, or an error when the value is of a type which may not be
+	// an element of the object.
 	Contains(value ref.Value) ref.Value
 }
@@ -45,4 +45,7 @@ const (
 	SizerType
 	// SubtractorType type support '-' operations.
 	SubtractorType
+	// FieldTesterType types support the detection of field presence via
+	// 'has()' macros.
+	FieldTesterType
 )
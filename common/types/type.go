@@ -16,9 +16,11 @@ package types
 
 import (
 	"fmt"
+	"github.com/google/cel-go/common/overloads"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/common/types/traits"
 	"reflect"
+	"sort"
 )
 
 var (
@@ -47,6 +49,7 @@ func NewTypeValue(name string, traits ...int) *TypeValue {
 // annotated with the traits relevant to all objects.
 func NewObjectTypeValue(name string) *TypeValue {
 	return NewTypeValue(name,
+		traits.FieldTesterType,
 		traits.IndexerType,
 		traits.IterableType)
 }
@@ -89,3 +92,25 @@ func (t *TypeValue) Value() interface{} {
 func (t *TypeValue) String() string {
 	return t.name
 }
+
+// ReceivedFunctions returns the sorted names of the functions which the
+// values of a type implement through the traits.Receiver interface, such as
+// 'getHours' for timestamps, or nil when the type receives no functions.
+func ReceivedFunctions(t ref.Type) []string {
+	var received []string
+	switch t {
+	case TimestampType:
+		for function := range timestampZeroArgOverloads {
+			received = append(received, function)
+		}
+	case DurationType:
+		for function := range durationZeroArgOverloads {
+			received = append(received, function)
+		}
+	case HeaderMapType:
+		received = []string{overloads.HeadersContains, overloads.HeadersGet,
+			overloads.HeadersGetAll}
+	}
+	sort.Strings(received)
+	return received
+}
@@ -42,14 +42,14 @@ const (
 
 func (i Uint) Add(other ref.Value) ref.Value {
 	if UintType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return i + other.(Uint)
 }
 
 func (i Uint) Compare(other ref.Value) ref.Value {
 	if UintType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	if i < other.(Uint) {
 		return IntNegOne
@@ -63,6 +63,8 @@ func (i Uint) Compare(other ref.Value) ref.Value {
 func (i Uint) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
 	value := i.Value()
 	switch typeDesc.Kind() {
+	case reflect.Uint:
+		return uint(value.(uint64)), nil
 	case reflect.Uint32:
 		return uint32(value.(uint64)), nil
 	case reflect.Uint64:
@@ -107,7 +109,7 @@ func (i Uint) ConvertToType(typeVal ref.Type) ref.Value {
 
 func (i Uint) Divide(other ref.Value) ref.Value {
 	if UintType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	otherUint := other.(Uint)
 	if otherUint == uintZero {
@@ -123,7 +125,7 @@ func (i Uint) Equal(other ref.Value) ref.Value {
 
 func (i Uint) Modulo(other ref.Value) ref.Value {
 	if UintType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	otherUint := other.(Uint)
 	if otherUint == uintZero {
@@ -134,14 +136,14 @@ func (i Uint) Modulo(other ref.Value) ref.Value {
 
 func (i Uint) Multiply(other ref.Value) ref.Value {
 	if UintType != other.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return i * other.(Uint)
 }
 
 func (i Uint) Subtract(subtrahend ref.Value) ref.Value {
 	if UintType != subtrahend.Type() {
-		return NewErr("unsupported overload")
+		return newUnsupportedOverloadErr()
 	}
 	return i - subtrahend.(Uint)
 }
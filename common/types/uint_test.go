@@ -53,6 +53,15 @@ func TestUint_ConvertToNative_Error(t *testing.T) {
 	}
 }
 
+func TestUint_ConvertToNative_Uint(t *testing.T) {
+	val, err := Uint(42).ConvertToNative(reflect.TypeOf(uint(0)))
+	if err != nil {
+		t.Error(err)
+	} else if val.(uint) != 42 {
+		t.Errorf("Got '%v', expected 42", val)
+	}
+}
+
 func TestUint_ConvertToNative_Json(t *testing.T) {
 	val, err := Uint(10000).ConvertToNative(jsonValueType)
 	if err != nil {
@@ -21,6 +21,10 @@ import (
 
 // Unknown type implementation which collects expression ids which caused the
 // current value to become unknown.
+//
+// The interpreter produces UnknownSet values, which also record the attributes
+// which were unknown. Unknown values are merged into an UnknownSet by
+// MergeUnknowns.
 type Unknown []int64
 
 var (
@@ -0,0 +1,170 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// UnknownSet is an unknown value which collects the ids of the expressions
+// which caused it to become unknown, corresponding to the UnknownSet of the
+// CEL ExprValue, along with the path of each attribute whose value was
+// unknown, e.g. 'request.auth.claims', by the id of the expression which
+// referenced it.
+//
+// An UnknownSet has the UnknownType, so IsUnknown reports true for it.
+type UnknownSet struct {
+	ids        []int64
+	attributes map[int64]string
+}
+
+// NewUnknownSet returns an UnknownSet of the expression ids, such as those of
+// the expressions which were not evaluated, with no attributes.
+func NewUnknownSet(ids ...int64) *UnknownSet {
+	u := &UnknownSet{attributes: make(map[int64]string)}
+	for _, id := range ids {
+		u.add(id)
+	}
+	return u
+}
+
+// NewUnknownAttribute returns an UnknownSet for the attribute of the path
+// which was referenced by the expression id and was not provided.
+func NewUnknownAttribute(id int64, path string) *UnknownSet {
+	u := NewUnknownSet(id)
+	u.attributes[id] = path
+	return u
+}
+
+// MergeUnknowns combines the unknown values, whether Unknown or UnknownSet,
+// into a single UnknownSet holding their expression ids, in order and without
+// duplicates, and their attributes. Known values are ignored, and the result
+// is nil when none of the values is unknown.
+func MergeUnknowns(vals ...ref.Value) ref.Value {
+	var merged *UnknownSet
+	for _, val := range vals {
+		switch v := val.(type) {
+		case Unknown:
+			if merged == nil {
+				merged = NewUnknownSet()
+			}
+			for _, id := range v {
+				merged.add(id)
+			}
+		case *UnknownSet:
+			if merged == nil {
+				merged = NewUnknownSet()
+			}
+			for _, id := range v.ids {
+				merged.add(id)
+			}
+			for id, path := range v.attributes {
+				merged.attributes[id] = path
+			}
+		}
+	}
+	if merged == nil {
+		return nil
+	}
+	return merged
+}
+
+func (u *UnknownSet) add(id int64) {
+	for _, existing := range u.ids {
+		if existing == id {
+			return
+		}
+	}
+	u.ids = append(u.ids, id)
+}
+
+// Ids returns the ids of the expressions which caused the value to become
+// unknown.
+func (u *UnknownSet) Ids() []int64 {
+	return append([]int64{}, u.ids...)
+}
+
+// Attribute returns the path of the unknown attribute referenced by the
+// expression id, if any.
+func (u *UnknownSet) Attribute(id int64) (string, bool) {
+	path, found := u.attributes[id]
+	return path, found
+}
+
+// Attributes returns the sorted, distinct paths of the attributes which were
+// unknown, i.e. the inputs which must be provided for the value to be known.
+func (u *UnknownSet) Attributes() []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, path := range u.attributes {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Select returns the UnknownSet of the selection of a field, by the
+// expression id, from this unknown value. The attribute referenced by the
+// first expression of the set, i.e. the operand of the selection, is
+// qualified by the field and attributed to the selection.
+func (u *UnknownSet) Select(id int64, field string) *UnknownSet {
+	selected := NewUnknownSet(append([]int64{id}, u.ids...)...)
+	for attrId, path := range u.attributes {
+		selected.attributes[attrId] = path
+	}
+	if len(u.ids) != 0 {
+		if path, found := u.attributes[u.ids[0]]; found {
+			delete(selected.attributes, u.ids[0])
+			selected.attributes[id] = path + "." + field
+		}
+	}
+	return selected
+}
+
+func (u *UnknownSet) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	return u.Value(), nil
+}
+
+func (u *UnknownSet) ConvertToType(typeVal ref.Type) ref.Value {
+	return u
+}
+
+func (u *UnknownSet) Equal(other ref.Value) ref.Value {
+	return u
+}
+
+func (u *UnknownSet) String() string {
+	if len(u.attributes) == 0 {
+		return fmt.Sprintf("unknown %v", u.ids)
+	}
+	return fmt.Sprintf("unknown %v (%s)", u.ids, strings.Join(u.Attributes(), ", "))
+}
+
+func (u *UnknownSet) Type() ref.Type {
+	return UnknownType
+}
+
+// Value returns the expression ids of the set, as for Unknown.
+func (u *UnknownSet) Value() interface{} {
+	return u.Ids()
+}
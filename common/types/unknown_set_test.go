@@ -0,0 +1,49 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeUnknowns(t *testing.T) {
+	if MergeUnknowns(True, NewErr("error")) != nil {
+		t.Error("Got an unknown when merging known values")
+	}
+	request := NewUnknownAttribute(1, "request").Select(2, "auth").Select(3, "claims")
+	merged := MergeUnknowns(request, Int(1), Unknown{4, 2}, NewUnknownAttribute(5, "x"),
+		NewUnknownAttribute(6, "x"))
+	if !IsUnknown(merged) {
+		t.Fatalf("Got '%v', wanted an unknown", merged)
+	}
+	unknown := merged.(*UnknownSet)
+	if ids := unknown.Ids(); !reflect.DeepEqual(ids, []int64{3, 2, 1, 4, 5, 6}) {
+		t.Errorf("Got ids %v, wanted the ids of each unknown in order", ids)
+	}
+	if attrs := unknown.Attributes(); !reflect.DeepEqual(attrs,
+		[]string{"request.auth.claims", "x"}) {
+		t.Errorf("Got attributes %v, wanted 'request.auth.claims' and 'x'", attrs)
+	}
+	if path, found := unknown.Attribute(3); !found || path != "request.auth.claims" {
+		t.Errorf("Got attribute '%s' of the selection, wanted 'request.auth.claims'", path)
+	}
+	if _, found := unknown.Attribute(1); found {
+		t.Error("Got an attribute of the qualified identifier")
+	}
+	if unknown.String() != "unknown [3 2 1 4 5 6] (request.auth.claims, x)" {
+		t.Errorf("Got '%v', wanted the ids and attributes", unknown)
+	}
+}
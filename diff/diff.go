@@ -0,0 +1,514 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff compares two versions of an expression at the level of their
+// abstract syntax trees, and summarizes the changes for review, e.g.
+//
+//     condition on request.size relaxed from < 1024 to < 4096
+//
+// Both expressions are normalized before they are compared: conjunctions and
+// disjunctions are flattened into unordered lists of terms, and comparisons
+// with a constant on the left are rewritten with the constant on the right.
+// Terms which are common to both versions are not reported. The remaining
+// terms are paired up where possible, so that changes to the bounds of
+// numeric comparisons and to the values of 'in' lists are described as
+// relaxing or tightening the expression.
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/debug"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/parser"
+)
+
+// ChangeKind classifies a Change.
+type ChangeKind int
+
+const (
+	// Added is a term which only appears in the new expression.
+	Added ChangeKind = iota
+	// Removed is a term which only appears in the old expression.
+	Removed
+	// Relaxed is a changed term which makes the expression true for more
+	// inputs.
+	Relaxed
+	// Tightened is a changed term which makes the expression true for fewer
+	// inputs.
+	Tightened
+	// Modified is a changed term whose effect is not known.
+	Modified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Relaxed:
+		return "relaxed"
+	case Tightened:
+		return "tightened"
+	case Modified:
+		return "modified"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change describes a difference between two expressions.
+type Change struct {
+	Kind ChangeKind
+	// Before and After are the text of the changed term in the old and the
+	// new expression, and are empty when the term is absent.
+	Before string
+	After  string
+	// Summary is a human-readable description of the change.
+	Summary string
+}
+
+func (c *Change) String() string {
+	return c.Summary
+}
+
+// Text parses two expressions and returns the changes between them.
+func Text(before, after string) ([]*Change, error) {
+	b, err := parse(before, "<before>")
+	if err != nil {
+		return nil, err
+	}
+	a, err := parse(after, "<after>")
+	if err != nil {
+		return nil, err
+	}
+	return Diff(b, a), nil
+}
+
+func parse(text, description string) (*ast.Expr, error) {
+	parsed, errs := parser.ParseAst(common.NewStringSource(text, description),
+		parser.AllMacros)
+	if len(errs.GetErrors()) != 0 {
+		return nil, fmt.Errorf("%s", errs.ToDisplayString())
+	}
+	return parsed.Expr, nil
+}
+
+// Diff returns the changes between two expressions, ordered by the position
+// of the changed terms within the old expression, followed by the terms
+// which were added to the new expression.
+func Diff(before, after *ast.Expr) []*Change {
+	before, after = normalize(before), normalize(after)
+	op, beforeTerms, afterTerms := terms(before, after)
+	if beforeTerms == nil {
+		b, a := debug.Format(before), debug.Format(after)
+		if b == a {
+			return nil
+		}
+		return []*Change{{Kind: Modified, Before: b, After: a,
+			Summary: fmt.Sprintf("expression changed from %s to %s", b, a)}}
+	}
+
+	// Terms which appear in both expressions are unchanged.
+	removed := unmatched(beforeTerms, afterTerms)
+	added := unmatched(afterTerms, beforeTerms)
+
+	noun := "condition"
+	if op == operators.LogicalOr {
+		noun = "alternative"
+	}
+	paired := make(map[*ast.Expr]bool)
+	var changes []*Change
+	var unpaired []*ast.Expr
+	for _, b := range removed {
+		var change *Change
+		for _, a := range added {
+			if paired[a] {
+				continue
+			}
+			if change = compareTerms(b, a); change != nil {
+				paired[a] = true
+				break
+			}
+		}
+		if change == nil {
+			unpaired = append(unpaired, b)
+			continue
+		}
+		changes = append(changes, change)
+	}
+	var unpairedAdded []*ast.Expr
+	for _, a := range added {
+		if !paired[a] {
+			unpairedAdded = append(unpairedAdded, a)
+		}
+	}
+	// A single replaced term is reported as a modification.
+	if len(unpaired) == 1 && len(unpairedAdded) == 1 {
+		b, a := debug.Format(unpaired[0]), debug.Format(unpairedAdded[0])
+		return append(changes, &Change{Kind: Modified, Before: b, After: a,
+			Summary: fmt.Sprintf("%s %s changed to %s", noun, b, a)})
+	}
+	for _, b := range unpaired {
+		text := debug.Format(b)
+		changes = append(changes, &Change{Kind: Removed, Before: text,
+			Summary: fmt.Sprintf("%s %s removed", noun, text)})
+	}
+	for _, a := range unpairedAdded {
+		text := debug.Format(a)
+		changes = append(changes, &Change{Kind: Added, After: text,
+			Summary: fmt.Sprintf("%s %s added", noun, text)})
+	}
+	return changes
+}
+
+// terms returns the logical operator joining the terms of both expressions,
+// and their terms. The terms are nil when the expressions are joined by
+// different operators.
+func terms(before, after *ast.Expr) (string, []*ast.Expr, []*ast.Expr) {
+	op := logicalOp(before)
+	if op == "" {
+		op = logicalOp(after)
+	} else if afterOp := logicalOp(after); afterOp != "" && afterOp != op {
+		return "", nil, nil
+	}
+	if op == "" {
+		op = operators.LogicalAnd
+	}
+	return op, flatten(before, op, nil), flatten(after, op, nil)
+}
+
+// unmatched returns the terms of x which are not terms of y, counting
+// duplicate terms separately.
+func unmatched(x, y []*ast.Expr) []*ast.Expr {
+	counts := make(map[string]int)
+	for _, term := range y {
+		counts[debug.Format(term)]++
+	}
+	var terms []*ast.Expr
+	for _, term := range x {
+		if key := debug.Format(term); counts[key] > 0 {
+			counts[key]--
+		} else {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+func logicalOp(e *ast.Expr) string {
+	if call, isCall := e.Kind.(*ast.Call); isCall && call.Target == nil &&
+		(call.Function == operators.LogicalAnd || call.Function == operators.LogicalOr) {
+		return call.Function
+	}
+	return ""
+}
+
+func flatten(e *ast.Expr, op string, terms []*ast.Expr) []*ast.Expr {
+	if logicalOp(e) != op {
+		return append(terms, e)
+	}
+	for _, arg := range e.Kind.(*ast.Call).Args {
+		terms = flatten(arg, op, terms)
+	}
+	return terms
+}
+
+var (
+	// Comparison operators, mapped to the operator with swapped operands.
+	swappedComparisons = map[string]string{
+		operators.Equals:        operators.Equals,
+		operators.NotEquals:     operators.NotEquals,
+		operators.Less:          operators.Greater,
+		operators.LessEquals:    operators.GreaterEquals,
+		operators.Greater:       operators.Less,
+		operators.GreaterEquals: operators.LessEquals,
+	}
+
+	// Comparison operators, mapped to their symbols.
+	comparisonSymbols = map[string]string{
+		operators.Equals:        "==",
+		operators.NotEquals:     "!=",
+		operators.Less:          "<",
+		operators.LessEquals:    "<=",
+		operators.Greater:       ">",
+		operators.GreaterEquals: ">=",
+	}
+
+	// Comparison operators, mapped to the operator of their negation.
+	negatedComparisons = map[string]string{
+		operators.Equals:        operators.NotEquals,
+		operators.NotEquals:     operators.Equals,
+		operators.Less:          operators.GreaterEquals,
+		operators.LessEquals:    operators.Greater,
+		operators.Greater:       operators.LessEquals,
+		operators.GreaterEquals: operators.Less,
+	}
+)
+
+// normalize returns a copy of the expression in which comparisons have their
+// constant operand on the right.
+func normalize(e *ast.Expr) *ast.Expr {
+	call, isCall := e.Kind.(*ast.Call)
+	if !isCall {
+		return e
+	}
+	args := make([]*ast.Expr, len(call.Args))
+	for i, arg := range call.Args {
+		args[i] = normalize(arg)
+	}
+	function := call.Function
+	if swapped, found := swappedComparisons[function]; found && call.Target == nil &&
+		len(args) == 2 && isLiteral(args[0]) && !isLiteral(args[1]) {
+		function = swapped
+		args[0], args[1] = args[1], args[0]
+	}
+	target := call.Target
+	if target != nil {
+		target = normalize(target)
+	}
+	return &ast.Expr{Id: e.Id,
+		Kind: &ast.Call{Target: target, Function: function, Args: args}}
+}
+
+func isLiteral(e *ast.Expr) bool {
+	_, isLit := e.Kind.(*ast.Literal)
+	return isLit
+}
+
+// compareTerms returns the change from one term to another when both test
+// the same operand, and nil otherwise.
+func compareTerms(before, after *ast.Expr) *Change {
+	if b, a := boundOf(before), boundOf(after); b != nil && a != nil {
+		if b.subject != a.subject {
+			return nil
+		}
+		change := &Change{Kind: Modified, Before: debug.Format(before), After: debug.Format(after)}
+		if kind, comparable := compareBounds(b, a); comparable {
+			change.Kind = kind
+		}
+		change.Summary = fmt.Sprintf("condition on %s %s from %s to %s",
+			b.subject, change.Kind, b, a)
+		return change
+	}
+	if b, a := valuesOf(before), valuesOf(after); b != nil && a != nil {
+		if b.subject != a.subject || b.negated != a.negated {
+			return nil
+		}
+		return compareValues(before, after, b, a)
+	}
+	return nil
+}
+
+// bound is a comparison of an operand with a literal.
+type bound struct {
+	subject string
+	op      string
+	value   *ast.Literal
+}
+
+func (b *bound) String() string {
+	return comparisonSymbols[b.op] + " " + debug.Format(&ast.Expr{Kind: b.value})
+}
+
+// boundOf returns the bound tested by a term, with negations applied to the
+// comparison operator, or nil if the term is not a comparison.
+func boundOf(e *ast.Expr) *bound {
+	negated := false
+	call, isCall := e.Kind.(*ast.Call)
+	for isCall && call.Function == operators.LogicalNot && len(call.Args) == 1 {
+		negated = !negated
+		call, isCall = call.Args[0].Kind.(*ast.Call)
+	}
+	if !isCall || call.Target != nil || len(call.Args) != 2 {
+		return nil
+	}
+	op := call.Function
+	if _, found := swappedComparisons[op]; !found {
+		return nil
+	}
+	lit, isLit := call.Args[1].Kind.(*ast.Literal)
+	if !isLit {
+		return nil
+	}
+	if negated {
+		op = negatedComparisons[op]
+	}
+	return &bound{subject: debug.Format(call.Args[0]), op: op, value: lit}
+}
+
+// compareBounds returns whether the new bound accepts more or fewer values
+// than the old bound. Only numeric bounds in the same direction are
+// comparable.
+func compareBounds(before, after *bound) (ChangeKind, bool) {
+	upper := func(op string) bool {
+		return op == operators.Less || op == operators.LessEquals
+	}
+	lower := func(op string) bool {
+		return op == operators.Greater || op == operators.GreaterEquals
+	}
+	if !(upper(before.op) && upper(after.op)) && !(lower(before.op) && lower(after.op)) {
+		return Modified, false
+	}
+	cmp, comparable := compareNumbers(before.value.Value, after.value.Value)
+	if !comparable {
+		return Modified, false
+	}
+	if cmp == 0 {
+		inclusive := func(op string) bool {
+			return op == operators.LessEquals || op == operators.GreaterEquals
+		}
+		if inclusive(before.op) == inclusive(after.op) {
+			return Modified, false
+		}
+		if inclusive(after.op) {
+			return Relaxed, true
+		}
+		return Tightened, true
+	}
+	// An upper bound is relaxed by increasing it, and a lower bound by
+	// decreasing it.
+	if (cmp < 0) == upper(before.op) {
+		return Relaxed, true
+	}
+	return Tightened, true
+}
+
+// compareNumbers compares two numeric literal values, returning -1, 0, or 1
+// when the first value is less than, equal to, or greater than the second.
+func compareNumbers(x, y interface{}) (int, bool) {
+	switch xv := x.(type) {
+	case int64:
+		if yv, isInt := y.(int64); isInt {
+			return sign(xv < yv, xv > yv), true
+		}
+	case uint64:
+		if yv, isUint := y.(uint64); isUint {
+			return sign(xv < yv, xv > yv), true
+		}
+	}
+	xf, xNum := toFloat(x)
+	yf, yNum := toFloat(y)
+	if !xNum || !yNum {
+		return 0, false
+	}
+	return sign(xf < yf, xf > yf), true
+}
+
+func sign(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// values is a test of membership of an operand within a list of literals.
+type values struct {
+	subject string
+	negated bool
+	elems   []string
+}
+
+// valuesOf returns the values tested by an 'in' term, or nil if the term is
+// not a test of membership in a list of literals.
+func valuesOf(e *ast.Expr) *values {
+	negated := false
+	call, isCall := e.Kind.(*ast.Call)
+	for isCall && call.Function == operators.LogicalNot && len(call.Args) == 1 {
+		negated = !negated
+		call, isCall = call.Args[0].Kind.(*ast.Call)
+	}
+	if !isCall || call.Function != operators.In || call.Target != nil ||
+		len(call.Args) != 2 {
+		return nil
+	}
+	list, isList := call.Args[1].Kind.(*ast.CreateList)
+	if !isList {
+		return nil
+	}
+	elems := make([]string, len(list.Elements))
+	for i, elem := range list.Elements {
+		if !isLiteral(elem) {
+			return nil
+		}
+		elems[i] = debug.Format(elem)
+	}
+	return &values{subject: debug.Format(call.Args[0]), negated: negated, elems: elems}
+}
+
+func compareValues(before, after *ast.Expr, b, a *values) *Change {
+	extended := difference(a.elems, b.elems)
+	reduced := difference(b.elems, a.elems)
+	change := &Change{Kind: Modified, Before: debug.Format(before), After: debug.Format(after)}
+	var parts []string
+	if len(extended) != 0 {
+		parts = append(parts, "extended with "+strings.Join(extended, ", "))
+	}
+	if len(reduced) != 0 {
+		parts = append(parts, "reduced by "+strings.Join(reduced, ", "))
+	}
+	switch {
+	case len(parts) == 0:
+		// Only the order of the values changed.
+		parts = append(parts, "reordered")
+	case len(reduced) == 0:
+		change.Kind = Relaxed
+	case len(extended) == 0:
+		change.Kind = Tightened
+	}
+	// Excluding more values tightens a negated test.
+	if b.negated && change.Kind != Modified {
+		change.Kind = Relaxed + Tightened - change.Kind
+	}
+	noun := "values"
+	if b.negated {
+		noun = "excluded values"
+	}
+	change.Summary = fmt.Sprintf("%s of %s %s (%s)",
+		noun, b.subject, strings.Join(parts, " and "), change.Kind)
+	return change
+}
+
+// difference returns the elements of x which are not elements of y.
+func difference(x, y []string) []string {
+	in := make(map[string]bool, len(y))
+	for _, elem := range y {
+		in[elem] = true
+	}
+	var diff []string
+	for _, elem := range x {
+		if !in[elem] {
+			diff = append(diff, elem)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	var tests = []struct {
+		before  string
+		after   string
+		kinds   []ChangeKind
+		summary []string
+	}{
+		{before: `request.size < 1024 && user == "admin"`,
+			after:   `user == "admin" && request.size < 4096`,
+			kinds:   []ChangeKind{Relaxed},
+			summary: []string{`condition on request.size relaxed from < 1024 to < 4096`}},
+		{before: `1024 > request.size`,
+			after:   `request.size <= 512`,
+			kinds:   []ChangeKind{Tightened},
+			summary: []string{`condition on request.size tightened from < 1024 to <= 512`}},
+		{before: `request.size >= 10`,
+			after:   `request.size > 10`,
+			kinds:   []ChangeKind{Tightened},
+			summary: []string{`condition on request.size tightened from >= 10 to > 10`}},
+		{before: `!(request.size > 10)`,
+			after:   `!(request.size > 20)`,
+			kinds:   []ChangeKind{Relaxed},
+			summary: []string{`condition on request.size relaxed from <= 10 to <= 20`}},
+		{before: `request.size < 10`,
+			after:   `request.size > 10`,
+			kinds:   []ChangeKind{Modified},
+			summary: []string{`condition on request.size modified from < 10 to > 10`}},
+		{before: `a && b`,
+			after:   `a && b && c`,
+			kinds:   []ChangeKind{Added},
+			summary: []string{`condition c added`}},
+		{before: `a || b || c`,
+			after:   `a || c`,
+			kinds:   []ChangeKind{Removed},
+			summary: []string{`alternative b removed`}},
+		{before: `a && b.startsWith("x")`,
+			after:   `a && b.endsWith("x")`,
+			kinds:   []ChangeKind{Modified},
+			summary: []string{`condition b.startsWith("x") changed to b.endsWith("x")`}},
+		{before: `method in ["GET", "HEAD"] && a`,
+			after:   `a && method in ["GET", "HEAD", "OPTIONS"]`,
+			kinds:   []ChangeKind{Relaxed},
+			summary: []string{`values of method extended with "OPTIONS" (relaxed)`}},
+		{before: `!(user in ["root", "admin"])`,
+			after:   `!(user in ["root"])`,
+			kinds:   []ChangeKind{Relaxed},
+			summary: []string{`excluded values of user reduced by "admin" (relaxed)`}},
+		{before: `a && b`,
+			after:   `a || b`,
+			kinds:   []ChangeKind{Modified},
+			summary: []string{`expression changed from a && b to a || b`}},
+		{before: `(a + 1) * 2 < x && y`,
+			after:   `(a + 1) * 2 < x`,
+			kinds:   []ChangeKind{Removed},
+			summary: []string{`condition y removed`}},
+		{before: `x < 1 && y`,
+			after: `y && x < 1`},
+	}
+	for _, tst := range tests {
+		changes, err := Text(tst.before, tst.after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var kinds []ChangeKind
+		var summary []string
+		for _, change := range changes {
+			kinds = append(kinds, change.Kind)
+			summary = append(summary, change.Summary)
+		}
+		if !reflect.DeepEqual(kinds, tst.kinds) || !reflect.DeepEqual(summary, tst.summary) {
+			t.Errorf("%s -> %s: got %v %q, wanted %v %q",
+				tst.before, tst.after, kinds, summary, tst.kinds, tst.summary)
+		}
+	}
+}
+
+func TestText_ParseError(t *testing.T) {
+	if _, err := Text("a &&", "a"); err == nil {
+		t.Error("Got nil error, wanted a parse error")
+	}
+}
@@ -15,6 +15,10 @@
 package interpreter
 
 import (
+	"context"
+	"encoding/json"
+	"sync"
+
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 )
@@ -38,6 +42,41 @@ type Activation interface {
 	Parent() Activation
 }
 
+// QualifiedResolver is an optional interface of an Activation which resolves a
+// chain of field selections from a variable in one call, e.g. so that a data
+// source resolves 'resource.labels.env' by reading a single label rather than
+// by supplying the whole resource.
+//
+// Chains of selections are resolved as a whole by programs evaluated as trees,
+// see TreeEvaluation, and by programs whose instructions are fused, see
+// FuseInstructions, when the values of the variable and of the intermediate
+// selections are not used elsewhere in the expression. The values of the
+// intermediate selections are then absent from the EvalState.
+type QualifiedResolver interface {
+	// ResolveQualified returns the value selected by the path from the
+	// variable with the given name, or false if the path could not be
+	// resolved, in which case the variable is resolved with ResolveName and
+	// the fields are selected from its value.
+	ResolveQualified(name string, path []Qualifier) (ref.Value, bool)
+}
+
+// Qualifier is a field selected from a variable, e.g. 'labels' or 'env' of
+// 'resource.labels.env'.
+type Qualifier struct {
+	// Field is the name of the selected field.
+	Field string
+}
+
+// resolveQualified resolves the path from the variable when the activation is
+// a QualifiedResolver.
+func resolveQualified(activation Activation, name string,
+	path []Qualifier) (ref.Value, bool) {
+	if resolver, isResolver := activation.(QualifiedResolver); isResolver {
+		return resolver.ResolveQualified(name, path)
+	}
+	return nil, false
+}
+
 // NewActivation returns an activation based on a map-based binding where the
 // map keys are expected to be qualified names used with ResolveName calls.
 // TODO: supply references from checkedpb.proto.
@@ -45,15 +84,134 @@ func NewActivation(bindings map[string]interface{}) Activation {
 	return &mapActivation{bindings: bindings}
 }
 
+// NewActivationWithLimits returns an activation based on a map-based binding
+// which adapts the bound values subject to the given limits on the messages
+// which may be unpacked from Any values and the depth of the messages which
+// may be traversed.
+func NewActivationWithLimits(bindings map[string]interface{},
+	limits *types.UnpackLimits) Activation {
+	return &mapActivation{bindings: bindings, limits: limits}
+}
+
+// NewMemoizingActivation returns an activation based on a map-based binding,
+// as for NewActivation, which resolves each name at most once and caches the
+// resolved value, so that the suppliers of lazily bound values, such as a
+// LazyBinding, are called once however often their variables are
+// referenced.
+//
+// The values are cached for the lifetime of the activation, so an activation
+// should be created for each evaluation. The activation is safe for
+// concurrent use.
+func NewMemoizingActivation(bindings map[string]interface{}) Activation {
+	return &memoActivation{
+		mapActivation: mapActivation{bindings: bindings},
+		values:        make(map[string]ref.Value)}
+}
+
+// NewJSONActivation returns an activation whose variables are bound to JSON
+// documents, e.g. the parts of a request received as JSON, which are decoded
+// as by types.DecodeJSON when their variables are first resolved, so that
+// large integers are not rounded as they are by a google.protobuf.Struct. A
+// document which is not valid JSON resolves to an error.
+//
+// The decoded values are cached, as by NewMemoizingActivation.
+func NewJSONActivation(bindings map[string]json.RawMessage) Activation {
+	values := make(map[string]interface{}, len(bindings))
+	for name, doc := range bindings {
+		doc := doc
+		values[name] = func() ref.Value {
+			return types.DecodeJSON(doc)
+		}
+	}
+	return NewMemoizingActivation(values)
+}
+
+// NewTrackingActivation returns an activation based on a map-based binding,
+// as for NewActivation, which records the variables resolved from it and the
+// fields read from the protobuf messages bound to them as the expression is
+// evaluated, without copying the messages, see types.NewTrackedObject.
+//
+// The reads are recorded for the lifetime of the activation, so an activation
+// should be created for each evaluation. The activation is safe for
+// concurrent use.
+func NewTrackingActivation(bindings map[string]interface{}) TrackingActivation {
+	return &trackingActivation{
+		mapActivation: mapActivation{bindings: bindings},
+		variables:     make(map[string]bool),
+		fields:        make(map[string]bool)}
+}
+
+// TrackingActivation is an Activation which records what an evaluation read
+// from it.
+type TrackingActivation interface {
+	Activation
+
+	// Variables returns the sorted names of the variables resolved from the
+	// activation.
+	Variables() []string
+
+	// Fields returns the sorted paths of the fields read from the messages
+	// bound to the variables, qualified by the variable names, e.g.
+	// 'request.auth.claims'. The path of a message field, or the name of a
+	// variable, is included when the message was used as a whole, e.g. when
+	// it was compared.
+	Fields() []string
+}
+
+// trackingActivation which implements TrackingActivation and tracks the
+// messages resolved from a mapActivation.
+type trackingActivation struct {
+	mapActivation
+	mutex     sync.Mutex
+	variables map[string]bool
+	fields    map[string]bool
+}
+
+func (a *trackingActivation) ResolveName(name string) (ref.Value, bool) {
+	value, found := a.mapActivation.ResolveName(name)
+	if !found {
+		return nil, false
+	}
+	a.mutex.Lock()
+	a.variables[name] = true
+	a.mutex.Unlock()
+	return types.NewTrackedObject(value, name, a.read), true
+}
+
+func (a *trackingActivation) read(path string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.fields[path] = true
+}
+
+func (a *trackingActivation) Variables() []string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return sortedKeys(a.variables)
+}
+
+func (a *trackingActivation) Fields() []string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return sortedKeys(a.fields)
+}
+
+// LazyBinding supplies the value of a variable when the variable is resolved,
+// e.g. by looking up a record in a database or by parsing a token. The value
+// may be of any type supported by NewActivation. An error is captured as the
+// value of the variable, and reported by the expressions which reference it.
+type LazyBinding func() (interface{}, error)
+
 // mapActivation which implements Activation and maps of named and referenced
 // values.
 //
 // Named bindings may lazily supply values by providing a function which
-// accepts no arguments and produces an interface value.
+// accepts no arguments and produces an interface value, or a LazyBinding.
 // TODO: consider passing the current activation to the supplier.
 type mapActivation struct {
 	references map[int64]ref.Value
 	bindings   map[string]interface{}
+	limits     *types.UnpackLimits
 }
 
 func (a *mapActivation) Parent() Activation {
@@ -66,11 +224,17 @@ func (a *mapActivation) ResolveName(name string) (ref.Value, bool) {
 		// Resolve a lazily bound value.
 		case func() ref.Value:
 			return object.(func() ref.Value)(), true
+		case LazyBinding:
+			value, err := object.(LazyBinding)()
+			if err != nil {
+				return types.NewErr("failed to resolve '%s': %v", name, err), true
+			}
+			return types.NativeToValueWithLimits(value, a.limits), true
 		// Otherwise, return the bound value.
 		case ref.Value:
 			return object.(ref.Value), true
 		default:
-			return types.NativeToValue(object), true
+			return types.NativeToValueWithLimits(object, a.limits), true
 		}
 	}
 	return nil, false
@@ -81,6 +245,29 @@ func (a *mapActivation) ResolveReference(exprId int64) (ref.Value, bool) {
 	return object, found
 }
 
+// memoActivation which implements Activation and caches the values resolved
+// from a mapActivation.
+type memoActivation struct {
+	mapActivation
+	// mutex is held while a value is resolved, so that concurrent
+	// evaluations share a single call of each supplier.
+	mutex  sync.Mutex
+	values map[string]ref.Value
+}
+
+func (a *memoActivation) ResolveName(name string) (ref.Value, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if value, found := a.values[name]; found {
+		return value, true
+	}
+	value, found := a.mapActivation.ResolveName(name)
+	if found {
+		a.values[name] = value
+	}
+	return value, found
+}
+
 // hierarchicalActivation which implements Activation and contains a parent and
 // child activation.
 type hierarchicalActivation struct {
@@ -99,6 +286,16 @@ func (a *hierarchicalActivation) ResolveName(name string) (ref.Value, bool) {
 	return a.parent.ResolveName(name)
 }
 
+// ResolveQualified resolves the path from the parent, unless the variable is
+// bound by the child, e.g. as the variable of a comprehension.
+func (a *hierarchicalActivation) ResolveQualified(name string,
+	path []Qualifier) (ref.Value, bool) {
+	if _, found := a.child.ResolveName(name); found {
+		return nil, false
+	}
+	return resolveQualified(a.parent, name, path)
+}
+
 func (a *hierarchicalActivation) ResolveReference(exprId int64) (ref.Value, bool) {
 	if object, found := a.child.ResolveReference(exprId); found {
 		return object, found
@@ -111,3 +308,64 @@ func (a *hierarchicalActivation) ResolveReference(exprId int64) (ref.Value, bool
 func NewHierarchicalActivation(parent Activation, child Activation) Activation {
 	return &hierarchicalActivation{parent, child}
 }
+
+// NewContextActivation returns an activation which resolves names and
+// references from the given activation, and whose evaluation is aborted once
+// the context is done, when the program was created with the
+// CheckCancellation option.
+//
+// The context is found from the activation passed to Eval or its parents.
+func NewContextActivation(ctx context.Context, activation Activation) Activation {
+	return &contextActivation{ctx: ctx, activation: activation}
+}
+
+// contextActivation which implements Activation and carries the context of an
+// evaluation.
+type contextActivation struct {
+	ctx        context.Context
+	activation Activation
+}
+
+func (a *contextActivation) Parent() Activation {
+	return a.activation
+}
+
+func (a *contextActivation) ResolveName(name string) (ref.Value, bool) {
+	return a.activation.ResolveName(name)
+}
+
+func (a *contextActivation) ResolveReference(exprId int64) (ref.Value, bool) {
+	return a.activation.ResolveReference(exprId)
+}
+
+func (a *contextActivation) ResolveQualified(name string,
+	path []Qualifier) (ref.Value, bool) {
+	return resolveQualified(a.activation, name, path)
+}
+
+// activationContext returns the context of an activation created by
+// NewContextActivation, or of one of its parents, or nil if there is none.
+func activationContext(activation Activation) context.Context {
+	for a := activation; a != nil; a = a.Parent() {
+		if c, isContext := a.(*contextActivation); isContext {
+			return c.ctx
+		}
+	}
+	return nil
+}
+
+// done returns whether the context is done, without blocking.
+func done(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// evalCancelled is the result of an evaluation which was aborted as its
+// context was done.
+func evalCancelled(ctx context.Context) ref.Value {
+	return types.NewErr("evaluation cancelled: %v", ctx.Err())
+}
@@ -15,7 +15,17 @@
 package interpreter
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes"
 	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/test"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
 	"testing"
 )
 
@@ -26,6 +36,190 @@ func TestNewActivation(t *testing.T) {
 	}
 }
 
+func TestNewActivationWithLimits(t *testing.T) {
+	any, err := ptypes.MarshalAny(&expr.ParsedExpr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := &types.UnpackLimits{
+		AllowedAnyTypes: []string{"google.api.expr.v1.SourceInfo"}}
+	activation := NewActivationWithLimits(map[string]interface{}{"a": any}, limits)
+	if val, found := activation.ResolveName("a"); !found || !types.IsError(val) {
+		t.Errorf("Got %v, wanted a disallowed type error", val)
+	}
+}
+
+func TestMemoizingActivation(t *testing.T) {
+	calls := 0
+	user := LazyBinding(func() (interface{}, error) {
+		calls++
+		return map[string]interface{}{"id": 1, "name": "alice"}, nil
+	})
+	// The tree resolves each reference to a variable, and the rules share
+	// their activation.
+	var rules []Interpretable
+	for _, text := range []string{
+		`user.id == 1 && user.name == 'alice'`,
+		`user.id > 0`,
+	} {
+		program := parsedProgram(t, text)
+		TreeEvaluation()(program.(*exprProgram))
+		rules = append(rules, interpreter.NewInterpretable(program))
+	}
+	for _, tst := range []struct {
+		activation Activation
+		calls      int
+	}{
+		{activation: NewActivation(map[string]interface{}{"user": user}), calls: 3},
+		{activation: NewMemoizingActivation(map[string]interface{}{"user": user}), calls: 1},
+	} {
+		calls = 0
+		for _, rule := range rules {
+			if result, _ := rule.Eval(tst.activation); result != types.True {
+				t.Errorf("Got '%v', wanted 'true'", result)
+			}
+		}
+		if calls != tst.calls {
+			t.Errorf("Got %d calls of the binding, wanted %d", calls, tst.calls)
+		}
+	}
+}
+
+func TestMemoizingActivation_Error(t *testing.T) {
+	calls := 0
+	activation := NewMemoizingActivation(map[string]interface{}{
+		"token": LazyBinding(func() (interface{}, error) {
+			calls++
+			return nil, errors.New("malformed token")
+		})})
+	for i := 0; i < 2; i++ {
+		val, found := activation.ResolveName("token")
+		if !found || !types.IsError(val) ||
+			!strings.Contains(val.(*types.Err).String(), "malformed token") {
+			t.Errorf("Got %v, wanted a malformed token error", val)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Got %d calls of the binding, wanted 1", calls)
+	}
+	if _, found := activation.ResolveName("missing"); found {
+		t.Error("Got a value for an unbound name, wanted none")
+	}
+}
+
+func TestJSONActivation(t *testing.T) {
+	activation := NewJSONActivation(map[string]json.RawMessage{
+		"request": json.RawMessage(`{"user": {"id": 9007199254740993}, "tags": ["a"]}`),
+		"invalid": json.RawMessage(`{`)})
+	program := parsedProgram(t, `request.user.id == 9007199254740993 && 'a' in request.tags`)
+	if result, _ := interpreter.NewInterpretable(program).Eval(activation); result != types.True {
+		t.Errorf("Got '%v', wanted 'true'", result)
+	}
+	if val, found := activation.ResolveName("invalid"); !found || !types.IsError(val) {
+		t.Errorf("Got %v, wanted an invalid JSON error", val)
+	}
+}
+
+func TestTrackingActivation(t *testing.T) {
+	msg := &test.TestAllTypes{
+		SingleInt64: 1,
+		NestedType: &test.TestAllTypes_SingleNestedMessage{
+			SingleNestedMessage: &test.TestAllTypes_NestedMessage{Bb: 2}}}
+	for _, tst := range []struct {
+		text      string
+		variables []string
+		fields    []string
+	}{
+		{text: `x.single_nested_message.bb == 2 && !has(x.single_string)`,
+			variables: []string{"x"},
+			fields:    []string{"x.single_nested_message.bb", "x.single_string"}},
+		{text: `x.single_int64 == 1 || y.single_int64 == 1`,
+			variables: []string{"x"},
+			fields:    []string{"x.single_int64"}},
+		{text: `x.single_nested_message == y.single_nested_message`,
+			variables: []string{"x", "y"},
+			fields:    []string{"x.single_nested_message", "y.single_nested_message"}},
+		{text: `x == y && n > 0`,
+			variables: []string{"n", "x", "y"},
+			fields:    []string{"x", "y"}},
+	} {
+		activation := NewTrackingActivation(map[string]interface{}{
+			"x": msg,
+			"y": msg,
+			"n": 1})
+		result, _ := interpreter.NewInterpretable(parsedProgram(t, tst.text)).Eval(activation)
+		if result != types.True {
+			t.Errorf("%s: got %v, wanted true", tst.text, result)
+		}
+		if !reflect.DeepEqual(activation.Variables(), tst.variables) {
+			t.Errorf("%s: got variables %v, wanted %v",
+				tst.text, activation.Variables(), tst.variables)
+		}
+		if !reflect.DeepEqual(activation.Fields(), tst.fields) {
+			t.Errorf("%s: got fields %v, wanted %v",
+				tst.text, activation.Fields(), tst.fields)
+		}
+	}
+}
+
+// labelResolver resolves the labels of a resource by path, and the whole
+// resource by name.
+type labelResolver struct {
+	Activation
+	labels map[string]string
+}
+
+func (r *labelResolver) ResolveQualified(name string, path []Qualifier) (ref.Value, bool) {
+	if name != "resource" || len(path) != 2 || path[0].Field != "labels" {
+		return nil, false
+	}
+	return types.String(r.labels[path[1].Field]), true
+}
+
+func TestQualifiedResolver(t *testing.T) {
+	loads := 0
+	resolver := &labelResolver{
+		Activation: NewActivation(map[string]interface{}{
+			"resource": LazyBinding(func() (interface{}, error) {
+				loads++
+				return map[string]interface{}{
+					"name":   "db",
+					"labels": map[string]string{"env": "prod"}}, nil
+			})}),
+		labels: map[string]string{"env": "prod"}}
+	for _, tst := range []struct {
+		text string
+		// loads holds the number of times the resource is resolved by name
+		// by the stepper, the fused stepper, and the tree.
+		loads [3]int
+	}{
+		{text: `resource.labels.env == 'prod'`, loads: [3]int{1, 0, 0}},
+		{text: `resource.labels.env == 'prod' && resource.name == 'db'`,
+			loads: [3]int{1, 1, 1}},
+		{text: `resource.labels.env == 'prod' && resource.labels.env != 'dev'`,
+			loads: [3]int{1, 1, 0}},
+		// Comprehension variables are not resolved by the resolver.
+		{text: `[{'labels': {'env': 'dev'}}].exists(resource, resource.labels.env == 'dev')`,
+			loads: [3]int{0, 0, 0}},
+	} {
+		for mode, opt := range []ProgramOption{nil, FuseInstructions(), TreeEvaluation()} {
+			program := parsedProgram(t, tst.text)
+			if opt != nil {
+				opt(program.(*exprProgram))
+			}
+			loads = 0
+			activation := NewContextActivation(context.Background(), resolver)
+			if result, _ := interpreter.NewInterpretable(program).Eval(activation); result != types.True {
+				t.Errorf("%s: got '%v' in mode %d, wanted 'true'", tst.text, result, mode)
+			}
+			if loads != tst.loads[mode] {
+				t.Errorf("%s: got %d loads in mode %d, wanted %d",
+					tst.text, loads, mode, tst.loads[mode])
+			}
+		}
+	}
+}
+
 func TestHierarchicalActivation(t *testing.T) {
 	// compose a parent with more properties than the child
 	parent := NewActivation(map[string]interface{}{"a": "world", "b": -42})
@@ -46,3 +240,21 @@ func TestHierarchicalActivation(t *testing.T) {
 		t.Error("Activation failed to resolve child value of 'c'")
 	}
 }
+
+func TestContextActivation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vars := NewActivation(map[string]interface{}{"a": true})
+	activation := NewContextActivation(ctx, vars)
+	if val, found := activation.ResolveName("a"); !found || val != types.True {
+		t.Error("Activation failed to resolve 'a'")
+	}
+	combined := NewHierarchicalActivation(activation,
+		NewActivation(map[string]interface{}{"b": 1}))
+	if activationContext(combined) != ctx {
+		t.Error("Activation failed to find the context of its parent")
+	}
+	if activationContext(vars) != nil {
+		t.Error("Activation has a context, wanted none")
+	}
+}
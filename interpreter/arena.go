@@ -0,0 +1,224 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"github.com/google/cel-go/common/types/ref"
+)
+
+const (
+	// Number of elements in the first chunk of each arena slab. Subsequent
+	// chunks double in size.
+	arenaChunkSize = 32
+)
+
+// Arena allocates the data structures produced while planning a Program, such
+// as instructions, argument id lists, and id maps, from chunks which are
+// released as a unit.
+//
+// Services which compile many short-lived expressions may keep an Arena per
+// worker and call Release once the programs planned within it are no longer
+// in use. After the first few compiles, planning an expression of similar size
+// reuses the chunks held by the arena rather than allocating new ones.
+//
+// An Arena is not safe for concurrent use.
+type Arena struct {
+	bases    []baseInstruction
+	calls    []CallExpr
+	idents   []IdentExpr
+	selects  []SelectExpr
+	jumps    []JumpInst
+	movs     []MovInst
+	ids      []int64
+	revMaps  []map[int64]int
+	litMaps  []map[int64]ref.Value
+	usedRevs int
+	usedLits int
+}
+
+// NewArena creates an empty Arena.
+func NewArena() *Arena {
+	return &Arena{}
+}
+
+// Release returns all of the memory allocated from the arena for reuse.
+//
+// Programs planned within the arena must not be used after Release is called.
+func (a *Arena) Release() {
+	for i := range a.calls {
+		a.calls[i] = CallExpr{}
+	}
+	for i := range a.idents {
+		a.idents[i] = IdentExpr{}
+	}
+	for i := range a.selects {
+		a.selects[i] = SelectExpr{}
+	}
+	for i := range a.jumps {
+		a.jumps[i] = JumpInst{}
+	}
+	for i := range a.movs {
+		a.movs[i] = MovInst{}
+	}
+	a.bases = a.bases[:0]
+	a.calls = a.calls[:0]
+	a.idents = a.idents[:0]
+	a.selects = a.selects[:0]
+	a.jumps = a.jumps[:0]
+	a.movs = a.movs[:0]
+	a.ids = a.ids[:0]
+	for i := 0; i < a.usedRevs; i++ {
+		for k := range a.revMaps[i] {
+			delete(a.revMaps[i], k)
+		}
+	}
+	for i := 0; i < a.usedLits; i++ {
+		for k := range a.litMaps[i] {
+			delete(a.litMaps[i], k)
+		}
+	}
+	a.usedRevs = 0
+	a.usedLits = 0
+}
+
+// The methods below fall back to heap allocation when the arena is nil so that
+// the planner may call them unconditionally.
+//
+// When a chunk is exhausted a new, larger chunk replaces it. Instructions which
+// point into the previous chunk keep it reachable until they are collected.
+
+func (a *Arena) newBase(exprId int64) *baseInstruction {
+	if a == nil {
+		return &baseInstruction{exprId}
+	}
+	if len(a.bases) == cap(a.bases) {
+		a.bases = make([]baseInstruction, 0, nextChunkSize(cap(a.bases)))
+	}
+	a.bases = append(a.bases, baseInstruction{exprId})
+	return &a.bases[len(a.bases)-1]
+}
+
+func (a *Arena) newCall(exprId int64, function string, argIds []int64) *CallExpr {
+	if a == nil {
+		return NewCall(exprId, function, argIds)
+	}
+	if len(a.calls) == cap(a.calls) {
+		a.calls = make([]CallExpr, 0, nextChunkSize(cap(a.calls)))
+	}
+	a.calls = append(a.calls,
+		CallExpr{a.newBase(exprId), function, argIds, "", checkIsStrict(function)})
+	return &a.calls[len(a.calls)-1]
+}
+
+func (a *Arena) newIdent(exprId int64, name string) *IdentExpr {
+	if a == nil {
+		return NewIdent(exprId, name)
+	}
+	if len(a.idents) == cap(a.idents) {
+		a.idents = make([]IdentExpr, 0, nextChunkSize(cap(a.idents)))
+	}
+	a.idents = append(a.idents, IdentExpr{a.newBase(exprId), name})
+	return &a.idents[len(a.idents)-1]
+}
+
+func (a *Arena) newSelect(exprId int64, operandId int64, field string,
+	testOnly bool) *SelectExpr {
+	if a == nil {
+		return &SelectExpr{&baseInstruction{exprId}, operandId, field, testOnly}
+	}
+	if len(a.selects) == cap(a.selects) {
+		a.selects = make([]SelectExpr, 0, nextChunkSize(cap(a.selects)))
+	}
+	a.selects = append(a.selects,
+		SelectExpr{a.newBase(exprId), operandId, field, testOnly})
+	return &a.selects[len(a.selects)-1]
+}
+
+func (a *Arena) newJump(exprId int64, instructionCount int,
+	cond jumpCondition) *JumpInst {
+	if a == nil {
+		jump := NewJump(exprId, instructionCount, cond.eval())
+		jump.condition = cond
+		return jump
+	}
+	if len(a.jumps) == cap(a.jumps) {
+		a.jumps = make([]JumpInst, 0, nextChunkSize(cap(a.jumps)))
+	}
+	a.jumps = append(a.jumps,
+		JumpInst{a.newBase(exprId), instructionCount, cond.eval(), cond})
+	return &a.jumps[len(a.jumps)-1]
+}
+
+func (a *Arena) newMov(exprId int64, toExprId int64) *MovInst {
+	if a == nil {
+		return NewMov(exprId, toExprId)
+	}
+	if len(a.movs) == cap(a.movs) {
+		a.movs = make([]MovInst, 0, nextChunkSize(cap(a.movs)))
+	}
+	a.movs = append(a.movs, MovInst{a.newBase(exprId), toExprId})
+	return &a.movs[len(a.movs)-1]
+}
+
+// newIds returns a zeroed slice of n expression ids whose capacity is limited
+// to its length so that appends never write into neighboring allocations.
+func (a *Arena) newIds(n int) []int64 {
+	if a == nil {
+		return make([]int64, n)
+	}
+	if len(a.ids)+n > cap(a.ids) {
+		size := nextChunkSize(cap(a.ids))
+		for size < n {
+			size *= 2
+		}
+		a.ids = make([]int64, 0, size)
+	}
+	start := len(a.ids)
+	a.ids = a.ids[:start+n]
+	ids := a.ids[start : start+n : start+n]
+	for i := range ids {
+		ids[i] = 0
+	}
+	return ids
+}
+
+func (a *Arena) newRevMap() map[int64]int {
+	if a == nil {
+		return make(map[int64]int)
+	}
+	if a.usedRevs == len(a.revMaps) {
+		a.revMaps = append(a.revMaps, make(map[int64]int))
+	}
+	a.usedRevs++
+	return a.revMaps[a.usedRevs-1]
+}
+
+func (a *Arena) newLiteralMap() map[int64]ref.Value {
+	if a == nil {
+		return make(map[int64]ref.Value)
+	}
+	if a.usedLits == len(a.litMaps) {
+		a.litMaps = append(a.litMaps, make(map[int64]ref.Value))
+	}
+	a.usedLits++
+	return a.litMaps[a.usedLits-1]
+}
+
+func nextChunkSize(size int) int {
+	if size < arenaChunkSize {
+		return arenaChunkSize
+	}
+	return size * 2
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/parser"
+	"github.com/google/cel-go/test"
+)
+
+func TestArena_Program(t *testing.T) {
+	arena := NewArena()
+	for i := 0; i < 3; i++ {
+		program := NewProgram(test.Exists.Expr, test.Exists.Info(t.Name()),
+			ProgramArena(arena))
+		expected := NewProgram(test.Exists.Expr, test.Exists.Info(t.Name()))
+		interpreter.NewInterpretable(program)
+		interpreter.NewInterpretable(expected)
+		if fmt.Sprint(program) != fmt.Sprint(expected) {
+			t.Errorf("Got program:\n%v\nwanted:\n%v", program, expected)
+		}
+		arena.Release()
+	}
+}
+
+func TestArena_Eval(t *testing.T) {
+	parsed, errors := parser.ParseText(
+		"a.b.c == 1 && (x ? [1, 2].exists(i, i == y) : false)")
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	arena := NewArena()
+	activation := NewActivation(map[string]interface{}{
+		"a": map[string]interface{}{"b": map[string]int64{"c": 1}},
+		"x": true,
+		"y": 2})
+	for i := 0; i < 3; i++ {
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+			ProgramArena(arena))
+		res, _ := interpreter.NewInterpretable(program).Eval(activation)
+		if res != types.True {
+			t.Errorf("Got '%v', wanted 'true'", res)
+		}
+		arena.Release()
+	}
+}
+
+func BenchmarkArena_NewInterpretable(b *testing.B) {
+	parsed, errors := parser.ParseText(
+		"a.b.c == 1 && (x ? [1, 2].exists(i, i == y) : false) || z.size() > 2")
+	if len(errors.GetErrors()) != 0 {
+		b.Fatal(errors.ToDisplayString())
+	}
+	arena := NewArena()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+			ProgramArena(arena))
+		interpreter.NewInterpretable(program)
+		arena.Release()
+	}
+}
@@ -16,6 +16,8 @@ package interpreter
 
 import (
 	"fmt"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
 	"github.com/google/cel-go/common/operators"
 	"github.com/google/cel-go/common/overloads"
 	"github.com/google/cel-go/common/types"
@@ -37,90 +39,110 @@ func WalkExpr(expression *expr.Expr,
 	metadata Metadata,
 	dispatcher Dispatcher,
 	state MutableEvalState) []Instruction {
-	nextId := maxId(expression)
+	instructions, _ := walkAst(astpb.FromExpr(expression),
+		metadata, dispatcher, state, NewConstants(), nil, nil)
+	return instructions
+}
+
+// walkAst produces the Instruction values for an expression along with the
+// pooled literal values which were set within the state, keyed by expression
+// id. When the arena is non-nil, instructions are allocated from it.
+//
+// No instructions are produced for the compiled arguments other than
+// literals, whose compiled values are set within the state by the Program.
+func walkAst(expression *ast.Expr,
+	metadata Metadata,
+	dispatcher Dispatcher,
+	state MutableEvalState,
+	constants *Constants,
+	arena *Arena,
+	compiled map[int64]ref.Value) ([]Instruction, map[int64]ref.Value) {
+	// The generated ids follow those of the expression, with three ids for
+	// each comprehension, as reserved by the Program.
+	nextId := maxId(expression) + 1
 	walker := &astWalker{
+		arena:      arena,
+		compiled:   compiled,
+		constants:  constants,
 		dispatcher: dispatcher,
 		genSymId:   nextId,
 		genExprId:  nextId,
+		literals:   arena.newLiteralMap(),
 		metadata:   metadata,
 		scope:      newScope(),
 		state:      state}
-	return walker.walk(expression)
+	return walker.walk(expression), walker.literals
 }
 
 // astWalker implementation of the AST walking logic.
 type astWalker struct {
+	arena      *Arena
+	compiled   map[int64]ref.Value
+	constants  *Constants
 	dispatcher Dispatcher
 	genExprId  int64
 	genSymId   int64
+	literals   map[int64]ref.Value
 	metadata   Metadata
 	scope      *blockScope
 	state      MutableEvalState
 }
 
-func (w *astWalker) walk(node *expr.Expr) []Instruction {
-	switch node.ExprKind.(type) {
-	case *expr.Expr_CallExpr:
+func (w *astWalker) walk(node *ast.Expr) []Instruction {
+	if _, isLiteral := node.Kind.(*ast.Literal); !isLiteral {
+		if _, found := w.compiled[node.Id]; found {
+			return []Instruction{}
+		}
+	}
+	switch node.Kind.(type) {
+	case *ast.Call:
 		return w.walkCall(node)
-	case *expr.Expr_IdentExpr:
+	case *ast.Ident:
 		return w.walkIdent(node)
-	case *expr.Expr_SelectExpr:
+	case *ast.Select:
 		return w.walkSelect(node)
-	case *expr.Expr_LiteralExpr:
+	case *ast.Literal:
 		w.walkLiteral(node)
 		return []Instruction{}
-	case *expr.Expr_ListExpr:
+	case *ast.CreateList:
 		return w.walkList(node)
-	case *expr.Expr_StructExpr:
+	case *ast.CreateStruct:
 		return w.walkStruct(node)
-	case *expr.Expr_ComprehensionExpr:
+	case *ast.Comprehension:
 		return w.walkComprehension(node)
 	}
 	return []Instruction{}
 }
 
-func (w *astWalker) walkLiteral(node *expr.Expr) {
-	literal := node.GetLiteralExpr()
-	var value ref.Value = nil
-	switch literal.LiteralKind.(type) {
-	case *expr.Literal_BoolValue:
-		value = types.Bool(literal.GetBoolValue())
-	case *expr.Literal_BytesValue:
-		value = types.Bytes(literal.GetBytesValue())
-	case *expr.Literal_DoubleValue:
-		value = types.Double(literal.GetDoubleValue())
-	case *expr.Literal_Int64Value:
-		value = types.Int(literal.GetInt64Value())
-	case *expr.Literal_NullValue:
-		value = types.Null(literal.GetNullValue())
-	case *expr.Literal_StringValue:
-		value = types.String(literal.GetStringValue())
-	case *expr.Literal_Uint64Value:
-		value = types.Uint(literal.GetUint64Value())
-	}
+func (w *astWalker) walkLiteral(node *ast.Expr) {
+	value := w.constants.literal(node.Kind.(*ast.Literal))
+	w.literals[node.Id] = value
 	w.state.SetValue(node.Id, value)
 }
 
-func (w *astWalker) walkIdent(node *expr.Expr) []Instruction {
-	identName := node.GetIdentExpr().Name
+func (w *astWalker) walkIdent(node *ast.Expr) []Instruction {
+	identName := node.Kind.(*ast.Ident).Name
 	if _, found := w.scope.ref(identName); !found {
-		ident := NewIdent(node.Id, identName)
+		ident := w.arena.newIdent(node.Id, identName)
 		w.scope.setRef(identName, node.Id)
 		return []Instruction{ident}
 	}
 	return []Instruction{}
 }
 
-func (w *astWalker) walkSelect(node *expr.Expr) []Instruction {
-	sel := node.GetSelectExpr()
+func (w *astWalker) walkSelect(node *ast.Expr) []Instruction {
+	sel := node.Kind.(*ast.Select)
 	operandId := w.getId(sel.Operand)
 	return append(
 		w.walk(sel.Operand),
-		NewSelect(node.Id, operandId, sel.Field))
+		w.arena.newSelect(node.Id, operandId, sel.Field, sel.TestOnly))
 }
 
-func (w *astWalker) walkCall(node *expr.Expr) []Instruction {
-	call := node.GetCallExpr()
+func (w *astWalker) walkCall(node *ast.Expr) []Instruction {
+	call := node.Kind.(*ast.Call)
+	if static, found := w.staticCall(call); found {
+		call = static
+	}
 	function := call.Function
 	argGroups, argGroupLens, argIds := w.walkCallArgs(call)
 	argCount := len(argIds)
@@ -151,14 +173,14 @@ func (w *astWalker) walkCall(node *expr.Expr) []Instruction {
 			instructions = append(instructions, argGroup...)
 			if i != argCount-1 {
 				instructions = append(instructions,
-					NewJump(
+					w.arena.newJump(
 						argIds[i],
 						instructionCount-evalCount,
-						jumpIfEqual(argIds[i], types.Bool(function == operators.LogicalOr))))
+						jumpCondition{jumpIfEqualKind, argIds[i], function == operators.LogicalOr}))
 			}
 			evalCount += 1
 		}
-		return append(instructions, NewCall(node.Id, call.Function, argIds))
+		return append(instructions, w.arena.newCall(node.Id, call.Function, argIds))
 
 	case operators.Conditional:
 		// Compute the conditional jump, with two jumps, one for false,
@@ -180,56 +202,55 @@ func (w *astWalker) walkCall(node *expr.Expr) []Instruction {
 		instructions = append(instructions, condition...)
 		// 1: jump to <END> on undefined/error
 		instructions = append(instructions,
-			NewJump(conditionId, len(trueVal)+len(falseVal)+3,
-				jumpIfUnknownOrError(conditionId)))
+			w.arena.newJump(conditionId, len(trueVal)+len(falseVal)+3,
+				jumpCondition{jumpIfUnknownOrErrorKind, conditionId, false}))
 		// 2: jump to <ELSE> on false.
 		instructions = append(instructions,
-			NewJump(conditionId, len(trueVal)+2,
-				jumpIfEqual(conditionId, types.False)))
+			w.arena.newJump(conditionId, len(trueVal)+2,
+				jumpCondition{jumpIfEqualKind, conditionId, false}))
 		// 3: <IF> expr
 		instructions = append(instructions, trueVal...)
 		// 4: jump to <END>
 		instructions = append(instructions,
-			NewJump(trueId, len(falseVal)+1, jumpAlways))
+			w.arena.newJump(trueId, len(falseVal)+1, jumpCondition{kind: jumpAlwaysKind}))
 		// 5: <ELSE> expr
 		instructions = append(instructions, falseVal...)
 		// 6: <END> ternary
-		return append(instructions, NewCall(node.Id, call.Function, argIds))
+		return append(instructions, w.arena.newCall(node.Id, call.Function, argIds))
 
 	default:
 		for _, argGroup := range argGroups {
 			instructions = append(instructions, argGroup...)
 		}
-		return append(instructions, NewCall(node.Id, call.Function, argIds))
+		return append(instructions, w.arena.newCall(node.Id, call.Function, argIds))
 	}
 }
 
-func (w *astWalker) walkList(node *expr.Expr) []Instruction {
-	listExpr := node.GetListExpr()
+func (w *astWalker) walkList(node *ast.Expr) []Instruction {
+	listExpr := node.Kind.(*ast.CreateList)
 	var elementIds []int64
 	var elementSteps []Instruction
-	for _, elem := range listExpr.GetElements() {
+	for _, elem := range listExpr.Elements {
 		elementIds = append(elementIds, w.getId(elem))
 		elementSteps = append(elementSteps, w.walk(elem)...)
 	}
 	return append(elementSteps, NewList(node.Id, elementIds))
 }
 
-func (w *astWalker) walkStruct(node *expr.Expr) []Instruction {
-	structExpr := node.GetStructExpr()
+func (w *astWalker) walkStruct(node *ast.Expr) []Instruction {
+	structExpr := node.Kind.(*ast.CreateStruct)
 	keyValues := make(map[int64]int64)
 	fieldValues := make(map[string]int64)
 	var entrySteps []Instruction
-	for _, entry := range structExpr.GetEntries() {
-		valueId := w.getId(entry.GetValue())
-		switch entry.KeyKind.(type) {
-		case *expr.Expr_CreateStruct_Entry_FieldKey:
-			fieldValues[entry.GetFieldKey()] = valueId
-		case *expr.Expr_CreateStruct_Entry_MapKey:
-			keyValues[w.getId(entry.GetMapKey())] = valueId
-			entrySteps = append(entrySteps, w.walk(entry.GetMapKey())...)
+	for _, entry := range structExpr.Entries {
+		valueId := w.getId(entry.Value)
+		if entry.MapKey == nil {
+			fieldValues[entry.FieldKey] = valueId
+		} else {
+			keyValues[w.getId(entry.MapKey)] = valueId
+			entrySteps = append(entrySteps, w.walk(entry.MapKey)...)
 		}
-		entrySteps = append(entrySteps, w.walk(entry.GetValue())...)
+		entrySteps = append(entrySteps, w.walk(entry.Value)...)
 	}
 	if len(structExpr.MessageName) == 0 {
 		return append(entrySteps, NewMap(node.Id, keyValues))
@@ -238,7 +259,7 @@ func (w *astWalker) walkStruct(node *expr.Expr) []Instruction {
 		NewObject(node.Id, structExpr.MessageName, fieldValues))
 }
 
-func (w *astWalker) walkComprehension(node *expr.Expr) []Instruction {
+func (w *astWalker) walkComprehension(node *ast.Expr) []Instruction {
 	// Serializing a comprehension into a linear set of executable steps is one
 	// of the more complex tasks in AST walking. The challenge being loop
 	// termination when errors or unknown values are encountered outside
@@ -261,12 +282,12 @@ func (w *astWalker) walkComprehension(node *expr.Expr) []Instruction {
 	// 9: result = accu                   # result
 	// 10: comp = result
 	// 11: pop-scope
-	comprehensionExpr := node.GetComprehensionExpr()
-	comprehensionRange := comprehensionExpr.GetIterRange()
-	comprehensionAccu := comprehensionExpr.GetAccuInit()
-	comprehensionLoop := comprehensionExpr.GetLoopCondition()
-	comprehensionStep := comprehensionExpr.GetLoopStep()
-	result := comprehensionExpr.GetResult()
+	comprehensionExpr := node.Kind.(*ast.Comprehension)
+	comprehensionRange := comprehensionExpr.IterRange
+	comprehensionAccu := comprehensionExpr.AccuInit
+	comprehensionLoop := comprehensionExpr.LoopCondition
+	comprehensionStep := comprehensionExpr.LoopStep
+	result := comprehensionExpr.Result
 
 	// iter-range
 	rangeSteps := w.walk(comprehensionRange)
@@ -276,10 +297,8 @@ func (w *astWalker) walkComprehension(node *expr.Expr) []Instruction {
 	iterNextId := w.nextExprId()
 	iterSymId := w.nextSymId()
 	accuId := w.getId(comprehensionAccu)
-	loopId := w.getId(comprehensionLoop)
-	stepId := w.getId(comprehensionStep)
 	pushScopeStep := NewPushScope(
-		node.GetId(),
+		node.Id,
 		map[string]int64{
 			comprehensionExpr.AccuVar: accuId,
 			comprehensionExpr.IterVar: iterNextId,
@@ -289,12 +308,17 @@ func (w *astWalker) walkComprehension(node *expr.Expr) []Instruction {
 	currScope.setRef(comprehensionExpr.IterVar, iterNextId)
 	currScope.setRef(iterSymId, iteratorId)
 	w.pushScope(currScope)
+	// The ids of the loop condition and step are resolved within the scope,
+	// as either may simply reference the accumulator, as the loop condition
+	// of the 'all' macro does.
+	loopId := w.getId(comprehensionLoop)
+	stepId := w.getId(comprehensionStep)
 	// accu-init
 	accuInitSteps := w.walk(comprehensionAccu)
 
 	// iter-init
 	iterInitStep :=
-		NewCall(iteratorId, overloads.Iterator, []int64{w.getId(comprehensionRange)})
+		w.arena.newCall(iteratorId, overloads.Iterator, w.singleId(w.getId(comprehensionRange)))
 
 	// <LOOP>
 	// Loop instruction breakdown
@@ -316,26 +340,29 @@ func (w *astWalker) walkComprehension(node *expr.Expr) []Instruction {
 	// iter-hasNext
 	iterHasNextId := w.nextExprId()
 	iterHasNextStep :=
-		NewCall(iterHasNextId, overloads.HasNext, []int64{iteratorId})
+		w.arena.newCall(iterHasNextId, overloads.HasNext, w.singleId(iteratorId))
 	// jump <END> if !it.hasNext()
 	jumpIterEndStep :=
-		NewJump(iterHasNextId, loopInstructionCount-2, breakIfEnd(iterHasNextId))
+		w.arena.newJump(iterHasNextId, loopInstructionCount-2,
+			jumpCondition{breakIfEndKind, iterHasNextId, false})
 	// eval x = it.next()
 	// eval <cond>
 	// jump <END> if condition false
 	jumpConditionFalseStep :=
-		NewJump(loopId, loopInstructionCount-4, jumpIfEqual(loopId, types.False))
+		w.arena.newJump(loopId, loopInstructionCount-4,
+			jumpCondition{jumpIfEqualKind, loopId, false})
 
 	// iter-next
-	nextIterVarStep := NewCall(iterNextId, overloads.Next, []int64{iteratorId})
+	nextIterVarStep := w.arena.newCall(iterNextId, overloads.Next, w.singleId(iteratorId))
 	// assign the loop-step to the accu var
-	accuUpdateStep := NewMov(stepId, accuId)
+	accuUpdateStep := w.arena.newMov(stepId, accuId)
 	// jump <LOOP>
-	jumpCondStep := NewJump(stepId, -(loopInstructionCount - 2), jumpAlways)
+	jumpCondStep := w.arena.newJump(stepId, -(loopInstructionCount - 2),
+		jumpCondition{kind: jumpAlwaysKind})
 
 	// <END> result
 	resultSteps := w.walk(result)
-	compResultUpdateStep := NewMov(w.getId(result), w.getId(node))
+	compResultUpdateStep := w.arena.newMov(w.getId(result), w.getId(node))
 	popScopeStep := NewPopScope(w.getId(node))
 	w.popScope()
 
@@ -353,13 +380,13 @@ func (w *astWalker) walkComprehension(node *expr.Expr) []Instruction {
 	return instructions
 }
 
-func (w *astWalker) walkCallArgs(call *expr.Expr_Call) (
+func (w *astWalker) walkCallArgs(call *ast.Call) (
 	argGroups [][]Instruction, argGroupLens []int, argIds []int64) {
 	args := getArgs(call)
 	argCount := len(args)
 	argGroups = make([][]Instruction, argCount)
 	argGroupLens = make([]int, argCount)
-	argIds = make([]int64, argCount)
+	argIds = w.arena.newIds(argCount)
 	for i, arg := range getArgs(call) {
 		argIds[i] = w.getId(arg)
 		argGroups[i] = w.walk(arg)
@@ -370,19 +397,59 @@ func (w *astWalker) walkCallArgs(call *expr.Expr_Call) (
 
 // Helper functions.
 
+// staticCall returns a global call when the target of a receiver-style call is
+// a qualified name which, together with the function, names an overload known
+// to the dispatcher, e.g. 'math.isNaN(x)'. The checker resolves such calls in
+// the same manner.
+func (w *astWalker) staticCall(call *ast.Call) (*ast.Call, bool) {
+	if call.Target == nil || w.dispatcher == nil {
+		return nil, false
+	}
+	qname, found := qualifiedName(call.Target)
+	if !found {
+		return nil, false
+	}
+	function := qname + "." + call.Function
+	if _, found := w.dispatcher.FindOverload(function); !found {
+		return nil, false
+	}
+	return &ast.Call{Function: function, Args: call.Args}, true
+}
+
+// qualifiedName returns the dot-separated name formed by a chain of selects
+// on an identifier.
+func qualifiedName(e *ast.Expr) (string, bool) {
+	switch kind := e.Kind.(type) {
+	case *ast.Ident:
+		return kind.Name, true
+	case *ast.Select:
+		if qname, found := qualifiedName(kind.Operand); found && !kind.TestOnly {
+			return qname + "." + kind.Field, true
+		}
+	}
+	return "", false
+}
+
 // getArgs returns a unified set of call args for both global and receiver
 // style calls.
-func getArgs(call *expr.Expr_Call) []*expr.Expr {
-	var argSet []*expr.Expr
+func getArgs(call *ast.Call) []*ast.Expr {
+	var argSet []*ast.Expr
 	if call.Target != nil {
 		argSet = append(argSet, call.Target)
 	}
-	if call.GetArgs() != nil {
-		argSet = append(argSet, call.GetArgs()...)
+	if call.Args != nil {
+		argSet = append(argSet, call.Args...)
 	}
 	return argSet
 }
 
+// singleId returns a one-element list of expression ids.
+func (w *astWalker) singleId(id int64) []int64 {
+	ids := w.arena.newIds(1)
+	ids[0] = id
+	return ids
+}
+
 // nextSymId generates an expression-unique identifier name for identifiers
 // that need to be produced programmatically.
 func (w *astWalker) nextSymId() string {
@@ -416,9 +483,9 @@ func (w *astWalker) popScope() {
 
 // getId returns the expression id associated with a given identifier if one
 // has been set within the current scope, else the expression id.
-func (w *astWalker) getId(expr *expr.Expr) int64 {
-	id := expr.GetId()
-	if ident := expr.GetIdentExpr(); ident != nil {
+func (w *astWalker) getId(expr *ast.Expr) int64 {
+	id := expr.Id
+	if ident, isIdent := expr.Kind.(*ast.Ident); isIdent {
 		if altId, found := w.scope.ref(ident.Name); found {
 			w.state.SetRuntimeExpressionId(id, altId)
 			return altId
@@ -452,6 +519,43 @@ func (b *blockScope) setRef(ident string, exprId int64) {
 	b.references[ident] = exprId
 }
 
+// jumpConditionKind identifies one of the jump conditions generated by the
+// astWalker.
+type jumpConditionKind int
+
+const (
+	// Conditions supplied by the caller of NewJump are opaque functions.
+	customJumpKind jumpConditionKind = iota
+	jumpAlwaysKind
+	jumpIfEqualKind
+	jumpIfUnknownOrErrorKind
+	breakIfEndKind
+)
+
+// jumpCondition describes a generated jump condition in terms of its kind, the
+// expression id it tests, and for equality tests, the bool value compared
+// against.
+//
+// Unlike the condition function, the description can be serialized.
+type jumpCondition struct {
+	kind   jumpConditionKind
+	exprId int64
+	value  bool
+}
+
+// eval returns the function which evaluates the condition.
+func (c jumpCondition) eval() func(EvalState) bool {
+	switch c.kind {
+	case jumpIfEqualKind:
+		return jumpIfEqual(c.exprId, types.Bool(c.value))
+	case jumpIfUnknownOrErrorKind:
+		return jumpIfUnknownOrError(c.exprId)
+	case breakIfEndKind:
+		return breakIfEnd(c.exprId)
+	}
+	return jumpAlways
+}
+
 func jumpIfUnknownOrError(exprId int64) func(EvalState) bool {
 	return func(s EvalState) bool {
 		if val, found := s.Value(exprId); found {
@@ -474,7 +578,8 @@ func breakIfEnd(conditionId int64) func(EvalState) bool {
 
 func jumpIfEqual(exprId int64, value ref.Value) func(EvalState) bool {
 	return func(s EvalState) bool {
-		if val, found := s.Value(exprId); found {
+		// Expressions skipped by a jump have no value.
+		if val, found := s.Value(exprId); found && val != nil {
 			if types.IsBool(val.Type()) {
 				return bool(val.Equal(value).(types.Bool))
 			}
@@ -487,79 +592,29 @@ func jumpAlways(_ EvalState) bool {
 	return true
 }
 
-func comprehensionCount(nodes ...*expr.Expr) int64 {
-	if nodes == nil || len(nodes) == 0 {
-		return 0
-	}
+func comprehensionCount(node *ast.Expr) int64 {
 	count := int64(0)
-	for _, node := range nodes {
-		if node == nil {
-			continue
-		}
-		switch node.ExprKind.(type) {
-		case *expr.Expr_SelectExpr:
-			count += comprehensionCount(node.GetSelectExpr().GetOperand())
-		case *expr.Expr_CallExpr:
-			call := node.GetCallExpr()
-			count += comprehensionCount(call.GetTarget()) + comprehensionCount(call.GetArgs()...)
-		case *expr.Expr_ListExpr:
-			count += comprehensionCount(node.GetListExpr().GetElements()...)
-		case *expr.Expr_StructExpr:
-			for _, entry := range node.GetStructExpr().GetEntries() {
-				count += comprehensionCount(entry.GetMapKey()) +
-					comprehensionCount(entry.GetValue())
-			}
-		case *expr.Expr_ComprehensionExpr:
-			compre := node.GetComprehensionExpr()
-			count += 1
-			count += comprehensionCount(compre.IterRange) +
-				comprehensionCount(compre.AccuInit) +
-				comprehensionCount(compre.LoopCondition) +
-				comprehensionCount(compre.LoopStep) +
-				comprehensionCount(compre.Result)
+	ast.Visit(node, func(e *ast.Expr, _ *ast.Expr) bool {
+		if _, isComprehension := e.Kind.(*ast.Comprehension); isComprehension {
+			count++
 		}
-	}
+		return true
+	})
 	return count
 }
 
-func maxId(node *expr.Expr) int64 {
-	if node == nil {
-		return 0
-	}
-	currId := node.Id
-	switch node.ExprKind.(type) {
-	case *expr.Expr_SelectExpr:
-		return maxInt(currId, maxId(node.GetSelectExpr().Operand))
-	case *expr.Expr_CallExpr:
-		call := node.GetCallExpr()
-		currId = maxInt(currId, maxId(call.Target))
-		for _, arg := range call.Args {
-			currId = maxInt(currId, maxId(arg))
-		}
-		return currId
-	case *expr.Expr_ListExpr:
-		list := node.GetListExpr()
-		for _, elem := range list.Elements {
-			currId = maxInt(currId, maxId(elem))
-		}
-		return currId
-	case *expr.Expr_StructExpr:
-		str := node.GetStructExpr()
-		for _, entry := range str.Entries {
-			currId = maxInt(currId, entry.Id, maxId(entry.GetMapKey()), maxId(entry.Value))
+func maxId(node *ast.Expr) int64 {
+	currId := int64(0)
+	ast.Visit(node, func(e *ast.Expr, _ *ast.Expr) bool {
+		currId = maxInt(currId, e.Id)
+		if str, isStruct := e.Kind.(*ast.CreateStruct); isStruct {
+			for _, entry := range str.Entries {
+				currId = maxInt(currId, entry.Id)
+			}
 		}
-		return currId
-	case *expr.Expr_ComprehensionExpr:
-		compre := node.GetComprehensionExpr()
-		return maxInt(currId,
-			maxId(compre.IterRange),
-			maxId(compre.AccuInit),
-			maxId(compre.LoopCondition),
-			maxId(compre.LoopStep),
-			maxId(compre.Result))
-	default:
-		return currId
-	}
+		return true
+	})
+	return currId
 }
 
 func maxInt(vals ...int64) int64 {
@@ -0,0 +1,53 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+// attributeTracker records the attributes read by an evaluation, as the
+// values of the expressions which read them are recorded within the
+// EvalState. A nil tracker records nothing.
+type attributeTracker struct {
+	// ids holds the name of the attribute read by each expression id.
+	ids  map[int64]string
+	read map[string]bool
+}
+
+// newAttributeTracker returns a tracker of the attributes reported by
+// ProgramAttributes, or nil when the program does not track its attributes.
+func newAttributeTracker(p *exprProgram) *attributeTracker {
+	if !p.trackAttributes || p.expression == nil {
+		return nil
+	}
+	collector := newAttributeCollector()
+	collector.visit(p.expression)
+	return &attributeTracker{ids: collector.ids, read: make(map[string]bool)}
+}
+
+// clone returns a tracker of the same attributes, for a new evaluation.
+func (t *attributeTracker) clone() *attributeTracker {
+	if t == nil {
+		return nil
+	}
+	return &attributeTracker{ids: t.ids, read: make(map[string]bool)}
+}
+
+// track records the attribute read by the expression id, if any.
+func (t *attributeTracker) track(id int64) {
+	if t == nil {
+		return
+	}
+	if name, found := t.ids[id]; found {
+		t.read[name] = true
+	}
+}
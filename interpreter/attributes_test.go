@@ -0,0 +1,104 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/parser"
+)
+
+func TestTrackAttributes(t *testing.T) {
+	request := map[string]interface{}{
+		"auth": map[string]interface{}{
+			"claims": map[string]interface{}{"sub": "alice", "admin": false}},
+		"path": "/admin"}
+	for _, tst := range []struct {
+		text     string
+		expected []string
+	}{
+		{text: `request.auth.claims.sub == 'alice' || request.path == '/'`,
+			expected: []string{"request.auth.claims.sub"}},
+		{text: `request.auth.claims.admin || request.path.size() > 1`,
+			expected: []string{"request.auth.claims.admin", "request.path"}},
+		{text: `has(request.auth.claims.email) ? request.auth.claims.email : request.auth.claims.sub`,
+			expected: []string{"request.auth.claims.email", "request.auth.claims.sub"}},
+		{text: `request.auth.missing.sub == 'alice'`,
+			expected: []string{"request.auth.missing.sub"}},
+		{text: `['/admin', '/root'].exists(p, p == request.path)`,
+			expected: []string{"request.path"}},
+		{text: `roles[request.auth.claims.sub]`,
+			expected: []string{"request.auth.claims.sub", "roles"}},
+		{text: `size('x') > 0`},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		for _, opts := range [][]ProgramOption{
+			{TrackAttributes()},
+			{TrackAttributes(), FuseInstructions()},
+			{TrackAttributes(), TreeEvaluation()},
+		} {
+			program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(), opts...)
+			_, state := interpreter.NewInterpretable(program).Eval(
+				NewActivation(map[string]interface{}{
+					"request": request,
+					"roles":   map[string]string{"alice": "user"}}))
+			if attributes := state.Attributes(); !reflect.DeepEqual(attributes, tst.expected) {
+				t.Errorf("%s: got %v with %+v, wanted %v",
+					tst.text, attributes, program.Config(), tst.expected)
+			}
+		}
+	}
+}
+
+func TestTrackAttributes_PerEvaluation(t *testing.T) {
+	for _, opts := range [][]ProgramOption{
+		{TrackAttributes()},
+		{TrackAttributes(), TreeEvaluation()},
+	} {
+		program := parsedProgram(t, `a || b.c`)
+		for _, opt := range opts {
+			opt(program.(*exprProgram))
+		}
+		interpretable := interpreter.NewInterpretable(program)
+		for _, tst := range []struct {
+			a        bool
+			expected []string
+		}{
+			{a: false, expected: []string{"a", "b.c"}},
+			{a: true, expected: []string{"a"}},
+		} {
+			_, state := interpretable.Eval(NewActivation(map[string]interface{}{
+				"a": tst.a,
+				"b": map[string]bool{"c": true}}))
+			if attributes := state.Attributes(); !reflect.DeepEqual(attributes, tst.expected) {
+				t.Errorf("Got %v for a=%t with %+v, wanted %v",
+					attributes, tst.a, program.Config(), tst.expected)
+			}
+		}
+	}
+}
+
+func TestTrackAttributes_Disabled(t *testing.T) {
+	program := parsedProgram(t, `a.b`)
+	_, state := interpreter.NewInterpretable(program).Eval(
+		NewActivation(map[string]interface{}{"a": map[string]int{"b": 1}}))
+	if attributes := state.Attributes(); attributes != nil {
+		t.Errorf("Got %v, wanted no attributes", attributes)
+	}
+}
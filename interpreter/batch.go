@@ -0,0 +1,306 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/types/ref"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+// Batch evaluates a set of expressions together, such as the rules of a
+// policy bundle over the same request, so that the sub-expressions which the
+// expressions have in common are evaluated once per activation, rather than
+// once by each expression:
+//
+//     batch := NewBatch(interp, map[string]*expr.ParsedExpr{
+//         "admin":  admin,   // request.auth.claims.groups.exists(g, g == 'admin')
+//         "reader": reader}) // request.auth.claims.groups.exists(g, g == 'admin') || ...
+//     results := batch.Eval(activation)
+//
+// The sub-expressions are compared by their structure, and the calls, field
+// selections, and aggregate literals which occur more than once are shared,
+// except for those within the loops of comprehensions, which depend on the
+// variables of the comprehensions, and those which call Volatile overloads. A
+// shared sub-expression is evaluated when an expression first needs its value,
+// so the short-circuiting of logical operators is preserved.
+//
+// The expressions are evaluated as unchecked expressions. A Batch may be
+// evaluated concurrently.
+type Batch struct {
+	names          []string
+	interpretables map[string]Interpretable
+	shared         map[string]Interpretable
+}
+
+// NewBatch creates a Batch of the parsed expressions, by name, whose programs
+// are configured with the options and evaluated by the interpreter.
+func NewBatch(interp Interpreter, expressions map[string]*expr.ParsedExpr,
+	opts ...ProgramOption) *Batch {
+	b := &Batch{
+		interpretables: make(map[string]Interpretable, len(expressions)),
+		shared:         make(map[string]Interpretable)}
+	var dispatcher Dispatcher
+	if i, isExprInterpreter := interp.(*exprInterpreter); isExprInterpreter {
+		dispatcher = i.dispatcher
+	}
+	s := &subexprSharer{
+		dispatcher: dispatcher,
+		keys:       make(map[*ast.Expr]string),
+		counts:     make(map[string]int),
+		names:      make(map[string]string)}
+	roots := make(map[string]*ast.Expr, len(expressions))
+	for name, parsed := range expressions {
+		b.names = append(b.names, name)
+		roots[name] = astpb.FromExpr(parsed.Expr)
+		s.count(roots[name])
+	}
+	sort.Strings(b.names)
+	for _, name := range b.names {
+		info := astpb.FromSourceInfo(expressions[name].SourceInfo)
+		s.info = info
+		program := NewAstProgram(s.rewrite(roots[name]), info, opts...)
+		b.interpretables[name] = interp.NewInterpretable(program)
+	}
+	for _, def := range s.defs {
+		program := NewAstProgram(def.expr, def.info, opts...)
+		b.shared[def.name] = interp.NewInterpretable(program)
+	}
+	return b
+}
+
+// Shared returns the number of sub-expressions shared by the expressions.
+func (b *Batch) Shared() int {
+	return len(b.shared)
+}
+
+// Eval evaluates each of the expressions with the activation, and returns
+// their results by name.
+func (b *Batch) Eval(activation Activation) map[string]ref.Value {
+	shared := &sharedActivation{
+		parent: activation,
+		shared: b.shared,
+		values: make(map[string]ref.Value, len(b.shared))}
+	results := make(map[string]ref.Value, len(b.names))
+	for _, name := range b.names {
+		results[name], _ = b.interpretables[name].Eval(shared)
+	}
+	return results
+}
+
+// sharedActivation resolves the identifiers of shared sub-expressions by
+// evaluating the sub-expressions when they are first resolved, and resolves
+// other names from the parent. It is used by a single evaluation of a Batch.
+type sharedActivation struct {
+	parent Activation
+	shared map[string]Interpretable
+	values map[string]ref.Value
+}
+
+func (a *sharedActivation) Parent() Activation {
+	return a.parent
+}
+
+func (a *sharedActivation) ResolveName(name string) (ref.Value, bool) {
+	interpretable, found := a.shared[name]
+	if !found {
+		return a.parent.ResolveName(name)
+	}
+	if value, found := a.values[name]; found {
+		return value, true
+	}
+	value, _ := interpretable.Eval(a)
+	a.values[name] = value
+	return value, true
+}
+
+func (a *sharedActivation) ResolveReference(exprId int64) (ref.Value, bool) {
+	return a.parent.ResolveReference(exprId)
+}
+
+// subexprSharer finds the sub-expressions which occur more than once within a
+// set of expressions, and replaces them with identifiers of the shared
+// sub-expressions.
+type subexprSharer struct {
+	dispatcher Dispatcher
+	// Structural keys of the nodes, and the number of occurrences of each key
+	// outside of the loops of comprehensions.
+	keys   map[*ast.Expr]string
+	counts map[string]int
+	// Names of the shared sub-expressions by key, and their definitions in
+	// the order in which they were found.
+	names map[string]string
+	defs  []*sharedExpr
+	// Source information of the expression being rewritten.
+	info *ast.SourceInfo
+}
+
+type sharedExpr struct {
+	name string
+	expr *ast.Expr
+	info *ast.SourceInfo
+}
+
+// count records the occurrences of the sharable nodes of the expression.
+func (s *subexprSharer) count(e *ast.Expr) {
+	if e == nil {
+		return
+	}
+	if s.sharable(e) {
+		s.counts[s.key(e)]++
+	}
+	for _, child := range s.children(e) {
+		s.count(child)
+	}
+}
+
+// rewrite returns a copy of the expression in which the nodes which occur
+// more than once are replaced by the identifiers of shared sub-expressions.
+func (s *subexprSharer) rewrite(e *ast.Expr) *ast.Expr {
+	if e == nil {
+		return nil
+	}
+	if !s.sharable(e) || s.counts[s.key(e)] < 2 {
+		return s.rewriteChildren(e)
+	}
+	key := s.key(e)
+	name, found := s.names[key]
+	if !found {
+		name = fmt.Sprintf("@shared%d", len(s.defs))
+		s.names[key] = name
+		def := &sharedExpr{name: name, info: s.info}
+		s.defs = append(s.defs, def)
+		def.expr = s.rewriteChildren(e)
+	}
+	return &ast.Expr{Id: e.Id, Kind: &ast.Ident{Name: name}}
+}
+
+// rewriteChildren returns a copy of the node whose children outside of the
+// loops of comprehensions are rewritten.
+func (s *subexprSharer) rewriteChildren(e *ast.Expr) *ast.Expr {
+	switch kind := e.Kind.(type) {
+	case *ast.Select:
+		sel := *kind
+		sel.Operand = s.rewrite(kind.Operand)
+		return &ast.Expr{Id: e.Id, Kind: &sel}
+	case *ast.Call:
+		call := *kind
+		call.Target = s.rewrite(kind.Target)
+		call.Args = make([]*ast.Expr, len(kind.Args))
+		for i, arg := range kind.Args {
+			call.Args[i] = s.rewrite(arg)
+		}
+		return &ast.Expr{Id: e.Id, Kind: &call}
+	case *ast.CreateList:
+		list := *kind
+		list.Elements = make([]*ast.Expr, len(kind.Elements))
+		for i, elem := range kind.Elements {
+			list.Elements[i] = s.rewrite(elem)
+		}
+		return &ast.Expr{Id: e.Id, Kind: &list}
+	case *ast.CreateStruct:
+		str := *kind
+		str.Entries = make([]*ast.Entry, len(kind.Entries))
+		for i, entry := range kind.Entries {
+			rewritten := *entry
+			rewritten.MapKey = s.rewrite(entry.MapKey)
+			rewritten.Value = s.rewrite(entry.Value)
+			str.Entries[i] = &rewritten
+		}
+		return &ast.Expr{Id: e.Id, Kind: &str}
+	case *ast.Comprehension:
+		comp := *kind
+		comp.IterRange = s.rewrite(kind.IterRange)
+		comp.AccuInit = s.rewrite(kind.AccuInit)
+		return &ast.Expr{Id: e.Id, Kind: &comp}
+	}
+	return e
+}
+
+// children returns the children of the node which are evaluated outside of
+// the loops of comprehensions.
+func (s *subexprSharer) children(e *ast.Expr) []*ast.Expr {
+	if comp, isComp := e.Kind.(*ast.Comprehension); isComp {
+		return []*ast.Expr{comp.IterRange, comp.AccuInit}
+	}
+	return ast.Children(e)
+}
+
+// sharable returns whether the node is worth sharing, and may be shared.
+func (s *subexprSharer) sharable(e *ast.Expr) bool {
+	switch e.Kind.(type) {
+	case *ast.Literal, *ast.Ident:
+		return false
+	}
+	volatile := false
+	ast.Visit(e, func(e *ast.Expr, parent *ast.Expr) bool {
+		if call, isCall := e.Kind.(*ast.Call); isCall && s.dispatcher != nil &&
+			isVolatile(s.dispatcher, call.Function, "") {
+			volatile = true
+		}
+		return !volatile
+	})
+	return !volatile
+}
+
+// key returns a string which is equal for nodes of the same structure.
+func (s *subexprSharer) key(e *ast.Expr) string {
+	if e == nil {
+		return ""
+	}
+	if key, found := s.keys[e]; found {
+		return key
+	}
+	var key string
+	switch kind := e.Kind.(type) {
+	case *ast.Literal:
+		key = fmt.Sprintf("%T:%#v", kind.Value, kind.Value)
+	case *ast.Ident:
+		key = kind.Name
+	case *ast.Select:
+		key = fmt.Sprintf("%s.%s?%t", s.key(kind.Operand), kind.Field, kind.TestOnly)
+	case *ast.Call:
+		args := make([]string, len(kind.Args))
+		for i, arg := range kind.Args {
+			args[i] = s.key(arg)
+		}
+		key = fmt.Sprintf("%s.%s(%s)", s.key(kind.Target), kind.Function,
+			strings.Join(args, ","))
+	case *ast.CreateList:
+		elems := make([]string, len(kind.Elements))
+		for i, elem := range kind.Elements {
+			elems[i] = s.key(elem)
+		}
+		key = fmt.Sprintf("[%s]", strings.Join(elems, ","))
+	case *ast.CreateStruct:
+		entries := make([]string, len(kind.Entries))
+		for i, entry := range kind.Entries {
+			entries[i] = fmt.Sprintf("%s%s:%s", entry.FieldKey, s.key(entry.MapKey),
+				s.key(entry.Value))
+		}
+		key = fmt.Sprintf("%s{%s}", kind.MessageName, strings.Join(entries, ","))
+	case *ast.Comprehension:
+		key = fmt.Sprintf("comprehension(%s,%s,%s,%s,%s,%s,%s)", kind.IterVar,
+			s.key(kind.IterRange), kind.AccuVar, s.key(kind.AccuInit),
+			s.key(kind.LoopCondition), s.key(kind.LoopStep), s.key(kind.Result))
+	}
+	s.keys[e] = key
+	return key
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/google/cel-go/parser"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+func TestBatch(t *testing.T) {
+	texts := map[string]string{
+		"admin":  "lookup(user).exists(g, g == 'admin')",
+		"reader": "lookup(user).exists(g, g == 'admin') || lookup(user).exists(g, g == 'reader')",
+		"groups": "size(lookup(user)) + size(lookup(other))",
+		"loop":   "[1, 2].exists(x, lookup(user).size() > x)",
+		"clock":  "tick() + tick()",
+		"error":  "lookup(user)[5] == 'admin'",
+	}
+	expected := map[string]ref.Value{
+		"admin":  types.True,
+		"reader": types.True,
+		"groups": types.Int(1),
+		"loop":   types.False,
+		"clock":  types.Int(3),
+	}
+	expressions := make(map[string]*expr.ParsedExpr)
+	for name, text := range texts {
+		parsed, errors := parser.ParseText(text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		expressions[name] = parsed
+	}
+	for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+		lookups, ticks := 0, 0
+		dispatcher := dispatcher()
+		dispatcher.Add(&functions.Overload{
+			Operator: "lookup",
+			Unary: func(value ref.Value) ref.Value {
+				lookups++
+				if value == types.String("alice") {
+					return types.NewStringList([]string{"admin"})
+				}
+				return types.NewStringList([]string{})
+			}}, &functions.Overload{
+			Operator: "tick",
+			Volatile: true,
+			Function: func(values ...ref.Value) ref.Value {
+				ticks++
+				return types.Int(ticks)
+			}})
+		interp := NewInterpreter(dispatcher, packages.DefaultPackage,
+			types.NewProvider())
+		batch := NewBatch(interp, expressions, opts...)
+		// lookup(user) and the exists() comprehension over it.
+		if batch.Shared() != 2 {
+			t.Errorf("Got %d shared sub-expressions, wanted 2", batch.Shared())
+		}
+		results := batch.Eval(NewActivation(map[string]interface{}{
+			"user":  "alice",
+			"other": "bob"}))
+		for name, want := range expected {
+			if got := results[name]; got == nil || got.Equal(want) != types.True {
+				t.Errorf("%s: got '%v', wanted '%v'", texts[name], got, want)
+			}
+		}
+		if !types.IsError(results["error"]) {
+			t.Errorf("%s: got '%v', wanted error", texts["error"], results["error"])
+		}
+		// lookup(user) is evaluated once outside of the loop and once for each
+		// iteration within it, and lookup(other) once. Volatile calls are not
+		// shared.
+		if lookups != 4 || ticks != 2 {
+			t.Errorf("Got %d lookups and %d ticks, wanted 4 and 2", lookups, ticks)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// ProgramAttributes returns the sorted names of the attributes read by a
+// Program, such as 'a' or 'a.b.c'. Each attribute is a variable or a chain of
+// field selections on a variable, and only the longest chain is reported for
+// each read, e.g. 'a.b' rather than 'a' for 'a.b == 1'. The variables of
+// comprehensions are not attributes.
+//
+// The attributes of programs loaded with UnmarshalProgram are not known.
+func ProgramAttributes(program Program) ([]string, bool) {
+	p, ok := program.(*exprProgram)
+	if !ok || p.expression == nil {
+		return nil, false
+	}
+	collector := newAttributeCollector()
+	collector.visit(p.expression)
+	var sorted []string
+	for name := range collector.attributes {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted, true
+}
+
+// attributeCollector collects the names of the attributes read by an
+// expression.
+type attributeCollector struct {
+	// Counts of the comprehension variables in scope, by name.
+	scopes     map[string]int
+	attributes map[string]bool
+	// ids holds the name of the attribute read by each expression id.
+	ids map[int64]string
+}
+
+func newAttributeCollector() *attributeCollector {
+	return &attributeCollector{
+		scopes:     make(map[string]int),
+		attributes: make(map[string]bool),
+		ids:        make(map[int64]string)}
+}
+
+func (c *attributeCollector) add(id int64, name string) {
+	c.attributes[name] = true
+	c.ids[id] = name
+}
+
+func (c *attributeCollector) visit(e *ast.Expr) {
+	if e == nil {
+		return
+	}
+	if name, root, found := attributeName(e); found {
+		if c.scopes[root] == 0 {
+			c.add(e.Id, name)
+		}
+		return
+	}
+	switch kind := e.Kind.(type) {
+	case *ast.Select:
+		// Presence tests read the tested field.
+		if name, root, found := attributeName(kind.Operand); found {
+			if c.scopes[root] == 0 {
+				c.add(e.Id, name+"."+kind.Field)
+			}
+			return
+		}
+		c.visit(kind.Operand)
+	case *ast.Call:
+		c.visit(kind.Target)
+		for _, arg := range kind.Args {
+			c.visit(arg)
+		}
+	case *ast.CreateList:
+		for _, elem := range kind.Elements {
+			c.visit(elem)
+		}
+	case *ast.CreateStruct:
+		for _, entry := range kind.Entries {
+			c.visit(entry.MapKey)
+			c.visit(entry.Value)
+		}
+	case *ast.Comprehension:
+		c.visit(kind.IterRange)
+		c.visit(kind.AccuInit)
+		c.scopes[kind.IterVar]++
+		c.scopes[kind.AccuVar]++
+		c.visit(kind.LoopCondition)
+		c.visit(kind.LoopStep)
+		c.visit(kind.Result)
+		c.scopes[kind.IterVar]--
+		c.scopes[kind.AccuVar]--
+	}
+}
+
+// DecisionCache is an Interpretable which memoizes the results of another
+// Interpretable, keyed by the values of the attributes its program reads.
+//
+// Activations which agree on the values of the attributes read by the
+// program share a cache entry, so decisions which are repeated across
+// requests skip evaluation, while a change to any attribute the program reads
+// selects a different entry. Functions are assumed to be deterministic; the
+// cache should be purged if the behavior of the Dispatcher changes.
+//
+// Only values are cached. Errors and unknowns are returned without being
+// cached, since they may be transient, such as the errors of cancelled
+// evaluations or of evaluations which exceeded their limits or deadlines.
+//
+// The least recently used entries are evicted once the capacity of the cache
+// is reached.
+type DecisionCache struct {
+	interpretable Interpretable
+	attributes    []string
+	resultId      int64
+	stateSize     int64
+	capacity      int
+
+	mutex   sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List
+}
+
+type decisionCacheEntry struct {
+	key   [sha256.Size]byte
+	value ref.Value
+}
+
+// NewDecisionCache creates a DecisionCache holding at most capacity results
+// of an Interpretable created by NewInterpretable.
+func NewDecisionCache(interpretable Interpretable,
+	capacity int) (*DecisionCache, error) {
+	i, ok := innerInterpretable(interpretable).(*exprInterpretable)
+	if !ok {
+		return nil, fmt.Errorf("unsupported interpretable type: %T", interpretable)
+	}
+	if capacity <= 0 {
+		return nil, fmt.Errorf("invalid decision cache capacity: %d", capacity)
+	}
+	attributes, ok := ProgramAttributes(i.program)
+	if !ok {
+		return nil, fmt.Errorf("the attributes of the program are not known")
+	}
+	// The result of the program is the value of its last instruction.
+	var resultId int64
+	if instructions := i.program.(*exprProgram).instructions; len(instructions) > 0 {
+		resultId = instructions[len(instructions)-1].GetId()
+	}
+	return &DecisionCache{
+		interpretable: interpretable,
+		attributes:    attributes,
+		resultId:      resultId,
+		stateSize:     i.program.MaxInstructionId() + 1,
+		capacity:      capacity,
+		entries:       make(map[[sha256.Size]byte]*list.Element),
+		lru:           list.New()}, nil
+}
+
+// Attributes returns the names of the attributes from which cache keys are
+// derived.
+func (c *DecisionCache) Attributes() []string {
+	return c.attributes
+}
+
+// Eval returns the cached result for the values of the attributes within the
+// activation, or evaluates the Interpretable when no result is cached.
+//
+// The EvalState of a cached result only holds the value of the expression
+// result.
+func (c *DecisionCache) Eval(activation Activation) (ref.Value, EvalState) {
+	key := c.key(activation)
+	c.mutex.Lock()
+	if elem, found := c.entries[key]; found {
+		c.lru.MoveToFront(elem)
+		value := elem.Value.(*decisionCacheEntry).value
+		c.mutex.Unlock()
+		state := NewEvalState(c.stateSize)
+		state.SetValue(c.resultId, value)
+		return value, state
+	}
+	c.mutex.Unlock()
+
+	value, state := c.interpretable.Eval(activation)
+	if types.IsUnknownOrError(value) {
+		return value, state
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, found := c.entries[key]; !found {
+		c.entries[key] = c.lru.PushFront(&decisionCacheEntry{key, value})
+		if c.lru.Len() > c.capacity {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(c.entries, oldest.Value.(*decisionCacheEntry).key)
+		}
+	}
+	return value, state
+}
+
+// Len returns the number of cached results.
+func (c *DecisionCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
+
+// Purge removes all cached results.
+func (c *DecisionCache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = make(map[[sha256.Size]byte]*list.Element)
+	c.lru.Init()
+}
+
+// key derives the cache key from the values of the attributes resolved from
+// the activation.
+func (c *DecisionCache) key(activation Activation) [sha256.Size]byte {
+	var buf bytes.Buffer
+	for _, attribute := range c.attributes {
+		buf.WriteString(attribute)
+		buf.WriteByte('=')
+		writeKeyValue(&buf, resolveAttribute(activation, attribute))
+		buf.WriteByte(';')
+	}
+	return sha256.Sum256(buf.Bytes())
+}
+
+// resolveAttribute resolves the value of an attribute from the activation in
+// the same manner as evaluation: the variable is resolved by name and its
+// fields are selected, or when the variable is absent, the shortest qualified
+// name present in the activation is resolved instead.
+func resolveAttribute(activation Activation, attribute string) ref.Value {
+	fields := strings.Split(attribute, ".")
+	for i := 1; i <= len(fields); i++ {
+		val, found := activation.ResolveName(strings.Join(fields[:i], "."))
+		if !found {
+			continue
+		}
+		for _, field := range fields[i:] {
+			indexer, isIndexer := val.(traits.Indexer)
+			if !isIndexer || types.IsError(val) || types.IsUnknown(val) {
+				return val
+			}
+			val = indexer.Get(types.String(field))
+		}
+		return val
+	}
+	return nil
+}
+
+// writeKeyValue writes a canonical representation of the value to the buffer.
+func writeKeyValue(buf *bytes.Buffer, val ref.Value) {
+	if val == nil || types.IsUnknown(val) {
+		buf.WriteByte('?')
+		return
+	}
+	if types.IsError(val) {
+		buf.WriteByte('!')
+		buf.WriteString(strconv.Quote(fmt.Sprint(val)))
+		return
+	}
+	buf.WriteString(val.Type().TypeName())
+	buf.WriteByte(':')
+	switch v := val.(type) {
+	case types.Bytes:
+		buf.WriteString(strconv.Quote(string(v)))
+		return
+	case types.String:
+		buf.WriteString(strconv.Quote(string(v)))
+		return
+	case types.Bool, types.Double, types.Int, types.Uint:
+		fmt.Fprint(buf, v)
+		return
+	}
+	switch val.Type() {
+	case types.ListType:
+		list := val.(traits.Lister)
+		buf.WriteByte('[')
+		if size, isInt := list.Size().(types.Int); isInt {
+			for i := types.Int(0); i < size; i++ {
+				writeKeyValue(buf, list.Get(i))
+				buf.WriteByte(',')
+			}
+		}
+		buf.WriteByte(']')
+		return
+	case types.MapType:
+		m := val.(traits.Mapper)
+		var entries []string
+		for it := m.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			var entry bytes.Buffer
+			writeKeyValue(&entry, key)
+			entry.WriteByte(':')
+			writeKeyValue(&entry, m.Get(key))
+			entries = append(entries, entry.String())
+		}
+		sort.Strings(entries)
+		buf.WriteByte('{')
+		buf.WriteString(strings.Join(entries, ","))
+		buf.WriteByte('}')
+		return
+	}
+	if msg, isMessage := val.Value().(proto.Message); isMessage {
+		buf.WriteString(strconv.Quote(proto.CompactTextString(msg)))
+		return
+	}
+	fmt.Fprintf(buf, "%v", val.Value())
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/parser"
+	"github.com/google/cel-go/test"
+)
+
+func TestProgramAttributes(t *testing.T) {
+	for _, tst := range []struct {
+		text     string
+		expected []string
+	}{
+		{text: `a.b.c == 1 && a.d`, expected: []string{"a.b.c", "a.d"}},
+		{text: `size(a) > 0 && a.b`, expected: []string{"a", "a.b"}},
+		{text: `has(msg.single_int64) && [1, 2].exists(x, x > y)`,
+			expected: []string{"msg.single_int64", "y"}},
+		{text: `m[k].f`, expected: []string{"k", "m"}},
+	} {
+		program := parsedProgram(t, tst.text)
+		attributes, _ := ProgramAttributes(program)
+		if !reflect.DeepEqual(attributes, tst.expected) {
+			t.Errorf("%s: got %v, wanted %v", tst.text, attributes, tst.expected)
+		}
+	}
+}
+
+func TestDecisionCache(t *testing.T) {
+	program := parsedProgram(t, `request.user in admins || request.size < limit`)
+	cache, err := NewDecisionCache(interpreter.NewInterpretable(program), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The admins are resolved once to derive the cache key, and once more
+	// when the program is evaluated.
+	resolved := 0
+	for _, tst := range []struct {
+		user     string
+		admins   []string
+		expected ref.Value
+		cached   bool
+	}{
+		{user: "alice", admins: []string{"alice"}, expected: types.True},
+		// Repeated decision.
+		{user: "alice", admins: []string{"alice"}, expected: types.True, cached: true},
+		// Changed attribute values.
+		{user: "bob", admins: []string{"alice"}, expected: types.False},
+		{user: "alice", admins: []string{"bob"}, expected: types.False},
+		{user: "alice", admins: []string{"bob"}, expected: types.False, cached: true},
+		// The least recently used decision was evicted.
+		{user: "alice", admins: []string{"alice"}, expected: types.True},
+	} {
+		admins := tst.admins
+		activation := NewActivation(map[string]interface{}{
+			"request": map[string]interface{}{"user": tst.user, "size": 10},
+			"admins": func() ref.Value {
+				resolved++
+				return types.NativeToValue(admins)
+			},
+			"limit": 5,
+			// Attributes which are not read by the program do not affect
+			// the cache key.
+			"unused": resolved})
+		resolved = 0
+		result, _ := cache.Eval(activation)
+		if result != tst.expected {
+			t.Errorf("%v: got %v, wanted %v", tst, result, tst.expected)
+		}
+		if cached := resolved == 1; cached != tst.cached {
+			t.Errorf("%v: got cached %t, wanted %t", tst, cached, tst.cached)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Got %d entries, wanted 2", cache.Len())
+	}
+	cache.Purge()
+	if cache.Len() != 0 {
+		t.Errorf("Got %d entries after purge, wanted 0", cache.Len())
+	}
+}
+
+func TestDecisionCache_Errors(t *testing.T) {
+	program := parsedProgram(t, `request.size / divisor > 1`)
+	cache, err := NewDecisionCache(interpreter.NewInterpretable(program), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activation := NewActivation(map[string]interface{}{
+		"request": map[string]interface{}{"size": 10},
+		"divisor": 0})
+	for i := 0; i < 2; i++ {
+		if result, _ := cache.Eval(activation); !types.IsError(result) {
+			t.Errorf("Got %v, wanted error", result)
+		}
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Got %d entries, wanted no cached errors", cache.Len())
+	}
+}
+
+func TestDecisionCache_Messages(t *testing.T) {
+	program := parsedProgram(t, `msg.single_int64 > 1`)
+	cache, err := NewDecisionCache(interpreter.NewInterpretable(program), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tst := range []struct {
+		value    int64
+		expected ref.Value
+	}{
+		{value: 2, expected: types.True},
+		{value: 1, expected: types.False},
+		{value: 2, expected: types.True},
+	} {
+		result, _ := cache.Eval(NewActivation(map[string]interface{}{
+			"msg": &test.TestAllTypes{SingleInt64: tst.value}}))
+		if result != tst.expected {
+			t.Errorf("%d: got %v, wanted %v", tst.value, result, tst.expected)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Got %d entries, wanted 2", cache.Len())
+	}
+}
+
+func parsedProgram(t *testing.T, text string) Program {
+	t.Helper()
+	parsed, errors := parser.ParseText(text)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	return NewProgram(parsed.Expr, parsed.SourceInfo)
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Continuation is an evaluation suspended by EvalChunked, which continues
+// where it left off when it is resumed.
+//
+// A Continuation may only be resumed once, and must not be resumed while
+// the activation of the evaluation is modified.
+type Continuation struct {
+	interpretable *exprInterpretable
+	evaluation    *evaluation
+}
+
+// EvalChunked begins the evaluation of the Interpretable with the activation,
+// and suspends it after at most maxSteps instructions, so that an embedder
+// with a single thread, such as a game server or a WASM host, may interleave
+// a long evaluation with other work without goroutines.
+//
+// When the evaluation completes within the steps, its result and state are
+// returned with a nil Continuation. Otherwise, the result and state are nil,
+// and the returned Continuation resumes the evaluation.
+//
+// Only the Interpretables created by NewInterpretable for programs evaluated
+// by the stepper may be suspended. Other Interpretables, including those
+// evaluated as a tree or through middleware, are evaluated in a single chunk.
+// A limit of zero steps evaluates the Interpretable to completion.
+func EvalChunked(interpretable Interpretable, activation Activation,
+	maxSteps uint) (ref.Value, EvalState, *Continuation) {
+	i, isStepper := interpretable.(*exprInterpretable)
+	if !isStepper {
+		result, state := interpretable.Eval(activation)
+		return result, state, nil
+	}
+	// The interpretable is copied, as by Eval, so that the suspended
+	// evaluation has a state of its own.
+	eval := *i
+	eval.state = i.initial.clone()
+	eval.state.trace = i.sampler.sample()
+	c := &Continuation{interpretable: &eval, evaluation: eval.begin(activation)}
+	return c.Resume(maxSteps)
+}
+
+// Resume continues the evaluation for at most maxSteps instructions, or to
+// completion when maxSteps is zero, with the results described by
+// EvalChunked.
+func (c *Continuation) Resume(maxSteps uint) (ref.Value, EvalState, *Continuation) {
+	result, complete := c.interpretable.run(c.evaluation, maxSteps)
+	if !complete {
+		return nil, nil, c
+	}
+	return result, c.interpretable.state, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/common/types"
+)
+
+func TestEvalChunked(t *testing.T) {
+	text := "elems.map(x, x * 2).exists(x, x == a) && size(elems) == 4"
+	for _, tst := range []struct {
+		a        int64
+		expected types.Bool
+	}{
+		{a: 8, expected: types.True},
+		{a: 5, expected: types.False},
+	} {
+		activation := NewActivation(map[string]interface{}{
+			"a":     tst.a,
+			"elems": []int64{1, 2, 3, 4}})
+		program := parsedProgram(t, text)
+		interpretable := interpreter.NewInterpretable(program)
+		expected, _ := interpretable.Eval(activation)
+		if expected != tst.expected {
+			t.Fatalf("%s: got '%v', wanted '%v'", text, expected, tst.expected)
+		}
+
+		chunks := 1
+		result, state, cont := EvalChunked(interpretable, activation, 3)
+		for cont != nil {
+			if result != nil || state != nil {
+				t.Errorf("Got '%v' from a suspended evaluation, wanted nil", result)
+			}
+			chunks++
+			result, state, cont = cont.Resume(3)
+		}
+		if result != tst.expected || state == nil {
+			t.Errorf("%s: got '%v' from chunks, wanted '%v'", text, result, tst.expected)
+		}
+		if instructions := instructionCount(interpretable); int64(chunks) < instructions/3 {
+			t.Errorf("Got %d chunks of a program of %d instructions", chunks, instructions)
+		}
+
+		// Suspended evaluations are independent of each other.
+		_, _, first := EvalChunked(interpretable, activation, 5)
+		second, _, _ := EvalChunked(interpretable, activation, 0)
+		if resumed, _, _ := first.Resume(0); resumed != tst.expected || second != tst.expected {
+			t.Errorf("Got '%v' and '%v' from interleaved evaluations, wanted '%v'",
+				resumed, second, tst.expected)
+		}
+	}
+
+	// Interpretables which may not be suspended are evaluated in one chunk.
+	program := parsedProgram(t, "a + 1")
+	TreeEvaluation()(program.(*exprProgram))
+	result, _, cont := EvalChunked(interpreter.NewInterpretable(program),
+		NewActivation(map[string]interface{}{"a": 1}), 1)
+	if result != types.Int(2) || cont != nil {
+		t.Errorf("Got '%v' from a tree evaluation, wanted 2", result)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"strings"
+
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// Collation maps a string to the key by which it is compared, so that
+// strings with equal keys are considered equal. For example, a Collation
+// which normalizes strings to NFC may be built from the norm package of
+// golang.org/x/text as norm.NFC.String.
+type Collation func(s string) string
+
+// FoldCase is a Collation which compares strings case-insensitively, as is
+// the norm for HTTP header names and hostnames.
+func FoldCase(s string) string {
+	return strings.ToLower(s)
+}
+
+// NewCollatingDispatcher returns a Dispatcher which dispatches calls to the
+// given Dispatcher, except that the named operations compare strings by their
+// collation keys. The operations may be any of:
+//
+//   - operators.Equals and operators.NotEquals, of two strings
+//   - operators.In, of a string in a list or in the keys of a map
+//   - operators.Index, of a map by a string key, which falls back to a key
+//     with the same collation key when there is no exact match
+//
+// All of the operations are collated when none are named. The selection of
+// map fields, as in 'headers.host', is not a call and is not collated.
+//
+// Calls through a collating Dispatcher are not fused, folded, or specialized,
+// since each must pass through the collation.
+func NewCollatingDispatcher(dispatcher Dispatcher, collation Collation,
+	operations ...string) Dispatcher {
+	if len(operations) == 0 {
+		operations = []string{operators.Equals, operators.NotEquals,
+			operators.In, operators.Index}
+	}
+	d := &collatingDispatcher{
+		Dispatcher: dispatcher,
+		collation:  collation,
+		operations: make(map[string]bool)}
+	for _, operation := range operations {
+		d.operations[operation] = true
+		if operation == operators.In {
+			d.operations[overloads.DeprecatedIn] = true
+		}
+	}
+	return d
+}
+
+type collatingDispatcher struct {
+	Dispatcher
+	collation  Collation
+	operations map[string]bool
+}
+
+func (d *collatingDispatcher) Dispatch(ctx *CallContext) ref.Value {
+	function, _ := ctx.Function()
+	if !d.operations[function] || len(ctx.args) != 2 {
+		return d.Dispatcher.Dispatch(ctx)
+	}
+	lhs, rhs := ctx.args[0], ctx.args[1]
+	switch function {
+	case operators.Equals, operators.NotEquals:
+		l, lhsString := lhs.(types.String)
+		r, rhsString := rhs.(types.String)
+		if lhsString && rhsString {
+			return types.Bool(d.equal(l, r) == (function == operators.Equals))
+		}
+	case operators.In, overloads.DeprecatedIn:
+		elem, isString := lhs.(types.String)
+		_, isList := rhs.(traits.Lister)
+		_, isMap := rhs.(traits.Mapper)
+		if isString && (isList || isMap) {
+			_, found := d.find(rhs.(traits.Iterable), elem)
+			return types.Bool(found)
+		}
+	case operators.Index:
+		mapper, isMap := lhs.(traits.Mapper)
+		key, isString := rhs.(types.String)
+		if isMap && isString {
+			value := mapper.Get(key)
+			if !types.IsError(value) {
+				return value
+			}
+			if collated, found := d.find(mapper, key); found {
+				return mapper.Get(collated)
+			}
+			return value
+		}
+	}
+	return d.Dispatcher.Dispatch(ctx)
+}
+
+// find returns the first string element of the iterable, such as a key of a
+// map, whose collation key is that of the string.
+func (d *collatingDispatcher) find(iterable traits.Iterable,
+	s types.String) (ref.Value, bool) {
+	key := d.collation(string(s))
+	for it := iterable.Iterator(); it.HasNext() == types.True; {
+		elem := it.Next()
+		if str, isString := elem.(types.String); isString &&
+			d.collation(string(str)) == key {
+			return elem, true
+		}
+	}
+	return nil, false
+}
+
+func (d *collatingDispatcher) equal(lhs types.String, rhs types.String) bool {
+	return lhs == rhs || d.collation(string(lhs)) == d.collation(string(rhs))
+}
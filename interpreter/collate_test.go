@@ -0,0 +1,79 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+func TestCollatingDispatcher(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"headers": map[string]string{"Content-Type": "application/json"},
+		"host":    "API.Example.com",
+		"hosts":   []string{"www.example.com", "api.example.com"}})
+	idents := []*checkedpb.Decl{
+		decls.NewIdent("headers", decls.NewMapType(decls.String, decls.String), nil),
+		decls.NewIdent("host", decls.String, nil),
+		decls.NewIdent("hosts", decls.NewListType(decls.String), nil)}
+	for _, tst := range []struct {
+		text       string
+		operations []string
+		expected   ref.Value
+	}{
+		{text: "host == 'api.example.com'", expected: types.True},
+		{text: "host != 'api.example.com'", expected: types.False},
+		{text: "host in hosts", expected: types.True},
+		{text: "'content-type' in headers", expected: types.True},
+		{text: "headers['content-type'] == 'application/json'", expected: types.True},
+		{text: "headers['Content-Type']", expected: types.String("application/json")},
+		{text: "host == 'api.example.com'", operations: []string{operators.In},
+			expected: types.False},
+		{text: "host in hosts", operations: []string{operators.In},
+			expected: types.True},
+		{text: "headers['content-type']", operations: []string{operators.Equals}},
+	} {
+		dispatcher := NewCollatingDispatcher(dispatcher(), FoldCase,
+			tst.operations...)
+		interp := NewInterpreter(dispatcher, packages.DefaultPackage,
+			types.NewProvider())
+		for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+			// Checked programs index maps by string keys, which are otherwise
+			// specialized.
+			for _, program := range []Program{
+				parsedProgram(t, tst.text),
+				checkedProgram(t, tst.text, idents...)} {
+				for _, opt := range opts {
+					opt(program.(*exprProgram))
+				}
+				res, _ := interp.NewInterpretable(program).Eval(activation)
+				if tst.expected == nil {
+					if !types.IsError(res) {
+						t.Errorf("%s: got '%v', wanted error", tst.text, res)
+					}
+				} else if res.Equal(tst.expected) != types.True {
+					t.Errorf("%s: got '%v' with %+v, wanted '%v'",
+						tst.text, res, program.Config(), tst.expected)
+				}
+			}
+		}
+	}
+}
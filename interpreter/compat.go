@@ -0,0 +1,428 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+const (
+	// FeatureTypedIndex indicates a program contains index operations which
+	// were specialized using type-check information.
+	FeatureTypedIndex = "typed_index"
+
+	// The following features indicate the options a program was planned with
+	// which change the results of its evaluations, and which a loaded program
+	// must therefore be configured with as well.
+
+	// FeatureFusedInstructions indicates the FuseInstructions option.
+	FeatureFusedInstructions = "fused_instructions"
+	// FeaturePropagateNullSelect indicates the PropagateNullSelect option.
+	FeaturePropagateNullSelect = "propagate_null_select"
+	// FeatureDynDispatchNull indicates the DynDispatch option with
+	// NullOnMismatch.
+	FeatureDynDispatchNull = "dyn_dispatch_null"
+	// FeatureDynDispatchCoerceStrings indicates the DynDispatch option with
+	// CoerceStringsOnMismatch.
+	FeatureDynDispatchCoerceStrings = "dyn_dispatch_coerce_strings"
+	// FeatureDivisionByZeroDefault indicates the DivisionByZeroDefault option.
+	FeatureDivisionByZeroDefault = "division_by_zero_default"
+	// FeatureRedactErrors indicates the RedactErrors option.
+	FeatureRedactErrors = "redact_errors"
+)
+
+var (
+	// Features supported by the instruction set of this interpreter.
+	supportedFeatures = map[string]bool{
+		FeatureTypedIndex:               true,
+		FeatureFusedInstructions:        true,
+		FeaturePropagateNullSelect:      true,
+		FeatureDynDispatchNull:          true,
+		FeatureDynDispatchCoerceStrings: true,
+		FeatureDivisionByZeroDefault:    true,
+		FeatureRedactErrors:             true,
+	}
+)
+
+// Requirements describes what a planned Program expects of the environment in
+// which it is evaluated.
+type Requirements struct {
+	// Functions lists the names of the functions bound through the Dispatcher
+	// the program was initialized with.
+	Functions []string
+
+	// Types lists the names of the message types constructed by the program,
+	// as they were written in the expression.
+	Types []string
+
+	// Features lists the interpreter features used by the program
+	// instructions, and the options the program was planned with which
+	// change the results of its evaluations.
+	Features []string
+}
+
+// ProgramRequirements returns the Requirements of an initialized Program.
+func ProgramRequirements(program Program) (*Requirements, bool) {
+	p, ok := program.(*exprProgram)
+	if !ok || p.requirements == nil {
+		return nil, false
+	}
+	return p.requirements, true
+}
+
+// CompatibilityError reports each way in which a Program's requirements are
+// not met by an Interpreter.
+type CompatibilityError struct {
+	Mismatches []string
+}
+
+// Error implements the error interface method.
+func (e *CompatibilityError) Error() string {
+	return fmt.Sprintf("program is incompatible with the environment:\n  - %s",
+		strings.Join(e.Mismatches, "\n  - "))
+}
+
+// LoadProgram loads a Program serialized with MarshalProgram and validates it
+// against the Interpreter which will evaluate it.
+//
+// When the Interpreter does not satisfy the program requirements, the error
+// is a *CompatibilityError listing every mismatch.
+func LoadProgram(serialized []byte, interp Interpreter,
+	opts ...ProgramOption) (Program, error) {
+	program, err := UnmarshalProgram(serialized, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateProgram(program, interp); err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+// ValidateProgram checks that the overloads, types, and features required by
+// an initialized Program are provided by the Interpreter, and that the Program
+// is configured with the options it was planned with, such as the options
+// provided to UnmarshalProgram for a loaded program.
+func ValidateProgram(program Program, interp Interpreter) error {
+	reqs, found := ProgramRequirements(program)
+	if !found {
+		return fmt.Errorf("program must be initialized prior to validation")
+	}
+	i, ok := interp.(*exprInterpreter)
+	if !ok {
+		return fmt.Errorf("unsupported interpreter type: %T", interp)
+	}
+	var mismatches []string
+	for _, function := range reqs.Functions {
+		if _, found := i.dispatcher.FindOverload(function); !found {
+			mismatches = append(mismatches,
+				fmt.Sprintf("no overload for function '%s'", function))
+		}
+	}
+	for _, typeName := range reqs.Types {
+		if !i.hasType(typeName) {
+			mismatches = append(mismatches,
+				fmt.Sprintf("unknown type '%s'", typeName))
+		}
+	}
+	configured := make(map[string]bool)
+	for _, feature := range optionFeatures(program.(*exprProgram)) {
+		configured[feature] = true
+	}
+	for _, feature := range reqs.Features {
+		if !supportedFeatures[feature] {
+			mismatches = append(mismatches,
+				fmt.Sprintf("unsupported feature '%s'", feature))
+		} else if feature != FeatureTypedIndex && !configured[feature] {
+			mismatches = append(mismatches,
+				fmt.Sprintf("feature '%s' is not configured", feature))
+		}
+	}
+	if len(mismatches) > 0 {
+		return &CompatibilityError{Mismatches: mismatches}
+	}
+	return nil
+}
+
+// ValidateDeclarations cross-checks the declarations of the functions used
+// for type-checking against the overloads of the Interpreter, e.g. as a
+// self-check when a service starts. The error is a *CompatibilityError
+// listing:
+//
+//   - the declared functions which the Interpreter does not implement, and
+//     which would type-check but fail with 'no such overload' when evaluated.
+//     Functions received by timestamps and durations, such as 'getHours', are
+//     implemented by the values themselves.
+//   - the declared overloads of implemented functions which the Interpreter
+//     does not implement, by id. An overload is implemented by the overload
+//     of the same id, or by an overload of the function whose argument types
+//     accept the declared parameters. The overload which implements a
+//     function by its name, such as the '_<_' overload of the standard
+//     overloads, implements the declared overloads whose first parameter has
+//     its operand trait and is not the first argument type of an overload of
+//     the function, such as a type registered with the TypeProvider.
+//   - the overloads of the Interpreter which implement no declared function,
+//     and which may therefore not be called from checked expressions.
+//
+// The overloads of an Interpreter created with a Dispatcher other than the
+// one created by NewDispatcher may not be listed, so only the first check
+// applies to such Interpreters.
+func ValidateDeclarations(interp Interpreter, functions []*checkedpb.Decl) error {
+	i, ok := interp.(*exprInterpreter)
+	if !ok {
+		return fmt.Errorf("unsupported interpreter type: %T", interp)
+	}
+	received := make(map[string]bool)
+	for _, t := range []ref.Type{types.TimestampType, types.DurationType,
+		types.HeaderMapType} {
+		for _, function := range types.ReceivedFunctions(t) {
+			received[function] = true
+		}
+	}
+	sorted := make([]*checkedpb.Decl, len(functions))
+	copy(sorted, functions)
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].GetName() < sorted[b].GetName()
+	})
+	d, isDefault := i.dispatcher.(*defaultDispatcher)
+	declared := make(map[string]bool)
+	var mismatches []string
+	for _, decl := range sorted {
+		function := decl.GetName()
+		declared[function] = true
+		var missing []string
+		for _, overload := range decl.GetFunction().GetOverloads() {
+			declared[overload.GetOverloadId()] = true
+			if !received[function] && !i.implements(function, overload) {
+				missing = append(missing, overload.GetOverloadId())
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		_, found := i.dispatcher.FindOverload(function)
+		if !found && (!isDefault || len(d.functions[function]) == 0) {
+			mismatches = append(mismatches,
+				fmt.Sprintf("no overload for declared function '%s'", function))
+			continue
+		}
+		for _, id := range missing {
+			mismatches = append(mismatches,
+				fmt.Sprintf("no overload '%s' of declared function '%s'", id, function))
+		}
+	}
+	if isDefault {
+		var undeclared []string
+		for name := range d.overloads {
+			if !declared[name] && !internalOverloads[name] {
+				undeclared = append(undeclared, name)
+			}
+		}
+		sort.Strings(undeclared)
+		for _, name := range undeclared {
+			mismatches = append(mismatches,
+				fmt.Sprintf("no declaration of overload '%s'", name))
+		}
+	}
+	if len(mismatches) > 0 {
+		return &CompatibilityError{Mismatches: mismatches}
+	}
+	return nil
+}
+
+// implements returns whether the Interpreter implements the declared overload
+// of the function, as described by ValidateDeclarations.
+func (i *exprInterpreter) implements(function string,
+	overload *checkedpb.Decl_FunctionDecl_Overload) bool {
+	params := make([]ref.Type, len(overload.GetParams()))
+	for idx, param := range overload.GetParams() {
+		params[idx] = i.runtimeType(param)
+	}
+	o, found := i.dispatcher.FindOverload(overload.GetOverloadId())
+	if found && (o.OverloadOf == "" || o.OverloadOf == function) {
+		return acceptsParams(o, params)
+	}
+	generic, found := i.dispatcher.FindOverload(function)
+	d, isDefault := i.dispatcher.(*defaultDispatcher)
+	if !isDefault || len(d.functions[function]) == 0 {
+		return found
+	}
+	covered := false
+	for _, candidate := range d.functions[function] {
+		if acceptsParams(candidate, params) {
+			return true
+		}
+		if len(params) != 0 && len(candidate.ArgTypes) != 0 &&
+			params[0] != nil && candidate.ArgTypes[0] != nil &&
+			params[0].TypeName() == candidate.ArgTypes[0].TypeName() {
+			covered = true
+		}
+	}
+	return found && !covered && len(params) != 0 && params[0] != nil &&
+		params[0].HasTrait(generic.OperandTrait)
+}
+
+// acceptsParams returns whether the argument types of the overload accept the
+// runtime types of the declared parameters, where a nil parameter type may be
+// of any type.
+func acceptsParams(overload *functions.Overload, params []ref.Type) bool {
+	if overload.ArgTypes == nil {
+		return true
+	}
+	if len(overload.ArgTypes) != len(params) {
+		return false
+	}
+	for idx, t := range overload.ArgTypes {
+		if t != nil && params[idx] != nil &&
+			t.TypeName() != params[idx].TypeName() {
+			return false
+		}
+	}
+	return true
+}
+
+// runtimeType returns the type of the values of the checked type, or nil when
+// they may be of more than one type, such as values of dyn or of a type
+// parameter.
+func (i *exprInterpreter) runtimeType(t *checkedpb.Type) ref.Type {
+	var typeName string
+	switch t.GetTypeKind().(type) {
+	case *checkedpb.Type_Primitive:
+		switch t.GetPrimitive() {
+		case checkedpb.Type_BOOL:
+			return types.BoolType
+		case checkedpb.Type_INT64:
+			return types.IntType
+		case checkedpb.Type_UINT64:
+			return types.UintType
+		case checkedpb.Type_DOUBLE:
+			return types.DoubleType
+		case checkedpb.Type_STRING:
+			return types.StringType
+		case checkedpb.Type_BYTES:
+			return types.BytesType
+		}
+		return nil
+	case *checkedpb.Type_WellKnown:
+		switch t.GetWellKnown() {
+		case checkedpb.Type_TIMESTAMP:
+			return types.TimestampType
+		case checkedpb.Type_DURATION:
+			return types.DurationType
+		}
+		return nil
+	case *checkedpb.Type_ListType_:
+		return types.ListType
+	case *checkedpb.Type_MapType_:
+		return types.MapType
+	case *checkedpb.Type_MessageType:
+		typeName = t.GetMessageType()
+	default:
+		return nil
+	}
+	if value, found := i.typeProvider.FindIdent(typeName); found {
+		if runtimeType, isType := value.(ref.Type); isType {
+			return runtimeType
+		}
+	}
+	return nil
+}
+
+// internalOverloads are called by the instructions planned for
+// comprehensions, rather than by expressions.
+var internalOverloads = map[string]bool{
+	overloads.Iterator: true,
+	overloads.HasNext:  true,
+	overloads.Next:     true,
+}
+
+func (i *exprInterpreter) hasType(typeName string) bool {
+	for _, candidate := range i.packager.ResolveCandidateNames(typeName) {
+		if _, found := i.typeProvider.FindType(candidate); found {
+			return true
+		}
+	}
+	return false
+}
+
+// newRequirements computes the requirements of a set of instructions planned
+// for the program with the given Dispatcher.
+func newRequirements(p *exprProgram, instructions []Instruction,
+	dispatcher Dispatcher) *Requirements {
+	functions := make(map[string]bool)
+	typeNames := make(map[string]bool)
+	features := make(map[string]bool)
+	for _, feature := range optionFeatures(p) {
+		features[feature] = true
+	}
+	for _, inst := range instructions {
+		switch i := inst.(type) {
+		case *CallExpr:
+			if _, found := dispatcher.FindOverload(i.Function); found {
+				functions[i.Function] = true
+			}
+		case *CreateObjectExpr:
+			typeNames[i.Name] = true
+		case *IndexExpr:
+			features[FeatureTypedIndex] = true
+		}
+	}
+	return &Requirements{
+		Functions: sortedKeys(functions),
+		Types:     sortedKeys(typeNames),
+		Features:  sortedKeys(features)}
+}
+
+// optionFeatures returns the features of the options of the program which
+// change the results of its evaluations.
+func optionFeatures(p *exprProgram) []string {
+	var features []string
+	if p.fuse {
+		features = append(features, FeatureFusedInstructions)
+	}
+	if p.propagateNull {
+		features = append(features, FeaturePropagateNullSelect)
+	}
+	switch p.dynDispatch {
+	case NullOnMismatch:
+		features = append(features, FeatureDynDispatchNull)
+	case CoerceStringsOnMismatch:
+		features = append(features, FeatureDynDispatchCoerceStrings)
+	}
+	if p.divisionDefault != nil {
+		features = append(features, FeatureDivisionByZeroDefault)
+	}
+	if p.redactErrors {
+		features = append(features, FeatureRedactErrors)
+	}
+	return features
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/google/cel-go/parser"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+func TestProgramRequirements(t *testing.T) {
+	program := checkedProgram(t, "elems[0] + size(elems) > 1",
+		decls.NewIdent("elems", decls.NewListType(decls.Int), nil))
+	if _, found := ProgramRequirements(program); found {
+		t.Error("Got requirements for an uninitialized program")
+	}
+	interpreter.NewInterpretable(program)
+	reqs, found := ProgramRequirements(program)
+	if !found {
+		t.Fatal("Expected requirements for an initialized program")
+	}
+	expected := &Requirements{
+		Functions: []string{"_+_", "_>_", "size"},
+		Features:  []string{FeatureTypedIndex}}
+	if fmt.Sprint(reqs) != fmt.Sprint(expected) {
+		t.Errorf("Got %v, wanted %v", reqs, expected)
+	}
+}
+
+func TestLoadProgram(t *testing.T) {
+	parsed, errors := parser.ParseText(
+		"[v1.Expr{id: size('abc') + 1}] != [] && x")
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	pkg := packages.NewPackage("google.api.expr")
+	provider := types.NewProvider(&expr.Expr{})
+	program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+	NewStandardIntepreter(pkg, provider).NewInterpretable(program)
+	serialized, err := MarshalProgram(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Compatible environment.
+	if _, err := LoadProgram(serialized,
+		NewStandardIntepreter(pkg, provider)); err != nil {
+		t.Error(err)
+	}
+
+	// Environment without the message type or the size and addition functions.
+	dispatcher := NewDispatcher()
+	for _, o := range functions.StandardOverloads() {
+		if o.Operator != "size" && o.Operator != "_+_" {
+			dispatcher.Add(o)
+		}
+	}
+	_, err = LoadProgram(serialized, NewInterpreter(dispatcher,
+		packages.DefaultPackage, types.NewProvider()))
+	compatErr, ok := err.(*CompatibilityError)
+	if !ok {
+		t.Fatalf("Got error %v, wanted a CompatibilityError", err)
+	}
+	expected := []string{
+		"no overload for function '_+_'",
+		"no overload for function 'size'",
+		"unknown type 'v1.Expr'",
+	}
+	if fmt.Sprint(compatErr.Mismatches) != fmt.Sprint(expected) {
+		t.Errorf("Got mismatches %v, wanted %v", compatErr.Mismatches, expected)
+	}
+}
+
+func TestLoadProgram_Options(t *testing.T) {
+	parsed, errors := parser.ParseText("m.missing.name == null || x + 1 == null")
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+		PropagateNullSelect(), DynDispatch(NullOnMismatch))
+	interpreter.NewInterpretable(program)
+	reqs, _ := ProgramRequirements(program)
+	expected := []string{FeatureDynDispatchNull, FeaturePropagateNullSelect}
+	if fmt.Sprint(reqs.Features) != fmt.Sprint(expected) {
+		t.Errorf("Got features %v, wanted %v", reqs.Features, expected)
+	}
+	serialized, err := MarshalProgram(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The options the program was planned with.
+	if _, err := LoadProgram(serialized, interpreter,
+		PropagateNullSelect(), DynDispatch(NullOnMismatch)); err != nil {
+		t.Error(err)
+	}
+
+	// The results of the program would differ without the options.
+	_, err = LoadProgram(serialized, interpreter, DynDispatch(CoerceStringsOnMismatch))
+	compatErr, ok := err.(*CompatibilityError)
+	if !ok {
+		t.Fatalf("Got error %v, wanted a CompatibilityError", err)
+	}
+	mismatches := []string{
+		"feature 'dyn_dispatch_null' is not configured",
+		"feature 'propagate_null_select' is not configured",
+	}
+	if fmt.Sprint(compatErr.Mismatches) != fmt.Sprint(mismatches) {
+		t.Errorf("Got mismatches %v, wanted %v", compatErr.Mismatches, mismatches)
+	}
+}
+
+func TestValidateDeclarations(t *testing.T) {
+	errors := common.NewErrors(common.NewStringSource("", "<input>"))
+	env := checker.NewStandardEnv(packages.DefaultPackage,
+		types.NewProvider(&expr.ParsedExpr{}), errors)
+	if err := ValidateDeclarations(interpreter, env.Functions()); err != nil {
+		t.Fatalf("Got '%v' for the standard environment", err)
+	}
+	program := checkedProgram(t, "'abc'.matches('a.c') && timestamp('2018-01-01T00:00:00Z').getFullYear() == 2018")
+	if res, _ := interpreter.NewInterpretable(program).Eval(NewActivation(map[string]interface{}{})); res != types.True {
+		t.Errorf("Got '%v', wanted true", res)
+	}
+
+	env.Add(checker.GeoDeclarations()...)
+	interp := NewInterpreter(dispatcher(&functions.Overload{Operator: "orphan",
+		Unary: func(value ref.Value) ref.Value {
+			return value
+		}}), packages.DefaultPackage, types.NewProvider())
+	err := ValidateDeclarations(interp, env.Functions())
+	expected := "program is incompatible with the environment:\n" +
+		"  - no overload for declared function 'geo.country'\n" +
+		"  - no overload for declared function 'geo.region'\n" +
+		"  - no declaration of overload 'orphan'"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Got '%v', wanted '%s'", err, expected)
+	}
+}
+
+func TestValidateDeclarations_Overloads(t *testing.T) {
+	errors := common.NewErrors(common.NewStringSource("", "<input>"))
+	provider := types.NewProvider()
+	provider.RegisterType(types.NewTypeValue("acme.Point"),
+		types.NewTypeValue("acme.Version", traits.ComparerType))
+	env := checker.NewStandardEnv(packages.DefaultPackage, provider, errors)
+	point := decls.NewObjectType("acme.Point")
+	env.Add(
+		decls.NewFunction("describe",
+			decls.NewOverload("describe_int", []*checkedpb.Type{decls.Int}, decls.String),
+			decls.NewOverload("describe_string", []*checkedpb.Type{decls.String}, decls.String)),
+		decls.NewFunction(operators.Add,
+			decls.NewOverload("add_acme_point", []*checkedpb.Type{point, point}, point)))
+	interp := NewInterpreter(dispatcher(&functions.Overload{Operator: "describe_int",
+		OverloadOf: "describe",
+		ArgTypes:   []ref.Type{types.IntType},
+		Unary: func(value ref.Value) ref.Value {
+			return value.ConvertToType(types.StringType)
+		}}), packages.DefaultPackage, provider)
+	err := ValidateDeclarations(interp, env.Functions())
+	expected := "program is incompatible with the environment:\n" +
+		"  - no overload 'add_acme_point' of declared function '_+_'\n" +
+		"  - no overload 'describe_string' of declared function 'describe'"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Got '%v', wanted '%s'", err, expected)
+	}
+}
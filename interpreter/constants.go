@@ -0,0 +1,108 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"math"
+	"sync"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Constants is a pool of literal values which are materialized once and
+// shared by every program initialized with the pool.
+//
+// Literals are keyed by their type and plain value, so equal literals within
+// an expression, and across expressions, resolve to the same ref.Value. The
+// pool is safe for concurrent use by programs initialized in parallel.
+type Constants struct {
+	mutex  sync.Mutex
+	index  map[constantKey]int
+	values []ref.Value
+}
+
+// NewConstants creates an empty constant pool.
+func NewConstants() *Constants {
+	return &Constants{index: make(map[constantKey]int)}
+}
+
+// Len returns the number of distinct constants in the pool.
+func (c *Constants) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.values)
+}
+
+// literal returns the pooled value for the literal, adding it to the pool if
+// it is not already present.
+func (c *Constants) literal(lit *ast.Literal) ref.Value {
+	key := newConstantKey(lit.Value)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if i, found := c.index[key]; found {
+		return c.values[i]
+	}
+	value := literalValue(lit.Value)
+	c.index[key] = len(c.values)
+	c.values = append(c.values, value)
+	return value
+}
+
+// constantKey is a comparable form of a literal value.
+//
+// Doubles are keyed by their bit pattern so that -0.0 and 0.0 are distinct,
+// and bytes are keyed by their string form.
+type constantKey struct {
+	kind  ref.Type
+	value interface{}
+}
+
+func newConstantKey(value interface{}) constantKey {
+	switch v := value.(type) {
+	case bool:
+		return constantKey{types.BoolType, v}
+	case []byte:
+		return constantKey{types.BytesType, string(v)}
+	case float64:
+		return constantKey{types.DoubleType, math.Float64bits(v)}
+	case int64:
+		return constantKey{types.IntType, v}
+	case string:
+		return constantKey{types.StringType, v}
+	case uint64:
+		return constantKey{types.UintType, v}
+	}
+	return constantKey{kind: types.NullType}
+}
+
+func literalValue(value interface{}) ref.Value {
+	switch v := value.(type) {
+	case bool:
+		return types.Bool(v)
+	case []byte:
+		return types.Bytes(v)
+	case float64:
+		return types.Double(v)
+	case int64:
+		return types.Int(v)
+	case string:
+		return types.String(v)
+	case uint64:
+		return types.Uint(v)
+	}
+	return types.NullValue
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/parser"
+)
+
+func TestConstants_Shared(t *testing.T) {
+	parsed, errors := parser.ParseText(
+		"x in [1, 2, 1, 'a', 'a', 2.0, -0.0, 0.0, 2u, b'a', true, null]")
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	constants := NewConstants()
+	first := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+		SharedConstants(constants))
+	second := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+		SharedConstants(constants))
+	interpreter.NewInterpretable(first)
+	interpreter.NewInterpretable(second)
+	if constants.Len() != 9 {
+		t.Errorf("Got %d constants, wanted 9", constants.Len())
+	}
+
+	// Programs may be reused across interpretables once initialized.
+	for _, i := range []Interpretable{
+		interpreter.NewInterpretable(first),
+		interpreter.NewInterpretable(first)} {
+		res, _ := i.Eval(NewActivation(map[string]interface{}{"x": "a"}))
+		if res != types.True {
+			t.Errorf("Got '%v', wanted 'true'", res)
+		}
+	}
+}
+
+func BenchmarkConstants_NewInterpretable(b *testing.B) {
+	parsed, errors := parser.ParseText(
+		"x in [1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16]")
+	if len(errors.GetErrors()) != 0 {
+		b.Fatal(errors.ToDisplayString())
+	}
+	program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+	activation := NewActivation(map[string]interface{}{"x": 16})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		interpreter.NewInterpretable(program).Eval(activation)
+	}
+}
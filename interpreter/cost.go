@@ -0,0 +1,474 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"math"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// The cost of an evaluation is the number of identifier resolutions, field
+// selections, function calls, and aggregate constructions it performs.
+// Literals and references to the variables of comprehensions are free, and
+// the expressions of a comprehension are charged each time they are
+// evaluated, so the cost of a comprehension grows with the size of its range.
+//
+// Calls whose work grows with the sizes of their arguments, such as string
+// and list concatenation, 'in' on lists, and functions such as 'matches', are
+// also charged one unit for every sizeCostUnit bytes of their string and
+// bytes arguments and elements of their list arguments, as the CEL cost
+// model charges a fraction of a unit per byte traversed.
+
+const (
+	// sizeCostUnit is the number of bytes or list elements of the arguments
+	// of a call which cost one unit.
+	sizeCostUnit = 10
+)
+
+// CostEstimate is the range of the possible costs of evaluating a Program.
+type CostEstimate struct {
+	Min uint64
+	// Max is math.MaxUint64 when the cost is unbounded, e.g. for a
+	// comprehension over a list whose size is not known until evaluation.
+	Max uint64
+}
+
+// SizeEstimator bounds the sizes of the lists, maps, strings, and bytes
+// referenced by an expression, so that EstimateCost may bound the cost of the
+// comprehensions over them and of the calls which they are passed to.
+type SizeEstimator interface {
+	// EstimateSize returns the maximum number of elements of the list or map,
+	// or the maximum length in bytes of the string or bytes, held by the
+	// attribute, such as 'a' or 'a.b.c', or false if the size is unbounded.
+	EstimateSize(attribute string) (uint64, bool)
+}
+
+// SizeEstimates is a SizeEstimator which bounds the sizes of attributes by
+// name.
+type SizeEstimates map[string]uint64
+
+// EstimateSize implements the SizeEstimator interface method.
+func (s SizeEstimates) EstimateSize(attribute string) (uint64, bool) {
+	size, found := s[attribute]
+	return size, found
+}
+
+// EstimateCost returns the range of the possible costs of evaluating a
+// Program created by this package.
+//
+// The cost of a comprehension is bounded by the size of its range, which is
+// known statically for list and map literals, and which the optional
+// SizeEstimator provides for attributes. The sizes of the lists produced by
+// the 'map' and 'filter' macros, and by list concatenation, are derived from
+// the sizes of their operands. Comprehensions over other ranges have
+// unbounded costs, as do the calls charged for the sizes of their arguments
+// when the arguments may be strings, bytes, or lists of unknown size.
+//
+// Programs created by NewCheckedProgram only consult the SizeEstimator for
+// the attributes whose checked types are lists, maps, strings, bytes, or dyn,
+// and the calls of their other arguments are not charged for sizes. Calls which are
+// folded into constants when the program is initialized, such as matchers of
+// constant lists, are estimated as constants once the program has been
+// initialized.
+func EstimateCost(program Program, sizes SizeEstimator) CostEstimate {
+	p, isExprProgram := program.(*exprProgram)
+	if !isExprProgram {
+		return CostEstimate{Min: 0, Max: math.MaxUint64}
+	}
+	return (&costEstimator{program: p, sizes: sizes}).estimate(p.expression)
+}
+
+type costEstimator struct {
+	program *exprProgram
+	sizes   SizeEstimator
+	// vars are the names of the comprehension variables in scope.
+	vars []string
+	// accuSizes bound the sizes of the accumulators of the comprehensions in
+	// scope which build lists, such as those of the 'map' and 'filter'
+	// macros, by variable name.
+	accuSizes map[string]uint64
+}
+
+func (c *costEstimator) estimate(e *ast.Expr) CostEstimate {
+	if _, found := c.program.literals[e.Id]; found {
+		return CostEstimate{}
+	}
+	if _, found := c.program.compiled[e.Id]; found {
+		return CostEstimate{}
+	}
+	switch kind := e.Kind.(type) {
+	case *ast.Literal:
+		return CostEstimate{}
+	case *ast.Ident:
+		if c.inScope(kind.Name) {
+			return CostEstimate{}
+		}
+		return CostEstimate{Min: 1, Max: 1}
+	case *ast.Select:
+		return c.estimate(kind.Operand).add(CostEstimate{Min: 1, Max: 1})
+	case *ast.Call:
+		return c.estimateCall(kind)
+	case *ast.CreateList:
+		cost := CostEstimate{Min: 1, Max: 1}
+		for _, elem := range kind.Elements {
+			cost = cost.add(c.estimate(elem))
+		}
+		return cost
+	case *ast.CreateStruct:
+		cost := CostEstimate{Min: 1, Max: 1}
+		for _, entry := range kind.Entries {
+			if entry.MapKey != nil {
+				cost = cost.add(c.estimate(entry.MapKey))
+			}
+			cost = cost.add(c.estimate(entry.Value))
+		}
+		return cost
+	case *ast.Comprehension:
+		return c.estimateComprehension(kind)
+	}
+	return CostEstimate{}
+}
+
+func (c *costEstimator) estimateCall(call *ast.Call) CostEstimate {
+	args := getArgs(call)
+	switch call.Function {
+	case operators.LogicalAnd, operators.LogicalOr:
+		if len(args) == 2 {
+			// The right-hand side is not evaluated when the left-hand side
+			// determines the result.
+			lhs, rhs := c.estimate(args[0]), c.estimate(args[1])
+			return CostEstimate{
+				Min: addCost(1, lhs.Min),
+				Max: addCost(1, addCost(lhs.Max, rhs.Max))}
+		}
+	case operators.Conditional:
+		if len(args) == 3 {
+			// Neither branch is evaluated when the condition is unknown or an
+			// error.
+			cond := c.estimate(args[0])
+			trueCost, falseCost := c.estimate(args[1]), c.estimate(args[2])
+			return CostEstimate{
+				Min: addCost(1, cond.Min),
+				Max: addCost(1, addCost(cond.Max, maxUint64(trueCost.Max, falseCost.Max)))}
+		}
+	}
+	cost := CostEstimate{Min: 1, Max: 1}
+	for _, arg := range args {
+		cost = cost.add(c.estimate(arg))
+		if sizedCall(call.Function) {
+			cost.Max = addCost(cost.Max, c.argumentSizeCost(arg))
+		}
+	}
+	return cost
+}
+
+// argumentSizeCost bounds the cost charged for the size of an argument of a
+// call whose work grows with the sizes of its arguments.
+func (c *costEstimator) argumentSizeCost(arg *ast.Expr) uint64 {
+	if val, found := c.program.literals[arg.Id]; found {
+		return sizeCost(val)
+	}
+	switch kind := arg.Kind.(type) {
+	case *ast.Literal:
+		switch value := kind.Value.(type) {
+		case string:
+			return uint64(len(value)) / sizeCostUnit
+		case []byte:
+			return uint64(len(value)) / sizeCostUnit
+		}
+		return 0
+	case *ast.CreateStruct:
+		return 0
+	}
+	if !c.mayBeSized(arg.Id) {
+		return 0
+	}
+	if size, found := c.size(arg); found {
+		return size / sizeCostUnit
+	}
+	return math.MaxUint64
+}
+
+func (c *costEstimator) estimateComprehension(comp *ast.Comprehension) CostEstimate {
+	cost := c.estimate(comp.IterRange)
+	size, found := c.size(comp.IterRange)
+	if !found {
+		size = math.MaxUint64
+	}
+	c.vars = append(c.vars, comp.AccuVar, comp.IterVar)
+	if accuSize, found := c.comprehensionSize(comp); found {
+		previous, shadowed := c.accuSizes[comp.AccuVar]
+		if c.accuSizes == nil {
+			c.accuSizes = make(map[string]uint64)
+		}
+		c.accuSizes[comp.AccuVar] = accuSize
+		if shadowed {
+			defer func() { c.accuSizes[comp.AccuVar] = previous }()
+		} else {
+			defer delete(c.accuSizes, comp.AccuVar)
+		}
+	}
+	cost = cost.add(c.estimate(comp.AccuInit)).add(c.estimate(comp.Result))
+	// The loop may end before the first iteration, while each iteration
+	// evaluates the loop condition and step at most once.
+	iteration := c.estimate(comp.LoopCondition).add(c.estimate(comp.LoopStep))
+	c.vars = c.vars[:len(c.vars)-2]
+	cost.Max = addCost(cost.Max, mulCost(size, iteration.Max))
+	return cost
+}
+
+// size returns the maximum number of elements of the list or map produced by
+// an expression, or false if the number is unbounded.
+func (c *costEstimator) size(e *ast.Expr) (uint64, bool) {
+	if val, found := c.program.literals[e.Id]; found {
+		if sizer, isSizer := val.(traits.Sizer); isSizer {
+			if size, isInt := sizer.Size().(types.Int); isInt && size >= 0 {
+				return uint64(size), true
+			}
+		}
+		return 0, false
+	}
+	switch kind := e.Kind.(type) {
+	case *ast.CreateList:
+		return uint64(len(kind.Elements)), true
+	case *ast.CreateStruct:
+		if kind.MessageName == "" {
+			return uint64(len(kind.Entries)), true
+		}
+	case *ast.Literal:
+		switch value := kind.Value.(type) {
+		case string:
+			return uint64(len(value)), true
+		case []byte:
+			return uint64(len(value)), true
+		}
+	case *ast.Ident, *ast.Select:
+		if ident, isIdent := kind.(*ast.Ident); isIdent && c.inScope(ident.Name) {
+			size, found := c.accuSizes[ident.Name]
+			return size, found
+		}
+		if c.sizes == nil || !c.mayBeContainer(e.Id) {
+			return 0, false
+		}
+		if name, root, found := attributeName(e); found && !c.inScope(root) {
+			return c.sizes.EstimateSize(name)
+		}
+	case *ast.Call:
+		args := getArgs(kind)
+		if kind.Function == operators.Add && len(args) == 2 && c.mayBeContainer(e.Id) {
+			lhs, lhsFound := c.size(args[0])
+			rhs, rhsFound := c.size(args[1])
+			return addCost(lhs, rhs), lhsFound && rhsFound
+		}
+	case *ast.Comprehension:
+		return c.comprehensionSize(kind)
+	}
+	return 0, false
+}
+
+// comprehensionSize bounds the size of the list produced by a comprehension
+// which, like those of the 'map' and 'filter' macros, starts from an empty
+// list and appends at most the elements of a list literal on each iteration.
+func (c *costEstimator) comprehensionSize(comp *ast.Comprehension) (uint64, bool) {
+	init, isList := comp.AccuInit.Kind.(*ast.CreateList)
+	if !isList || len(init.Elements) != 0 {
+		return 0, false
+	}
+	if result, isIdent := comp.Result.Kind.(*ast.Ident); !isIdent ||
+		result.Name != comp.AccuVar {
+		return 0, false
+	}
+	appended, found := appendedElements(comp.LoopStep, comp.AccuVar)
+	if !found {
+		return 0, false
+	}
+	size, found := c.size(comp.IterRange)
+	if !found {
+		return 0, false
+	}
+	return mulCost(size, appended), true
+}
+
+// appendedElements returns the maximum number of elements which a loop step
+// appends to the accumulator, or false if the step does not have the form of
+// an append.
+func appendedElements(step *ast.Expr, accuVar string) (uint64, bool) {
+	switch kind := step.Kind.(type) {
+	case *ast.Ident:
+		return 0, kind.Name == accuVar
+	case *ast.Call:
+		switch {
+		case kind.Function == operators.Add && len(kind.Args) == 2:
+			accu, isIdent := kind.Args[0].Kind.(*ast.Ident)
+			elems, isList := kind.Args[1].Kind.(*ast.CreateList)
+			if isIdent && accu.Name == accuVar && isList {
+				return uint64(len(elems.Elements)), true
+			}
+		case kind.Function == operators.Conditional && len(kind.Args) == 3:
+			lhs, lhsFound := appendedElements(kind.Args[1], accuVar)
+			rhs, rhsFound := appendedElements(kind.Args[2], accuVar)
+			return maxUint64(lhs, rhs), lhsFound && rhsFound
+		}
+	}
+	return 0, false
+}
+
+// mayBeContainer returns whether the checked type of an expression is a list,
+// map, or dyn. The values of unchecked expressions may be of any type.
+func (c *costEstimator) mayBeContainer(id int64) bool {
+	t, found := c.program.typeMap[id]
+	if !found {
+		return true
+	}
+	switch t.TypeKind.(type) {
+	case *checkedpb.Type_ListType_, *checkedpb.Type_MapType_, *checkedpb.Type_Dyn:
+		return true
+	}
+	return false
+}
+
+// mayBeSized returns whether the checked type of an expression is a string,
+// bytes, list, or dyn, whose size is charged for by the calls it is passed to.
+// The values of unchecked expressions may be of any type.
+func (c *costEstimator) mayBeSized(id int64) bool {
+	t, found := c.program.typeMap[id]
+	if !found {
+		return true
+	}
+	switch t.TypeKind.(type) {
+	case *checkedpb.Type_ListType_, *checkedpb.Type_Dyn:
+		return true
+	case *checkedpb.Type_Primitive:
+		switch t.GetPrimitive() {
+		case checkedpb.Type_STRING, checkedpb.Type_BYTES:
+			return true
+		}
+	}
+	return false
+}
+
+// inScope returns whether a comprehension variable of the name is in scope.
+func (c *costEstimator) inScope(name string) bool {
+	for _, v := range c.vars {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c CostEstimate) add(other CostEstimate) CostEstimate {
+	return CostEstimate{
+		Min: addCost(c.Min, other.Min),
+		Max: addCost(c.Max, other.Max)}
+}
+
+// addCost adds costs, saturating at math.MaxUint64.
+func addCost(x, y uint64) uint64 {
+	if x > math.MaxUint64-y {
+		return math.MaxUint64
+	}
+	return x + y
+}
+
+// mulCost multiplies costs, saturating at math.MaxUint64.
+func mulCost(x, y uint64) uint64 {
+	if x != 0 && y > math.MaxUint64/x {
+		return math.MaxUint64
+	}
+	return x * y
+}
+
+func maxUint64(x, y uint64) uint64 {
+	if x > y {
+		return x
+	}
+	return y
+}
+
+// instructionCost returns the cost of an instruction of the stepper. The
+// instructions which implement comprehensions and control flow are free.
+func instructionCost(inst Instruction) uint64 {
+	switch inst := inst.(type) {
+	case *IdentExpr, *SelectExpr, *CompareConstExpr, *IndexExpr,
+		*CreateListExpr, *CreateMapExpr, *CreateObjectExpr:
+		return 1
+	case *SelectPathExpr:
+		cost := uint64(len(inst.Selects))
+		if inst.Ident != nil {
+			cost++
+		}
+		return cost
+	case *CallExpr:
+		switch inst.Function {
+		case overloads.Iterator, overloads.HasNext, overloads.Next:
+			return 0
+		}
+		return 1
+	}
+	return 0
+}
+
+// sizedCall returns whether the work of a call of the function grows with the
+// sizes of its arguments, as for concatenation, 'in', and the functions which
+// are not operators, such as 'contains' and 'matches'. Operators and internal
+// functions, such as '_==_', '!_', and '@next', are named with a leading '_',
+// '!', '-', or '@', and 'size' is constant.
+func sizedCall(function string) bool {
+	switch function {
+	case operators.Add, operators.In:
+		return true
+	case overloads.Size, "":
+		return false
+	}
+	return !strings.ContainsRune("_!-@", rune(function[0]))
+}
+
+// sizeCost returns the cost charged for the size of an argument of a call
+// whose work grows with the sizes of its arguments.
+func sizeCost(value ref.Value) uint64 {
+	switch v := value.(type) {
+	case types.String:
+		return uint64(len(v)) / sizeCostUnit
+	case types.Bytes:
+		return uint64(len(v)) / sizeCostUnit
+	}
+	if value != nil && value.Type() == types.ListType {
+		if size, isInt := value.(traits.Sizer).Size().(types.Int); isInt && size > 0 {
+			return uint64(size) / sizeCostUnit
+		}
+	}
+	return 0
+}
+
+// costLimitExceeded is the result of an evaluation whose cost exceeded the
+// limit.
+func costLimitExceeded(limit uint64) ref.Value {
+	return types.NewErr("cost limit of %d exceeded", limit)
+}
+
+// iterationLimitExceeded is the result of an evaluation whose comprehensions
+// visited more elements than the limit.
+func iterationLimitExceeded(limit uint64) ref.Value {
+	return types.NewErr("iteration limit of %d exceeded", limit)
+}
@@ -0,0 +1,211 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/parser"
+)
+
+func TestEstimateCost(t *testing.T) {
+	for _, tst := range []struct {
+		text     string
+		sizes    SizeEstimates
+		expected CostEstimate
+	}{
+		{text: "1", expected: CostEstimate{}},
+		{text: "a.b.c", expected: CostEstimate{Min: 3, Max: 3}},
+		// The operands of unchecked additions may be strings or lists.
+		{text: "a + 1 < b", expected: CostEstimate{Min: 4, Max: math.MaxUint64}},
+		{text: "a + 1 < b",
+			sizes:    SizeEstimates{"a": 40},
+			expected: CostEstimate{Min: 4, Max: 8}},
+		{text: "'abcdefghij' + 'abcdefghij'", expected: CostEstimate{Min: 1, Max: 3}},
+		{text: "a && b.c", expected: CostEstimate{Min: 2, Max: 4}},
+		{text: "a ? b : c.d", expected: CostEstimate{Min: 2, Max: 4}},
+		{text: "[a, 1, {'k': b}]", expected: CostEstimate{Min: 4, Max: 4}},
+		{text: "[1, 2, 3].exists(x, x > a)",
+			expected: CostEstimate{Min: 1, Max: 13}},
+		{text: "elems.all(x, x > 0)",
+			expected: CostEstimate{Min: 1, Max: math.MaxUint64}},
+		{text: "elems.all(x, x > 0)",
+			sizes:    SizeEstimates{"elems": 10},
+			expected: CostEstimate{Min: 1, Max: 21}},
+		{text: "a.elems.map(x, x * 2).exists(y, y > b)",
+			sizes:    SizeEstimates{"a.elems": 4},
+			expected: CostEstimate{Min: 3, Max: 31}},
+		{text: "(elems + [1, 2]).filter(x, x > 0).size()",
+			sizes:    SizeEstimates{"elems": 4},
+			expected: CostEstimate{Min: 5, Max: 29}},
+		{text: "elems.exists(x, x.all(y, y > 0))",
+			sizes:    SizeEstimates{"elems": 4, "x": 2},
+			expected: CostEstimate{Min: 1, Max: math.MaxUint64}},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+		if cost := EstimateCost(program, tst.sizes); cost != tst.expected {
+			t.Errorf("%s: got %+v, wanted %+v", tst.text, cost, tst.expected)
+		}
+	}
+}
+
+// recordedSizes records the attributes whose sizes are estimated.
+type recordedSizes struct {
+	SizeEstimates
+	attributes []string
+}
+
+func (r *recordedSizes) EstimateSize(attribute string) (uint64, bool) {
+	r.attributes = append(r.attributes, attribute)
+	return r.SizeEstimates.EstimateSize(attribute)
+}
+
+func TestEstimateCost_Checked(t *testing.T) {
+	program := checkedProgram(t, "(items + [n]).exists(x, x > n) && req.tags.all(k, k != '')",
+		decls.NewIdent("items", decls.NewListType(decls.Int), nil),
+		decls.NewIdent("n", decls.Int, nil),
+		decls.NewIdent("req", decls.NewMapType(decls.String, decls.Dyn), nil))
+	sizes := &recordedSizes{SizeEstimates: SizeEstimates{"items": 3, "req.tags": 2}}
+	expected := CostEstimate{Min: 5, Max: 27}
+	if cost := EstimateCost(program, sizes); cost != expected {
+		t.Errorf("Got %+v, wanted %+v", cost, expected)
+	}
+	// The size of items bounds both the cost of the concatenation and the
+	// size of the list it produces.
+	if !reflect.DeepEqual(sizes.attributes, []string{"items", "items", "req.tags"}) {
+		t.Errorf("Got estimates of %v, wanted items and req.tags", sizes.attributes)
+	}
+}
+
+func TestActualCost(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"a":     1,
+		"b":     true,
+		"m":     map[string]interface{}{"k": map[string]int{"n": 2}},
+		"elems": []int64{1, 2, 3, 4},
+		"s":     strings.Repeat("a", 1000),
+		"t":     strings.Repeat("b", 1000),
+		"l":     make([]int64, 100),
+		"r":     make([]int64, 100)})
+	for _, tst := range []struct {
+		text     string
+		expected uint64
+	}{
+		{text: "m.k.n + a", expected: 5},
+		{text: "b || a / 0 == 1", expected: 2},
+		{text: "!b || a / 0 == 1", expected: 6},
+		{text: "b ? m.k : a", expected: 4},
+		{text: "[1, 2, 3].exists(x, x > a)", expected: 10},
+		{text: "elems.exists(x, x > a)", expected: 10},
+		{text: "elems.map(x, x * 2)", expected: 14},
+		{text: "{'k': [a, b]}", expected: 4},
+		// Calls are charged for the sizes of their string and list arguments.
+		{text: "s + t", expected: 203},
+		{text: "s.matches('a+')", expected: 102},
+		{text: "a in l + r", expected: 45},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		for _, opts := range [][]ProgramOption{nil, {TreeEvaluation()}} {
+			program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(), opts...)
+			_, state := interpreter.NewInterpretable(program).Eval(activation)
+			cost, found := ActualCost(state)
+			if !found || cost != tst.expected {
+				t.Errorf("%s: got cost %d with tree evaluation %t, wanted %d",
+					tst.text, cost, program.Config().TreeEvaluation, tst.expected)
+			}
+			estimate := EstimateCost(program, SizeEstimates{"elems": 4})
+			if cost < estimate.Min || cost > estimate.Max {
+				t.Errorf("%s: got cost %d outside of the estimate %+v",
+					tst.text, cost, estimate)
+			}
+		}
+	}
+}
+
+func TestCostLimit(t *testing.T) {
+	program := checkedProgram(t, "elems.exists(x, x < 0)",
+		decls.NewIdent("elems", decls.NewListType(decls.Int), nil))
+	elems := make([]int64, 100)
+	for i := range elems {
+		elems[i] = int64(i)
+	}
+	activation := NewActivation(map[string]interface{}{"elems": elems})
+	for _, opts := range [][]ProgramOption{
+		{CostLimit(100)},
+		{CostLimit(100), TreeEvaluation()},
+	} {
+		for _, opt := range opts {
+			opt(program.(*exprProgram))
+		}
+		res, state := interpreter.NewInterpretable(program).Eval(activation)
+		if fmt.Sprint(res) != "cost limit of 100 exceeded" {
+			t.Errorf("Got '%v' with tree evaluation %t, wanted cost limit error",
+				res, program.Config().TreeEvaluation)
+		}
+		if cost, _ := ActualCost(state); cost <= 100 || cost > 110 {
+			t.Errorf("Got cost %d with tree evaluation %t, wanted evaluation to stop at the limit",
+				cost, program.Config().TreeEvaluation)
+		}
+		res, _ = interpreter.NewInterpretable(program).Eval(
+			NewActivation(map[string]interface{}{"elems": elems[:10]}))
+		if res != types.False {
+			t.Errorf("Got '%v' with tree evaluation %t, wanted false",
+				res, program.Config().TreeEvaluation)
+		}
+	}
+}
+
+func TestIterationLimit(t *testing.T) {
+	elems := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	activation := NewActivation(map[string]interface{}{"elems": elems})
+	for _, tst := range []struct {
+		limit    uint64
+		expected string
+	}{
+		{limit: 15, expected: "iteration limit of 15 exceeded"},
+		{limit: 20, expected: "false"},
+		{limit: 0, expected: "false"},
+	} {
+		for _, opts := range [][]ProgramOption{
+			{},
+			{FuseInstructions()},
+			{TreeEvaluation()},
+		} {
+			// The limit applies to the elements visited by both comprehensions.
+			program := checkedProgram(t, "elems.exists(x, x < 0) || elems.map(y, y * 2).size() == 0",
+				decls.NewIdent("elems", decls.NewListType(decls.Int), nil))
+			for _, opt := range append(opts, InterpretableWithIterationLimit(tst.limit)) {
+				opt(program.(*exprProgram))
+			}
+			res, _ := interpreter.NewInterpretable(program).Eval(activation)
+			if fmt.Sprint(res) != tst.expected {
+				t.Errorf("Got '%v' with %+v, wanted '%s'", res, program.Config(), tst.expected)
+			}
+		}
+	}
+}
@@ -16,6 +16,9 @@ package interpreter
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/common"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/common/types/traits"
@@ -55,6 +58,20 @@ func (ctx *CallContext) Args() []ref.Value {
 	return ctx.args
 }
 
+// ExprId returns the expression id of the call.
+func (ctx *CallContext) ExprId() int64 {
+	return ctx.call.GetId()
+}
+
+// Location returns the source location of the call, or false when the
+// program has no source positions.
+func (ctx *CallContext) Location() (common.Location, bool) {
+	if ctx.metadata == nil {
+		return nil, false
+	}
+	return ctx.metadata.IdLocation(ctx.call.GetId())
+}
+
 func (ctx *CallContext) String() string {
 	return fmt.Sprintf("%s with %v", ctx.call.String(), ctx.args)
 }
@@ -67,7 +84,8 @@ func (ctx *CallContext) String() string {
 //     dispatcher.add(functions.StandardOverloads())
 func NewDispatcher() Dispatcher {
 	return &defaultDispatcher{
-		overloads: make(map[string]*functions.Overload)}
+		overloads: make(map[string]*functions.Overload),
+		functions: make(map[string][]*functions.Overload)}
 }
 
 // Helper types for tracking overloads by various dimensions.
@@ -75,6 +93,10 @@ type overloadMap map[string]*functions.Overload
 
 type defaultDispatcher struct {
 	overloads overloadMap
+
+	// Overloads by the name of the function they implement, in the order in
+	// which they were added.
+	functions map[string][]*functions.Overload
 }
 
 func (d *defaultDispatcher) Add(overloads ...*functions.Overload) error {
@@ -86,33 +108,152 @@ func (d *defaultDispatcher) Add(overloads ...*functions.Overload) error {
 		}
 		// Index the overload by function and by arg count.
 		d.overloads[o.Operator] = o
+		if o.OverloadOf != "" {
+			d.functions[o.OverloadOf] = append(d.functions[o.OverloadOf], o)
+		}
 	}
 	return nil
 }
 
 func (d *defaultDispatcher) Dispatch(ctx *CallContext) ref.Value {
 	function, overloadId := ctx.Function()
-	operand := ctx.args[0]
 	if overload, found := d.overloads[function]; found {
-		if !operand.Type().HasTrait(overload.OperandTrait) {
-			return types.NewErr("no such overload")
+		if result, invoked := invokeOverload(overload, ctx.args, ctx); invoked {
+			return d.describeMismatch(function, ctx.args, result)
 		}
-		argCount := len(ctx.args)
-		if argCount == 2 && overload.Binary != nil {
-			return overload.Binary(ctx.args[0], ctx.args[1])
+	}
+	// Checked calls name their overload, while unchecked calls, and checked
+	// calls of dyn operands which do not match, are resolved by the types of
+	// their operands.
+	candidates := d.functions[function]
+	overload, found := d.overloads[overloadId]
+	if !found || overload.OverloadOf != function ||
+		!matchesArgs(overload, ctx.args) {
+		overload, found = matchOverload(candidates, ctx.args)
+	}
+	if found {
+		if result, invoked := invokeOverload(overload, ctx.args, ctx); invoked {
+			return result
 		}
-		if argCount == 1 && overload.Unary != nil {
-			return overload.Unary(ctx.args[0])
+	}
+	// Special dispatch for member functions.
+	if len(ctx.args) != 0 && ctx.args[0].Type().HasTrait(traits.ReceiverType) {
+		return ctx.args[0].(traits.Receiver).Receive(function, overloadId, ctx.args[1:])
+	}
+	return d.describeMismatch(function, ctx.args, types.NewNoSuchOverloadErr())
+}
+
+// describeMismatch returns the result of a call of the function, unless the
+// arguments matched none of its overloads and the overloads of the function
+// are typed, in which case the error lists their signatures. Errors propagated
+// from the arguments of non-strict functions are returned as they are.
+func (d *defaultDispatcher) describeMismatch(function string, args []ref.Value,
+	result ref.Value) ref.Value {
+	if !types.IsNoSuchOverload(result) {
+		return result
+	}
+	candidates := d.functions[function]
+	if len(candidates) == 0 {
+		return result
+	}
+	for _, arg := range args {
+		if types.IsUnknownOrError(arg) {
+			return result
 		}
-		if overload.Function != nil {
-			return overload.Function(ctx.args...)
+	}
+	signatures := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		signatures[i] = signature(candidate)
+	}
+	return types.NewNoMatchingOverloadErr(function, args, signatures)
+}
+
+// matchOverload returns the first of the overloads whose argument types and
+// operand trait match the arguments.
+func matchOverload(overloads []*functions.Overload,
+	args []ref.Value) (*functions.Overload, bool) {
+	for _, overload := range overloads {
+		if matchesArgs(overload, args) {
+			return overload, true
 		}
 	}
-	// Special dispatch for member functions.
-	if operand.Type().HasTrait(traits.ReceiverType) {
-		operand.(traits.Receiver).Receive(function, overloadId, ctx.args[1:])
+	return nil, false
+}
+
+func matchesArgs(overload *functions.Overload, args []ref.Value) bool {
+	if len(args) != 0 && !args[0].Type().HasTrait(overload.OperandTrait) {
+		return false
+	}
+	if overload.ArgTypes == nil {
+		return true
+	}
+	if len(args) != len(overload.ArgTypes) {
+		return false
+	}
+	for i, t := range overload.ArgTypes {
+		if t != nil && args[i].Type().TypeName() != t.TypeName() {
+			return false
+		}
+	}
+	return true
+}
+
+// signature describes the arguments accepted by an overload, e.g.
+// 'area_circle(circle)', or 'area_circle(...)' when they are not typed.
+func signature(overload *functions.Overload) string {
+	if overload.ArgTypes == nil {
+		return overload.Operator + "(...)"
+	}
+	argTypes := make([]string, len(overload.ArgTypes))
+	for i, t := range overload.ArgTypes {
+		argTypes[i] = "dyn"
+		if t != nil {
+			argTypes[i] = t.TypeName()
+		}
+	}
+	return fmt.Sprintf("%s(%s)", overload.Operator, strings.Join(argTypes, ", "))
+}
+
+// isVolatile returns whether a call of the function, with the overload id
+// resolved by the checker if any, may be dispatched to a Volatile overload,
+// whose result may differ between calls with the same arguments.
+func isVolatile(dispatcher Dispatcher, function string, overloadId string) bool {
+	for _, name := range []string{function, overloadId} {
+		if overload, found := dispatcher.FindOverload(name); found && overload.Volatile {
+			return true
+		}
+	}
+	if d, isDefault := dispatcher.(*defaultDispatcher); isDefault {
+		for _, overload := range d.functions[function] {
+			if overload.Volatile {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// invokeOverload calls the implementation of the overload which accepts the
+// number of arguments, and returns false when there is none. The context of
+// the call may be nil when the overload has no Contextual implementation.
+func invokeOverload(overload *functions.Overload, args []ref.Value,
+	ctx *CallContext) (ref.Value, bool) {
+	if len(args) != 0 && !args[0].Type().HasTrait(overload.OperandTrait) {
+		return types.NewNoSuchOverloadErr(), true
+	}
+	switch {
+	case overload.Contextual != nil:
+		return overload.Contextual(ctx), true
+	case len(args) == 2 && overload.Binary != nil:
+		return overload.Binary(args[0], args[1]), true
+	case len(args) == 1 && overload.Unary != nil:
+		return overload.Unary(args[0]), true
+	case overload.Variadic != nil:
+		return overload.Variadic.Call(args...), true
+	case overload.Function != nil:
+		return overload.Function(args...), true
 	}
-	return types.NewErr("no such overload")
+	return nil, false
 }
 
 func (d *defaultDispatcher) FindOverload(overload string) (*functions.Overload, bool) {
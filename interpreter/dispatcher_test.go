@@ -15,8 +15,12 @@
 package interpreter
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/google/cel-go/common/operators"
 	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/packages"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/interpreter/functions"
@@ -50,6 +54,211 @@ func TestDefaultDispatcher_DispatchOverload(t *testing.T) {
 	invokeCall(t, dispatcher, call, types.False)
 }
 
+func TestDefaultDispatcher_Variadic(t *testing.T) {
+	dispatcher := NewDispatcher()
+	dispatcher.Add(functions.StandardOverloads()...)
+	dispatcher.Add(&functions.Overload{
+		Operator: "concat",
+		Variadic: &functions.VariadicOp{
+			Rest: types.StringType,
+			Function: func(values ...ref.Value) ref.Value {
+				parts := make([]string, len(values))
+				for i, value := range values {
+					parts[i] = string(value.(types.String))
+				}
+				return types.String(strings.Join(parts, ""))
+			}}},
+		&functions.Overload{
+			Operator: "format",
+			Variadic: &functions.VariadicOp{
+				Params: []ref.Type{types.StringType},
+				Function: func(values ...ref.Value) ref.Value {
+					args := make([]interface{}, len(values)-1)
+					for i, value := range values[1:] {
+						args[i] = value.Value()
+					}
+					return types.String(fmt.Sprintf(string(values[0].(types.String)), args...))
+				}}})
+	interp := NewInterpreter(dispatcher, packages.DefaultPackage,
+		types.NewProvider())
+	for _, tst := range []struct {
+		text     string
+		expected ref.Value
+	}{
+		{text: `concat('a', 'b', 'c')`, expected: types.String("abc")},
+		{text: `concat('a')`, expected: types.String("a")},
+		{text: `concat()`, expected: types.String("")},
+		{text: `concat('a', 1)`, expected: types.NewNoSuchOverloadErr()},
+		{text: `format('%s-%d', 'x', 1)`, expected: types.String("x-1")},
+		{text: `format('x')`, expected: types.String("x")},
+		{text: `format(1, 'x')`, expected: types.NewNoSuchOverloadErr()},
+		{text: `format()`, expected: types.NewNoSuchOverloadErr()},
+	} {
+		for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+			program := parsedProgram(t, tst.text)
+			for _, opt := range opts {
+				opt(program.(*exprProgram))
+			}
+			result, _ := interp.NewInterpretable(program).Eval(NewActivation(map[string]interface{}{}))
+			if fmt.Sprint(result) != fmt.Sprint(tst.expected) {
+				t.Errorf("%s: got '%v' with %+v, wanted '%v'",
+					tst.text, result, program.Config(), tst.expected)
+			}
+		}
+	}
+}
+
+func TestDefaultDispatcher_Contextual(t *testing.T) {
+	dispatcher := NewDispatcher()
+	dispatcher.Add(functions.StandardOverloads()...)
+	dispatcher.Add(&functions.Overload{
+		Operator: "positive",
+		Contextual: func(call functions.Call) ref.Value {
+			function, _ := call.Function()
+			if call.Args()[0].(types.Int) > 0 {
+				return call.Args()[0]
+			}
+			loc, _ := call.Location()
+			return types.NewErr("%d:%d: %s(#%d) got %v",
+				loc.Line(), loc.Column(), function, call.ExprId(), call.Args()[0])
+		}})
+	interp := NewInterpreter(dispatcher, packages.DefaultPackage,
+		types.NewProvider())
+	for _, tst := range []struct {
+		text     string
+		expected string
+	}{
+		{text: `positive(a) + 1`, expected: "3"},
+		{text: "1 +\n  positive(-a)", expected: "2:10: positive(#4) got -2"},
+	} {
+		for _, opts := range [][]ProgramOption{
+			{},
+			{FuseInstructions()},
+			{TreeEvaluation()},
+		} {
+			program := parsedProgram(t, tst.text)
+			for _, opt := range opts {
+				opt(program.(*exprProgram))
+			}
+			result, _ := interp.NewInterpretable(program).Eval(
+				NewActivation(map[string]interface{}{"a": 2}))
+			if fmt.Sprint(result) != tst.expected {
+				t.Errorf("%s: got '%v' with %+v, wanted '%s'",
+					tst.text, result, program.Config(), tst.expected)
+			}
+		}
+	}
+}
+
+func TestDefaultDispatcher_RuntimeOverloads(t *testing.T) {
+	dispatcher := NewDispatcher()
+	dispatcher.Add(functions.StandardOverloads()...)
+	dispatcher.Add(&functions.Overload{
+		Operator:   "describe_int",
+		OverloadOf: "describe",
+		ArgTypes:   []ref.Type{types.IntType},
+		Unary: func(value ref.Value) ref.Value {
+			return types.String(fmt.Sprintf("int %v", value))
+		}},
+		&functions.Overload{
+			Operator:   "describe_string_int",
+			OverloadOf: "describe",
+			ArgTypes:   []ref.Type{types.StringType, nil},
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return types.String(fmt.Sprintf("%v then %v", lhs, rhs))
+			}})
+	interp := NewInterpreter(dispatcher, packages.DefaultPackage,
+		types.NewProvider())
+	for _, tst := range []struct {
+		text     string
+		expected string
+	}{
+		{text: `describe(1)`, expected: "int 1"},
+		{text: `describe('a', 2.5)`, expected: "a then 2.5"},
+		{text: `'a'.describe(true)`, expected: "a then true"},
+		{text: `describe(1u)`,
+			expected: "no matching overload for 'describe' applied to (uint), " +
+				"candidates: describe_int(int), describe_string_int(string, dyn)"},
+	} {
+		for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+			program := parsedProgram(t, tst.text)
+			for _, opt := range opts {
+				opt(program.(*exprProgram))
+			}
+			result, _ := interp.NewInterpretable(program).Eval(NewActivation(map[string]interface{}{}))
+			if fmt.Sprint(result) != tst.expected {
+				t.Errorf("%s: got '%v' with %+v, wanted '%s'",
+					tst.text, result, program.Config(), tst.expected)
+			}
+		}
+	}
+
+	// Checked calls are dispatched to the overload they name, and mismatches
+	// are reported as missing overloads.
+	call := &CallContext{
+		call: NewCallOverload(0, "describe", []int64{1}, "describe_int"),
+		args: []ref.Value{types.Int(3)}}
+	invokeCall(t, dispatcher, call, types.String("int 3"))
+	call.args = []ref.Value{types.Double(3)}
+	if result := dispatcher.Dispatch(call); !types.IsNoSuchOverload(result) {
+		t.Errorf("Got '%v', wanted a no such overload error", result)
+	}
+}
+
+func TestDefaultDispatcher_StandardOverloads(t *testing.T) {
+	for _, tst := range []struct {
+		text     string
+		expected string
+	}{
+		{text: `size('abc') + int(2u) == 5`, expected: "true"},
+		{text: `[1] + [2] == [1, 2] && 'b' > 'a'`, expected: "true"},
+		{text: `string(1.5) in ['1.5'] && type(2) == int`, expected: "true"},
+		{text: `timestamp('2018-01-01T00:00:00Z') + duration('1s') >
+			timestamp('2018-01-01T00:00:00Z')`, expected: "true"},
+		{text: `size(1)`,
+			expected: "no matching overload for 'size' applied to (int), " +
+				"candidates: string_size(string), bytes_size(bytes), " +
+				"list_size(list), map_size(map), size_string(string), " +
+				"size_bytes(bytes), size_list(list), size_map(map)"},
+		{text: `-'a'`,
+			expected: "no matching overload for '-_' applied to (string), " +
+				"candidates: negate_int64(int), negate_double(double)"},
+		{text: `'a' in 1`,
+			expected: "no matching overload for '_in_' applied to (string, int), " +
+				"candidates: in_list(dyn, list), in_map(dyn, map)"},
+		{text: `1 ? 2 : 3`,
+			expected: "no matching overload for '_?_:_' applied to (int, int, int), " +
+				"candidates: conditional(bool, dyn, dyn)"},
+	} {
+		for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+			program := parsedProgram(t, tst.text)
+			for _, opt := range opts {
+				opt(program.(*exprProgram))
+			}
+			result, _ := interpreter.NewInterpretable(program).Eval(NewActivation(map[string]interface{}{}))
+			if fmt.Sprint(result) != tst.expected {
+				t.Errorf("%s: got '%v' with %+v, wanted '%s'",
+					tst.text, result, program.Config(), tst.expected)
+			}
+		}
+	}
+
+	// Each declared overload of a standard function is defined by its id.
+	dispatcher := NewDispatcher()
+	dispatcher.Add(functions.StandardOverloads()...)
+	overload, found := dispatcher.FindOverload(overloads.AddDurationTimestamp)
+	if !found || overload.OverloadOf != operators.Add ||
+		fmt.Sprint(overload.ArgTypes) != fmt.Sprint([]ref.Type{
+			types.DurationType, types.TimestampType}) {
+		t.Fatalf("Got %+v, wanted an overload of '%s'", overload, operators.Add)
+	}
+	call := &CallContext{
+		call: NewCallOverload(0, operators.Add, []int64{1, 2},
+			overloads.AddInt64),
+		args: []ref.Value{types.Int(1), types.Int(2)}}
+	invokeCall(t, dispatcher, call, types.Int(3))
+}
+
 func BenchmarkDefaultDispatcher_Dispatch(b *testing.B) {
 	dispatcher := NewDispatcher()
 	if err := dispatcher.Add(functions.StandardOverloads()...); err != nil {
@@ -0,0 +1,156 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+var (
+	// Variables of the attributes exposed by Envoy, see
+	// checker#EnvoyDeclarations.
+	envoyVariables = map[string]bool{
+		"request":     true,
+		"response":    true,
+		"connection":  true,
+		"source":      true,
+		"destination": true,
+	}
+)
+
+// EnvoyActivation returns an Activation which resolves the attributes which
+// Envoy exposes to the expressions of its RBAC filters, as declared by
+// checker#EnvoyDeclarations, from their values by qualified name, e.g.
+// 'request.path' or 'connection.mtls'. The values of the attributes of an
+// HTTP request may be obtained with EnvoyRequestAttributes.
+//
+// As in Envoy, an attribute which is not supplied, such as a response code
+// while a request is being processed, evaluates to an error rather than to an
+// unknown value. The attributes are resolved by their qualified names within
+// checked expressions, and as the fields of maps bound to the request,
+// response, connection, source, and destination variables within unchecked
+// expressions.
+func EnvoyActivation(attributes map[string]interface{}) Activation {
+	return newAttributeActivation(envoyVariables, attributes)
+}
+
+// newAttributeActivation returns an Activation which resolves attributes by
+// their qualified names, and as the fields of maps bound to the given
+// variables. Attributes of the variables which are not supplied evaluate to
+// errors.
+func newAttributeActivation(variables map[string]bool,
+	attributes map[string]interface{}) Activation {
+	bindings := make(map[string]interface{}, len(attributes)+len(variables))
+	fields := make(map[string]map[string]interface{})
+	for variable := range variables {
+		fields[variable] = make(map[string]interface{})
+		bindings[variable] = fields[variable]
+	}
+	for name, value := range attributes {
+		bindings[name] = value
+		if dot := strings.IndexByte(name, '.'); dot > 0 && variables[name[:dot]] {
+			fields[name[:dot]][name[dot+1:]] = value
+		}
+	}
+	return &attributeActivation{
+		mapActivation: mapActivation{bindings: bindings},
+		variables:     variables}
+}
+
+type attributeActivation struct {
+	mapActivation
+	variables map[string]bool
+}
+
+func (a *attributeActivation) ResolveName(name string) (ref.Value, bool) {
+	if value, found := a.mapActivation.ResolveName(name); found {
+		return value, true
+	}
+	if dot := strings.IndexByte(name, '.'); dot > 0 && a.variables[name[:dot]] {
+		return types.NewErr("no such attribute: '%s'", name), true
+	}
+	return nil, false
+}
+
+// EnvoyRequestAttributes returns the values of the request, source, and
+// connection attributes of an HTTP request received by a server, as they
+// would be exposed by Envoy, for use with EnvoyActivation:
+//
+//   - the headers are keyed by their lower case names, and the values of a
+//     header which occurs more than once are joined with commas
+//   - request.path includes the query, while request.url_path does not
+//   - request.referer, request.useragent, and request.id are set from the
+//     Referer, User-Agent, and X-Request-Id headers when they are present
+//   - source.address includes the port of the remote address
+//
+// Attributes which cannot be derived from the request, such as request.time,
+// may be added to the returned map before it is bound.
+func EnvoyRequestAttributes(r *http.Request) map[string]interface{} {
+	headers := make(map[string]string, len(r.Header)+1)
+	for name, values := range r.Header {
+		key := strings.ToLower(name)
+		if existing, found := headers[key]; found {
+			values = append([]string{existing}, values...)
+		}
+		headers[key] = strings.Join(values, ",")
+	}
+	if r.Host != "" {
+		headers["host"] = r.Host
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	attributes := map[string]interface{}{
+		"request.path":     r.URL.RequestURI(),
+		"request.url_path": r.URL.Path,
+		"request.host":     r.Host,
+		"request.scheme":   scheme,
+		"request.method":   r.Method,
+		"request.headers":  headers,
+		"request.protocol": r.Proto,
+		"request.query":    r.URL.RawQuery,
+		"connection.mtls":  r.TLS != nil && len(r.TLS.PeerCertificates) != 0,
+	}
+	for attribute, header := range map[string]string{
+		"request.referer":   "referer",
+		"request.useragent": "user-agent",
+		"request.id":        "x-request-id"} {
+		if value, found := headers[header]; found {
+			attributes[attribute] = value
+		}
+	}
+	if r.ContentLength >= 0 {
+		attributes["request.size"] = r.ContentLength
+	}
+	if r.TLS != nil && r.TLS.ServerName != "" {
+		attributes["connection.requested_server_name"] = r.TLS.ServerName
+	}
+	if r.RemoteAddr != "" {
+		attributes["source.address"] = r.RemoteAddr
+		if _, port, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			if p, err := strconv.ParseInt(port, 10, 64); err == nil {
+				attributes["source.port"] = p
+			}
+		}
+	}
+	return attributes
+}
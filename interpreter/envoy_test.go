@@ -0,0 +1,74 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+func TestEnvoyActivation(t *testing.T) {
+	r := httptest.NewRequest("POST", "/v1/users?limit=10", nil)
+	r.Host = "api.example.com"
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Add("X-Api-Key", "secret")
+	r.Header.Add("Accept", "text/html")
+	r.Header.Add("Accept", "application/json")
+	r.Header.Set("User-Agent", "curl/7.58")
+	attributes := EnvoyRequestAttributes(r)
+	attributes["connection.id"] = uint64(7)
+	activation := EnvoyActivation(attributes)
+	for _, tst := range []struct {
+		text     string
+		expected ref.Value
+	}{
+		{text: `request.headers['x-api-key'] == 'secret' && source.port == 1234`,
+			expected: types.True},
+		{text: `request.path == '/v1/users?limit=10' && request.url_path == '/v1/users'`,
+			expected: types.True},
+		{text: `request.host == 'api.example.com' && request.method == 'POST'`,
+			expected: types.True},
+		{text: `request.headers['accept']`, expected: types.String("text/html,application/json")},
+		{text: `request.useragent == 'curl/7.58' && !has(request.headers.referer)`,
+			expected: types.True},
+		{text: `source.address == '192.0.2.1:1234' && connection.id == 7u`,
+			expected: types.True},
+		{text: `!connection.mtls && request.scheme == 'http'`, expected: types.True},
+		// Headers are keyed by their lower case names, as in Envoy.
+		{text: `request.headers['X-Api-Key']`},
+		// Attributes which are not supplied are errors rather than unknowns.
+		{text: `response.code == 200`},
+		{text: `request.referer`},
+		{text: `response.code == 200 || request.method == 'POST'`, expected: types.True},
+	} {
+		for _, program := range []Program{
+			parsedProgram(t, tst.text),
+			checkedProgram(t, tst.text, checker.EnvoyDeclarations()...)} {
+			res, _ := interpreter.NewInterpretable(program).Eval(activation)
+			if tst.expected == nil {
+				if !types.IsError(res) {
+					t.Errorf("%s: got '%v' with %+v, wanted error", tst.text, res, program.Config())
+				}
+			} else if res.Equal(tst.expected) != types.True {
+				t.Errorf("%s: got '%v' with %+v, wanted '%v'",
+					tst.text, res, program.Config(), tst.expected)
+			}
+		}
+	}
+}
@@ -15,11 +15,33 @@
 package interpreter
 
 import (
+	"sort"
+
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 )
 
 // EvalState tracks the values associated with expression ids during execution.
 type EvalState interface {
+	// Attributes returns the sorted names of the attributes read by the
+	// evaluation, such as 'a' or 'a.b.c', as described for ProgramAttributes,
+	// when the program was created with the TrackAttributes option.
+	//
+	// Attributes which were not evaluated, e.g. the right-hand side of a
+	// logical operator which short-circuits, are omitted, while those whose
+	// selection produced an error or an unknown value are included.
+	Attributes() []string
+
+	// ErrorLocation returns the source location of the expression which
+	// produced the error value for the given expression id, or false if the
+	// value is not an error or its location is unknown. For an aggregate of
+	// errors, the location is that of the first error.
+	//
+	// The location may be combined with the error message and the expression
+	// source to report the error as a common.Error.
+	ErrorLocation(exprId int64) (common.Location, bool)
+
 	// GetRuntimeExpressionId returns the runtime id corresponding to the
 	// expression id from the AST.
 	GetRuntimeExpressionId(exprId int64) int64
@@ -51,9 +73,84 @@ func NewEvalState(instructionCount int64) *defaultEvalState {
 }
 
 type defaultEvalState struct {
+	attributes *attributeTracker
+	cost       uint64
 	exprCount  int64
 	exprValues []ref.Value
 	exprIdMap  map[int64]int64
+	metadata   Metadata
+	// trace is set when the evaluation is traced.
+	trace *Trace
+}
+
+// ActualCost returns the cost of the evaluation which produced the EvalState,
+// as described for EstimateCost, or false if the cost was not tracked.
+func ActualCost(state EvalState) (uint64, bool) {
+	if s, isDefault := state.(*defaultEvalState); isDefault {
+		return s.cost, true
+	}
+	return 0, false
+}
+
+// clone returns a copy of the state of an initialized program, which holds
+// the values seeded by Program.Init, for a single evaluation. The runtime
+// expression ids and the metadata are shared, as they are not modified by
+// evaluation.
+func (s *defaultEvalState) clone() *defaultEvalState {
+	values := make([]ref.Value, len(s.exprValues))
+	copy(values, s.exprValues)
+	return &defaultEvalState{
+		attributes: s.attributes.clone(),
+		exprCount:  s.exprCount,
+		exprValues: values,
+		exprIdMap:  s.exprIdMap,
+		metadata:   s.metadata}
+}
+
+// reset restores a state cloned from the state of an initialized program to
+// the initial state, so that the state may be reused by another evaluation.
+func (s *defaultEvalState) reset(initial *defaultEvalState) {
+	copy(s.exprValues, initial.exprValues)
+	if s.attributes != nil {
+		for name := range s.attributes.read {
+			delete(s.attributes.read, name)
+		}
+	}
+	s.cost = 0
+	s.trace = nil
+}
+
+func (s *defaultEvalState) Attributes() []string {
+	if s.attributes == nil {
+		return nil
+	}
+	var names []string
+	for name := range s.attributes.read {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *defaultEvalState) ErrorLocation(exprId int64) (common.Location, bool) {
+	val, found := s.Value(s.GetRuntimeExpressionId(exprId))
+	if !found || s.metadata == nil {
+		return nil, false
+	}
+	var err *types.Err
+	switch v := val.(type) {
+	case *types.Err:
+		err = v
+	case types.ErrorSet:
+		// The location of an aggregate is that of its first error.
+		err = v[0]
+	default:
+		return nil, false
+	}
+	if errId, found := err.ExprId(); found {
+		return s.metadata.IdLocation(errId)
+	}
+	return nil, false
 }
 
 func (s *defaultEvalState) GetRuntimeExpressionId(exprId int64) int64 {
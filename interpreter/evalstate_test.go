@@ -15,7 +15,9 @@
 package interpreter
 
 import (
+	"github.com/google/cel-go/common"
 	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/parser"
 	"testing"
 )
 
@@ -30,3 +32,61 @@ func TestGetterSetter(t *testing.T) {
 		t.Error("Unexpected value found", greeting)
 	}
 }
+
+func TestErrorLocation(t *testing.T) {
+	text := "x == 1 &&\n  a.b / 0 > 2"
+	parsed, errors := parser.ParseText(text)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+	res, state := interpreter.NewInterpretable(program).Eval(
+		NewActivation(map[string]interface{}{
+			"x": 1,
+			"a": map[string]int64{"b": 2}}))
+	if !types.IsError(res) {
+		t.Fatalf("Got '%v', wanted an error", res)
+	}
+	loc, found := state.ErrorLocation(parsed.GetExpr().GetId())
+	if !found {
+		t.Fatal("Expected a location for the error")
+	}
+	err := &common.Error{Location: loc, Message: res.(*types.Err).String()}
+	expected := "ERROR: <input>:2:7: divide by zero\n" +
+		" |   a.b / 0 > 2\n" +
+		" | ......^"
+	if display := err.ToDisplayString(common.NewStringSource(text, "<input>")); display != expected {
+		t.Errorf("Got:\n%s\nwanted:\n%s", display, expected)
+	}
+	if _, found := state.ErrorLocation(2); found {
+		t.Error("Got a location for a non-error value")
+	}
+}
+
+func TestErrorDetail(t *testing.T) {
+	text := "x == 1 &&\n  a.emial + 1 > 2 &&\n  x / 0 == 1"
+	parsed, errors := parser.ParseText(text)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(), opts...)
+		activation := NewActivation(map[string]interface{}{
+			"x": 1,
+			"a": map[string]int64{"email": 2}})
+		res, _ := interpreter.NewInterpretable(program).Eval(activation)
+		errs, isSet := res.(types.ErrorSet)
+		if !isSet || len(errs) != 2 {
+			t.Fatalf("Got '%v', wanted two errors", res)
+		}
+		expected := []string{
+			"no such key: 'emial' at line 2, col 4 in 'a.emial'",
+			"divide by zero at line 3, col 5 in 'x / 0'"}
+		for i, err := range errs {
+			if err.Detail() != expected[i] {
+				t.Errorf("Got '%s' with %+v, wanted '%s'",
+					err.Detail(), program.Config(), expected[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// EncodersOverloads returns the implementations of the encoding functions
+// declared by checker#EncodersDeclarations: base64.encode, base64.decode,
+// hex.encode, hex.decode, url.encode, url.decode, and utf8.valid.
+//
+// base64.decode accepts the standard encoding with or without padding, and
+// hex.decode accepts upper and lower case digits.
+func EncodersOverloads() []*Overload {
+	return []*Overload{
+		{Operator: overloads.Base64Encode,
+			Unary: func(value ref.Value) ref.Value {
+				b, isBytes := value.(types.Bytes)
+				if !isBytes {
+					return types.NewNoSuchOverloadErr()
+				}
+				return types.String(base64.StdEncoding.EncodeToString(b))
+			}},
+		{Operator: overloads.Base64Decode,
+			Unary: func(value ref.Value) ref.Value {
+				return decodeString(value, func(s string) ([]byte, error) {
+					b, err := base64.StdEncoding.DecodeString(s)
+					if err != nil {
+						if raw, rawErr := base64.RawStdEncoding.DecodeString(s); rawErr == nil {
+							return raw, nil
+						}
+					}
+					return b, err
+				})
+			}},
+		{Operator: overloads.HexEncode,
+			Unary: func(value ref.Value) ref.Value {
+				b, isBytes := value.(types.Bytes)
+				if !isBytes {
+					return types.NewNoSuchOverloadErr()
+				}
+				return types.String(hex.EncodeToString(b))
+			}},
+		{Operator: overloads.HexDecode,
+			Unary: func(value ref.Value) ref.Value {
+				return decodeString(value, hex.DecodeString)
+			}},
+		{Operator: overloads.URLEncode,
+			Unary: func(value ref.Value) ref.Value {
+				s, isString := value.(types.String)
+				if !isString {
+					return types.NewNoSuchOverloadErr()
+				}
+				return types.String(url.QueryEscape(string(s)))
+			}},
+		{Operator: overloads.URLDecode,
+			Unary: func(value ref.Value) ref.Value {
+				s, isString := value.(types.String)
+				if !isString {
+					return types.NewNoSuchOverloadErr()
+				}
+				decoded, err := url.QueryUnescape(string(s))
+				if err != nil {
+					return types.NewErr("%v", err)
+				}
+				return types.String(decoded)
+			}},
+		{Operator: overloads.UTF8Valid,
+			Unary: func(value ref.Value) ref.Value {
+				b, isBytes := value.(types.Bytes)
+				if !isBytes {
+					return types.NewNoSuchOverloadErr()
+				}
+				return types.Bool(utf8.Valid(b))
+			}},
+	}
+}
+
+// decodeString decodes a string argument to bytes with the decoder.
+func decodeString(value ref.Value, decode func(string) ([]byte, error)) ref.Value {
+	s, isString := value.(types.String)
+	if !isString {
+		return types.NewNoSuchOverloadErr()
+	}
+	b, err := decode(string(s))
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	return types.Bytes(b)
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter/functions"
+)
+
+func TestEncoders(t *testing.T) {
+	e := newEvaluator(checker.EncodersDeclarations(), functions.EncodersOverloads()...)
+	e.run(t, []evalTest{
+		{text: "base64.encode(b'hello')", expected: types.String("aGVsbG8=")},
+		{text: "base64.decode('aGVsbG8=')", expected: types.Bytes("hello")},
+		{text: "base64.decode('aGVsbG8')", expected: types.Bytes("hello")},
+		{text: "hex.encode(b'hi')", expected: types.String("6869")},
+		{text: "hex.encode(hex.decode('CAFE'))", expected: types.String("cafe")},
+		{text: "url.encode('a b&c=d/é')", expected: types.String("a+b%26c%3Dd%2F%C3%A9")},
+		{text: "url.decode(url.encode('a b&c=d/é'))", expected: types.String("a b&c=d/é")},
+		{text: "utf8.valid(b'héllo')", expected: types.True},
+		{text: "utf8.valid(hex.decode('ff'))", expected: types.False},
+		{text: "base64.decode('!')"},
+		{text: "hex.decode('abc')"},
+		{text: "url.decode('%zz')"},
+	})
+}
@@ -16,11 +16,15 @@
 // interpreter and as declared within the checker#StandardDeclarations.
 package functions
 
-import "github.com/google/cel-go/common/types/ref"
+import (
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
 
 // Overload defines a named overload of a function, indicating an operand trait
 // which must be present on the first argument to the overload as well as one
-// of either a unary, binary, or function implementation.
+// of either a unary, binary, variadic, contextual, or function implementation.
 //
 // The majority of  operators within the expression language are unary or binary
 // and the specializations simplify the call contract for implementers of
@@ -36,12 +40,38 @@ type Overload struct {
 	// definitions should be used to execute the call.
 	OperandTrait int
 
+	// OverloadOf is the name of the function implemented by the overload,
+	// e.g. 'size' for 'size_string'. Calls of the function which have not
+	// been checked are dispatched to the overload whose arguments match the
+	// operands at runtime, or report the signatures of its overloads when
+	// none match. May be empty.
+	OverloadOf string
+
+	// ArgTypes are the types of the arguments accepted by an overload of the
+	// OverloadOf function, where a nil type accepts a value of any type. When
+	// nil, the overload accepts any arguments with the OperandTrait.
+	ArgTypes []ref.Type
+
+	// Volatile indicates that the result of the overload may differ between
+	// calls with the same arguments, e.g. because it reads the current time,
+	// so that the optimizer does not fold calls of it with constant
+	// arguments.
+	Volatile bool
+
 	// Unary defines the overload with a UnaryOp implementation. May be nil.
 	Unary UnaryOp
 
 	// Binary defines the overload with a BinaryOp implementation. May be nil.
 	Binary BinaryOp
 
+	// Variadic defines the overload with a VariadicOp implementation, which
+	// takes precedence over the Function. May be nil.
+	Variadic *VariadicOp
+
+	// Contextual defines the overload with a ContextualOp implementation,
+	// which takes precedence over the other implementations. May be nil.
+	Contextual ContextualOp
+
 	// Function defines the overload with a FunctionOp implementation. May be
 	// nil.
 	Function FunctionOp
@@ -56,3 +86,62 @@ type BinaryOp func(lhs ref.Value, rhs ref.Value) ref.Value
 // FunctionOp is a function with accepts zero or more arguments and produces
 // an value (as interface{}) or error as a result.
 type FunctionOp func(values ...ref.Value) ref.Value
+
+// Call describes an invocation of a function, e.g. so that an overload may
+// report errors and metrics at the location of the call.
+type Call interface {
+	// Function returns the name of the function as it is written in the
+	// expression, and the overload id when it was resolved by the checker.
+	Function() (string, string)
+
+	// Args returns the values of the arguments.
+	Args() []ref.Value
+
+	// ExprId returns the expression id of the call.
+	ExprId() int64
+
+	// Location returns the source location of the call, or false when the
+	// program has no source positions.
+	Location() (common.Location, bool)
+}
+
+// ContextualOp is a function which is called with the description of its
+// invocation rather than with its arguments alone.
+type ContextualOp func(call Call) ref.Value
+
+// VariadicOp is a function of a fixed number of leading arguments followed by
+// any number of trailing arguments, e.g. 'format(fmt, args...)' or
+// 'concat(parts...)'. The types of the arguments are checked before the
+// function is called, so that the implementation may assert the types of its
+// arguments without guarding against other types.
+type VariadicOp struct {
+	// Params holds the types of the leading arguments, where a nil type
+	// accepts a value of any type.
+	Params []ref.Type
+
+	// Rest is the type of each of the trailing arguments, or nil for any
+	// type.
+	Rest ref.Type
+
+	// Function implements the overload, and is called with the leading and
+	// trailing arguments.
+	Function FunctionOp
+}
+
+// Call checks the types of the arguments and calls the function, or returns a
+// 'no such overload' error when the arguments do not match.
+func (v *VariadicOp) Call(values ...ref.Value) ref.Value {
+	if len(values) < len(v.Params) {
+		return types.NewNoSuchOverloadErr()
+	}
+	for i, value := range values {
+		t := v.Rest
+		if i < len(v.Params) {
+			t = v.Params[i]
+		}
+		if t != nil && value.Type().TypeName() != t.TypeName() {
+			return types.NewNoSuchOverloadErr()
+		}
+	}
+	return v.Function(values...)
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/google/cel-go/parser"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+// evalTest is an expression evaluated against the bindings of its variables,
+// along with the value expected of it, or nil when an error is expected.
+type evalTest struct {
+	text     string
+	vars     map[string]interface{}
+	opts     []interpreter.ProgramOption
+	expected ref.Value
+}
+
+// evaluator checks expressions against the standard declarations and those
+// of an extension, and evaluates them with the standard overloads and those
+// of the extension.
+type evaluator struct {
+	dispatcher interpreter.Dispatcher
+	interp     interpreter.Interpreter
+	idents     []*checkedpb.Decl
+}
+
+func newEvaluator(idents []*checkedpb.Decl, overloads ...*functions.Overload) *evaluator {
+	dispatcher := interpreter.NewDispatcher()
+	dispatcher.Add(functions.StandardOverloads()...)
+	dispatcher.Add(overloads...)
+	return &evaluator{
+		dispatcher: dispatcher,
+		interp: interpreter.NewInterpreter(dispatcher, packages.DefaultPackage,
+			types.NewProvider()),
+		idents: idents}
+}
+
+// program parses and checks the expression.
+func (e *evaluator) program(t *testing.T, text string,
+	opts ...interpreter.ProgramOption) interpreter.Program {
+	t.Helper()
+	parsed, errors := parser.ParseText(text)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	env := checker.NewStandardEnv(packages.DefaultPackage,
+		types.NewProvider(&expr.ParsedExpr{}), errors)
+	env.Add(e.idents...)
+	checked := checker.Check(parsed, env)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	return interpreter.NewCheckedProgram(checked, opts...)
+}
+
+// run evaluates each test, and reports those whose values differ from the
+// expected values.
+func (e *evaluator) run(t *testing.T, tests []evalTest) {
+	t.Helper()
+	for _, tst := range tests {
+		program := e.program(t, tst.text, tst.opts...)
+		res, _ := e.interp.NewInterpretable(program).Eval(
+			interpreter.NewActivation(tst.vars))
+		if tst.expected == nil {
+			if !types.IsError(res) {
+				t.Errorf("%s: got '%v', wanted error", tst.text, res)
+			}
+		} else if res.Equal(tst.expected) != types.True {
+			t.Errorf("%s: got '%v', wanted '%v'", tst.text, res, tst.expected)
+		}
+	}
+}
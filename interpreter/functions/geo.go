@@ -0,0 +1,71 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"net"
+
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// GeoResolver resolves the location of IP addresses for the geo.country and
+// geo.region functions. The location data, such as a GeoIP database, is
+// supplied by the embedder.
+//
+// Implementations must be safe for concurrent use.
+type GeoResolver interface {
+	// Country returns the ISO 3166-1 alpha-2 code of the country of the
+	// address, or the empty string when the country is not known.
+	Country(ip net.IP) (string, error)
+
+	// Region returns the ISO 3166-2 code of the country subdivision of the
+	// address, e.g. 'US-CA', or the empty string when the region is not known.
+	Region(ip net.IP) (string, error)
+}
+
+// GeoOverloads returns the implementations of the geo.country and geo.region
+// functions declared by checker#GeoDeclarations, which resolve the locations
+// of IP addresses with the given resolver.
+func GeoOverloads(resolver GeoResolver) []*Overload {
+	return []*Overload{
+		{Operator: overloads.GeoCountry,
+			Unary: func(value ref.Value) ref.Value {
+				return geoLookup(value, resolver.Country)
+			}},
+		{Operator: overloads.GeoRegion,
+			Unary: func(value ref.Value) ref.Value {
+				return geoLookup(value, resolver.Region)
+			}},
+	}
+}
+
+// geoLookup parses the IP address and resolves its location.
+func geoLookup(value ref.Value, lookup func(net.IP) (string, error)) ref.Value {
+	addr, isString := value.(types.String)
+	if !isString {
+		return types.NewNoSuchOverloadErr()
+	}
+	ip := net.ParseIP(string(addr))
+	if ip == nil {
+		return types.NewErr("invalid IP address: '%s'", addr)
+	}
+	location, err := lookup(ip)
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	return types.String(location)
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter/functions"
+)
+
+// testGeoResolver resolves the locations of addresses within a fixed network.
+type testGeoResolver struct {
+	network *net.IPNet
+}
+
+func (r *testGeoResolver) Country(ip net.IP) (string, error) {
+	if ip.Equal(net.IPv4bcast) {
+		return "", errors.New("lookup failed")
+	}
+	if r.network.Contains(ip) {
+		return "DE", nil
+	}
+	return "", nil
+}
+
+func (r *testGeoResolver) Region(ip net.IP) (string, error) {
+	if r.network.Contains(ip) {
+		return "DE-BE", nil
+	}
+	return "", nil
+}
+
+func TestGeo(t *testing.T) {
+	_, network, _ := net.ParseCIDR("192.0.2.0/24")
+	e := newEvaluator(append(checker.GeoDeclarations(),
+		decls.NewIdent("ip", decls.String, nil)),
+		functions.GeoOverloads(&testGeoResolver{network})...)
+	ip := func(value string) map[string]interface{} {
+		return map[string]interface{}{"ip": value}
+	}
+	e.run(t, []evalTest{
+		{text: "geo.country(ip) in ['DE', 'FR']", vars: ip("192.0.2.1"), expected: types.True},
+		{text: "geo.country(ip) in ['DE', 'FR']", vars: ip("198.51.100.1"),
+			expected: types.False},
+		{text: "geo.region(ip)", vars: ip("192.0.2.1"), expected: types.String("DE-BE")},
+		{text: "geo.region(ip) == ''", vars: ip("2001:db8::1"), expected: types.True},
+		{text: "geo.country(ip)", vars: ip("not an address")},
+		{text: "geo.country(ip)", vars: ip("255.255.255.255")},
+	})
+}
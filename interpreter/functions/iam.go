@@ -0,0 +1,48 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"net"
+
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// IAMOverloads returns the implementation of the inIpRange function declared
+// by checker#IAMDeclarations, which returns whether an IPv4 or IPv6 address is
+// within a range in CIDR notation, e.g. '10.0.0.0/8'.
+func IAMOverloads() []*Overload {
+	return []*Overload{
+		{Operator: overloads.InIPRange,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				addr, isString := lhs.(types.String)
+				cidr, isCIDRString := rhs.(types.String)
+				if !isString || !isCIDRString {
+					return types.NewNoSuchOverloadErr()
+				}
+				ip := net.ParseIP(string(addr))
+				if ip == nil {
+					return types.NewErr("invalid IP address: '%s'", addr)
+				}
+				_, network, err := net.ParseCIDR(string(cidr))
+				if err != nil {
+					return types.NewErr("invalid IP range: '%s'", cidr)
+				}
+				return types.Bool(network.Contains(ip))
+			}},
+	}
+}
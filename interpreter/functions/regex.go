@@ -0,0 +1,75 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// RegexOverloads returns the implementations of the re.matches, re.capture,
+// re.captureAll, and re.replace functions declared by
+// checker#RegexDeclarations.
+//
+// The pattern is the second argument of each function. Constant patterns are
+// compiled when the program is planned, and other patterns on each call.
+func RegexOverloads() []*Overload {
+	return []*Overload{
+		{Operator: overloads.RegexMatches,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return withRegex(rhs, func(re *types.Regex) ref.Value {
+					return re.Match(lhs)
+				})
+			}},
+		{Operator: overloads.RegexCapture,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return withRegex(rhs, func(re *types.Regex) ref.Value {
+					return re.Capture(lhs)
+				})
+			}},
+		{Operator: overloads.RegexCaptureAll,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return withRegex(rhs, func(re *types.Regex) ref.Value {
+					return re.CaptureAll(lhs)
+				})
+			}},
+		{Operator: overloads.RegexReplace,
+			Function: func(values ...ref.Value) ref.Value {
+				if len(values) != 3 {
+					return types.NewNoSuchOverloadErr()
+				}
+				return withRegex(values[1], func(re *types.Regex) ref.Value {
+					return re.Replace(values[0], values[2])
+				})
+			}},
+	}
+}
+
+// withRegex calls the function with the compiled pattern, compiling string
+// patterns which were not constant when the program was planned.
+func withRegex(pattern ref.Value, f func(*types.Regex) ref.Value) ref.Value {
+	switch p := pattern.(type) {
+	case *types.Regex:
+		return f(p)
+	case types.String:
+		re, err := types.NewRegex(string(p))
+		if err != nil {
+			return types.NewErr("%v", err)
+		}
+		return f(re)
+	}
+	return types.NewNoSuchOverloadErr()
+}
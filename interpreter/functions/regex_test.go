@@ -0,0 +1,48 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter/functions"
+)
+
+func TestRegex(t *testing.T) {
+	e := newEvaluator(append(checker.RegexDeclarations(),
+		decls.NewIdent("s", decls.String, nil),
+		decls.NewIdent("pattern", decls.String, nil)),
+		functions.RegexOverloads()...)
+	s := func(value string) map[string]interface{} {
+		return map[string]interface{}{"s": value, "pattern": "b+"}
+	}
+	e.run(t, []evalTest{
+		{text: `re.matches(s, '^/users/[0-9]+$')`, vars: s("/users/12"), expected: types.True},
+		{text: `re.matches(s, '^/users/[0-9]+$')`, vars: s("/users/me"), expected: types.False},
+		{text: `re.capture(s, 'user-([0-9]+)')`, vars: s("id: user-12"),
+			expected: types.String("12")},
+		{text: `re.capture(s, 'user-([0-9]+)')`, vars: s("id: 12")},
+		{text: `re.captureAll(s, '[a-z]+') == ['ab', 'c']`, vars: s("ab-c"),
+			expected: types.True},
+		{text: `re.captureAll(s, '[0-9]+').size()`, vars: s("ab-c"), expected: types.Int(0)},
+		{text: `re.replace(s, '([a-z]+)@', '$1 at ')`, vars: s("jo@example.com"),
+			expected: types.String("jo at example.com")},
+		{text: `re.matches(s, pattern)`, vars: s("abc"), expected: types.True},
+		{text: `re.matches(s, '(')`, vars: s("abc")},
+	})
+}
@@ -20,11 +20,18 @@ import (
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/common/types/traits"
+	"math"
 )
 
 // StandardOverloads returns the definitions of the built-in overloads.
+//
+// The built-in functions are implemented once for the operands of all of
+// their overloads, and each overload declared by
+// checker#StandardDeclarations is also defined by its id with the types of its
+// arguments, so that unchecked calls report the overloads which their operands
+// do not match.
 func StandardOverloads() []*Overload {
-	return []*Overload{
+	standard := []*Overload{
 		// Logical not (!a)
 		{
 			Operator:     operators.LogicalNot,
@@ -44,6 +51,15 @@ func StandardOverloads() []*Overload {
 		{
 			Operator: operators.Conditional,
 			Function: conditional},
+		// Null coalescing function (coalesce(a, b))
+		{
+			Operator: overloads.Coalesce,
+			Binary:   coalesce},
+
+		// Oneof introspection function (whichOneof(msg, 'oneof_name'))
+		{
+			Operator: overloads.WhichOneof,
+			Binary:   types.WhichOneof},
 
 		// Equality overloads
 		{Operator: operators.Equals,
@@ -174,22 +190,51 @@ func StandardOverloads() []*Overload {
 				return value.(traits.Sizer).Size()
 			}},
 
-		// In operator
+		// In operator, and the deprecated in() function
 		{Operator: operators.In,
-			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
-				if rhs.Type().HasTrait(traits.ContainerType) {
-					return rhs.(traits.Container).Contains(lhs)
-				}
-				return types.NewErr("no such overload")
-			}},
+			Binary: contains},
+		{Operator: overloads.DeprecatedIn,
+			Binary: contains},
 
 		// Matches function
 		{Operator: overloads.MatchString,
+			OverloadOf:   overloads.Matches,
 			OperandTrait: traits.MatcherType,
 			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
 				return lhs.(traits.Matcher).Match(rhs)
 			}},
 
+		// Glob matching function. Constant patterns are compiled when the
+		// program is planned.
+		{Operator: overloads.MatchesGlob,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				switch pattern := rhs.(type) {
+				case *types.Glob:
+					return pattern.Match(lhs)
+				case types.String:
+					glob, err := types.NewGlob(string(pattern))
+					if err != nil {
+						return types.NewErr("%v", err)
+					}
+					return glob.Match(lhs)
+				}
+				return types.NewNoSuchOverloadErr()
+			}},
+
+		// Key uniqueness functions of the all_unique and exists_unique macros.
+		{Operator: overloads.AllUniqueKeys,
+			Unary: func(value ref.Value) ref.Value {
+				return uniqueKeys(value, func(unique, total int) bool {
+					return unique == total
+				})
+			}},
+		{Operator: overloads.ExistsUniqueKeys,
+			Unary: func(value ref.Value) ref.Value {
+				return uniqueKeys(value, func(unique, total int) bool {
+					return unique > 0
+				})
+			}},
+
 		// Type conversion functions
 		// TODO: verify type conversion safety of numeric values.
 
@@ -247,6 +292,12 @@ func StandardOverloads() []*Overload {
 				return value.ConvertToType(types.TypeType)
 			}},
 
+		// Dyn conversions, which only affect the type-check of an expression.
+		{Operator: overloads.TypeConvertDyn,
+			Unary: func(value ref.Value) ref.Value {
+				return value
+			}},
+
 		{Operator: overloads.Iterator,
 			OperandTrait: traits.IterableType,
 			Unary: func(value ref.Value) ref.Value {
@@ -265,7 +316,701 @@ func StandardOverloads() []*Overload {
 				return value.(traits.Iterator).Next()
 			}},
 	}
+	return typedOverloads(standard, standardSignatures)
+}
+
+// signature describes an overload of a function by its id and the types of
+// its arguments, where a nil type accepts a value of any type.
+type signature struct {
+	function string
+	overload string
+	argTypes []ref.Type
+}
+
+// standardSignatures lists the overloads of the built-in functions declared by
+// checker#StandardDeclarations.
+var standardSignatures = []signature{
+	{operators.Conditional, overloads.Conditional,
+		[]ref.Type{types.BoolType, nil, nil}},
+	{overloads.Coalesce, overloads.Coalesce, []ref.Type{nil, nil}},
+	{overloads.WhichOneof, overloads.WhichOneof,
+		[]ref.Type{nil, types.StringType}},
+	{operators.LogicalAnd, overloads.LogicalAnd,
+		[]ref.Type{types.BoolType, types.BoolType}},
+	{operators.LogicalOr, overloads.LogicalOr,
+		[]ref.Type{types.BoolType, types.BoolType}},
+	{operators.LogicalNot, overloads.LogicalNot, []ref.Type{types.BoolType}},
+	{overloads.AllUniqueKeys, overloads.AllUniqueList,
+		[]ref.Type{types.ListType}},
+	{overloads.ExistsUniqueKeys, overloads.ExistsUniqueList,
+		[]ref.Type{types.ListType}},
+	{overloads.MatchesGlob, overloads.MatchesGlobString,
+		[]ref.Type{types.StringType, nil}},
 
+	// Relations
+	{operators.Equals, overloads.Equals, []ref.Type{nil, nil}},
+	{operators.NotEquals, overloads.NotEquals, []ref.Type{nil, nil}},
+	{operators.Less, overloads.LessBool,
+		[]ref.Type{types.BoolType, types.BoolType}},
+	{operators.Less, overloads.LessInt64,
+		[]ref.Type{types.IntType, types.IntType}},
+	{operators.Less, overloads.LessUint64,
+		[]ref.Type{types.UintType, types.UintType}},
+	{operators.Less, overloads.LessDouble,
+		[]ref.Type{types.DoubleType, types.DoubleType}},
+	{operators.Less, overloads.LessString,
+		[]ref.Type{types.StringType, types.StringType}},
+	{operators.Less, overloads.LessBytes,
+		[]ref.Type{types.BytesType, types.BytesType}},
+	{operators.Less, overloads.LessTimestamp,
+		[]ref.Type{types.TimestampType, types.TimestampType}},
+	{operators.Less, overloads.LessDuration,
+		[]ref.Type{types.DurationType, types.DurationType}},
+	{operators.LessEquals, overloads.LessEqualsBool,
+		[]ref.Type{types.BoolType, types.BoolType}},
+	{operators.LessEquals, overloads.LessEqualsInt64,
+		[]ref.Type{types.IntType, types.IntType}},
+	{operators.LessEquals, overloads.LessEqualsUint64,
+		[]ref.Type{types.UintType, types.UintType}},
+	{operators.LessEquals, overloads.LessEqualsDouble,
+		[]ref.Type{types.DoubleType, types.DoubleType}},
+	{operators.LessEquals, overloads.LessEqualsString,
+		[]ref.Type{types.StringType, types.StringType}},
+	{operators.LessEquals, overloads.LessEqualsBytes,
+		[]ref.Type{types.BytesType, types.BytesType}},
+	{operators.LessEquals, overloads.LessEqualsTimestamp,
+		[]ref.Type{types.TimestampType, types.TimestampType}},
+	{operators.LessEquals, overloads.LessEqualsDuration,
+		[]ref.Type{types.DurationType, types.DurationType}},
+	{operators.Greater, overloads.GreaterBool,
+		[]ref.Type{types.BoolType, types.BoolType}},
+	{operators.Greater, overloads.GreaterInt64,
+		[]ref.Type{types.IntType, types.IntType}},
+	{operators.Greater, overloads.GreaterUint64,
+		[]ref.Type{types.UintType, types.UintType}},
+	{operators.Greater, overloads.GreaterDouble,
+		[]ref.Type{types.DoubleType, types.DoubleType}},
+	{operators.Greater, overloads.GreaterString,
+		[]ref.Type{types.StringType, types.StringType}},
+	{operators.Greater, overloads.GreaterBytes,
+		[]ref.Type{types.BytesType, types.BytesType}},
+	{operators.Greater, overloads.GreaterTimestamp,
+		[]ref.Type{types.TimestampType, types.TimestampType}},
+	{operators.Greater, overloads.GreaterDuration,
+		[]ref.Type{types.DurationType, types.DurationType}},
+	{operators.GreaterEquals, overloads.GreaterEqualsBool,
+		[]ref.Type{types.BoolType, types.BoolType}},
+	{operators.GreaterEquals, overloads.GreaterEqualsInt64,
+		[]ref.Type{types.IntType, types.IntType}},
+	{operators.GreaterEquals, overloads.GreaterEqualsUint64,
+		[]ref.Type{types.UintType, types.UintType}},
+	{operators.GreaterEquals, overloads.GreaterEqualsDouble,
+		[]ref.Type{types.DoubleType, types.DoubleType}},
+	{operators.GreaterEquals, overloads.GreaterEqualsString,
+		[]ref.Type{types.StringType, types.StringType}},
+	{operators.GreaterEquals, overloads.GreaterEqualsBytes,
+		[]ref.Type{types.BytesType, types.BytesType}},
+	{operators.GreaterEquals, overloads.GreaterEqualsTimestamp,
+		[]ref.Type{types.TimestampType, types.TimestampType}},
+	{operators.GreaterEquals, overloads.GreaterEqualsDuration,
+		[]ref.Type{types.DurationType, types.DurationType}},
+
+	// Arithmetic
+	{operators.Add, overloads.AddInt64,
+		[]ref.Type{types.IntType, types.IntType}},
+	{operators.Add, overloads.AddUint64,
+		[]ref.Type{types.UintType, types.UintType}},
+	{operators.Add, overloads.AddDouble,
+		[]ref.Type{types.DoubleType, types.DoubleType}},
+	{operators.Add, overloads.AddString,
+		[]ref.Type{types.StringType, types.StringType}},
+	{operators.Add, overloads.AddBytes,
+		[]ref.Type{types.BytesType, types.BytesType}},
+	{operators.Add, overloads.AddList,
+		[]ref.Type{types.ListType, types.ListType}},
+	{operators.Add, overloads.AddTimestampDuration,
+		[]ref.Type{types.TimestampType, types.DurationType}},
+	{operators.Add, overloads.AddDurationTimestamp,
+		[]ref.Type{types.DurationType, types.TimestampType}},
+	{operators.Add, overloads.AddDurationDuration,
+		[]ref.Type{types.DurationType, types.DurationType}},
+	{operators.Subtract, overloads.SubtractInt64,
+		[]ref.Type{types.IntType, types.IntType}},
+	{operators.Subtract, overloads.SubtractUint64,
+		[]ref.Type{types.UintType, types.UintType}},
+	{operators.Subtract, overloads.SubtractDouble,
+		[]ref.Type{types.DoubleType, types.DoubleType}},
+	{operators.Subtract, overloads.SubtractTimestampTimestamp,
+		[]ref.Type{types.TimestampType, types.TimestampType}},
+	{operators.Subtract, overloads.SubtractTimestampDuration,
+		[]ref.Type{types.TimestampType, types.DurationType}},
+	{operators.Subtract, overloads.SubtractDurationDuration,
+		[]ref.Type{types.DurationType, types.DurationType}},
+	{operators.Multiply, overloads.MultiplyInt64,
+		[]ref.Type{types.IntType, types.IntType}},
+	{operators.Multiply, overloads.MultiplyUint64,
+		[]ref.Type{types.UintType, types.UintType}},
+	{operators.Multiply, overloads.MultiplyDouble,
+		[]ref.Type{types.DoubleType, types.DoubleType}},
+	{operators.Divide, overloads.DivideInt64,
+		[]ref.Type{types.IntType, types.IntType}},
+	{operators.Divide, overloads.DivideUint64,
+		[]ref.Type{types.UintType, types.UintType}},
+	{operators.Divide, overloads.DivideDouble,
+		[]ref.Type{types.DoubleType, types.DoubleType}},
+	{operators.Modulo, overloads.ModuloInt64,
+		[]ref.Type{types.IntType, types.IntType}},
+	{operators.Modulo, overloads.ModuloUint64,
+		[]ref.Type{types.UintType, types.UintType}},
+	{operators.Negate, overloads.NegateInt64, []ref.Type{types.IntType}},
+	{operators.Negate, overloads.NegateDouble, []ref.Type{types.DoubleType}},
+
+	// Index
+	{operators.Index, overloads.IndexList,
+		[]ref.Type{types.ListType, types.IntType}},
+	{operators.Index, overloads.IndexMap, []ref.Type{types.MapType, nil}},
+
+	// Collections
+	{overloads.Size, overloads.SizeStringInst, []ref.Type{types.StringType}},
+	{overloads.Size, overloads.SizeBytesInst, []ref.Type{types.BytesType}},
+	{overloads.Size, overloads.SizeListInst, []ref.Type{types.ListType}},
+	{overloads.Size, overloads.SizeMapInst, []ref.Type{types.MapType}},
+	{overloads.Size, overloads.SizeString, []ref.Type{types.StringType}},
+	{overloads.Size, overloads.SizeBytes, []ref.Type{types.BytesType}},
+	{overloads.Size, overloads.SizeList, []ref.Type{types.ListType}},
+	{overloads.Size, overloads.SizeMap, []ref.Type{types.MapType}},
+	{operators.In, overloads.InList, []ref.Type{nil, types.ListType}},
+	{operators.In, overloads.InMap, []ref.Type{nil, types.MapType}},
+
+	// Conversions
+	{overloads.TypeConvertType, overloads.TypeConvertType, []ref.Type{nil}},
+	{overloads.TypeConvertInt, overloads.IntToInt, []ref.Type{types.IntType}},
+	{overloads.TypeConvertInt, overloads.UintToInt, []ref.Type{types.UintType}},
+	{overloads.TypeConvertInt, overloads.DoubleToInt,
+		[]ref.Type{types.DoubleType}},
+	{overloads.TypeConvertInt, overloads.StringToInt,
+		[]ref.Type{types.StringType}},
+	{overloads.TypeConvertInt, overloads.TimestampToInt,
+		[]ref.Type{types.TimestampType}},
+	{overloads.TypeConvertInt, overloads.DurationToInt,
+		[]ref.Type{types.DurationType}},
+	{overloads.TypeConvertUint, overloads.UintToUint,
+		[]ref.Type{types.UintType}},
+	{overloads.TypeConvertUint, overloads.IntToUint, []ref.Type{types.IntType}},
+	{overloads.TypeConvertUint, overloads.DoubleToUint,
+		[]ref.Type{types.DoubleType}},
+	{overloads.TypeConvertUint, overloads.StringToUint,
+		[]ref.Type{types.StringType}},
+	{overloads.TypeConvertDouble, overloads.DoubleToDouble,
+		[]ref.Type{types.DoubleType}},
+	{overloads.TypeConvertDouble, overloads.IntToDouble,
+		[]ref.Type{types.IntType}},
+	{overloads.TypeConvertDouble, overloads.UintToDouble,
+		[]ref.Type{types.UintType}},
+	{overloads.TypeConvertDouble, overloads.StringToDouble,
+		[]ref.Type{types.StringType}},
+	{overloads.TypeConvertBool, overloads.BoolToBool,
+		[]ref.Type{types.BoolType}},
+	{overloads.TypeConvertBool, overloads.StringToBool,
+		[]ref.Type{types.StringType}},
+	{overloads.TypeConvertString, overloads.StringToString,
+		[]ref.Type{types.StringType}},
+	{overloads.TypeConvertString, overloads.BoolToString,
+		[]ref.Type{types.BoolType}},
+	{overloads.TypeConvertString, overloads.IntToString,
+		[]ref.Type{types.IntType}},
+	{overloads.TypeConvertString, overloads.UintToString,
+		[]ref.Type{types.UintType}},
+	{overloads.TypeConvertString, overloads.DoubleToString,
+		[]ref.Type{types.DoubleType}},
+	{overloads.TypeConvertString, overloads.BytesToString,
+		[]ref.Type{types.BytesType}},
+	{overloads.TypeConvertString, overloads.TimestampToString,
+		[]ref.Type{types.TimestampType}},
+	{overloads.TypeConvertString, overloads.DurationToString,
+		[]ref.Type{types.DurationType}},
+	{overloads.TypeConvertBytes, overloads.BytesToBytes,
+		[]ref.Type{types.BytesType}},
+	{overloads.TypeConvertBytes, overloads.StringToBytes,
+		[]ref.Type{types.StringType}},
+	{overloads.TypeConvertTimestamp, overloads.TimestampToTimestamp,
+		[]ref.Type{types.TimestampType}},
+	{overloads.TypeConvertTimestamp, overloads.StringToTimestamp,
+		[]ref.Type{types.StringType}},
+	{overloads.TypeConvertTimestamp, overloads.IntToTimestamp,
+		[]ref.Type{types.IntType}},
+	{overloads.TypeConvertDuration, overloads.DurationToDuration,
+		[]ref.Type{types.DurationType}},
+	{overloads.TypeConvertDuration, overloads.StringToDuration,
+		[]ref.Type{types.StringType}},
+	{overloads.TypeConvertDuration, overloads.IntToDuration,
+		[]ref.Type{types.IntType}},
+	{overloads.TypeConvertDyn, overloads.ToDyn, []ref.Type{nil}},
+}
+
+// typedOverloads annotates the overloads which implement the functions of the
+// signatures, and returns them along with a copy of the implementation of the
+// function for each signature whose overload id is not the function name.
+func typedOverloads(implementations []*Overload,
+	signatures []signature) []*Overload {
+	byFunction := make(map[string]*Overload, len(implementations))
+	for _, o := range implementations {
+		byFunction[o.Operator] = o
+	}
+	typed := implementations
+	for _, sig := range signatures {
+		impl := byFunction[sig.function]
+		if sig.overload == sig.function {
+			impl.OverloadOf, impl.ArgTypes = sig.function, sig.argTypes
+			continue
+		}
+		overload := *impl
+		overload.Operator = sig.overload
+		overload.OverloadOf, overload.ArgTypes = sig.function, sig.argTypes
+		typed = append(typed, &overload)
+	}
+	return typed
+}
+
+// SafeArithmeticOverloads returns the definitions of the functions declared by
+// checker#SafeArithmeticDeclarations.
+func SafeArithmeticOverloads() []*Overload {
+	return []*Overload{
+		{Operator: overloads.SafeDiv,
+			OperandTrait: traits.DividerType,
+			Function: func(values ...ref.Value) ref.Value {
+				return safeArithmetic(values, func(lhs ref.Value, rhs ref.Value) ref.Value {
+					return lhs.(traits.Divider).Divide(rhs)
+				})
+			}},
+
+		{Operator: overloads.SafeMod,
+			OperandTrait: traits.ModderType,
+			Function: func(values ...ref.Value) ref.Value {
+				return safeArithmetic(values, func(lhs ref.Value, rhs ref.Value) ref.Value {
+					return lhs.(traits.Modder).Modulo(rhs)
+				})
+			}},
+	}
+}
+
+// MathOverloads returns the implementations of the math functions declared by
+// checker#MathDeclarations: the math.isNaN, math.isInf, and math.isFinite
+// predicates, math.greatest and math.least, the math.abs, math.ceil,
+// math.floor, math.round, and math.sqrt functions, and the bit operations.
+func MathOverloads() []*Overload {
+	return []*Overload{
+		{Operator: overloads.MathIsNaN,
+			Unary: func(value ref.Value) ref.Value {
+				return mathPredicate(value, math.IsNaN)
+			}},
+		{Operator: overloads.MathIsInf,
+			Unary: func(value ref.Value) ref.Value {
+				return mathPredicate(value, func(d float64) bool {
+					return math.IsInf(d, 0)
+				})
+			}},
+		{Operator: overloads.MathIsFinite,
+			Unary: func(value ref.Value) ref.Value {
+				return mathPredicate(value, func(d float64) bool {
+					return !math.IsNaN(d) && !math.IsInf(d, 0)
+				})
+			}},
+		{Operator: overloads.MathGreatest,
+			Function: func(values ...ref.Value) ref.Value {
+				return mathExtreme(overloads.MathGreatest, values, 1)
+			}},
+		{Operator: overloads.MathLeast,
+			Function: func(values ...ref.Value) ref.Value {
+				return mathExtreme(overloads.MathLeast, values, -1)
+			}},
+		{Operator: overloads.MathAbs,
+			Unary: func(value ref.Value) ref.Value {
+				switch v := value.(type) {
+				case types.Int:
+					if v == math.MinInt64 {
+						return types.NewErr("integer overflow")
+					}
+					if v < 0 {
+						return -v
+					}
+					return v
+				case types.Uint:
+					return v
+				case types.Double:
+					return types.Double(math.Abs(float64(v)))
+				}
+				return types.NewNoSuchOverloadErr()
+			}},
+		{Operator: overloads.MathCeil,
+			Unary: func(value ref.Value) ref.Value {
+				return mathRounding(value, math.Ceil)
+			}},
+		{Operator: overloads.MathFloor,
+			Unary: func(value ref.Value) ref.Value {
+				return mathRounding(value, math.Floor)
+			}},
+		{Operator: overloads.MathRound,
+			Unary: func(value ref.Value) ref.Value {
+				return mathRounding(value, math.Round)
+			}},
+		{Operator: overloads.MathSqrt,
+			Unary: func(value ref.Value) ref.Value {
+				switch v := value.(type) {
+				case types.Int:
+					return types.Double(math.Sqrt(float64(v)))
+				case types.Uint:
+					return types.Double(math.Sqrt(float64(v)))
+				case types.Double:
+					return types.Double(math.Sqrt(float64(v)))
+				}
+				return types.NewNoSuchOverloadErr()
+			}},
+		{Operator: overloads.MathBitAnd,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return bitOperation(lhs, rhs, func(a, b uint64) uint64 { return a & b })
+			}},
+		{Operator: overloads.MathBitOr,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return bitOperation(lhs, rhs, func(a, b uint64) uint64 { return a | b })
+			}},
+		{Operator: overloads.MathBitXor,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return bitOperation(lhs, rhs, func(a, b uint64) uint64 { return a ^ b })
+			}},
+		{Operator: overloads.MathBitNot,
+			Unary: func(value ref.Value) ref.Value {
+				switch v := value.(type) {
+				case types.Int:
+					return ^v
+				case types.Uint:
+					return ^v
+				}
+				return types.NewNoSuchOverloadErr()
+			}},
+		{Operator: overloads.MathBitShiftLeft,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return bitShift(lhs, rhs, func(bits uint64, n uint64) uint64 {
+					return bits << n
+				})
+			}},
+		{Operator: overloads.MathBitShiftRight,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return bitShift(lhs, rhs, func(bits uint64, n uint64) uint64 {
+					return bits >> n
+				})
+			}},
+	}
+}
+
+// MaxEditDistanceLength is the maximum length, in code points, of the strings
+// compared by strings.editDistance and strings.similar. The time taken by the
+// comparison grows with the product of the lengths of the strings, so longer
+// strings produce an error rather than occupying the evaluation.
+const MaxEditDistanceLength = 1000
+
+// EditDistanceOverloads returns the implementations of the
+// strings.editDistance and strings.similar functions declared by
+// checker#EditDistanceDeclarations.
+func EditDistanceOverloads() []*Overload {
+	return []*Overload{
+		{Operator: overloads.StringsEditDistance,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				a, aIsString := lhs.(types.String)
+				b, bIsString := rhs.(types.String)
+				if !aIsString || !bIsString {
+					return types.NewNoSuchOverloadErr()
+				}
+				ra, rb, err := editDistanceRunes(a, b)
+				if err != nil {
+					return err
+				}
+				return types.Int(editDistance(ra, rb))
+			}},
+		{Operator: overloads.StringsSimilar,
+			Function: func(values ...ref.Value) ref.Value {
+				if len(values) != 3 {
+					return types.NewNoSuchOverloadErr()
+				}
+				a, aIsString := values[0].(types.String)
+				b, bIsString := values[1].(types.String)
+				threshold, isDouble := values[2].(types.Double)
+				if !aIsString || !bIsString || !isDouble {
+					return types.NewNoSuchOverloadErr()
+				}
+				if threshold < 0 || threshold > 1 {
+					return types.NewErr("similarity threshold out of range: %g", threshold)
+				}
+				ra, rb, err := editDistanceRunes(a, b)
+				if err != nil {
+					return err
+				}
+				longest := len(ra)
+				if len(rb) > longest {
+					longest = len(rb)
+				}
+				if longest == 0 {
+					return types.True
+				}
+				similarity := 1 - float64(editDistance(ra, rb))/float64(longest)
+				return types.Bool(similarity >= float64(threshold))
+			}},
+	}
+}
+
+// editDistanceRunes returns the code points of the compared strings, or an
+// error when either is longer than MaxEditDistanceLength.
+func editDistanceRunes(a, b types.String) ([]rune, []rune, ref.Value) {
+	ra, rb := []rune(string(a)), []rune(string(b))
+	if len(ra) > MaxEditDistanceLength || len(rb) > MaxEditDistanceLength {
+		return nil, nil, types.NewErr(
+			"edit distance of strings longer than %d code points", MaxEditDistanceLength)
+	}
+	return ra, rb, nil
+}
+
+// editDistance returns the Levenshtein distance between two strings, using a
+// single row of the distance matrix.
+func editDistance(a, b []rune) int {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		diagonal := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			substitution := diagonal
+			if a[i-1] != b[j-1] {
+				substitution++
+			}
+			diagonal = row[j]
+			row[j] = min3(row[j]+1, row[j-1]+1, substitution)
+		}
+	}
+	return row[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// MatcherOverloads returns the implementations of the matcher.fromList function
+// and the tests of strings against matchers declared by
+// checker#MatcherDeclarations.
+//
+// Matchers created from constant lists are built when a program is planned
+// rather than on each evaluation.
+func MatcherOverloads() []*Overload {
+	return []*Overload{
+		{Operator: overloads.MatcherFromList,
+			Unary: types.NewListMatcherFromList},
+		{Operator: overloads.ContainsAny,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return matcherTest(lhs, rhs, (*types.ListMatcher).ContainsAny)
+			}},
+		{Operator: overloads.StartsWithAny,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return matcherTest(lhs, rhs, (*types.ListMatcher).StartsWithAny)
+			}},
+		{Operator: overloads.EndsWithAny,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				return matcherTest(lhs, rhs, (*types.ListMatcher).EndsWithAny)
+			}},
+	}
+}
+
+// matcherTest applies the test of a matcher to a string.
+func matcherTest(lhs ref.Value, rhs ref.Value,
+	test func(*types.ListMatcher, ref.Value) ref.Value) ref.Value {
+	matcher, isMatcher := lhs.(*types.ListMatcher)
+	if !isMatcher {
+		return types.NewNoSuchOverloadErr()
+	}
+	return test(matcher, rhs)
+}
+
+// uniqueKeys applies the test to the number of keys of a list which are not
+// equal to any other key, and to the number of keys. Keys of primitive types
+// are counted by hashing so that the test takes linear time, while other keys
+// are compared with each other.
+func uniqueKeys(value ref.Value, test func(unique, total int) bool) ref.Value {
+	keys, isList := value.(traits.Lister)
+	if !isList {
+		return types.NewNoSuchOverloadErr()
+	}
+	hashed := make(map[interface{}]int)
+	var others []ref.Value
+	var otherCounts []int
+	total := 0
+	for it := keys.Iterator(); it.HasNext() == types.True; total++ {
+		key := it.Next()
+		if types.IsUnknownOrError(key) {
+			return key
+		}
+		if hash, hashable := keyHash(key); hashable {
+			hashed[hash]++
+			continue
+		}
+		found := false
+		for i, other := range others {
+			if other.Type() == key.Type() && key.Equal(other) == types.True {
+				otherCounts[i]++
+				found = true
+				break
+			}
+		}
+		if !found {
+			others = append(others, key)
+			otherCounts = append(otherCounts, 1)
+		}
+	}
+	unique := 0
+	for _, count := range hashed {
+		if count == 1 {
+			unique++
+		}
+	}
+	for _, count := range otherCounts {
+		if count == 1 {
+			unique++
+		}
+	}
+	return types.Bool(test(unique, total))
+}
+
+// bytesKey is the hash of a bytes key, distinct from that of an equal string.
+type bytesKey string
+
+// keyHash returns a hashable value which is equal for equal keys of the same
+// primitive type, or false for keys of other types.
+func keyHash(key ref.Value) (interface{}, bool) {
+	switch k := key.(type) {
+	case types.Bool, types.Int, types.Uint, types.Double, types.String, types.Null:
+		return k, true
+	case types.Bytes:
+		return bytesKey(k), true
+	}
+	return nil, false
+}
+
+// mathPredicate applies the predicate to a double value.
+func mathPredicate(value ref.Value, predicate func(float64) bool) ref.Value {
+	d, isDouble := value.(types.Double)
+	if !isDouble {
+		return types.NewNoSuchOverloadErr()
+	}
+	return types.Bool(predicate(float64(d)))
+}
+
+// mathExtreme returns the greatest or least of the values, or of the elements
+// of a single list, for a sign of 1 or -1 respectively. The values must all be
+// of the same numeric type.
+func mathExtreme(function string, values []ref.Value, sign types.Int) ref.Value {
+	if len(values) == 1 {
+		if list, isList := values[0].(traits.Lister); isList {
+			size, _ := list.Size().(types.Int)
+			values = make([]ref.Value, size)
+			for i := types.Int(0); i < size; i++ {
+				values[i] = list.Get(i)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return types.NewErr("%s requires at least one value", function)
+	}
+	extreme := values[0]
+	for _, value := range values {
+		switch value.(type) {
+		case types.Int, types.Uint, types.Double:
+		default:
+			return types.NewNoSuchOverloadErr()
+		}
+		if value.Type() != extreme.Type() {
+			return types.NewErr("%s of values of different types '%s' and '%s'",
+				function, extreme.Type().TypeName(), value.Type().TypeName())
+		}
+		if value.(traits.Comparer).Compare(extreme) == sign {
+			extreme = value
+		}
+	}
+	return extreme
+}
+
+// mathRounding applies a rounding function to a double.
+func mathRounding(value ref.Value, round func(float64) float64) ref.Value {
+	d, isDouble := value.(types.Double)
+	if !isDouble {
+		return types.NewNoSuchOverloadErr()
+	}
+	return types.Double(round(float64(d)))
+}
+
+// bitOperation applies the operation to the bits of two ints or two uints.
+func bitOperation(lhs ref.Value, rhs ref.Value, op func(uint64, uint64) uint64) ref.Value {
+	switch a := lhs.(type) {
+	case types.Int:
+		if b, isInt := rhs.(types.Int); isInt {
+			return types.Int(op(uint64(a), uint64(b)))
+		}
+	case types.Uint:
+		if b, isUint := rhs.(types.Uint); isUint {
+			return types.Uint(op(uint64(a), uint64(b)))
+		}
+	}
+	return types.NewNoSuchOverloadErr()
+}
+
+// bitShift shifts the bits of an int or uint by a non-negative int count. The
+// bits of ints are shifted without regard to their sign, so that a right shift
+// fills the high bits with zeros, and shifts by 64 or more bits produce zero.
+func bitShift(value ref.Value, count ref.Value, shift func(uint64, uint64) uint64) ref.Value {
+	n, isInt := count.(types.Int)
+	if !isInt {
+		return types.NewNoSuchOverloadErr()
+	}
+	if n < 0 {
+		return types.NewErr("negative shift count: %d", n)
+	}
+	switch v := value.(type) {
+	case types.Int:
+		return types.Int(shift(uint64(v), uint64(n)))
+	case types.Uint:
+		return types.Uint(shift(uint64(v), uint64(n)))
+	}
+	return types.NewNoSuchOverloadErr()
+}
+
+// safeArithmetic applies the operation to the dividend and divisor, or
+// returns the default value when the divisor is zero.
+func safeArithmetic(values []ref.Value, op BinaryOp) ref.Value {
+	if len(values) != 3 {
+		return types.NewNoSuchOverloadErr()
+	}
+	switch divisor := values[1].(type) {
+	case types.Int:
+		if divisor == types.IntZero {
+			return values[2]
+		}
+	case types.Uint:
+		if divisor == 0 {
+			return values[2]
+		}
+	case types.Double:
+		if divisor == 0 {
+			return values[2]
+		}
+	}
+	return op(values[0], values[1])
 }
 
 func logicalAnd(lhs ref.Value, rhs ref.Value) ref.Value {
@@ -281,12 +1026,16 @@ func logicalAnd(lhs ref.Value, rhs ref.Value) ref.Value {
 		return types.False
 	}
 
-	if types.IsUnknown(lhs) {
-		return lhs
+	// unknowns absorb errors, as evaluating the unknowns may decide the
+	// result, and are merged when both sides are unknown.
+	if types.IsUnknown(lhs) || types.IsUnknown(rhs) {
+		return types.MergeUnknowns(lhs, rhs)
 	}
 
-	if types.IsUnknown(rhs) {
-		return rhs
+	// errors are returned as-is so that they retain their origin, and are
+	// aggregated when both sides are errors.
+	if types.IsError(lhs) || types.IsError(rhs) {
+		return types.MergeErrors(lhs, rhs)
 	}
 
 	// if the left-hand side is non-boolean return it as the error.
@@ -309,12 +1058,16 @@ func logicalOr(lhs ref.Value, rhs ref.Value) ref.Value {
 		return types.True
 	}
 
-	if types.IsUnknown(lhs) {
-		return lhs
+	// unknowns absorb errors, as evaluating the unknowns may decide the
+	// result, and are merged when both sides are unknown.
+	if types.IsUnknown(lhs) || types.IsUnknown(rhs) {
+		return types.MergeUnknowns(lhs, rhs)
 	}
 
-	if types.IsUnknown(rhs) {
-		return rhs
+	// errors are returned as-is so that they retain their origin, and are
+	// aggregated when both sides are errors.
+	if types.IsError(lhs) || types.IsError(rhs) {
+		return types.MergeErrors(lhs, rhs)
 	}
 
 	// if the left-hand side is non-boolean return it as the error.
@@ -324,9 +1077,18 @@ func logicalOr(lhs ref.Value, rhs ref.Value) ref.Value {
 	return types.NewErr("Got '%v', expected argument of type 'bool'", rhs)
 }
 
+// coalesce returns the right-hand side when the left-hand side is null or an
+// error reporting a missing map key or message field.
+func coalesce(lhs ref.Value, rhs ref.Value) ref.Value {
+	if lhs == types.NullValue || types.IsMissingField(lhs) {
+		return rhs
+	}
+	return lhs
+}
+
 func conditional(values ...ref.Value) ref.Value {
 	if len(values) != 3 {
-		return types.NewErr("no such overload")
+		return types.NewNoSuchOverloadErr()
 	}
 	cond := values[0]
 	condType := cond.Type()
@@ -338,6 +1100,56 @@ func conditional(values ...ref.Value) ref.Value {
 	} else if types.IsError(condType) || types.IsUnknown(condType) {
 		return cond
 	} else {
-		return types.NewErr("no such overload")
+		return types.NewNoSuchOverloadErr()
+	}
+}
+
+// TimeZoneOverloads returns overloads of the timestamp accessors, such as
+// getHours, which resolve their time zone arguments with the given loader
+// rather than with types.LoadTimeZone.
+//
+// The overloads may be added to a dispatcher alongside the StandardOverloads,
+// for example with types.LoadFixedTimeZone to restrict expressions to fixed
+// UTC offsets, or with a loader from types.NewTimeZoneDatabase to supply the
+// time zone database to binaries which lack one.
+func TimeZoneOverloads(loader types.TimeZoneLoader) []*Overload {
+	var tzOverloads []*Overload
+	for _, function := range []string{
+		overloads.TimeGetFullYear,
+		overloads.TimeGetMonth,
+		overloads.TimeGetDayOfYear,
+		overloads.TimeGetDate,
+		overloads.TimeGetDayOfMonth,
+		overloads.TimeGetDayOfWeek,
+		overloads.TimeGetHours,
+		overloads.TimeGetMinutes,
+		overloads.TimeGetSeconds,
+		overloads.TimeGetMilliseconds} {
+		function := function
+		tzOverloads = append(tzOverloads, &Overload{
+			Operator:     function,
+			OperandTrait: traits.ReceiverType,
+			Binary: func(ts ref.Value, tz ref.Value) ref.Value {
+				if types.TimestampType != ts.Type() {
+					return types.NewNoSuchOverloadErr()
+				}
+				return ts.(types.Timestamp).ReceiveInTimeZone(function, tz, loader)
+			}})
+	}
+	return tzOverloads
+}
+
+// contains tests the membership of the lhs within the rhs through the
+// traits.Container protocol, so that the values of any type with the
+// ContainerType trait, such as a user-defined set, may be the rhs of 'in'.
+func contains(lhs ref.Value, rhs ref.Value) ref.Value {
+	if !rhs.Type().HasTrait(traits.ContainerType) {
+		return types.NewNoSuchOverloadErr()
+	}
+	result := rhs.(traits.Container).Contains(lhs)
+	if _, isBool := result.(types.Bool); !isBool && !types.IsUnknownOrError(result) {
+		return types.NewErr("invalid result of type '%s' from 'in'",
+			result.Type().TypeName())
 	}
+	return result
 }
@@ -0,0 +1,194 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	tpb "github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/google/cel-go/parser"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+func TestSafeArithmetic(t *testing.T) {
+	e := newEvaluator(append(checker.SafeArithmeticDeclarations(),
+		decls.NewIdent("errors", decls.Int, nil),
+		decls.NewIdent("requests", decls.Int, nil),
+		decls.NewIdent("ratio", decls.Double, nil)),
+		functions.SafeArithmeticOverloads()...)
+	vars := map[string]interface{}{
+		"errors":   4,
+		"requests": 0,
+		"ratio":    0.0}
+	e.run(t, []evalTest{
+		{text: "safeDiv(errors, requests, -1)", vars: vars, expected: types.Int(-1)},
+		{text: "safeDiv(errors, 2, -1)", vars: vars, expected: types.Int(2)},
+		{text: "safeMod(errors, requests, 7)", vars: vars, expected: types.Int(7)},
+		{text: "safeDiv(1.0, ratio, 0.5)", vars: vars, expected: types.Double(0.5)},
+		{text: "safeMod(5u, 3u, 0u)", expected: types.Uint(2)},
+		{text: "errors / requests > 1 || errors % requests == 1", vars: vars,
+			opts:     []interpreter.ProgramOption{interpreter.DivisionByZeroDefault(0, 0)},
+			expected: types.False},
+		{text: "errors / requests == -1 && uint(errors) / uint(requests) == 7u", vars: vars,
+			opts:     []interpreter.ProgramOption{interpreter.DivisionByZeroDefault(-1, 7)},
+			expected: types.True},
+		// Division by zero remains an error by default.
+		{text: "errors / requests", vars: vars},
+	})
+}
+
+func TestMathPredicates(t *testing.T) {
+	e := newEvaluator(append(checker.MathDeclarations(),
+		decls.NewIdent("ratio", decls.Double, nil)),
+		functions.MathOverloads()...)
+	ratio := func(value float64) map[string]interface{} {
+		return map[string]interface{}{"ratio": value}
+	}
+	e.run(t, []evalTest{
+		{text: "math.isFinite(ratio) ? int(ratio) : -1", vars: ratio(2.5),
+			expected: types.Int(2)},
+		{text: "math.isFinite(ratio) ? int(ratio) : -1", vars: ratio(math.Inf(1)),
+			expected: types.Int(-1)},
+		{text: "math.isNaN(ratio)", vars: ratio(math.NaN()), expected: types.True},
+		{text: "math.isInf(ratio)", vars: ratio(math.Inf(-1)), expected: types.True},
+		{text: "math.isInf(ratio) || math.isNaN(ratio)", vars: ratio(1e300),
+			expected: types.False},
+		{text: "int(ratio)", vars: ratio(math.NaN())},
+		{text: "uint(ratio)", vars: ratio(-1.0)},
+		{text: "int(ratio)", vars: ratio(1e19)},
+	})
+}
+
+func TestMathFunctions(t *testing.T) {
+	e := newEvaluator(append(checker.MathDeclarations(),
+		decls.NewIdent("i", decls.Int, nil),
+		decls.NewIdent("scores", decls.NewListType(decls.Double), nil)),
+		functions.MathOverloads()...)
+	i := func(value int64) map[string]interface{} {
+		return map[string]interface{}{"i": value}
+	}
+	scores := map[string]interface{}{"scores": []float64{1.5, -1, 2.5}}
+	e.run(t, []evalTest{
+		{text: "math.greatest(i, 3)", vars: i(5), expected: types.Int(5)},
+		{text: "math.least(i, 3)", vars: i(5), expected: types.Int(3)},
+		{text: "math.greatest(7u)", expected: types.Uint(7)},
+		{text: "math.greatest(scores)", vars: scores, expected: types.Double(2.5)},
+		{text: "math.least(scores)", vars: scores, expected: types.Double(-1.0)},
+		{text: "math.least([])"},
+		{text: "math.abs(i)", vars: i(-4), expected: types.Int(4)},
+		{text: "math.abs(i)", vars: i(math.MinInt64)},
+		{text: "math.abs(-2.5)", expected: types.Double(2.5)},
+		{text: "math.ceil(1.2)", expected: types.Double(2)},
+		{text: "math.floor(-1.2)", expected: types.Double(-2)},
+		{text: "math.round(2.5) == 3.0 && math.round(-2.5) == -3.0", expected: types.True},
+		{text: "math.sqrt(i)", vars: i(16), expected: types.Double(4)},
+		{text: "math.bitAnd(i, 6)", vars: i(3), expected: types.Int(2)},
+		{text: "math.bitOr(3u, 4u)", expected: types.Uint(7)},
+		{text: "math.bitXor(i, 1)", vars: i(3), expected: types.Int(2)},
+		{text: "math.bitNot(i)", vars: i(0), expected: types.Int(-1)},
+		{text: "math.bitShiftLeft(i, 4)", vars: i(1), expected: types.Int(16)},
+		{text: "math.bitShiftLeft(1u, 64)", expected: types.Uint(0)},
+		{text: "math.bitShiftRight(i, 60)", vars: i(-1), expected: types.Int(15)},
+		{text: "math.bitShiftRight(i, -1)", vars: i(1)},
+	})
+
+	// Values of different types are not compared.
+	parsed, errors := parser.ParseText("math.greatest(1, 2u)")
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	program := interpreter.NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+	if res, _ := e.interp.NewInterpretable(program).Eval(interpreter.NewActivation(
+		map[string]interface{}{})); !types.IsError(res) {
+		t.Errorf("Got '%v', wanted error", res)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	e := newEvaluator(append(checker.EditDistanceDeclarations(),
+		decls.NewIdent("name", decls.String, nil)),
+		functions.EditDistanceOverloads()...)
+	name := func(value string) map[string]interface{} {
+		return map[string]interface{}{"name": value}
+	}
+	e.run(t, []evalTest{
+		{text: "strings.editDistance(name, 'kitten')", vars: name("sitting"),
+			expected: types.Int(3)},
+		{text: "strings.editDistance(name, '')", vars: name("héllo"), expected: types.Int(5)},
+		{text: "strings.editDistance(name, 'héllo')", vars: name("hello"),
+			expected: types.Int(1)},
+		{text: "strings.similar(name, 'administrator', 0.8)", vars: name("adminstrator"),
+			expected: types.True},
+		{text: "strings.similar(name, 'administrator', 0.8)", vars: name("admin"),
+			expected: types.False},
+		{text: "strings.similar(name, '', 1.0)", vars: name(""), expected: types.True},
+		{text: "strings.similar(name, 'a', 1.5)", vars: name("a")},
+		{text: "strings.editDistance(name, 'a')",
+			vars: name(strings.Repeat("a", functions.MaxEditDistanceLength+1))},
+		{text: "strings.similar(name, 'a', 0.5)",
+			vars: name(strings.Repeat("é", functions.MaxEditDistanceLength+1))},
+	})
+}
+
+func TestMatcher(t *testing.T) {
+	e := newEvaluator(append(checker.MatcherDeclarations(),
+		decls.NewIdent("host", decls.String, nil),
+		decls.NewIdent("domains", decls.NewListType(decls.String), nil)),
+		functions.MatcherOverloads()...)
+	var tests []evalTest
+	for _, text := range []string{
+		`matcher.fromList(['.example.com', '.example.org']).endsWithAny(host)`,
+		`matcher.fromList(domains).endsWithAny(host)`,
+	} {
+		for host, expected := range map[string]types.Bool{
+			"api.example.com": types.True,
+			"example.org":     types.False,
+			"example.org.net": types.False,
+		} {
+			tests = append(tests, evalTest{text: text,
+				vars: map[string]interface{}{
+					"host":    host,
+					"domains": []string{".example.com", ".example.org"}},
+				expected: expected})
+		}
+	}
+	e.run(t, tests)
+}
+
+func TestTimeZones(t *testing.T) {
+	idents := []*checkedpb.Decl{decls.NewIdent("ts", decls.Timestamp, nil)}
+	standard := newEvaluator(idents)
+	fixed := newEvaluator(idents,
+		functions.TimeZoneOverloads(types.LoadFixedTimeZone)...)
+	// 1970-01-01T02:05:06Z
+	vars := map[string]interface{}{"ts": &tpb.Timestamp{Seconds: 7506}}
+	standard.run(t, []evalTest{
+		{text: "ts.getHours()", vars: vars, expected: types.Int(2)},
+		{text: "ts.getHours('America/Phoenix')", vars: vars, expected: types.Int(19)},
+		{text: "ts.getHours('-07:00')", vars: vars, expected: types.Int(19)},
+	})
+	fixed.run(t, []evalTest{
+		{text: "ts.getHours()", vars: vars, expected: types.Int(2)},
+		{text: "ts.getMinutes('+05:30')", vars: vars, expected: types.Int(35)},
+		{text: "ts.getHours('America/Phoenix')", vars: vars},
+	})
+}
@@ -0,0 +1,373 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// StringsOverloads returns the implementations of the string functions
+// declared by checker#StringsDeclarations: split, join, replace, substring,
+// indexOf, lowerAscii, upperAscii, trim, and format.
+//
+// Indexes into strings, such as those of substring and indexOf, count code
+// points rather than bytes.
+func StringsOverloads() []*Overload {
+	return []*Overload{
+		{Operator: overloads.Split,
+			Function: func(values ...ref.Value) ref.Value {
+				s, sep, n, ok := stringArgs(values, -1)
+				if !ok {
+					return types.NewNoSuchOverloadErr()
+				}
+				return types.NewStringList(strings.SplitN(s, sep, n))
+			}},
+		{Operator: overloads.Join,
+			Function: func(values ...ref.Value) ref.Value {
+				if len(values) != 1 && len(values) != 2 {
+					return types.NewNoSuchOverloadErr()
+				}
+				sep := types.String("")
+				if len(values) == 2 {
+					var isString bool
+					if sep, isString = values[1].(types.String); !isString {
+						return types.NewNoSuchOverloadErr()
+					}
+				}
+				elems, err := stringElems(values[0])
+				if err != nil {
+					return err
+				}
+				return types.String(strings.Join(elems, string(sep)))
+			}},
+		{Operator: overloads.Replace,
+			Function: func(values ...ref.Value) ref.Value {
+				if len(values) != 3 && len(values) != 4 {
+					return types.NewNoSuchOverloadErr()
+				}
+				// The replacement precedes the optional count, so it is
+				// checked apart from the other arguments.
+				args := []ref.Value{values[0], values[1]}
+				args = append(args, values[3:]...)
+				s, old, n, ok := stringArgs(args, -1)
+				replacement, isString := values[2].(types.String)
+				if !ok || !isString {
+					return types.NewNoSuchOverloadErr()
+				}
+				return types.String(strings.Replace(s, old, string(replacement), n))
+			}},
+		{Operator: overloads.Substring,
+			Function: func(values ...ref.Value) ref.Value {
+				if len(values) != 2 && len(values) != 3 {
+					return types.NewNoSuchOverloadErr()
+				}
+				s, isString := values[0].(types.String)
+				start, isInt := values[1].(types.Int)
+				if !isString || !isInt {
+					return types.NewNoSuchOverloadErr()
+				}
+				runes := []rune(string(s))
+				end := types.Int(len(runes))
+				if len(values) == 3 {
+					if end, isInt = values[2].(types.Int); !isInt {
+						return types.NewNoSuchOverloadErr()
+					}
+				}
+				if start < 0 || end > types.Int(len(runes)) || start > end {
+					return types.NewErr("substring range [%d, %d) out of bounds of '%s'",
+						start, end, s)
+				}
+				return types.String(runes[start:end])
+			}},
+		{Operator: overloads.IndexOf,
+			Function: func(values ...ref.Value) ref.Value {
+				s, sub, offset, ok := stringArgs(values, 0)
+				if !ok {
+					return types.NewNoSuchOverloadErr()
+				}
+				runes := []rune(s)
+				if offset < 0 || offset > len(runes) {
+					return types.NewErr("index %d out of bounds of '%s'", offset, s)
+				}
+				i := strings.Index(string(runes[offset:]), sub)
+				if i < 0 {
+					return types.Int(-1)
+				}
+				return types.Int(offset + utf8.RuneCountInString(string(runes[offset:])[:i]))
+			}},
+		{Operator: overloads.LowerAscii,
+			Unary: func(value ref.Value) ref.Value {
+				return mapAscii(value, 'A', 'Z', 'a'-'A')
+			}},
+		{Operator: overloads.UpperAscii,
+			Unary: func(value ref.Value) ref.Value {
+				return mapAscii(value, 'a', 'z', 'A'-'a')
+			}},
+		{Operator: overloads.Trim,
+			Unary: func(value ref.Value) ref.Value {
+				s, isString := value.(types.String)
+				if !isString {
+					return types.NewNoSuchOverloadErr()
+				}
+				return types.String(strings.TrimSpace(string(s)))
+			}},
+		{Operator: overloads.Format,
+			Binary: func(lhs ref.Value, rhs ref.Value) ref.Value {
+				format, isString := lhs.(types.String)
+				args, isList := rhs.(traits.Lister)
+				if !isString || !isList {
+					return types.NewNoSuchOverloadErr()
+				}
+				return formatString(string(format), args)
+			}},
+	}
+}
+
+// stringArgs returns the string receiver and string argument of a call, along
+// with its optional int argument, or the default when it was omitted.
+func stringArgs(values []ref.Value, defaultInt int) (string, string, int, bool) {
+	if len(values) != 2 && len(values) != 3 {
+		return "", "", 0, false
+	}
+	s, isString := values[0].(types.String)
+	arg, argIsString := values[1].(types.String)
+	if !isString || !argIsString {
+		return "", "", 0, false
+	}
+	n := defaultInt
+	if len(values) == 3 {
+		i, isInt := values[2].(types.Int)
+		if !isInt {
+			return "", "", 0, false
+		}
+		n = int(i)
+	}
+	return string(s), string(arg), n, true
+}
+
+// stringElems returns the elements of a list of strings.
+func stringElems(list ref.Value) ([]string, ref.Value) {
+	lister, isList := list.(traits.Lister)
+	if !isList {
+		return nil, types.NewNoSuchOverloadErr()
+	}
+	size, isInt := lister.Size().(types.Int)
+	if !isInt {
+		return nil, types.NewNoSuchOverloadErr()
+	}
+	elems := make([]string, size)
+	for i := types.Int(0); i < size; i++ {
+		elem, isString := lister.Get(i).(types.String)
+		if !isString {
+			return nil, types.NewErr("join of a list with a non-string element")
+		}
+		elems[i] = string(elem)
+	}
+	return elems, nil
+}
+
+// mapAscii shifts the ASCII letters of a string within the range [lo, hi],
+// leaving other characters unchanged.
+func mapAscii(value ref.Value, lo, hi rune, shift rune) ref.Value {
+	s, isString := value.(types.String)
+	if !isString {
+		return types.NewNoSuchOverloadErr()
+	}
+	return types.String(strings.Map(func(r rune) rune {
+		if r >= lo && r <= hi {
+			return r + shift
+		}
+		return r
+	}, string(s)))
+}
+
+// formatString substitutes the arguments for the verbs of a printf-style
+// format string:
+//
+//     %s      any value, as by string(), with lists and maps in brackets and
+//             the entries of maps sorted
+//     %d      an int or uint in decimal
+//     %f, %e  a number in fixed-point or scientific notation, with an
+//             optional precision, e.g. %.2f
+//     %x, %X  an int, uint, string, or bytes in hexadecimal
+//     %o, %b  an int or uint in octal or binary
+//     %%      a literal percent sign
+//
+// It is an error for the number of arguments not to match the verbs.
+func formatString(format string, args traits.Lister) ref.Value {
+	size, isInt := args.Size().(types.Int)
+	if !isInt {
+		return types.NewNoSuchOverloadErr()
+	}
+	var out strings.Builder
+	next := types.Int(0)
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			out.WriteByte(c)
+			continue
+		}
+		start := i
+		precision := -1
+		if i+1 < len(format) && format[i+1] == '.' {
+			j := i + 2
+			for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+				j++
+			}
+			p, err := strconv.Atoi(format[i+2 : j])
+			if err != nil {
+				return types.NewErr("format: invalid precision at position %d", start)
+			}
+			precision, i = p, j-1
+		}
+		if i+1 == len(format) {
+			return types.NewErr("format: incomplete verb at position %d", start)
+		}
+		i++
+		verb := format[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if next == size {
+			return types.NewErr("format: missing argument for '%s' at position %d",
+				format[start:i+1], start)
+		}
+		formatted, err := formatArg(verb, precision, args.Get(next))
+		if err != nil {
+			return err
+		}
+		out.WriteString(formatted)
+		next++
+	}
+	if next != size {
+		return types.NewErr("format: %d arguments given for %d verbs", size, next)
+	}
+	return types.String(out.String())
+}
+
+// formatArg formats a single argument for a verb.
+func formatArg(verb byte, precision int, arg ref.Value) (string, ref.Value) {
+	switch verb {
+	case 's':
+		return formatValue(arg)
+	case 'd':
+		switch v := arg.(type) {
+		case types.Int:
+			return strconv.FormatInt(int64(v), 10), nil
+		case types.Uint:
+			return strconv.FormatUint(uint64(v), 10), nil
+		}
+	case 'f', 'e':
+		if precision < 0 {
+			precision = 6
+		}
+		switch v := arg.(type) {
+		case types.Double:
+			return strconv.FormatFloat(float64(v), verb, precision, 64), nil
+		case types.Int:
+			return strconv.FormatFloat(float64(v), verb, precision, 64), nil
+		case types.Uint:
+			return strconv.FormatFloat(float64(v), verb, precision, 64), nil
+		}
+	case 'x', 'X':
+		var formatted string
+		switch v := arg.(type) {
+		case types.Int:
+			formatted = strconv.FormatInt(int64(v), 16)
+		case types.Uint:
+			formatted = strconv.FormatUint(uint64(v), 16)
+		case types.String:
+			formatted = hex.EncodeToString([]byte(v))
+		case types.Bytes:
+			formatted = hex.EncodeToString(v)
+		default:
+			return "", formatError(verb, arg)
+		}
+		if verb == 'X' {
+			formatted = strings.ToUpper(formatted)
+		}
+		return formatted, nil
+	case 'o', 'b':
+		base := 8
+		if verb == 'b' {
+			base = 2
+		}
+		switch v := arg.(type) {
+		case types.Int:
+			return strconv.FormatInt(int64(v), base), nil
+		case types.Uint:
+			return strconv.FormatUint(uint64(v), base), nil
+		}
+	default:
+		return "", types.NewErr("format: unknown verb '%%%c'", verb)
+	}
+	return "", formatError(verb, arg)
+}
+
+func formatError(verb byte, arg ref.Value) ref.Value {
+	return types.NewErr("format: verb '%%%c' does not accept type '%s'",
+		verb, arg.Type().TypeName())
+}
+
+// formatValue formats a value for the '%s' verb.
+func formatValue(value ref.Value) (string, ref.Value) {
+	switch v := value.(type) {
+	case types.String:
+		return string(v), nil
+	case types.Null:
+		return "null", nil
+	case traits.Lister:
+		size := v.Size().(types.Int)
+		elems := make([]string, size)
+		for i := types.Int(0); i < size; i++ {
+			elem, err := formatValue(v.Get(i))
+			if err != nil {
+				return "", err
+			}
+			elems[i] = elem
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case traits.Mapper:
+		var entries []string
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			k, err := formatValue(key)
+			if err != nil {
+				return "", err
+			}
+			val, err := formatValue(v.Get(key))
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, k+": "+val)
+		}
+		sort.Strings(entries)
+		return "{" + strings.Join(entries, ", ") + "}", nil
+	}
+	if s, isString := value.ConvertToType(types.StringType).(types.String); isString {
+		return string(s), nil
+	}
+	return "", formatError('s', value)
+}
@@ -0,0 +1,62 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter/functions"
+)
+
+func TestStrings(t *testing.T) {
+	e := newEvaluator(append(checker.StringsDeclarations(),
+		decls.NewIdent("s", decls.String, nil)),
+		functions.StringsOverloads()...)
+	vars := map[string]interface{}{"s": "cart"}
+	e.run(t, []evalTest{
+		{text: `'a,b,,c'.split(',') == ['a', 'b', '', 'c']`, expected: types.True},
+		{text: `'a,b,c'.split(',', 2) == ['a', 'b,c']`, expected: types.True},
+		{text: `'a,b'.split(',', 0).size()`, expected: types.Int(0)},
+		{text: `['a', 'b', 'c'].join('-')`, expected: types.String("a-b-c")},
+		{text: `['a', 'b'].join()`, expected: types.String("ab")},
+		{text: `'aaa'.replace('a', 'b')`, expected: types.String("bbb")},
+		{text: `'aaa'.replace('a', 'b', 2)`, expected: types.String("bba")},
+		{text: `'héllo'.substring(1)`, expected: types.String("éllo")},
+		{text: `'héllo'.substring(1, 3)`, expected: types.String("él")},
+		{text: `'héllo'.substring(4, 6)`},
+		{text: `'héllo'.substring(3, 2)`},
+		{text: `'héllo héllo'.indexOf('llo')`, expected: types.Int(2)},
+		{text: `'héllo héllo'.indexOf('llo', 3)`, expected: types.Int(8)},
+		{text: `'héllo'.indexOf('x')`, expected: types.Int(-1)},
+		{text: `'héllo'.indexOf('l', 6)`},
+		{text: `'ÀbC'.lowerAscii()`, expected: types.String("Àbc")},
+		{text: `'àbC'.upperAscii()`, expected: types.String("àBC")},
+		{text: `'  a b\n'.trim()`, expected: types.String("a b")},
+		{text: `'%s has %d items'.format([s, dyn(3)])`, vars: vars,
+			expected: types.String("cart has 3 items")},
+		{text: `'%.2f%% %e'.format([2.5, dyn(1500)])`, expected: types.String("2.50% 1.500000e+03")},
+		{text: `'%x %X %o %b'.format([255, dyn(b'\x01\x7f'), dyn(8u), 5])`,
+			expected: types.String("ff 017F 10 101")},
+		{text: `'%s %s %s'.format([[1, dyn('a')], dyn({'b': dyn(true), 'a': null}), dyn(1.5)])`,
+			expected: types.String("[1, a] {a: null, b: true} 1.5")},
+		{text: `'%d'.format([])`},
+		{text: `'%d'.format([1, 2])`},
+		{text: `'%d'.format(['a'])`},
+		{text: `'%q'.format(['a'])`},
+	})
+}
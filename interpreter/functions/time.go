@@ -0,0 +1,216 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Clock returns the current time for the since, until, and age functions.
+type Clock func() time.Time
+
+// TimeOverloads returns the implementations of the time functions declared by
+// checker#TimeDeclarations. The current time is read from the clock, and the
+// time zone arguments are resolved with the loader, which default to time.Now
+// and types.LoadTimeZone when nil.
+//
+// The since, until, and age overloads are Volatile, since their results
+// depend on the time at which they are called.
+func TimeOverloads(clock Clock, loader types.TimeZoneLoader) []*Overload {
+	if clock == nil {
+		clock = time.Now
+	}
+	if loader == nil {
+		loader = types.LoadTimeZone
+	}
+	ts := types.TimestampType
+	str := types.StringType
+	return []*Overload{
+		{Operator: overloads.TimestampTruncateToMinute,
+			OverloadOf: overloads.TruncateToMinute,
+			ArgTypes:   []ref.Type{ts},
+			Unary: func(value ref.Value) ref.Value {
+				return inTimeZone(value, types.String("UTC"), loader,
+					func(t time.Time) ref.Value {
+						return timestampValue(t.Truncate(time.Minute))
+					})
+			}},
+		{Operator: overloads.TimestampTruncateToHour,
+			OverloadOf: overloads.TruncateToHour,
+			ArgTypes:   []ref.Type{ts},
+			Unary: func(value ref.Value) ref.Value {
+				return inTimeZone(value, types.String("UTC"), loader, truncateToHour)
+			}},
+		{Operator: overloads.TruncateToHourWithTz,
+			OverloadOf: overloads.TruncateToHour,
+			ArgTypes:   []ref.Type{ts, str},
+			Binary: func(value ref.Value, tz ref.Value) ref.Value {
+				return inTimeZone(value, tz, loader, truncateToHour)
+			}},
+		{Operator: overloads.TimestampTruncateToDay,
+			OverloadOf: overloads.TruncateToDay,
+			ArgTypes:   []ref.Type{ts},
+			Unary: func(value ref.Value) ref.Value {
+				return inTimeZone(value, types.String("UTC"), loader, truncateToDay)
+			}},
+		{Operator: overloads.TruncateToDayWithTz,
+			OverloadOf: overloads.TruncateToDay,
+			ArgTypes:   []ref.Type{ts, str},
+			Binary: func(value ref.Value, tz ref.Value) ref.Value {
+				return inTimeZone(value, tz, loader, truncateToDay)
+			}},
+		{Operator: overloads.SinceTimestamp,
+			OverloadOf: overloads.Since,
+			ArgTypes:   []ref.Type{ts},
+			Volatile:   true,
+			Unary: func(value ref.Value) ref.Value {
+				return inTimeZone(value, types.String("UTC"), loader,
+					func(t time.Time) ref.Value {
+						return durationValue(clock().Sub(t))
+					})
+			}},
+		{Operator: overloads.UntilTimestamp,
+			OverloadOf: overloads.Until,
+			ArgTypes:   []ref.Type{ts},
+			Volatile:   true,
+			Unary: func(value ref.Value) ref.Value {
+				return inTimeZone(value, types.String("UTC"), loader,
+					func(t time.Time) ref.Value {
+						return durationValue(t.Sub(clock()))
+					})
+			}},
+		{Operator: overloads.AgeTimestamp,
+			OverloadOf: overloads.Age,
+			ArgTypes:   []ref.Type{ts},
+			Volatile:   true,
+			Unary: func(value ref.Value) ref.Value {
+				return inTimeZone(value, types.String("UTC"), loader,
+					func(t time.Time) ref.Value {
+						return age(t, clock().In(t.Location()))
+					})
+			}},
+		{Operator: overloads.AgeTimestampWithTz,
+			OverloadOf: overloads.Age,
+			ArgTypes:   []ref.Type{ts, str},
+			Volatile:   true,
+			Binary: func(value ref.Value, tz ref.Value) ref.Value {
+				return inTimeZone(value, tz, loader,
+					func(t time.Time) ref.Value {
+						return age(t, clock().In(t.Location()))
+					})
+			}},
+		{Operator: overloads.ParseTimestampString,
+			OverloadOf: overloads.ParseTimestamp,
+			ArgTypes:   []ref.Type{str, str},
+			Binary: func(text ref.Value, layout ref.Value) ref.Value {
+				return parseTimestamp(text, layout, time.UTC)
+			}},
+		{Operator: overloads.ParseTimestampStringWithTz,
+			OverloadOf: overloads.ParseTimestamp,
+			ArgTypes:   []ref.Type{str, str, str},
+			Function: func(values ...ref.Value) ref.Value {
+				if len(values) != 3 || values[2].Type() != types.StringType {
+					return types.NewNoSuchOverloadErr()
+				}
+				loc, err := loader(string(values[2].(types.String)))
+				if err != nil {
+					return types.NewErr("%v", err)
+				}
+				return parseTimestamp(values[0], values[1], loc)
+			}},
+	}
+}
+
+// inTimeZone converts the timestamp to a time in the named time zone, and
+// applies the function to it.
+func inTimeZone(value ref.Value, tz ref.Value, loader types.TimeZoneLoader,
+	f func(time.Time) ref.Value) ref.Value {
+	if value.Type() != types.TimestampType || tz.Type() != types.StringType {
+		return types.NewNoSuchOverloadErr()
+	}
+	t, err := ptypes.Timestamp(value.(types.Timestamp).Timestamp)
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	loc, err := loader(string(tz.(types.String)))
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	return f(t.In(loc))
+}
+
+// truncateToHour returns the start of the hour of the time in its location,
+// which differs from time.Truncate for locations whose offset is not a whole
+// number of hours.
+func truncateToHour(t time.Time) ref.Value {
+	return timestampValue(time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0,
+		t.Location()))
+}
+
+// truncateToDay returns the start of the day of the time in its location.
+func truncateToDay(t time.Time) ref.Value {
+	return timestampValue(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0,
+		t.Location()))
+}
+
+// age returns the number of whole years from the time to now, which is
+// negative when the time is in the future.
+func age(t time.Time, now time.Time) ref.Value {
+	if now.Before(t) {
+		return types.Int(-wholeYears(now, t))
+	}
+	return types.Int(wholeYears(t, now))
+}
+
+// wholeYears returns the number of anniversaries of the start up to the end.
+func wholeYears(start time.Time, end time.Time) int {
+	years := end.Year() - start.Year()
+	if end.Month() < start.Month() ||
+		end.Month() == start.Month() && end.Day() < start.Day() {
+		years--
+	}
+	return years
+}
+
+// parseTimestamp parses the text with a layout of the time package, such as
+// '2006-01-02 15:04', in the location when the layout has no time zone.
+func parseTimestamp(text ref.Value, layout ref.Value, loc *time.Location) ref.Value {
+	if text.Type() != types.StringType || layout.Type() != types.StringType {
+		return types.NewNoSuchOverloadErr()
+	}
+	t, err := time.ParseInLocation(string(layout.(types.String)),
+		string(text.(types.String)), loc)
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	return timestampValue(t)
+}
+
+func timestampValue(t time.Time) ref.Value {
+	ts, err := ptypes.TimestampProto(t)
+	if err != nil {
+		return types.NewErr("%v", err)
+	}
+	return types.Timestamp{Timestamp: ts}
+}
+
+func durationValue(d time.Duration) ref.Value {
+	return types.Duration{Duration: ptypes.DurationProto(d)}
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"testing"
+	"time"
+
+	dpb "github.com/golang/protobuf/ptypes/duration"
+	tpb "github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/interpreter/functions"
+)
+
+func TestTime(t *testing.T) {
+	now := time.Date(2018, 6, 15, 17, 30, 0, 0, time.UTC)
+	e := newEvaluator(append(checker.TimeDeclarations(),
+		decls.NewIdent("t", decls.Timestamp, nil)),
+		functions.TimeOverloads(func() time.Time { return now }, types.LoadFixedTimeZone)...)
+	vars := map[string]interface{}{"t": &tpb.Timestamp{Seconds: 1529055900}}
+	e.run(t, []evalTest{
+		{text: "t.truncateToMinute() == timestamp('2018-06-15T09:45:00Z')", vars: vars,
+			expected: types.True},
+		{text: "t.truncateToHour() == timestamp('2018-06-15T09:00:00Z')", vars: vars,
+			expected: types.True},
+		{text: "t.truncateToHour('+05:30') == timestamp('2018-06-15T09:30:00Z')", vars: vars,
+			expected: types.True},
+		{text: "t.truncateToDay('-10:00') == timestamp('2018-06-14T10:00:00Z')", vars: vars,
+			expected: types.True},
+		{text: "t - t.truncateToDay('+02:00') < duration('18h')", vars: vars,
+			expected: types.True},
+		{text: "t.since() == duration('27900s')", vars: vars, expected: types.True},
+		{text: "t.until() == duration('-27900s')", vars: vars, expected: types.True},
+		{text: "timestamp('2000-06-16T00:00:00Z').age()", expected: types.Int(17)},
+		{text: "timestamp('2000-06-16T00:00:00Z').age('+08:00')", expected: types.Int(18)},
+		{text: "timestamp('2020-06-15T00:00:00Z').age()", expected: types.Int(-2)},
+		{text: "parseTimestamp('15.06.2018 09:45', '02.01.2006 15:04') == t", vars: vars,
+			expected: types.True},
+		{text: "parseTimestamp('15.06.2018 11:45', '02.01.2006 15:04', '+02:00') == t",
+			vars: vars, expected: types.True},
+		{text: "parseTimestamp('2018-06-15', '02.01.2006')"},
+		{text: "t.truncateToDay('Europe/Berlin')", vars: vars},
+	})
+
+	// Calls which read the clock are not folded by the optimizer.
+	program := interpreter.Optimize(
+		e.program(t, "timestamp('2018-06-15T17:00:00Z').since()"), e.dispatcher)
+	interpretable := e.interp.NewInterpretable(program)
+	now = now.Add(time.Hour)
+	res, _ := interpretable.Eval(interpreter.NewActivation(map[string]interface{}{}))
+	if res.Equal(types.Duration{Duration: &dpb.Duration{Seconds: 5400}}) != types.True {
+		t.Errorf("Got '%v' from an optimized since, wanted '1.5h'", res)
+	}
+}
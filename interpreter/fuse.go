@@ -0,0 +1,224 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+var (
+	// Comparison operators which may be fused with a constant argument.
+	fusableComparisons = map[string]bool{
+		operators.Equals:        true,
+		operators.NotEquals:     true,
+		operators.Less:          true,
+		operators.LessEquals:    true,
+		operators.Greater:       true,
+		operators.GreaterEquals: true,
+	}
+)
+
+// fuseInstructions is a peephole pass which replaces common instruction
+// sequences with superinstructions:
+//
+//     local 'a', r1                   call  select(local 'a'.b.c), r3
+//     call  select(1, 'b'), r2   =>
+//     call  select(2, 'c'), r3
+//
+//     call  _==_(r3, r4), r5     =>   call  _==_(r3, x), r5
+//
+// Each superinstruction writes the same registers as the sequence it
+// replaces. Sequences which span the target of a jump are left as-is, and the
+// counts of the remaining jumps are adjusted to the new instruction offsets.
+//
+// Comparisons are only fused when the dispatcher is the one created by
+// NewDispatcher, since a custom Dispatcher may resolve calls differently.
+func fuseInstructions(instructions []Instruction,
+	literals map[int64]ref.Value,
+	dispatcher Dispatcher) []Instruction {
+	jumpTargets := make(map[int]bool)
+	for i, inst := range instructions {
+		if jump, isJump := inst.(*JumpInst); isJump {
+			jumpTargets[i+jump.Count] = true
+		}
+	}
+	reads := readCounts(instructions)
+	// Offsets of the original instructions within the fused program. The
+	// extra entry accounts for jumps to the end of the program.
+	offsets := make([]int, len(instructions)+1)
+	var fused []Instruction
+	for i := 0; i < len(instructions); {
+		offsets[i] = len(fused)
+		if path, count := fuseSelectPath(instructions[i:], i, jumpTargets); count > 1 {
+			path.qualified = qualifiedPath(path, reads)
+			for j := i + 1; j < i+count; j++ {
+				offsets[j] = len(fused)
+			}
+			fused = append(fused, path)
+			i += count
+			continue
+		}
+		if cmp, found := fuseCompareConst(instructions[i], literals, dispatcher); found {
+			fused = append(fused, cmp)
+		} else {
+			fused = append(fused, instructions[i])
+		}
+		i++
+	}
+	offsets[len(instructions)] = len(fused)
+	if len(fused) == len(instructions) {
+		return fused
+	}
+	for i, inst := range instructions {
+		jump, isJump := inst.(*JumpInst)
+		if !isJump {
+			continue
+		}
+		target := i + jump.Count
+		if target < 0 || target > len(instructions) {
+			continue
+		}
+		adjusted := *jump
+		adjusted.Count = offsets[target] - offsets[i]
+		fused[offsets[i]] = &adjusted
+	}
+	return fused
+}
+
+// fuseSelectPath returns a SelectPathExpr for the identifier and selects at the
+// start of the instructions along with the number of instructions it replaces.
+func fuseSelectPath(instructions []Instruction,
+	offset int,
+	jumpTargets map[int]bool) (*SelectPathExpr, int) {
+	var ident *IdentExpr
+	var selects []*SelectExpr
+	switch inst := instructions[0].(type) {
+	case *IdentExpr:
+		ident = inst
+	case *SelectExpr:
+		if inst.TestOnly {
+			return nil, 0
+		}
+		selects = append(selects, inst)
+	default:
+		return nil, 0
+	}
+	prevId := instructions[0].GetId()
+	count := 1
+	for ; count < len(instructions) && !jumpTargets[offset+count]; count++ {
+		sel, isSelect := instructions[count].(*SelectExpr)
+		if !isSelect || sel.Operand != prevId || sel.TestOnly {
+			break
+		}
+		selects = append(selects, sel)
+		prevId = sel.GetId()
+	}
+	if count == 1 {
+		return nil, count
+	}
+	return NewSelectPath(ident, selects), count
+}
+
+// qualifiedPath returns the fields of a path which selects from an identifier
+// when the values of the identifier and of the intermediate selections are
+// only read by the path itself, or nil otherwise.
+func qualifiedPath(path *SelectPathExpr, reads map[int64]int) []Qualifier {
+	if path.Ident == nil || reads[path.Ident.GetId()] != 1 {
+		return nil
+	}
+	qualified := make([]Qualifier, len(path.Selects))
+	for i, sel := range path.Selects {
+		if i < len(path.Selects)-1 && reads[sel.GetId()] != 1 {
+			return nil
+		}
+		qualified[i] = Qualifier{Field: sel.Field}
+	}
+	return qualified
+}
+
+// readCounts counts the instructions which read the value of each register.
+func readCounts(instructions []Instruction) map[int64]int {
+	reads := make(map[int64]int)
+	for _, inst := range instructions {
+		switch inst := inst.(type) {
+		case *CallExpr:
+			for _, arg := range inst.Args {
+				reads[arg]++
+			}
+		case *CompareConstExpr:
+			reads[inst.Operand]++
+		case *SelectExpr:
+			reads[inst.Operand]++
+		case *SelectPathExpr:
+			if inst.Ident == nil {
+				reads[inst.Selects[0].Operand]++
+			}
+		case *IndexExpr:
+			reads[inst.Operand]++
+			reads[inst.Index]++
+		case *CreateListExpr:
+			for _, elem := range inst.Elements {
+				reads[elem]++
+			}
+		case *CreateMapExpr:
+			for key, value := range inst.KeyValues {
+				reads[key]++
+				reads[value]++
+			}
+		case *CreateObjectExpr:
+			for _, value := range inst.FieldValues {
+				reads[value]++
+			}
+		case *JumpInst, *MovInst:
+			reads[inst.GetId()]++
+		case *PushScopeInst:
+			for _, id := range inst.Declarations {
+				reads[id]++
+			}
+		}
+	}
+	return reads
+}
+
+// fuseCompareConst returns a CompareConstExpr for a comparison call with one
+// constant argument.
+func fuseCompareConst(inst Instruction,
+	literals map[int64]ref.Value,
+	dispatcher Dispatcher) (*CompareConstExpr, bool) {
+	call, isCall := inst.(*CallExpr)
+	if !isCall || len(call.Args) != 2 || !fusableComparisons[call.Function] {
+		return nil, false
+	}
+	if _, isDefault := dispatcher.(*defaultDispatcher); !isDefault {
+		return nil, false
+	}
+	overload, found := dispatcher.FindOverload(call.Function)
+	if !found || overload.Binary == nil || overload.Contextual != nil {
+		return nil, false
+	}
+	cmp := &CompareConstExpr{
+		baseInstruction: call.baseInstruction,
+		Call:            call,
+		overload:        overload}
+	if value, found := literals[call.Args[1]]; found {
+		cmp.Operand, cmp.Const = call.Args[0], value
+	} else if value, found := literals[call.Args[0]]; found {
+		cmp.Operand, cmp.Const, cmp.ConstFirst = call.Args[1], value, true
+	} else {
+		return nil, false
+	}
+	return cmp, true
+}
@@ -0,0 +1,158 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/google/cel-go/parser"
+)
+
+func TestFuseInstructions_Program(t *testing.T) {
+	parsed, errors := parser.ParseText(`a.b.c == "x" && d > 1`)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+		FuseInstructions())
+	interpreter.NewInterpretable(program)
+	expected := []string{
+		"0: call  select(local 'a'.b.c), r3",
+		"1: call  _==_(r3, x), r5",
+		"2: jump  3 if cond<r5>",
+		"3: local 'd', r6",
+		"4: call  _>_(r6, 1), r8",
+		"5: call  _&&_(r5, r8), r9",
+	}
+	if fmt.Sprint(program) != strings.Join(expected, "\n") {
+		t.Errorf("Got program:\n%v\nwanted:\n%s", program,
+			strings.Join(expected, "\n"))
+	}
+	if inst := program.GetInstruction(1); fmt.Sprint(inst) != "local 'a', r1" {
+		t.Errorf("Got instruction '%v' for id 1, wanted the identifier", inst)
+	}
+}
+
+func TestFuseInstructions_Eval(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"a":     map[string]interface{}{"b": map[string]string{"c": "x"}},
+		"d":     2,
+		"x.y.z": 3,
+		"elems": []int64{1, 2, 3}})
+	for _, text := range []string{
+		`a.b.c == "x" && d > 1`,
+		`"x" != a.b.c || 1 >= d`,
+		`x.y.z == 3`,
+		`a.b.c == "x" ? a.b.size() : d`,
+		`elems.exists(e, e == 2) ? d < 1 : 2 <= d`,
+		`a.b.d == "x"`,
+		`d == "x"`,
+		`missing.field == 1`,
+	} {
+		parsed, errors := parser.ParseText(text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+		fused := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+			FuseInstructions())
+		expected, _ := interpreter.NewInterpretable(program).Eval(activation)
+		actual, _ := interpreter.NewInterpretable(fused).Eval(activation)
+		if fmt.Sprint(actual) != fmt.Sprint(expected) {
+			t.Errorf("%s: got '%v', wanted '%v'", text, actual, expected)
+		}
+	}
+}
+
+func TestFuseInstructions_CustomDispatcher(t *testing.T) {
+	parsed, errors := parser.ParseText(`d == 1`)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+		FuseInstructions())
+	dispatcher := &testDispatcher{NewDispatcher()}
+	dispatcher.Add(functions.StandardOverloads()...)
+	NewInterpreter(dispatcher, packages.DefaultPackage, types.NewProvider()).
+		NewInterpretable(program)
+	for _, inst := range program.(*exprProgram).instructions {
+		if _, isFused := inst.(*CompareConstExpr); isFused {
+			t.Errorf("Got fused comparison '%v' with a custom dispatcher", inst)
+		}
+	}
+}
+
+func TestFuseInstructions_Serialize(t *testing.T) {
+	parsed, errors := parser.ParseText(`a.b.c == "x" && d > 1`)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+		FuseInstructions())
+	interpreter.NewInterpretable(program)
+	serialized, err := MarshalProgram(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := UnmarshalProgram(serialized, FuseInstructions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ := interpreter.NewInterpretable(loaded).Eval(
+		NewActivation(map[string]interface{}{
+			"a": map[string]interface{}{"b": map[string]string{"c": "x"}},
+			"d": 2}))
+	if res != types.True {
+		t.Errorf("Got '%v', wanted 'true'", res)
+	}
+	if fmt.Sprint(loaded) != fmt.Sprint(program) {
+		t.Errorf("Got program:\n%v\nwanted:\n%v", loaded, program)
+	}
+}
+
+func BenchmarkFuseInstructions_Eval(b *testing.B) {
+	parsed, errors := parser.ParseText(`a.b.c == "x" && d > 1`)
+	if len(errors.GetErrors()) != 0 {
+		b.Fatal(errors.ToDisplayString())
+	}
+	activation := NewActivation(map[string]interface{}{
+		"a": map[string]interface{}{"b": map[string]string{"c": "x"}},
+		"d": 2})
+	for _, bench := range []struct {
+		name string
+		opts []ProgramOption
+	}{
+		{"planned", nil},
+		{"fused", []ProgramOption{FuseInstructions()}},
+	} {
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+			bench.opts...)
+		eval := interpreter.NewInterpretable(program)
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				eval.Eval(activation)
+			}
+		})
+	}
+}
+
+type testDispatcher struct {
+	Dispatcher
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// GuardOption configures a Dispatcher created by NewGuardedDispatcher.
+type GuardOption func(*guardedDispatcher)
+
+// CaptureStack includes the stack of the goroutine in the errors of the
+// calls which panic.
+func CaptureStack() GuardOption {
+	return func(d *guardedDispatcher) {
+		d.captureStack = true
+	}
+}
+
+// CallTimeout limits the time taken by each call of the named functions, or
+// of all functions when none are named, to the timeout. A call which exceeds
+// its timeout evaluates to an error, and its overload continues to run in the
+// background until it returns, as Go provides no means to stop it.
+//
+// Each guarded call is made on a goroutine of its own, so the timeout should
+// be limited to the functions which may hang, rather than operators.
+func CallTimeout(timeout time.Duration, functions ...string) GuardOption {
+	return func(d *guardedDispatcher) {
+		if len(functions) == 0 {
+			d.timeout = timeout
+			return
+		}
+		for _, function := range functions {
+			d.timeouts[function] = timeout
+		}
+	}
+}
+
+// NewGuardedDispatcher returns a Dispatcher which dispatches calls to the
+// given Dispatcher, and recovers the panics of the overloads it calls into
+// errors, so that a misbehaving extension function cannot crash the process
+// which evaluates it. Calls may also be limited in time with CallTimeout.
+//
+// Calls through a guarded Dispatcher are not fused, folded, or called directly
+// by tree evaluations, since each must pass through the guard.
+func NewGuardedDispatcher(dispatcher Dispatcher, opts ...GuardOption) Dispatcher {
+	d := &guardedDispatcher{
+		Dispatcher: dispatcher,
+		timeouts:   make(map[string]time.Duration)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+type guardedDispatcher struct {
+	Dispatcher
+	captureStack bool
+	// Timeout of calls of functions which have no timeout of their own, or
+	// zero for none.
+	timeout  time.Duration
+	timeouts map[string]time.Duration
+}
+
+func (d *guardedDispatcher) Dispatch(ctx *CallContext) ref.Value {
+	function, _ := ctx.Function()
+	timeout, found := d.timeouts[function]
+	if !found {
+		timeout = d.timeout
+	}
+	if timeout <= 0 {
+		return d.dispatch(ctx)
+	}
+	results := make(chan ref.Value, 1)
+	go func() {
+		results <- d.dispatch(ctx)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-results:
+		return result
+	case <-timer.C:
+		return types.NewErr("call of function '%s' exceeded the timeout of %v",
+			function, timeout)
+	}
+}
+
+// dispatch calls the underlying Dispatcher, and returns an error when the
+// call panics.
+func (d *guardedDispatcher) dispatch(ctx *CallContext) (result ref.Value) {
+	defer func() {
+		if r := recover(); r != nil {
+			function, _ := ctx.Function()
+			if d.captureStack {
+				result = types.NewErr("panic in function '%s': %v\n%s",
+					function, r, debug.Stack())
+				return
+			}
+			result = types.NewErr("panic in function '%s': %v", function, r)
+		}
+	}()
+	return d.Dispatcher.Dispatch(ctx)
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+)
+
+func TestGuardedDispatcher(t *testing.T) {
+	release := make(chan bool)
+	defer close(release)
+	overloads := dispatcher(&functions.Overload{
+		Operator: "explode",
+		Unary: func(value ref.Value) ref.Value {
+			panic(fmt.Sprintf("exploded on %v", value))
+		}},
+		&functions.Overload{
+			Operator: "hang",
+			Unary: func(value ref.Value) ref.Value {
+				<-release
+				return value
+			}})
+	for _, tst := range []struct {
+		text     string
+		opts     []GuardOption
+		expected string
+	}{
+		{text: `explode(1) || true`, expected: "true"},
+		{text: `explode(1)`, expected: "panic in function 'explode': exploded on 1"},
+		{text: `explode(1)`, opts: []GuardOption{CaptureStack()},
+			expected: "panic in function 'explode': exploded on 1\ngoroutine"},
+		{text: `hang(1) + 1`, opts: []GuardOption{CallTimeout(10 * time.Millisecond)},
+			expected: "call of function 'hang' exceeded the timeout of 10ms"},
+		{text: `hang(1) || 1 + 1 == 2`,
+			opts:     []GuardOption{CallTimeout(time.Millisecond, "hang")},
+			expected: "true"},
+		{text: `1 + 1`, opts: []GuardOption{CallTimeout(time.Second, "hang")},
+			expected: "2"},
+	} {
+		interp := NewInterpreter(NewGuardedDispatcher(overloads, tst.opts...),
+			packages.DefaultPackage, types.NewProvider())
+		for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+			program := parsedProgram(t, tst.text)
+			for _, opt := range opts {
+				opt(program.(*exprProgram))
+			}
+			result, _ := interp.NewInterpretable(program).Eval(NewActivation(map[string]interface{}{}))
+			if !strings.HasPrefix(fmt.Sprint(result), tst.expected) {
+				t.Errorf("%s: got '%v' with %+v, wanted '%s'",
+					tst.text, result, program.Config(), tst.expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"net/http"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// HTTPRequest returns the attributes of an HTTP request as a map, so that
+// the request may be bound to a variable of an Activation:
+//
+//     activation := NewActivation(map[string]interface{}{
+//         "request": HTTPRequest(r)})
+//
+// The map holds the method, scheme, host, path, and raw query of the request
+// as strings, and its headers, including the Host header, as a
+// types.HeaderMap whose functions are declared by
+// checker#HeadersDeclarations.
+func HTTPRequest(r *http.Request) ref.Value {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return types.NewDynamicMap(map[string]ref.Value{
+		"method":  types.String(r.Method),
+		"scheme":  types.String(scheme),
+		"host":    types.String(r.Host),
+		"path":    types.String(r.URL.Path),
+		"query":   types.String(r.URL.RawQuery),
+		"headers": requestHeaders(r)})
+}
+
+// requestHeaders returns the headers of the request, to which the Host
+// header removed by net/http is restored.
+func requestHeaders(r *http.Request) *types.HeaderMap {
+	headers := make(map[string][]string, len(r.Header)+1)
+	for name, values := range r.Header {
+		headers[name] = values
+	}
+	if r.Host != "" {
+		headers["Host"] = []string{r.Host}
+	}
+	return types.NewHeaderMap(headers)
+}
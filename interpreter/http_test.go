@@ -0,0 +1,52 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "https://api.example.com/v1/users?limit=10", nil)
+	r.Header.Add("Accept", "text/html")
+	r.Header.Add("Accept", "application/json")
+	r.Header.Set("X-Request-Id", "abc")
+	activation := NewActivation(map[string]interface{}{"request": HTTPRequest(r)})
+	idents := append(checker.HeadersDeclarations(),
+		decls.NewIdent("request", decls.NewMapType(decls.String, decls.Dyn), nil))
+	for _, text := range []string{
+		`request.method == 'GET' && request.scheme == 'https'`,
+		`request.host == 'api.example.com' && request.path == '/v1/users'`,
+		`request.query == 'limit=10'`,
+		`request.headers.get('host') == 'api.example.com'`,
+		`request.headers['ACCEPT'] == 'text/html,application/json'`,
+		`request.headers.getAll('accept').exists(a, a == 'application/json')`,
+		`request.headers.contains('x-request-id') && has(request.headers.accept)`,
+		`'x-request-id' in request.headers && !('cookie' in request.headers)`,
+		`request.headers.get('Cookie') == '' && request.headers.getAll('cookie').size() == 0`,
+	} {
+		for _, program := range []Program{
+			parsedProgram(t, text), checkedProgram(t, text, idents...)} {
+			if res, _ := interpreter.NewInterpretable(program).Eval(activation); res != types.True {
+				t.Errorf("%s: got '%v' with %+v, wanted true", text, res, program.Config())
+			}
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Variables of the IAM condition context, see checker#IAMDeclarations.
+	iamVariables = map[string]bool{
+		"principal": true,
+		"resource":  true,
+		"request":   true,
+	}
+
+	// Attributes of the IAM condition context by the lower case names of
+	// the global condition keys of AWS IAM policies.
+	iamConditionKeys = map[string]string{
+		"aws:principalarn":     "principal.id",
+		"aws:principalaccount": "principal.account",
+		"aws:principaltype":    "principal.type",
+		"aws:currenttime":      "request.time",
+		"aws:sourceip":         "request.source_ip",
+		"aws:securetransport":  "request.secure",
+	}
+
+	// Tag maps of the IAM condition context by the lower case prefixes of
+	// the tag condition keys of AWS IAM policies.
+	iamTagKeys = map[string]string{
+		"aws:principaltag/": "principal.tags",
+		"aws:resourcetag/":  "resource.tags",
+	}
+)
+
+// IAMActivation returns an Activation which resolves the variables of the
+// condition context of cloud IAM policies, as declared by
+// checker#IAMDeclarations, from their values by qualified name, e.g.
+// 'request.time' or 'resource.tags'. request.time may be supplied as a
+// google.protobuf.Timestamp, and the tags as map[string]string values.
+//
+// As with EnvoyActivation, an attribute which is not supplied evaluates to an
+// error, and the attributes are resolved as the fields of maps bound to the
+// principal, resource, and request variables within unchecked expressions.
+func IAMActivation(attributes map[string]interface{}) Activation {
+	return newAttributeActivation(iamVariables, attributes)
+}
+
+// IAMConditionToCEL converts the Condition block of an AWS IAM policy
+// statement to a CEL expression over the variables of
+// checker#IAMDeclarations, e.g.
+//
+//     {"StringEquals": {"aws:PrincipalTag/team": ["payments"]},
+//      "IpAddress": {"aws:SourceIp": ["10.0.0.0/8", "192.0.2.1"]}}
+//
+// is converted to:
+//
+//     (inIpRange(request.source_ip, "10.0.0.0/8") ||
+//         inIpRange(request.source_ip, "192.0.2.1/32")) &&
+//         principal.tags["team"] == "payments"
+//
+// The condition is keyed by operator and then by condition key, and each key
+// has one or more values. As in AWS, the conditions of a block must all be
+// satisfied, and a condition is satisfied when any of its values matches, or
+// when none of them matches for a negated operator such as StringNotEquals.
+//
+// The supported condition keys are aws:PrincipalArn, aws:PrincipalAccount,
+// aws:PrincipalType, aws:PrincipalTag/<key>, aws:ResourceTag/<key>,
+// aws:CurrentTime, aws:SourceIp, and aws:SecureTransport, along with the
+// qualified names of the variables, e.g. 'resource.service'. The supported
+// operators are the String, Arn, Numeric, Date, Bool, and IpAddress
+// operators and their negations. Operators with an IfExists suffix or a set
+// prefix, and the Null operator, are not supported.
+func IAMConditionToCEL(condition map[string]map[string][]string) (string, error) {
+	var operators []string
+	for operator := range condition {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+	var terms []string
+	for _, operator := range operators {
+		var keys []string
+		for key := range condition[operator] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			term, err := iamConditionTerm(operator, key, condition[operator][key])
+			if err != nil {
+				return "", err
+			}
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return "true", nil
+	}
+	return strings.Join(terms, " && "), nil
+}
+
+// iamConditionTerm converts a single condition of an IAM condition block.
+func iamConditionTerm(operator string, key string, values []string) (string, error) {
+	attr, err := iamAttribute(key)
+	if err != nil {
+		return "", err
+	}
+	if len(values) == 0 {
+		return "", fmt.Errorf("no values for condition key '%s'", key)
+	}
+	switch operator {
+	case "StringEquals":
+		return stringEquals(attr, values), nil
+	case "StringNotEquals":
+		return negate(stringEquals(attr, values)), nil
+	case "StringEqualsIgnoreCase", "StringNotEqualsIgnoreCase":
+		term := matchesAny(attr, "(?i)", values, regexp.QuoteMeta)
+		if operator == "StringNotEqualsIgnoreCase" {
+			return negate(term), nil
+		}
+		return term, nil
+	case "StringLike", "ArnLike", "ArnEquals":
+		return matchesAny(attr, "", values, wildcardPattern), nil
+	case "StringNotLike", "ArnNotLike", "ArnNotEquals":
+		return negate(matchesAny(attr, "", values, wildcardPattern)), nil
+	case "NumericEquals", "NumericNotEquals", "NumericLessThan",
+		"NumericLessThanEquals", "NumericGreaterThan", "NumericGreaterThanEquals":
+		return compareAny(operator, "double("+attr+")", values,
+			func(value string) (string, error) {
+				d, err := strconv.ParseFloat(value, 64)
+				if err != nil || math.IsInf(d, 0) || math.IsNaN(d) {
+					return "", fmt.Errorf("invalid number '%s'", value)
+				}
+				literal := strconv.FormatFloat(d, 'g', -1, 64)
+				if !strings.ContainsAny(literal, ".e") {
+					literal += ".0"
+				}
+				return literal, nil
+			})
+	case "DateEquals", "DateNotEquals", "DateLessThan",
+		"DateLessThanEquals", "DateGreaterThan", "DateGreaterThanEquals":
+		return compareAny(operator, attr, values, func(value string) (string, error) {
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				return "", fmt.Errorf("invalid date '%s'", value)
+			}
+			return "timestamp(" + strconv.Quote(value) + ")", nil
+		})
+	case "Bool":
+		b, err := strconv.ParseBool(values[0])
+		if err != nil || len(values) != 1 {
+			return "", fmt.Errorf("invalid Bool condition values %v", values)
+		}
+		return fmt.Sprintf("%s == %t", attr, b), nil
+	case "IpAddress", "NotIpAddress":
+		ranges := make([]string, len(values))
+		for i, value := range values {
+			if !strings.Contains(value, "/") {
+				if strings.Contains(value, ":") {
+					value += "/128"
+				} else {
+					value += "/32"
+				}
+			}
+			ranges[i] = fmt.Sprintf("inIpRange(%s, %s)", attr, strconv.Quote(value))
+		}
+		term := disjunction(ranges)
+		if operator == "NotIpAddress" {
+			return negate(term), nil
+		}
+		return term, nil
+	}
+	return "", fmt.Errorf("unsupported IAM condition operator '%s'", operator)
+}
+
+// iamAttribute returns the attribute of the IAM condition context which holds
+// the value of a condition key.
+func iamAttribute(key string) (string, error) {
+	lower := strings.ToLower(key)
+	if attr, found := iamConditionKeys[lower]; found {
+		return attr, nil
+	}
+	for prefix, tags := range iamTagKeys {
+		if strings.HasPrefix(lower, prefix) && len(key) > len(prefix) {
+			return fmt.Sprintf("%s[%s]", tags, strconv.Quote(key[len(prefix):])), nil
+		}
+	}
+	switch key {
+	case "principal.id", "principal.account", "principal.type",
+		"resource.name", "resource.type", "resource.service",
+		"request.action", "request.time", "request.source_ip", "request.secure":
+		return key, nil
+	}
+	return "", fmt.Errorf("unsupported IAM condition key '%s'", key)
+}
+
+// stringEquals returns a term which tests whether the attribute equals any of
+// the values.
+func stringEquals(attr string, values []string) string {
+	if len(values) == 1 {
+		return attr + " == " + strconv.Quote(values[0])
+	}
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(value)
+	}
+	return fmt.Sprintf("%s in [%s]", attr, strings.Join(quoted, ", "))
+}
+
+// matchesAny returns a term which tests whether the attribute matches any of
+// the patterns, whose regular expressions are returned by the function.
+func matchesAny(attr string, flags string, patterns []string,
+	re func(string) string) string {
+	alternatives := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		alternatives[i] = re(pattern)
+	}
+	pattern := fmt.Sprintf("%s^(?:%s)$", flags, strings.Join(alternatives, "|"))
+	return fmt.Sprintf("%s.matches(%s)", attr, strconv.Quote(pattern))
+}
+
+// wildcardPattern returns the regular expression of an IAM pattern, in which
+// '*' matches any sequence of characters and '?' any single character.
+func wildcardPattern(pattern string) string {
+	var re strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			re.WriteString("(?s:.*)")
+		case '?':
+			re.WriteString("(?s:.)")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return re.String()
+}
+
+// compareAny returns a term which compares the attribute to any of the values,
+// converted to CEL literals by the function, with the relational operator of a
+// Numeric or Date IAM condition operator.
+func compareAny(operator string, attr string, values []string,
+	literal func(string) (string, error)) (string, error) {
+	var relation string
+	negated := false
+	switch {
+	case strings.HasSuffix(operator, "NotEquals"):
+		relation, negated = "==", true
+	case strings.HasSuffix(operator, "LessThanEquals"):
+		relation = "<="
+	case strings.HasSuffix(operator, "LessThan"):
+		relation = "<"
+	case strings.HasSuffix(operator, "GreaterThanEquals"):
+		relation = ">="
+	case strings.HasSuffix(operator, "GreaterThan"):
+		relation = ">"
+	default:
+		relation = "=="
+	}
+	comparisons := make([]string, len(values))
+	for i, value := range values {
+		lit, err := literal(value)
+		if err != nil {
+			return "", err
+		}
+		comparisons[i] = fmt.Sprintf("%s %s %s", attr, relation, lit)
+	}
+	term := disjunction(comparisons)
+	if negated {
+		return negate(term), nil
+	}
+	return term, nil
+}
+
+// disjunction returns a term which is satisfied by any of the terms.
+func disjunction(terms []string) string {
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return "(" + strings.Join(terms, " || ") + ")"
+}
+
+// negate returns the negation of a term.
+func negate(term string) string {
+	if strings.HasPrefix(term, "(") {
+		return "!" + term
+	}
+	return "!(" + term + ")"
+}
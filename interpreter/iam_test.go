@@ -0,0 +1,129 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+)
+
+func TestIAMConditionToCEL(t *testing.T) {
+	interp := NewInterpreter(dispatcher(functions.IAMOverloads()...),
+		packages.DefaultPackage, types.NewProvider())
+	activation := IAMActivation(map[string]interface{}{
+		"principal.id":      "arn:aws:iam::123456789012:user/alice",
+		"principal.account": "123456789012",
+		"principal.tags":    map[string]string{"team": "payments"},
+		"resource.name":     "arn:aws:s3:::invoices/2018/01.pdf",
+		"resource.tags":     map[string]string{"team": "Payments"},
+		"request.action":    "s3:GetObject",
+		"request.time":      ptypes.TimestampNow(),
+		"request.source_ip": "10.1.2.3",
+		"request.secure":    true,
+	})
+	for _, tst := range []struct {
+		condition map[string]map[string][]string
+		text      string
+		expected  ref.Value
+	}{
+		{condition: map[string]map[string][]string{
+			"StringEquals": {"aws:PrincipalTag/team": {"payments"}}},
+			text:     `principal.tags["team"] == "payments"`,
+			expected: types.True},
+		{condition: map[string]map[string][]string{
+			"StringEquals": {"aws:principalaccount": {"111111111111", "123456789012"}}},
+			text:     `principal.account in ["111111111111", "123456789012"]`,
+			expected: types.True},
+		{condition: map[string]map[string][]string{
+			"StringNotEquals": {"request.action": {"s3:PutObject"}}},
+			text:     `!(request.action == "s3:PutObject")`,
+			expected: types.True},
+		{condition: map[string]map[string][]string{
+			"StringEqualsIgnoreCase": {"aws:ResourceTag/team": {"payments"}}},
+			text:     `resource.tags["team"].matches("(?i)^(?:payments)$")`,
+			expected: types.True},
+		{condition: map[string]map[string][]string{
+			"StringLike": {"resource.name": {"arn:aws:s3:::invoices/*"}}},
+			expected: types.True},
+		{condition: map[string]map[string][]string{
+			"ArnNotLike": {"aws:PrincipalArn": {"arn:aws:iam::*:role/?dmin"}}},
+			expected: types.True},
+		{condition: map[string]map[string][]string{
+			"DateGreaterThan": {"aws:CurrentTime": {"2018-01-01T00:00:00Z"}},
+			"DateLessThan":    {"aws:CurrentTime": {"2000-01-01T00:00:00Z"}}},
+			text: `request.time > timestamp("2018-01-01T00:00:00Z") && ` +
+				`request.time < timestamp("2000-01-01T00:00:00Z")`,
+			expected: types.False},
+		{condition: map[string]map[string][]string{
+			"NumericLessThan": {"aws:PrincipalAccount": {"2e11"}}},
+			text:     `double(principal.account) < 2e+11`,
+			expected: types.True},
+		{condition: map[string]map[string][]string{
+			"Bool":      {"aws:SecureTransport": {"true"}},
+			"IpAddress": {"aws:SourceIp": {"192.0.2.1", "10.0.0.0/8"}}},
+			text: `request.secure == true && (inIpRange(request.source_ip, "192.0.2.1/32") || ` +
+				`inIpRange(request.source_ip, "10.0.0.0/8"))`,
+			expected: types.True},
+		{condition: map[string]map[string][]string{
+			"NotIpAddress": {"aws:SourceIp": {"10.0.0.0/8"}}},
+			expected: types.False},
+		{condition: map[string]map[string][]string{}, text: "true",
+			expected: types.True},
+		// Unsupported operators and keys.
+		{condition: map[string]map[string][]string{
+			"StringEqualsIfExists": {"aws:PrincipalType": {"User"}}}},
+		{condition: map[string]map[string][]string{
+			"StringEquals": {"aws:username": {"alice"}}}},
+		{condition: map[string]map[string][]string{
+			"DateLessThan": {"aws:CurrentTime": {"yesterday"}}}},
+	} {
+		text, err := IAMConditionToCEL(tst.condition)
+		if tst.expected == nil {
+			if err == nil {
+				t.Errorf("%v: got '%s', wanted error", tst.condition, text)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tst.condition, err)
+			continue
+		}
+		if tst.text != "" && text != tst.text {
+			t.Errorf("%v: got '%s', wanted '%s'", tst.condition, text, tst.text)
+		}
+		for _, program := range []Program{
+			parsedProgram(t, text),
+			checkedProgram(t, text, checker.IAMDeclarations()...)} {
+			res, _ := interp.NewInterpretable(program).Eval(activation)
+			if res != tst.expected {
+				t.Errorf("%s: got '%v' with %+v, wanted '%v'",
+					text, res, program.Config(), tst.expected)
+			}
+		}
+	}
+
+	// Attributes which are not supplied are errors rather than unknowns.
+	res, _ := interp.NewInterpretable(parsedProgram(t, "principal.type == 'User'")).
+		Eval(activation)
+	if !types.IsError(res) {
+		t.Errorf("Got '%v' for an attribute which was not supplied, wanted error", res)
+	}
+}
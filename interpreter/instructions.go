@@ -17,7 +17,10 @@ package interpreter
 import (
 	"fmt"
 	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
 	"strings"
 )
 
@@ -92,26 +95,148 @@ func NewCallOverload(exprId int64, function string, argIds []int64, overload str
 }
 
 func checkIsStrict(function string) bool {
-	if function != operators.LogicalAnd && function != operators.LogicalOr && function != operators.Conditional {
+	if function != operators.LogicalAnd && function != operators.LogicalOr &&
+		function != operators.Conditional && function != overloads.Coalesce {
 		return true
 	}
 	return false
 }
 
-// SelectExpr is a select expression where the operand is represented by id.
+// IndexExpr is a specialization of the '_[_]' call for list and map operands
+// whose element type is known to be bool, int, or string at check time.
+type IndexExpr struct {
+	*baseInstruction
+	Operand  int64
+	Index    int64
+	ElemType ref.Type
+}
+
+func (e *IndexExpr) String() string {
+	return fmt.Sprintf("call  index(r%d, r%d) %s, r%d",
+		e.Operand, e.Index, e.ElemType.TypeName(), e.GetId())
+}
+
+// NewIndex creates an IndexExpr of the operand and index whose elements are
+// known to be of the element type.
+func NewIndex(exprId int64, operandId int64, indexId int64,
+	elemType ref.Type) *IndexExpr {
+	return &IndexExpr{&baseInstruction{exprId}, operandId, indexId, elemType}
+}
+
+// SelectExpr is a field selection from an operand, or a presence test for the
+// field when TestOnly is set, e.g. 'has(a.b)'.
 type SelectExpr struct {
 	*baseInstruction
-	Operand int64
-	Field   string
+	Operand  int64
+	Field    string
+	TestOnly bool
 }
 
 func (e *SelectExpr) String() string {
+	if e.TestOnly {
+		return fmt.Sprintf("call  has(%d, '%s'), r%d",
+			e.Operand, e.Field, e.GetId())
+	}
 	return fmt.Sprintf("call  select(%d, '%s'), r%d",
 		e.Operand, e.Field, e.GetId())
 }
 
 func NewSelect(exprId int64, operandId int64, field string) *SelectExpr {
-	return &SelectExpr{&baseInstruction{exprId}, operandId, field}
+	return &SelectExpr{&baseInstruction{exprId}, operandId, field, false}
+}
+
+// NewTestOnlySelect creates a SelectExpr which tests for the presence of the
+// field rather than selecting its value.
+func NewTestOnlySelect(exprId int64, operandId int64, field string) *SelectExpr {
+	return &SelectExpr{&baseInstruction{exprId}, operandId, field, true}
+}
+
+// SelectPathExpr is a superinstruction which evaluates an optional identifier
+// followed by a chain of field selections, each of which selects from the
+// result of the one before it.
+type SelectPathExpr struct {
+	*baseInstruction
+	Ident   *IdentExpr
+	Selects []*SelectExpr
+
+	// qualified holds the fields of the selections when the path may be
+	// resolved as a whole by a QualifiedResolver, as the values of the
+	// identifier and of the intermediate selections are not used elsewhere.
+	qualified []Qualifier
+}
+
+func (e *SelectPathExpr) String() string {
+	path := make([]string, len(e.Selects), len(e.Selects))
+	for i, sel := range e.Selects {
+		path[i] = sel.Field
+	}
+	operand := fmt.Sprintf("r%d", e.Selects[0].Operand)
+	if e.Ident != nil {
+		operand = fmt.Sprintf("local '%s'", e.Ident.Name)
+	}
+	return fmt.Sprintf("call  select(%s.%s), r%d",
+		operand, strings.Join(path, "."), e.GetId())
+}
+
+func (e *SelectPathExpr) components() []Instruction {
+	var insts []Instruction
+	if e.Ident != nil {
+		insts = append(insts, e.Ident)
+	}
+	for _, sel := range e.Selects {
+		insts = append(insts, sel)
+	}
+	return insts
+}
+
+// NewSelectPath creates a SelectPathExpr whose result is written to the id of
+// the last select in the chain.
+func NewSelectPath(ident *IdentExpr, selects []*SelectExpr) *SelectPathExpr {
+	return &SelectPathExpr{
+		baseInstruction: &baseInstruction{selects[len(selects)-1].GetId()},
+		Ident:           ident,
+		Selects:         selects}
+}
+
+// CompareConstExpr is a superinstruction for a comparison call in which one
+// of the arguments is a constant. The overload is bound when the instruction
+// is created, so the call does not need to be resolved on each evaluation.
+type CompareConstExpr struct {
+	*baseInstruction
+	Call       *CallExpr
+	Operand    int64
+	Const      ref.Value
+	ConstFirst bool
+
+	overload *functions.Overload
+}
+
+func (e *CompareConstExpr) String() string {
+	args := []string{fmt.Sprintf("r%d", e.Operand), fmt.Sprintf("%v", e.Const)}
+	if e.ConstFirst {
+		args[0], args[1] = args[1], args[0]
+	}
+	return fmt.Sprintf("call  %s(%s), r%d",
+		e.Call.Function, strings.Join(args, ", "), e.GetId())
+}
+
+// compare invokes the bound overload with the operands of the comparison.
+func (e *CompareConstExpr) compare(lhs, rhs ref.Value) ref.Value {
+	if !lhs.Type().HasTrait(e.overload.OperandTrait) {
+		return types.NewNoSuchOverloadErr()
+	}
+	return e.overload.Binary(lhs, rhs)
+}
+
+func (e *CompareConstExpr) components() []Instruction {
+	return []Instruction{e.Call}
+}
+
+// fusedInstruction is implemented by superinstructions in order to expose the
+// instructions they replaced.
+type fusedInstruction interface {
+	Instruction
+	components() []Instruction
 }
 
 // CrateListExpr will create a new list from the elements referened by their ids.
@@ -165,6 +290,9 @@ type JumpInst struct {
 	*baseInstruction
 	Count       int
 	OnCondition func(EvalState) bool
+
+	// Description of the condition when generated by the planner.
+	condition jumpCondition
 }
 
 func (e *JumpInst) String() string {
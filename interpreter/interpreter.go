@@ -18,11 +18,15 @@
 package interpreter
 
 import (
+	"context"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
 	"github.com/google/cel-go/common/packages"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/common/types/traits"
 	"github.com/google/cel-go/interpreter/functions"
+	"sort"
 )
 
 // Interpreter generates a new Interpretable from a Program.
@@ -36,9 +40,32 @@ type Interpreter interface {
 // data might be necessary to complete the evaluation.
 type Interpretable interface {
 	// Eval an Activation to produce an output and EvalState.
+	//
+	// The Interpretables created by NewInterpretable allocate the state of
+	// each evaluation when Eval is called, so Eval is safe for concurrent use.
 	Eval(activation Activation) (ref.Value, EvalState)
 }
 
+// ReusableInterpretable is an Interpretable which may evaluate into an
+// EvalState held by the caller, so that a caller which evaluates many times,
+// e.g. from a pool of evaluators, may reuse the state rather than allocate it
+// for each evaluation.
+//
+// The Interpretables created by NewInterpretable for programs without
+// middleware are ReusableInterpretables.
+type ReusableInterpretable interface {
+	Interpretable
+
+	// NewState returns an EvalState for use with EvalWithState.
+	NewState() EvalState
+
+	// EvalWithState evaluates the Activation into a state returned by
+	// NewState, which is first reset to the state of the initialized program.
+	// The state must not be used by concurrent evaluations, and its values are
+	// replaced by its next evaluation.
+	EvalWithState(activation Activation, state EvalState) (ref.Value, EvalState)
+}
+
 type exprInterpreter struct {
 	dispatcher   Dispatcher
 	packager     packages.Packager
@@ -67,35 +94,196 @@ func NewStandardIntepreter(packager packages.Packager,
 }
 
 func (i *exprInterpreter) NewInterpretable(program Program) Interpretable {
+	interpretable := i.newInterpretable(program)
+	p, isExprProgram := program.(*exprProgram)
+	if !isExprProgram {
+		return interpretable
+	}
+	middleware := p.middleware
+	if p.strictVariables != nil {
+		// The activation is validated before it is seen by any middleware.
+		middleware = append([]Middleware{
+			strictActivationMiddleware(p.strictVariables, p.requireDeclared)},
+			middleware...)
+	}
+	if len(p.outputs) > 0 {
+		// The results are transformed within the middleware, so that the
+		// middleware observe the transformed results.
+		middleware = append(middleware[:len(middleware):len(middleware)],
+			outputMiddleware(p.outputs))
+	}
+	if len(middleware) > 0 {
+		return newMiddlewareInterpretable(interpretable, middleware)
+	}
+	return interpretable
+}
+
+func (i *exprInterpreter) newInterpretable(program Program) Interpretable {
 	// program needs to be pruned with the TypeProvider
 	evalState := NewEvalState(program.MaxInstructionId() + 1)
+	evalState.metadata = program.Metadata()
 	program.Init(i.dispatcher, evalState)
-	return &exprInterpretable{
+	p, isExprProgram := program.(*exprProgram)
+	if isExprProgram {
+		evalState.attributes = newAttributeTracker(p)
+	}
+	if isExprProgram && p.tree {
+		return newTreeInterpretable(i, p, evalState)
+	}
+	interpretable := &exprInterpretable{
 		interpreter: i,
 		program:     program,
-		state:       evalState}
+		initial:     evalState}
+	if isExprProgram {
+		interpretable.sampler = newTraceSampler(p)
+		interpretable.cancelInterval = p.cancelInterval
+		interpretable.costLimit = p.costLimit
+		interpretable.divisionDefault = p.divisionDefault
+		interpretable.dynDispatch = p.dynDispatch
+		interpretable.iterationLimit = p.iterationLimit
+		interpretable.maxErrors = p.maxErrors
+		interpretable.observer = p.observer
+		interpretable.propagateNull = p.propagateNull
+		interpretable.redactErrors = p.redactErrors
+	}
+	return interpretable
 }
 
 type exprInterpretable struct {
-	interpreter *exprInterpreter
-	program     Program
-	state       MutableEvalState
+	cancelInterval  uint
+	costLimit       uint64
+	divisionDefault *divisionDefaults
+	dynDispatch     OverloadMismatch
+	// initial is the state of the initialized program, which is copied for
+	// each evaluation.
+	initial        *defaultEvalState
+	interpreter    *exprInterpreter
+	iterationLimit uint64
+	maxErrors      int
+	observer       EvalObserver
+	program        Program
+	propagateNull  bool
+	redactErrors   bool
+	sampler        *traceSampler
+	// state is the state of the current evaluation, which is only set on the
+	// copy of the interpretable made for the evaluation.
+	state *defaultEvalState
 }
 
 func (i *exprInterpretable) Eval(activation Activation) (ref.Value, EvalState) {
+	return i.evalWith(activation, i.initial.clone())
+}
+
+func (i *exprInterpretable) NewState() EvalState {
+	return i.initial.clone()
+}
+
+func (i *exprInterpretable) EvalWithState(activation Activation,
+	state EvalState) (ref.Value, EvalState) {
+	s := state.(*defaultEvalState)
+	s.reset(i.initial)
+	return i.evalWith(activation, s)
+}
+
+// evalWith evaluates the activation into a state copied from the initial state.
+func (i *exprInterpretable) evalWith(activation Activation,
+	state *defaultEvalState) (ref.Value, EvalState) {
+	// The interpretable is copied so that concurrent evaluations do not share
+	// their state.
+	eval := *i
+	eval.state = state
+	eval.state.trace = i.sampler.sample()
+	return eval.eval(activation)
+}
+
+func (i *exprInterpretable) eval(activation Activation) (ref.Value, EvalState) {
+	result, _ := i.run(i.begin(activation), 0)
+	return result, i.state
+}
+
+// evaluation is the progress of an evaluation by the stepper, which may be
+// suspended between instructions and resumed, see EvalChunked.
+type evaluation struct {
+	activation Activation
+	ctx        context.Context
+	stepper    InstructionStepper
+	resultId   int64
+	cost       uint64
+	steps      uint
+	iterations uint64
+}
+
+// begin starts an evaluation of the program with the given activation.
+func (i *exprInterpretable) begin(activation Activation) *evaluation {
+	e := &evaluation{activation: activation}
+	if i.cancelInterval != 0 {
+		e.ctx = activationContext(activation)
+	}
+	return e
+}
+
+// run evaluates at most maxSteps instructions of the evaluation, or all of
+// its remaining instructions when maxSteps is zero, and returns the result and
+// true once the evaluation is complete.
+func (i *exprInterpretable) run(e *evaluation, maxSteps uint) (ref.Value, bool) {
 	// register machine-like evaluation of the program with the given activation.
-	currActivation := activation
-	stepper := i.program.Begin()
-	var resultId int64
-	for step, hasNext := stepper.Next(); hasNext; step, hasNext = stepper.Next() {
-		resultId = step.GetId()
+	if e.ctx != nil && done(e.ctx) {
+		i.state.cost = e.cost
+		return evalCancelled(e.ctx), true
+	}
+	if e.stepper == nil {
+		e.stepper = i.program.Begin()
+	}
+	stepper := e.stepper
+	for chunk := uint(0); maxSteps == 0 || chunk < maxSteps; chunk++ {
+		step, hasNext := stepper.Next()
+		if !hasNext {
+			i.state.cost = e.cost
+			result, _ := i.state.Value(e.resultId)
+			if result == nil {
+				result, _ = i.state.OnlyValue()
+			}
+			return result, true
+		}
+		e.resultId = step.GetId()
+		e.cost += instructionCost(step)
+		if call, isCall := step.(*CallExpr); isCall && sizedCall(call.Function) {
+			for _, argId := range call.Args {
+				e.cost = addCost(e.cost, sizeCost(i.value(argId)))
+			}
+		}
+		if i.costLimit != 0 && e.cost > i.costLimit {
+			i.state.cost = e.cost
+			return costLimitExceeded(i.costLimit), true
+		}
+		if e.steps++; e.ctx != nil && e.steps%i.cancelInterval == 0 && done(e.ctx) {
+			i.state.cost = e.cost
+			return evalCancelled(e.ctx), true
+		}
 		switch step.(type) {
+		case *ConstExpr:
+			i.evalConst(step.(*ConstExpr))
 		case *IdentExpr:
-			i.evalIdent(step.(*IdentExpr), currActivation)
+			i.evalIdent(step.(*IdentExpr), e.activation)
 		case *SelectExpr:
-			i.evalSelect(step.(*SelectExpr), currActivation)
+			i.evalSelect(step.(*SelectExpr), e.activation)
+		case *SelectPathExpr:
+			i.evalSelectPath(step.(*SelectPathExpr), e.activation)
 		case *CallExpr:
-			i.evalCall(step.(*CallExpr), currActivation)
+			call := step.(*CallExpr)
+			// Each element visited by a comprehension is fetched by a call
+			// of the iterator's next function.
+			if i.iterationLimit != 0 && call.Function == overloads.Next {
+				if e.iterations++; e.iterations > i.iterationLimit {
+					i.state.cost = e.cost
+					return iterationLimitExceeded(i.iterationLimit), true
+				}
+			}
+			i.evalCall(call, e.activation)
+		case *CompareConstExpr:
+			i.evalCompareConst(step.(*CompareConstExpr))
+		case *IndexExpr:
+			i.evalIndex(step.(*IndexExpr))
 		case *CreateListExpr:
 			i.evalCreateList(step.(*CreateListExpr))
 		case *CreateMapExpr:
@@ -124,16 +312,27 @@ func (i *exprInterpretable) Eval(activation Activation) (ref.Value, EvalState) {
 					return i.value(declId)
 				}
 			}
-			currActivation = NewHierarchicalActivation(currActivation, NewActivation(childActivaton))
+			e.activation = NewHierarchicalActivation(e.activation, NewActivation(childActivaton))
 		case *PopScopeInst:
-			currActivation = currActivation.Parent()
+			e.activation = e.activation.Parent()
+		}
+		if i.observer != nil {
+			i.observe(step)
 		}
 	}
-	result := i.value(resultId)
-	if result == nil {
-		result, _ = i.state.OnlyValue()
+	i.state.cost = e.cost
+	return nil, false
+}
+
+// observe notifies the observer of the value produced by an instruction.
+func (i *exprInterpretable) observe(step Instruction) {
+	var value ref.Value
+	switch step.(type) {
+	case *JumpInst, *PushScopeInst, *PopScopeInst:
+	default:
+		value, _ = i.state.Value(step.GetId())
 	}
-	return result, i.state
+	i.observer.Observe(step.GetId(), instructionKind(step), value)
 }
 
 func (i *exprInterpretable) evalConst(constExpr *ConstExpr) {
@@ -147,17 +346,28 @@ func (i *exprInterpretable) evalIdent(idExpr *IdentExpr, currActivation Activati
 	} else if idVal, found := i.interpreter.typeProvider.FindIdent(idExpr.Name); found {
 		i.setValue(idExpr.GetId(), idVal)
 	} else {
-		i.setValue(idExpr.GetId(), types.Unknown{idExpr.Id})
+		i.setValue(idExpr.GetId(), types.NewUnknownAttribute(idExpr.Id, idExpr.Name))
 	}
 }
 
 func (i *exprInterpretable) evalSelect(selExpr *SelectExpr, currActivation Activation) {
 	operand := i.value(selExpr.Operand)
+	if selExpr.TestOnly {
+		i.evalTestOnly(selExpr, operand)
+		return
+	}
 	if !operand.Type().HasTrait(traits.IndexerType) {
-		if types.IsUnknown(operand) {
-			i.resolveUnknown(operand.(types.Unknown), selExpr, currActivation)
+		if types.IsError(operand) {
+			// Propagate the error so that its origin, such as a missing field
+			// earlier in a chain of selections, is preserved.
+			i.setValue(selExpr.GetId(), operand)
+		} else if types.IsUnknown(operand) {
+			i.resolveUnknown(types.MergeUnknowns(operand).(*types.UnknownSet),
+				selExpr, currActivation)
+		} else if i.propagateNull && operand.Type() == types.NullType {
+			i.setValue(selExpr.GetId(), types.NullValue)
 		} else {
-			i.setValue(selExpr.Operand, types.NewErr("invalid operand in select"))
+			i.setValue(selExpr.GetId(), types.NewErr("invalid operand in select"))
 		}
 		return
 	}
@@ -165,11 +375,45 @@ func (i *exprInterpretable) evalSelect(selExpr *SelectExpr, currActivation Activ
 	i.setValue(selExpr.GetId(), fieldValue)
 }
 
+// evalTestOnly tests for the presence of a field within a message or of a key
+// within a map, e.g. 'has(a.b)'.
+func (i *exprInterpretable) evalTestOnly(selExpr *SelectExpr, operand ref.Value) {
+	field := types.String(selExpr.Field)
+	switch {
+	case types.IsError(operand) || types.IsUnknown(operand):
+		i.setValue(selExpr.GetId(), operand)
+	case operand.Type().HasTrait(traits.FieldTesterType):
+		i.setValue(selExpr.GetId(), operand.(traits.FieldTester).IsSet(field))
+	case operand.Type() == types.MapType:
+		i.setValue(selExpr.GetId(), operand.(traits.Container).Contains(field))
+	case i.propagateNull && operand.Type() == types.NullType:
+		i.setValue(selExpr.GetId(), types.False)
+	default:
+		i.setValue(selExpr.GetId(), types.NewErr("invalid operand in presence test"))
+	}
+}
+
+func (i *exprInterpretable) evalSelectPath(pathExpr *SelectPathExpr, currActivation Activation) {
+	if pathExpr.qualified != nil {
+		if value, found := resolveQualified(currActivation,
+			pathExpr.Ident.Name, pathExpr.qualified); found {
+			i.setValue(pathExpr.GetId(), value)
+			return
+		}
+	}
+	if pathExpr.Ident != nil {
+		i.evalIdent(pathExpr.Ident, currActivation)
+	}
+	for _, selExpr := range pathExpr.Selects {
+		i.evalSelect(selExpr, currActivation)
+	}
+}
+
 // resolveUnknown attempts to resolve a qualified name from a select expression
 // which may have generated unknown values during the course of execution if
 // the expression was not type-checked and the select, in fact, refers to a
 // qualified identifier name instead of a series of field selections.
-func (i *exprInterpretable) resolveUnknown(unknown types.Unknown,
+func (i *exprInterpretable) resolveUnknown(unknown *types.UnknownSet,
 	selExpr *SelectExpr,
 	currActivation Activation) {
 	if object, found := currActivation.ResolveReference(selExpr.Id); found {
@@ -178,7 +422,7 @@ func (i *exprInterpretable) resolveUnknown(unknown types.Unknown,
 	}
 	validIdent := true
 	identifier := selExpr.Field
-	for _, arg := range unknown {
+	for _, arg := range unknown.Ids() {
 		inst := i.program.GetInstruction(arg)
 		switch inst.(type) {
 		case *IdentExpr:
@@ -210,36 +454,155 @@ func (i *exprInterpretable) resolveUnknown(unknown types.Unknown,
 			return
 		}
 	}
-	i.setValue(selExpr.Id, append(types.Unknown{selExpr.Id}, unknown...))
+	i.setValue(selExpr.Id, unknown.Select(selExpr.Id, selExpr.Field))
 }
 
 func (i *exprInterpretable) evalCall(callExpr *CallExpr, currActivation Activation) {
 	argVals := make([]ref.Value, len(callExpr.Args), len(callExpr.Args))
+	var invalid ref.Value
 	for idx, argId := range callExpr.Args {
 		argVals[idx] = i.value(argId)
-		if callExpr.Strict && (types.IsError(argVals[idx]) || types.IsUnknown(argVals[idx])) {
-			i.setValue(callExpr.GetId(), argVals[idx])
-			return
+		if callExpr.Strict {
+			invalid = mergeInvalid(invalid, argVals[idx])
 		}
 	}
+	if invalid != nil {
+		i.setValue(callExpr.GetId(), invalid)
+		return
+	}
 	ctx := &CallContext{
 		call:       callExpr,
 		activation: currActivation,
 		args:       argVals,
 		metadata:   i.program.Metadata()}
 	result := i.interpreter.dispatcher.Dispatch(ctx)
+	if types.IsError(result) {
+		if value, found := i.divisionDefault.of(callExpr.Function, argVals); found {
+			result = value
+		}
+	}
+	if i.dynDispatch != ErrorOnMismatch && types.IsError(result) {
+		result = resolveMismatch(i.dynDispatch, result, argVals,
+			func(args []ref.Value) ref.Value {
+				ctx.args = args
+				return i.interpreter.dispatcher.Dispatch(ctx)
+			})
+	}
 	i.setValue(callExpr.GetId(), result)
 }
 
+// resolveMismatch applies the DynDispatch behavior of a program to the result
+// of a call whose operands may match none of the overloads of its function.
+// The call is made again with the coerced operands when strings are coerced.
+func resolveMismatch(onMismatch OverloadMismatch, result ref.Value,
+	args []ref.Value, call func(args []ref.Value) ref.Value) ref.Value {
+	if !types.IsNoSuchOverload(result) {
+		return result
+	}
+	// Errors which were propagated from the operands of non-strict calls,
+	// such as the logical operators, are not mismatches of the call itself.
+	if _, located := result.(*types.Err).ExprId(); located {
+		return result
+	}
+	switch onMismatch {
+	case NullOnMismatch:
+		return types.NullValue
+	case CoerceStringsOnMismatch:
+		coerced := make([]ref.Value, len(args))
+		hasString := false
+		for idx, arg := range args {
+			if arg.Type() == types.StringType {
+				hasString = true
+			}
+			coerced[idx] = arg.ConvertToType(types.StringType)
+			if coerced[idx].Type() != types.StringType {
+				return result
+			}
+		}
+		if !hasString {
+			return result
+		}
+		if coercedResult := call(coerced); !types.IsError(coercedResult) {
+			return coercedResult
+		}
+	}
+	return result
+}
+
+// divisionDefaults are the values of int and uint division by zero.
+type divisionDefaults struct {
+	intValue  types.Int
+	uintValue types.Uint
+}
+
+// of returns the default value of the call when it is an int or uint division
+// or modulus with a zero divisor, and the defaults are configured.
+func (d *divisionDefaults) of(function string, args []ref.Value) (ref.Value, bool) {
+	if d == nil || (function != operators.Divide && function != operators.Modulo) ||
+		len(args) != 2 {
+		return nil, false
+	}
+	switch divisor := args[1].(type) {
+	case types.Int:
+		return d.intValue, divisor == types.IntZero
+	case types.Uint:
+		return d.uintValue, divisor == 0
+	}
+	return nil, false
+}
+
+func (i *exprInterpretable) evalCompareConst(cmpExpr *CompareConstExpr) {
+	operand := i.value(cmpExpr.Operand)
+	if types.IsUnknownOrError(operand) {
+		i.setValue(cmpExpr.GetId(), operand)
+		return
+	}
+	lhs, rhs := operand, cmpExpr.Const
+	if cmpExpr.ConstFirst {
+		lhs, rhs = rhs, lhs
+	}
+	result := cmpExpr.compare(lhs, rhs)
+	if i.dynDispatch != ErrorOnMismatch && types.IsError(result) {
+		result = resolveMismatch(i.dynDispatch, result, []ref.Value{lhs, rhs},
+			func(args []ref.Value) ref.Value {
+				return cmpExpr.compare(args[0], args[1])
+			})
+	}
+	i.setValue(cmpExpr.GetId(), result)
+}
+
+func (i *exprInterpretable) evalIndex(idxExpr *IndexExpr) {
+	operand := i.value(idxExpr.Operand)
+	if types.IsUnknownOrError(operand) {
+		i.setValue(idxExpr.GetId(), operand)
+		return
+	}
+	index := i.value(idxExpr.Index)
+	if types.IsUnknownOrError(index) {
+		i.setValue(idxExpr.GetId(), index)
+		return
+	}
+	if elem, found := indexNative(operand.Value(), index, idxExpr.ElemType); found {
+		i.setValue(idxExpr.GetId(), elem)
+		return
+	}
+	if !operand.Type().HasTrait(traits.IndexerType) {
+		i.setValue(idxExpr.GetId(), types.NewNoSuchOverloadErr())
+		return
+	}
+	i.setValue(idxExpr.GetId(), operand.(traits.Indexer).Get(index))
+}
+
 func (i *exprInterpretable) evalCreateList(listExpr *CreateListExpr) {
 	elements := make([]ref.Value, len(listExpr.Elements))
+	var invalid ref.Value
 	for idx, elementId := range listExpr.Elements {
-		elem := i.value(elementId)
-		if types.IsError(elem.Type()) || types.IsUnknown(elem.Type()) {
-			i.setValue(listExpr.GetId(), elem)
-			return
-		}
 		elements[idx] = i.value(elementId)
+		invalid = mergeInvalid(invalid, elements[idx])
+	}
+	if invalid != nil {
+		i.setValue(listExpr.GetId(), invalid)
+		return
 	}
 	adaptingList := types.NewDynamicList(elements)
 	i.setValue(listExpr.GetId(), adaptingList)
@@ -247,33 +610,33 @@ func (i *exprInterpretable) evalCreateList(listExpr *CreateListExpr) {
 
 func (i *exprInterpretable) evalCreateMap(mapExpr *CreateMapExpr) {
 	entries := make(map[ref.Value]ref.Value)
+	var invalid ref.Value
 	for keyId, valueId := range mapExpr.KeyValues {
 		key := i.value(keyId)
-		if types.IsError(key.Type()) || types.IsUnknown(key.Type()) {
-			i.setValue(mapExpr.GetId(), key)
-			return
-		}
 		val := i.value(valueId)
-		if types.IsError(val.Type()) || types.IsUnknown(val.Type()) {
-			i.setValue(mapExpr.GetId(), val)
-			return
-		}
+		invalid = mergeInvalid(mergeInvalid(invalid, key), val)
 		entries[key] = val
 	}
+	if invalid != nil {
+		i.setValue(mapExpr.GetId(), sortErrors(invalid))
+		return
+	}
 	adaptingMap := types.NewDynamicMap(entries)
 	i.setValue(mapExpr.GetId(), adaptingMap)
 }
 
 func (i *exprInterpretable) evalCreateType(objExpr *CreateObjectExpr) {
 	fields := make(map[string]ref.Value)
+	var invalid ref.Value
 	for field, valueId := range objExpr.FieldValues {
 		val := i.value(valueId)
-		if types.IsError(val) || types.IsUnknown(val) {
-			i.setValue(objExpr.GetId(), val)
-			return
-		}
+		invalid = mergeInvalid(invalid, val)
 		fields[field] = val
 	}
+	if invalid != nil {
+		i.setValue(objExpr.GetId(), sortErrors(invalid))
+		return
+	}
 	i.setValue(objExpr.GetId(), i.newValue(objExpr.Name, fields))
 }
 
@@ -281,14 +644,77 @@ func (i *exprInterpretable) evalMov(movExpr *MovInst) {
 	i.setValue(movExpr.ToExprId, i.value(movExpr.GetId()))
 }
 
+// mergeInvalid combines an unknown or error value with the invalid value
+// accumulated from the preceding operands of an instruction, if any.
+//
+// Unknown values take precedence over any errors, and are merged so that each
+// of the unknown attributes is reported; otherwise the errors of all operands
+// are aggregated so that each of them is reported.
+func mergeInvalid(invalid ref.Value, val ref.Value) ref.Value {
+	switch {
+	case types.IsUnknown(val):
+		if invalid == nil || !types.IsUnknown(invalid) {
+			return val
+		}
+		return types.MergeUnknowns(invalid, val)
+	case types.IsError(val):
+		if invalid == nil {
+			return val
+		}
+		if !types.IsUnknown(invalid) {
+			return types.MergeErrors(invalid, val)
+		}
+	}
+	return invalid
+}
+
+// sortErrors orders the errors of an ErrorSet aggregated from the unordered
+// entries of a map or object by the ids of the expressions which produced
+// them.
+func sortErrors(invalid ref.Value) ref.Value {
+	if errs, isSet := invalid.(types.ErrorSet); isSet {
+		sort.Slice(errs, func(a, b int) bool {
+			aId, _ := errs[a].ExprId()
+			bId, _ := errs[b].ExprId()
+			return aId < bId
+		})
+	}
+	return invalid
+}
+
 func (i *exprInterpretable) value(id int64) ref.Value {
-	if object, found := i.state.Value(id); found {
+	// Expressions skipped by a jump, such as the right-hand side of a logical
+	// operator which short-circuits, have no value.
+	if object, found := i.state.Value(id); found && object != nil {
 		return object
 	}
-	return types.Unknown{id}
+	return types.NewUnknownSet(id)
 }
 
+// setValue associates a value with an expression id. Errors which have not yet
+// been associated with an expression are attributed to the given id so that
+// their source location may be reported by EvalState.ErrorLocation, and are
+// redacted at that point when the program is configured to do so.
+//
+// ErrorSet values are truncated to the maximum number of errors configured
+// for the program.
 func (i *exprInterpretable) setValue(id int64, value ref.Value) {
+	switch v := value.(type) {
+	case *types.Err:
+		if _, found := v.ExprId(); !found {
+			v = locateError(i.program, v, id)
+			if i.redactErrors {
+				v = v.Redact()
+			}
+			value = v
+		}
+	case types.ErrorSet:
+		if i.maxErrors > 0 && len(v) > i.maxErrors {
+			value = types.MergeErrors(v[:i.maxErrors])
+		}
+	}
+	i.state.attributes.track(id)
+	i.state.trace.add(id, value)
 	i.state.SetValue(id, value)
 }
 
@@ -15,16 +15,23 @@
 package interpreter
 
 import (
+	"context"
+	"fmt"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
 	"github.com/google/cel-go/common/packages"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
 	"github.com/google/cel-go/interpreter/functions"
 	"github.com/google/cel-go/parser"
 	"github.com/google/cel-go/test"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
 	expr "github.com/google/cel-spec/proto/v1/syntax"
+	"net"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -92,6 +99,72 @@ func TestInterpreter_ComprehensionExpr(t *testing.T) {
 	}
 }
 
+func TestInterpreter_ShortCircuit(t *testing.T) {
+	for _, tst := range []struct {
+		text     string
+		expected ref.Value
+	}{
+		{"false && 1 / 0 == 1", types.False},
+		{"1 / 0 == 1 && false", types.False},
+		{"true || 1 / 0 == 1", types.True},
+		{"1 / 0 == 1 || true", types.True},
+		// Unknowns absorb errors on either side, and are merged.
+		{"x && 1 / 0 == 1", types.NewUnknownAttribute(1, "x")},
+		{"1 / 0 == 1 || x", types.NewUnknownAttribute(6, "x")},
+		{"x && y", types.MergeUnknowns(
+			types.NewUnknownAttribute(1, "x"), types.NewUnknownAttribute(2, "y"))},
+		{"x || false || y", types.MergeUnknowns(
+			types.NewUnknownAttribute(1, "x"), types.NewUnknownAttribute(4, "y"))},
+		{"x && false", types.False},
+		{"[0, 1].all(i, i == 0 || 1 / i == 1)", types.True},
+		{"[1, 0].exists(i, i != 0 && 1 / i == 0)", types.False},
+		{"[1, 2].all(i, i < 2)", types.False},
+	} {
+		for _, opts := range [][]ProgramOption{
+			{},
+			{FuseInstructions()},
+			{TreeEvaluation()},
+		} {
+			program := parsedProgram(t, tst.text)
+			for _, opt := range opts {
+				opt(program.(*exprProgram))
+			}
+			result, _ := interpreter.NewInterpretable(program).Eval(NewActivation(
+				map[string]interface{}{}))
+			if !reflect.DeepEqual(result, tst.expected) {
+				t.Errorf("%s: got %v with %+v, wanted %v",
+					tst.text, result, program.Config(), tst.expected)
+			}
+		}
+	}
+}
+
+func TestInterpreter_ShortCircuitSkipsEvaluation(t *testing.T) {
+	for _, text := range []string{"false && skipped", "true || skipped"} {
+		for _, opts := range [][]ProgramOption{
+			{},
+			{FuseInstructions()},
+			{TreeEvaluation()},
+		} {
+			evals := 0
+			activation := NewActivation(map[string]interface{}{
+				"skipped": func() ref.Value {
+					evals++
+					return types.True
+				}})
+			program := parsedProgram(t, text)
+			for _, opt := range opts {
+				opt(program.(*exprProgram))
+			}
+			interpreter.NewInterpretable(program).Eval(activation)
+			if evals != 0 {
+				t.Errorf("%s: got %d evaluations of the right-hand side with %+v, wanted none",
+					text, evals, program.Config())
+			}
+		}
+	}
+}
+
 func TestInterpreter_BuildObject(t *testing.T) {
 	parsed, errors := parser.ParseText("v1.Expr{id: 1, " +
 		"literal_expr: v1.Literal{string_value: \"oneof_test\"}}")
@@ -177,6 +250,789 @@ func TestInterpreter_MapIndex(t *testing.T) {
 	}
 }
 
+func TestInterpreter_TypedIndex(t *testing.T) {
+	program := checkedProgram(t,
+		"elems[1] == 2 && names['b'] == 'z' && flags[0]",
+		decls.NewIdent("elems", decls.NewListType(decls.Int), nil),
+		decls.NewIdent("names", decls.NewMapType(decls.String, decls.String), nil),
+		decls.NewIdent("flags", decls.NewListType(decls.Bool), nil))
+	i := interpreter.NewInterpretable(program)
+	indexCount := 0
+	for _, inst := range program.(*exprProgram).instructions {
+		if _, isIndex := inst.(*IndexExpr); isIndex {
+			indexCount++
+		}
+	}
+	if indexCount != 3 {
+		t.Errorf("Got %d index instructions, wanted 3", indexCount)
+	}
+
+	// Native slices and maps take the fast path, while other list and map
+	// implementations fall back to the traits.Indexer interface.
+	activations := []Activation{
+		NewActivation(map[string]interface{}{
+			"elems": []int64{1, 2, 3},
+			"names": map[string]string{"a": "y", "b": "z"},
+			"flags": []bool{true}}),
+		NewActivation(map[string]interface{}{
+			"elems": []interface{}{1, 2, 3},
+			"names": map[string]interface{}{"a": "y", "b": "z"},
+			"flags": types.NewValueList([]ref.Value{types.True})}),
+	}
+	for _, activation := range activations {
+		if res, _ := i.Eval(activation); res != types.True {
+			t.Errorf("Got '%v', wanted 'true'", res)
+		}
+	}
+
+	res, _ := i.Eval(NewActivation(map[string]interface{}{
+		"elems": []int64{1},
+		"names": map[string]string{},
+		"flags": []bool{}}))
+	if !types.IsError(res) {
+		t.Errorf("Got '%v', wanted index out of range error", res)
+	}
+}
+
+func TestInterpreter_RedactErrors(t *testing.T) {
+	parsed, errors := parser.ParseText("m['ssn'] == 1 || name && true")
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	activation := NewActivation(map[string]interface{}{
+		"m":    map[string]int64{"id": 1},
+		"name": "Jane"})
+	for _, tst := range []struct {
+		opts     []ProgramOption
+		expected []string
+	}{
+		{nil, []string{"no such key: 'ssn'",
+			"Got 'Jane', expected argument of type 'bool'"}},
+		{[]ProgramOption{RedactErrors()}, []string{"no such key: '<string>'",
+			"Got '<string>', expected argument of type 'bool'"}},
+		{[]ProgramOption{RedactErrors(), TreeEvaluation()}, []string{"no such key: '<string>'",
+			"Got '<string>', expected argument of type 'bool'"}},
+	} {
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+			tst.opts...)
+		res, state := interpreter.NewInterpretable(program).Eval(activation)
+		errs, isSet := res.(types.ErrorSet)
+		if !isSet || len(errs) != len(tst.expected) {
+			t.Errorf("Got '%v', wanted an error set of %v", res, tst.expected)
+			continue
+		}
+		for i, err := range errs {
+			if err.String() != tst.expected[i] {
+				t.Errorf("Got '%v', wanted '%s'", err, tst.expected[i])
+			}
+		}
+		if _, found := state.ErrorLocation(parsed.GetExpr().GetId()); !found {
+			t.Error("Expected a location for the error")
+		}
+	}
+}
+
+func TestInterpreter_UnknownAttributes(t *testing.T) {
+	for _, tst := range []struct {
+		text       string
+		attributes []string
+	}{
+		{"a.b.c + d > 1", []string{"a.b.c", "d"}},
+		{"size(a.b) == 2 || c[0] && true", []string{"a.b", "c"}},
+		{"e.f == 1", nil},
+	} {
+		for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+			program := parsedProgram(t, tst.text)
+			for _, opt := range opts {
+				opt(program.(*exprProgram))
+			}
+			result, _ := interpreter.NewInterpretable(program).Eval(NewActivation(
+				map[string]interface{}{"e": map[string]int{"f": 1}}))
+			if tst.attributes == nil {
+				if result != types.True {
+					t.Errorf("%s: got '%v', wanted true", tst.text, result)
+				}
+				continue
+			}
+			unknown, isSet := result.(*types.UnknownSet)
+			if !isSet || !reflect.DeepEqual(unknown.Attributes(), tst.attributes) {
+				t.Errorf("%s: got '%v' with %+v, wanted unknown attributes %v",
+					tst.text, result, program.Config(), tst.attributes)
+			}
+		}
+	}
+}
+
+func TestInterpreter_ErrorSet(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"a": 1,
+		"m": map[string]int64{"id": 1}})
+	for _, tst := range []struct {
+		text     string
+		opts     []ProgramOption
+		expected string
+	}{
+		{text: "a / 0 + m['x']",
+			expected: "2 errors: divide by zero; no such key: 'x'"},
+		{text: "[a / 0, 1, m['x'], a % 0]",
+			expected: "3 errors: divide by zero; no such key: 'x'; modulus by zero"},
+		{text: "{'k': a / 0, m['x']: 1}",
+			expected: "2 errors: divide by zero; no such key: 'x'"},
+		{text: "a / 0 > 1 || m['x'] == 1",
+			expected: "2 errors: divide by zero; no such key: 'x'"},
+		{text: "[a / 0, 1, m['x'], a % 0]",
+			opts:     []ProgramOption{MaxErrors(2)},
+			expected: "2 errors: divide by zero; no such key: 'x'"},
+		{text: "[a / 0, m['x']]",
+			opts:     []ProgramOption{MaxErrors(1)},
+			expected: "divide by zero"},
+		{text: "[a / 0, x]", expected: "unknown [4] (x)"},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+			tst.opts...)
+		res, _ := interpreter.NewInterpretable(program).Eval(activation)
+		if fmt.Sprint(res) != tst.expected {
+			t.Errorf("%s: got '%v', wanted '%s'", tst.text, res, tst.expected)
+		}
+	}
+}
+
+func TestInterpreter_Coalesce(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"a": 1,
+		"m": map[string]interface{}{
+			"name": "cel",
+			"zip":  types.NullValue,
+			"tags": map[string]string{"env": "prod"}}})
+	for _, tst := range []struct {
+		text     string
+		expected string
+	}{
+		{"coalesce(m.name, 'none')", "cel"},
+		{"coalesce(m.missing, 'none')", "none"},
+		{"coalesce(m.tags.region, m.tags.env)", "prod"},
+		{"coalesce(m.other.region, 'none')", "none"},
+		{"coalesce(m['zip'], 0)", "0"},
+		{"coalesce(coalesce(m.missing, m.zip), 'none')", "none"},
+		{"coalesce(a / 0, 2)", "divide by zero"},
+		{"coalesce(x, 2)", "unknown [1] (x)"},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+		res, _ := interpreter.NewInterpretable(program).Eval(activation)
+		if fmt.Sprint(res) != tst.expected {
+			t.Errorf("%s: got '%v', wanted '%s'", tst.text, res, tst.expected)
+		}
+	}
+}
+
+func TestInterpreter_PropagateNullSelect(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"m": map[string]interface{}{
+			"name":  "cel",
+			"owner": types.NullValue}})
+	null := fmt.Sprint(types.NullValue)
+	for _, tst := range []struct {
+		text      string
+		propagate string
+		expected  string
+	}{
+		{"m.name", "cel", "cel"},
+		{"m.owner.name", null, "invalid operand in select"},
+		{"m.owner.address.zip", null, "invalid operand in select"},
+		{"m.missing.name", "no such key: 'missing'", "no such key: 'missing'"},
+		{"m.name.size", "invalid operand in select", "invalid operand in select"},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+		res, _ := interpreter.NewInterpretable(program).Eval(activation)
+		if fmt.Sprint(res) != tst.expected {
+			t.Errorf("%s: got '%v', wanted '%s'", tst.text, res, tst.expected)
+		}
+		program = NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+			PropagateNullSelect())
+		res, _ = interpreter.NewInterpretable(program).Eval(activation)
+		if fmt.Sprint(res) != tst.propagate {
+			t.Errorf("%s: got '%v', wanted '%s' with null propagation",
+				tst.text, res, tst.propagate)
+		}
+	}
+}
+
+func TestInterpreter_DynDispatch(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"s": "a",
+		"n": 1,
+		"b": true,
+		"l": []int{1}})
+	null := fmt.Sprint(types.NullValue)
+	// Operands which match no overload once coerced are reported along with
+	// the signatures of the overloads of the function.
+	addCandidates := "candidates: add_int64(int, int), add_uint64(uint, uint), " +
+		"add_double(double, double), add_string(string, string), " +
+		"add_bytes(bytes, bytes), add_list(list, list), " +
+		"add_timestamp_duration(google.protobuf.Timestamp, google.protobuf.Duration), " +
+		"add_duration_timestamp(google.protobuf.Duration, google.protobuf.Timestamp), " +
+		"add_duration_duration(google.protobuf.Duration, google.protobuf.Duration)"
+	for _, tst := range []struct {
+		text    string
+		null    string
+		coerced string
+	}{
+		{text: "s + n", null: null, coerced: "a1"},
+		{text: "n + s", null: null, coerced: "1a"},
+		{text: "s < n", null: null, coerced: "false"},
+		{text: "n < 'b'", null: null, coerced: "true"},
+		{text: "s + b", null: null, coerced: "atrue"},
+		{text: "(s + n).size()", null: null, coerced: "2"},
+		{text: "n + 1u", null: null,
+			coerced: "no matching overload for '_+_' applied to (int, uint), " + addCandidates},
+		{text: "s + l", null: null,
+			coerced: "no matching overload for '_+_' applied to (string, list), " + addCandidates},
+		{text: "-s", null: null,
+			coerced: "no matching overload for '-_' applied to (string), " +
+				"candidates: negate_int64(int), negate_double(double)"},
+		{text: "s + n == 'a1'", null: "false", coerced: "true"},
+		{text: "n / 0", null: "divide by zero", coerced: "divide by zero"},
+		{text: "s + n + n", null: null, coerced: "a11"},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		for _, evalOpts := range [][]ProgramOption{
+			nil,
+			{FuseInstructions()},
+			{TreeEvaluation()},
+		} {
+			for onMismatch, expected := range map[OverloadMismatch]string{
+				NullOnMismatch:          tst.null,
+				CoerceStringsOnMismatch: tst.coerced,
+			} {
+				opts := append([]ProgramOption{DynDispatch(onMismatch)}, evalOpts...)
+				program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(), opts...)
+				res, _ := interpreter.NewInterpretable(program).Eval(activation)
+				if fmt.Sprint(res) != expected {
+					t.Errorf("%s: got '%v' with %+v, wanted '%s'",
+						tst.text, res, program.Config(), expected)
+				}
+			}
+		}
+	}
+
+	// Mismatched overloads remain errors by default.
+	parsed, _ := parser.ParseText("s + n")
+	program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+	if res, _ := interpreter.NewInterpretable(program).Eval(activation); !types.IsNoSuchOverload(res) {
+		t.Errorf("Got '%v', wanted a no such overload error", res)
+	}
+}
+
+func TestInterpreter_ConcurrentEval(t *testing.T) {
+	parsed, errors := parser.ParseText(
+		"[1, 2, 3].exists(x, x == a.level) && size(a.name) == 3")
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	for _, opts := range [][]ProgramOption{
+		{TrackAttributes()},
+		{TrackAttributes(), FuseInstructions()},
+		{TrackAttributes(), TreeEvaluation()},
+	} {
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(), opts...)
+		interpretable := interpreter.NewInterpretable(program)
+		var wg sync.WaitGroup
+		errs := make(chan error, 8)
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					level := (g + i) % 5
+					res, state := interpretable.Eval(NewActivation(map[string]interface{}{
+						"a": map[string]interface{}{"level": level, "name": "bob"}}))
+					expected := types.Bool(level >= 1 && level <= 3)
+					attributes := []string{"a.level"}
+					if expected {
+						attributes = append(attributes, "a.name")
+					}
+					if res != expected || !reflect.DeepEqual(state.Attributes(), attributes) {
+						errs <- fmt.Errorf("got '%v' reading %v for level %d, wanted '%v' reading %v",
+							res, state.Attributes(), level, expected, attributes)
+						return
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+	}
+}
+
+func TestInterpreter_EvalWithState(t *testing.T) {
+	parsed, errors := parser.ParseText(
+		"[1, 2, 3].exists(x, x == a.level) && size(a.name) == 3")
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	for _, opts := range [][]ProgramOption{
+		{TrackAttributes()},
+		{TrackAttributes(), TreeEvaluation()},
+	} {
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(), opts...)
+		interpretable := interpreter.NewInterpretable(program).(ReusableInterpretable)
+		state := interpretable.NewState()
+		// The state is reset between evaluations, so the attributes read by
+		// the first evaluation are not reported by the second.
+		for _, level := range []int{2, 4, 3} {
+			activation := NewActivation(map[string]interface{}{
+				"a": map[string]interface{}{"level": level, "name": "bob"}})
+			_, fresh := interpretable.Eval(activation)
+			res, evalState := interpretable.EvalWithState(activation, state)
+			expected := types.Bool(level <= 3)
+			attributes := []string{"a.level"}
+			if expected {
+				attributes = append(attributes, "a.name")
+			}
+			if res != expected || !reflect.DeepEqual(evalState.Attributes(), attributes) {
+				t.Errorf("got '%v' reading %v for level %d, wanted '%v' reading %v",
+					res, evalState.Attributes(), level, expected, attributes)
+			}
+			cost, _ := ActualCost(evalState)
+			if expectedCost, _ := ActualCost(fresh); cost != expectedCost {
+				t.Errorf("got cost %d for level %d, wanted %d", cost, level, expectedCost)
+			}
+		}
+	}
+}
+
+func TestInterpreter_CheckCancellation(t *testing.T) {
+	elems := make([]int64, 10000)
+	parsed, errors := parser.ParseText("elems.exists(x, x < 0)")
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	for _, evalOpts := range [][]ProgramOption{
+		nil,
+		{FuseInstructions()},
+		{TreeEvaluation()},
+	} {
+		opts := append([]ProgramOption{CheckCancellation(10)}, evalOpts...)
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(), opts...)
+		interpretable := interpreter.NewInterpretable(program)
+
+		// The context is cancelled once the evaluation has begun.
+		ctx, cancel := context.WithCancel(context.Background())
+		res, state := interpretable.Eval(NewContextActivation(ctx,
+			NewActivation(map[string]interface{}{
+				"elems": func() ref.Value {
+					cancel()
+					return types.NewDynamicList(elems)
+				}})))
+		if fmt.Sprint(res) != "evaluation cancelled: context canceled" {
+			t.Errorf("Got '%v' with %+v, wanted a cancellation error", res, program.Config())
+		}
+		if cost, _ := ActualCost(state); cost > 100 {
+			t.Errorf("Got cost %d with %+v, wanted evaluation to stop once cancelled",
+				cost, program.Config())
+		}
+
+		// Evaluations with a live context, or without one, are unaffected.
+		ctx, cancel = context.WithCancel(context.Background())
+		vars := NewActivation(map[string]interface{}{"elems": elems[:100]})
+		for _, activation := range []Activation{NewContextActivation(ctx, vars), vars} {
+			if res, _ := interpretable.Eval(activation); res != types.False {
+				t.Errorf("Got '%v' with %+v, wanted false", res, program.Config())
+			}
+		}
+		cancel()
+	}
+
+	// The context is not checked by default.
+	program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, _ := interpreter.NewInterpretable(program).Eval(NewContextActivation(ctx,
+		NewActivation(map[string]interface{}{"elems": elems[:10]})))
+	if res != types.False {
+		t.Errorf("Got '%v', wanted false", res)
+	}
+}
+
+func TestInterpreter_NativeContainers(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"tags":   []string{"a", "b"},
+		"counts": map[string]int32{"a": 1},
+		"groups": map[string][]string{"admin": {"alice"}},
+		"ids":    map[int]string{1: "one"},
+		"matrix": [][]float32{{0.5}, {1.5, 2.5}}})
+	idents := []*checkedpb.Decl{
+		decls.NewVar("tags", decls.NewListType(decls.String)),
+		decls.NewVar("counts", decls.NewMapType(decls.String, decls.Int)),
+		decls.NewVar("groups",
+			decls.NewMapType(decls.String, decls.NewListType(decls.String))),
+		decls.NewVar("ids", decls.NewMapType(decls.Int, decls.String)),
+		decls.NewVar("matrix",
+			decls.NewListType(decls.NewListType(decls.Double)))}
+	for _, text := range []string{
+		"'b' in tags && tags + ['c'] == ['a', 'b', 'c']",
+		"counts['a'] + 1 == 2 && counts == {'a': 1}",
+		"'alice' in groups.admin",
+		"ids[1] == 'one' && 1 in ids",
+		"matrix[1][1] == 2.5 && matrix[0] == [0.5]",
+	} {
+		program := checkedProgram(t, text, idents...)
+		res, _ := interpreter.NewInterpretable(program).Eval(activation)
+		if res != types.True {
+			t.Errorf("%s: got '%v', wanted true", text, res)
+		}
+	}
+}
+
+func TestInterpreter_CustomComparer(t *testing.T) {
+	provider := types.NewProvider()
+	if err := provider.RegisterType(versionType); err != nil {
+		t.Fatal(err)
+	}
+	interp := NewStandardIntepreter(packages.DefaultPackage, provider)
+	activation := NewActivation(map[string]interface{}{
+		"installed": version{1, 2},
+		"required":  version{1, 10},
+		"versions":  []ref.Value{version{0, 9}, version{1, 2}}})
+	versionDecl := decls.NewObjectType("acme.Version")
+	idents := append(checker.ComparisonDeclarations(versionType, ipRangesType),
+		decls.NewVar("installed", versionDecl),
+		decls.NewVar("required", versionDecl),
+		decls.NewVar("versions", decls.NewListType(versionDecl)))
+	if len(idents) != 7 {
+		t.Errorf("Got %d declarations, wanted 4 comparisons and 3 idents", len(idents))
+	}
+	for _, tst := range []struct {
+		text     string
+		expected ref.Value
+	}{
+		{text: "installed < required", expected: types.True},
+		{text: "installed <= required && installed >= installed", expected: types.True},
+		{text: "installed > required || required < installed", expected: types.False},
+		{text: "installed == versions[1] && installed != required", expected: types.True},
+		{text: "versions.all(v, v <= installed)", expected: types.True},
+		{text: "installed in versions", expected: types.True},
+	} {
+		for _, program := range []Program{
+			parsedProgram(t, tst.text),
+			checkedProgram(t, tst.text, idents...),
+		} {
+			for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+				for _, opt := range opts {
+					opt(program.(*exprProgram))
+				}
+				optimized := Optimize(program, dispatcher())
+				for _, p := range []Program{program, optimized} {
+					res, _ := interp.NewInterpretable(p).Eval(activation)
+					if res != tst.expected {
+						t.Errorf("%s: got '%v' with %+v, wanted '%v'",
+							tst.text, res, p.Config(), tst.expected)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestInterpreter_CustomContainer(t *testing.T) {
+	_, private, _ := net.ParseCIDR("10.0.0.0/8")
+	activation := NewActivation(map[string]interface{}{
+		"ip":      "10.1.2.3",
+		"private": ipRanges{private}})
+	idents := append(decls.NewInFunctions("in_string_ip_ranges",
+		decls.String, decls.NewObjectType("net.IPRanges")),
+		decls.NewVar("ip", decls.String),
+		decls.NewVar("private", decls.NewObjectType("net.IPRanges")))
+	for _, tst := range []struct {
+		text     string
+		expected string
+	}{
+		{text: "ip in private", expected: "true"},
+		{text: "'192.168.0.1' in private", expected: "false"},
+		{text: "!('11.0.0.1' in private) && ip in private", expected: "true"},
+		{text: "'not an ip' in private", expected: "invalid IP address 'not an ip'"},
+	} {
+		for _, program := range []Program{
+			parsedProgram(t, tst.text),
+			checkedProgram(t, tst.text, idents...),
+		} {
+			for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+				for _, opt := range opts {
+					opt(program.(*exprProgram))
+				}
+				res, _ := interpreter.NewInterpretable(program).Eval(activation)
+				if fmt.Sprint(res) != tst.expected {
+					t.Errorf("%s: got '%v' with %+v, wanted '%s'",
+						tst.text, res, program.Config(), tst.expected)
+				}
+			}
+		}
+	}
+}
+
+func TestInterpreter_HasField(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"x": &test.TestAllTypes{
+			NestedType: &test.TestAllTypes_SingleNestedEnum{
+				SingleNestedEnum: test.TestAllTypes_FOO}},
+		"m": map[string]int{"a": 1}})
+	provider := types.NewProvider(&test.TestAllTypes{})
+	idents := []*checkedpb.Decl{
+		decls.NewVar("x",
+			decls.NewObjectType("google.api.tools.expr.test.TestAllTypes")),
+		decls.NewVar("m", decls.NewMapType(decls.String, decls.Int))}
+	for _, tst := range []struct {
+		text     string
+		expected ref.Value
+	}{
+		{"has(x.single_nested_enum)", types.True},
+		{"has(x.single_nested_message)", types.False},
+		{"has(x.single_int64_wrapper)", types.False},
+		{"x.single_nested_enum == 0 && x.single_nested_message.bb == 0", types.True},
+		{"whichOneof(x, 'nested_type')", types.String("single_nested_enum")},
+		{"has(m.a) && !has(m.b)", types.True},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		env := checker.NewStandardEnv(packages.DefaultPackage, provider, errors)
+		env.Add(idents...)
+		checked := checker.Check(parsed, env)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		program := NewCheckedProgram(checked)
+		res, _ := interpreter.NewInterpretable(program).Eval(activation)
+		if res != tst.expected {
+			t.Errorf("%s: got '%v', wanted '%v'", tst.text, res, tst.expected)
+		}
+	}
+}
+
+func TestInterpreter_JSONFieldNames(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"x": &test.TestAllTypes{
+			SingleInt32: 2,
+			NestedType: &test.TestAllTypes_SingleNestedEnum{
+				SingleNestedEnum: test.TestAllTypes_BAR}}})
+	provider := types.NewProvider(&test.TestAllTypes{})
+	pkg := packages.NewPackage("google.api.tools.expr.test")
+	i := NewStandardIntepreter(pkg, provider)
+	for _, tst := range []struct {
+		text     string
+		expected ref.Value
+	}{
+		{"x.singleInt32 + x.singleNestedEnum == 3", types.True},
+		{"has(x.singleInt64Wrapper) ? x.singleInt64Wrapper + 1 : 0", types.Int(0)},
+		{"[TestAllTypes{singleInt64: 4}][0].singleInt64", types.Int(4)},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		env := checker.NewStandardEnv(pkg, provider, errors,
+			checker.FieldNames(checker.JSONFieldNames))
+		env.Add(decls.NewVar("x",
+			decls.NewObjectType("google.api.tools.expr.test.TestAllTypes")))
+		checked := checker.Check(parsed, env)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+			program := NewCheckedProgram(checked, opts...)
+			if res, _ := i.NewInterpretable(program).Eval(activation); res != tst.expected {
+				t.Errorf("%s: got '%v', wanted '%v'", tst.text, res, tst.expected)
+			}
+		}
+		// Unchecked expressions only select fields by their proto names.
+		program := NewProgram(parsed.Expr, parsed.SourceInfo)
+		if res, _ := i.NewInterpretable(program).Eval(activation); !types.IsError(res) {
+			t.Errorf("%s: got '%v' without checking, wanted an error", tst.text, res)
+		}
+	}
+}
+
+func TestInterpreter_UniqueKeys(t *testing.T) {
+	idents := []*checkedpb.Decl{
+		decls.NewIdent("users", decls.NewListType(
+			decls.NewMapType(decls.String, decls.String)), nil),
+		decls.NewIdent("ids", decls.NewListType(decls.Dyn), nil)}
+	for _, tst := range []struct {
+		text     string
+		expected ref.Value
+	}{
+		{text: "users.all_unique(u, u.name)", expected: types.False},
+		{text: "users.all_unique(u, u.name + u.team)", expected: types.True},
+		{text: "users.exists_unique(u, u.team)", expected: types.True},
+		{text: "users.exists_unique(u, u.name)", expected: types.True},
+		{text: "users.map(u, u.team).all_unique(t, t)", expected: types.False},
+		{text: "[].all_unique(x, x)", expected: types.True},
+		{text: "[].exists_unique(x, x)", expected: types.False},
+		{text: "ids.all_unique(x, x)", expected: types.True},
+		{text: "ids.all_unique(x, [x])", expected: types.True},
+		{text: "(ids + [b'a']).all_unique(x, x)", expected: types.False},
+		{text: "(ids + [[1]]).all_unique(x, [x])", expected: types.False},
+		{text: "users.all_unique(u, u.missing)"},
+	} {
+		program := checkedProgram(t, tst.text, idents...)
+		activation := NewActivation(map[string]interface{}{
+			"users": []map[string]string{
+				{"name": "ana", "team": "a"},
+				{"name": "ana", "team": "b"},
+				{"name": "bo", "team": "a"}},
+			"ids": []interface{}{1, uint64(1), "1", []byte("a"), 1.5, []int64{1}}})
+		res, _ := interpreter.NewInterpretable(program).Eval(activation)
+		if tst.expected == nil {
+			if !types.IsError(res) {
+				t.Errorf("%s: got '%v', wanted error", tst.text, res)
+			}
+		} else if res != tst.expected {
+			t.Errorf("%s: got '%v', wanted '%v'", tst.text, res, tst.expected)
+		}
+	}
+}
+
+// compiledConstants counts the constant arguments of the program which were
+// compiled into values of the type when the program was planned.
+func compiledConstants(program Program, t ref.Type) int {
+	count := 0
+	for _, value := range program.(*exprProgram).compiled {
+		if value.Type() == t {
+			count++
+		}
+	}
+	return count
+}
+
+func TestInterpreter_MatchesGlob(t *testing.T) {
+	program := checkedProgram(t, `request.path.matchesGlob('/api/**/edit') ||
+		request.path.matchesGlob(pattern)`,
+		decls.NewIdent("request", decls.NewMapType(decls.String, decls.String), nil),
+		decls.NewIdent("pattern", decls.String, nil))
+	interpretable := interpreter.NewInterpretable(program)
+	for _, tst := range []struct {
+		path     string
+		pattern  string
+		expected ref.Value
+	}{
+		{path: "/api/users/1/edit", pattern: "", expected: types.True},
+		{path: "/api/users/1", pattern: "/api/*/?", expected: types.True},
+		{path: "/api/users/1", pattern: "/api/*", expected: types.False},
+	} {
+		activation := NewActivation(map[string]interface{}{
+			"request": map[string]string{"path": tst.path},
+			"pattern": tst.pattern})
+		res, _ := interpretable.Eval(activation)
+		if res != tst.expected {
+			t.Errorf("%s: got '%v', wanted '%v'", tst.path, res, tst.expected)
+		}
+	}
+	// The constant pattern was compiled when the program was planned.
+	if globs := compiledConstants(program, types.GlobType); globs != 1 {
+		t.Errorf("Got %d compiled patterns, wanted 1", globs)
+	}
+	// Invalid patterns produce an error on evaluation.
+	res, _ := interpretable.Eval(NewActivation(map[string]interface{}{
+		"request": map[string]string{"path": "/"},
+		"pattern": `\`}))
+	if !types.IsError(res) {
+		t.Errorf("Got '%v', wanted error", res)
+	}
+}
+
+func TestInterpreter_Regex(t *testing.T) {
+	interp := NewInterpreter(dispatcher(functions.RegexOverloads()...),
+		packages.DefaultPackage, types.NewProvider())
+	idents := append(checker.RegexDeclarations(),
+		decls.NewIdent("s", decls.String, nil),
+		decls.NewIdent("pattern", decls.String, nil))
+	// Valid constant patterns are compiled when the program is planned, and
+	// invalid ones are left to report an error on evaluation.
+	for _, tst := range []struct {
+		text     string
+		compiled int
+	}{
+		{text: `re.matches(s, '^/users/[0-9]+$')`, compiled: 1},
+		{text: `re.capture(s, 'user-([0-9]+)')`, compiled: 1},
+		{text: `re.captureAll(s, '[a-z]+') == ['ab', 'c']`, compiled: 1},
+		{text: `re.replace(s, '([a-z]+)@', '$1 at ')`, compiled: 1},
+		{text: `re.matches(s, pattern)`},
+		{text: `re.matches(s, '(')`},
+	} {
+		program := checkedProgram(t, tst.text, idents...)
+		interp.NewInterpretable(program)
+		if compiled := compiledConstants(program, types.RegexType); compiled != tst.compiled {
+			t.Errorf("%s: got %d compiled patterns, wanted %d",
+				tst.text, compiled, tst.compiled)
+		}
+	}
+}
+
+func TestInterpreter_Matcher(t *testing.T) {
+	interp := NewInterpreter(dispatcher(functions.MatcherOverloads()...),
+		packages.DefaultPackage, types.NewProvider())
+	idents := append(checker.MatcherDeclarations(),
+		decls.NewIdent("host", decls.String, nil),
+		decls.NewIdent("domains", decls.NewListType(decls.String), nil))
+	for _, tst := range []struct {
+		text     string
+		constant bool
+	}{
+		{text: `matcher.fromList(['.example.com', '.example.org']).endsWithAny(host)`,
+			constant: true},
+		{text: `matcher.fromList(domains).endsWithAny(host)`},
+	} {
+		program := checkedProgram(t, tst.text, idents...)
+		interpretable := interp.NewInterpretable(program)
+		// Matchers of constant lists are built when the program is planned.
+		matchers := compiledConstants(program, types.MatcherType)
+		if constant := matchers == 1; constant != tst.constant {
+			t.Errorf("%s: got constant %t, wanted %t", tst.text, constant, tst.constant)
+		}
+		serialized, err := MarshalProgram(program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unmarshalled, err := UnmarshalProgram(serialized)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range []Interpretable{interpretable, interp.NewInterpretable(unmarshalled)} {
+			for host, expected := range map[string]ref.Value{
+				"api.example.com": types.True,
+				"example.org":     types.False,
+				"example.org.net": types.False,
+			} {
+				res, _ := i.Eval(NewActivation(map[string]interface{}{
+					"host":    host,
+					"domains": []string{".example.com", ".example.org"}}))
+				if res != expected {
+					t.Errorf("%s: got '%v' for %s, wanted '%v'", tst.text, res, host, expected)
+				}
+			}
+		}
+	}
+}
+
 func BenchmarkInterpreter_ConditionalExpr(b *testing.B) {
 	// a ? b < 1.0 : c == ["hello"]
 	program := NewProgram(
@@ -268,6 +1124,20 @@ func BenchmarkInterpreter_ComprehensionExpr(b *testing.B) {
 	}
 }
 
+func BenchmarkInterpreter_TypedIndexComprehension(b *testing.B) {
+	program := checkedProgram(b,
+		"[0, 1, 2, 3, 4, 5, 6, 7].exists(i, elems[i] < 0 || names['a'] == '')",
+		decls.NewIdent("elems", decls.NewListType(decls.Int), nil),
+		decls.NewIdent("names", decls.NewMapType(decls.String, decls.String), nil))
+	interpretable := interpreter.NewInterpretable(program)
+	activation := NewActivation(map[string]interface{}{
+		"elems": []int64{0, 1, 2, 3, 4, 5, 6, 7},
+		"names": map[string]string{"a": "y", "b": "z"}})
+	for i := 0; i < b.N; i++ {
+		interpretable.Eval(activation)
+	}
+}
+
 func BenchmarkInterpreter_ComprehensionExprWithInput(b *testing.B) {
 	// elems.exists(x, type(x) == uint)
 	program := NewProgram(
@@ -281,8 +1151,108 @@ func BenchmarkInterpreter_ComprehensionExprWithInput(b *testing.B) {
 	}
 }
 
+func checkedProgram(t testing.TB, text string, idents ...*checkedpb.Decl) Program {
+	parsed, errors := parser.ParseText(text)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	env := checker.NewStandardEnv(packages.DefaultPackage,
+		types.NewProvider(&expr.ParsedExpr{}), errors)
+	env.Add(idents...)
+	checked := checker.Check(parsed, env)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	return NewCheckedProgram(checked)
+}
+
 var (
 	interpreter = NewStandardIntepreter(
 		packages.DefaultPackage,
 		types.NewProvider(&expr.ParsedExpr{}))
 )
+
+// ipRanges is a set of IP address ranges which supports 'in' tests of the
+// string form of an IP address.
+type ipRanges []*net.IPNet
+
+var ipRangesType = types.NewTypeValue("net.IPRanges", traits.ContainerType)
+
+func (r ipRanges) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	return nil, fmt.Errorf("unsupported type conversion to '%v'", typeDesc)
+}
+
+func (r ipRanges) ConvertToType(typeVal ref.Type) ref.Value {
+	return types.NewErr("unsupported type conversion to '%v'", typeVal)
+}
+
+func (r ipRanges) Contains(value ref.Value) ref.Value {
+	str, isStr := value.(types.String)
+	if !isStr {
+		return types.NewNoSuchOverloadErr()
+	}
+	ip := net.ParseIP(string(str))
+	if ip == nil {
+		return types.NewErr("invalid IP address '%s'", str)
+	}
+	for _, ipNet := range r {
+		if ipNet.Contains(ip) {
+			return types.True
+		}
+	}
+	return types.False
+}
+
+func (r ipRanges) Equal(other ref.Value) ref.Value {
+	return types.Bool(reflect.DeepEqual(r, other))
+}
+
+func (r ipRanges) Type() ref.Type {
+	return ipRangesType
+}
+
+func (r ipRanges) Value() interface{} {
+	return []*net.IPNet(r)
+}
+
+// version is a major and minor version number, which is ordered through the
+// traits.Comparer interface.
+type version [2]int64
+
+var versionType = types.NewTypeValue("acme.Version", traits.ComparerType)
+
+func (v version) Compare(other ref.Value) ref.Value {
+	o, isVersion := other.(version)
+	if !isVersion {
+		return types.NewNoSuchOverloadErr()
+	}
+	for i := range v {
+		if v[i] != o[i] {
+			if v[i] < o[i] {
+				return types.IntNegOne
+			}
+			return types.IntOne
+		}
+	}
+	return types.IntZero
+}
+
+func (v version) ConvertToNative(typeDesc reflect.Type) (interface{}, error) {
+	return nil, fmt.Errorf("unsupported type conversion to '%v'", typeDesc)
+}
+
+func (v version) ConvertToType(typeVal ref.Type) ref.Value {
+	return types.NewErr("unsupported type conversion to '%v'", typeVal)
+}
+
+func (v version) Equal(other ref.Value) ref.Value {
+	return types.Bool(v == other)
+}
+
+func (v version) Type() ref.Type {
+	return versionType
+}
+
+func (v version) Value() interface{} {
+	return [2]int64(v)
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// EvalFunc evaluates a program with an activation, as Interpretable.Eval.
+type EvalFunc func(activation Activation) (ref.Value, EvalState)
+
+// Middleware wraps the evaluation of a program, e.g. to log or measure its
+// evaluations, to cache their results, or to recover from panics. A
+// middleware may inspect or replace the activation before calling next, and
+// the result and state after, or may return without calling next at all.
+type Middleware func(next EvalFunc) EvalFunc
+
+// middlewareInterpretable which implements Interpretable and evaluates an
+// Interpretable through a chain of middleware.
+type middlewareInterpretable struct {
+	interpretable Interpretable
+	eval          EvalFunc
+}
+
+// newMiddlewareInterpretable returns an Interpretable which evaluates the
+// interpretable through the middleware, the first of which is outermost.
+func newMiddlewareInterpretable(interpretable Interpretable,
+	middleware []Middleware) Interpretable {
+	eval := interpretable.Eval
+	for i := len(middleware) - 1; i >= 0; i-- {
+		eval = middleware[i](eval)
+	}
+	return &middlewareInterpretable{interpretable: interpretable, eval: eval}
+}
+
+func (i *middlewareInterpretable) Eval(activation Activation) (ref.Value, EvalState) {
+	return i.eval(activation)
+}
+
+// innerInterpretable returns the Interpretable evaluated through middleware,
+// or the given Interpretable when it has no middleware.
+func innerInterpretable(interpretable Interpretable) Interpretable {
+	if i, isMiddleware := interpretable.(*middlewareInterpretable); isMiddleware {
+		return i.interpretable
+	}
+	return interpretable
+}
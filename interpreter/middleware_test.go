@@ -0,0 +1,90 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// logMiddleware records the evaluations it wraps, before and after they are
+// evaluated.
+func logMiddleware(name string, log *[]string) Middleware {
+	return func(next EvalFunc) EvalFunc {
+		return func(activation Activation) (ref.Value, EvalState) {
+			*log = append(*log, name+" before")
+			result, state := next(activation)
+			*log = append(*log, name+" after")
+			return result, state
+		}
+	}
+}
+
+func TestEvalMiddleware(t *testing.T) {
+	for _, opts := range [][]ProgramOption{
+		{},
+		{FuseInstructions()},
+		{TreeEvaluation()},
+	} {
+		var log []string
+		// The default middleware binds 'a' when the activation does not.
+		defaults := func(next EvalFunc) EvalFunc {
+			return func(activation Activation) (ref.Value, EvalState) {
+				return next(NewHierarchicalActivation(
+					NewActivation(map[string]interface{}{"a": 1}), activation))
+			}
+		}
+		program := parsedProgram(t, "a + 1")
+		opts = append(opts,
+			EvalMiddleware(logMiddleware("outer", &log), logMiddleware("inner", &log)),
+			EvalMiddleware(defaults))
+		for _, opt := range opts {
+			opt(program.(*exprProgram))
+		}
+		interpretable := interpreter.NewInterpretable(program)
+		if result, _ := interpretable.Eval(NewActivation(map[string]interface{}{})); result != types.Int(2) {
+			t.Errorf("Got %v with %+v, wanted 2", result, program.Config())
+		}
+		if result, _ := interpretable.Eval(NewActivation(map[string]interface{}{"a": 2})); result != types.Int(3) {
+			t.Errorf("Got %v with %+v, wanted 3", result, program.Config())
+		}
+		expected := []string{
+			"outer before", "inner before", "inner after", "outer after",
+			"outer before", "inner before", "inner after", "outer after"}
+		if !reflect.DeepEqual(log, expected) {
+			t.Errorf("Got %v with %+v, wanted %v", log, program.Config(), expected)
+		}
+	}
+}
+
+func TestEvalMiddleware_ShortCircuit(t *testing.T) {
+	cached := func(next EvalFunc) EvalFunc {
+		return func(activation Activation) (ref.Value, EvalState) {
+			return types.String("cached"), NewEvalState(0)
+		}
+	}
+	program := parsedProgram(t, "a + 1")
+	EvalMiddleware(cached)(program.(*exprProgram))
+	interpretable := interpreter.NewInterpretable(program)
+	if result, _ := interpretable.Eval(NewActivation(map[string]interface{}{})); result != types.String("cached") {
+		t.Errorf("Got %v, wanted the cached result", result)
+	}
+	if config := program.Config(); config.Middleware != 1 {
+		t.Errorf("Got %d middleware, wanted 1", config.Middleware)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// EvalObserver is notified of each step of an evaluation, e.g. by a debugger
+// which shows the values of the subexpressions of an expression to explain
+// its result.
+type EvalObserver interface {
+	// Observe is called after an instruction is evaluated with the id of the
+	// instruction, its kind, and the value it produced, or nil when the
+	// instruction produces no value, as for jumps and changes of scope.
+	//
+	// When the program is evaluated as a tree, see TreeEvaluation, Observe is
+	// called after each expression is evaluated with the id and kind of the
+	// expression. Expressions are observed once per iteration within a
+	// comprehension.
+	//
+	// The observer is called by every evaluation of the program, so it must
+	// be safe for concurrent use when the program is evaluated concurrently.
+	Observe(id int64, kind InstructionKind, value ref.Value)
+}
+
+// InstructionKind is the kind of an instruction, or of an expression
+// evaluated as a tree, reported to an EvalObserver.
+type InstructionKind int
+
+const (
+	ConstKind InstructionKind = iota + 1
+	IdentKind
+	SelectKind
+	CallKind
+	IndexKind
+	CreateListKind
+	CreateMapKind
+	CreateObjectKind
+	ComprehensionKind
+	JumpKind
+	MovKind
+	PushScopeKind
+	PopScopeKind
+)
+
+var instructionKindNames = map[InstructionKind]string{
+	ConstKind:         "const",
+	IdentKind:         "ident",
+	SelectKind:        "select",
+	CallKind:          "call",
+	IndexKind:         "index",
+	CreateListKind:    "list",
+	CreateMapKind:     "map",
+	CreateObjectKind:  "object",
+	ComprehensionKind: "comprehension",
+	JumpKind:          "jump",
+	MovKind:           "mov",
+	PushScopeKind:     "push_scope",
+	PopScopeKind:      "pop_scope",
+}
+
+func (k InstructionKind) String() string {
+	return instructionKindNames[k]
+}
+
+// instructionKind returns the kind of an instruction. Fused instructions are
+// reported as the kind of the expression they replace, so that a comparison
+// with a constant is a call, and a chain of selections is a select.
+func instructionKind(inst Instruction) InstructionKind {
+	switch inst.(type) {
+	case *ConstExpr:
+		return ConstKind
+	case *IdentExpr:
+		return IdentKind
+	case *SelectExpr, *SelectPathExpr:
+		return SelectKind
+	case *CallExpr, *CompareConstExpr:
+		return CallKind
+	case *IndexExpr:
+		return IndexKind
+	case *CreateListExpr:
+		return CreateListKind
+	case *CreateMapExpr:
+		return CreateMapKind
+	case *CreateObjectExpr:
+		return CreateObjectKind
+	case *JumpInst:
+		return JumpKind
+	case *MovInst:
+		return MovKind
+	case *PushScopeInst:
+		return PushScopeKind
+	case *PopScopeInst:
+		return PopScopeKind
+	}
+	return 0
+}
+
+// expressionKinds returns the kind of each expression of a program evaluated
+// as a tree, by expression id.
+func expressionKinds(e *ast.Expr) map[int64]InstructionKind {
+	kinds := make(map[int64]InstructionKind)
+	ast.Visit(e, func(e *ast.Expr, parent *ast.Expr) bool {
+		switch kind := e.Kind.(type) {
+		case *ast.Literal:
+			kinds[e.Id] = ConstKind
+		case *ast.Ident:
+			kinds[e.Id] = IdentKind
+		case *ast.Select:
+			kinds[e.Id] = SelectKind
+		case *ast.Call:
+			if kind.Function == operators.Index {
+				kinds[e.Id] = IndexKind
+			} else {
+				kinds[e.Id] = CallKind
+			}
+		case *ast.CreateList:
+			kinds[e.Id] = CreateListKind
+		case *ast.CreateStruct:
+			if kind.MessageName == "" {
+				kinds[e.Id] = CreateMapKind
+			} else {
+				kinds[e.Id] = CreateObjectKind
+			}
+		case *ast.Comprehension:
+			kinds[e.Id] = ComprehensionKind
+		}
+		return true
+	})
+	return kinds
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// stepRecorder records the steps of an evaluation as 'kind id=value', where
+// values without a string conversion are recorded by their type names.
+type stepRecorder struct {
+	steps []string
+}
+
+func (r *stepRecorder) Observe(id int64, kind InstructionKind, value ref.Value) {
+	step := fmt.Sprintf("%v %d", kind, id)
+	if value != nil {
+		formatted := value.ConvertToType(types.StringType)
+		if types.IsError(formatted) {
+			formatted = types.String(value.Type().TypeName())
+		}
+		step += fmt.Sprintf("=%v", formatted)
+	}
+	r.steps = append(r.steps, step)
+}
+
+func TestObserveEvaluation(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"a": map[string]int{"b": 2},
+		"c": false})
+	// The identifier 'c' is skipped as the '||' short-circuits.
+	for _, tst := range []struct {
+		opts  []ProgramOption
+		steps []string
+	}{
+		{steps: []string{
+			"ident 1=map", "select 2=2", "list 4=list", "call 6=2",
+			"call 8=true", "jump 8", "call 10=true"}},
+		{opts: []ProgramOption{FuseInstructions()},
+			steps: []string{
+				"select 2=2", "list 4=list", "call 6=2",
+				"call 8=true", "jump 8", "call 10=true"}},
+		{opts: []ProgramOption{TreeEvaluation()},
+			steps: []string{
+				"ident 1=map", "select 2=2", "list 4=list", "index 6=2",
+				"call 8=true", "call 10=true"}},
+	} {
+		recorder := &stepRecorder{}
+		program := parsedProgram(t, "[a.b, 1][0] == 2 || c")
+		for _, opt := range append(tst.opts, ObserveEvaluation(recorder)) {
+			opt(program.(*exprProgram))
+		}
+		result, _ := interpreter.NewInterpretable(program).Eval(activation)
+		if result != types.True {
+			t.Errorf("Got %v with %+v, wanted true", result, program.Config())
+		}
+		if !reflect.DeepEqual(recorder.steps, tst.steps) {
+			t.Errorf("Got steps %v with %+v, wanted %v",
+				recorder.steps, program.Config(), tst.steps)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Optimize returns a copy of the program whose constant sub-expressions are
+// evaluated once, when the program is optimized, rather than on each
+// evaluation. The program itself is not modified.
+//
+// Calls of functions whose arguments are constant, and lists and maps whose
+// elements are constant, are folded into literals, as are logical operators
+// and conditionals which are decided by a constant operand. The jumps which
+// are decided by constants are then removed along with the instructions they
+// skip, and moves of a register to itself are removed.
+//
+// Calls are only folded for the Dispatcher created by NewDispatcher, and the
+// overloads of the dispatcher are assumed to be pure functions of their
+// arguments, except for the Volatile overloads, such as 'since', which are
+// never folded. Expressions which evaluate to errors or unknown values are not
+// folded, so that they are reported as they would be without optimization.
+//
+// Folded values other than primitives, lists, and maps, such as timestamps,
+// cannot be serialized by MarshalProgram.
+func Optimize(program Program, dispatcher Dispatcher) Program {
+	p, isExprProgram := program.(*exprProgram)
+	if !isExprProgram {
+		return program
+	}
+	optimized := *p
+	optimized.optimize = true
+	optimized.functions = nil
+	optimized.revInstructions = make(map[int64]int)
+	// Fused instructions are optimized in their original form, and fused
+	// again when the program is initialized.
+	if p.planned != nil {
+		optimized.instructions, optimized.planned = p.planned, nil
+	}
+	// Programs which were already initialized, including those loaded by
+	// UnmarshalProgram, are optimized directly. Otherwise the instructions
+	// are optimized as they are planned by Init.
+	if optimized.instructions != nil {
+		optimized.instructions, optimized.literals = optimizeInstructions(
+			optimized.instructions, optimized.literals, optimized.maxId, dispatcher)
+		optimized.requirements = newRequirements(&optimized, optimized.instructions,
+			dispatcher)
+	}
+	optimized.Init(dispatcher, NewEvalState(optimized.maxId+1))
+	return &optimized
+}
+
+// optimizeInstructions folds the constant instructions of a program into its
+// literals, as described for Optimize, and returns the remaining instructions
+// along with the literals. The given literals are not modified.
+func optimizeInstructions(instructions []Instruction,
+	literals map[int64]ref.Value,
+	maxId int64,
+	dispatcher Dispatcher) ([]Instruction, map[int64]ref.Value) {
+	o := &optimizer{
+		dispatcher: dispatcher,
+		literals:   make(map[int64]ref.Value, len(literals)),
+		maxId:      maxId}
+	for id, value := range literals {
+		o.literals[id] = value
+	}
+	for {
+		optimized, changed := o.pass(instructions)
+		if !changed {
+			return optimized, o.literals
+		}
+		instructions = optimized
+	}
+}
+
+// optimizer folds the constant instructions of a program, one pass at a time,
+// until no more instructions can be folded.
+type optimizer struct {
+	dispatcher Dispatcher
+	literals   map[int64]ref.Value
+	maxId      int64
+	// writes counts the instructions which write to each register. Literals
+	// are only constant when no instruction overwrites them, e.g. the initial
+	// value of the accumulator of a comprehension.
+	writes map[int64]int
+	// constants holds the constant registers, for evaluating the conditions of
+	// jumps.
+	constants *defaultEvalState
+}
+
+// pass folds the constant instructions and removes the dead jumps and
+// unreachable instructions, and returns the remaining instructions and
+// whether any were folded or removed.
+func (o *optimizer) pass(instructions []Instruction) ([]Instruction, bool) {
+	o.writes = make(map[int64]int)
+	jumpTargets := make(map[int]bool)
+	for i, inst := range instructions {
+		switch inst := inst.(type) {
+		case *JumpInst:
+			jumpTargets[i+inst.Count] = true
+		case *MovInst:
+			o.writes[inst.ToExprId]++
+		case *PushScopeInst, *PopScopeInst:
+		default:
+			o.writes[inst.GetId()]++
+		}
+	}
+	o.constants = NewEvalState(o.maxId + 1)
+	for id, value := range o.literals {
+		if o.writes[id] == 0 && id >= 0 && id <= o.maxId {
+			o.constants.SetValue(id, value)
+		}
+	}
+
+	removed := make(map[int]bool)
+	replaced := make(map[int]Instruction)
+	for i := 0; i < len(instructions); i++ {
+		inst := instructions[i]
+		if removed[i] {
+			continue
+		}
+		if jump, isJump := inst.(*JumpInst); isJump {
+			taken, decided := o.decide(jump)
+			switch {
+			case !decided:
+				continue
+			case !taken || jump.Count == 1:
+				removed[i] = true
+				continue
+			case jump.condition.kind != jumpAlwaysKind:
+				always := NewJump(jump.GetId(), jump.Count, jumpAlways)
+				always.condition = jumpCondition{kind: jumpAlwaysKind}
+				replaced[i] = always
+			}
+			// The instructions skipped by the jump are unreachable unless they
+			// are the target of another jump.
+			for j := i + 1; j < i+jump.Count && j < len(instructions) && !jumpTargets[j]; j++ {
+				removed[j] = true
+			}
+			continue
+		}
+		value, folded := o.fold(inst)
+		if !folded {
+			continue
+		}
+		id := inst.GetId()
+		if mov, isMov := inst.(*MovInst); isMov {
+			id = mov.ToExprId
+		}
+		// The result of the program is the value of its last instruction, so
+		// the last instruction is replaced rather than removed.
+		last := i == len(instructions)-1
+		switch {
+		case value == nil && !last:
+			removed[i] = true
+		case value != nil:
+			o.literals[id] = value
+			o.constants.SetValue(id, value)
+			if last {
+				replaced[i] = NewLiteral(id, value)
+			} else {
+				removed[i] = true
+			}
+		}
+	}
+	if len(removed) == 0 && len(replaced) == 0 {
+		return instructions, false
+	}
+	return removeInstructions(instructions, removed, replaced), true
+}
+
+// decide returns whether the jump is taken, and false if that depends on the
+// evaluation.
+func (o *optimizer) decide(jump *JumpInst) (bool, bool) {
+	switch jump.condition.kind {
+	case jumpAlwaysKind:
+		return true, true
+	case customJumpKind:
+		return false, false
+	}
+	if _, isConst := o.constant(jump.condition.exprId); !isConst {
+		return false, false
+	}
+	return jump.OnCondition(o.constants), true
+}
+
+// fold returns the constant value of an instruction, or true with a nil value
+// for a move which has no effect, and false if the instruction may not be
+// folded.
+func (o *optimizer) fold(inst Instruction) (ref.Value, bool) {
+	switch inst := inst.(type) {
+	case *CallExpr:
+		if o.writes[inst.GetId()] != 1 {
+			return nil, false
+		}
+		return o.foldCall(inst)
+	case *CreateListExpr:
+		if o.writes[inst.GetId()] != 1 {
+			return nil, false
+		}
+		elems := make([]ref.Value, len(inst.Elements))
+		for i, id := range inst.Elements {
+			elem, isConst := o.constant(id)
+			if !isConst {
+				return nil, false
+			}
+			elems[i] = elem
+		}
+		return types.NewDynamicList(elems), true
+	case *CreateMapExpr:
+		if o.writes[inst.GetId()] != 1 {
+			return nil, false
+		}
+		entries := make(map[ref.Value]ref.Value, len(inst.KeyValues))
+		for keyId, valueId := range inst.KeyValues {
+			key, isConst := o.constant(keyId)
+			if !isConst {
+				return nil, false
+			}
+			value, isConst := o.constant(valueId)
+			if !isConst {
+				return nil, false
+			}
+			entries[key] = value
+		}
+		return types.NewDynamicMap(entries), true
+	case *MovInst:
+		if inst.GetId() == inst.ToExprId {
+			return nil, true
+		}
+		if o.writes[inst.ToExprId] != 1 {
+			return nil, false
+		}
+		return o.constant(inst.GetId())
+	}
+	return nil, false
+}
+
+// foldCall returns the constant value of a call.
+func (o *optimizer) foldCall(call *CallExpr) (ref.Value, bool) {
+	if _, isDefault := o.dispatcher.(*defaultDispatcher); !isDefault ||
+		len(call.Args) == 0 {
+		return nil, false
+	}
+	args := make([]ref.Value, len(call.Args))
+	allConst := true
+	for i, id := range call.Args {
+		args[i], _ = o.constant(id)
+		allConst = allConst && args[i] != nil
+	}
+	switch call.Function {
+	case overloads.Iterator, overloads.HasNext, overloads.Next:
+		// Iterators hold the state of a comprehension.
+		return nil, false
+	case operators.LogicalAnd, operators.LogicalOr:
+		// Either side decides the result when it is the short-circuit value,
+		// whatever the value of the other side, even an error or unknown.
+		shortCircuit := types.Bool(call.Function == operators.LogicalOr)
+		if len(args) == 2 && (args[0] == shortCircuit || args[1] == shortCircuit) {
+			return shortCircuit, true
+		}
+	case operators.Conditional:
+		if len(args) == 3 && args[0] == types.True && args[1] != nil {
+			return args[1], true
+		}
+		if len(args) == 3 && args[0] == types.False && args[2] != nil {
+			return args[2], true
+		}
+	}
+	if !allConst || isVolatile(o.dispatcher, call.Function, call.Overload) {
+		return nil, false
+	}
+	result := o.dispatcher.Dispatch(&CallContext{call: call, args: args})
+	if types.IsUnknownOrError(result) {
+		return nil, false
+	}
+	return result, true
+}
+
+// constant returns the value of a register which no instruction writes.
+func (o *optimizer) constant(id int64) (ref.Value, bool) {
+	if o.writes[id] != 0 {
+		return nil, false
+	}
+	value, found := o.literals[id]
+	return value, found
+}
+
+// removeInstructions removes and replaces instructions, and adjusts the counts
+// of the remaining jumps to the new instruction offsets. The targets of the
+// removed instructions become the instructions which follow them.
+func removeInstructions(instructions []Instruction,
+	removed map[int]bool,
+	replaced map[int]Instruction) []Instruction {
+	// The extra offset accounts for jumps to the end of the program.
+	offsets := make([]int, len(instructions)+1)
+	var kept []Instruction
+	for i, inst := range instructions {
+		offsets[i] = len(kept)
+		if removed[i] {
+			continue
+		}
+		if replacement, found := replaced[i]; found {
+			inst = replacement
+		}
+		kept = append(kept, inst)
+	}
+	offsets[len(instructions)] = len(kept)
+	for i, inst := range instructions {
+		if removed[i] {
+			continue
+		}
+		if replacement, found := replaced[i]; found {
+			inst = replacement
+		}
+		jump, isJump := inst.(*JumpInst)
+		if !isJump {
+			continue
+		}
+		target := i + jump.Count
+		if target < 0 || target > len(instructions) {
+			continue
+		}
+		adjusted := *jump
+		adjusted.Count = offsets[target] - offsets[i]
+		kept[offsets[i]] = &adjusted
+	}
+	return kept
+}
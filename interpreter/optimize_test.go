@@ -0,0 +1,112 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/common/types"
+)
+
+func TestOptimize(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"a": 3,
+		"b": []int{1, 2, 3},
+		"c": "x"})
+	for _, tst := range []struct {
+		text     string
+		expected interface{}
+		// remaining is the number of instructions left by the optimizer.
+		remaining int
+	}{
+		{text: `[1, 2].size() + 1 == a`, expected: true, remaining: 2},
+		{text: `true || a`, expected: true, remaining: 1},
+		{text: `false && a == 1`, expected: false, remaining: 1},
+		{text: `false || a == 3`, expected: true, remaining: 3},
+		{text: `a == 1 && false`, expected: false},
+		{text: `1 + 2 > 2 ? c : 'y'`, expected: "x", remaining: 2},
+		{text: `1 + 2 < 2 ? c : 'y' + 'z'`, expected: "yz", remaining: 1},
+		{text: `{'k': [1, 2]}['k'][1] * a`, expected: 6, remaining: 2},
+		{text: `b.exists(x, x == 1 + 2)`, expected: true},
+		{text: `b.map(x, x * (2 - 1))[2] == a`, expected: true},
+		// Errors are not folded, so that they are reported on evaluation.
+		{text: `1 / 0 == 1 || a == 3`, expected: true},
+	} {
+		for _, opts := range [][]ProgramOption{
+			{},
+			{FuseInstructions()},
+			{TreeEvaluation()},
+		} {
+			program := parsedProgram(t, tst.text)
+			for _, opt := range opts {
+				opt(program.(*exprProgram))
+			}
+			optimized := Optimize(program, dispatcher())
+			result, _ := interpreter.NewInterpretable(optimized).Eval(activation)
+			if expected := types.NativeToValue(tst.expected); result.Equal(expected) != types.True {
+				t.Errorf("%s: got %v with %+v, wanted %v",
+					tst.text, result, optimized.Config(), expected)
+			}
+			if tst.remaining == 0 || len(opts) != 0 {
+				continue
+			}
+			if remaining := len(optimized.(*exprProgram).instructions); remaining != tst.remaining {
+				t.Errorf("%s: got %d instructions, wanted %d:\n%v",
+					tst.text, remaining, tst.remaining, optimized)
+			}
+		}
+	}
+}
+
+func TestOptimize_Initialized(t *testing.T) {
+	program := parsedProgram(t, `a + (1 + 2)`)
+	interpretable := interpreter.NewInterpretable(program)
+	instructions := program.(*exprProgram).instructions
+	optimized := Optimize(program, dispatcher())
+	if remaining := len(optimized.(*exprProgram).instructions); remaining != 2 {
+		t.Errorf("Got %d instructions, wanted 2:\n%v", remaining, optimized)
+	}
+	if len(program.(*exprProgram).instructions) != len(instructions) {
+		t.Errorf("Got %d instructions after optimization, wanted %d",
+			len(program.(*exprProgram).instructions), len(instructions))
+	}
+	activation := NewActivation(map[string]interface{}{"a": 1})
+	for _, interpretable := range []Interpretable{
+		interpretable,
+		interpreter.NewInterpretable(optimized),
+	} {
+		if result, _ := interpretable.Eval(activation); result != types.Int(4) {
+			t.Errorf("Got %v, wanted 4", result)
+		}
+	}
+}
+
+func TestOptimize_CustomDispatcher(t *testing.T) {
+	program := parsedProgram(t, `1 + 2 == a`)
+	optimized := Optimize(program, &testDispatcher{dispatcher()})
+	if remaining := len(optimized.(*exprProgram).instructions); remaining != 3 {
+		t.Errorf("Got %d instructions, wanted 3:\n%v", remaining, optimized)
+	}
+}
+
+func TestOptimize_Config(t *testing.T) {
+	program := parsedProgram(t, `a`)
+	if program.Config().Optimized {
+		t.Error("Got an optimized program, wanted an unoptimized one")
+	}
+	if !Optimize(program, dispatcher()).Config().Optimized {
+		t.Error("Got an unoptimized program, wanted an optimized one")
+	}
+}
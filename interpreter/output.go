@@ -0,0 +1,113 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"reflect"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// OutputTransformer converts the result of an evaluation, e.g. to coerce it
+// to a bool or to a particular native type, so that the callers of a program
+// need not each convert its results.
+type OutputTransformer func(result ref.Value) ref.Value
+
+// Truthiness is the policy by which BoolOutput coerces results to bools.
+type Truthiness int
+
+const (
+	// StrictTruthiness reports an error for a result other than a bool, an
+	// error, or an unknown.
+	StrictTruthiness Truthiness = iota
+
+	// FailClosedTruthiness coerces every result other than true to false,
+	// including errors and unknowns, e.g. for authorization decisions which
+	// must deny access unless the expression grants it.
+	FailClosedTruthiness
+
+	// ZeroValueTruthiness coerces null, zero numbers, and empty strings,
+	// bytes, lists, and maps to false, and other values to true. Errors and
+	// unknowns are not coerced.
+	ZeroValueTruthiness
+)
+
+// BoolOutput returns an OutputTransformer which coerces results to bools by
+// the given policy.
+func BoolOutput(policy Truthiness) OutputTransformer {
+	return func(result ref.Value) ref.Value {
+		if policy == FailClosedTruthiness {
+			return types.Bool(result == types.True)
+		}
+		if types.IsBool(result) || types.IsUnknownOrError(result) {
+			return result
+		}
+		if policy == StrictTruthiness {
+			return types.NewErr("expected a bool result, got '%s'", result.Type().TypeName())
+		}
+		return types.Bool(!isZeroValue(result))
+	}
+}
+
+// isZeroValue returns whether a value is null, a zero number, or empty.
+func isZeroValue(value ref.Value) bool {
+	switch v := value.(type) {
+	case types.Null:
+		return true
+	case types.Int:
+		return v == 0
+	case types.Uint:
+		return v == 0
+	case types.Double:
+		return v == 0
+	case traits.Sizer:
+		return v.Size() == types.Int(0)
+	}
+	return false
+}
+
+// ConvertOutput returns an OutputTransformer which converts results to the
+// native type, as by ref.Value.ConvertToNative, and adapts the converted value
+// so that it is available from the Value of the result, e.g. to convert a map
+// to a google.protobuf.Struct. A result which may not be converted is replaced
+// by an error, while errors and unknowns are not converted.
+func ConvertOutput(typeDesc reflect.Type) OutputTransformer {
+	return func(result ref.Value) ref.Value {
+		if types.IsUnknownOrError(result) {
+			return result
+		}
+		native, err := result.ConvertToNative(typeDesc)
+		if err != nil {
+			return types.NewErr("%v", err)
+		}
+		return types.NativeToValue(native)
+	}
+}
+
+// outputMiddleware returns the Middleware which applies the transformers to
+// the results of evaluations, in order.
+func outputMiddleware(transformers []OutputTransformer) Middleware {
+	return func(next EvalFunc) EvalFunc {
+		return func(activation Activation) (ref.Value, EvalState) {
+			result, state := next(activation)
+			for _, transform := range transformers {
+				result = transform(result)
+			}
+			return result, state
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+func TestBoolOutput(t *testing.T) {
+	for _, tst := range []struct {
+		text       string
+		strict     string
+		failClosed string
+		zeroValue  string
+	}{
+		{text: `true`, strict: "true", failClosed: "true", zeroValue: "true"},
+		{text: `1 > 2`, strict: "false", failClosed: "false", zeroValue: "false"},
+		{text: `1 / 0`,
+			strict: "divide by zero", failClosed: "false", zeroValue: "divide by zero"},
+		{text: `x`, strict: "unknown [1] (x)", failClosed: "false",
+			zeroValue: "unknown [1] (x)"},
+		{text: `0`,
+			strict: "expected a bool result, got 'int'", failClosed: "false", zeroValue: "false"},
+		{text: `'abc'`,
+			strict: "expected a bool result, got 'string'", failClosed: "false", zeroValue: "true"},
+		{text: `[]`,
+			strict: "expected a bool result, got 'list'", failClosed: "false", zeroValue: "false"},
+		{text: `{'k': 1}`,
+			strict: "expected a bool result, got 'map'", failClosed: "false", zeroValue: "true"},
+		{text: `null`,
+			strict: "expected a bool result, got 'null_type'", failClosed: "false", zeroValue: "false"},
+	} {
+		for policy, expected := range map[Truthiness]string{
+			StrictTruthiness:     tst.strict,
+			FailClosedTruthiness: tst.failClosed,
+			ZeroValueTruthiness:  tst.zeroValue,
+		} {
+			for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+				program := parsedProgram(t, tst.text)
+				for _, opt := range append(opts, TransformOutput(BoolOutput(policy))) {
+					opt(program.(*exprProgram))
+				}
+				result, _ := interpreter.NewInterpretable(program).Eval(
+					NewActivation(map[string]interface{}{}))
+				if fmt.Sprint(result) != expected {
+					t.Errorf("%s: got %v with policy %d and %+v, wanted %s",
+						tst.text, result, policy, program.Config(), expected)
+				}
+			}
+		}
+	}
+}
+
+func TestConvertOutput(t *testing.T) {
+	program := parsedProgram(t, `{'allowed': a}`)
+	TransformOutput(ConvertOutput(reflect.TypeOf(&structpb.Struct{})))(program.(*exprProgram))
+	interpretable := interpreter.NewInterpretable(program)
+	result, _ := interpretable.Eval(NewActivation(map[string]interface{}{"a": true}))
+	st, isStruct := result.Value().(*structpb.Struct)
+	if !isStruct || !st.Fields["allowed"].GetBoolValue() {
+		t.Errorf("Got %v, wanted a struct with an allowed field", result)
+	}
+	result, _ = interpretable.Eval(NewActivation(map[string]interface{}{}))
+	if !types.IsUnknown(result) {
+		t.Errorf("Got %v, wanted an unknown", result)
+	}
+
+	program = parsedProgram(t, `a`)
+	TransformOutput(ConvertOutput(reflect.TypeOf(&structpb.Struct{})))(program.(*exprProgram))
+	result, _ = interpreter.NewInterpretable(program).Eval(
+		NewActivation(map[string]interface{}{"a": 1}))
+	if !types.IsError(result) {
+		t.Errorf("Got %v, wanted a conversion error", result)
+	}
+}
+
+func TestTransformOutput_Middleware(t *testing.T) {
+	var observed ref.Value
+	observe := func(next EvalFunc) EvalFunc {
+		return func(activation Activation) (ref.Value, EvalState) {
+			result, state := next(activation)
+			observed = result
+			return result, state
+		}
+	}
+	program := parsedProgram(t, `a + 1`)
+	double := func(result ref.Value) ref.Value {
+		return result.(traits.Adder).Add(result)
+	}
+	for _, opt := range []ProgramOption{
+		TransformOutput(double),
+		EvalMiddleware(observe),
+		TransformOutput(BoolOutput(ZeroValueTruthiness)),
+	} {
+		opt(program.(*exprProgram))
+	}
+	result, _ := interpreter.NewInterpretable(program).Eval(
+		NewActivation(map[string]interface{}{"a": 1}))
+	if result != types.True || observed != types.True {
+		t.Errorf("Got %v observed as %v, wanted true", result, observed)
+	}
+	if config := program.Config(); config.OutputTransformers != 2 || config.Middleware != 1 {
+		t.Errorf("Got %+v, wanted two transformers and one middleware", config)
+	}
+}
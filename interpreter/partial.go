@@ -0,0 +1,475 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter/partialpb"
+	"github.com/google/cel-spec/proto/v1/eval"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+	"github.com/google/cel-spec/proto/v1/value"
+)
+
+// PartialResult is the state of an evaluation which produced an unknown value
+// because some of the attributes referenced by the expression were not
+// provided. The partial result may be shipped to another process which holds
+// the missing attributes in order to resume the evaluation there:
+//
+//     // Stage one.
+//     result, state := interpretable.Eval(activation)
+//     if types.IsUnknown(result) {
+//         partial, err := NewPartialResult(parsed, state)
+//         serialized, err := MarshalPartialResult(partial)
+//         ...
+//     }
+//
+//     // Stage two.
+//     partial, err := UnmarshalPartialResult(serialized)
+//     program := NewProgram(partial.Residual.Expr, partial.Residual.SourceInfo)
+//     resumed, err := partial.Activation(activation)
+//     result, _ := interpreter.NewInterpretable(program).Eval(resumed)
+type PartialResult struct {
+	// Residual is the expression which remains to be evaluated, where the
+	// sub-expressions whose values were known have been folded into literals.
+	Residual *expr.ParsedExpr
+
+	// State holds the values of the attributes referenced by the residual
+	// expression by expression id. Attributes which were unknown are recorded
+	// as unknown sets, and attributes which were known, but whose values could
+	// not be folded into literals, such as lists and messages, are recorded
+	// with their values.
+	State *eval.EvalState
+}
+
+// NewPartialResult creates a PartialResult from the expression and the
+// EvalState produced by evaluating it to an unknown value.
+func NewPartialResult(parsed *expr.ParsedExpr,
+	state EvalState) (*PartialResult, error) {
+	residual, _ := pruneAst(astpb.FromExpr(parsed.Expr), state)
+	builder := &partialBuilder{
+		resolve: func(e *ast.Expr, name string) (*eval.ExprValue, error) {
+			val, found := state.Value(state.GetRuntimeExpressionId(e.Id))
+			if found && types.IsUnknown(val) {
+				return unknownExprValue(e.Id), nil
+			}
+			if _, isIdent := e.Kind.(*ast.Ident); !isIdent ||
+				!found || val == nil || types.IsError(val) {
+				return nil, nil
+			}
+			v, err := toValueProto(val)
+			if err != nil {
+				return nil, fmt.Errorf("attribute '%s': %v", name, err)
+			}
+			return &eval.ExprValue{Kind: &eval.ExprValue_Value{Value: v}}, nil
+		},
+		scopes: make(map[string]int),
+		result: &eval.EvalState{}}
+	if err := builder.visit(residual); err != nil {
+		return nil, err
+	}
+	return &PartialResult{
+		Residual: &expr.ParsedExpr{
+			Expr:       astpb.ToExpr(residual),
+			SourceInfo: parsed.SourceInfo},
+		State: builder.result}, nil
+}
+
+// UnknownAttributes returns the sorted names of the attributes which were
+// unknown, such as 'a' or 'a.b.c', and which must be supplied to resume the
+// evaluation.
+func (r *PartialResult) UnknownAttributes() []string {
+	attributes := residualAttributes(r.Residual)
+	var names []string
+	for _, result := range r.State.GetResults() {
+		if result.Value < 0 || result.Value >= int64(len(r.State.GetValues())) {
+			continue
+		}
+		exprValue := r.State.GetValues()[result.Value]
+		if _, isUnknown := exprValue.GetKind().(*eval.ExprValue_Unknown); isUnknown {
+			if name, found := attributes[result.Expr]; found {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Activation returns an Activation which resolves the attributes which were
+// known when the partial result was created, and otherwise resolves names
+// from the given activation, which supplies the attributes that were unknown.
+func (r *PartialResult) Activation(activation Activation) (Activation, error) {
+	attributes := residualAttributes(r.Residual)
+	bindings := make(map[string]interface{})
+	for _, result := range r.State.GetResults() {
+		if result.Value < 0 || result.Value >= int64(len(r.State.GetValues())) {
+			return nil, fmt.Errorf("value index out of range: %d", result.Value)
+		}
+		exprValue := r.State.GetValues()[result.Value]
+		v, isValue := exprValue.GetKind().(*eval.ExprValue_Value)
+		if !isValue {
+			continue
+		}
+		name, found := attributes[result.Expr]
+		if !found {
+			return nil, fmt.Errorf("no attribute with expression id: %d", result.Expr)
+		}
+		val, err := fromValueProto(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		bindings[name] = val
+	}
+	return NewHierarchicalActivation(activation, NewActivation(bindings)), nil
+}
+
+// MarshalPartialResult serializes a PartialResult to bytes in the form of
+// the partialpb.PartialResult message.
+func MarshalPartialResult(r *PartialResult) ([]byte, error) {
+	attributes := residualAttributes(r.Residual)
+	bindings := make(map[string]*value.Value)
+	for _, result := range r.State.GetResults() {
+		if result.Value < 0 || result.Value >= int64(len(r.State.GetValues())) {
+			continue
+		}
+		exprValue := r.State.GetValues()[result.Value]
+		if v, isValue := exprValue.GetKind().(*eval.ExprValue_Value); isValue {
+			if name, found := attributes[result.Expr]; found {
+				bindings[name] = v.Value
+			}
+		}
+	}
+	return proto.Marshal(&partialpb.PartialResult{
+		Residual:          r.Residual,
+		UnknownAttributes: r.UnknownAttributes(),
+		Bindings:          bindings})
+}
+
+// UnmarshalPartialResult loads a PartialResult serialized with
+// MarshalPartialResult.
+func UnmarshalPartialResult(serialized []byte) (*PartialResult, error) {
+	data := &partialpb.PartialResult{}
+	if err := proto.Unmarshal(serialized, data); err != nil {
+		return nil, err
+	}
+	if data.Residual == nil {
+		return nil, fmt.Errorf("partial result has no residual expression")
+	}
+	unknowns := make(map[string]bool)
+	for _, name := range data.UnknownAttributes {
+		unknowns[name] = true
+	}
+	builder := &partialBuilder{
+		resolve: func(e *ast.Expr, name string) (*eval.ExprValue, error) {
+			if unknowns[name] {
+				return unknownExprValue(e.Id), nil
+			}
+			if _, isIdent := e.Kind.(*ast.Ident); isIdent {
+				if v, found := data.Bindings[name]; found {
+					return &eval.ExprValue{Kind: &eval.ExprValue_Value{Value: v}}, nil
+				}
+			}
+			return nil, nil
+		},
+		scopes: make(map[string]int),
+		result: &eval.EvalState{}}
+	if err := builder.visit(astpb.FromExpr(data.Residual.Expr)); err != nil {
+		return nil, err
+	}
+	return &PartialResult{
+		Residual: data.Residual,
+		State:    builder.result}, nil
+}
+
+// partialBuilder records the values of the attributes of a residual
+// expression.
+type partialBuilder struct {
+	// resolve returns the value to record for an attribute of the residual
+	// expression, or nil when the attribute has no value to record.
+	resolve func(e *ast.Expr, name string) (*eval.ExprValue, error)
+	// Counts of the comprehension variables in scope, by name.
+	scopes map[string]int
+	result *eval.EvalState
+}
+
+func (b *partialBuilder) visit(e *ast.Expr) error {
+	if e == nil {
+		return nil
+	}
+	if name, root, found := attributeName(e); found && b.scopes[root] == 0 {
+		exprValue, err := b.resolve(e, name)
+		if err != nil {
+			return err
+		}
+		if exprValue != nil {
+			b.record(e.Id, exprValue)
+			return nil
+		}
+		if _, isIdent := e.Kind.(*ast.Ident); isIdent {
+			return nil
+		}
+	}
+	switch kind := e.Kind.(type) {
+	case *ast.Select:
+		return b.visit(kind.Operand)
+	case *ast.Call:
+		if err := b.visit(kind.Target); err != nil {
+			return err
+		}
+		for _, arg := range kind.Args {
+			if err := b.visit(arg); err != nil {
+				return err
+			}
+		}
+	case *ast.CreateList:
+		for _, elem := range kind.Elements {
+			if err := b.visit(elem); err != nil {
+				return err
+			}
+		}
+	case *ast.CreateStruct:
+		for _, entry := range kind.Entries {
+			if err := b.visit(entry.MapKey); err != nil {
+				return err
+			}
+			if err := b.visit(entry.Value); err != nil {
+				return err
+			}
+		}
+	case *ast.Comprehension:
+		if err := b.visit(kind.IterRange); err != nil {
+			return err
+		}
+		if err := b.visit(kind.AccuInit); err != nil {
+			return err
+		}
+		b.scopes[kind.IterVar]++
+		b.scopes[kind.AccuVar]++
+		defer func() {
+			b.scopes[kind.IterVar]--
+			b.scopes[kind.AccuVar]--
+		}()
+		for _, step := range []*ast.Expr{
+			kind.LoopCondition, kind.LoopStep, kind.Result} {
+			if err := b.visit(step); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *partialBuilder) record(id int64, exprValue *eval.ExprValue) {
+	b.result.Results = append(b.result.Results, &eval.EvalState_Result{
+		Expr:  id,
+		Value: int64(len(b.result.Values))})
+	b.result.Values = append(b.result.Values, exprValue)
+}
+
+// unknownExprValue returns the value recorded for an unknown attribute.
+func unknownExprValue(id int64) *eval.ExprValue {
+	return &eval.ExprValue{
+		Kind: &eval.ExprValue_Unknown{
+			Unknown: &eval.UnknownSet{Exprs: []int64{id}}}}
+}
+
+// attributeName returns the qualified name formed by a chain of selects on an
+// identifier, along with the name of the identifier.
+func attributeName(e *ast.Expr) (string, string, bool) {
+	switch kind := e.Kind.(type) {
+	case *ast.Ident:
+		return kind.Name, kind.Name, true
+	case *ast.Select:
+		if kind.TestOnly {
+			return "", "", false
+		}
+		if name, root, found := attributeName(kind.Operand); found {
+			return name + "." + kind.Field, root, true
+		}
+	}
+	return "", "", false
+}
+
+// residualAttributes returns the names of the attributes of the residual
+// expression by expression id.
+func residualAttributes(residual *expr.ParsedExpr) map[int64]string {
+	attributes := make(map[int64]string)
+	var visit func(e *ast.Expr)
+	visit = func(e *ast.Expr) {
+		if e == nil {
+			return
+		}
+		if name, _, found := attributeName(e); found {
+			attributes[e.Id] = name
+		}
+		switch kind := e.Kind.(type) {
+		case *ast.Select:
+			visit(kind.Operand)
+		case *ast.Call:
+			visit(kind.Target)
+			for _, arg := range kind.Args {
+				visit(arg)
+			}
+		case *ast.CreateList:
+			for _, elem := range kind.Elements {
+				visit(elem)
+			}
+		case *ast.CreateStruct:
+			for _, entry := range kind.Entries {
+				visit(entry.MapKey)
+				visit(entry.Value)
+			}
+		case *ast.Comprehension:
+			for _, child := range []*ast.Expr{kind.IterRange, kind.AccuInit,
+				kind.LoopCondition, kind.LoopStep, kind.Result} {
+				visit(child)
+			}
+		}
+	}
+	visit(astpb.FromExpr(residual.GetExpr()))
+	return attributes
+}
+
+// toValueProto converts a ref.Value which is neither an error nor unknown to
+// its protocol buffer representation.
+func toValueProto(val ref.Value) (*value.Value, error) {
+	switch val.Type() {
+	case types.NullType:
+		return &value.Value{Kind: &value.Value_NullValue{}}, nil
+	case types.BoolType:
+		return &value.Value{
+			Kind: &value.Value_BoolValue{BoolValue: bool(val.(types.Bool))}}, nil
+	case types.IntType:
+		return &value.Value{
+			Kind: &value.Value_Int64Value{Int64Value: int64(val.(types.Int))}}, nil
+	case types.UintType:
+		return &value.Value{
+			Kind: &value.Value_Uint64Value{Uint64Value: uint64(val.(types.Uint))}}, nil
+	case types.DoubleType:
+		return &value.Value{
+			Kind: &value.Value_DoubleValue{DoubleValue: float64(val.(types.Double))}}, nil
+	case types.StringType:
+		return &value.Value{
+			Kind: &value.Value_StringValue{StringValue: string(val.(types.String))}}, nil
+	case types.BytesType:
+		return &value.Value{
+			Kind: &value.Value_BytesValue{BytesValue: []byte(val.(types.Bytes))}}, nil
+	case types.ListType:
+		list := val.(traits.Lister)
+		listValue := &value.ListValue{}
+		size, isInt := list.Size().(types.Int)
+		if !isInt {
+			return nil, fmt.Errorf("unsupported list size: %v", list.Size())
+		}
+		for i := types.Int(0); i < size; i++ {
+			elem, err := toValueProto(list.Get(i))
+			if err != nil {
+				return nil, err
+			}
+			listValue.Values = append(listValue.Values, elem)
+		}
+		return &value.Value{Kind: &value.Value_ListValue{ListValue: listValue}}, nil
+	case types.MapType:
+		m := val.(traits.Mapper)
+		mapValue := &value.MapValue{}
+		for it := m.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			k, err := toValueProto(key)
+			if err != nil {
+				return nil, err
+			}
+			v, err := toValueProto(m.Get(key))
+			if err != nil {
+				return nil, err
+			}
+			mapValue.Entries = append(mapValue.Entries,
+				&value.MapValue_Entry{Key: k, Value: v})
+		}
+		return &value.Value{Kind: &value.Value_MapValue{MapValue: mapValue}}, nil
+	}
+	if types.IsError(val) || types.IsUnknown(val) {
+		return nil, fmt.Errorf("unsupported value: %v", val)
+	}
+	msg, isMessage := val.Value().(proto.Message)
+	if !isMessage {
+		return nil, fmt.Errorf("unsupported value type: %s", val.Type())
+	}
+	any, err := ptypes.MarshalAny(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &value.Value{Kind: &value.Value_ObjectValue{ObjectValue: any}}, nil
+}
+
+// fromValueProto converts the protocol buffer representation of a value to a
+// ref.Value.
+func fromValueProto(v *value.Value) (ref.Value, error) {
+	switch kind := v.GetKind().(type) {
+	case *value.Value_NullValue:
+		return types.NullValue, nil
+	case *value.Value_BoolValue:
+		return types.Bool(kind.BoolValue), nil
+	case *value.Value_Int64Value:
+		return types.Int(kind.Int64Value), nil
+	case *value.Value_Uint64Value:
+		return types.Uint(kind.Uint64Value), nil
+	case *value.Value_DoubleValue:
+		return types.Double(kind.DoubleValue), nil
+	case *value.Value_StringValue:
+		return types.String(kind.StringValue), nil
+	case *value.Value_BytesValue:
+		return types.Bytes(kind.BytesValue), nil
+	case *value.Value_ListValue:
+		elems := make([]ref.Value, len(kind.ListValue.GetValues()))
+		for i, elem := range kind.ListValue.GetValues() {
+			val, err := fromValueProto(elem)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = val
+		}
+		return types.NewValueList(elems), nil
+	case *value.Value_MapValue:
+		entries := make(map[ref.Value]ref.Value)
+		for _, entry := range kind.MapValue.GetEntries() {
+			key, err := fromValueProto(entry.Key)
+			if err != nil {
+				return nil, err
+			}
+			val, err := fromValueProto(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = val
+		}
+		return types.NewDynamicMap(entries), nil
+	case *value.Value_ObjectValue:
+		val := types.NativeToValue(kind.ObjectValue)
+		if types.IsError(val) {
+			return nil, fmt.Errorf("invalid object value: %v", val)
+		}
+		return val, nil
+	}
+	return nil, fmt.Errorf("unsupported value kind: %T", v.GetKind())
+}
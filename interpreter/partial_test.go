@@ -0,0 +1,133 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/cel-go/common/debug"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter/partialpb"
+	"github.com/google/cel-go/parser"
+	"github.com/google/cel-go/test"
+)
+
+func TestPartialResult_Resume(t *testing.T) {
+	parsed, errors := parser.ParseText(
+		`user.name == 'alice' && role in roles && request.size < limit`)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	// Stage one knows the user, roles, and limit.
+	program := NewProgram(parsed.Expr, parsed.SourceInfo)
+	result, state := interpreter.NewInterpretable(program).Eval(
+		NewActivation(map[string]interface{}{
+			"user":  map[string]string{"name": "alice"},
+			"roles": []string{"admin", "dev"},
+			"limit": 10}))
+	if !types.IsUnknown(result) {
+		t.Fatalf("Got %v, wanted unknown", result)
+	}
+	partial, err := NewPartialResult(parsed, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serialized, err := MarshalPartialResult(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := &partialpb.PartialResult{}
+	if err := proto.Unmarshal(serialized, message); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(message.UnknownAttributes, []string{"request.size", "role"}) {
+		t.Errorf("Got serialized unknown attributes %v", message.UnknownAttributes)
+	}
+	if _, found := message.Bindings["roles"]; !found || len(message.Bindings) != 1 {
+		t.Errorf("Got serialized bindings %v, wanted roles", message.Bindings)
+	}
+
+	// Stage two knows the role and the request.
+	partial, err = UnmarshalPartialResult(serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	residual := debug.ToDebugString(partial.Residual.Expr)
+	expected := `_&&_(
+  _in_(
+    role,
+    roles
+  ),
+  _<_(
+    request.size,
+    10
+  )
+)`
+	if residual != expected {
+		t.Errorf("Got residual:\n%s\nwanted:\n%s", residual, expected)
+	}
+	unknowns := partial.UnknownAttributes()
+	if !reflect.DeepEqual(unknowns, []string{"request.size", "role"}) {
+		t.Errorf("Got unknown attributes %v", unknowns)
+	}
+	for _, tst := range []struct {
+		role     string
+		expected types.Bool
+	}{
+		{role: "dev", expected: types.True},
+		{role: "guest", expected: types.False},
+	} {
+		// The roles known to stage one take precedence.
+		activation, err := partial.Activation(NewActivation(
+			map[string]interface{}{
+				"role":    tst.role,
+				"roles":   []string{"guest"},
+				"request": map[string]int{"size": 5}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resumed := NewProgram(partial.Residual.Expr, partial.Residual.SourceInfo)
+		result, _ = interpreter.NewInterpretable(resumed).Eval(activation)
+		if result != tst.expected {
+			t.Errorf("%s: got %v, wanted %v", tst.role, result, tst.expected)
+		}
+	}
+}
+
+func TestPartialResult_Objects(t *testing.T) {
+	parsed, errors := parser.ParseText(`msg.single_int64 + x > 2`)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	program := NewProgram(parsed.Expr, parsed.SourceInfo)
+	_, state := interpreter.NewInterpretable(program).Eval(
+		NewActivation(map[string]interface{}{
+			"msg": &test.TestAllTypes{SingleInt64: 1}}))
+	partial, err := NewPartialResult(parsed, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activation, err := partial.Activation(
+		NewActivation(map[string]interface{}{"x": 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := NewProgram(partial.Residual.Expr, partial.Residual.SourceInfo)
+	if result, _ := interpreter.NewInterpretable(resumed).Eval(activation); result != types.True {
+		t.Errorf("Got %v, wanted true", result)
+	}
+}
@@ -0,0 +1,882 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"context"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter/functions"
+)
+
+// planned is a node of the tree planned from a program's expression. Each kind
+// of expression has its own node implementation, so evaluation dispatches
+// directly to the code for the node rather than switching on the kind of each
+// instruction.
+type planned interface {
+	eval(f *treeFrame, activation Activation) ref.Value
+}
+
+// treeInterpretable evaluates the tree planned from a program. Its semantics
+// match those of the instruction stepper, including short-circuiting, error
+// and unknown propagation, and the values recorded within the EvalState.
+type treeInterpretable struct {
+	interpreter *exprInterpreter
+	program     *exprProgram
+	// initial is the state of the initialized program, which is copied for
+	// each evaluation.
+	initial         *defaultEvalState
+	root            planned
+	cancelInterval  uint
+	costLimit       uint64
+	divisionDefault *divisionDefaults
+	dynDispatch     OverloadMismatch
+	iterationLimit  uint64
+	maxErrors       int
+	propagateNull   bool
+	redactErrors    bool
+	sampler         *traceSampler
+	// observer is notified of the value of each expression, whose kinds are
+	// held by id in kinds.
+	observer EvalObserver
+	kinds    map[int64]InstructionKind
+}
+
+func (t *treeInterpretable) Eval(activation Activation) (ref.Value, EvalState) {
+	return t.evalWith(activation, t.initial.clone())
+}
+
+func (t *treeInterpretable) NewState() EvalState {
+	return t.initial.clone()
+}
+
+func (t *treeInterpretable) EvalWithState(activation Activation,
+	state EvalState) (ref.Value, EvalState) {
+	s := state.(*defaultEvalState)
+	s.reset(t.initial)
+	return t.evalWith(activation, s)
+}
+
+// evalWith evaluates the activation into a state copied from the initial state.
+func (t *treeInterpretable) evalWith(activation Activation,
+	state *defaultEvalState) (ref.Value, EvalState) {
+	f := &treeFrame{tree: t, state: state}
+	f.state.trace = t.sampler.sample()
+	if t.cancelInterval != 0 {
+		f.ctx = activationContext(activation)
+		if f.ctx != nil && done(f.ctx) {
+			return evalCancelled(f.ctx), f.state
+		}
+	}
+	result := t.root.eval(f, activation)
+	switch {
+	case f.exceeded():
+		return costLimitExceeded(t.costLimit), f.state
+	case f.iterationsExceeded():
+		return iterationLimitExceeded(t.iterationLimit), f.state
+	case f.cancelled:
+		return evalCancelled(f.ctx), f.state
+	}
+	return result, f.state
+}
+
+// treeFrame holds the state of a single evaluation of the tree, so that the
+// nodes of the tree are not modified by evaluation and the tree may be
+// evaluated concurrently.
+type treeFrame struct {
+	tree  *treeInterpretable
+	state *defaultEvalState
+	// ctx is the context of the evaluation, when it is checked for
+	// cancellation, and cancelled is set once the context is found to be done.
+	ctx        context.Context
+	iterations uint
+	cancelled  bool
+	// visited is the number of elements visited by the comprehensions of
+	// the evaluation.
+	visited uint64
+}
+
+// exceeded returns whether the cost of the evaluation exceeds the limit. The
+// evaluation of a comprehension stops once the limit is exceeded.
+func (f *treeFrame) exceeded() bool {
+	return f.tree.costLimit != 0 && f.state.cost > f.tree.costLimit
+}
+
+// iterationsExceeded returns whether the comprehensions of the evaluation
+// visited more elements than the limit.
+func (f *treeFrame) iterationsExceeded() bool {
+	return f.tree.iterationLimit != 0 && f.visited > f.tree.iterationLimit
+}
+
+// interrupted counts an iteration of a comprehension, and returns whether the
+// evaluation should stop as its cost or iterations exceed their limits or its
+// context is done. The context is checked at the interval configured by
+// CheckCancellation.
+func (f *treeFrame) interrupted() bool {
+	if f.ctx != nil && !f.cancelled {
+		if f.iterations++; f.iterations%f.tree.cancelInterval == 0 {
+			f.cancelled = done(f.ctx)
+		}
+	}
+	return f.cancelled || f.exceeded() || f.iterationsExceeded()
+}
+
+// record charges for the evaluation of an expression and associates its value
+// with the expression id within the EvalState, in the same manner as
+// exprInterpretable.setValue, and returns the recorded value.
+func (f *treeFrame) record(id int64, value ref.Value) ref.Value {
+	f.state.cost++
+	return f.store(id, value)
+}
+
+// chargeSizes charges for the sizes of the arguments of a call of the
+// function when its work grows with their sizes.
+func (f *treeFrame) chargeSizes(function string, args ...ref.Value) {
+	if sizedCall(function) {
+		for _, arg := range args {
+			f.state.cost = addCost(f.state.cost, sizeCost(arg))
+		}
+	}
+}
+
+// store associates a value with an expression id within the EvalState.
+func (f *treeFrame) store(id int64, value ref.Value) ref.Value {
+	switch v := value.(type) {
+	case *types.Err:
+		if _, found := v.ExprId(); !found {
+			v = locateError(f.tree.program, v, id)
+			if f.tree.redactErrors {
+				v = v.Redact()
+			}
+			value = v
+		}
+	case types.ErrorSet:
+		if f.tree.maxErrors > 0 && len(v) > f.tree.maxErrors {
+			value = types.MergeErrors(v[:f.tree.maxErrors])
+		}
+	}
+	f.state.attributes.track(id)
+	f.state.trace.add(id, value)
+	f.state.SetValue(id, value)
+	if f.tree.observer != nil {
+		f.tree.observer.Observe(id, f.tree.kinds[id], value)
+	}
+	return value
+}
+
+// newTreeInterpretable plans the expression of an initialized program.
+func newTreeInterpretable(i *exprInterpreter, p *exprProgram,
+	state *defaultEvalState) *treeInterpretable {
+	t := &treeInterpretable{
+		interpreter:     i,
+		program:         p,
+		initial:         state,
+		cancelInterval:  p.cancelInterval,
+		costLimit:       p.costLimit,
+		divisionDefault: p.divisionDefault,
+		dynDispatch:     p.dynDispatch,
+		iterationLimit:  p.iterationLimit,
+		maxErrors:       p.maxErrors,
+		propagateNull:   p.propagateNull,
+		redactErrors:    p.redactErrors,
+		sampler:         newTraceSampler(p)}
+	if p.observer != nil {
+		t.observer = p.observer
+		t.kinds = expressionKinds(p.expression)
+	}
+	planner := &treePlanner{
+		tree:   t,
+		walker: &astWalker{dispatcher: i.dispatcher},
+		scope:  newScope()}
+	t.root = planner.plan(p.expression)
+	return t
+}
+
+// treePlanner builds the tree of an expression.
+type treePlanner struct {
+	tree   *treeInterpretable
+	walker *astWalker
+	// scope holds the names of the comprehension variables in scope.
+	scope *blockScope
+}
+
+func (p *treePlanner) plan(e *ast.Expr) planned {
+	// Literals are seeded when the program is initialized, with compiled
+	// constant arguments, such as glob and regex patterns, taking the place
+	// of their expressions.
+	if value, found := p.tree.program.compiled[e.Id]; found {
+		return &constNode{value: value}
+	}
+	if value, found := p.tree.program.literals[e.Id]; found {
+		return &constNode{value: value}
+	}
+	switch kind := e.Kind.(type) {
+	case *ast.Ident:
+		if _, found := p.scope.ref(kind.Name); found {
+			return &varNode{id: e.Id, name: kind.Name}
+		}
+		return &identNode{tree: p.tree, id: e.Id, name: kind.Name}
+	case *ast.Select:
+		return p.planSelect(e, kind)
+	case *ast.Call:
+		return p.planCall(e, kind)
+	case *ast.CreateList:
+		elems := make([]planned, len(kind.Elements))
+		for i, elem := range kind.Elements {
+			elems[i] = p.plan(elem)
+		}
+		list := &listNode{id: e.Id, elems: elems}
+		// Lists of constants, e.g. the ranges of comprehensions, are built
+		// once, when the tree is planned, and recorded within the initial
+		// state, though their construction is charged to each evaluation.
+		for _, elem := range elems {
+			if _, isConst := elem.(*constNode); !isConst {
+				return list
+			}
+		}
+		initial := &treeFrame{tree: p.tree, state: p.tree.initial}
+		return &constNode{value: list.eval(initial, nil), cost: 1}
+	case *ast.CreateStruct:
+		return p.planStruct(e, kind)
+	case *ast.Comprehension:
+		return p.planComprehension(e, kind)
+	}
+	return &constNode{value: types.NewErr("unsupported expression: %v", e)}
+}
+
+func (p *treePlanner) planSelect(e *ast.Expr, sel *ast.Select) planned {
+	operand := p.plan(sel.Operand)
+	// Only the longest chain of selections is resolved as a whole.
+	if qualified, isQualified := operand.(*qualifiedNode); isQualified {
+		operand = qualified.selection
+	}
+	node := &selectNode{
+		tree:     p.tree,
+		id:       e.Id,
+		operand:  operand,
+		field:    types.String(sel.Field),
+		testOnly: sel.TestOnly}
+	// A select whose operand is unknown may, in fact, refer to a qualified
+	// identifier name when the expression was not type-checked.
+	if qname, found := qualifiedName(e); found && !sel.TestOnly {
+		node.candidates = p.tree.interpreter.packager.ResolveCandidateNames(qname)
+	}
+	if name, path, found := p.qualifiedPath(e); found {
+		return &qualifiedNode{id: e.Id, name: name, path: path, selection: node}
+	}
+	return node
+}
+
+// qualifiedPath returns the identifier and the fields of a chain of
+// selections from a variable which is not a comprehension variable.
+func (p *treePlanner) qualifiedPath(e *ast.Expr) (string, []Qualifier, bool) {
+	var path []Qualifier
+	for {
+		switch kind := e.Kind.(type) {
+		case *ast.Select:
+			if kind.TestOnly {
+				return "", nil, false
+			}
+			path = append([]Qualifier{{Field: kind.Field}}, path...)
+			e = kind.Operand
+		case *ast.Ident:
+			if _, found := p.scope.ref(kind.Name); found {
+				return "", nil, false
+			}
+			return kind.Name, path, true
+		default:
+			return "", nil, false
+		}
+	}
+}
+
+func (p *treePlanner) planCall(e *ast.Expr, call *ast.Call) planned {
+	if static, found := p.walker.staticCall(call); found {
+		call = static
+	}
+	args := getArgs(call)
+	planArgs := make([]planned, len(args))
+	for i, arg := range args {
+		planArgs[i] = p.plan(arg)
+	}
+	dispatcher := p.tree.interpreter.dispatcher
+	overload, _ := dispatcher.FindOverload(call.Function)
+	switch call.Function {
+	case operators.LogicalAnd, operators.LogicalOr:
+		if overload != nil && overload.Binary != nil && len(args) == 2 {
+			return &logicalNode{
+				id:           e.Id,
+				lhs:          planArgs[0],
+				rhs:          planArgs[1],
+				shortCircuit: types.Bool(call.Function == operators.LogicalOr),
+				op:           overload.Binary}
+		}
+	case operators.Conditional:
+		if overload != nil && overload.Function != nil && len(args) == 3 {
+			d, _ := dispatcher.(*defaultDispatcher)
+			return &conditionalNode{
+				id:         e.Id,
+				dispatcher: d,
+				args:       planArgs,
+				argIds:     []int64{args[0].Id, args[1].Id, args[2].Id},
+				condition:  overload.Function}
+		}
+	case operators.Index:
+		if _, isDefault := dispatcher.(*defaultDispatcher); isDefault && len(args) == 2 {
+			if elemType, found := indexElemType(p.tree.program.typeMap[args[0].Id]); found {
+				return &indexNode{
+					id:       e.Id,
+					operand:  planArgs[0],
+					index:    planArgs[1],
+					elemType: elemType}
+			}
+		}
+	}
+	argIds := make([]int64, len(args))
+	for i, arg := range args {
+		argIds[i] = arg.Id
+	}
+	node := &callNode{
+		tree:     p.tree,
+		id:       e.Id,
+		function: call.Function,
+		args:     planArgs,
+		strict:   checkIsStrict(call.Function),
+		call:     NewCall(e.Id, call.Function, argIds)}
+	// Calls through the default dispatcher use the overload directly, so that
+	// no CallContext is created on evaluation, unless the overload is
+	// called with its context or is resolved from the operands at runtime.
+	if d, isDefault := dispatcher.(*defaultDispatcher); isDefault &&
+		(overload == nil && len(d.functions[call.Function]) == 0 ||
+			overload != nil && overload.Contextual == nil) {
+		node.overload = overload
+		node.direct = true
+		if overload != nil {
+			node.unary = overload.Unary
+			node.binary = overload.Binary
+		}
+	}
+	return node
+}
+
+func (p *treePlanner) planStruct(e *ast.Expr, str *ast.CreateStruct) planned {
+	values := make([]planned, len(str.Entries))
+	for i, entry := range str.Entries {
+		values[i] = p.plan(entry.Value)
+	}
+	if str.MessageName == "" {
+		keys := make([]planned, len(str.Entries))
+		for i, entry := range str.Entries {
+			keys[i] = p.plan(entry.MapKey)
+		}
+		return &mapNode{id: e.Id, keys: keys, values: values}
+	}
+	fields := make([]string, len(str.Entries))
+	for i, entry := range str.Entries {
+		fields[i] = entry.FieldKey
+	}
+	typeName := str.MessageName
+	provider := p.tree.interpreter.typeProvider
+	for _, candidate := range p.tree.interpreter.packager.ResolveCandidateNames(typeName) {
+		if _, found := provider.FindType(candidate); found {
+			typeName = candidate
+			break
+		}
+	}
+	return &objectNode{
+		tree:     p.tree,
+		id:       e.Id,
+		typeName: typeName,
+		fields:   fields,
+		values:   values}
+}
+
+func (p *treePlanner) planComprehension(e *ast.Expr, comp *ast.Comprehension) planned {
+	node := &comprehensionNode{
+		id:        e.Id,
+		iterRange: p.plan(comp.IterRange),
+		accuVar:   comp.AccuVar,
+		iterVar:   comp.IterVar}
+	scope := newScope()
+	scope.setRef(comp.AccuVar, comp.AccuInit.Id)
+	scope.setRef(comp.IterVar, comp.IterRange.Id)
+	scope.parent = p.scope
+	p.scope = scope
+	node.accuInit = p.plan(comp.AccuInit)
+	node.condition = p.plan(comp.LoopCondition)
+	node.step = p.plan(comp.LoopStep)
+	node.result = p.plan(comp.Result)
+	p.scope = scope.parent
+	return node
+}
+
+// constNode is a literal value.
+type constNode struct {
+	value ref.Value
+	// cost is charged for the constant, e.g. for a list built when the tree
+	// was planned.
+	cost uint64
+}
+
+func (n *constNode) eval(f *treeFrame, _ Activation) ref.Value {
+	f.state.cost += n.cost
+	return n.value
+}
+
+// identNode resolves an identifier from the activation or the type provider.
+type identNode struct {
+	tree *treeInterpretable
+	id   int64
+	name string
+}
+
+func (n *identNode) eval(f *treeFrame, activation Activation) ref.Value {
+	if val, found := activation.ResolveName(n.name); found {
+		return f.record(n.id, val)
+	}
+	if val, found := n.tree.interpreter.typeProvider.FindIdent(n.name); found {
+		return f.record(n.id, val)
+	}
+	return f.record(n.id, types.NewUnknownAttribute(n.id, n.name))
+}
+
+// varNode resolves a comprehension variable. As with the instruction stepper,
+// the values of the variables are neither recorded at the ids of their
+// references nor charged to the cost of the evaluation.
+type varNode struct {
+	id   int64
+	name string
+}
+
+func (n *varNode) eval(f *treeFrame, activation Activation) ref.Value {
+	if val, found := activation.ResolveName(n.name); found {
+		return val
+	}
+	return types.NewUnknownAttribute(n.id, n.name)
+}
+
+// selectNode selects a field from its operand, or tests for its presence.
+type selectNode struct {
+	tree       *treeInterpretable
+	id         int64
+	operand    planned
+	field      types.String
+	testOnly   bool
+	candidates []string
+}
+
+func (n *selectNode) eval(f *treeFrame, activation Activation) ref.Value {
+	operand := n.operand.eval(f, activation)
+	if n.testOnly {
+		return f.record(n.id, n.testField(operand))
+	}
+	if indexer, isIndexer := operand.(traits.Indexer); isIndexer &&
+		operand.Type().HasTrait(traits.IndexerType) {
+		return f.record(n.id, indexer.Get(n.field))
+	}
+	switch {
+	case types.IsError(operand):
+		return f.record(n.id, operand)
+	case types.IsUnknown(operand):
+		return f.record(n.id, n.resolveUnknown(
+			types.MergeUnknowns(operand).(*types.UnknownSet), activation))
+	case n.tree.propagateNull && operand.Type() == types.NullType:
+		return f.record(n.id, types.NullValue)
+	}
+	return f.record(n.id, types.NewErr("invalid operand in select"))
+}
+
+func (n *selectNode) testField(operand ref.Value) ref.Value {
+	switch {
+	case types.IsError(operand) || types.IsUnknown(operand):
+		return operand
+	case operand.Type().HasTrait(traits.FieldTesterType):
+		return operand.(traits.FieldTester).IsSet(n.field)
+	case operand.Type() == types.MapType:
+		return operand.(traits.Container).Contains(n.field)
+	case n.tree.propagateNull && operand.Type() == types.NullType:
+		return types.False
+	}
+	return types.NewErr("invalid operand in presence test")
+}
+
+// resolveUnknown resolves the qualified name formed by the select, as for
+// exprInterpretable.resolveUnknown.
+func (n *selectNode) resolveUnknown(unknown *types.UnknownSet,
+	activation Activation) ref.Value {
+	if object, found := activation.ResolveReference(n.id); found {
+		return object
+	}
+	if n.candidates == nil {
+		return unknown.Select(n.id, string(n.field))
+	}
+	for _, candidate := range n.candidates {
+		if object, found := activation.ResolveName(candidate); found {
+			return object
+		}
+		if identVal, found := n.tree.interpreter.typeProvider.FindIdent(candidate); found {
+			return identVal
+		}
+	}
+	return unknown.Select(n.id, string(n.field))
+}
+
+// qualifiedNode resolves a chain of selections from a variable with a
+// QualifiedResolver, and otherwise evaluates the selections one at a time.
+type qualifiedNode struct {
+	id        int64
+	name      string
+	path      []Qualifier
+	selection *selectNode
+}
+
+func (n *qualifiedNode) eval(f *treeFrame, activation Activation) ref.Value {
+	if value, found := resolveQualified(activation, n.name, n.path); found {
+		return f.record(n.id, value)
+	}
+	return n.selection.eval(f, activation)
+}
+
+// callNode invokes a function.
+type callNode struct {
+	tree     *treeInterpretable
+	id       int64
+	function string
+	args     []planned
+	strict   bool
+	call     *CallExpr
+	// direct is true when the overload is invoked without the dispatcher,
+	// in which case overload is the overload for the function, if any.
+	direct   bool
+	overload *functions.Overload
+	// unary and binary are the implementations of the overload for calls
+	// with one or two arguments, which are invoked without allocating a
+	// slice of arguments.
+	unary  functions.UnaryOp
+	binary functions.BinaryOp
+}
+
+func (n *callNode) eval(f *treeFrame, activation Activation) ref.Value {
+	switch {
+	case len(n.args) == 1 && n.unary != nil:
+		arg := n.args[0].eval(f, activation)
+		if n.strict && types.IsUnknownOrError(arg) {
+			return f.record(n.id, arg)
+		}
+		if !arg.Type().HasTrait(n.overload.OperandTrait) {
+			return f.record(n.id, n.mismatch(types.NewNoSuchOverloadErr(),
+				[]ref.Value{arg}, activation))
+		}
+		f.chargeSizes(n.function, arg)
+		return f.record(n.id, n.unary(arg))
+	case len(n.args) == 2 && n.binary != nil:
+		lhs := n.args[0].eval(f, activation)
+		rhs := n.args[1].eval(f, activation)
+		if n.strict {
+			if invalid := mergeInvalid(mergeInvalid(nil, lhs), rhs); invalid != nil {
+				return f.record(n.id, invalid)
+			}
+		}
+		f.chargeSizes(n.function, lhs, rhs)
+		var result ref.Value
+		if lhs.Type().HasTrait(n.overload.OperandTrait) {
+			result = n.binary(lhs, rhs)
+		} else {
+			result = types.NewNoSuchOverloadErr()
+		}
+		if types.IsError(result) {
+			if value, found := n.tree.divisionDefault.of(n.function,
+				[]ref.Value{lhs, rhs}); found {
+				result = value
+			} else {
+				result = n.mismatch(result, []ref.Value{lhs, rhs}, activation)
+			}
+		}
+		return f.record(n.id, result)
+	}
+	args := make([]ref.Value, len(n.args))
+	var invalid ref.Value
+	for i, arg := range n.args {
+		args[i] = arg.eval(f, activation)
+		if n.strict {
+			invalid = mergeInvalid(invalid, args[i])
+		}
+	}
+	if invalid != nil {
+		return f.record(n.id, invalid)
+	}
+	f.chargeSizes(n.function, args...)
+	result := n.invoke(args, activation)
+	if types.IsError(result) {
+		if value, found := n.tree.divisionDefault.of(n.function, args); found {
+			result = value
+		}
+	}
+	return f.record(n.id, n.mismatch(result, args, activation))
+}
+
+// invoke calls the function with the evaluated arguments.
+func (n *callNode) invoke(args []ref.Value, activation Activation) ref.Value {
+	if n.direct {
+		return n.dispatch(args)
+	}
+	return n.tree.interpreter.dispatcher.Dispatch(&CallContext{
+		call:       n.call,
+		activation: activation,
+		args:       args,
+		metadata:   n.tree.program.Metadata()})
+}
+
+// mismatch applies the DynDispatch behavior of the program to the result of
+// the call, as for exprInterpretable.evalCall.
+func (n *callNode) mismatch(result ref.Value, args []ref.Value,
+	activation Activation) ref.Value {
+	if n.direct {
+		d := n.tree.interpreter.dispatcher.(*defaultDispatcher)
+		result = d.describeMismatch(n.function, args, result)
+	}
+	if n.tree.dynDispatch == ErrorOnMismatch || !types.IsError(result) {
+		return result
+	}
+	return resolveMismatch(n.tree.dynDispatch, result, args,
+		func(args []ref.Value) ref.Value {
+			return n.invoke(args, activation)
+		})
+}
+
+// dispatch invokes the overload in the same manner as
+// defaultDispatcher.Dispatch.
+func (n *callNode) dispatch(args []ref.Value) ref.Value {
+	if n.overload != nil {
+		if result, invoked := invokeOverload(n.overload, args, nil); invoked {
+			return result
+		}
+	}
+	if len(args) != 0 && args[0].Type().HasTrait(traits.ReceiverType) {
+		return args[0].(traits.Receiver).Receive(n.function, n.call.Overload, args[1:])
+	}
+	return types.NewNoSuchOverloadErr()
+}
+
+// logicalNode evaluates a logical and or or, which only evaluates its
+// right-hand side when the left-hand side does not determine the result.
+type logicalNode struct {
+	id  int64
+	lhs planned
+	rhs planned
+	// shortCircuit is the value of the left-hand side which determines the
+	// result: true for a logical or, and false for a logical and.
+	shortCircuit types.Bool
+	op           functions.BinaryOp
+}
+
+func (n *logicalNode) eval(f *treeFrame, activation Activation) ref.Value {
+	lhs := n.lhs.eval(f, activation)
+	if lhs == n.shortCircuit {
+		return f.record(n.id, n.shortCircuit)
+	}
+	return f.record(n.id, n.op(lhs, n.rhs.eval(f, activation)))
+}
+
+// conditionalNode evaluates the branch selected by its condition.
+type conditionalNode struct {
+	id        int64
+	args      []planned
+	argIds    []int64
+	condition functions.FunctionOp
+	// dispatcher describes conditions which are not bools, when the overload
+	// is that of the default dispatcher.
+	dispatcher *defaultDispatcher
+}
+
+func (n *conditionalNode) eval(f *treeFrame, activation Activation) ref.Value {
+	cond := n.args[0].eval(f, activation)
+	switch cond {
+	case types.True:
+		return f.record(n.id, n.args[1].eval(f, activation))
+	case types.False:
+		return f.record(n.id, n.args[2].eval(f, activation))
+	}
+	// Neither branch is evaluated when the condition is unknown or an error.
+	trueVal := ref.Value(types.NewUnknownSet(n.argIds[1]))
+	falseVal := ref.Value(types.NewUnknownSet(n.argIds[2]))
+	if !types.IsUnknownOrError(cond) {
+		trueVal = n.args[1].eval(f, activation)
+		falseVal = n.args[2].eval(f, activation)
+	}
+	result := n.condition(cond, trueVal, falseVal)
+	if n.dispatcher != nil {
+		result = n.dispatcher.describeMismatch(operators.Conditional,
+			[]ref.Value{cond, trueVal, falseVal}, result)
+	}
+	return f.record(n.id, result)
+}
+
+// indexNode indexes a list or map whose element type is known at check time.
+type indexNode struct {
+	id       int64
+	operand  planned
+	index    planned
+	elemType ref.Type
+}
+
+func (n *indexNode) eval(f *treeFrame, activation Activation) ref.Value {
+	operand := n.operand.eval(f, activation)
+	index := n.index.eval(f, activation)
+	if types.IsUnknownOrError(operand) {
+		return f.record(n.id, operand)
+	}
+	if types.IsUnknownOrError(index) {
+		return f.record(n.id, index)
+	}
+	if elem, found := indexNative(operand.Value(), index, n.elemType); found {
+		return f.record(n.id, elem)
+	}
+	if !operand.Type().HasTrait(traits.IndexerType) {
+		return f.record(n.id, types.NewNoSuchOverloadErr())
+	}
+	return f.record(n.id, operand.(traits.Indexer).Get(index))
+}
+
+// listNode creates a list.
+type listNode struct {
+	id    int64
+	elems []planned
+}
+
+func (n *listNode) eval(f *treeFrame, activation Activation) ref.Value {
+	elems := make([]ref.Value, len(n.elems))
+	var invalid ref.Value
+	for i, elem := range n.elems {
+		elems[i] = elem.eval(f, activation)
+		invalid = mergeInvalid(invalid, elems[i])
+	}
+	if invalid != nil {
+		return f.record(n.id, invalid)
+	}
+	return f.record(n.id, types.NewDynamicList(elems))
+}
+
+// mapNode creates a map.
+type mapNode struct {
+	id     int64
+	keys   []planned
+	values []planned
+}
+
+func (n *mapNode) eval(f *treeFrame, activation Activation) ref.Value {
+	entries := make(map[ref.Value]ref.Value, len(n.keys))
+	var invalid ref.Value
+	for i, key := range n.keys {
+		k := key.eval(f, activation)
+		v := n.values[i].eval(f, activation)
+		invalid = mergeInvalid(mergeInvalid(invalid, k), v)
+		entries[k] = v
+	}
+	if invalid != nil {
+		return f.record(n.id, sortErrors(invalid))
+	}
+	return f.record(n.id, types.NewDynamicMap(entries))
+}
+
+// objectNode creates an object of a type resolved when the tree is planned.
+type objectNode struct {
+	tree     *treeInterpretable
+	id       int64
+	typeName string
+	fields   []string
+	values   []planned
+}
+
+func (n *objectNode) eval(f *treeFrame, activation Activation) ref.Value {
+	fields := make(map[string]ref.Value, len(n.fields))
+	var invalid ref.Value
+	for i, field := range n.fields {
+		val := n.values[i].eval(f, activation)
+		invalid = mergeInvalid(invalid, val)
+		fields[field] = val
+	}
+	if invalid != nil {
+		return f.record(n.id, sortErrors(invalid))
+	}
+	return f.record(n.id,
+		n.tree.interpreter.typeProvider.NewValue(n.typeName, fields))
+}
+
+// comprehensionNode evaluates a comprehension.
+type comprehensionNode struct {
+	id        int64
+	iterRange planned
+	accuVar   string
+	iterVar   string
+	accuInit  planned
+	condition planned
+	step      planned
+	result    planned
+}
+
+func (n *comprehensionNode) eval(f *treeFrame, activation Activation) ref.Value {
+	iterRange := n.iterRange.eval(f, activation)
+	vars := &varActivation{parent: activation, accuName: n.accuVar, iterName: n.iterVar}
+	vars.accu = n.accuInit.eval(f, vars)
+	// The loop ends when the range is not iterable, and the loop condition is
+	// tested before each element is visited.
+	if iterable, isIterable := iterRange.(traits.Iterable); isIterable &&
+		iterRange.Type().HasTrait(traits.IterableType) {
+		it := iterable.Iterator()
+		for it.HasNext() == types.True && !f.interrupted() {
+			if n.condition.eval(f, vars) == types.False {
+				break
+			}
+			if f.visited++; f.iterationsExceeded() {
+				break
+			}
+			vars.iter = it.Next()
+			vars.accu = n.step.eval(f, vars)
+		}
+	}
+	return f.store(n.id, n.result.eval(f, vars))
+}
+
+// varActivation binds the variables of a comprehension.
+type varActivation struct {
+	parent   Activation
+	accuName string
+	accu     ref.Value
+	iterName string
+	iter     ref.Value
+}
+
+func (a *varActivation) Parent() Activation {
+	return a.parent
+}
+
+func (a *varActivation) ResolveName(name string) (ref.Value, bool) {
+	switch {
+	case name == a.accuName && a.accu != nil:
+		return a.accu, true
+	case name == a.iterName && a.iter != nil:
+		return a.iter, true
+	}
+	return a.parent.ResolveName(name)
+}
+
+func (a *varActivation) ResolveReference(exprId int64) (ref.Value, bool) {
+	return a.parent.ResolveReference(exprId)
+}
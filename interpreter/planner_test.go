@@ -0,0 +1,175 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/parser"
+	"github.com/google/cel-go/test"
+)
+
+func TestTreeEvaluation(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"a":     1,
+		"b":     true,
+		"s":     "hello",
+		"elems": []int64{1, 2, 3},
+		"m": map[string]interface{}{
+			"k":      "v",
+			"nested": map[string]interface{}{"n": 2}},
+		"x.y": "qualified"})
+	for _, tst := range []struct {
+		text string
+		opts []ProgramOption
+	}{
+		{text: "a + 1 == 2 && s.size() == 5"},
+		{text: "b || a / 0 == 1"},
+		{text: "a / 0 == 1 || b"},
+		{text: "!b && a / 0 == 1"},
+		{text: "a / 0 == 1 && m['x'] == 1"},
+		{text: "b ? s : a / 0"},
+		{text: "a > 1 ? s : m.k"},
+		{text: "s ? 1 : 2"},
+		{text: "elems.exists(e, e == 2)"},
+		{text: "elems.exists_one(e, e > 2)"},
+		{text: "elems.map(e, e * a)"},
+		{text: "elems.filter(e, e % 2 == 1)"},
+		{text: "a.exists(e, e > 0)"},
+		{text: "m.nested.n + m['nested']['n']"},
+		{text: "has(m.k) && !has(m.missing)"},
+		{text: "m.missing"},
+		{text: "x.y"},
+		{text: "unknown.field.name"},
+		{text: "{'a': a, s: [elems[1], 1u, 2.0]}"},
+		{text: "[a / 0, 1, m['x'], a % 0]"},
+		{text: "[a / 0, m['x'], a % 0]", opts: []ProgramOption{MaxErrors(2)}},
+		{text: "a / 0 + 1", opts: []ProgramOption{DivisionByZeroDefault(0, 0)}},
+		{text: "m['ssn'] == 1", opts: []ProgramOption{RedactErrors()}},
+		{text: "s.matches('h.*o') && s.matchesGlob('he*')"},
+		{text: "type(a) == int && string(elems[0]) == '1'"},
+		{text: "coalesce(m.missing, m.other.name, a)"},
+	} {
+		parsed, errors := parser.ParseText(tst.text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		stepped := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(), tst.opts...)
+		planned := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(),
+			append(tst.opts, TreeEvaluation())...)
+		expected, expectedState := interpreter.NewInterpretable(stepped).Eval(activation)
+		res, state := interpreter.NewInterpretable(planned).Eval(activation)
+		if !sameValue(res, expected) {
+			t.Errorf("%s: got '%v', wanted '%v'", tst.text, res, expected)
+		}
+		id := parsed.GetExpr().GetId()
+		value, _ := state.Value(id)
+		expectedValue, _ := expectedState.Value(id)
+		if !sameValue(value, expectedValue) {
+			t.Errorf("%s: got state value '%v', wanted '%v'", tst.text, value, expectedValue)
+		}
+		_, found := state.ErrorLocation(id)
+		_, expectedFound := expectedState.ErrorLocation(id)
+		if found != expectedFound {
+			t.Errorf("%s: got error location %t, wanted %t", tst.text, found, expectedFound)
+		}
+	}
+}
+
+// sameValue reports whether two results are equal, comparing errors and
+// unknowns by their text.
+func sameValue(value, expected ref.Value) bool {
+	if types.IsUnknownOrError(expected) {
+		return fmt.Sprint(value) == fmt.Sprint(expected)
+	}
+	return value != nil && value.Equal(expected) == types.True
+}
+
+func TestTreeEvaluation_Checked(t *testing.T) {
+	program := checkedProgram(t,
+		"elems[1] == 2 && names['b'] == 'z' && flags[0]",
+		decls.NewIdent("elems", decls.NewListType(decls.Int), nil),
+		decls.NewIdent("names", decls.NewMapType(decls.String, decls.String), nil),
+		decls.NewIdent("flags", decls.NewListType(decls.Bool), nil))
+	TreeEvaluation()(program.(*exprProgram))
+	if !program.Config().TreeEvaluation {
+		t.Error("Expected the program to be configured for tree evaluation")
+	}
+	i := interpreter.NewInterpretable(program)
+	if _, isTree := i.(*treeInterpretable); !isTree {
+		t.Fatalf("Got %T, wanted a tree interpretable", i)
+	}
+	res, _ := i.Eval(NewActivation(map[string]interface{}{
+		"elems": []int64{1, 2, 3},
+		"names": map[string]string{"a": "y", "b": "z"},
+		"flags": []bool{true}}))
+	if res != types.True {
+		t.Errorf("Got '%v', wanted 'true'", res)
+	}
+	res, _ = i.Eval(NewActivation(map[string]interface{}{
+		"elems": []int64{1},
+		"names": map[string]string{},
+		"flags": []bool{}}))
+	if !types.IsError(res) {
+		t.Errorf("Got '%v', wanted index out of range error", res)
+	}
+}
+
+func BenchmarkTreeEvaluation_ConditionalExpr(b *testing.B) {
+	// a ? b < 1.0 : c == ["hello"]
+	program := NewProgram(
+		test.Conditional.Expr,
+		test.Conditional.Info(b.Name()),
+		TreeEvaluation())
+	interpretable := interpreter.NewInterpretable(program)
+	activation := NewActivation(map[string]interface{}{
+		"a": types.False,
+		"b": types.Double(0.999),
+		"c": types.NativeToValue([]string{"hello"})})
+	for i := 0; i < b.N; i++ {
+		interpretable.Eval(activation)
+	}
+}
+
+func BenchmarkTreeEvaluation_ComprehensionExpr(b *testing.B) {
+	// [1, 1u, 1.0].exists(x, type(x) == uint)
+	program := NewProgram(
+		test.Exists.Expr,
+		test.Exists.Info(b.Name()),
+		TreeEvaluation())
+	interpretable := interpreter.NewInterpretable(program)
+	activation := NewActivation(map[string]interface{}{})
+	for i := 0; i < b.N; i++ {
+		interpretable.Eval(activation)
+	}
+}
+
+func BenchmarkTreeEvaluation_ComprehensionExprWithInput(b *testing.B) {
+	// elems.exists(x, type(x) == uint)
+	program := NewProgram(
+		test.ExistsWithInput.Expr,
+		test.ExistsWithInput.Info(b.Name()),
+		TreeEvaluation())
+	interpretable := interpreter.NewInterpretable(program)
+	activation := NewActivation(map[string]interface{}{
+		"elems": types.NativeToValue([]interface{}{0, 1, 2, 3, 4, uint(5), 6})})
+	for i := 0; i < b.N; i++ {
+		interpretable.Eval(activation)
+	}
+}
@@ -17,8 +17,14 @@ package interpreter
 import (
 	"fmt"
 	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/pb"
+	"github.com/google/cel-go/common/types/ref"
 	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
 	expr "github.com/google/cel-spec/proto/v1/syntax"
+	"sort"
 	"strings"
 )
 
@@ -45,6 +51,73 @@ type Program interface {
 
 	// Metadata used to determine source locations of sub-expressions.
 	Metadata() Metadata
+
+	// Config returns the options the program was created with.
+	Config() *ProgramConfig
+}
+
+// ProgramConfig describes the options a Program was created with, e.g. for
+// logging and comparing the evaluation behavior of different services.
+type ProgramConfig struct {
+	// Checked is true when the program was created from a checked expression.
+	Checked bool
+
+	FuseInstructions    bool
+	TreeEvaluation      bool
+	PropagateNullSelect bool
+	RedactErrors        bool
+	MaxErrors           int
+	CostLimit           uint64
+	IterationLimit      uint64
+	TrackAttributes     bool
+
+	// Optimized is true when the constant expressions of the program were
+	// folded by Optimize.
+	Optimized bool
+
+	// TraceFraction is the fraction of evaluations which are traced.
+	TraceFraction float64
+
+	// CancellationInterval is the interval at which the context of an
+	// evaluation is checked, or zero when it is not checked.
+	CancellationInterval uint
+
+	// Middleware is the number of middleware through which the program is
+	// evaluated.
+	Middleware int
+
+	// OutputTransformers is the number of transformers applied to the
+	// results of the program.
+	OutputTransformers int
+
+	// Observed is true when the evaluations are observed by an EvalObserver.
+	Observed bool
+
+	// StrictActivation is true when the activations of evaluations are
+	// validated against the declared variables, and RequireDeclared is true
+	// when each of the declared variables must also be bound.
+	StrictActivation bool
+	RequireDeclared  bool
+
+	// IntDivisionByZeroDefault and UintDivisionByZeroDefault are the values
+	// of int and uint division by zero, if configured.
+	IntDivisionByZeroDefault  ref.Value
+	UintDivisionByZeroDefault ref.Value
+
+	// DynDispatch is the behavior of calls which match none of the overloads
+	// of their functions.
+	DynDispatch OverloadMismatch
+
+	// SharedConstants and SharedArena are true when the program uses a
+	// constant pool or an arena supplied by the caller.
+	SharedConstants bool
+	SharedArena     bool
+
+	// Functions holds the sorted names of the functions which were available
+	// to the program when it was initialized, including those of opt-in
+	// overload sets such as functions.MathOverloads. It is only populated for
+	// the Dispatcher created by NewDispatcher.
+	Functions []string
 }
 
 // IntructionStepper steps through program instructions and provides an option
@@ -63,26 +136,353 @@ type InstructionStepper interface {
 }
 
 type exprProgram struct {
-	expression      *expr.Expr
+	arena           *Arena
+	cancelInterval  uint
+	compiled        map[int64]ref.Value
+	constants       *Constants
+	costLimit       uint64
+	divisionDefault *divisionDefaults
+	dynDispatch     OverloadMismatch
+	expression      *ast.Expr
+	functions       []string
+	fuse            bool
 	instructions    []Instruction
+	iterationLimit  uint64
+	literals        map[int64]ref.Value
+	maxErrors       int
+	maxId           int64
 	metadata        Metadata
+	middleware      []Middleware
+	nodes           map[int64]*ast.Expr
+	observer        EvalObserver
+	optimize        bool
+	outputs         []OutputTransformer
+	planned         []Instruction
+	propagateNull   bool
+	redactErrors    bool
+	requireDeclared bool
+	requirements    *Requirements
 	revInstructions map[int64]int
+	sharedConstants bool
+	strictVariables []string
+	trackAttributes bool
+	traceFraction   float64
+	tree            bool
+	typeMap         map[int64]*checkedpb.Type
+}
+
+// ProgramOption configures a Program.
+type ProgramOption func(*exprProgram)
+
+// SharedConstants configures the Program to materialize its literals from the
+// given constant pool rather than a pool private to the program.
+//
+// Programs compiled from the same expression, or from expressions with many
+// literals in common, may share a pool so that each literal is only boxed as
+// a ref.Value once.
+func SharedConstants(constants *Constants) ProgramOption {
+	return func(p *exprProgram) {
+		p.constants = constants
+		p.sharedConstants = constants != nil
+	}
+}
+
+// ProgramArena configures the Program to allocate the data structures created
+// while planning its instructions from the given Arena.
+//
+// The Program must not be used after the arena has been released.
+func ProgramArena(arena *Arena) ProgramOption {
+	return func(p *exprProgram) {
+		p.arena = arena
+	}
+}
+
+// FuseInstructions configures the Program to replace common instruction
+// sequences, such as chains of field selections and comparisons against
+// constants, with superinstructions when the program is initialized.
+//
+// Fusion reduces the number of instructions dispatched during evaluation, but
+// the fused program no longer has a one-to-one correspondence between
+// instructions and expression ids.
+func FuseInstructions() ProgramOption {
+	return func(p *exprProgram) {
+		p.fuse = true
+	}
+}
+
+// TreeEvaluation configures the Interpretable created for the Program to plan
+// the expression into a tree of nodes specialized to each kind of
+// expression, such as identifiers, selections, and calls, and to evaluate
+// the tree directly rather than stepping through the instructions.
+//
+// The results and the values recorded within the EvalState are the same as
+// those of the instruction stepper, though the values of the expressions
+// generated for comprehensions are not recorded. The overloads of the
+// functions are resolved from the Dispatcher when the tree is planned, and
+// the FuseInstructions option has no effect on the evaluation of the tree.
+func TreeEvaluation() ProgramOption {
+	return func(p *exprProgram) {
+		p.tree = true
+	}
+}
+
+// RedactErrors configures the Program to redact the values of the data being
+// evaluated, such as strings and map keys, from the messages of runtime
+// errors. The redacted messages retain the types of the values, and the
+// expression location of each error remains available from the EvalState.
+func RedactErrors() ProgramOption {
+	return func(p *exprProgram) {
+		p.redactErrors = true
+	}
+}
+
+// MaxErrors limits the number of errors aggregated into a types.ErrorSet when
+// an instruction encounters errors in several of its operands. By default,
+// all such errors are retained.
+func MaxErrors(max int) ProgramOption {
+	return func(p *exprProgram) {
+		p.maxErrors = max
+	}
+}
+
+// DivisionByZeroDefault configures the Program to produce the given values,
+// rather than an error, when an int or uint division or modulus has a zero
+// divisor, so that the result is of the type of the operands.
+//
+// By default, such errors propagate as for any other strict operation. The
+// default values are typically zero, though any values may be used.
+func DivisionByZeroDefault(intValue types.Int, uintValue types.Uint) ProgramOption {
+	return func(p *exprProgram) {
+		p.divisionDefault = &divisionDefaults{intValue: intValue, uintValue: uintValue}
+	}
+}
+
+// OverloadMismatch is the behavior of a call whose operands match none of the
+// overloads of its function, such as 'x + 1' where the dyn operand 'x' is a
+// string.
+type OverloadMismatch int
+
+const (
+	// ErrorOnMismatch produces a 'no such overload' error. This is the
+	// default.
+	ErrorOnMismatch OverloadMismatch = iota
+	// NullOnMismatch produces null.
+	NullOnMismatch
+	// CoerceStringsOnMismatch converts the operands of the call to strings
+	// and calls the function again when at least one of the operands is a
+	// string, e.g. so that 'x + 1' is '"a1"' when 'x' is '"a"'. The call
+	// produces the original error when the operands cannot be converted or
+	// still match no overload.
+	CoerceStringsOnMismatch
+)
+
+func (m OverloadMismatch) String() string {
+	switch m {
+	case ErrorOnMismatch:
+		return "error"
+	case NullOnMismatch:
+		return "null"
+	case CoerceStringsOnMismatch:
+		return "coerce strings"
+	}
+	return fmt.Sprintf("OverloadMismatch(%d)", int(m))
+}
+
+// DynDispatch configures the behavior of the Program when the operands of a
+// call match none of the overloads of its function, as may happen for
+// operands which are dyn or for expressions which were not type-checked,
+// e.g. for host applications migrating from expression languages which are
+// more lenient than CEL. By default, the call produces an error.
+//
+// Values of different types compare as unequal rather than mismatching the
+// overloads of equality, so equality is unaffected by the option.
+func DynDispatch(onMismatch OverloadMismatch) ProgramOption {
+	return func(p *exprProgram) {
+		p.dynDispatch = onMismatch
+	}
+}
+
+// CostLimit configures the Interpretable created for the Program to abort an
+// evaluation whose cost exceeds the limit, as described for EstimateCost,
+// with an error. A limit of zero is unlimited.
+//
+// The cost of each evaluation is available from ActualCost, whether or not
+// it is limited.
+func CostLimit(limit uint64) ProgramOption {
+	return func(p *exprProgram) {
+		p.costLimit = limit
+	}
+}
+
+// InterpretableWithIterationLimit configures the Interpretable created for the
+// Program to abort an evaluation with an error once the comprehensions of the
+// expression, such as the 'all', 'exists', and 'map' macros, have visited more
+// than the limit of elements in total, e.g. to bound the evaluation of
+// expressions authored by users over lists of unbounded size. A limit of zero
+// is unlimited.
+func InterpretableWithIterationLimit(limit uint64) ProgramOption {
+	return func(p *exprProgram) {
+		p.iterationLimit = limit
+	}
+}
+
+// TrackAttributes configures the Interpretable created for the Program to
+// record the attributes read by each evaluation within its EvalState, e.g.
+// for audit logging or for deriving cache keys from the inputs which
+// determined a result. The attributes are available from
+// EvalState.Attributes.
+func TrackAttributes() ProgramOption {
+	return func(p *exprProgram) {
+		p.trackAttributes = true
+	}
+}
+
+// SampleTraces configures the Interpretable created for the Program to trace
+// the given fraction of its evaluations, from zero to one, e.g. to collect
+// debugging data from a service without the overhead of tracing every
+// evaluation. The trace of a sampled evaluation is available from EvalTrace,
+// while the other evaluations are not traced.
+func SampleTraces(fraction float64) ProgramOption {
+	return func(p *exprProgram) {
+		p.traceFraction = fraction
+	}
+}
+
+// EvalMiddleware configures the Interpretable created for the Program to
+// evaluate the program through the given middleware, e.g. to log, measure, or
+// cache its evaluations. The first middleware is outermost, and middleware
+// configured by successive options are applied within those configured
+// before.
+func EvalMiddleware(middleware ...Middleware) ProgramOption {
+	return func(p *exprProgram) {
+		p.middleware = append(p.middleware, middleware...)
+	}
+}
+
+// TransformOutput configures the Interpretable created for the Program to
+// transform the result of each evaluation with the given transformers, in
+// order, e.g. to coerce the result to a bool with BoolOutput. The results are
+// transformed before they are returned to the middleware configured by
+// EvalMiddleware.
+func TransformOutput(transformers ...OutputTransformer) ProgramOption {
+	return func(p *exprProgram) {
+		p.outputs = append(p.outputs, transformers...)
+	}
+}
+
+// ObserveEvaluation configures the Interpretable created for the Program to
+// notify the observer of each instruction it evaluates, along with the value
+// the instruction produced, e.g. to show the intermediate values of an
+// expression in a debugger.
+func ObserveEvaluation(observer EvalObserver) ProgramOption {
+	return func(p *exprProgram) {
+		p.observer = observer
+	}
+}
+
+// CheckCancellation configures the Interpretable created for the Program to
+// abort an evaluation with an error once the context of its activation, as
+// supplied by NewContextActivation, is done, so that long-running
+// comprehensions may be interrupted.
+//
+// The context is checked when the evaluation begins and then at the given
+// interval: every interval instructions for the instruction stepper, and
+// every interval iterations of a comprehension for TreeEvaluation. An
+// interval of zero disables the checks.
+func CheckCancellation(interval uint) ProgramOption {
+	return func(p *exprProgram) {
+		p.cancelInterval = interval
+	}
+}
+
+// PropagateNullSelect configures the Program to evaluate the selection of a
+// field from null to null rather than to an error, so that 'a.b.c' is null
+// whenever 'a' or 'a.b' is null. Selecting an absent field still produces an
+// error.
+//
+// Checked expressions which rely on this behavior should be type-checked with
+// the checker.PropagateNullSelect option.
+func PropagateNullSelect() ProgramOption {
+	return func(p *exprProgram) {
+		p.propagateNull = true
+	}
 }
 
 // NewCheckedProgram creates a Program from a checked CEL expression.
-func NewCheckedProgram(c *checkedpb.CheckedExpr) Program {
-	// TODO: take advantage of the type-check information.
-	return NewProgram(c.Expr, c.SourceInfo)
+//
+// The message fields which the expression names by their JSON names, as
+// permitted by the checker.FieldNames option, are selected and set by their
+// proto names.
+func NewCheckedProgram(c *checkedpb.CheckedExpr, opts ...ProgramOption) Program {
+	program := NewProgram(c.Expr, c.SourceInfo, opts...).(*exprProgram)
+	program.typeMap = c.TypeMap
+	resolveFieldNames(program.expression, c.TypeMap)
+	return program
+}
+
+// resolveFieldNames replaces the JSON names of the message fields selected or
+// set within a checked expression with their proto names.
+func resolveFieldNames(e *ast.Expr, typeMap map[int64]*checkedpb.Type) {
+	ast.Visit(e, func(e *ast.Expr, parent *ast.Expr) bool {
+		switch kind := e.Kind.(type) {
+		case *ast.Select:
+			kind.Field = protoFieldName(typeMap[kind.Operand.Id], kind.Field)
+		case *ast.CreateStruct:
+			if kind.MessageName != "" {
+				for _, entry := range kind.Entries {
+					entry.FieldKey = protoFieldName(typeMap[e.Id], entry.FieldKey)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// protoFieldName returns the proto name of a field of a message type which is
+// named by its JSON name, or otherwise the name as it is.
+func protoFieldName(t *checkedpb.Type, name string) string {
+	if t.GetMessageType() == "" {
+		return name
+	}
+	td, err := pb.DescribeType(t.GetMessageType())
+	if err != nil {
+		return name
+	}
+	if _, found := td.FieldByName(name); found {
+		return name
+	}
+	if fd, found := td.FieldByJSONName(name); found {
+		return fd.OrigName()
+	}
+	return name
 }
 
 // NewProgram creates a Program from a CEL expression and source information.
 func NewProgram(expression *expr.Expr,
-	info *expr.SourceInfo) Program {
-	revInstructions := make(map[int64]int)
-	return &exprProgram{
-		expression:      expression,
-		revInstructions: revInstructions,
-		metadata:        newExprMetadata(info)}
+	info *expr.SourceInfo, opts ...ProgramOption) Program {
+	return NewAstProgram(astpb.FromExpr(expression), astpb.FromSourceInfo(info), opts...)
+}
+
+// NewAstProgram creates a Program from the native ast representation of a CEL
+// expression and its source information.
+func NewAstProgram(expression *ast.Expr,
+	info *ast.SourceInfo, opts ...ProgramOption) Program {
+	program := &exprProgram{
+		expression: expression,
+		metadata:   newExprMetadata(info),
+		// The max instruction id is computed as the highest expression id + 1
+		// combined with the number of comprehensions times two. Each
+		// comprehension introduces two generated ids (one for an iterator and
+		// one for current iterator value) once the program is initialized.
+		maxId: maxId(expression) + comprehensionCount(expression)*3}
+	for _, opt := range opts {
+		opt(program)
+	}
+	program.revInstructions = program.arena.newRevMap()
+	if program.constants == nil {
+		program.constants = NewConstants()
+	}
+	return program
 }
 
 func (p *exprProgram) Begin() InstructionStepper {
@@ -93,30 +493,121 @@ func (p *exprProgram) Begin() InstructionStepper {
 }
 
 func (p *exprProgram) GetInstruction(runtimeId int64) Instruction {
-	return p.instructions[p.revInstructions[runtimeId]]
+	inst := p.instructions[p.revInstructions[runtimeId]]
+	if fused, isFused := inst.(fusedInstruction); isFused {
+		for _, component := range fused.components() {
+			if component.GetId() == runtimeId {
+				return component
+			}
+		}
+	}
+	return inst
 }
 
 func (p *exprProgram) Init(dispatcher Dispatcher, state MutableEvalState) {
+	// Constant arguments, such as glob and regex patterns and the lists of
+	// matchers, are compiled once and take the place of the arguments within
+	// the state. Literal arguments remain within the literals, so that the
+	// program may still be serialized.
+	if p.compiled == nil {
+		p.compiled = compileConstants(p.expression, dispatcher)
+	}
 	if p.instructions == nil {
-		p.instructions = WalkExpr(p.expression, p.metadata, dispatcher, state)
-		for i, inst := range p.instructions {
-			p.revInstructions[inst.GetId()] = i
+		p.instructions, p.literals = walkAst(p.expression, p.metadata,
+			dispatcher, state, p.constants, p.arena, p.compiled)
+		if _, isDefault := dispatcher.(*defaultDispatcher); isDefault && p.typeMap != nil {
+			p.instructions = specializeIndexes(p.instructions, p.typeMap)
+		}
+		if p.optimize {
+			p.instructions, p.literals = optimizeInstructions(
+				p.instructions, p.literals, p.maxId, dispatcher)
+			for id, value := range p.literals {
+				state.SetValue(id, value)
+			}
+		}
+		p.requirements = newRequirements(p, p.instructions, dispatcher)
+		p.nodes = make(map[int64]*ast.Expr)
+		ast.Visit(p.expression, func(e *ast.Expr, parent *ast.Expr) bool {
+			p.nodes[e.Id] = e
+			return true
+		})
+	} else {
+		// The program has already been initialized, so only the literal values
+		// need to be seeded into the new state.
+		for id, value := range p.literals {
+			state.SetValue(id, value)
+		}
+	}
+	for id, value := range p.compiled {
+		state.SetValue(id, value)
+	}
+	if p.fuse && p.planned == nil {
+		p.planned = p.instructions
+		p.instructions = fuseInstructions(p.planned, p.literals, dispatcher)
+		p.revInstructions = make(map[int64]int)
+	}
+	if len(p.revInstructions) == 0 {
+		p.indexInstructions()
+	}
+	if d, isDefault := dispatcher.(*defaultDispatcher); isDefault && p.functions == nil {
+		for function := range d.overloads {
+			p.functions = append(p.functions, function)
+		}
+		sort.Strings(p.functions)
+	}
+}
+
+// indexInstructions maps the expression ids of the program to the offsets of
+// the instructions which compute them.
+func (p *exprProgram) indexInstructions() {
+	for i, inst := range p.instructions {
+		p.revInstructions[inst.GetId()] = i
+		if fused, isFused := inst.(fusedInstruction); isFused {
+			for _, component := range fused.components() {
+				p.revInstructions[component.GetId()] = i
+			}
 		}
 	}
 }
 
 func (p *exprProgram) MaxInstructionId() int64 {
-	// The max instruction id is computed as the highest expression id + 1
-	// combined with the number of comprehensions times two. Each comprehension
-	// introduces two generated ids (one for an iterator and one for current
-	// iterator value) once the program is initialized.
-	return maxId(p.expression) + comprehensionCount(p.expression)*2
+	return p.maxId
 }
 
 func (p *exprProgram) Metadata() Metadata {
 	return p.metadata
 }
 
+func (p *exprProgram) Config() *ProgramConfig {
+	config := &ProgramConfig{
+		Checked:              p.typeMap != nil,
+		FuseInstructions:     p.fuse,
+		TreeEvaluation:       p.tree,
+		CostLimit:            p.costLimit,
+		IterationLimit:       p.iterationLimit,
+		TrackAttributes:      p.trackAttributes,
+		Optimized:            p.optimize,
+		TraceFraction:        p.traceFraction,
+		CancellationInterval: p.cancelInterval,
+		Middleware:           len(p.middleware),
+		Observed:             p.observer != nil,
+		StrictActivation:     p.strictVariables != nil,
+		RequireDeclared:      p.requireDeclared,
+		OutputTransformers:   len(p.outputs),
+		PropagateNullSelect:  p.propagateNull,
+		RedactErrors:         p.redactErrors,
+		MaxErrors:            p.maxErrors,
+		DynDispatch:          p.dynDispatch,
+		SharedConstants:      p.sharedConstants,
+		SharedArena:          p.arena != nil,
+		Functions:            p.functions}
+	if p.divisionDefault != nil {
+		config.IntDivisionByZeroDefault = p.divisionDefault.intValue
+		config.UintDivisionByZeroDefault = p.divisionDefault.uintValue
+	}
+	return config
+}
+
 func (p *exprProgram) String() string {
 	instStrs := make([]string, len(p.instructions), len(p.instructions))
 	for i, inst := range p.instructions {
@@ -152,30 +643,56 @@ func (s *exprStepper) JumpCount(count int) bool {
 	return false
 }
 
+// locateError attributes an error which has not yet been associated with an
+// expression to the given id, along with the source location and text of the
+// expression when they are known, e.g. so that a missing field is reported as
+// "no such field 'emial' at line 3, col 14 in 'a.emial'" by Err.Detail.
+func locateError(program Program, err *types.Err, id int64) *types.Err {
+	err = err.WithExprId(id)
+	metadata := program.Metadata()
+	if metadata == nil {
+		return err
+	}
+	location, found := metadata.IdLocation(id)
+	if !found {
+		return err
+	}
+	var snippet string
+	if p, isExprProgram := program.(*exprProgram); isExprProgram {
+		if node, found := p.nodes[id]; found {
+			snippet = ast.Text(node)
+		}
+	}
+	return err.WithLocation(location, snippet)
+}
+
 // The exprMetadata type provides helper functions for retrieving source
 // locations in a human readable manner based on the data contained within
-// the expr.SourceInfo message.
+// the ast.SourceInfo value.
 type exprMetadata struct {
-	info *expr.SourceInfo
+	info *ast.SourceInfo
 }
 
-func newExprMetadata(info *expr.SourceInfo) Metadata {
+func newExprMetadata(info *ast.SourceInfo) Metadata {
+	if info == nil {
+		info = &ast.SourceInfo{}
+	}
 	return &exprMetadata{info: info}
 }
 
 func (m *exprMetadata) IdLocation(exprId int64) (common.Location, bool) {
 	if exprOffset, found := m.IdOffset(exprId); found {
-		var index = 0
-		var lineIndex = 0
-		var lineOffset int32 = 0
-		for index, lineOffset = range m.info.LineOffsets {
+		// Each line offset marks the start of the line which follows it.
+		var line = 1
+		var lineStart int32 = 0
+		for _, lineOffset := range m.info.LineOffsets {
 			if lineOffset > exprOffset {
 				break
 			}
-			lineIndex = index
+			line++
+			lineStart = lineOffset
 		}
-		line := lineIndex + 1
-		column := exprOffset - lineOffset
+		column := exprOffset - lineStart
 		return common.NewLocation(line, int(column)), true
 	}
 	return nil, false
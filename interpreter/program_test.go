@@ -16,6 +16,9 @@ package interpreter
 
 import (
 	"fmt"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/interpreter/functions"
 	"github.com/google/cel-go/test"
 	"testing"
@@ -111,8 +114,59 @@ func TestNewProgram_DynMap(t *testing.T) {
 	fmt.Printf("%s\n%s\n\n", t.Name(), program)
 }
 
-func dispatcher() Dispatcher {
+// dispatcher returns a Dispatcher of the standard overloads and the given
+// overloads, such as those of an extension.
+func dispatcher(overloads ...*functions.Overload) Dispatcher {
 	dispatcher := NewDispatcher()
 	dispatcher.Add(functions.StandardOverloads()...)
+	dispatcher.Add(overloads...)
 	return dispatcher
 }
+
+func TestNewAstProgram_Conditional(t *testing.T) {
+	program := NewProgram(
+		test.Conditional.Expr,
+		test.Conditional.Info(t.Name()))
+	astProgram := NewAstProgram(
+		astpb.FromExpr(test.Conditional.Expr),
+		astpb.FromSourceInfo(test.Conditional.Info(t.Name())))
+	if program.MaxInstructionId() != astProgram.MaxInstructionId() {
+		t.Errorf("Got max instruction id %d, wanted %d",
+			astProgram.MaxInstructionId(), program.MaxInstructionId())
+	}
+	program.Init(dispatcher(), NewEvalState(program.MaxInstructionId()+1))
+	astProgram.Init(dispatcher(), NewEvalState(astProgram.MaxInstructionId()+1))
+	if fmt.Sprint(program) != fmt.Sprint(astProgram) {
+		t.Errorf("Got program:\n%v\nwanted:\n%v", astProgram, program)
+	}
+}
+
+func TestProgram_Config(t *testing.T) {
+	program := NewProgram(
+		test.Conditional.Expr,
+		test.Conditional.Info(t.Name()),
+		FuseInstructions(),
+		MaxErrors(2),
+		DivisionByZeroDefault(0, 0),
+		SharedConstants(NewConstants()))
+	d := dispatcher()
+	d.Add(functions.MathOverloads()...)
+	program.Init(d, NewEvalState(program.MaxInstructionId()+1))
+	config := program.Config()
+	if config.Checked || !config.FuseInstructions || config.RedactErrors ||
+		config.MaxErrors != 2 || config.IntDivisionByZeroDefault != types.Int(0) ||
+		config.UintDivisionByZeroDefault != types.Uint(0) ||
+		!config.SharedConstants || config.SharedArena {
+		t.Errorf("Got config %+v", config)
+	}
+	found := false
+	for _, function := range config.Functions {
+		found = found || function == overloads.MathIsNaN
+	}
+	if !found {
+		t.Errorf("Got functions %v, wanted %s", config.Functions, overloads.MathIsNaN)
+	}
+	if checked := checkedProgram(t, `1 + 1`); !checked.Config().Checked {
+		t.Error("Got unchecked config for checked program")
+	}
+}
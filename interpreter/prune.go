@@ -15,7 +15,10 @@
 package interpreter
 
 import (
-	"github.com/golang/protobuf/ptypes/struct"
+	"fmt"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
 	"github.com/google/cel-go/common/operators"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
@@ -23,8 +26,15 @@ import (
 )
 
 type astPruner struct {
-	expr  *expr.Expr
+	expr  *ast.Expr
 	state EvalState
+	// Values of the sub-expressions which may not be folded into literals,
+	// such as lists and messages, by the names of the identifiers which
+	// replace them, or nil when such values are left to be evaluated again.
+	bindings map[string]ref.Value
+	// Ids of the sub-expressions whose values may not be reused, such as the
+	// calls of Volatile overloads and the expressions which contain them.
+	pinned map[int64]bool
 }
 
 // TODO Consider having a separate walk of the AST that finds common
@@ -56,46 +66,50 @@ type astPruner struct {
 // compiled and constant folded expressions, but is not willing to constant
 // fold(and thus cache results of) some external calls, then they can prepare
 // the overloads accordingly.
-func PruneAst(expr *expr.Expr, state EvalState) *expr.Expr {
+func PruneAst(expression *expr.Expr, state EvalState) *expr.Expr {
+	pruned, _ := pruneAst(astpb.FromExpr(expression), state)
+	return astpb.ToExpr(pruned)
+}
+
+// pruneAst prunes the native representation of an expression and indicates
+// whether any portion of the expression was pruned.
+func pruneAst(expression *ast.Expr, state EvalState) (*ast.Expr, bool) {
 	pruner := &astPruner{
-		expr:  expr,
+		expr:  expression,
 		state: state}
-	newExpr, _ := pruner.prune(expr)
-	return newExpr
+	return pruner.prune(expression)
 }
 
-func (p *astPruner) createLiteral(node *expr.Expr, val *expr.Literal) *expr.Expr {
-	newExpr := *node
-	newExpr.ExprKind = &expr.Expr_LiteralExpr{LiteralExpr: val}
-	return &newExpr
+func (p *astPruner) createLiteral(node *ast.Expr, val interface{}) *ast.Expr {
+	return &ast.Expr{Id: node.Id, Kind: &ast.Literal{Value: val}}
 }
 
-func (p *astPruner) maybePruneAndOr(node *expr.Expr) (*expr.Expr, bool) {
-	if !p.existsWithUnknownValue(node.GetId()) {
+func (p *astPruner) maybePruneAndOr(node *ast.Expr) (*ast.Expr, bool) {
+	if !p.existsWithUnknownValue(node.Id) {
 		return nil, false
 	}
 
-	call := node.GetCallExpr()
+	call := node.Kind.(*ast.Call)
 
 	// We know result is unknown, so we have at least one unknown arg
 	// and if one side is a known value, we know we can ignore it.
-	if p.existsWithKnownValue(call.Args[0].GetId()) {
+	if p.existsWithKnownValue(call.Args[0].Id) {
 		return call.Args[1], true
 	}
 
-	if p.existsWithKnownValue(call.Args[1].GetId()) {
+	if p.existsWithKnownValue(call.Args[1].Id) {
 		return call.Args[0], true
 	}
 	return nil, false
 }
 
-func (p *astPruner) maybePruneConditional(node *expr.Expr) (*expr.Expr, bool) {
-	if !p.existsWithUnknownValue(node.GetId()) {
+func (p *astPruner) maybePruneConditional(node *ast.Expr) (*ast.Expr, bool) {
+	if !p.existsWithUnknownValue(node.Id) {
 		return nil, false
 	}
 
-	call := node.GetCallExpr()
-	condVal, condValueExists := p.value(call.Args[0].GetId())
+	call := node.Kind.(*ast.Call)
+	condVal, condValueExists := p.value(call.Args[0].Id)
 	if !condValueExists || types.IsUnknownOrError(condVal) {
 		return nil, false
 	}
@@ -107,8 +121,8 @@ func (p *astPruner) maybePruneConditional(node *expr.Expr) (*expr.Expr, bool) {
 	}
 }
 
-func (p *astPruner) maybePruneFunction(node *expr.Expr) (*expr.Expr, bool) {
-	call := node.GetCallExpr()
+func (p *astPruner) maybePruneFunction(node *ast.Expr) (*ast.Expr, bool) {
+	call := node.Kind.(*ast.Call)
 	if call.Function == operators.LogicalOr || call.Function == operators.LogicalAnd {
 		return p.maybePruneAndOr(node)
 	}
@@ -119,38 +133,31 @@ func (p *astPruner) maybePruneFunction(node *expr.Expr) (*expr.Expr, bool) {
 	return nil, false
 }
 
-func (p *astPruner) prune(node *expr.Expr) (*expr.Expr, bool) {
+func (p *astPruner) prune(node *ast.Expr) (*ast.Expr, bool) {
 	if node == nil {
 		return node, false
 	}
-	if val, valueExists := p.value(node.GetId()); valueExists && !types.IsUnknownOrError(val) {
+	if val, valueExists := p.value(node.Id); valueExists && !types.IsUnknownOrError(val) {
 
 		// TODO if we have a list or struct, create a list/struct
 		// expression. This is useful especially if these expressions
 		// are result of a function call.
 
 		switch val.Type() {
-		case types.BoolType:
-			return p.createLiteral(node,
-				&expr.Literal{LiteralKind: &expr.Literal_BoolValue{val.Value().(bool)}}), true
-		case types.IntType:
-			return p.createLiteral(node,
-				&expr.Literal{LiteralKind: &expr.Literal_Int64Value{val.Value().(int64)}}), true
-		case types.UintType:
-			return p.createLiteral(node,
-				&expr.Literal{LiteralKind: &expr.Literal_Uint64Value{val.Value().(uint64)}}), true
-		case types.StringType:
-			return p.createLiteral(node,
-				&expr.Literal{LiteralKind: &expr.Literal_StringValue{val.Value().(string)}}), true
-		case types.DoubleType:
-			return p.createLiteral(node,
-				&expr.Literal{LiteralKind: &expr.Literal_DoubleValue{val.Value().(float64)}}), true
-		case types.BytesType:
-			return p.createLiteral(node,
-				&expr.Literal{LiteralKind: &expr.Literal_BytesValue{val.Value().([]byte)}}), true
+		case types.BoolType,
+			types.IntType,
+			types.UintType,
+			types.StringType,
+			types.DoubleType,
+			types.BytesType:
+			return p.createLiteral(node, val.Value()), true
 		case types.NullType:
-			return p.createLiteral(node,
-				&expr.Literal{LiteralKind: &expr.Literal_NullValue{val.Value().(structpb.NullValue)}}), true
+			return p.createLiteral(node, nil), true
+		}
+		if _, isIdent := node.Kind.(*ast.Ident); !isIdent && p.bindings != nil {
+			name := fmt.Sprintf("@snapshot%d", node.Id)
+			p.bindings[name] = val
+			return &ast.Expr{Id: node.Id, Kind: &ast.Ident{Name: name}}, true
 		}
 	}
 
@@ -158,88 +165,85 @@ func (p *astPruner) prune(node *expr.Expr) (*expr.Expr, bool) {
 	// transform, or expression was not evaluated. If possible, drill down
 	// more.
 
-	switch node.ExprKind.(type) {
-	case *expr.Expr_SelectExpr:
-		if operand, pruned := p.prune(node.GetSelectExpr().Operand); pruned {
-			newExpr := *node
-			newSelect := *newExpr.GetSelectExpr()
+	switch kind := node.Kind.(type) {
+	case *ast.Select:
+		if operand, pruned := p.prune(kind.Operand); pruned {
+			newSelect := *kind
 			newSelect.Operand = operand
-			newExpr.GetExprKind().(*expr.Expr_SelectExpr).SelectExpr = &newSelect
-			return &newExpr, true
+			return &ast.Expr{Id: node.Id, Kind: &newSelect}, true
 		}
-	case *expr.Expr_CallExpr:
+	case *ast.Call:
 		if newExpr, pruned := p.maybePruneFunction(node); pruned {
 			newExpr, _ = p.prune(newExpr)
 			return newExpr, true
 		}
-		newCall := *node.GetCallExpr()
+		newCall := *kind
+		newCall.Args = make([]*ast.Expr, len(kind.Args))
 		var prunedCall bool
 		var prunedArg bool
-		for i, arg := range node.GetCallExpr().Args {
+		for i, arg := range kind.Args {
 			if newCall.Args[i], prunedArg = p.prune(arg); prunedArg {
 				prunedCall = true
 			}
 		}
-		if newTarget, prunedTarget := p.prune(node.GetCallExpr().Target); prunedTarget {
+		if newTarget, prunedTarget := p.prune(kind.Target); prunedTarget {
 			prunedCall = true
 			newCall.Target = newTarget
 		}
 		if prunedCall {
-			newExpr := *node
-			newExpr.GetExprKind().(*expr.Expr_CallExpr).CallExpr = &newCall
-			return &newExpr, true
+			return &ast.Expr{Id: node.Id, Kind: &newCall}, true
 		}
-	case *expr.Expr_ListExpr:
-		newList := *node.GetListExpr()
+	case *ast.CreateList:
+		newList := *kind
+		newList.Elements = make([]*ast.Expr, len(kind.Elements))
 		var prunedList bool
 		var prunedElem bool
-		for i, elem := range node.GetListExpr().Elements {
+		for i, elem := range kind.Elements {
 			if newList.Elements[i], prunedElem = p.prune(elem); prunedElem {
 				prunedList = true
 			}
 		}
 		if prunedList {
-			newExpr := *node
-			newExpr.GetExprKind().(*expr.Expr_ListExpr).ListExpr = &newList
-			return &newExpr, true
+			return &ast.Expr{Id: node.Id, Kind: &newList}, true
 		}
-	case *expr.Expr_StructExpr:
-		newStruct := *node.GetStructExpr()
+	case *ast.CreateStruct:
+		newStruct := *kind
+		newStruct.Entries = make([]*ast.Entry, len(kind.Entries))
 		var prunedStruct bool
-		var prunedEntry bool
-		for i, entry := range node.GetStructExpr().Entries {
+		for i, entry := range kind.Entries {
 			newEntry := *entry
-			if newKey, pruned := p.prune(entry.GetMapKey()); pruned {
+			var prunedEntry bool
+			if newKey, pruned := p.prune(entry.MapKey); pruned {
 				prunedEntry = true
-				newEntry.GetKeyKind().(*expr.Expr_CreateStruct_Entry_MapKey).MapKey = newKey
+				newEntry.MapKey = newKey
 			}
 			if newValue, pruned := p.prune(entry.Value); pruned {
 				prunedEntry = true
 				newEntry.Value = newValue
 			}
+			newStruct.Entries[i] = entry
 			if prunedEntry {
 				prunedStruct = true
 				newStruct.Entries[i] = &newEntry
 			}
 		}
 		if prunedStruct {
-			newExpr := *node
-			newExpr.GetExprKind().(*expr.Expr_StructExpr).StructExpr = &newStruct
-			return &newExpr, true
+			return &ast.Expr{Id: node.Id, Kind: &newStruct}, true
 		}
-	case *expr.Expr_ComprehensionExpr:
-		if newIterRange, pruned := p.prune(node.GetComprehensionExpr().IterRange); pruned {
-			newExpr := *node
-			newCompre := *newExpr.GetComprehensionExpr()
+	case *ast.Comprehension:
+		if newIterRange, pruned := p.prune(kind.IterRange); pruned {
+			newCompre := *kind
 			newCompre.IterRange = newIterRange
-			newExpr.GetExprKind().(*expr.Expr_ComprehensionExpr).ComprehensionExpr = &newCompre
-			return &newExpr, true
+			return &ast.Expr{Id: node.Id, Kind: &newCompre}, true
 		}
 	}
 	return node, false
 }
 
 func (p *astPruner) value(id int64) (ref.Value, bool) {
+	if p.pinned[id] {
+		return nil, false
+	}
 	val, found := p.state.Value(p.state.GetRuntimeExpressionId(id))
 	return val, (found && val != nil)
 }
@@ -0,0 +1,124 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Rule is a named Interpretable which is evaluated as part of a RuleSet.
+type Rule struct {
+	Name          string
+	Interpretable Interpretable
+
+	// Priority orders the evaluation of the rules, highest first. Rules of
+	// equal priority are evaluated in the order they were added.
+	Priority int
+
+	// Cost is charged against the cost budget when the rule is evaluated. When
+	// zero, the cost is the number of instructions of the rule's program.
+	Cost int64
+}
+
+// Budget limits the evaluation of a RuleSet. A zero limit is unlimited.
+type Budget struct {
+	// Cost limits the total cost of the rules which are evaluated.
+	Cost int64
+
+	// Latency limits the time spent evaluating rules. A rule is only started
+	// while the time spent is within the limit, so a slow rule may cause the
+	// limit to be exceeded.
+	Latency time.Duration
+}
+
+// RuleResults reports the outcome of evaluating a RuleSet.
+type RuleResults struct {
+	// Values of the rules which were evaluated, by name.
+	Values map[string]ref.Value
+
+	// Skipped lists the names of the rules which were not evaluated because
+	// the budget was exhausted, in priority order. The caller may defer these
+	// rules to a later evaluation.
+	Skipped []string
+
+	// Cost is the total cost charged for the evaluated rules.
+	Cost int64
+
+	// Elapsed is the time spent evaluating rules.
+	Elapsed time.Duration
+}
+
+// RuleSet evaluates a set of rules in priority order within a Budget.
+type RuleSet struct {
+	rules []*Rule
+	costs []int64
+}
+
+// NewRuleSet creates a RuleSet from the given rules.
+func NewRuleSet(rules ...*Rule) *RuleSet {
+	sorted := make([]*Rule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+	costs := make([]int64, len(sorted))
+	for i, rule := range sorted {
+		costs[i] = rule.Cost
+		if costs[i] == 0 {
+			costs[i] = instructionCount(rule.Interpretable)
+		}
+	}
+	return &RuleSet{rules: sorted, costs: costs}
+}
+
+// Eval evaluates the rules in priority order until the budget is exhausted.
+//
+// Before each rule is evaluated its cost is charged against the budget. Once
+// a rule cannot be afforded, or the latency budget has been spent, the rule
+// and all rules of lower priority are skipped, so a rule is never evaluated
+// in preference to a rule of higher priority.
+func (s *RuleSet) Eval(activation Activation, budget Budget) *RuleResults {
+	results := &RuleResults{Values: make(map[string]ref.Value)}
+	start := time.Now()
+	for i, rule := range s.rules {
+		results.Elapsed = time.Since(start)
+		exhausted := budget.Cost > 0 && results.Cost+s.costs[i] > budget.Cost ||
+			budget.Latency > 0 && results.Elapsed >= budget.Latency
+		if exhausted {
+			for _, skipped := range s.rules[i:] {
+				results.Skipped = append(results.Skipped, skipped.Name)
+			}
+			return results
+		}
+		results.Cost += s.costs[i]
+		results.Values[rule.Name], _ = rule.Interpretable.Eval(activation)
+	}
+	results.Elapsed = time.Since(start)
+	return results
+}
+
+// instructionCount returns the number of instructions of the program of an
+// Interpretable created by NewInterpretable, or one for other Interpretables.
+func instructionCount(interpretable Interpretable) int64 {
+	if i, ok := innerInterpretable(interpretable).(*exprInterpretable); ok {
+		if p, ok := i.program.(*exprProgram); ok && len(p.instructions) > 0 {
+			return int64(len(p.instructions))
+		}
+	}
+	return 1
+}
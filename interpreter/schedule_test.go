@@ -0,0 +1,101 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+func TestRuleSet_Cost(t *testing.T) {
+	rule := func(name string, priority int, cost int64, text string) *Rule {
+		return &Rule{
+			Name:          name,
+			Priority:      priority,
+			Cost:          cost,
+			Interpretable: interpreter.NewInterpretable(parsedProgram(t, text))}
+	}
+	rules := NewRuleSet(
+		rule("low", 0, 1, `a > 1`),
+		rule("high", 10, 5, `a < 10`),
+		rule("medium", 5, 5, `a == 2`),
+		rule("default", 5, 0, `a + 1`))
+	activation := NewActivation(map[string]interface{}{"a": 2})
+	for _, tst := range []struct {
+		budget   int64
+		values   map[string]ref.Value
+		skipped  []string
+		expected int64
+	}{
+		{budget: 0,
+			values: map[string]ref.Value{
+				"high": types.True, "medium": types.True,
+				"default": types.Int(3), "low": types.True},
+			expected: 13},
+		{budget: 12,
+			values: map[string]ref.Value{
+				"high": types.True, "medium": types.True, "default": types.Int(3)},
+			skipped:  []string{"low"},
+			expected: 12},
+		// Rules are not evaluated in preference to rules of higher priority,
+		// even when they could be afforded.
+		{budget: 11,
+			values:   map[string]ref.Value{"high": types.True, "medium": types.True},
+			skipped:  []string{"default", "low"},
+			expected: 10},
+		{budget: 4,
+			values:   map[string]ref.Value{},
+			skipped:  []string{"high", "medium", "default", "low"},
+			expected: 0},
+	} {
+		results := rules.Eval(activation, Budget{Cost: tst.budget})
+		if !reflect.DeepEqual(results.Values, tst.values) {
+			t.Errorf("%d: got values %v, wanted %v", tst.budget, results.Values, tst.values)
+		}
+		if !reflect.DeepEqual(results.Skipped, tst.skipped) {
+			t.Errorf("%d: got skipped %v, wanted %v", tst.budget, results.Skipped, tst.skipped)
+		}
+		if results.Cost != tst.expected {
+			t.Errorf("%d: got cost %d, wanted %d", tst.budget, results.Cost, tst.expected)
+		}
+	}
+}
+
+func TestRuleSet_Latency(t *testing.T) {
+	rules := NewRuleSet(
+		&Rule{Name: "slow", Priority: 1,
+			Interpretable: interpreter.NewInterpretable(parsedProgram(t, `slow`))},
+		&Rule{Name: "fast",
+			Interpretable: interpreter.NewInterpretable(parsedProgram(t, `true`))})
+	activation := NewActivation(map[string]interface{}{
+		"slow": func() ref.Value {
+			time.Sleep(10 * time.Millisecond)
+			return types.True
+		}})
+	results := rules.Eval(activation, Budget{Latency: 5 * time.Millisecond})
+	if results.Values["slow"] != types.True {
+		t.Errorf("Got %v, wanted true", results.Values["slow"])
+	}
+	if !reflect.DeepEqual(results.Skipped, []string{"fast"}) {
+		t.Errorf("Got skipped %v, wanted [fast]", results.Skipped)
+	}
+	if results.Elapsed < 5*time.Millisecond {
+		t.Errorf("Got elapsed %v, wanted at least 5ms", results.Elapsed)
+	}
+}
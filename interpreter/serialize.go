@@ -0,0 +1,505 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+const (
+	// Version of the serialized program format. The version is incremented
+	// whenever the format changes in a way older loaders cannot read.
+	programFormatVersion = 1
+)
+
+// MarshalProgram serializes a planned Program to bytes.
+//
+// The serialized form contains the program instructions, the literal values
+// referenced by the instructions, the function and overload names of each
+// call, and the source positions of the expressions. The expression itself,
+// along with its types when the program was checked, is serialized as a
+// CheckedExpr proto, so that the loaded program may also be evaluated with
+// TreeEvaluation and track its attributes. Overloads are bound by name when
+// the program is evaluated, so the Dispatcher used at load time must provide
+// the same functions as the one used when the program was compiled.
+//
+// The Program must have been initialized, either by calling Init or by
+// creating an Interpretable from it, prior to serialization.
+func MarshalProgram(program Program) ([]byte, error) {
+	p, ok := program.(*exprProgram)
+	if !ok {
+		return nil, fmt.Errorf("unsupported program type: %T", program)
+	}
+	if p.instructions == nil {
+		return nil, fmt.Errorf("program must be initialized prior to serialization")
+	}
+	// Fused programs are serialized as planned, since superinstructions bind
+	// overloads from the Dispatcher. The FuseInstructions option may be
+	// provided to UnmarshalProgram to fuse the loaded program.
+	instructions := p.instructions
+	if p.planned != nil {
+		instructions = p.planned
+	}
+	data := &programData{
+		Version:      programFormatVersion,
+		MaxId:        p.maxId,
+		Instructions: make([]instructionData, len(instructions)),
+		Constants:    make([]constantData, 0, len(p.literals)),
+		Requirements: p.requirements}
+	for i, inst := range instructions {
+		instData, err := marshalInstruction(inst)
+		if err != nil {
+			return nil, err
+		}
+		data.Instructions[i] = instData
+	}
+	for id, val := range p.literals {
+		constData, err := marshalConstant(id, val)
+		if err != nil {
+			return nil, err
+		}
+		data.Constants = append(data.Constants, constData)
+	}
+	if m, ok := p.metadata.(*exprMetadata); ok {
+		data.Location = m.info.Location
+		data.LineOffsets = m.info.LineOffsets
+		data.Positions = m.info.Positions
+	}
+	if p.expression != nil {
+		expression, err := proto.Marshal(&checkedpb.CheckedExpr{
+			Expr:    astpb.ToExpr(p.expression),
+			TypeMap: p.typeMap})
+		if err != nil {
+			return nil, err
+		}
+		data.Expression = expression
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalProgram loads a Program serialized with MarshalProgram.
+//
+// The loaded Program is already planned and does not require the parser or
+// checker. The SharedConstants option may be used to pool the literals of the
+// loaded program with those of other programs.
+func UnmarshalProgram(serialized []byte, opts ...ProgramOption) (Program, error) {
+	data := &programData{}
+	if err := gob.NewDecoder(bytes.NewReader(serialized)).Decode(data); err != nil {
+		return nil, err
+	}
+	if data.Version != programFormatVersion {
+		return nil, fmt.Errorf(
+			"unsupported program format version: %d", data.Version)
+	}
+	program := &exprProgram{
+		maxId: data.MaxId,
+		metadata: newExprMetadata(&ast.SourceInfo{
+			Location:    data.Location,
+			LineOffsets: data.LineOffsets,
+			Positions:   data.Positions}),
+		requirements: data.Requirements}
+	if program.requirements == nil {
+		program.requirements = &Requirements{}
+	}
+	if len(data.Expression) != 0 {
+		checked := &checkedpb.CheckedExpr{}
+		if err := proto.Unmarshal(data.Expression, checked); err != nil {
+			return nil, err
+		}
+		program.expression = astpb.FromExpr(checked.Expr)
+		if len(checked.TypeMap) != 0 {
+			program.typeMap = checked.TypeMap
+		}
+	}
+	for _, opt := range opts {
+		opt(program)
+	}
+	if program.constants == nil {
+		program.constants = NewConstants()
+	}
+	program.instructions = make([]Instruction, len(data.Instructions))
+	program.revInstructions = make(map[int64]int)
+	for i, instData := range data.Instructions {
+		if err := validateInstruction(instData, i, data); err != nil {
+			return nil, err
+		}
+		inst, err := unmarshalInstruction(instData, program.constants)
+		if err != nil {
+			return nil, err
+		}
+		program.instructions[i] = inst
+		program.revInstructions[inst.GetId()] = i
+	}
+	program.literals = make(map[int64]ref.Value)
+	for _, constData := range data.Constants {
+		if constData.Id < 0 || constData.Id > program.maxId {
+			return nil, fmt.Errorf("constant id out of range: %d", constData.Id)
+		}
+		value, err := constData.refValue(program.constants)
+		if err != nil {
+			return nil, err
+		}
+		program.literals[constData.Id] = value
+	}
+	return program, nil
+}
+
+// programData is the serialized form of an exprProgram.
+type programData struct {
+	Version      int
+	MaxId        int64
+	Instructions []instructionData
+	Constants    []constantData
+	Location     string
+	LineOffsets  []int32
+	Positions    map[int64]int32
+	Requirements *Requirements
+
+	// Expression is the proto encoding of a CheckedExpr holding the
+	// expression of the program and, for checked programs, its type map.
+	Expression []byte
+}
+
+type opcode int
+
+const (
+	opConst opcode = iota + 1
+	opIdent
+	opSelect
+	opCall
+	opIndex
+	opList
+	opMap
+	opObject
+	opJump
+	opMov
+	opPushScope
+	opPopScope
+	opTestOnlySelect
+)
+
+// instructionData is the serialized form of an Instruction. The fields in use
+// depend on the opcode.
+type instructionData struct {
+	Op opcode
+	Id int64
+
+	// Identifier name, function name, field name, or message name.
+	Name     string
+	Overload string
+
+	// Call arguments, list elements, or the operand of a select, index, or mov
+	// instruction followed by the index or destination.
+	Args []int64
+
+	KeyValues   map[int64]int64
+	FieldValues map[string]int64
+
+	// ElemType is the type name of the elements of an IndexExpr.
+	ElemType string
+
+	Count     int
+	CondKind  jumpConditionKind
+	CondId    int64
+	CondValue bool
+
+	Const *constantData
+}
+
+func marshalInstruction(inst Instruction) (instructionData, error) {
+	data := instructionData{Id: inst.GetId()}
+	switch i := inst.(type) {
+	case *ConstExpr:
+		constData, err := marshalConstant(i.GetId(), i.Value)
+		if err != nil {
+			return data, err
+		}
+		data.Op = opConst
+		data.Const = &constData
+	case *IdentExpr:
+		data.Op = opIdent
+		data.Name = i.Name
+	case *SelectExpr:
+		data.Op = opSelect
+		if i.TestOnly {
+			data.Op = opTestOnlySelect
+		}
+		data.Name = i.Field
+		data.Args = []int64{i.Operand}
+	case *CallExpr:
+		data.Op = opCall
+		data.Name = i.Function
+		data.Overload = i.Overload
+		data.Args = i.Args
+	case *IndexExpr:
+		data.Op = opIndex
+		data.Args = []int64{i.Operand, i.Index}
+		data.ElemType = i.ElemType.TypeName()
+	case *CreateListExpr:
+		data.Op = opList
+		data.Args = i.Elements
+	case *CreateMapExpr:
+		data.Op = opMap
+		data.KeyValues = i.KeyValues
+	case *CreateObjectExpr:
+		data.Op = opObject
+		data.Name = i.Name
+		data.FieldValues = i.FieldValues
+	case *JumpInst:
+		if i.condition.kind == customJumpKind {
+			return data, fmt.Errorf(
+				"jump at expression id %d has a custom condition", i.GetId())
+		}
+		data.Op = opJump
+		data.Count = i.Count
+		data.CondKind = i.condition.kind
+		data.CondId = i.condition.exprId
+		data.CondValue = i.condition.value
+	case *MovInst:
+		data.Op = opMov
+		data.Args = []int64{i.ToExprId}
+	case *PushScopeInst:
+		data.Op = opPushScope
+		data.FieldValues = i.Declarations
+	case *PopScopeInst:
+		data.Op = opPopScope
+	default:
+		return data, fmt.Errorf("unsupported instruction: %v", inst)
+	}
+	return data, nil
+}
+
+// validateInstruction ensures the expression ids written by an instruction
+// and its jump target, if any, are within the bounds of the program.
+func validateInstruction(inst instructionData, index int, data *programData) error {
+	if inst.Id < 0 || inst.Id > data.MaxId {
+		return fmt.Errorf("expression id out of range: %d", inst.Id)
+	}
+	if inst.Op == opMov && len(inst.Args) == 1 &&
+		(inst.Args[0] < 0 || inst.Args[0] > data.MaxId) {
+		return fmt.Errorf("expression id out of range: %d", inst.Args[0])
+	}
+	if target := index + inst.Count; inst.Op == opJump &&
+		(target < 0 || target >= len(data.Instructions)) {
+		return fmt.Errorf("jump target out of range at expression id %d", inst.Id)
+	}
+	return nil
+}
+
+func unmarshalInstruction(data instructionData,
+	constants *Constants) (Instruction, error) {
+	switch data.Op {
+	case opConst:
+		if data.Const == nil {
+			return nil, fmt.Errorf("missing constant at expression id %d", data.Id)
+		}
+		value, err := data.Const.refValue(constants)
+		if err != nil {
+			return nil, err
+		}
+		return NewLiteral(data.Id, value), nil
+	case opIdent:
+		return NewIdent(data.Id, data.Name), nil
+	case opSelect:
+		if len(data.Args) != 1 {
+			return nil, fmt.Errorf("malformed select at expression id %d", data.Id)
+		}
+		return NewSelect(data.Id, data.Args[0], data.Name), nil
+	case opTestOnlySelect:
+		if len(data.Args) != 1 {
+			return nil, fmt.Errorf("malformed select at expression id %d", data.Id)
+		}
+		return NewTestOnlySelect(data.Id, data.Args[0], data.Name), nil
+	case opCall:
+		return NewCallOverload(data.Id, data.Name, data.Args, data.Overload), nil
+	case opIndex:
+		elemType, found := indexElemTypes[data.ElemType]
+		if len(data.Args) != 2 || !found {
+			return nil, fmt.Errorf("malformed index at expression id %d", data.Id)
+		}
+		return NewIndex(data.Id, data.Args[0], data.Args[1], elemType), nil
+	case opList:
+		return NewList(data.Id, data.Args), nil
+	case opMap:
+		return NewMap(data.Id, data.KeyValues), nil
+	case opObject:
+		return NewObject(data.Id, data.Name, data.FieldValues), nil
+	case opJump:
+		cond := jumpCondition{data.CondKind, data.CondId, data.CondValue}
+		if cond.kind <= customJumpKind || cond.kind > breakIfEndKind {
+			return nil, fmt.Errorf("malformed jump at expression id %d", data.Id)
+		}
+		jump := NewJump(data.Id, data.Count, cond.eval())
+		jump.condition = cond
+		return jump, nil
+	case opMov:
+		if len(data.Args) != 1 {
+			return nil, fmt.Errorf("malformed mov at expression id %d", data.Id)
+		}
+		return NewMov(data.Id, data.Args[0]), nil
+	case opPushScope:
+		return NewPushScope(data.Id, data.FieldValues), nil
+	case opPopScope:
+		return NewPopScope(data.Id), nil
+	}
+	return nil, fmt.Errorf("unsupported opcode %d at expression id %d",
+		data.Op, data.Id)
+}
+
+// constantData is the serialized form of a literal value.
+type constantData struct {
+	Id     int64
+	Type   string
+	Bool   bool
+	Bytes  []byte
+	Double float64
+	Int    int64
+	String string
+	Uint   uint64
+
+	// Elems holds the elements of a list, or the keys and values of a map in
+	// alternation, e.g. for lists and maps folded by Optimize.
+	Elems []constantData
+}
+
+func marshalConstant(id int64, val ref.Value) (constantData, error) {
+	data := constantData{Id: id, Type: val.Type().TypeName()}
+	switch v := val.(type) {
+	case types.Bool:
+		data.Bool = bool(v)
+	case types.Bytes:
+		data.Bytes = []byte(v)
+	case types.Double:
+		data.Double = float64(v)
+	case types.Int:
+		data.Int = int64(v)
+	case types.Null:
+	case types.String:
+		data.String = string(v)
+	case types.Uint:
+		data.Uint = uint64(v)
+	case traits.Lister:
+		data.Type = types.ListType.TypeName()
+		for i := types.Int(0); i < v.Size().(types.Int); i++ {
+			elem, err := marshalConstant(id, v.Get(i))
+			if err != nil {
+				return data, err
+			}
+			data.Elems = append(data.Elems, elem)
+		}
+	case traits.Mapper:
+		data.Type = types.MapType.TypeName()
+		it := v.Iterator()
+		for it.HasNext() == types.True {
+			key := it.Next()
+			for _, entry := range []ref.Value{key, v.Get(key)} {
+				entryData, err := marshalConstant(id, entry)
+				if err != nil {
+					return data, err
+				}
+				data.Elems = append(data.Elems, entryData)
+			}
+		}
+	default:
+		return data, fmt.Errorf(
+			"unsupported constant type '%s' at expression id %d",
+			val.Type().TypeName(), id)
+	}
+	return data, nil
+}
+
+// refValue returns the value of the constant, whose primitive values are
+// taken from the pool of constants.
+func (c *constantData) refValue(constants *Constants) (ref.Value, error) {
+	switch c.Type {
+	case types.ListType.TypeName():
+		elems := make([]ref.Value, len(c.Elems))
+		for i := range c.Elems {
+			elem, err := c.Elems[i].refValue(constants)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return types.NewDynamicList(elems), nil
+	case types.MapType.TypeName():
+		if len(c.Elems)%2 != 0 {
+			return nil, fmt.Errorf("malformed map constant at expression id %d", c.Id)
+		}
+		entries := make(map[ref.Value]ref.Value, len(c.Elems)/2)
+		for i := 0; i < len(c.Elems); i += 2 {
+			key, err := c.Elems[i].refValue(constants)
+			if err != nil {
+				return nil, err
+			}
+			value, err := c.Elems[i+1].refValue(constants)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = value
+		}
+		return types.NewDynamicMap(entries), nil
+	}
+	value, err := c.value()
+	if err != nil {
+		return nil, err
+	}
+	return constants.literal(&ast.Literal{Value: value}), nil
+}
+
+// value returns the plain Go value of the constant in the form used by
+// ast.Literal.
+func (c *constantData) value() (interface{}, error) {
+	switch c.Type {
+	case types.BoolType.TypeName():
+		return c.Bool, nil
+	case types.BytesType.TypeName():
+		return c.Bytes, nil
+	case types.DoubleType.TypeName():
+		return c.Double, nil
+	case types.IntType.TypeName():
+		return c.Int, nil
+	case types.NullType.TypeName():
+		return nil, nil
+	case types.StringType.TypeName():
+		return c.String, nil
+	case types.UintType.TypeName():
+		return c.Uint, nil
+	}
+	return nil, fmt.Errorf(
+		"unsupported constant type '%s' at expression id %d", c.Type, c.Id)
+}
+
+var (
+	indexElemTypes = map[string]ref.Type{
+		types.BoolType.TypeName():   types.BoolType,
+		types.IntType.TypeName():    types.IntType,
+		types.StringType.TypeName(): types.StringType,
+	}
+)
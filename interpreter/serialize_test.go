@@ -0,0 +1,153 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/parser"
+)
+
+func TestMarshalProgram_RoundTrip(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"a":     map[string]interface{}{"b": 1},
+		"x":     true,
+		"elems": []int64{1, 2, 3},
+		"name":  "cel"})
+	for _, text := range []string{
+		`a.b == 1 && !x || name == 'cel'`,
+		`x ? {'k': [1u, 2.5, b'\x00', null]}['k'].size() : -1`,
+		`elems.exists(e, e > 2)`,
+		`name.size() == 3`,
+		`has(a.b) && !has(a.c)`,
+	} {
+		parsed, errors := parser.ParseText(text)
+		if len(errors.GetErrors()) != 0 {
+			t.Fatal(errors.ToDisplayString())
+		}
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+		expected, _ := interpreter.NewInterpretable(program).Eval(activation)
+		loaded := roundTrip(t, program)
+		if fmt.Sprint(loaded) != fmt.Sprint(program) {
+			t.Errorf("Got program:\n%v\nwanted:\n%v", loaded, program)
+		}
+		actual, _ := interpreter.NewInterpretable(loaded).Eval(activation)
+		if actual.Equal(expected) != types.True {
+			t.Errorf("%s: got '%v', wanted '%v'", text, actual, expected)
+		}
+	}
+}
+
+func TestMarshalProgram_Checked(t *testing.T) {
+	program := checkedProgram(t, "elems[1] == 2 && names['a'] == 'y'",
+		decls.NewIdent("elems", decls.NewListType(decls.Int), nil),
+		decls.NewIdent("names", decls.NewMapType(decls.String, decls.String), nil))
+	interpreter.NewInterpretable(program)
+	loaded := roundTrip(t, program)
+	res, _ := interpreter.NewInterpretable(loaded).Eval(
+		NewActivation(map[string]interface{}{
+			"elems": []int64{1, 2},
+			"names": map[string]string{"a": "y"}}))
+	if res != types.True {
+		t.Errorf("Got '%v', wanted 'true'", res)
+	}
+	expected, _ := program.Metadata().IdLocation(1)
+	if loc, found := loaded.Metadata().IdLocation(1); !found ||
+		fmt.Sprint(loc) != fmt.Sprint(expected) {
+		t.Errorf("Got location %v, wanted %v", loc, expected)
+	}
+}
+
+func TestMarshalProgram_Expression(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{
+		"elems": []int64{1, 2, 3},
+		"names": map[string]string{"a": "y"}})
+	for _, program := range []Program{
+		parsedProgram(t, `elems.exists(e, e > 2) && names['a'] == 'y'`),
+		checkedProgram(t, `elems.exists(e, e > 2) && names['a'] == 'y'`,
+			decls.NewIdent("elems", decls.NewListType(decls.Int), nil),
+			decls.NewIdent("names", decls.NewMapType(decls.String, decls.String), nil)),
+	} {
+		interpreter.NewInterpretable(program)
+		serialized, err := MarshalProgram(program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := UnmarshalProgram(serialized, TreeEvaluation(), TrackAttributes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.Config().Checked != program.Config().Checked {
+			t.Errorf("Got %+v, wanted %+v", loaded.Config(), program.Config())
+		}
+		result, state := interpreter.NewInterpretable(loaded).Eval(activation)
+		if result != types.True {
+			t.Errorf("Got '%v' with %+v, wanted 'true'", result, loaded.Config())
+		}
+		if attributes := state.Attributes(); fmt.Sprint(attributes) != "[elems names]" {
+			t.Errorf("Got attributes %v, wanted [elems names]", attributes)
+		}
+	}
+}
+
+func TestMarshalProgram_Optimized(t *testing.T) {
+	activation := NewActivation(map[string]interface{}{"a": 2})
+	for _, text := range []string{
+		`[1, 2] + [3] == [a - 1, a, a + 1]`,
+		`{'k': [1, 2]}.k[1] == a`,
+		`[1, 2] + [a]`,
+	} {
+		program := Optimize(parsedProgram(t, text), dispatcher())
+		expected, _ := interpreter.NewInterpretable(program).Eval(activation)
+		loaded := roundTrip(t, program)
+		if fmt.Sprint(loaded) != fmt.Sprint(program) {
+			t.Errorf("Got program:\n%v\nwanted:\n%v", loaded, program)
+		}
+		actual, _ := interpreter.NewInterpretable(loaded).Eval(activation)
+		if actual.Equal(expected) != types.True {
+			t.Errorf("%s: got '%v', wanted '%v'", text, actual, expected)
+		}
+	}
+}
+
+func TestMarshalProgram_Errors(t *testing.T) {
+	parsed, errors := parser.ParseText("1 + 2")
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo())
+	if _, err := MarshalProgram(program); err == nil {
+		t.Error("Expected error serializing an uninitialized program")
+	}
+	if _, err := UnmarshalProgram([]byte("not a program")); err == nil {
+		t.Error("Expected error loading malformed bytes")
+	}
+}
+
+func roundTrip(t *testing.T, program Program) Program {
+	t.Helper()
+	serialized, err := MarshalProgram(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := UnmarshalProgram(serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
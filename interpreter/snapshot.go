@@ -0,0 +1,136 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+// Snapshot holds the values computed by an evaluation in which only some of
+// the variables were supplied, so that the evaluations which supply the
+// remaining variables reuse the values of the sub-expressions which did not
+// depend on them, e.g. when most of the variables are fixed for the lifetime
+// of a connection:
+//
+//     // Once per connection, with the static variables.
+//     _, state := interpretable.Eval(static)
+//     snapshot := NewSnapshot(interp, parsed, static, state)
+//
+//     // On each request, with the remaining variables.
+//     result, state := snapshot.Eval(activation)
+//
+// The variables which were not supplied to the first evaluation evaluate to
+// unknown values, and the sub-expressions which depend on them are evaluated
+// again by the Snapshot, while the others are replaced by their values. A
+// Snapshot may be evaluated concurrently.
+type Snapshot struct {
+	residual      *ast.Expr
+	static        Activation
+	interpretable Interpretable
+}
+
+// NewSnapshot creates a Snapshot of the state of an evaluation of the parsed
+// expression with the static activation. The calls of Volatile overloads of
+// the interpreter's dispatcher, such as 'since', and the expressions which
+// contain them, are evaluated again rather than reused.
+func NewSnapshot(interp Interpreter, parsed *expr.ParsedExpr,
+	static Activation, state EvalState) *Snapshot {
+	root := astpb.FromExpr(parsed.Expr)
+	pruner := &astPruner{
+		expr:     root,
+		state:    state,
+		bindings: make(map[string]ref.Value),
+		pinned:   make(map[int64]bool)}
+	if i, isExprInterpreter := interp.(*exprInterpreter); isExprInterpreter {
+		pinVolatileCalls(root, i.dispatcher, pruner.pinned)
+	}
+	residual, _ := pruner.prune(root)
+	bindings := make(map[string]interface{}, len(pruner.bindings))
+	for name, value := range pruner.bindings {
+		bindings[name] = value
+	}
+	program := NewProgram(astpb.ToExpr(residual), parsed.SourceInfo)
+	return &Snapshot{
+		residual:      residual,
+		static:        NewHierarchicalActivation(static, NewActivation(bindings)),
+		interpretable: interp.NewInterpretable(program)}
+}
+
+// Residual returns the expression evaluated by the Snapshot, in which the
+// reused values of sub-expressions are literals, or identifiers whose names
+// begin with '@snapshot' for values which have no literal form.
+func (s *Snapshot) Residual() *expr.Expr {
+	return astpb.ToExpr(s.residual)
+}
+
+// Eval evaluates the expression with the activation of the variables which
+// were not supplied to the evaluation of the Snapshot. The activation takes
+// precedence over the static activation of the Snapshot.
+func (s *Snapshot) Eval(activation Activation) (ref.Value, EvalState) {
+	return s.interpretable.Eval(NewHierarchicalActivation(s.static, activation))
+}
+
+// ChangedValues returns the sorted ids of the expressions whose values differ
+// between the states of two evaluations of the same program, e.g. to
+// invalidate the results which were cached for the sub-expressions whose
+// values changed. The values of states other than those created by the
+// interpreter cannot be compared, and no ids are returned for them.
+func ChangedValues(before EvalState, after EvalState) []int64 {
+	b, isDefault := before.(*defaultEvalState)
+	a, isOtherDefault := after.(*defaultEvalState)
+	if !isDefault || !isOtherDefault {
+		return nil
+	}
+	var changed []int64
+	for id := int64(0); id < b.exprCount || id < a.exprCount; id++ {
+		bv, _ := b.Value(id)
+		av, _ := a.Value(id)
+		if !equalValues(bv, av) {
+			changed = append(changed, id)
+		}
+	}
+	return changed
+}
+
+// equalValues returns whether two values, either of which may be nil, are the
+// same.
+func equalValues(lhs ref.Value, rhs ref.Value) bool {
+	if lhs == nil || rhs == nil {
+		return lhs == nil && rhs == nil
+	}
+	return lhs.Type() == rhs.Type() && lhs.Equal(rhs) == types.True
+}
+
+// pinVolatileCalls records the ids of the calls of Volatile overloads, and of
+// the expressions which contain them.
+func pinVolatileCalls(root *ast.Expr, dispatcher Dispatcher, pinned map[int64]bool) {
+	parents := make(map[int64]*ast.Expr)
+	ast.Visit(root, func(e *ast.Expr, parent *ast.Expr) bool {
+		if parent != nil {
+			parents[e.Id] = parent
+		}
+		call, isCall := e.Kind.(*ast.Call)
+		if isCall && isVolatile(dispatcher, call.Function, "") {
+			for pin := e; pin != nil; pin = parents[pin.Id] {
+				pinned[pin.Id] = true
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	tpb "github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/google/cel-go/common/debug"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/google/cel-go/parser"
+)
+
+func TestSnapshot(t *testing.T) {
+	parsed, errors := parser.ParseText(
+		`conn.roles.filter(r, r.matches('^app-')).size() > 0 && ` +
+			`request.size < conn.limit`)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	static := NewActivation(map[string]interface{}{
+		"conn": map[string]interface{}{
+			"roles": []string{"app-reader", "admin"},
+			"limit": 1024}})
+	program := NewProgram(parsed.Expr, parsed.SourceInfo)
+	interpretable := interpreter.NewInterpretable(program)
+	result, state := interpretable.Eval(static)
+	if !types.IsUnknown(result) {
+		t.Fatalf("Got '%v', wanted unknown", result)
+	}
+	snapshot := NewSnapshot(interpreter, parsed, static, state)
+	residual := debug.ToDebugString(snapshot.Residual())
+	expected := `_<_(
+  request.size,
+  1024
+)`
+	if residual != expected {
+		t.Errorf("Got residual:\n%s\nwanted:\n%s", residual, expected)
+	}
+	for _, tst := range []struct {
+		size     int64
+		expected ref.Value
+	}{
+		{size: 512, expected: types.True},
+		{size: 2048, expected: types.False},
+	} {
+		activation := NewActivation(map[string]interface{}{
+			"request": map[string]int64{"size": tst.size}})
+		if res, _ := snapshot.Eval(activation); res != tst.expected {
+			t.Errorf("%d: got '%v' from the snapshot, wanted '%v'", tst.size, res, tst.expected)
+		}
+		full := NewHierarchicalActivation(static, activation)
+		if res, _ := interpretable.Eval(full); res != tst.expected {
+			t.Errorf("%d: got '%v', wanted '%v'", tst.size, res, tst.expected)
+		}
+	}
+}
+
+func TestSnapshot_Aggregates(t *testing.T) {
+	parsed, errors := parser.ParseText(
+		`role in conn.roles.map(r, r + '-role') || now.since() < duration('1h')`)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	clock := time.Unix(1000, 0)
+	interp := NewInterpreter(
+		dispatcher(functions.TimeOverloads(func() time.Time { return clock }, nil)...),
+		packages.DefaultPackage, types.NewProvider())
+	static := NewActivation(map[string]interface{}{
+		"conn": map[string]interface{}{"roles": []string{"reader", "writer"}},
+		"now":  &tpb.Timestamp{Seconds: 1000}})
+	_, state := interp.NewInterpretable(
+		NewProgram(parsed.Expr, parsed.SourceInfo)).Eval(static)
+	snapshot := NewSnapshot(interp, parsed, static, state)
+	// The mapped roles and the duration are reused, while the volatile call
+	// is evaluated again.
+	residual := debug.ToDebugString(snapshot.Residual())
+	expected := `_||_(
+  _in_(
+    role,
+    @snapshot13
+  ),
+  _<_(
+    now.since(),
+    @snapshot18
+  )
+)`
+	if residual != expected {
+		t.Errorf("Got residual:\n%s\nwanted:\n%s", residual, expected)
+	}
+	activation := NewActivation(map[string]interface{}{"role": "guest"})
+	if res, _ := snapshot.Eval(activation); res != types.True {
+		t.Errorf("Got '%v', wanted true", res)
+	}
+	clock = clock.Add(2 * time.Hour)
+	if res, _ := snapshot.Eval(activation); res != types.False {
+		t.Errorf("Got '%v' after an hour, wanted false", res)
+	}
+	activation = NewActivation(map[string]interface{}{"role": "writer-role"})
+	if res, _ := snapshot.Eval(activation); res != types.True {
+		t.Errorf("Got '%v', wanted true", res)
+	}
+}
+
+func TestChangedValues(t *testing.T) {
+	program := parsedProgram(t, "a + b > 10 || c")
+	interpretable := interpreter.NewInterpretable(program)
+	_, before := interpretable.Eval(NewActivation(map[string]interface{}{
+		"a": 1, "b": 2, "c": true}))
+	_, after := interpretable.Eval(NewActivation(map[string]interface{}{
+		"a": 1, "b": 3, "c": true}))
+	changed := ChangedValues(before, after)
+	// The values of 'b' and of the sum changed.
+	if !reflect.DeepEqual(changed, []int64{2, 3}) {
+		t.Errorf("Got changed values %v, wanted [2 3]", changed)
+	}
+	if changed := ChangedValues(before, before); len(changed) != 0 {
+		t.Errorf("Got changed values %v of the same state", changed)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// specializeIndexes replaces '_[_]' calls with IndexExpr instructions when the
+// type map indicates the operand is a list, or a map with string keys, whose
+// elements are bool, int, or string values. Indexes are only specialized for
+// the Dispatcher created by NewDispatcher, as with the fusion of comparisons.
+func specializeIndexes(instructions []Instruction,
+	typeMap map[int64]*checkedpb.Type) []Instruction {
+	for i, inst := range instructions {
+		call, isCall := inst.(*CallExpr)
+		if !isCall || call.Function != operators.Index || len(call.Args) != 2 {
+			continue
+		}
+		if elemType, found := indexElemType(typeMap[call.Args[0]]); found {
+			instructions[i] = NewIndex(call.GetId(), call.Args[0], call.Args[1], elemType)
+		}
+	}
+	return instructions
+}
+
+// constantCompilers compile the constant arguments of the functions, keyed
+// by name, when a program is planned, so that values such as patterns are
+// built once rather than on each evaluation. A compiler is given the
+// arguments of a call, including the target of a receiver-style call, and
+// returns the index of the argument which it compiled along with the value
+// which takes the place of the argument. Arguments which are not constant, or
+// whose values are invalid, are not compiled, and are left to be evaluated
+// and to report any error as usual.
+var constantCompilers = map[string]func(args []*ast.Expr) (int, ref.Value, bool){
+	overloads.MatchesGlob:     compileGlob,
+	overloads.MatcherFromList: compileMatcher,
+	overloads.RegexMatches:    compileRegex,
+	overloads.RegexCapture:    compileRegex,
+	overloads.RegexCaptureAll: compileRegex,
+	overloads.RegexReplace:    compileRegex,
+}
+
+// compileConstants compiles the constant arguments of the calls within the
+// expression with the constantCompilers, returning the compiled values keyed
+// by the ids of the arguments. Arguments are only compiled for the Dispatcher
+// created by NewDispatcher, whose overloads accept the compiled values.
+func compileConstants(expression *ast.Expr,
+	dispatcher Dispatcher) map[int64]ref.Value {
+	compiled := make(map[int64]ref.Value)
+	if _, isDefault := dispatcher.(*defaultDispatcher); !isDefault || expression == nil {
+		return compiled
+	}
+	walker := &astWalker{dispatcher: dispatcher}
+	ast.Visit(expression, func(e *ast.Expr, parent *ast.Expr) bool {
+		call, isCall := e.Kind.(*ast.Call)
+		if !isCall {
+			return true
+		}
+		if static, found := walker.staticCall(call); found {
+			call = static
+		}
+		compile, found := constantCompilers[call.Function]
+		if !found {
+			return true
+		}
+		if _, found := dispatcher.FindOverload(call.Function); !found {
+			return true
+		}
+		args := getArgs(call)
+		if arg, value, found := compile(args); found {
+			compiled[args[arg].Id] = value
+		}
+		return true
+	})
+	return compiled
+}
+
+// compileGlob compiles the constant pattern of a 'matchesGlob' call.
+func compileGlob(args []*ast.Expr) (int, ref.Value, bool) {
+	if len(args) != 2 {
+		return 0, nil, false
+	}
+	pattern, isString := stringLiteral(args[1])
+	if !isString {
+		return 0, nil, false
+	}
+	glob, err := types.NewGlob(pattern)
+	if err != nil {
+		return 0, nil, false
+	}
+	return 1, glob, true
+}
+
+// compileRegex compiles the constant pattern of a call of a regex function.
+func compileRegex(args []*ast.Expr) (int, ref.Value, bool) {
+	if len(args) < 2 {
+		return 0, nil, false
+	}
+	pattern, isString := stringLiteral(args[1])
+	if !isString {
+		return 0, nil, false
+	}
+	re, err := types.NewRegex(pattern)
+	if err != nil {
+		return 0, nil, false
+	}
+	return 1, re, true
+}
+
+// compileMatcher builds the matcher of a call of matcher.fromList on a list of
+// string literals.
+func compileMatcher(args []*ast.Expr) (int, ref.Value, bool) {
+	if len(args) != 1 {
+		return 0, nil, false
+	}
+	list, isList := args[0].Kind.(*ast.CreateList)
+	if !isList {
+		return 0, nil, false
+	}
+	patterns := make([]string, len(list.Elements))
+	for i, elem := range list.Elements {
+		pattern, isString := stringLiteral(elem)
+		if !isString {
+			return 0, nil, false
+		}
+		patterns[i] = pattern
+	}
+	return 0, types.NewListMatcher(patterns), true
+}
+
+// stringLiteral returns the value of a string literal.
+func stringLiteral(e *ast.Expr) (string, bool) {
+	lit, isLiteral := e.Kind.(*ast.Literal)
+	if !isLiteral {
+		return "", false
+	}
+	value, isString := lit.Value.(string)
+	return value, isString
+}
+
+// indexElemType returns the runtime type of the elements of a list or
+// string-keyed map type when the elements are bool, int, or string values.
+func indexElemType(t *checkedpb.Type) (ref.Type, bool) {
+	var elem *checkedpb.Type
+	switch t.GetTypeKind().(type) {
+	case *checkedpb.Type_ListType_:
+		elem = t.GetListType().GetElemType()
+	case *checkedpb.Type_MapType_:
+		mapType := t.GetMapType()
+		if mapType.GetKeyType().GetPrimitive() != checkedpb.Type_STRING {
+			return nil, false
+		}
+		elem = mapType.GetValueType()
+	default:
+		return nil, false
+	}
+	if _, isPrimitive := elem.GetTypeKind().(*checkedpb.Type_Primitive); !isPrimitive {
+		return nil, false
+	}
+	switch elem.GetPrimitive() {
+	case checkedpb.Type_BOOL:
+		return types.BoolType, true
+	case checkedpb.Type_INT64:
+		return types.IntType, true
+	case checkedpb.Type_STRING:
+		return types.StringType, true
+	}
+	return nil, false
+}
+
+// indexNative reads an element directly from the native Go slice or map which
+// backs a list or map value, avoiding the reflection used by the general
+// traits.Indexer implementations.
+//
+// When the native value does not have the expected shape, or the element does
+// not exist, the result is not found and the caller should fall back to the
+// traits.Indexer interface in order to produce the appropriate error.
+func indexNative(native interface{}, index ref.Value, elemType ref.Type) (ref.Value, bool) {
+	switch idx := index.(type) {
+	case types.Int:
+		switch elemType {
+		case types.BoolType:
+			if elems, ok := native.([]bool); ok && idx >= 0 && int(idx) < len(elems) {
+				return types.Bool(elems[idx]), true
+			}
+		case types.IntType:
+			if elems, ok := native.([]int64); ok && idx >= 0 && int(idx) < len(elems) {
+				return types.Int(elems[idx]), true
+			}
+		case types.StringType:
+			if elems, ok := native.([]string); ok && idx >= 0 && int(idx) < len(elems) {
+				return types.String(elems[idx]), true
+			}
+		}
+	case types.String:
+		switch elemType {
+		case types.BoolType:
+			if m, ok := native.(map[string]bool); ok {
+				if v, found := m[string(idx)]; found {
+					return types.Bool(v), true
+				}
+			}
+		case types.IntType:
+			if m, ok := native.(map[string]int64); ok {
+				if v, found := m[string(idx)]; found {
+					return types.Int(v), true
+				}
+			}
+		case types.StringType:
+			if m, ok := native.(map[string]string); ok {
+				if v, found := m[string(idx)]; found {
+					return types.String(v), true
+				}
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"sort"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// StrictActivation configures the Interpretable created for the Program to
+// reject activations which bind variables other than the declared ones, e.g.
+// a misspelled 'requst', and, when requireDeclared is set, activations which
+// do not bind each of the declared variables. The variables of a checked
+// program are those of the Env it was checked against, see
+// checker.Env.Variables.
+//
+// The evaluation of a rejected activation returns an error without
+// evaluating the expression, rather than the unknown value of a variable
+// which was not bound. The bindings of the activations created by
+// NewActivation and the other constructors of this package, and of the
+// activations which combine them, are validated; those of other activations
+// are assumed to be valid, and their missing variables are found by
+// resolving each of the declared variables.
+func StrictActivation(declared []string, requireDeclared bool) ProgramOption {
+	return func(p *exprProgram) {
+		p.strictVariables = append([]string{}, declared...)
+		sort.Strings(p.strictVariables)
+		p.requireDeclared = requireDeclared
+	}
+}
+
+// strictActivationMiddleware returns the Middleware which validates the
+// activation of each evaluation against the declared variables.
+func strictActivationMiddleware(declared []string, requireDeclared bool) Middleware {
+	isDeclared := make(map[string]bool, len(declared))
+	for _, name := range declared {
+		isDeclared[name] = true
+	}
+	return func(next EvalFunc) EvalFunc {
+		return func(activation Activation) (ref.Value, EvalState) {
+			bound, listed := boundNames(activation)
+			if listed {
+				for _, name := range sortedKeys(bound) {
+					if !isDeclared[name] {
+						return types.NewErr("undeclared variable '%s' in activation", name),
+							NewEvalState(0)
+					}
+				}
+			}
+			if !requireDeclared {
+				return next(activation)
+			}
+			for _, name := range declared {
+				found := bound[name]
+				if !listed {
+					_, found = activation.ResolveName(name)
+				}
+				if !found {
+					return types.NewErr("missing declared variable '%s' in activation", name),
+						NewEvalState(0)
+				}
+			}
+			return next(activation)
+		}
+	}
+}
+
+// boundNames returns the names of the variables bound by an activation, or
+// false if the activation, or one of the activations it combines, is not
+// created by this package.
+func boundNames(activation Activation) (map[string]bool, bool) {
+	var bindings map[string]interface{}
+	switch a := activation.(type) {
+	case *mapActivation:
+		bindings = a.bindings
+	case *memoActivation:
+		bindings = a.bindings
+	case *trackingActivation:
+		bindings = a.bindings
+	case *contextActivation:
+		return boundNames(a.activation)
+	case *hierarchicalActivation:
+		parent, listed := boundNames(a.parent)
+		if !listed {
+			return nil, false
+		}
+		child, listed := boundNames(a.child)
+		if !listed {
+			return nil, false
+		}
+		for name := range child {
+			parent[name] = true
+		}
+		return parent, true
+	default:
+		return nil, false
+	}
+	names := make(map[string]bool, len(bindings))
+	for name := range bindings {
+		names[name] = true
+	}
+	return names, true
+}
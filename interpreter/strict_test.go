@@ -0,0 +1,84 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// unlistedActivation hides the bindings of an activation from StrictActivation.
+type unlistedActivation struct {
+	Activation
+}
+
+func TestStrictActivation(t *testing.T) {
+	declared := []string{"request", "limit"}
+	for _, tst := range []struct {
+		requireDeclared bool
+		activation      Activation
+		expected        ref.Value
+	}{
+		{activation: NewActivation(map[string]interface{}{"request": 1, "limit": 2}),
+			expected: types.True},
+		{activation: NewActivation(map[string]interface{}{"requst": 1, "limit": 2}),
+			expected: types.NewErr("undeclared variable 'requst' in activation")},
+		{activation: NewActivation(map[string]interface{}{"limit": 2}),
+			expected: types.NewUnknownAttribute(1, "request")},
+		{requireDeclared: true,
+			activation: NewActivation(map[string]interface{}{"limit": 2}),
+			expected:   types.NewErr("missing declared variable 'request' in activation")},
+		{requireDeclared: true,
+			activation: NewContextActivation(context.Background(), NewHierarchicalActivation(
+				NewMemoizingActivation(map[string]interface{}{"request": 1}),
+				NewTrackingActivation(map[string]interface{}{"limit": 2, "lmit": 3}))),
+			expected: types.NewErr("undeclared variable 'lmit' in activation")},
+		{requireDeclared: true,
+			activation: unlistedActivation{NewActivation(map[string]interface{}{
+				"request": 1, "limit": 2, "other": 3})},
+			expected: types.True},
+		{requireDeclared: true,
+			activation: unlistedActivation{NewActivation(map[string]interface{}{"request": 1})},
+			expected:   types.NewErr("missing declared variable 'limit' in activation")},
+	} {
+		for _, opts := range [][]ProgramOption{{}, {TreeEvaluation()}} {
+			program := parsedProgram(t, "request < limit")
+			for _, opt := range append(opts, StrictActivation(declared, tst.requireDeclared)) {
+				opt(program.(*exprProgram))
+			}
+			result, _ := interpreter.NewInterpretable(program).Eval(tst.activation)
+			if types.IsError(tst.expected) {
+				if !types.IsError(result) || result.(*types.Err).String() !=
+					tst.expected.(*types.Err).String() {
+					t.Errorf("Got '%v' with %+v, wanted '%v'",
+						result, program.Config(), tst.expected)
+				}
+				continue
+			}
+			if types.IsUnknown(tst.expected) {
+				if !types.IsUnknown(result) {
+					t.Errorf("Got '%v', wanted an unknown", result)
+				}
+				continue
+			}
+			if result != tst.expected {
+				t.Errorf("Got '%v' with %+v, wanted '%v'", result, program.Config(), tst.expected)
+			}
+		}
+	}
+}
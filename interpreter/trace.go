@@ -0,0 +1,83 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"sync/atomic"
+
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// TraceEvent is a value produced by an expression during an evaluation.
+type TraceEvent struct {
+	ExprId int64
+	Value  ref.Value
+}
+
+// Trace holds the values produced by the expressions of an evaluation, in the
+// order in which they were produced. Unlike the EvalState, which holds the
+// last value of each expression, a trace holds every value produced within
+// the iterations of a comprehension.
+type Trace []TraceEvent
+
+// EvalTrace returns the trace of the evaluation which produced the EvalState,
+// or false if the evaluation was not sampled, as described for SampleTraces.
+func EvalTrace(state EvalState) (Trace, bool) {
+	if s, isDefault := state.(*defaultEvalState); isDefault && s.trace != nil {
+		return *s.trace, true
+	}
+	return nil, false
+}
+
+// add appends a value to the trace. A nil trace records nothing.
+func (t *Trace) add(id int64, value ref.Value) {
+	if t == nil {
+		return
+	}
+	*t = append(*t, TraceEvent{ExprId: id, Value: value})
+}
+
+// traceSampler selects the evaluations which are traced. The evaluations are
+// counted rather than sampled at random, so that exactly the configured
+// fraction of the evaluations is traced, e.g. every fourth evaluation for a
+// fraction of 0.25.
+type traceSampler struct {
+	// count is the number of evaluations, which is accessed atomically and
+	// so comes first to be 64-bit aligned.
+	count    uint64
+	fraction float64
+}
+
+// newTraceSampler returns a sampler of the fraction of evaluations configured
+// for the program, or nil when no evaluations are traced.
+func newTraceSampler(p *exprProgram) *traceSampler {
+	if p.traceFraction <= 0 {
+		return nil
+	}
+	return &traceSampler{fraction: p.traceFraction}
+}
+
+// sample returns a new trace when the next evaluation is sampled, and nil
+// otherwise.
+func (s *traceSampler) sample() *Trace {
+	if s == nil {
+		return nil
+	}
+	n := atomic.AddUint64(&s.count, 1)
+	if s.fraction < 1 && uint64(float64(n)*s.fraction) == uint64(float64(n-1)*s.fraction) {
+		return nil
+	}
+	return &Trace{}
+}
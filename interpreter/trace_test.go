@@ -0,0 +1,93 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/parser"
+)
+
+func TestSampleTraces(t *testing.T) {
+	parsed, errors := parser.ParseText("[1, 2, 3].exists(x, x == a) && b")
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	activation := NewActivation(map[string]interface{}{"a": 3, "b": true})
+	for _, opts := range [][]ProgramOption{
+		{SampleTraces(1)},
+		{SampleTraces(1), FuseInstructions()},
+		{SampleTraces(1), TreeEvaluation()},
+	} {
+		program := NewProgram(parsed.GetExpr(), parsed.GetSourceInfo(), opts...)
+		res, state := interpreter.NewInterpretable(program).Eval(activation)
+		if res != types.True {
+			t.Fatalf("Got '%v' with %+v, wanted 'true'", res, program.Config())
+		}
+		trace, sampled := EvalTrace(state)
+		if !sampled {
+			t.Fatalf("Got no trace with %+v", program.Config())
+		}
+		// The trace holds the value of the loop step for each element, while
+		// the EvalState only holds the last.
+		ids := make(map[int64]bool)
+		for _, event := range trace {
+			ids[event.ExprId] = true
+		}
+		if len(ids) == len(trace) {
+			t.Errorf("Got %v with %+v, wanted repeated expressions", trace, program.Config())
+		}
+		last := trace[len(trace)-1]
+		if value, _ := state.Value(last.ExprId); value != last.Value {
+			t.Errorf("Got %v for the last event with %+v, wanted %v",
+				value, program.Config(), last.Value)
+		}
+	}
+}
+
+func TestSampleTraces_Fraction(t *testing.T) {
+	for _, opts := range [][]ProgramOption{
+		{SampleTraces(0.25)},
+		{SampleTraces(0.25), TreeEvaluation()},
+	} {
+		program := parsedProgram(t, "a + 1")
+		for _, opt := range opts {
+			opt(program.(*exprProgram))
+		}
+		interpretable := interpreter.NewInterpretable(program)
+		var sampled []int
+		for i := 1; i <= 8; i++ {
+			_, state := interpretable.Eval(NewActivation(map[string]interface{}{"a": i}))
+			if _, found := EvalTrace(state); found {
+				sampled = append(sampled, i)
+			}
+		}
+		if expected := []int{4, 8}; !reflect.DeepEqual(sampled, expected) {
+			t.Errorf("Got evaluations %v traced with %+v, wanted %v",
+				sampled, program.Config(), expected)
+		}
+	}
+}
+
+func TestSampleTraces_Disabled(t *testing.T) {
+	program := parsedProgram(t, "a + 1")
+	_, state := interpreter.NewInterpretable(program).Eval(
+		NewActivation(map[string]interface{}{"a": 1}))
+	if trace, sampled := EvalTrace(state); sampled {
+		t.Errorf("Got trace %v, wanted none", trace)
+	}
+}
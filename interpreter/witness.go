@@ -0,0 +1,150 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"sort"
+
+	"github.com/google/cel-go/common/types"
+)
+
+// Witnesses are example inputs of a boolean expression which make it true
+// and false, e.g. for policy authors to sanity-check a new rule against
+// generated test cases.
+type Witnesses struct {
+	// True and False are the bindings of an activation for which the
+	// expression evaluates to true and false, or nil when none was found.
+	True  map[string]interface{}
+	False map[string]interface{}
+
+	// Truncated is true when the search reached its limit before both
+	// witnesses were found. When the search was not truncated, a missing
+	// witness shows that the expression cannot evaluate to that value for
+	// any combination of the candidate values.
+	Truncated bool
+}
+
+// FindWitnesses searches the combinations of the candidate values of each
+// variable for activations in which the interpretable evaluates to true and
+// to false. Evaluations which produce errors, unknowns, or values other than
+// bools are not witnesses.
+//
+// The witnesses are minimal: the activations which bind the fewest variables
+// are searched first, so a variable is only bound when the result depends
+// on it. The search stops after the given number of evaluations, or when
+// both witnesses are found. A limit of zero is unlimited, and searches every
+// combination of the candidate values with and without each variable.
+func FindWitnesses(interpretable Interpretable,
+	domains map[string][]interface{}, limit int) *Witnesses {
+	names := make([]string, 0, len(domains))
+	for name := range domains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s := &witnessSearch{
+		interpretable: interpretable,
+		domains:       domains,
+		limit:         limit,
+		bindings:      make(map[string]interface{}),
+		witnesses:     &Witnesses{}}
+	for size := 0; size <= len(names); size++ {
+		if !s.combinations(names, size) {
+			break
+		}
+	}
+	return s.witnesses
+}
+
+type witnessSearch struct {
+	interpretable Interpretable
+	domains       map[string][]interface{}
+	limit         int
+	evaluations   int
+	// bindings holds the values of the variables bound by the combination
+	// being searched.
+	bindings  map[string]interface{}
+	witnesses *Witnesses
+}
+
+// combinations searches the combinations of the given number of variables
+// from the names, and returns whether the search should continue.
+func (s *witnessSearch) combinations(names []string, size int) bool {
+	if size == 0 {
+		return s.values(s.boundNames())
+	}
+	for i := 0; i+size <= len(names); i++ {
+		s.bindings[names[i]] = nil
+		more := s.combinations(names[i+1:], size-1)
+		delete(s.bindings, names[i])
+		if !more {
+			return false
+		}
+	}
+	return true
+}
+
+// values searches the combinations of the candidate values of the variables,
+// and returns whether the search should continue.
+func (s *witnessSearch) values(names []string) bool {
+	if len(names) == 0 {
+		return s.eval()
+	}
+	for _, value := range s.domains[names[0]] {
+		s.bindings[names[0]] = value
+		if !s.values(names[1:]) {
+			return false
+		}
+	}
+	return true
+}
+
+// eval evaluates the current bindings, and returns whether the search should
+// continue.
+func (s *witnessSearch) eval() bool {
+	if s.limit != 0 && s.evaluations == s.limit {
+		s.witnesses.Truncated = true
+		return false
+	}
+	s.evaluations++
+	result, _ := s.interpretable.Eval(NewActivation(s.bindings))
+	switch result {
+	case types.True:
+		if s.witnesses.True == nil {
+			s.witnesses.True = s.copyBindings()
+		}
+	case types.False:
+		if s.witnesses.False == nil {
+			s.witnesses.False = s.copyBindings()
+		}
+	}
+	return s.witnesses.True == nil || s.witnesses.False == nil
+}
+
+func (s *witnessSearch) boundNames() []string {
+	names := make([]string, 0, len(s.bindings))
+	for name := range s.bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *witnessSearch) copyBindings() map[string]interface{} {
+	bindings := make(map[string]interface{}, len(s.bindings))
+	for name, value := range s.bindings {
+		bindings[name] = value
+	}
+	return bindings
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindWitnesses(t *testing.T) {
+	domains := map[string][]interface{}{
+		"role":  {"user", "admin"},
+		"owner": {"alice", "bob"},
+		"level": {1, 5, 10}}
+	for _, tst := range []struct {
+		text      string
+		whenTrue  map[string]interface{}
+		whenFalse map[string]interface{}
+	}{
+		{text: `role == 'admin'`,
+			whenTrue:  map[string]interface{}{"role": "admin"},
+			whenFalse: map[string]interface{}{"role": "user"}},
+		{text: `role == 'admin' || owner == 'bob' && level > 3`,
+			whenTrue:  map[string]interface{}{"role": "admin"},
+			whenFalse: map[string]interface{}{"level": 1, "role": "user"}},
+		{text: `level > 0`,
+			whenTrue: map[string]interface{}{"level": 1}},
+		{text: `role == 'root'`,
+			whenFalse: map[string]interface{}{"role": "user"}},
+		{text: `[1, 5].exists(l, l == level)`,
+			whenTrue:  map[string]interface{}{"level": 1},
+			whenFalse: map[string]interface{}{"level": 10}},
+	} {
+		interpretable := interpreter.NewInterpretable(parsedProgram(t, tst.text))
+		witnesses := FindWitnesses(interpretable, domains, 0)
+		if !reflect.DeepEqual(witnesses.True, tst.whenTrue) ||
+			!reflect.DeepEqual(witnesses.False, tst.whenFalse) {
+			t.Errorf("%s: got true for %v and false for %v, wanted %v and %v",
+				tst.text, witnesses.True, witnesses.False, tst.whenTrue, tst.whenFalse)
+		}
+		if witnesses.Truncated {
+			t.Errorf("%s: got a truncated search, wanted a complete one", tst.text)
+		}
+	}
+}
+
+func TestFindWitnesses_Limit(t *testing.T) {
+	interpretable := interpreter.NewInterpretable(
+		parsedProgram(t, `a == 3 && b == 3`))
+	domains := map[string][]interface{}{
+		"a": {1, 2, 3},
+		"b": {1, 2, 3}}
+	witnesses := FindWitnesses(interpretable, domains, 5)
+	if witnesses.True != nil || !witnesses.Truncated {
+		t.Errorf("Got %+v, wanted a truncated search without a true witness", witnesses)
+	}
+	witnesses = FindWitnesses(interpretable, domains, 0)
+	if expected := map[string]interface{}{"a": 3, "b": 3}; !reflect.DeepEqual(witnesses.True, expected) {
+		t.Errorf("Got %v, wanted %v", witnesses.True, expected)
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loader maintains sets of compiled expressions which are reloaded
+// from a Source as new versions of the expressions become available.
+package loader
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+)
+
+// EnvFunc returns the Env against which expressions are checked. Type-check
+// errors must be reported to the given errors.
+type EnvFunc func(errors *common.Errors) *checker.Env
+
+// ProgramSet is an immutable version of a set of compiled expressions.
+type ProgramSet struct {
+	// Version is incremented each time a new set of expressions is loaded.
+	Version int64
+
+	// Expressions holds the source text of the expressions, by name.
+	Expressions map[string]string
+
+	interpretables map[string]interpreter.Interpretable
+}
+
+// Eval evaluates the named expression, returning false if no expression of
+// the name is in the set.
+func (s *ProgramSet) Eval(name string,
+	activation interpreter.Activation) (ref.Value, interpreter.EvalState, bool) {
+	interpretable, found := s.interpretables[name]
+	if !found {
+		return nil, nil, false
+	}
+	val, state := interpretable.Eval(activation)
+	return val, state, true
+}
+
+// CompileError reports an expression which failed to parse or type-check.
+type CompileError struct {
+	Name   string
+	Errors *common.Errors
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("failed to compile '%s':\n%s", e.Name, e.Errors.ToDisplayString())
+}
+
+// Option configures a Loader.
+type Option func(*Loader)
+
+// OnRollback registers a hook which is called with the error when a version of
+// the expressions fails to load, along with the ProgramSet which remains
+// active. The active set is nil if no version has loaded.
+func OnRollback(hook func(err error, active *ProgramSet)) Option {
+	return func(l *Loader) {
+		l.rollback = hook
+	}
+}
+
+// Metrics records the shape of each expression compiled by a Loader, e.g. so
+// that the distribution of the complexity of deployed expressions may be
+// monitored.
+type Metrics interface {
+	// RecordCompile is called with the complexity of the named expression
+	// once it has been checked, including the calls of each overload of the
+	// declared functions.
+	RecordCompile(name string, score *checker.ComplexityScore)
+}
+
+// RecordMetrics registers the Metrics which record each compiled expression.
+func RecordMetrics(metrics Metrics) Option {
+	return func(l *Loader) {
+		l.metrics = metrics
+	}
+}
+
+// OnWarnings registers a hook which is called with the warnings and other
+// issues of lesser severity than errors which are found while compiling the
+// named expression, such as the uses of deprecated declarations. Expressions
+// with warnings are loaded, and the hook is not called for expressions which
+// fail to compile.
+func OnWarnings(hook func(name string, warnings []common.Error)) Option {
+	return func(l *Loader) {
+		l.warnings = hook
+	}
+}
+
+// WarnComplexity reports a warning to the OnWarnings hook for each expression
+// whose complexity exceeds the limits, rather than failing the load.
+func WarnComplexity(limits *checker.ComplexityLimits) Option {
+	return func(l *Loader) {
+		l.envOpts = append(l.envOpts, cel.WarnComplexity(limits))
+	}
+}
+
+// Parallelism limits the number of expressions compiled concurrently by
+// CompileAll and Load, which is the number of CPUs by default.
+func Parallelism(n int) Option {
+	return func(l *Loader) {
+		l.envOpts = append(l.envOpts, cel.Parallelism(n))
+	}
+}
+
+// ProgramOptions configures the options of the Programs created by the
+// Loader.
+func ProgramOptions(opts ...interpreter.ProgramOption) Option {
+	return func(l *Loader) {
+		l.envOpts = append(l.envOpts, cel.ProgramOptions(opts...))
+	}
+}
+
+// Loader compiles versions of a set of expressions and atomically swaps the
+// active ProgramSet once all expressions within a version have compiled.
+//
+// Evaluation through the active ProgramSet never blocks on loading, and a
+// version which fails to compile leaves the previous version active.
+type Loader struct {
+	env      *cel.Env
+	envOpts  []cel.EnvOption
+	rollback func(err error, active *ProgramSet)
+	metrics  Metrics
+	warnings func(name string, warnings []common.Error)
+
+	// Serializes loads so versions are activated in the order received.
+	mutex   sync.Mutex
+	version int64
+	active  atomic.Value
+}
+
+// NewLoader creates a Loader which checks expressions against the Env
+// returned by env and evaluates them with the Interpreter. Expressions are
+// compiled concurrently, so env may be called concurrently unless the
+// Parallelism of the Loader is 1.
+func NewLoader(env EnvFunc,
+	interpreter interpreter.Interpreter,
+	opts ...Option) *Loader {
+	l := &Loader{}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.env = cel.NewEnv(cel.EnvFunc(env), interpreter,
+		append(l.envOpts, cel.OnCompiled(l.compiled))...)
+	return l
+}
+
+// CompileAll compiles the expressions, keyed by name, without activating
+// them, as described for cel.Env.CompileAll. The Metrics and OnWarnings
+// hooks of the Loader are called for each expression which compiled, in the
+// order of their names.
+func (l *Loader) CompileAll(expressions map[string]string) (
+	map[string]interpreter.Interpretable, map[string]*common.Errors) {
+	return l.env.CompileAll(expressions)
+}
+
+func (l *Loader) compiled(name string,
+	score *checker.ComplexityScore, issues *common.Errors) {
+	if l.metrics != nil {
+		l.metrics.RecordCompile(name, score)
+	}
+	if l.warnings != nil && issues != nil {
+		l.warnings(name, issues.GetWarnings())
+	}
+}
+
+// compile compiles the expressions as CompileAll, and returns an error for the
+// first expression, by name, which failed to compile.
+func (l *Loader) compile(
+	expressions map[string]string) (map[string]interpreter.Interpretable, error) {
+	interpretables, issues := l.CompileAll(expressions)
+	var names []string
+	for name, errors := range issues {
+		if len(errors.GetErrors()) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) != 0 {
+		sort.Strings(names)
+		return nil, &CompileError{Name: names[0], Errors: issues[names[0]]}
+	}
+	return interpretables, nil
+}
+
+// Active returns the active ProgramSet, or nil if no version has loaded.
+func (l *Loader) Active() *ProgramSet {
+	active, _ := l.active.Load().(*ProgramSet)
+	return active
+}
+
+// Load compiles the expressions, keyed by name, and activates them. If any
+// expression fails to compile, the active ProgramSet is left in place, the
+// rollback hook is called and the error is returned.
+func (l *Loader) Load(expressions map[string]string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	interpretables, err := l.compile(expressions)
+	if err != nil {
+		l.fail(err)
+		return err
+	}
+	l.version++
+	copied := make(map[string]string, len(expressions))
+	for name, text := range expressions {
+		copied[name] = text
+	}
+	l.active.Store(&ProgramSet{
+		Version:        l.version,
+		Expressions:    copied,
+		interpretables: interpretables})
+	return nil
+}
+
+// Watch loads each version of the expressions produced by the Source in the
+// background until the returned stop function is called.
+func (l *Loader) Watch(source Source) (stop func()) {
+	updates := make(chan Update)
+	done := make(chan struct{})
+	go source.Watch(updates, done)
+	go func() {
+		for {
+			select {
+			case update := <-updates:
+				if update.Err != nil {
+					l.fail(update.Err)
+					continue
+				}
+				l.Load(update.Expressions)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+func (l *Loader) fail(err error) {
+	if l.rollback != nil {
+		l.rollback(err, l.Active())
+	}
+}
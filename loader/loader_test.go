@@ -0,0 +1,270 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+func newTestLoader(opts ...Option) *Loader {
+	provider := types.NewProvider(&expr.ParsedExpr{})
+	env := func(errors *common.Errors) *checker.Env {
+		env := checker.NewStandardEnv(packages.DefaultPackage, provider, errors)
+		env.Add(decls.NewIdent("x", decls.Int, nil))
+		return env
+	}
+	return NewLoader(env,
+		interpreter.NewStandardIntepreter(packages.DefaultPackage, provider),
+		opts...)
+}
+
+func TestLoader_Load(t *testing.T) {
+	var rollbacks []error
+	loader := newTestLoader(OnRollback(func(err error, active *ProgramSet) {
+		if active != nil && active.Version != 1 {
+			t.Errorf("Got active version %d on rollback, wanted 1", active.Version)
+		}
+		rollbacks = append(rollbacks, err)
+	}))
+	if loader.Active() != nil {
+		t.Fatal("Got an active set before loading")
+	}
+	if err := loader.Load(map[string]string{"pos": "x > 0"}); err != nil {
+		t.Fatal(err)
+	}
+	activation := interpreter.NewActivation(map[string]interface{}{"x": 2})
+	active := loader.Active()
+	if val, _, found := active.Eval("pos", activation); !found || val != types.True {
+		t.Errorf("Got %v, %t, wanted true", val, found)
+	}
+	if _, _, found := active.Eval("neg", activation); found {
+		t.Error("Found an expression which was not loaded")
+	}
+
+	// Versions which fail to parse or type-check are rolled back.
+	for _, expressions := range []map[string]string{
+		{"pos": "x > 0", "neg": "x <"},
+		{"pos": "x > 0", "neg": "x < 'zero'"},
+	} {
+		err := loader.Load(expressions)
+		if err == nil {
+			t.Fatalf("%v: got nil error, wanted a compile error", expressions)
+		}
+		if compileErr, ok := err.(*CompileError); !ok || compileErr.Name != "neg" {
+			t.Errorf("%v: got %v, wanted a compile error for 'neg'", expressions, err)
+		}
+		if loader.Active() != active {
+			t.Errorf("%v: active set was swapped", expressions)
+		}
+	}
+	if len(rollbacks) != 2 {
+		t.Errorf("Got %d rollbacks, wanted 2", len(rollbacks))
+	}
+
+	if err := loader.Load(map[string]string{"neg": "x < 0"}); err != nil {
+		t.Fatal(err)
+	}
+	if loader.Active().Version != 2 {
+		t.Errorf("Got version %d, wanted 2", loader.Active().Version)
+	}
+	if val, _, _ := loader.Active().Eval("neg", activation); val != types.False {
+		t.Errorf("Got %v, wanted false", val)
+	}
+	// Previously active sets remain usable.
+	if val, _, _ := active.Eval("pos", activation); val != types.True {
+		t.Errorf("Got %v, wanted true", val)
+	}
+}
+
+type testMetrics map[string]*checker.ComplexityScore
+
+func (m testMetrics) RecordCompile(name string, score *checker.ComplexityScore) {
+	m[name] = score
+}
+
+func TestLoader_RecordMetrics(t *testing.T) {
+	metrics := testMetrics{}
+	loader := newTestLoader(RecordMetrics(metrics))
+	if err := loader.Load(map[string]string{
+		"pos":  "x > 0",
+		"name": "[1, 2].exists(y, y == x) && 'abc' != string(x)"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("Got metrics of %d expressions, wanted 2", len(metrics))
+	}
+	if pos := metrics["pos"]; pos.Nodes != 3 || pos.Depth != 2 ||
+		pos.Overloads["greater_int64"] != 1 {
+		t.Errorf("Got %+v for 'pos', wanted 3 nodes of depth 2", pos)
+	}
+	name := metrics["name"]
+	if name.Comprehensions != 1 || name.LiteralBytes != 3 ||
+		name.Overloads["int64_to_string"] != 1 {
+		t.Errorf("Got %+v for 'name', wanted 1 comprehension and 3 literal bytes", name)
+	}
+}
+
+func TestLoader_OnWarnings(t *testing.T) {
+	warnings := make(map[string][]string)
+	loader := newTestLoader(
+		WarnComplexity(&checker.ComplexityLimits{MaxNodes: 3}),
+		OnWarnings(func(name string, issues []common.Error) {
+			for _, issue := range issues {
+				warnings[name] = append(warnings[name],
+					fmt.Sprintf("%s %d:%d", issue.Severity, issue.Location.Line(),
+						issue.Location.Column()))
+			}
+		}))
+	if err := loader.Load(map[string]string{
+		"pos":  "x > 0",
+		"neg":  "!!(x < 0)",
+		"long": "x + 1 > 2"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"neg":  {"WARNING 1:0"},
+		"long": {"WARNING 1:0"},
+	}
+	if fmt.Sprint(warnings) != fmt.Sprint(expected) {
+		t.Errorf("Got warnings %v, wanted %v", warnings, expected)
+	}
+	if _, _, found := loader.Active().Eval("long", interpreter.NewActivation(
+		map[string]interface{}{"x": 2})); !found {
+		t.Error("Expression with warnings was not loaded")
+	}
+}
+
+func TestLoader_CompileAll(t *testing.T) {
+	loader := newTestLoader(Parallelism(3))
+	expressions := map[string]string{
+		"bad":   "x +",
+		"dup":   "x * 2 > 10",
+		"neg":   "!!(x < 0)",
+		"pos":   "x * 2 > 10",
+		"undef": "y > 0",
+	}
+	for i := 0; i < 20; i++ {
+		expressions[fmt.Sprintf("gen%d", i)] = fmt.Sprintf("x == %d", i)
+	}
+	interpretables, issues := loader.CompileAll(expressions)
+	if len(interpretables) != 23 {
+		t.Errorf("Got %d interpretables, wanted 23", len(interpretables))
+	}
+	if interpretables["dup"] != interpretables["pos"] {
+		t.Error("Expressions of the same text were compiled twice")
+	}
+	if result, _ := interpretables["gen7"].Eval(interpreter.NewActivation(
+		map[string]interface{}{"x": 7})); result != types.True {
+		t.Errorf("Got '%v' from 'gen7', wanted true", result)
+	}
+	var names []string
+	for name := range issues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if fmt.Sprint(names) != "[bad neg undef]" {
+		t.Errorf("Got issues for %v, wanted [bad neg undef]", names)
+	}
+	if len(issues["neg"].GetErrors()) != 0 || len(issues["neg"].GetWarnings()) != 1 {
+		t.Errorf("Got issues %s for 'neg', wanted one warning", issues["neg"].ToDisplayString())
+	}
+	if display := issues["undef"].ToDisplayString(); !strings.HasPrefix(display,
+		"ERROR: undef:1:1: undeclared reference to 'y'") {
+		t.Errorf("Got issues %s for 'undef'", display)
+	}
+
+	// Load fails with the errors of the first expression which did not
+	// compile.
+	err := loader.Load(expressions)
+	if compileErr, ok := err.(*CompileError); !ok || compileErr.Name != "bad" {
+		t.Errorf("Got error %v, wanted a compile error of 'bad'", err)
+	}
+}
+
+func TestLoader_WatchChannel(t *testing.T) {
+	errs := make(chan error, 1)
+	loader := newTestLoader(OnRollback(func(err error, active *ProgramSet) {
+		errs <- err
+	}))
+	ch := make(chan map[string]string)
+	stop := loader.Watch(ChannelSource(ch))
+	defer stop()
+
+	ch <- map[string]string{"pos": "x > 0"}
+	waitForVersion(t, loader, 1)
+	ch <- map[string]string{"pos": "x >"}
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for rollback")
+	}
+	ch <- map[string]string{"pos": "x >= 0"}
+	waitForVersion(t, loader, 2)
+	if expr := loader.Active().Expressions["pos"]; expr != "x >= 0" {
+		t.Errorf("Got %s, wanted x >= 0", expr)
+	}
+}
+
+func TestLoader_WatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "expressions.json")
+	if err := ioutil.WriteFile(path, []byte(`{"pos": "x > 0"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loader := newTestLoader()
+	stop := loader.Watch(FileSource(path, time.Millisecond))
+	defer stop()
+	waitForVersion(t, loader, 1)
+
+	if err := ioutil.WriteFile(path, []byte(`{"pos": "x > 10"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForVersion(t, loader, 2)
+	activation := interpreter.NewActivation(map[string]interface{}{"x": 2})
+	if val, _, _ := loader.Active().Eval("pos", activation); val != types.False {
+		t.Errorf("Got %v, wanted false", val)
+	}
+}
+
+func waitForVersion(t *testing.T, loader *Loader, version int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if active := loader.Active(); active != nil && active.Version >= version {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for version %d", version)
+}
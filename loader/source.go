@@ -0,0 +1,151 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Update is a version of a set of expressions, keyed by name, or the error
+// encountered while reading it.
+type Update struct {
+	Expressions map[string]string
+	Err         error
+}
+
+// Source produces versions of a set of expressions.
+type Source interface {
+	// Watch sends each version of the expressions to updates until stop is
+	// closed. Sends must not block once stop is closed.
+	Watch(updates chan<- Update, stop <-chan struct{})
+}
+
+// ChannelSource returns a Source which produces the expressions received from
+// a channel.
+func ChannelSource(ch <-chan map[string]string) Source {
+	return channelSource(ch)
+}
+
+type channelSource <-chan map[string]string
+
+func (ch channelSource) Watch(updates chan<- Update, stop <-chan struct{}) {
+	for {
+		select {
+		case expressions, ok := <-ch:
+			if !ok {
+				return
+			}
+			if !send(updates, Update{Expressions: expressions}, stop) {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// CallbackSource returns a Source which calls fetch at the given interval and
+// produces the expressions it returns whenever they change. A nil result
+// without an error indicates that the expressions are unchanged.
+func CallbackSource(fetch func() (map[string]string, error),
+	interval time.Duration) Source {
+	return &callbackSource{fetch: fetch, interval: interval}
+}
+
+type callbackSource struct {
+	fetch    func() (map[string]string, error)
+	interval time.Duration
+}
+
+func (s *callbackSource) Watch(updates chan<- Update, stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	var last map[string]string
+	for {
+		expressions, err := s.fetch()
+		changed := expressions != nil &&
+			(last == nil || !equalExpressions(last, expressions))
+		if err != nil || changed {
+			if !send(updates, Update{Expressions: expressions, Err: err}, stop) {
+				return
+			}
+			if err == nil {
+				last = expressions
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// FileSource returns a Source which reads the expressions from a JSON file
+// holding an object of expression names to expression text, e.g.
+//
+//     {"allow": "request.user in admins"}
+//
+// The file is polled at the given interval and read whenever its size or
+// modification time changes.
+func FileSource(path string, interval time.Duration) Source {
+	var modified time.Time
+	var size int64 = -1
+	fetch := func() (map[string]string, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.ModTime().Equal(modified) && info.Size() == size {
+			return nil, nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		// Invalid contents are reported once rather than on each poll.
+		modified, size = info.ModTime(), info.Size()
+		expressions := make(map[string]string)
+		if err := json.Unmarshal(data, &expressions); err != nil {
+			return nil, err
+		}
+		return expressions, nil
+	}
+	return &callbackSource{fetch: fetch, interval: interval}
+}
+
+func send(updates chan<- Update, update Update, stop <-chan struct{}) bool {
+	select {
+	case updates <- update:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+func equalExpressions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, text := range a {
+		if other, found := b[name]; !found || other != text {
+			return false
+		}
+	}
+	return true
+}
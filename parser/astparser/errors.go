@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package parser
+package astparser
 
 import (
 	"fmt"
@@ -40,3 +40,7 @@ func (e *parseErrors) argumentIsNotIdent(l common.Location) {
 func (e *parseErrors) notAQualifiedName(l common.Location) {
 	e.ReportError(l, "expected a qualified name")
 }
+
+func (e *parseErrors) repeatedOperator(l common.Location, op string) {
+	e.ReportWarning(l, "redundant repetition of the unary operator '%s'", op)
+}
@@ -1,9 +1,9 @@
-package parser
+package astparser
 
 import (
 	"github.com/antlr/antlr4/runtime/Go/antlr"
 	"github.com/google/cel-go/common"
-	expr "github.com/google/cel-spec/proto/v1/syntax"
+	"github.com/google/cel-go/common/ast"
 )
 
 type parserHelper struct {
@@ -29,14 +29,14 @@ func newParserHelper(source common.Source, macros Macros) *parserHelper {
 	}
 }
 
-func (p *parserHelper) getSourceInfo() *expr.SourceInfo {
-	return &expr.SourceInfo{
+func (p *parserHelper) getSourceInfo() *ast.SourceInfo {
+	return &ast.SourceInfo{
 		Location:    p.source.Description(),
 		Positions:   p.positions,
 		LineOffsets: p.source.LineOffsets()}
 }
 
-func (p *parserHelper) reportError(ctx interface{}, format string, args ...interface{}) *expr.Expr {
+func (p *parserHelper) reportError(ctx interface{}, format string, args ...interface{}) *ast.Expr {
 	var location common.Location
 	switch ctx.(type) {
 	case common.Location:
@@ -51,142 +51,129 @@ func (p *parserHelper) reportError(ctx interface{}, format string, args ...inter
 	return err
 }
 
-func (p *parserHelper) newLiteral(ctx interface{}, value *expr.Literal) *expr.Expr {
+func (p *parserHelper) newLiteral(ctx interface{}, value interface{}) *ast.Expr {
 	exprNode := p.newExpr(ctx)
-	exprNode.ExprKind = &expr.Expr_LiteralExpr{LiteralExpr: value}
+	exprNode.Kind = &ast.Literal{Value: value}
 	return exprNode
 }
 
-func (p *parserHelper) newLiteralBool(ctx interface{}, value bool) *expr.Expr {
-	return p.newLiteral(ctx,
-		&expr.Literal{LiteralKind: &expr.Literal_BoolValue{value}})
+func (p *parserHelper) newLiteralBool(ctx interface{}, value bool) *ast.Expr {
+	return p.newLiteral(ctx, value)
 }
 
-func (p *parserHelper) newLiteralString(ctx interface{}, value string) *expr.Expr {
-	return p.newLiteral(ctx,
-		&expr.Literal{LiteralKind: &expr.Literal_StringValue{value}})
+func (p *parserHelper) newLiteralString(ctx interface{}, value string) *ast.Expr {
+	return p.newLiteral(ctx, value)
 }
 
-func (p *parserHelper) newLiteralBytes(ctx interface{}, value []byte) *expr.Expr {
-	return p.newLiteral(ctx,
-		&expr.Literal{LiteralKind: &expr.Literal_BytesValue{value}})
+func (p *parserHelper) newLiteralBytes(ctx interface{}, value []byte) *ast.Expr {
+	return p.newLiteral(ctx, value)
 }
 
-func (p *parserHelper) newLiteralInt(ctx interface{}, value int64) *expr.Expr {
-	return p.newLiteral(ctx,
-		&expr.Literal{LiteralKind: &expr.Literal_Int64Value{value}})
+func (p *parserHelper) newLiteralInt(ctx interface{}, value int64) *ast.Expr {
+	return p.newLiteral(ctx, value)
 }
 
-func (p *parserHelper) newLiteralUint(ctx interface{}, value uint64) *expr.Expr {
-	return p.newLiteral(ctx, &expr.Literal{LiteralKind: &expr.Literal_Uint64Value{value}})
+func (p *parserHelper) newLiteralUint(ctx interface{}, value uint64) *ast.Expr {
+	return p.newLiteral(ctx, value)
 }
 
-func (p *parserHelper) newLiteralDouble(ctx interface{}, value float64) *expr.Expr {
-	return p.newLiteral(ctx,
-		&expr.Literal{LiteralKind: &expr.Literal_DoubleValue{value}})
+func (p *parserHelper) newLiteralDouble(ctx interface{}, value float64) *ast.Expr {
+	return p.newLiteral(ctx, value)
 }
 
-func (p *parserHelper) newIdent(ctx interface{}, name string) *expr.Expr {
+func (p *parserHelper) newIdent(ctx interface{}, name string) *ast.Expr {
 	exprNode := p.newExpr(ctx)
-	exprNode.ExprKind = &expr.Expr_IdentExpr{IdentExpr: &expr.Expr_Ident{Name: name}}
+	exprNode.Kind = &ast.Ident{Name: name}
 	return exprNode
 }
 
-func (p *parserHelper) newSelect(ctx interface{}, operand *expr.Expr, field string) *expr.Expr {
+func (p *parserHelper) newSelect(ctx interface{}, operand *ast.Expr, field string) *ast.Expr {
 	exprNode := p.newExpr(ctx)
-	exprNode.ExprKind = &expr.Expr_SelectExpr{
-		SelectExpr: &expr.Expr_Select{Operand: operand, Field: field}}
+	exprNode.Kind = &ast.Select{Operand: operand, Field: field}
 	return exprNode
 }
 
-func (p *parserHelper) newPresenceTest(ctx interface{}, operand *expr.Expr, field string) *expr.Expr {
+func (p *parserHelper) newPresenceTest(ctx interface{}, operand *ast.Expr, field string) *ast.Expr {
 	exprNode := p.newExpr(ctx)
-	exprNode.ExprKind = &expr.Expr_SelectExpr{
-		SelectExpr: &expr.Expr_Select{Operand: operand, Field: field, TestOnly: true}}
+	exprNode.Kind = &ast.Select{Operand: operand, Field: field, TestOnly: true}
 	return exprNode
 }
 
-func (p *parserHelper) newGlobalCall(ctx interface{}, function string, args ...*expr.Expr) *expr.Expr {
+func (p *parserHelper) newGlobalCall(ctx interface{}, function string, args ...*ast.Expr) *ast.Expr {
 	if macro, found := p.macros[makeMacroKey(function, len(args), false)]; found {
 		return macro.expander(p, ctx, nil, args)
 	}
 	exprNode := p.newExpr(ctx)
-	exprNode.ExprKind = &expr.Expr_CallExpr{
-		CallExpr: &expr.Expr_Call{Function: function, Args: args}}
+	exprNode.Kind = &ast.Call{Function: function, Args: args}
 	return exprNode
 }
 
-func (p *parserHelper) newMemberCall(ctx interface{}, function string, target *expr.Expr, args ...*expr.Expr) *expr.Expr {
+func (p *parserHelper) newMemberCall(ctx interface{}, function string, target *ast.Expr, args ...*ast.Expr) *ast.Expr {
 	if macro, found := p.macros[makeMacroKey(function, len(args), true)]; found {
 		return macro.expander(p, ctx, target, args)
 	}
 	exprNode := p.newExpr(ctx)
-	exprNode.ExprKind = &expr.Expr_CallExpr{
-		CallExpr: &expr.Expr_Call{Function: function, Target: target, Args: args}}
+	exprNode.Kind = &ast.Call{Function: function, Target: target, Args: args}
 	return exprNode
 }
 
-func (p *parserHelper) newList(ctx interface{}, elements ...*expr.Expr) *expr.Expr {
+func (p *parserHelper) newList(ctx interface{}, elements ...*ast.Expr) *ast.Expr {
 	exprNode := p.newExpr(ctx)
-	exprNode.ExprKind = &expr.Expr_ListExpr{
-		ListExpr: &expr.Expr_CreateList{Elements: elements}}
+	exprNode.Kind = &ast.CreateList{Elements: elements}
 	return exprNode
 }
 
-func (p *parserHelper) newMap(ctx interface{}, entries ...*expr.Expr_CreateStruct_Entry) *expr.Expr {
+func (p *parserHelper) newMap(ctx interface{}, entries ...*ast.Entry) *ast.Expr {
 	exprNode := p.newExpr(ctx)
-	exprNode.ExprKind = &expr.Expr_StructExpr{
-		StructExpr: &expr.Expr_CreateStruct{Entries: entries}}
+	exprNode.Kind = &ast.CreateStruct{Entries: entries}
 	return exprNode
 }
 
-func (p *parserHelper) newMapEntry(ctx interface{}, key *expr.Expr, value *expr.Expr) *expr.Expr_CreateStruct_Entry {
-	return &expr.Expr_CreateStruct_Entry{
-		Id:      p.id(ctx),
-		KeyKind: &expr.Expr_CreateStruct_Entry_MapKey{MapKey: key},
-		Value:   value}
+func (p *parserHelper) newMapEntry(ctx interface{}, key *ast.Expr, value *ast.Expr) *ast.Entry {
+	return &ast.Entry{
+		Id:     p.id(ctx),
+		MapKey: key,
+		Value:  value}
 }
 
 func (p *parserHelper) newObject(ctx interface{},
 	typeName string,
-	entries ...*expr.Expr_CreateStruct_Entry) *expr.Expr {
+	entries ...*ast.Entry) *ast.Expr {
 	exprNode := p.newExpr(ctx)
-	exprNode.ExprKind = &expr.Expr_StructExpr{
-		StructExpr: &expr.Expr_CreateStruct{
-			MessageName: typeName,
-			Entries:     entries}}
+	exprNode.Kind = &ast.CreateStruct{
+		MessageName: typeName,
+		Entries:     entries}
 	return exprNode
 }
 
-func (p *parserHelper) newObjectField(ctx interface{}, field string, value *expr.Expr) *expr.Expr_CreateStruct_Entry {
-	return &expr.Expr_CreateStruct_Entry{
-		Id:      p.id(ctx),
-		KeyKind: &expr.Expr_CreateStruct_Entry_FieldKey{FieldKey: field},
-		Value:   value}
+func (p *parserHelper) newObjectField(ctx interface{}, field string, value *ast.Expr) *ast.Entry {
+	return &ast.Entry{
+		Id:       p.id(ctx),
+		FieldKey: field,
+		Value:    value}
 }
 
 func (p *parserHelper) newComprehension(ctx interface{}, iterVar string,
-	iterRange *expr.Expr,
+	iterRange *ast.Expr,
 	accuVar string,
-	accuInit *expr.Expr,
-	condition *expr.Expr,
-	step *expr.Expr,
-	result *expr.Expr) *expr.Expr {
+	accuInit *ast.Expr,
+	condition *ast.Expr,
+	step *ast.Expr,
+	result *ast.Expr) *ast.Expr {
 	exprNode := p.newExpr(ctx)
-	exprNode.ExprKind = &expr.Expr_ComprehensionExpr{
-		ComprehensionExpr: &expr.Expr_Comprehension{
-			AccuVar:       accuVar,
-			AccuInit:      accuInit,
-			IterVar:       iterVar,
-			IterRange:     iterRange,
-			LoopCondition: condition,
-			LoopStep:      step,
-			Result:        result}}
+	exprNode.Kind = &ast.Comprehension{
+		AccuVar:       accuVar,
+		AccuInit:      accuInit,
+		IterVar:       iterVar,
+		IterRange:     iterRange,
+		LoopCondition: condition,
+		LoopStep:      step,
+		Result:        result}
 	return exprNode
 }
 
-func (p *parserHelper) newExpr(ctx interface{}) *expr.Expr {
-	return &expr.Expr{Id: p.id(ctx)}
+func (p *parserHelper) newExpr(ctx interface{}) *ast.Expr {
+	return &ast.Expr{Id: p.id(ctx)}
 }
 
 func (p *parserHelper) id(ctx interface{}) int64 {
@@ -0,0 +1,98 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package astparser
+
+import (
+	goparser "go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const repoPath = "github.com/google/cel-go/"
+
+var (
+	// Import path prefixes of the protos and the protobuf runtime, which the
+	// parser must not link.
+	protoImports = []string{
+		"github.com/gogo/protobuf",
+		"github.com/golang/protobuf",
+		"github.com/google/cel-spec",
+		"google.golang.org/genproto",
+		"google.golang.org/protobuf",
+	}
+)
+
+// TestImports verifies that neither the astparser package nor the packages of
+// the repository which it imports, transitively, import the protos.
+func TestImports(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "parser", "astparser")); err != nil {
+		// The sources are not available, e.g. within a Bazel sandbox.
+		t.Skipf("repository sources not found: %v", err)
+	}
+	visited := make(map[string]bool)
+	var visit func(pkg string, from []string)
+	visit = func(pkg string, from []string) {
+		if visited[pkg] {
+			return
+		}
+		visited[pkg] = true
+		from = append(from, pkg)
+		for _, imp := range sourceImports(t, filepath.Join(root, filepath.FromSlash(pkg))) {
+			if strings.HasPrefix(imp, repoPath) {
+				visit(strings.TrimPrefix(imp, repoPath), from)
+				continue
+			}
+			for _, proto := range protoImports {
+				if strings.HasPrefix(imp, proto) {
+					t.Errorf("%s imports '%s'", strings.Join(from, " -> "), imp)
+				}
+			}
+		}
+	}
+	visit("parser/astparser", nil)
+}
+
+// sourceImports returns the import paths of the non-test sources of the
+// package within the directory.
+func sourceImports(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no sources within '%s': %v", dir, err)
+	}
+	var imports []string
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := goparser.ParseFile(token.NewFileSet(), file, nil, goparser.ImportsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, imp := range f.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			imports = append(imports, path)
+		}
+	}
+	return imports
+}
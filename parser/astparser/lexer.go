@@ -0,0 +1,113 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package astparser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	"github.com/google/cel-go/parser/gen"
+)
+
+// numericLexer wraps the generated CELLexer in order to recognize integer
+// literal forms which the grammar tokenizes as a NUM_INT immediately followed
+// by an IDENTIFIER:
+//
+//     1_000_000   // digit separators
+//     0xFF_FF     // hex digits with separators
+//     0o755       // octal
+//     0b1010      // binary
+//     1_000u      // any of the above with an unsigned suffix
+//
+// Adjacent tokens which together form one of these literals are merged into a
+// single NUM_INT or NUM_UINT token positioned at the start of the literal.
+type numericLexer struct {
+	*gen.CELLexer
+	pending antlr.Token
+}
+
+func newNumericLexer(lexer *gen.CELLexer) *numericLexer {
+	return &numericLexer{CELLexer: lexer}
+}
+
+func (l *numericLexer) NextToken() antlr.Token {
+	tok := l.nextToken()
+	if tok.GetTokenType() != gen.CELLexerNUM_INT {
+		return tok
+	}
+	next := l.nextToken()
+	if next.GetTokenType() != gen.CELLexerIDENTIFIER ||
+		next.GetStart() != tok.GetStop()+1 {
+		l.pending = next
+		return tok
+	}
+	text := tok.GetText() + next.GetText()
+	if !intLiteralPattern.MatchString(text) {
+		l.pending = next
+		return tok
+	}
+	tokenType := gen.CELLexerNUM_INT
+	if strings.HasSuffix(text, "u") || strings.HasSuffix(text, "U") {
+		tokenType = gen.CELLexerNUM_UINT
+	}
+	return l.GetTokenFactory().Create(tok.GetSource(), tokenType, text,
+		tok.GetChannel(), tok.GetStart(), next.GetStop(),
+		tok.GetLine(), tok.GetColumn())
+}
+
+func (l *numericLexer) nextToken() antlr.Token {
+	if l.pending != nil {
+		tok := l.pending
+		l.pending = nil
+		return tok
+	}
+	return l.CELLexer.NextToken()
+}
+
+// intLiteralDigits validates the digit separators within an integer literal
+// and returns the literal digits without the separators or radix prefix,
+// along with the radix of the literal.
+//
+// Separators may only appear between digits or directly after the radix
+// prefix, so '1__0', '_1', and '1_' are all invalid.
+func intLiteralDigits(text string) (string, int, error) {
+	base := 10
+	if len(text) > 2 && text[0] == '0' {
+		switch text[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			text = strings.TrimPrefix(text[2:], "_")
+		}
+	}
+	if strings.HasPrefix(text, "_") ||
+		strings.HasSuffix(text, "_") ||
+		strings.Contains(text, "__") {
+		return "", 0, fmt.Errorf("invalid digit separator")
+	}
+	return strings.Replace(text, "_", "", -1), base, nil
+}
+
+var (
+	intLiteralPattern = regexp.MustCompile(
+		`^(0[xX][_0-9a-fA-F]+|0[oO][_0-7]+|0[bB][_01]+|[0-9][_0-9]*)[uU]?$`)
+)
@@ -12,13 +12,14 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package parser
+package astparser
 
 import (
 	"fmt"
 
+	"github.com/google/cel-go/common/ast"
 	"github.com/google/cel-go/common/operators"
-	expr "github.com/google/cel-spec/proto/v1/syntax"
+	"github.com/google/cel-go/common/overloads"
 )
 
 // TODO: Consider moving macros to common.
@@ -32,7 +33,7 @@ type Macro struct {
 	name          string
 	instanceStyle bool
 	args          int
-	expander      func(*parserHelper, interface{}, *expr.Expr, []*expr.Expr) *expr.Expr
+	expander      func(*parserHelper, interface{}, *ast.Expr, []*ast.Expr) *ast.Expr
 }
 
 // AllMacros includes the list of all spec-supported macros.
@@ -68,6 +69,22 @@ var AllMacros = []Macro{
 		args:          2,
 		expander:      makeExistsOne,
 	},
+	// The macro "range.all_unique(var, key)", which is true if the key computed
+	// for each element in range differs from the keys of the other elements.
+	{
+		name:          operators.AllUnique,
+		instanceStyle: true,
+		args:          2,
+		expander:      makeAllUnique,
+	},
+	// The macro "range.exists_unique(var, key)", which is true if the key computed
+	// for at least one element in range differs from the keys of the other elements.
+	{
+		name:          operators.ExistsUnique,
+		instanceStyle: true,
+		args:          2,
+		expander:      makeExistsUnique,
+	},
 	// The macro "range.map(var, function)", applies the function to the vars in the range.
 	{
 		name:          operators.Map,
@@ -108,9 +125,9 @@ func (m *Macro) GetIsInstanceStyle() bool {
 	return m.instanceStyle
 }
 
-func makeHas(p *parserHelper, ctx interface{}, target *expr.Expr, args []*expr.Expr) *expr.Expr {
-	if s, ok := args[0].ExprKind.(*expr.Expr_SelectExpr); ok {
-		return p.newPresenceTest(ctx, s.SelectExpr.Operand, s.SelectExpr.Field)
+func makeHas(p *parserHelper, ctx interface{}, target *ast.Expr, args []*ast.Expr) *ast.Expr {
+	if s, ok := args[0].Kind.(*ast.Select); ok {
+		return p.newPresenceTest(ctx, s.Operand, s.Field)
 	}
 	return p.reportError(ctx, "invalid argument to has() macro")
 }
@@ -125,33 +142,33 @@ const (
 	quantifierExistsOne
 )
 
-func makeAll(p *parserHelper, ctx interface{}, target *expr.Expr, args []*expr.Expr) *expr.Expr {
+func makeAll(p *parserHelper, ctx interface{}, target *ast.Expr, args []*ast.Expr) *ast.Expr {
 	return makeQuantifier(quantifierAll, p, ctx, target, args)
 }
 
-func makeExists(p *parserHelper, ctx interface{}, target *expr.Expr, args []*expr.Expr) *expr.Expr {
+func makeExists(p *parserHelper, ctx interface{}, target *ast.Expr, args []*ast.Expr) *ast.Expr {
 	return makeQuantifier(quantifierExists, p, ctx, target, args)
 }
 
-func makeExistsOne(p *parserHelper, ctx interface{}, target *expr.Expr, args []*expr.Expr) *expr.Expr {
+func makeExistsOne(p *parserHelper, ctx interface{}, target *ast.Expr, args []*ast.Expr) *ast.Expr {
 	return makeQuantifier(quantifierExistsOne, p, ctx, target, args)
 }
 
-func makeQuantifier(kind quantifierKind, p *parserHelper, ctx interface{}, target *expr.Expr, args []*expr.Expr) *expr.Expr {
+func makeQuantifier(kind quantifierKind, p *parserHelper, ctx interface{}, target *ast.Expr, args []*ast.Expr) *ast.Expr {
 	v, found := extractIdent(args[0])
 	if !found {
 		offset := p.positions[args[0].Id]
 		location, _ := p.source.OffsetLocation(offset)
 		return p.reportError(location, "argument must be a simple name")
 	}
-	accuIdent := func() *expr.Expr {
+	accuIdent := func() *ast.Expr {
 		return p.newIdent(ctx, accumulatorName)
 	}
 
-	var init *expr.Expr
-	var condition *expr.Expr
-	var step *expr.Expr
-	var result *expr.Expr
+	var init *ast.Expr
+	var condition *ast.Expr
+	var step *ast.Expr
+	var result *ast.Expr
 	switch kind {
 	case quantifierAll:
 		init = p.newLiteralBool(ctx, true)
@@ -178,14 +195,32 @@ func makeQuantifier(kind quantifierKind, p *parserHelper, ctx interface{}, targe
 	return p.newComprehension(ctx, v, target, accumulatorName, init, condition, step, result)
 }
 
-func makeMap(p *parserHelper, ctx interface{}, target *expr.Expr, args []*expr.Expr) *expr.Expr {
+func makeAllUnique(p *parserHelper, ctx interface{}, target *ast.Expr, args []*ast.Expr) *ast.Expr {
+	return makeUniqueness(overloads.AllUniqueKeys, p, ctx, target, args)
+}
+
+func makeExistsUnique(p *parserHelper, ctx interface{}, target *ast.Expr, args []*ast.Expr) *ast.Expr {
+	return makeUniqueness(overloads.ExistsUniqueKeys, p, ctx, target, args)
+}
+
+// makeUniqueness expands a uniqueness macro to a call of the function with the
+// keys of the elements, as computed by 'range.map(var, key)', so that the keys
+// are compared in linear time rather than by nested comprehensions.
+func makeUniqueness(function string, p *parserHelper, ctx interface{}, target *ast.Expr, args []*ast.Expr) *ast.Expr {
+	if _, found := extractIdent(args[0]); !found {
+		return p.reportError(ctx, "argument is not an identifier")
+	}
+	return p.newGlobalCall(ctx, function, makeMap(p, ctx, target, args))
+}
+
+func makeMap(p *parserHelper, ctx interface{}, target *ast.Expr, args []*ast.Expr) *ast.Expr {
 	v, found := extractIdent(args[0])
 	if !found {
 		return p.reportError(ctx, "argument is not an identifier")
 	}
 
-	var fn *expr.Expr
-	var filter *expr.Expr
+	var fn *ast.Expr
+	var filter *ast.Expr
 
 	if len(args) == 3 {
 		filter = args[1]
@@ -207,7 +242,7 @@ func makeMap(p *parserHelper, ctx interface{}, target *expr.Expr, args []*expr.E
 	return p.newComprehension(ctx, v, target, accumulatorName, init, condition, step, accuExpr)
 }
 
-func makeFilter(p *parserHelper, ctx interface{}, target *expr.Expr, args []*expr.Expr) *expr.Expr {
+func makeFilter(p *parserHelper, ctx interface{}, target *ast.Expr, args []*ast.Expr) *ast.Expr {
 	v, found := extractIdent(args[0])
 	if !found {
 		return p.reportError(ctx, "argument is not an identifier")
@@ -223,10 +258,10 @@ func makeFilter(p *parserHelper, ctx interface{}, target *expr.Expr, args []*exp
 	return p.newComprehension(ctx, v, target, accumulatorName, init, condition, step, accuExpr)
 }
 
-func extractIdent(e *expr.Expr) (string, bool) {
-	switch e.ExprKind.(type) {
-	case *expr.Expr_IdentExpr:
-		return e.GetIdentExpr().Name, true
+func extractIdent(e *ast.Expr) (string, bool) {
+	switch kind := e.Kind.(type) {
+	case *ast.Ident:
+		return kind.Name, true
 	}
 	return "", false
 }
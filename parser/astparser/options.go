@@ -0,0 +1,46 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package astparser
+
+// Option configures optional parser behaviors which are not enabled by
+// default.
+type Option func(*options)
+
+type options struct {
+	dedentMultilineStrings bool
+}
+
+// DedentMultilineStrings strips the common leading indentation from the body
+// of triple-quoted string and bytes literals.
+//
+// When enabled, a newline immediately following the opening quotes and a
+// whitespace-only line preceding the closing quotes are dropped, and the
+// longest whitespace prefix shared by all non-blank lines is removed from
+// every line. This makes it possible to indent templates, PEM blocks, and
+// regular expressions together with the surrounding expression:
+//
+//     cert == r'''
+//         -----BEGIN CERTIFICATE-----
+//         MIIBszCCAVmgAwIBAgIU
+//         -----END CERTIFICATE-----
+//         '''
+//
+// Indentation is computed from the raw source text, so escape sequences such
+// as '\t' are never treated as indentation.
+func DedentMultilineStrings() Option {
+	return func(opts *options) {
+		opts.dedentMultilineStrings = true
+	}
+}
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package parser
+package astparser
 
 import (
 	"fmt"
@@ -207,6 +207,65 @@ func unescapeChar(s string) (value rune, multibyte bool, tail string, err error)
 	return
 }
 
+// dedent takes a quoted string and, if it is a triple-quoted string, strips the
+// common leading indentation from its lines. Other quoted strings are returned
+// unchanged.
+//
+// The opening newline and the whitespace-only line which precedes the closing
+// quotes are removed prior to computing the indentation. Lines which contain
+// only whitespace do not contribute to the indentation and are emptied.
+func dedent(value string) string {
+	value = newlineNormalizer.Replace(value)
+	prefix := ""
+	if len(value) > 0 && (value[0] == 'r' || value[0] == 'R') {
+		prefix = value[:1]
+		value = value[1:]
+	}
+	n := len(value)
+	if n < 6 {
+		return prefix + value
+	}
+	quote := value[:3]
+	if (quote != `"""` && quote != `'''`) || !strings.HasSuffix(value, quote) {
+		return prefix + value
+	}
+	lines := strings.Split(value[3:n-3], "\n")
+	if len(lines) > 1 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	if len(lines) > 1 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	indent := ""
+	first := true
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent = lineIndent
+			first = false
+			continue
+		}
+		for !strings.HasPrefix(lineIndent, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+	for i, line := range lines {
+		if isBlank(line) {
+			lines[i] = ""
+		} else {
+			lines[i] = line[len(indent):]
+		}
+	}
+	return prefix + quote + strings.Join(lines, "\n") + quote
+}
+
+func isBlank(line string) bool {
+	return strings.TrimLeft(line, " \t") == ""
+}
+
 func unhex(b byte) (rune, bool) {
 	c := rune(b)
 	switch {
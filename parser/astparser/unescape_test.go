@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package parser
+package astparser
 
 import (
 	"testing"
@@ -130,3 +130,32 @@ func TestUnescapeIllegalEscapes(t *testing.T) {
 		t.Errorf("Got '%v', expected error", text)
 	}
 }
+
+func TestDedentTripleQuote(t *testing.T) {
+	text := dedent("'''\n    a\n      b\n\n    c\n    '''")
+	if text != "'''a\n  b\n\nc'''" {
+		t.Errorf("Got '%v', wanted '%v'", text, "'''a\n  b\n\nc'''")
+	}
+}
+
+func TestDedentRawTripleQuote(t *testing.T) {
+	text := dedent("r\"\"\"\r\n\t\\d+\r\n\t\\w+\r\n\t\"\"\"")
+	if text != `r"""\d+`+"\n"+`\w+"""` {
+		t.Errorf("Got '%v', wanted '%v'", text, `r"""\d+`+"\n"+`\w+"""`)
+	}
+}
+
+func TestDedentMixedIndent(t *testing.T) {
+	// The common prefix is the two spaces shared by both lines.
+	text := dedent("\"\"\"\n  \tx\n   y\n\"\"\"")
+	if text != "\"\"\"\tx\n y\"\"\"" {
+		t.Errorf("Got '%v', wanted '%v'", text, "\"\"\"\tx\n y\"\"\"")
+	}
+}
+
+func TestDedentSingleQuote(t *testing.T) {
+	text := dedent(`'  x'`)
+	if text != `'  x'` {
+		t.Errorf("Got '%v', wanted '%v'", text, `'  x'`)
+	}
+}
@@ -671,6 +671,122 @@ ERROR: <input>:1:6: Syntax error: mismatched input '<EOF>' expecting {'[', '{',
     		 | "\a\b\f\n\r\t\v\'\"\\\? Illegal escape \>"
     		 | ..........................................^`,
 	},
+
+	{
+		I: `1_000_000`,
+		P: `1000000^#1:*syntax.Literal_Int64Value#`,
+	},
+	{
+		I: `0xFF`,
+		P: `255^#1:*syntax.Literal_Int64Value#`,
+	},
+	{
+		I: `0xff_ff`,
+		P: `65535^#1:*syntax.Literal_Int64Value#`,
+	},
+	{
+		I: `0o755`,
+		P: `493^#1:*syntax.Literal_Int64Value#`,
+	},
+	{
+		I: `0b1010`,
+		P: `10^#1:*syntax.Literal_Int64Value#`,
+	},
+	{
+		I: `0b_1111_0000`,
+		P: `240^#1:*syntax.Literal_Int64Value#`,
+	},
+	{
+		I: `1_000u`,
+		P: `1000u^#1:*syntax.Literal_Uint64Value#`,
+	},
+	{
+		I: `0xFFFF_FFFF_FFFF_FFFFu`,
+		P: `18446744073709551615u^#1:*syntax.Literal_Uint64Value#`,
+	},
+	{
+		I: `-0b1 + x`,
+		P: `_+_(
+    		  -_(
+    		    1^#1:*syntax.Literal_Int64Value#
+    		  )^#2:*syntax.Expr_CallExpr#,
+    		  x^#3:*syntax.Expr_IdentExpr#
+    		)^#4:*syntax.Expr_CallExpr#`,
+	},
+	{
+		I: `1__000`,
+		E: `ERROR: <input>:1:1: invalid int literal
+    		 | 1__000
+    		 | ^`,
+	},
+	{
+		I: `1_000_`,
+		E: `ERROR: <input>:1:1: invalid int literal
+    		 | 1_000_
+    		 | ^`,
+	},
+	{
+		I: `0o78`,
+		E: `ERROR: <input>:1:2: Syntax error: extraneous input 'o78' expecting <EOF>
+    		 | 0o78
+    		 | .^`,
+	},
+	{
+		I: `{'a': 1, "b": 2, 'a': 3}`,
+		E: `ERROR: <input>:1:18: duplicate map key: 'a'
+    		 | {'a': 1, "b": 2, 'a': 3}
+    		 | .................^`,
+	},
+	{
+		I: `{1: 'a', 1u: 'b', true: 'c', 'true': 'd'}`,
+		P: `{
+    		  1^#1:*syntax.Literal_Int64Value#:"a"^#2:*syntax.Literal_StringValue#^#3:*syntax.Expr_CreateStruct_Entry#,
+    		  1u^#4:*syntax.Literal_Uint64Value#:"b"^#5:*syntax.Literal_StringValue#^#6:*syntax.Expr_CreateStruct_Entry#,
+    		  true^#7:*syntax.Literal_BoolValue#:"c"^#8:*syntax.Literal_StringValue#^#9:*syntax.Expr_CreateStruct_Entry#,
+    		  "true"^#10:*syntax.Literal_StringValue#:"d"^#11:*syntax.Literal_StringValue#^#12:*syntax.Expr_CreateStruct_Entry#
+    		}^#13:*syntax.Expr_StructExpr#`,
+	},
+	{
+		I: `Msg{a: 1, b: 2, a: 3}`,
+		E: `ERROR: <input>:1:17: duplicate field initializer: a
+    		 | Msg{a: 1, b: 2, a: 3}
+    		 | ................^`,
+	},
+	{
+		I: `!!a`,
+		P: `a^#1:*syntax.Expr_IdentExpr#`,
+		W: `WARNING: <input>:1:1: redundant repetition of the unary operator '!'
+    		 | !!a
+    		 | ^`,
+	},
+	{
+		I: `1 + ---a`,
+		P: `_+_(
+    		  1^#1:*syntax.Literal_Int64Value#,
+    		  -_(
+    		    a^#2:*syntax.Expr_IdentExpr#
+    		  )^#3:*syntax.Expr_CallExpr#
+    		)^#4:*syntax.Expr_CallExpr#`,
+		W: `WARNING: <input>:1:5: redundant repetition of the unary operator '-'
+    		 | 1 + ---a
+    		 | ....^`,
+	},
+}
+
+func TestParseDedentMultilineStrings(t *testing.T) {
+	src := common.NewStringSource(
+		"r'''\n    [a-z]+\n      \\d\n    ''' + b'''\n  x\n  '''", "<input>")
+	parsed, errors := Parse(src, AllMacros, DedentMultilineStrings())
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	args := parsed.GetExpr().GetCallExpr().GetArgs()
+	if str := args[0].GetLiteralExpr().GetStringValue(); str != "[a-z]+\n  \\d" {
+		t.Errorf("Got '%v', wanted '%v'", str, "[a-z]+\n  \\d")
+	}
+	if b := string(args[1].GetLiteralExpr().GetBytesValue()); b != "x" {
+		t.Errorf("Got '%v', wanted 'x'", b)
+	}
 }
 
 type testInfo struct {
@@ -685,6 +801,10 @@ type testInfo struct {
 
 	// L contains the expected source adorned debug output of the expression tree.
 	L string
+
+	// W contains the expected warning output of a successful parse, or "" if
+	// the parse is expected to report no warnings.
+	W string
 }
 
 type metadata interface {
@@ -765,6 +885,12 @@ func Test(t *testing.T) {
 			} else if tst.E != "" {
 				tt.Fatalf("Expected error not thrown: '%s'", tst.E)
 			}
+			if len(errors.GetWarnings()) != 0 || tst.W != "" {
+				actualWarn := errors.ToDisplayString()
+				if !test.Compare(actualWarn, tst.W) {
+					tt.Fatalf(test.DiffMessage("Warning mismatch", actualWarn, tst.W))
+				}
+			}
 
 			actualWithKind := debug.ToAdornedDebugString(expression.Expr, &kindAndIdAdorner{})
 			if !test.Compare(actualWithKind, tst.P) {
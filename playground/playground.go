@@ -0,0 +1,259 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package playground provides an http.Handler for authoring expressions
+// interactively: expressions are parsed, checked, and evaluated against a
+// configured Env through a JSON API, which is also served by a minimal web UI.
+//
+// The API accepts POST requests to the 'parse', 'check', and 'eval' paths
+// below the path at which the handler is mounted, with a JSON body of the
+// form:
+//
+//     {"expression": "request.size < limit",
+//      "bindings": {"request": {"size": 10}, "limit": 100}}
+//
+// The bindings are only used by 'eval'. Responses hold the fields relevant to
+// each step, e.g. {"ast": ..., "type": "bool", "result": true}, or a list of
+// errors.
+package playground
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/debug"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/loader"
+	"github.com/google/cel-go/parser"
+)
+
+// cancellationInterval is the interval at which evaluations check whether
+// they have timed out, as for the interpreter.CheckCancellation option.
+const cancellationInterval = 100
+
+// Limits sandbox the requests served by the playground. Zero values select
+// the defaults.
+type Limits struct {
+	// MaxRequestBytes limits the size of request bodies. Defaults to 64KiB.
+	MaxRequestBytes int64
+
+	// MaxExpressionLength limits the length of expressions. Defaults to 4096.
+	MaxExpressionLength int
+
+	// EvalTimeout limits the duration of an evaluation. Defaults to one second.
+	// An evaluation which times out is interrupted through the cancellation
+	// of its context.
+	EvalTimeout time.Duration
+
+	// CostLimit limits the cost of an evaluation, as for the
+	// interpreter.CostLimit option. Defaults to 100000.
+	CostLimit uint64
+
+	// IterationLimit limits the number of elements visited by the
+	// comprehensions of an evaluation, as for the
+	// interpreter.InterpretableWithIterationLimit option. Defaults to 10000.
+	IterationLimit uint64
+}
+
+// Config configures the playground.
+type Config struct {
+	// Env returns the Env against which expressions are checked.
+	Env loader.EnvFunc
+
+	// Interpreter evaluates the checked expressions.
+	Interpreter interpreter.Interpreter
+
+	// ProgramOptions are applied to the programs created for evaluation.
+	ProgramOptions []interpreter.ProgramOption
+
+	Limits Limits
+}
+
+// request is the body of an API request.
+type request struct {
+	Expression string                 `json:"expression"`
+	Bindings   map[string]interface{} `json:"bindings"`
+}
+
+// response is the body of an API response.
+type response struct {
+	Ast    string          `json:"ast,omitempty"`
+	Type   string          `json:"type,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Errors []string        `json:"errors,omitempty"`
+}
+
+type handler struct {
+	config *Config
+	limits Limits
+}
+
+// NewHandler returns an http.Handler which serves the playground API and UI.
+func NewHandler(config *Config) http.Handler {
+	limits := config.Limits
+	if limits.MaxRequestBytes <= 0 {
+		limits.MaxRequestBytes = 64 << 10
+	}
+	if limits.MaxExpressionLength <= 0 {
+		limits.MaxExpressionLength = 4096
+	}
+	if limits.EvalTimeout <= 0 {
+		limits.EvalTimeout = time.Second
+	}
+	if limits.CostLimit == 0 {
+		limits.CostLimit = 100000
+	}
+	if limits.IterationLimit == 0 {
+		limits.IterationLimit = 10000
+	}
+	return &handler{config: config, limits: limits}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	step := path.Base(r.URL.Path)
+	if step != "parse" && step != "check" && step != "eval" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, ui)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req := &request{}
+	decoder := json.NewDecoder(io.LimitReader(r.Body, h.limits.MaxRequestBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.serve(r.Context(), step, req))
+}
+
+// serve runs the steps up to and including the requested step. Evaluations
+// are interrupted once the context is done.
+func (h *handler) serve(ctx context.Context, step string, req *request) *response {
+	if len(req.Expression) > h.limits.MaxExpressionLength {
+		return &response{Errors: []string{fmt.Sprintf(
+			"expression exceeds the maximum length of %d", h.limits.MaxExpressionLength)}}
+	}
+	src := common.NewStringSource(req.Expression, "<input>")
+	parsed, errs := parser.Parse(src, parser.AllMacros)
+	if len(errs.GetErrors()) != 0 {
+		return &response{Errors: errorStrings(src, errs)}
+	}
+	resp := &response{Ast: debug.ToDebugString(parsed.Expr)}
+	if step == "parse" {
+		return resp
+	}
+	errs = common.NewErrors(src)
+	checked := checker.Check(parsed, h.config.Env(errs))
+	if len(errs.GetErrors()) != 0 {
+		resp.Errors = errorStrings(src, errs)
+		return resp
+	}
+	resp.Type = checker.FormatCheckedType(checked.TypeMap[parsed.Expr.Id])
+	if step == "check" {
+		return resp
+	}
+	bindings := make(map[string]interface{}, len(req.Bindings))
+	for name, value := range req.Bindings {
+		bindings[name] = jsonNumbers(value)
+	}
+	// The limits are applied after the configured options so that they take
+	// precedence.
+	opts := append([]interpreter.ProgramOption{}, h.config.ProgramOptions...)
+	opts = append(opts,
+		interpreter.CostLimit(h.limits.CostLimit),
+		interpreter.InterpretableWithIterationLimit(h.limits.IterationLimit),
+		interpreter.CheckCancellation(cancellationInterval))
+	program := interpreter.NewCheckedProgram(checked, opts...)
+	ctx, cancel := context.WithTimeout(ctx, h.limits.EvalTimeout)
+	defer cancel()
+	result, _ := h.config.Interpreter.NewInterpretable(program).Eval(
+		interpreter.NewContextActivation(ctx, interpreter.NewActivation(bindings)))
+	if types.IsError(result) && ctx.Err() == context.DeadlineExceeded {
+		resp.Errors = []string{"evaluation timed out"}
+		return resp
+	}
+	if types.IsError(result) || types.IsUnknown(result) {
+		resp.Errors = []string{fmt.Sprintf("evaluation failed: %v", result)}
+		return resp
+	}
+	resp.Result = resultJSON(result)
+	return resp
+}
+
+// jsonNumbers converts the numbers within a decoded JSON value to int64 values
+// when they are integral, and to float64 values otherwise.
+func jsonNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = jsonNumbers(elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = jsonNumbers(elem)
+		}
+	}
+	return value
+}
+
+// resultJSON returns the JSON form of a value, or a JSON string holding its
+// text when the value has no JSON form.
+func resultJSON(val ref.Value) json.RawMessage {
+	native, err := val.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err == nil {
+		if msg, isMessage := native.(proto.Message); isMessage {
+			if text, err := (&jsonpb.Marshaler{}).MarshalToString(msg); err == nil {
+				return json.RawMessage(text)
+			}
+		}
+	}
+	text, _ := json.Marshal(fmt.Sprint(val.Value()))
+	return text
+}
+
+func errorStrings(src common.Source, errs *common.Errors) []string {
+	var strs []string
+	for _, err := range errs.GetErrors() {
+		strs = append(strs, err.ToDisplayString(src))
+	}
+	return strs
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package playground
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+func testConfig(limits Limits) *Config {
+	provider := types.NewProvider(&expr.ParsedExpr{})
+	return &Config{
+		Env: func(errors *common.Errors) *checker.Env {
+			env := checker.NewStandardEnv(packages.DefaultPackage, provider, errors)
+			env.Add(
+				decls.NewIdent("limit", decls.Int, nil),
+				decls.NewIdent("request", decls.NewMapType(decls.String, decls.Dyn), nil))
+			return env
+		},
+		Interpreter: interpreter.NewStandardIntepreter(packages.DefaultPackage, provider),
+		Limits:      limits}
+}
+
+// slowInterpreter creates Interpretables which sleep before evaluating.
+type slowInterpreter struct {
+	interpreter.Interpreter
+}
+
+func (i *slowInterpreter) NewInterpretable(program interpreter.Program) interpreter.Interpretable {
+	return &slowInterpretable{i.Interpreter.NewInterpretable(program)}
+}
+
+type slowInterpretable struct {
+	interpreter.Interpretable
+}
+
+func (i *slowInterpretable) Eval(activation interpreter.Activation) (ref.Value, interpreter.EvalState) {
+	time.Sleep(100 * time.Millisecond)
+	return i.Interpretable.Eval(activation)
+}
+
+func post(t *testing.T, handler http.Handler, step string, body string) *response {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/playground/"+step, strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s %s: got status %d", step, body, rec.Code)
+	}
+	resp := &response{}
+	if err := json.Unmarshal(rec.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestHandler(t *testing.T) {
+	handler := NewHandler(testConfig(Limits{MaxExpressionLength: 64}))
+	for _, tst := range []struct {
+		step   string
+		body   string
+		typ    string
+		result string
+		err    string
+	}{
+		{step: "parse", body: `{"expression": "1 + 1"}`},
+		{step: "check", body: `{"expression": "limit > 1"}`, typ: "bool"},
+		{step: "eval",
+			body: `{"expression": "request.size < limit && 'x' in request.tags",
+				"bindings": {"request": {"size": 10, "tags": ["x"]}, "limit": 100}}`,
+			typ: "bool", result: "true"},
+		{step: "eval", body: `{"expression": "{'a': [1, 2].map(x, x * 2), 'b': [3]}"}`,
+			typ: "map(string, list(int))", result: `{"a":[2,4],"b":[3]}`},
+		{step: "eval", body: `{"expression": "request.size", "bindings": {"request": {"size": 1.5}}}`,
+			typ: "dyn", result: "1.5"},
+		{step: "parse", body: `{"expression": "1 +"}`, err: "Syntax error"},
+		{step: "check", body: `{"expression": "limit > 'a'"}`, err: "no matching overload"},
+		{step: "eval", body: `{"expression": "1 / 0"}`, typ: "int", err: "divide by zero"},
+		{step: "eval", body: `{"expression": "'` + strings.Repeat("a", 64) + `'"}`,
+			err: "exceeds the maximum length"},
+	} {
+		resp := post(t, handler, tst.step, tst.body)
+		if tst.err == "" && len(resp.Errors) != 0 {
+			t.Errorf("%s: got errors %v", tst.body, resp.Errors)
+		}
+		if tst.err != "" && (len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0], tst.err)) {
+			t.Errorf("%s: got errors %v, wanted '%s'", tst.body, resp.Errors, tst.err)
+		}
+		if resp.Type != tst.typ {
+			t.Errorf("%s: got type '%s', wanted '%s'", tst.body, resp.Type, tst.typ)
+		}
+		if string(resp.Result) != tst.result {
+			t.Errorf("%s: got result '%s', wanted '%s'", tst.body, resp.Result, tst.result)
+		}
+	}
+	if resp := post(t, handler, "parse", `{"expression": "a.b"}`); resp.Ast == "" {
+		t.Error("Got an empty AST")
+	}
+}
+
+func TestHandler_Limits(t *testing.T) {
+	handler := NewHandler(testConfig(Limits{MaxRequestBytes: 32}))
+	rec := httptest.NewRecorder()
+	body := `{"expression": "` + strings.Repeat("a", 32) + `"}`
+	handler.ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/eval", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Got status %d for an oversized request, wanted %d",
+			rec.Code, http.StatusBadRequest)
+	}
+
+	config := testConfig(Limits{EvalTimeout: time.Millisecond})
+	config.Interpreter = &slowInterpreter{config.Interpreter}
+	resp := post(t, NewHandler(config), "eval", `{"expression": "true"}`)
+	if len(resp.Errors) != 1 || resp.Errors[0] != "evaluation timed out" {
+		t.Errorf("Got errors %v, wanted a timeout", resp.Errors)
+	}
+
+	// The comprehension visits half a million elements, and is interrupted on
+	// the timeout unless it first exceeds the cost or iteration limit.
+	items := "[" + strings.TrimSuffix(strings.Repeat("1,", 500000), ",") + "]"
+	body = `{"expression": "request.items.exists(y, y == 3)",
+		"bindings": {"request": {"items": ` + items + `}}}`
+	for _, tst := range []struct {
+		limits Limits
+		err    string
+	}{
+		{Limits{}, "iteration limit of 10000 exceeded"},
+		{Limits{IterationLimit: 1 << 30}, "cost limit of 100000 exceeded"},
+		{Limits{IterationLimit: 1 << 30, CostLimit: 1 << 30,
+			EvalTimeout: time.Millisecond}, "evaluation timed out"},
+	} {
+		tst.limits.MaxRequestBytes = 2 << 20
+		resp := post(t, NewHandler(testConfig(tst.limits)), "eval", body)
+		if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0], tst.err) {
+			t.Errorf("%v: got errors %v, wanted '%s'", tst.limits, resp.Errors, tst.err)
+		}
+	}
+}
+
+func TestHandler_UI(t *testing.T) {
+	handler := NewHandler(testConfig(Limits{}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/playground/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<textarea") {
+		t.Errorf("Got status %d and body %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/playground/eval", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Got status %d, wanted %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package playground
+
+// ui is a minimal page which submits expressions to the API relative to the
+// page's own path.
+const ui = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>CEL Playground</title>
+<style>
+body { font-family: sans-serif; margin: 2em; max-width: 60em; }
+textarea { width: 100%; font-family: monospace; }
+pre { background: #f4f4f4; padding: 1em; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>CEL Playground</h1>
+<p>Expression</p>
+<textarea id="expression" rows="4"></textarea>
+<p>Bindings (JSON)</p>
+<textarea id="bindings" rows="6">{}</textarea>
+<p>
+<button onclick="run('parse')">Parse</button>
+<button onclick="run('check')">Check</button>
+<button onclick="run('eval')">Evaluate</button>
+</p>
+<pre id="output"></pre>
+<script>
+function run(step) {
+  var output = document.getElementById('output');
+  var bindings;
+  try {
+    bindings = JSON.parse(document.getElementById('bindings').value || '{}');
+  } catch (e) {
+    output.textContent = 'Invalid bindings: ' + e;
+    return;
+  }
+  var base = location.pathname.replace(/\/?$/, '/');
+  fetch(base + step, {
+    method: 'POST',
+    headers: {'Content-Type': 'application/json'},
+    body: JSON.stringify({
+      expression: document.getElementById('expression').value,
+      bindings: bindings})
+  }).then(function(resp) { return resp.text(); })
+    .then(function(text) {
+      try {
+        text = JSON.stringify(JSON.parse(text), null, 2);
+      } catch (e) {}
+      output.textContent = text;
+    });
+}
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,136 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rls enforces row-level security policies written as CEL predicates
+// over the variables 'row' and 'user', e.g.
+//
+//     row.owner == user.id || user.role == 'admin'
+//
+// A Policy is enforced either by the application, evaluating the predicate
+// for each row, or by the database, through an equivalent SQL condition in
+// which the attributes of the user are bound as parameters.
+package rls
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/ast/astpb"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+const (
+	// RowVar is the variable bound to the row being accessed.
+	RowVar = "row"
+	// UserVar is the variable bound to the user accessing the row.
+	UserVar = "user"
+)
+
+// Policy is a row-level security predicate. A Policy is safe for concurrent
+// use.
+type Policy struct {
+	interp     interpreter.Interpreter
+	expression *ast.Expr
+	info       *ast.SourceInfo
+	opts       []interpreter.ProgramOption
+	// interpretable evaluates the policy, and is shared by concurrent calls.
+	interpretable interpreter.Interpretable
+	// evaluators holds the evaluators which are not in use, whose bindings and
+	// states are reused from row to row.
+	evaluators sync.Pool
+}
+
+// NewPolicy returns a Policy for the expression, whose programs are created
+// with the given options and evaluated by the Interpreter.
+func NewPolicy(i interpreter.Interpreter, expression *expr.Expr,
+	info *expr.SourceInfo, opts ...interpreter.ProgramOption) *Policy {
+	p := &Policy{
+		interp:     i,
+		expression: astpb.FromExpr(expression),
+		info:       astpb.FromSourceInfo(info),
+		opts:       opts}
+	program := interpreter.NewAstProgram(p.expression, p.info, p.opts...)
+	p.interpretable = i.NewInterpretable(program)
+	p.evaluators.New = func() interface{} {
+		e := &evaluator{}
+		if reusable, isReusable :=
+			p.interpretable.(interpreter.ReusableInterpretable); isReusable {
+			e.state = reusable.NewState()
+		}
+		return e
+	}
+	return p
+}
+
+// Allow returns whether the policy permits the user to access the row. The
+// row and user are bound as for interpreter.NewActivation.
+//
+// An error is returned when the policy does not evaluate to a bool, in which
+// case access should be denied.
+func (p *Policy) Allow(row, user interface{}) (bool, error) {
+	e := p.evaluators.Get().(*evaluator)
+	defer p.evaluators.Put(e)
+	e.row, e.user = types.NativeToValue(row), types.NativeToValue(user)
+	var result ref.Value
+	if e.state != nil {
+		reusable := p.interpretable.(interpreter.ReusableInterpretable)
+		result, _ = reusable.EvalWithState(&e.bindings, e.state)
+	} else {
+		result, _ = p.interpretable.Eval(&e.bindings)
+	}
+	// The bindings are released so that pooled evaluators do not retain the
+	// rows.
+	e.row, e.user = nil, nil
+	allowed, isBool := result.(types.Bool)
+	if !isBool {
+		return false, fmt.Errorf("policy evaluated to '%v', wanted a bool", result)
+	}
+	return bool(allowed), nil
+}
+
+// evaluator holds the bindings and the state of an evaluation of the policy,
+// which are reused by the evaluations of a single goroutine at a time. The
+// state is nil when the policy's Interpretable does not support reuse.
+type evaluator struct {
+	bindings
+	state interpreter.EvalState
+}
+
+// bindings is the Activation which binds the row and the user.
+type bindings struct {
+	row  ref.Value
+	user ref.Value
+}
+
+func (b *bindings) Parent() interpreter.Activation {
+	return nil
+}
+
+func (b *bindings) ResolveName(name string) (ref.Value, bool) {
+	switch name {
+	case RowVar:
+		return b.row, b.row != nil
+	case UserVar:
+		return b.user, b.user != nil
+	}
+	return nil, false
+}
+
+func (b *bindings) ResolveReference(exprId int64) (ref.Value, bool) {
+	return nil, false
+}
@@ -0,0 +1,176 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rls
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/parser"
+	expr "github.com/google/cel-spec/proto/v1/syntax"
+)
+
+const ownerPolicy = "row.owner == user.id || user.role == 'admin' && row.public"
+
+var (
+	alice = map[string]interface{}{"id": "alice", "role": "user"}
+	admin = map[string]interface{}{"id": "root", "role": "admin"}
+)
+
+func newPolicy(t *testing.T, text string) *Policy {
+	t.Helper()
+	parsed, errors := parser.ParseText(text)
+	if len(errors.GetErrors()) != 0 {
+		t.Fatal(errors.ToDisplayString())
+	}
+	interp := interpreter.NewStandardIntepreter(packages.DefaultPackage,
+		types.NewProvider(&expr.ParsedExpr{}))
+	return NewPolicy(interp, parsed.GetExpr(), parsed.GetSourceInfo())
+}
+
+func TestPolicy_Allow(t *testing.T) {
+	policy := newPolicy(t, ownerPolicy)
+	for _, tst := range []struct {
+		row      map[string]interface{}
+		user     map[string]interface{}
+		expected bool
+	}{
+		{row: map[string]interface{}{"owner": "alice", "public": false},
+			user: alice, expected: true},
+		{row: map[string]interface{}{"owner": "bob", "public": true},
+			user: alice, expected: false},
+		{row: map[string]interface{}{"owner": "bob", "public": true},
+			user: admin, expected: true},
+		{row: map[string]interface{}{"owner": "bob", "public": false},
+			user: admin, expected: false},
+	} {
+		allowed, err := policy.Allow(tst.row, tst.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != tst.expected {
+			t.Errorf("Got %t for row %v and user %v, wanted %t",
+				allowed, tst.row, tst.user, tst.expected)
+		}
+	}
+}
+
+func TestPolicy_AllowConcurrent(t *testing.T) {
+	policy := newPolicy(t, "[1, 2, 3].exists(x, x == row.level) && row.owner == user.id")
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				level := (g + i) % 5
+				row := map[string]interface{}{"owner": "alice", "level": level}
+				allowed, err := policy.Allow(row, alice)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if expected := level >= 1 && level <= 3; allowed != expected {
+					errs <- fmt.Errorf("got %t for level %d, wanted %t",
+						allowed, level, expected)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestPolicy_AllowError(t *testing.T) {
+	for _, text := range []string{"row.owner", "row.missing == user.id"} {
+		policy := newPolicy(t, text)
+		allowed, err := policy.Allow(map[string]interface{}{"owner": "alice"}, alice)
+		if err == nil || allowed {
+			t.Errorf("%s: got %t, %v, wanted an error", text, allowed, err)
+		}
+	}
+}
+
+func TestPolicy_SQL(t *testing.T) {
+	for _, tst := range []struct {
+		text     string
+		user     map[string]interface{}
+		dialect  SQLDialect
+		expected string
+		args     []interface{}
+	}{
+		{text: ownerPolicy, user: alice,
+			expected: `"owner" = ?`,
+			args:     []interface{}{"alice"}},
+		{text: ownerPolicy, user: admin,
+			expected: `("owner" = ? OR "public")`,
+			args:     []interface{}{"root"}},
+		{text: ownerPolicy, user: admin,
+			dialect: SQLDialect{
+				Table:       "docs",
+				Placeholder: func(n int) string { return fmt.Sprintf("$%d", n) }},
+			expected: `("docs"."owner" = $1 OR "docs"."public")`,
+			args:     []interface{}{"root"}},
+		{text: "user.role == 'admin' || !(row.level > 2) && has(row.owner)", user: alice,
+			expected: `(NOT ("level" > ?) AND "owner" IS NOT NULL)`,
+			args:     []interface{}{int64(2)}},
+		{text: "user.role == 'admin' || row.level > 2", user: admin,
+			expected: `TRUE`},
+		{text: "row.team in user.teams && row.deleted == null", user: map[string]interface{}{
+			"teams": []string{"red", "blue"}},
+			expected: `("team" IN (?, ?) AND "deleted" IS NULL)`,
+			args:     []interface{}{"red", "blue"}},
+		{text: "row.team in user.teams", user: map[string]interface{}{
+			"teams": []string{}},
+			expected: `FALSE`},
+		{text: "null != row.deleted", user: alice,
+			expected: `"deleted" IS NOT NULL`},
+	} {
+		policy := newPolicy(t, tst.text)
+		cond, args, err := policy.SQL(tst.user, tst.dialect)
+		if err != nil {
+			t.Fatalf("%s: %v", tst.text, err)
+		}
+		if cond != tst.expected || !reflect.DeepEqual(args, tst.args) {
+			t.Errorf("%s: got %s with %v, wanted %s with %v",
+				tst.text, cond, args, tst.expected, tst.args)
+		}
+	}
+}
+
+func TestPolicy_SQLError(t *testing.T) {
+	for _, text := range []string{
+		"row.name.size() > 3",
+		"row.a.b == 1",
+		"user.id in row.editors",
+		"row.owner == user.missing",
+		"user.id",
+	} {
+		policy := newPolicy(t, text)
+		if cond, _, err := policy.SQL(alice, SQLDialect{}); err == nil {
+			t.Errorf("%s: got %s, wanted an error", text, cond)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rls
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/debug"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter"
+)
+
+// SQLDialect configures the condition produced by Policy.SQL.
+type SQLDialect struct {
+	// Table qualifies the names of the columns, when set.
+	Table string
+
+	// Placeholder returns the placeholder of the nth parameter, counting from
+	// one, e.g. '$1'. Defaults to '?'.
+	Placeholder func(n int) string
+}
+
+var sqlOperators = map[string]string{
+	operators.Equals:        "=",
+	operators.NotEquals:     "<>",
+	operators.Less:          "<",
+	operators.LessEquals:    "<=",
+	operators.Greater:       ">",
+	operators.GreaterEquals: ">=",
+}
+
+// SQL returns a SQL condition equivalent to the policy for the user, along
+// with the values of its parameters, so that the policy may be enforced by
+// the database.
+//
+// The fields of the row are referenced as columns. Expressions which do not
+// refer to the row, such as the attributes of the user, are evaluated and
+// bound as parameters, or folded into the condition when they are bools. The
+// condition may use the logical operators, comparisons, 'in' tests against
+// lists, and presence tests of the fields of the row, and an error is
+// returned for other expressions over the row.
+//
+// Comparisons with null columns are not true in SQL, while the selection of
+// a null field is an error in CEL, so in either case the row is not
+// accessible.
+func (p *Policy) SQL(user interface{}, dialect SQLDialect) (string, []interface{}, error) {
+	w := &sqlWriter{policy: p, dialect: dialect, user: types.NativeToValue(user)}
+	cond, err := w.condition(p.expression)
+	if err != nil {
+		return "", nil, err
+	}
+	return cond.text, w.args, nil
+}
+
+// sqlCondition is a SQL condition, which is constant when folded from a bool.
+type sqlCondition struct {
+	text     string
+	constant bool
+	value    bool
+}
+
+func constantCondition(value bool) *sqlCondition {
+	if value {
+		return &sqlCondition{text: "TRUE", constant: true, value: true}
+	}
+	return &sqlCondition{text: "FALSE", constant: true}
+}
+
+type sqlWriter struct {
+	policy  *Policy
+	dialect SQLDialect
+	user    ref.Value
+	args    []interface{}
+}
+
+func (w *sqlWriter) condition(e *ast.Expr) (*sqlCondition, error) {
+	if !referencesRow(e) {
+		value, err := w.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		if b, isBool := value.(types.Bool); isBool {
+			return constantCondition(bool(b)), nil
+		}
+		return nil, fmt.Errorf("'%s' evaluated to '%v', wanted a bool", debug.Format(e), value)
+	}
+	switch kind := e.Kind.(type) {
+	case *ast.Select:
+		if kind.TestOnly {
+			column, err := w.column(kind)
+			if err != nil {
+				return nil, err
+			}
+			return &sqlCondition{text: column + " IS NOT NULL"}, nil
+		}
+		column, err := w.column(kind)
+		if err != nil {
+			return nil, err
+		}
+		return &sqlCondition{text: column}, nil
+	case *ast.Call:
+		args := kind.Args
+		switch kind.Function {
+		case operators.LogicalAnd, operators.LogicalOr:
+			return w.logical(kind.Function == operators.LogicalOr, args[0], args[1])
+		case operators.LogicalNot:
+			cond, err := w.condition(args[0])
+			if err != nil {
+				return nil, err
+			}
+			if cond.constant {
+				return constantCondition(!cond.value), nil
+			}
+			return &sqlCondition{text: "NOT (" + cond.text + ")"}, nil
+		case operators.In:
+			return w.in(args[0], args[1])
+		}
+		if op, found := sqlOperators[kind.Function]; found {
+			return w.compare(op, args[0], args[1])
+		}
+	}
+	return nil, fmt.Errorf("'%s' cannot be expressed in SQL", debug.Format(e))
+}
+
+// logical writes a logical or when shortCircuit is true, and a logical and
+// otherwise, omitting constant operands which do not determine the result.
+func (w *sqlWriter) logical(shortCircuit bool, lhsExpr, rhsExpr *ast.Expr) (*sqlCondition, error) {
+	bound := len(w.args)
+	lhs, err := w.condition(lhsExpr)
+	if err != nil {
+		return nil, err
+	}
+	if lhs.constant && lhs.value == shortCircuit {
+		return lhs, nil
+	}
+	rhs, err := w.condition(rhsExpr)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case rhs.constant && rhs.value == shortCircuit:
+		// The parameters of the omitted operand are unbound.
+		w.args = w.args[:bound]
+		return rhs, nil
+	case lhs.constant:
+		return rhs, nil
+	case rhs.constant:
+		return lhs, nil
+	}
+	op := " AND "
+	if shortCircuit {
+		op = " OR "
+	}
+	return &sqlCondition{text: "(" + lhs.text + op + rhs.text + ")"}, nil
+}
+
+func (w *sqlWriter) compare(op string, lhsExpr, rhsExpr *ast.Expr) (*sqlCondition, error) {
+	// Tests for null are written with 'IS NULL', as a comparison with NULL is
+	// never true in SQL.
+	if op == "=" || op == "<>" {
+		for _, operands := range [][2]*ast.Expr{{lhsExpr, rhsExpr}, {rhsExpr, lhsExpr}} {
+			if !referencesRow(operands[1]) {
+				value, err := w.eval(operands[1])
+				if err != nil {
+					return nil, err
+				}
+				if value != types.NullValue {
+					continue
+				}
+				operand, err := w.operand(operands[0])
+				if err != nil {
+					return nil, err
+				}
+				if op == "=" {
+					return &sqlCondition{text: operand + " IS NULL"}, nil
+				}
+				return &sqlCondition{text: operand + " IS NOT NULL"}, nil
+			}
+		}
+	}
+	lhs, err := w.operand(lhsExpr)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := w.operand(rhsExpr)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlCondition{text: lhs + " " + op + " " + rhs}, nil
+}
+
+func (w *sqlWriter) in(elemExpr, listExpr *ast.Expr) (*sqlCondition, error) {
+	if referencesRow(listExpr) {
+		return nil, fmt.Errorf("'%s' cannot be expressed in SQL", debug.Format(listExpr))
+	}
+	elem, err := w.operand(elemExpr)
+	if err != nil {
+		return nil, err
+	}
+	value, err := w.eval(listExpr)
+	if err != nil {
+		return nil, err
+	}
+	list, isList := value.(traits.Lister)
+	if !isList || !value.Type().HasTrait(traits.IterableType) {
+		return nil, fmt.Errorf("'%s' evaluated to '%v', wanted a list",
+			debug.Format(listExpr), value)
+	}
+	var placeholders []string
+	it := list.Iterator()
+	for it.HasNext() == types.True {
+		placeholder, err := w.bind(it.Next())
+		if err != nil {
+			return nil, err
+		}
+		placeholders = append(placeholders, placeholder)
+	}
+	if len(placeholders) == 0 {
+		return constantCondition(false), nil
+	}
+	return &sqlCondition{
+		text: elem + " IN (" + strings.Join(placeholders, ", ") + ")"}, nil
+}
+
+// operand writes a column, or a parameter for an expression which does not
+// refer to the row.
+func (w *sqlWriter) operand(e *ast.Expr) (string, error) {
+	if sel, isSelect := e.Kind.(*ast.Select); isSelect && !sel.TestOnly &&
+		referencesRow(e) {
+		return w.column(sel)
+	}
+	if referencesRow(e) {
+		return "", fmt.Errorf("'%s' cannot be expressed in SQL", debug.Format(e))
+	}
+	value, err := w.eval(e)
+	if err != nil {
+		return "", err
+	}
+	return w.bind(value)
+}
+
+// column writes the column for a field of the row.
+func (w *sqlWriter) column(sel *ast.Select) (string, error) {
+	ident, isIdent := sel.Operand.Kind.(*ast.Ident)
+	if !isIdent || ident.Name != RowVar {
+		return "", fmt.Errorf("'%s' is not a column of the row",
+			debug.Format(&ast.Expr{Kind: sel}))
+	}
+	column := quoteIdentifier(sel.Field)
+	if w.dialect.Table != "" {
+		column = quoteIdentifier(w.dialect.Table) + "." + column
+	}
+	return column, nil
+}
+
+// bind adds a parameter and returns its placeholder.
+func (w *sqlWriter) bind(value ref.Value) (string, error) {
+	switch value.(type) {
+	case types.Bool, types.Int, types.Uint, types.Double, types.String, types.Bytes:
+		w.args = append(w.args, value.Value())
+	default:
+		return "", fmt.Errorf("'%v' cannot be bound as a SQL parameter", value)
+	}
+	if w.dialect.Placeholder == nil {
+		return "?", nil
+	}
+	return w.dialect.Placeholder(len(w.args)), nil
+}
+
+// eval evaluates an expression which does not refer to the row.
+func (w *sqlWriter) eval(e *ast.Expr) (ref.Value, error) {
+	if lit, isLiteral := e.Kind.(*ast.Literal); isLiteral {
+		if lit.Value == nil {
+			return types.NullValue, nil
+		}
+		return types.NativeToValue(lit.Value), nil
+	}
+	program := interpreter.NewAstProgram(e, w.policy.info, w.policy.opts...)
+	activation := &bindings{user: w.user}
+	value, _ := w.policy.interp.NewInterpretable(program).Eval(activation)
+	if types.IsUnknownOrError(value) {
+		return nil, fmt.Errorf("'%s' evaluated to '%v'", debug.Format(e), value)
+	}
+	return value, nil
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// referencesRow returns whether an expression refers to the row.
+func referencesRow(e *ast.Expr) bool {
+	switch kind := e.Kind.(type) {
+	case *ast.Ident:
+		return kind.Name == RowVar
+	case *ast.Select:
+		return referencesRow(kind.Operand)
+	case *ast.Call:
+		if kind.Target != nil && referencesRow(kind.Target) {
+			return true
+		}
+		for _, arg := range kind.Args {
+			if referencesRow(arg) {
+				return true
+			}
+		}
+	case *ast.CreateList:
+		for _, elem := range kind.Elements {
+			if referencesRow(elem) {
+				return true
+			}
+		}
+	case *ast.CreateStruct:
+		for _, entry := range kind.Entries {
+			if entry.MapKey != nil && referencesRow(entry.MapKey) ||
+				referencesRow(entry.Value) {
+				return true
+			}
+		}
+	case *ast.Comprehension:
+		return referencesRow(kind.IterRange) || referencesRow(kind.AccuInit) ||
+			referencesRow(kind.LoopCondition) || referencesRow(kind.LoopStep) ||
+			referencesRow(kind.Result)
+	}
+	return false
+}
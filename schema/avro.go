@@ -0,0 +1,187 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/checker/decls"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// LoadAvro reads an Avro schema, in JSON form, whose top-level record
+// describes the records of a dataset. The fields of the top-level record are
+// declared as variables, and records are declared as object types named after
+// their full Avro names. Avro types are converted as follows:
+//
+//     null, boolean        null_type, bool
+//     int, long            int
+//     float, double        double
+//     string, enum         string
+//     bytes, fixed         bytes
+//     array, map           list(T), map(string, T)
+//     union of null and T  T
+//     other unions         dyn
+//
+// Logical types are converted to the type of their underlying Avro type.
+func LoadAvro(data []byte) (*Schema, error) {
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+	record, isRecord := root.(map[string]interface{})
+	if !isRecord || record["type"] != "record" {
+		return nil, fmt.Errorf("avro schema is not a record")
+	}
+	c := &avroConverter{schema: newSchema("")}
+	t, err := c.convert(record, "")
+	if err != nil {
+		return nil, err
+	}
+	name := t.GetMessageType()
+	for field, fieldType := range c.schema.objects[name] {
+		c.schema.root[field] = fieldType
+	}
+	return c.schema, nil
+}
+
+type avroConverter struct {
+	schema *Schema
+}
+
+var avroPrimitives = map[string]*checkedpb.Type{
+	"null":    decls.Null,
+	"boolean": decls.Bool,
+	"int":     decls.Int,
+	"long":    decls.Int,
+	"float":   decls.Double,
+	"double":  decls.Double,
+	"bytes":   decls.Bytes,
+	"string":  decls.String,
+}
+
+// convert returns the type of an Avro schema, where the namespace is the
+// enclosing namespace of named types.
+func (c *avroConverter) convert(s interface{}, namespace string) (*checkedpb.Type, error) {
+	switch schema := s.(type) {
+	case string:
+		if t, found := avroPrimitives[schema]; found {
+			return t, nil
+		}
+		if t, found := c.schema.named[avroFullName(schema, namespace)]; found {
+			return t, nil
+		}
+		if t, found := c.schema.named[schema]; found {
+			return t, nil
+		}
+		return nil, fmt.Errorf("unknown avro type '%s'", schema)
+	case []interface{}:
+		var types []*checkedpb.Type
+		for _, member := range schema {
+			t, err := c.convert(member, namespace)
+			if err != nil {
+				return nil, err
+			}
+			if t != decls.Null {
+				types = append(types, t)
+			}
+		}
+		if len(types) == 1 {
+			return types[0], nil
+		}
+		return decls.Dyn, nil
+	case map[string]interface{}:
+		return c.convertComplex(schema, namespace)
+	}
+	return nil, fmt.Errorf("invalid avro schema: %v", s)
+}
+
+func (c *avroConverter) convertComplex(schema map[string]interface{},
+	namespace string) (*checkedpb.Type, error) {
+	typeName, _ := schema["type"].(string)
+	switch typeName {
+	case "record", "error", "enum", "fixed":
+		name, _ := schema["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("avro %s has no name", typeName)
+		}
+		if ns, found := schema["namespace"].(string); found {
+			namespace = ns
+		}
+		fullName := avroFullName(name, namespace)
+		if i := strings.LastIndex(fullName, "."); i >= 0 {
+			namespace = fullName[:i]
+		}
+		var t *checkedpb.Type
+		switch typeName {
+		case "enum":
+			t = decls.String
+		case "fixed":
+			t = decls.Bytes
+		default:
+			t = decls.NewObjectType(fullName)
+		}
+		// Named types are declared before their fields are converted, so
+		// that records may refer to themselves.
+		c.schema.named[fullName] = t
+		if t.GetMessageType() == "" {
+			return t, nil
+		}
+		fields, _ := schema["fields"].([]interface{})
+		fieldTypes := make(map[string]*checkedpb.Type, len(fields))
+		for _, f := range fields {
+			field, _ := f.(map[string]interface{})
+			fieldName, _ := field["name"].(string)
+			if fieldName == "" {
+				return nil, fmt.Errorf("avro record '%s' has a field with no name", fullName)
+			}
+			fieldType, err := c.convert(field["type"], namespace)
+			if err != nil {
+				return nil, err
+			}
+			fieldTypes[fieldName] = fieldType
+		}
+		c.schema.objects[fullName] = fieldTypes
+		return t, nil
+	case "array":
+		elem, err := c.convert(schema["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return decls.NewListType(elem), nil
+	case "map":
+		value, err := c.convert(schema["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return decls.NewMapType(decls.String, value), nil
+	}
+	// Primitive types may also be written as objects, e.g. with logical types.
+	if t, found := avroPrimitives[typeName]; found {
+		return t, nil
+	}
+	return nil, fmt.Errorf("unknown avro type '%v'", schema["type"])
+}
+
+// avroFullName qualifies a name by the namespace, unless it is already
+// qualified.
+func avroFullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/checker"
+)
+
+const avroSchema = `{
+  "type": "record",
+  "name": "Event",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "id", "type": "long"},
+    {"name": "score", "type": ["null", "double"], "default": null},
+    {"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["A", "B"]}},
+    {"name": "other", "type": "Kind"},
+    {"name": "ts", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "payload", "type": ["string", "bytes"]},
+    {"name": "tags", "type": {"type": "array", "items": "string"}},
+    {"name": "attrs", "type": {"type": "map", "values": "int"}},
+    {"name": "user", "type": {
+      "type": "record", "name": "User", "namespace": "com.example.auth",
+      "fields": [
+        {"name": "name", "type": "string"},
+        {"name": "manager", "type": ["null", "User"]}
+      ]}}
+  ]
+}`
+
+func TestLoadAvro(t *testing.T) {
+	s, err := LoadAvro([]byte(avroSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTypes := []string{"com.example.Event", "com.example.auth.User"}
+	if names := s.TypeNames(); !reflect.DeepEqual(names, wantTypes) {
+		t.Errorf("Got types %v, wanted %v", names, wantTypes)
+	}
+	declarations := s.Declarations()
+	if len(declarations) != 9 {
+		t.Errorf("Got %d declarations, wanted 9", len(declarations))
+	}
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: `id + ts`, want: `int`},
+		{expr: `score * 2.0`, want: `double`},
+		{expr: `kind == other`, want: `bool`},
+		{expr: `payload`, want: `dyn`},
+		{expr: `tags.exists(t, t == "x")`, want: `bool`},
+		{expr: `attrs["a"] + 1`, want: `int`},
+		{expr: `user.manager.manager.name`, want: `string`},
+	}
+	for _, tst := range tests {
+		checked, errs := check(s, tst.expr, declarations...)
+		if len(errs.GetErrors()) != 0 {
+			t.Errorf("%s: %s", tst.expr, errs.ToDisplayString())
+			continue
+		}
+		if got := checker.FormatCheckedType(checked.TypeMap[checked.Expr.Id]); got != tst.want {
+			t.Errorf("%s: got type %s, wanted %s", tst.expr, got, tst.want)
+		}
+	}
+	if _, errs := check(s, `user.email`, declarations...); len(errs.GetErrors()) == 0 {
+		t.Error("Got no errors for an undefined field")
+	}
+	if _, found := s.Type("com.example.auth.User"); !found {
+		t.Error("Named record not found")
+	}
+}
+
+func TestLoadAvro_Errors(t *testing.T) {
+	for _, schema := range []string{
+		`"string"`,
+		`{"type": "record", "name": "A", "fields": [{"name": "b", "type": "B"}]}`,
+		`{"type": "record", "name": "A", "fields": [{"type": "int"}]}`,
+		`{"type": "record", "fields": []}`,
+	} {
+		if _, err := LoadAvro([]byte(schema)); err == nil {
+			t.Errorf("%s: got nil error, wanted an error", schema)
+		}
+	}
+}
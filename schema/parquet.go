@@ -0,0 +1,315 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/google/cel-go/checker/decls"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// LoadParquet reads a Parquet schema in the message type syntax printed by
+// the Parquet tools, e.g.
+//
+//     message row {
+//       required int64 id;
+//       optional binary name (UTF8);
+//       optional group tags (LIST) {
+//         repeated group list {
+//           required binary element (UTF8);
+//         }
+//       }
+//     }
+//
+// The columns of the message are declared as variables, and groups are
+// declared as object types named after their path within the message,
+// qualified by the container. Parquet types are converted as follows:
+//
+//     boolean                          bool
+//     int32, int64                     int, or uint when unsigned
+//     float, double, DECIMAL           double
+//     binary, fixed_len_byte_array     bytes, or string when annotated as
+//                                      UTF8, STRING, ENUM, or JSON
+//     group                            object type
+//     LIST group, repeated field       list(T)
+//     MAP group                        map(K, V)
+//     int96, other                     dyn
+func LoadParquet(text string, container string) (*Schema, error) {
+	p := &parquetParser{tokens: parquetTokens(text), schema: newSchema(container)}
+	if !p.accept("message") {
+		return nil, p.errorf("expected 'message'")
+	}
+	name := p.next()
+	if name == "" {
+		return nil, p.errorf("expected a message name")
+	}
+	fields, err := p.group()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "" {
+		return nil, p.errorf("unexpected '%s' after the message", p.peek())
+	}
+	typeName := p.schema.qualify(name)
+	p.schema.named[name] = p.schema.declare(typeName, fields)
+	for field, fieldType := range p.schema.objects[typeName] {
+		p.schema.root[field] = fieldType
+	}
+	return p.schema, nil
+}
+
+// parquetField is a parsed field of a Parquet group.
+type parquetField struct {
+	repetition string
+	primitive  string
+	name       string
+	annotation string
+	// fields are the fields of a group, and nil for a primitive field.
+	fields []*parquetField
+}
+
+// declare declares an object type for a group with the given fields.
+func (s *Schema) declare(typeName string, fields []*parquetField) *checkedpb.Type {
+	fieldTypes := make(map[string]*checkedpb.Type, len(fields))
+	for _, field := range fields {
+		fieldTypes[field.name] = field.fieldType(s, typeName)
+	}
+	s.objects[typeName] = fieldTypes
+	return decls.NewObjectType(typeName)
+}
+
+// fieldType returns the type of a field within the named group.
+func (f *parquetField) fieldType(s *Schema, groupName string) *checkedpb.Type {
+	t := f.valueType(s, groupName)
+	if f.repetition == "repeated" {
+		return decls.NewListType(t)
+	}
+	return t
+}
+
+// valueType returns the type of the values of a field, ignoring repetition.
+func (f *parquetField) valueType(s *Schema, groupName string) *checkedpb.Type {
+	typeName := groupName + "." + f.name
+	if f.fields != nil {
+		switch f.annotation {
+		case "LIST":
+			if len(f.fields) != 1 || f.fields[0].repetition != "repeated" {
+				break
+			}
+			elem := f.fields[0]
+			// The repeated group of the standard three-level encoding holds a
+			// single element field.
+			if len(elem.fields) == 1 && elem.name != "array" &&
+				elem.name != f.name+"_tuple" {
+				return decls.NewListType(elem.fields[0].fieldType(s, typeName))
+			}
+			return decls.NewListType(elem.valueType(s, typeName))
+		case "MAP", "MAP_KEY_VALUE":
+			if len(f.fields) != 1 || len(f.fields[0].fields) == 0 {
+				break
+			}
+			entry := f.fields[0]
+			key, value := decls.Dyn, decls.Dyn
+			for _, field := range entry.fields {
+				switch field.name {
+				case "key":
+					key = field.fieldType(s, typeName)
+				case "value":
+					value = field.fieldType(s, typeName)
+				}
+			}
+			return decls.NewMapType(key, value)
+		}
+		return s.declare(typeName, f.fields)
+	}
+	switch f.primitive {
+	case "boolean":
+		return decls.Bool
+	case "int32", "int64":
+		if strings.HasPrefix(f.annotation, "UINT_") ||
+			strings.HasPrefix(f.annotation, "INT(") && strings.HasSuffix(f.annotation, "false)") {
+			return decls.Uint
+		}
+		if strings.HasPrefix(f.annotation, "DECIMAL") {
+			return decls.Double
+		}
+		return decls.Int
+	case "float", "double":
+		return decls.Double
+	case "binary", "fixed_len_byte_array":
+		switch {
+		case f.annotation == "UTF8", f.annotation == "STRING",
+			f.annotation == "ENUM", f.annotation == "JSON":
+			return decls.String
+		case strings.HasPrefix(f.annotation, "DECIMAL"):
+			return decls.Double
+		}
+		return decls.Bytes
+	}
+	return decls.Dyn
+}
+
+type parquetParser struct {
+	tokens []string
+	pos    int
+	schema *Schema
+}
+
+// group parses the braced fields of a group.
+func (p *parquetParser) group() ([]*parquetField, error) {
+	if !p.accept("{") {
+		return nil, p.errorf("expected '{'")
+	}
+	fields := []*parquetField{}
+	for !p.accept("}") {
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func (p *parquetParser) field() (*parquetField, error) {
+	f := &parquetField{repetition: p.next()}
+	switch f.repetition {
+	case "required", "optional", "repeated":
+	default:
+		return nil, p.errorf("expected a repetition, found '%s'", f.repetition)
+	}
+	f.primitive = p.next()
+	if f.primitive == "fixed_len_byte_array" {
+		if !p.accept("(") || p.next() == "" || !p.accept(")") {
+			return nil, p.errorf("expected the length of a fixed_len_byte_array")
+		}
+	}
+	f.name = p.next()
+	if !isParquetName(f.name) {
+		return nil, p.errorf("expected a field name, found '%s'", f.name)
+	}
+	if p.accept("(") {
+		f.annotation = p.annotation()
+		if f.annotation == "" {
+			return nil, p.errorf("expected an annotation")
+		}
+	}
+	if p.accept("=") {
+		p.next()
+	}
+	if f.primitive == "group" {
+		fields, err := p.group()
+		if err != nil {
+			return nil, err
+		}
+		f.fields = fields
+		return f, nil
+	}
+	if !p.accept(";") {
+		return nil, p.errorf("expected ';' after field '%s'", f.name)
+	}
+	return f, nil
+}
+
+// annotation parses an annotation, including its parameters, e.g.
+// 'DECIMAL(10,2)', after the opening parenthesis.
+func (p *parquetParser) annotation() string {
+	var text strings.Builder
+	depth := 0
+	for {
+		tok := p.next()
+		switch tok {
+		case "":
+			return ""
+		case "(":
+			depth++
+		case ")":
+			if depth == 0 {
+				return text.String()
+			}
+			depth--
+		}
+		text.WriteString(tok)
+	}
+}
+
+func (p *parquetParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parquetParser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parquetParser) accept(tok string) bool {
+	if p.peek() == tok {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parquetParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid parquet schema at token %d: %s",
+		p.pos, fmt.Sprintf(format, args...))
+}
+
+// parquetTokens splits a schema into names and punctuation.
+func parquetTokens(text string) []string {
+	var tokens []string
+	start := -1
+	for i, r := range text {
+		if isParquetNameRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, text[start:i])
+			start = -1
+		}
+		if !unicode.IsSpace(r) {
+			tokens = append(tokens, string(r))
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+func isParquetName(tok string) bool {
+	for _, r := range tok {
+		if !isParquetNameRune(r) {
+			return false
+		}
+	}
+	return tok != ""
+}
+
+func isParquetNameRune(r rune) bool {
+	return r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/checker"
+)
+
+const parquetSchema = `
+message row {
+  required int64 id;
+  optional int32 count (UINT_32);
+  optional binary name (UTF8);
+  optional binary blob;
+  optional fixed_len_byte_array(16) price (DECIMAL(10,2));
+  optional int96 legacy_ts;
+  repeated int32 scores;
+  optional group tags (LIST) {
+    repeated group list {
+      optional binary element (STRING);
+    }
+  }
+  optional group attrs (MAP) {
+    repeated group key_value {
+      required binary key (UTF8);
+      optional double value;
+    }
+  }
+  optional group address {
+    required binary city (UTF8);
+    optional group geo {
+      required double lat;
+      required double lng;
+    }
+  }
+}`
+
+func TestLoadParquet(t *testing.T) {
+	s, err := LoadParquet(parquetSchema, "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTypes := []string{"data.row", "data.row.address", "data.row.address.geo"}
+	if names := s.TypeNames(); !reflect.DeepEqual(names, wantTypes) {
+		t.Errorf("Got types %v, wanted %v", names, wantTypes)
+	}
+	declarations := s.Declarations()
+	row, err := s.NewVar("row", "row")
+	if err != nil {
+		t.Fatal(err)
+	}
+	declarations = append(declarations, row)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: `id`, want: `int`},
+		{expr: `count`, want: `uint`},
+		{expr: `name`, want: `string`},
+		{expr: `blob`, want: `bytes`},
+		{expr: `price`, want: `double`},
+		{expr: `legacy_ts`, want: `dyn`},
+		{expr: `scores`, want: `list(int)`},
+		{expr: `tags`, want: `list(string)`},
+		{expr: `attrs`, want: `map(string, double)`},
+		{expr: `address.geo.lat > 0.0 && address.city == "x"`, want: `bool`},
+		{expr: `row.address.geo.lng`, want: `double`},
+	}
+	for _, tst := range tests {
+		checked, errs := check(s, tst.expr, declarations...)
+		if len(errs.GetErrors()) != 0 {
+			t.Errorf("%s: %s", tst.expr, errs.ToDisplayString())
+			continue
+		}
+		if got := checker.FormatCheckedType(checked.TypeMap[checked.Expr.Id]); got != tst.want {
+			t.Errorf("%s: got type %s, wanted %s", tst.expr, got, tst.want)
+		}
+	}
+}
+
+func TestLoadParquet_Errors(t *testing.T) {
+	for _, schema := range []string{
+		`row { required int64 id; }`,
+		`message row { required int64 id }`,
+		`message row { int64 id; }`,
+		`message row { required int64 id; } extra`,
+		`message row { optional group g { required int32 a; }`,
+	} {
+		if _, err := LoadParquet(schema, ""); err == nil {
+			t.Errorf("%s: got nil error, wanted an error", schema)
+		}
+	}
+}
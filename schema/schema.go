@@ -0,0 +1,412 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schema declares the types of an OpenAPI or JSON Schema document to
+// the type-checker, so that expressions over JSON requests and responses are
+// type-checked without protocol buffer definitions. The types of datasets may
+// likewise be loaded from Avro and Parquet schemas, so that filters over
+// their records are type-checked before a job runs.
+//
+// The named schemas of the document, i.e. the 'components/schemas' of an
+// OpenAPI 3 document, the 'definitions' of a Swagger 2 document, or the
+// 'definitions' and '$defs' of a JSON Schema, are converted as follows:
+//
+//     object with properties     object type with typed fields
+//     object, other              map(string, T), or map(string, dyn)
+//     array                      list(T), or list(dyn)
+//     string, integer, number    string, int, double
+//     boolean, null              bool, null_type
+//     allOf                      object type with the merged properties
+//     anyOf, oneOf, other        dyn
+//
+// Object types are named after their schema, qualified by the container given
+// to Load, and inline object schemas are named after the property holding
+// them, e.g. 'api.Pet.owner'. The values of the object types are plain JSON
+// maps, such as those decoded by encoding/json, so the types may be used for
+// type-checking but not for constructing objects within expressions.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types/ref"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+// Schema holds the types declared by a schema document.
+type Schema struct {
+	container string
+	// objects maps the qualified names of the object types to their fields.
+	objects map[string]map[string]*checkedpb.Type
+	// named maps the names of the document's schemas to their types.
+	named map[string]*checkedpb.Type
+	// root holds the properties of the root schema of a JSON Schema.
+	root map[string]*checkedpb.Type
+}
+
+// jsonSchema is the subset of a JSON Schema which determines CEL types.
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 json.RawMessage        `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	AllOf                []*jsonSchema          `json:"allOf"`
+	AnyOf                []*jsonSchema          `json:"anyOf"`
+	OneOf                []*jsonSchema          `json:"oneOf"`
+	Definitions          map[string]*jsonSchema `json:"definitions"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+	Components           *struct {
+		Schemas map[string]*jsonSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// refPrefixes are the prefixes of references to named schemas.
+var refPrefixes = []string{"#/components/schemas/", "#/definitions/", "#/$defs/"}
+
+// Load reads an OpenAPI or JSON Schema document, in JSON form, and qualifies
+// the names of its object types with the container.
+func Load(data []byte, container string) (*Schema, error) {
+	doc := &jsonSchema{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("invalid schema document: %v", err)
+	}
+	schemas := make(map[string]*jsonSchema)
+	for _, defs := range []map[string]*jsonSchema{doc.Definitions, doc.Defs} {
+		for name, s := range defs {
+			schemas[name] = s
+		}
+	}
+	if doc.Components != nil {
+		for name, s := range doc.Components.Schemas {
+			schemas[name] = s
+		}
+	}
+	c := &converter{
+		schema:     newSchema(container),
+		schemas:    schemas,
+		converting: make(map[string]bool),
+	}
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := c.named(name); err != nil {
+			return nil, err
+		}
+	}
+	// Inline object schemas of root properties are named after the property.
+	for name, s := range doc.Properties {
+		t, err := c.convert(c.schema.qualify(name), s)
+		if err != nil {
+			return nil, err
+		}
+		c.schema.root[name] = t
+	}
+	return c.schema, nil
+}
+
+func newSchema(container string) *Schema {
+	return &Schema{
+		container: container,
+		objects:   make(map[string]map[string]*checkedpb.Type),
+		named:     make(map[string]*checkedpb.Type),
+		root:      make(map[string]*checkedpb.Type),
+	}
+}
+
+// qualify returns the name qualified by the container of the schema.
+func (s *Schema) qualify(name string) string {
+	if s.container == "" {
+		return name
+	}
+	return s.container + "." + name
+}
+
+// Declarations returns a variable declaration for each property of the root
+// schema of a JSON Schema document, or for each column of an Avro or Parquet
+// schema, which describes the activation of the expressions. OpenAPI
+// documents have no root schema, and their variables are declared with
+// NewVar.
+func (s *Schema) Declarations() []*checkedpb.Decl {
+	names := make([]string, 0, len(s.root))
+	for name := range s.root {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var result []*checkedpb.Decl
+	for _, name := range names {
+		result = append(result, decls.NewIdent(name, s.root[name], nil))
+	}
+	return result
+}
+
+// NewVar declares a variable whose type is the named schema of the document.
+func (s *Schema) NewVar(name string, schemaName string) (*checkedpb.Decl, error) {
+	t, found := s.Type(schemaName)
+	if !found {
+		return nil, fmt.Errorf("no schema named '%s'", schemaName)
+	}
+	return decls.NewIdent(name, t, nil), nil
+}
+
+// Type returns the type of the named schema of the document.
+func (s *Schema) Type(schemaName string) (*checkedpb.Type, bool) {
+	t, found := s.named[schemaName]
+	return t, found
+}
+
+// TypeNames returns the sorted, qualified names of the object types.
+func (s *Schema) TypeNames() []string {
+	names := make([]string, 0, len(s.objects))
+	for name := range s.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Provider returns a TypeProvider which resolves the object types of the
+// schema, and delegates all other types to the given provider.
+func (s *Schema) Provider(provider ref.TypeProvider) ref.TypeProvider {
+	return &schemaProvider{TypeProvider: provider, schema: s}
+}
+
+type schemaProvider struct {
+	ref.TypeProvider
+	schema *Schema
+}
+
+func (p *schemaProvider) FindType(typeName string) (*checkedpb.Type, bool) {
+	if _, found := p.schema.objects[typeName]; found {
+		return decls.NewTypeType(decls.NewObjectType(typeName)), true
+	}
+	return p.TypeProvider.FindType(typeName)
+}
+
+func (p *schemaProvider) FindFieldType(t *checkedpb.Type,
+	fieldName string) (*ref.FieldType, bool) {
+	fields, found := p.schema.objects[t.GetMessageType()]
+	if !found {
+		return p.TypeProvider.FindFieldType(t, fieldName)
+	}
+	fieldType, found := fields[fieldName]
+	if !found {
+		return nil, false
+	}
+	// Properties are optional unless required, so presence may be tested.
+	return &ref.FieldType{SupportsPresence: true, Type: fieldType}, true
+}
+
+// FieldNames returns the sorted names of the properties of an object type.
+func (p *schemaProvider) FieldNames(typeName string) ([]string, bool) {
+	fields, found := p.schema.objects[typeName]
+	if !found {
+		return p.TypeProvider.FieldNames(typeName)
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+func (p *schemaProvider) TypeNames() []string {
+	names := append(p.TypeProvider.TypeNames(), p.schema.TypeNames()...)
+	sort.Strings(names)
+	return names
+}
+
+// converter converts the schemas of a document to CEL types.
+type converter struct {
+	schema  *Schema
+	schemas map[string]*jsonSchema
+	// converting holds the names of the schemas being converted, so that
+	// recursive references to non-object schemas are detected.
+	converting map[string]bool
+}
+
+// named returns the type of the named schema, converting it on first use.
+func (c *converter) named(name string) (*checkedpb.Type, error) {
+	if t, found := c.schema.named[name]; found {
+		return t, nil
+	}
+	s, found := c.schemas[name]
+	if !found {
+		return nil, fmt.Errorf("unresolved schema reference '%s'", name)
+	}
+	if isObject(s) {
+		// Object types are declared before their properties are converted,
+		// so that the properties may refer to the object recursively.
+		typeName := c.schema.qualify(name)
+		t := decls.NewObjectType(typeName)
+		c.schema.named[name] = t
+		fields, err := c.fields(typeName, s)
+		if err != nil {
+			return nil, err
+		}
+		c.schema.objects[typeName] = fields
+		return t, nil
+	}
+	if c.converting[name] {
+		return nil, fmt.Errorf("schema '%s' refers to itself", name)
+	}
+	c.converting[name] = true
+	defer delete(c.converting, name)
+	t, err := c.convert(c.schema.qualify(name), s)
+	if err != nil {
+		return nil, err
+	}
+	c.schema.named[name] = t
+	return t, nil
+}
+
+// convert returns the type of a schema, where the name is used for the type
+// of an inline object schema.
+func (c *converter) convert(name string, s *jsonSchema) (*checkedpb.Type, error) {
+	if s.Ref != "" {
+		for _, prefix := range refPrefixes {
+			if strings.HasPrefix(s.Ref, prefix) {
+				return c.named(strings.TrimPrefix(s.Ref, prefix))
+			}
+		}
+		return nil, fmt.Errorf("unsupported schema reference '%s'", s.Ref)
+	}
+	if isObject(s) {
+		fields, err := c.fields(name, s)
+		if err != nil {
+			return nil, err
+		}
+		c.schema.objects[name] = fields
+		return decls.NewObjectType(name), nil
+	}
+	switch schemaType(s) {
+	case "string":
+		return decls.String, nil
+	case "integer":
+		return decls.Int, nil
+	case "number":
+		return decls.Double, nil
+	case "boolean":
+		return decls.Bool, nil
+	case "null":
+		return decls.Null, nil
+	case "array":
+		if s.Items == nil {
+			return decls.NewListType(decls.Dyn), nil
+		}
+		elem, err := c.convert(name+".items", s.Items)
+		if err != nil {
+			return nil, err
+		}
+		return decls.NewListType(elem), nil
+	case "object":
+		var additional jsonSchema
+		if len(s.AdditionalProperties) == 0 ||
+			json.Unmarshal(s.AdditionalProperties, &additional) != nil {
+			// Absent or boolean additional properties.
+			return decls.NewMapType(decls.String, decls.Dyn), nil
+		}
+		value, err := c.convert(name+".value", &additional)
+		if err != nil {
+			return nil, err
+		}
+		return decls.NewMapType(decls.String, value), nil
+	}
+	return decls.Dyn, nil
+}
+
+// fields returns the types of the properties of an object schema, including
+// those of the members of an 'allOf' schema.
+func (c *converter) fields(name string, s *jsonSchema) (map[string]*checkedpb.Type, error) {
+	fields, err := c.properties(name, s.Properties)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range s.AllOf {
+		var memberFields map[string]*checkedpb.Type
+		if member.Ref != "" {
+			t, err := c.convert(name, member)
+			if err != nil {
+				return nil, err
+			}
+			memberFields = c.schema.objects[t.GetMessageType()]
+		} else {
+			memberFields, err = c.fields(name, member)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for field, t := range memberFields {
+			fields[field] = t
+		}
+	}
+	return fields, nil
+}
+
+func (c *converter) properties(name string,
+	props map[string]*jsonSchema) (map[string]*checkedpb.Type, error) {
+	fields := make(map[string]*checkedpb.Type, len(props))
+	for field, s := range props {
+		t, err := c.convert(name+"."+field, s)
+		if err != nil {
+			return nil, err
+		}
+		fields[field] = t
+	}
+	return fields, nil
+}
+
+// isObject returns whether a schema is converted to an object type.
+func isObject(s *jsonSchema) bool {
+	if s.Ref != "" {
+		return false
+	}
+	t := schemaType(s)
+	return (t == "object" || t == "") && (len(s.Properties) != 0 || len(s.AllOf) != 0)
+}
+
+// schemaType returns the type of a schema, ignoring 'null' within a list of
+// types, or the empty string when the schema has no single type.
+func schemaType(s *jsonSchema) string {
+	if len(s.Type) == 0 || len(s.AnyOf) != 0 || len(s.OneOf) != 0 {
+		return ""
+	}
+	var t string
+	if json.Unmarshal(s.Type, &t) == nil {
+		return t
+	}
+	var ts []string
+	if json.Unmarshal(s.Type, &ts) != nil {
+		return ""
+	}
+	t = ""
+	for _, elem := range ts {
+		if elem == "null" {
+			continue
+		}
+		if t != "" {
+			return ""
+		}
+		t = elem
+	}
+	return t
+}
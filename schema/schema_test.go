@@ -0,0 +1,203 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/packages"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/parser"
+	checkedpb "github.com/google/cel-spec/proto/checked/v1/checked"
+)
+
+const openAPI = `{
+  "openapi": "3.0.0",
+  "components": {"schemas": {
+    "Pet": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "age": {"type": "integer"},
+        "weight": {"type": "number"},
+        "tags": {"type": "array", "items": {"type": "string"}},
+        "owner": {"type": "object", "properties": {"email": {"type": "string"}}},
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+        "parent": {"$ref": "#/components/schemas/Pet"},
+        "status": {"$ref": "#/components/schemas/Status"}
+      }
+    },
+    "Dog": {"allOf": [
+      {"$ref": "#/components/schemas/Pet"},
+      {"type": "object", "properties": {"goodBoy": {"type": "boolean"}}}
+    ]},
+    "Status": {"type": "string", "enum": ["available", "sold"]}
+  }}
+}`
+
+const jsonSchemaDoc = `{
+  "type": "object",
+  "properties": {
+    "request": {
+      "type": "object",
+      "properties": {
+        "size": {"type": "integer"},
+        "path": {"type": ["string", "null"]},
+        "headers": {"type": "object"},
+        "body": {"oneOf": [{"type": "string"}, {"type": "object"}]},
+        "user": {"$ref": "#/$defs/User"}
+      }
+    }
+  },
+  "$defs": {
+    "User": {"properties": {"roles": {"type": "array"}}}
+  }
+}`
+
+func TestLoad_OpenAPI(t *testing.T) {
+	s, err := Load([]byte(openAPI), "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTypes := []string{"api.Dog", "api.Pet", "api.Pet.owner"}
+	if names := s.TypeNames(); !reflect.DeepEqual(names, wantTypes) {
+		t.Errorf("Got types %v, wanted %v", names, wantTypes)
+	}
+	if len(s.Declarations()) != 0 {
+		t.Errorf("Got declarations %v, wanted none", s.Declarations())
+	}
+	provider := s.Provider(types.NewProvider())
+	wantFields := []string{"email"}
+	if names, found := provider.FieldNames("api.Pet.owner"); !found ||
+		!reflect.DeepEqual(names, wantFields) {
+		t.Errorf("Got fields %v, wanted %v", names, wantFields)
+	}
+	if _, found := provider.FieldNames("api.Cat"); found {
+		t.Error("Got fields for an undefined schema")
+	}
+	pet, err := s.NewVar("pet", "Pet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dog, err := s.NewVar("dog", "Dog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.NewVar("cat", "Cat"); err == nil {
+		t.Error("Got nil error for an undefined schema")
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: `pet.name`, want: `string`},
+		{expr: `pet.age + 1`, want: `int`},
+		{expr: `pet.weight * 2.0`, want: `double`},
+		{expr: `pet.tags[0]`, want: `string`},
+		{expr: `pet.owner.email`, want: `string`},
+		{expr: `pet.labels["color"]`, want: `string`},
+		{expr: `pet.parent.parent.name`, want: `string`},
+		{expr: `pet.status == "sold"`, want: `bool`},
+		{expr: `dog.goodBoy && dog.age > 2`, want: `bool`},
+		{expr: `has(pet.owner)`, want: `bool`},
+	}
+	for _, tst := range tests {
+		checked, errs := check(s, tst.expr, pet, dog)
+		if len(errs.GetErrors()) != 0 {
+			t.Errorf("%s: %s", tst.expr, errs.ToDisplayString())
+			continue
+		}
+		if got := checker.FormatCheckedType(checked.TypeMap[checked.Expr.Id]); got != tst.want {
+			t.Errorf("%s: got type %s, wanted %s", tst.expr, got, tst.want)
+		}
+	}
+	for _, text := range []string{`pet.nickname`, `pet.age + "1"`, `pet.owner.phone`} {
+		if _, errs := check(s, text, pet, dog); len(errs.GetErrors()) == 0 {
+			t.Errorf("%s: got no errors, wanted a type error", text)
+		}
+	}
+}
+
+func TestLoad_JSONSchema(t *testing.T) {
+	s, err := Load([]byte(jsonSchemaDoc), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	declarations := s.Declarations()
+	if len(declarations) != 1 || declarations[0].Name != "request" {
+		t.Fatalf("Got declarations %v, wanted 'request'", declarations)
+	}
+	text := `request.size < 1024 && request.path.matchesGlob("/api/**") && ` +
+		`"admin" in request.user.roles && has(request.headers.host)`
+	checked, errs := check(s, text, declarations...)
+	if len(errs.GetErrors()) != 0 {
+		t.Fatal(errs.ToDisplayString())
+	}
+	for _, text := range []string{`request.body.size()`, `request.headers.foo`} {
+		if _, errs := check(s, text, declarations...); len(errs.GetErrors()) != 0 {
+			t.Errorf("%s: %s", text, errs.ToDisplayString())
+		}
+	}
+
+	// Values of the object types are JSON maps.
+	var request map[string]interface{}
+	err = json.Unmarshal([]byte(`{"size": 10, "path": "/api/v1",
+		"headers": {"host": "example.com"}, "user": {"roles": ["admin"]}}`), &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request["size"] = int64(request["size"].(float64))
+	provider := s.Provider(types.NewProvider())
+	i := interpreter.NewStandardIntepreter(packages.DefaultPackage, provider)
+	eval := i.NewInterpretable(interpreter.NewCheckedProgram(checked))
+	result, _ := eval.Eval(interpreter.NewActivation(map[string]interface{}{
+		"request": request}))
+	if result != types.True {
+		t.Errorf("Got %v, wanted true", result)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	for _, doc := range []string{
+		`{"definitions": `,
+		`{"definitions": {"A": {"$ref": "#/definitions/B"}}}`,
+		`{"definitions": {"A": {"$ref": "http://example.com/a.json"}}}`,
+		`{"definitions": {"A": {"type": "array", "items": {"$ref": "#/definitions/A"}}}}`,
+	} {
+		if _, err := Load([]byte(doc), ""); err == nil {
+			t.Errorf("%s: got nil error, wanted an error", doc)
+		}
+	}
+}
+
+func check(s *Schema, text string,
+	declarations ...*checkedpb.Decl) (*checkedpb.CheckedExpr, *common.Errors) {
+	src := common.NewStringSource(text, "<input>")
+	parsed, errs := parser.Parse(src, parser.AllMacros)
+	if len(errs.GetErrors()) != 0 {
+		return nil, errs
+	}
+	errs = common.NewErrors(src)
+	env := checker.NewStandardEnv(packages.NewPackage("api"),
+		s.Provider(types.NewProvider()), errs)
+	env.Add(declarations...)
+	return checker.Check(parsed, env), errs
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/cel-go/checker"
 	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast/astpb"
 	"github.com/google/cel-go/common/packages"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
@@ -40,12 +41,10 @@ func (s *CelServer) Parse(ctx context.Context, in *cspb.ParseRequest) (*cspb.Par
 	expr, errs := parser.Parse(src, macs)
 	resp := cspb.ParseResponse{}
 	if len(errs.GetErrors()) == 0 {
-		// Success
+		// Success, possibly with warnings
 		resp.ParsedExpr = expr
-	} else {
-		// Failure
-		appendErrors(errs, &resp.Issues)
 	}
+	appendErrors(errs, &resp.Issues)
 	return &resp, nil
 }
 
@@ -60,7 +59,8 @@ func (s *CelServer) Check(ctx context.Context, in *cspb.CheckRequest) (*cspb.Che
 	}
 	pkg := packages.NewPackage(in.Container)
 	typeProvider := types.NewProvider()
-	errs := common.NewErrors(common.NewInfoSource(in.ParsedExpr.SourceInfo))
+	errs := common.NewErrors(common.NewInfoSource(
+		astpb.FromSourceInfo(in.ParsedExpr.SourceInfo)))
 	var env *checker.Env
 	if in.NoStdEnv {
 		env = checker.NewEnv(pkg, typeProvider, errs)
@@ -71,12 +71,10 @@ func (s *CelServer) Check(ctx context.Context, in *cspb.CheckRequest) (*cspb.Che
 	c := checker.Check(in.ParsedExpr, env)
 	resp := cspb.CheckResponse{}
 	if len(errs.GetErrors()) == 0 {
-		// Success
+		// Success, possibly with warnings
 		resp.CheckedExpr = c
-	} else {
-		// Failure
-		appendErrors(errs, &resp.Issues)
 	}
+	appendErrors(errs, &resp.Issues)
 	return &resp, nil
 }
 
@@ -113,15 +111,23 @@ func (s *CelServer) Eval(ctx context.Context, in *cspb.EvalRequest) (*cspb.EvalR
 	return &cspb.EvalResponse{Result: resultExprVal}, nil
 }
 
-// appendErrors converts the errors from errs to Status messages
+// appendErrors converts the errors and warnings from errs to Status messages
 // and appends them to the list of issues.
 func appendErrors(errs *common.Errors, issues *[]*rpc.Status) {
-	for _, e := range errs.GetErrors() {
-		status := ErrToStatus(e, cspb.StatusDetails_ERROR)
+	for _, e := range errs.GetIssues() {
+		status := ErrToStatus(e, statusSeverity[e.Severity])
 		*issues = append(*issues, status)
 	}
 }
 
+// statusSeverity maps the severities of issues to those of Status messages,
+// which have no informational severity.
+var statusSeverity = map[common.Severity]cspb.StatusDetails_Severity{
+	common.SeverityError:   cspb.StatusDetails_ERROR,
+	common.SeverityWarning: cspb.StatusDetails_WARNING,
+	common.SeverityInfo:    cspb.StatusDetails_SEVERITY_UNSPECIFIED,
+}
+
 // ErrToStatus converts an Error to a Status message with the given severity.
 func ErrToStatus(e common.Error, severity cspb.StatusDetails_Severity) *rpc.Status {
 	detail := cspb.StatusDetails{
@@ -149,12 +155,30 @@ func ErrToStatus(e common.Error, severity cspb.StatusDetails_Severity) *rpc.Stat
 
 func RefValueToExprValue(res ref.Value) (*eval.ExprValue, error) {
 	if types.IsError(res) {
+		var errs []*types.Err
+		switch v := res.(type) {
+		case *types.Err:
+			errs = []*types.Err{v}
+		case types.ErrorSet:
+			errs = v
+		}
+		errSet := &eval.ErrorSet{}
+		for _, err := range errs {
+			errSet.Errors = append(errSet.Errors, &rpc.Status{
+				Code:    int32(codes.InvalidArgument),
+				Message: err.String()})
+		}
 		return &eval.ExprValue{
-			Kind: &eval.ExprValue_Error{}}, nil
+			Kind: &eval.ExprValue_Error{Error: errSet}}, nil
 	}
 	if types.IsUnknown(res) {
+		var exprs []int64
+		if unknown, isSet := types.MergeUnknowns(res).(*types.UnknownSet); isSet {
+			exprs = unknown.Ids()
+		}
 		return &eval.ExprValue{
-			Kind: &eval.ExprValue_Unknown{}}, nil
+			Kind: &eval.ExprValue_Unknown{
+				Unknown: &eval.UnknownSet{Exprs: exprs}}}, nil
 	}
 	v, err := RefValueToValue(res)
 	if err != nil {
@@ -291,7 +315,7 @@ func ExprValueToRefValue(ev *eval.ExprValue) (ref.Value, error) {
 		// TODO(jimlarson) make a convention for this.
 		return types.NewErr("XXX add details later"), nil
 	case *eval.ExprValue_Unknown:
-		return types.Unknown(ev.GetUnknown().Exprs), nil
+		return types.NewUnknownSet(ev.GetUnknown().GetExprs()...), nil
 	}
 	return nil, status.New(codes.InvalidArgument, "unknown ExprValue kind").Err()
 }